@@ -0,0 +1,397 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// apiPrefix is the base path of the versioned automation API.
+const apiPrefix = "/api/v1"
+
+// apiTokenEnv names the bearer token required by the automation API. The API
+// is disabled while it is empty.
+const apiTokenEnv = "APP_API_TOKEN"
+
+type apiConnectRequest struct {
+	Host    string `json:"host"`
+	Profile string `json:"profile"`
+	Engine  string `json:"engine"`
+}
+
+// apiFieldWrite targets a field by its index in the screen's field list, or a
+// 1-based screen position. Index writes replace the field contents; position
+// writes type the value at that position, like a recorded FillString step.
+type apiFieldWrite struct {
+	Index  *int   `json:"index"`
+	Row    int    `json:"row"`
+	Column int    `json:"column"`
+	Value  string `json:"value"`
+}
+
+type apiKeyRequest struct {
+	Key string `json:"key"`
+}
+
+type apiSession struct {
+	ID        string `json:"id"`
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	Profile   string `json:"profile,omitempty"`
+	Engine    string `json:"engine"`
+	Connected bool   `json:"connected"`
+}
+
+type apiPosition struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+type apiField struct {
+	Index       int    `json:"index"`
+	Row         int    `json:"row"`
+	Column      int    `json:"column"`
+	EndRow      int    `json:"endRow"`
+	EndColumn   int    `json:"endColumn"`
+	Protected   bool   `json:"protected"`
+	Numeric     bool   `json:"numeric"`
+	Hidden      bool   `json:"hidden"`
+	Intensified bool   `json:"intensified"`
+	Color       int    `json:"color"`
+	Highlight   int    `json:"highlight"`
+	Value       string `json:"value"`
+}
+
+// apiScreen is the structured screen returned by the API. Rows and columns
+// are 1-based, matching recording coordinates.
+type apiScreen struct {
+	Rows           int          `json:"rows"`
+	Columns        int          `json:"columns"`
+	Formatted      bool         `json:"formatted"`
+	Text           []string     `json:"text"`
+	Fields         []apiField   `json:"fields"`
+	Cursor         *apiPosition `json:"cursor,omitempty"`
+	KeyboardLocked *bool        `json:"keyboardLocked,omitempty"`
+	Model          string       `json:"model,omitempty"`
+}
+
+// APITokenMiddleware authenticates API requests with the APP_API_TOKEN bearer
+// token instead of the browser session cookie.
+func APITokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := strings.TrimSpace(os.Getenv(apiTokenEnv))
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "API disabled: set " + apiTokenEnv})
+			return
+		}
+		token, ok := bearerToken(c.Request)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="3270Web"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing API token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// isAPITokenRequest reports whether r is an API call carrying a bearer token.
+// Browsers cannot attach that header cross-site, so the Origin/Referer CSRF
+// check does not apply.
+func isAPITokenRequest(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		return false
+	}
+	_, ok := bearerToken(r)
+	return ok
+}
+
+func registerAPIRoutes(r *gin.Engine, app *App) {
+	api := r.Group(apiPrefix, APITokenMiddleware())
+	api.POST("/sessions", app.APICreateSessionHandler)
+	api.GET("/sessions/:id", app.APIGetSessionHandler)
+	api.DELETE("/sessions/:id", app.APICloseSessionHandler)
+	api.GET("/sessions/:id/screen", app.APIScreenHandler)
+	api.POST("/sessions/:id/fields", app.APIWriteFieldHandler)
+	api.POST("/sessions/:id/keys", app.APISendKeyHandler)
+}
+
+// apiSessionFromPath returns the API-owned session named in the URL. Browser
+// sessions are not reachable through the API.
+func (app *App) apiSessionFromPath(c *gin.Context) *session.Session {
+	s, ok := app.SessionManager.GetSession(c.Param("id"))
+	if ok {
+		withSessionLock(s, func() {
+			ok = s.APIOwned
+		})
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return nil
+	}
+	return s
+}
+
+func (app *App) APICreateSessionHandler(c *gin.Context) {
+	var req apiConnectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+		return
+	}
+	hostname := strings.TrimSpace(req.Host)
+	if profile := strings.TrimSpace(req.Profile); profile != "" {
+		hostname = profileHost(profile)
+	}
+	if hostname == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "host or profile is required"})
+		return
+	}
+	if req.Engine != "" {
+		if _, ok := hostEngineValues[strings.ToLower(req.Engine)]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "engine must be one of " + strings.Join(sortedKeys(hostEngineValues), ", ")})
+			return
+		}
+	}
+	s, err := app.openSession(hostname, req.Engine)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": connectErrorMessage(hostname, err)})
+		return
+	}
+	withSessionLock(s, func() {
+		s.APIOwned = true
+		s.User = "api"
+		s.ClientAddr = c.ClientIP()
+	})
+	c.JSON(http.StatusCreated, apiSessionInfo(s))
+}
+
+func (app *App) APIGetSessionHandler(c *gin.Context) {
+	s := app.apiSessionFromPath(c)
+	if s == nil {
+		return
+	}
+	c.JSON(http.StatusOK, apiSessionInfo(s))
+}
+
+func (app *App) APICloseSessionHandler(c *gin.Context) {
+	s := app.apiSessionFromPath(c)
+	if s == nil {
+		return
+	}
+	app.closeSession(s)
+	c.Status(http.StatusNoContent)
+}
+
+func (app *App) APIScreenHandler(c *gin.Context) {
+	s := app.apiSessionFromPath(c)
+	if s == nil {
+		return
+	}
+	app.writeAPIScreen(c, s)
+}
+
+func (app *App) APIWriteFieldHandler(c *gin.Context) {
+	s := app.apiSessionFromPath(c)
+	if s == nil {
+		return
+	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	var req apiFieldWrite
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+		return
+	}
+	if err := s.Host.UpdateScreen(); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "update screen failed: " + err.Error()})
+		return
+	}
+
+	if req.Index != nil {
+		fields := s.Host.GetScreen().Fields
+		if *req.Index < 0 || *req.Index >= len(fields) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "field index out of range"})
+			return
+		}
+		f := fields[*req.Index]
+		if f.IsProtected() {
+			c.JSON(http.StatusConflict, gin.H{"error": "field is protected"})
+			return
+		}
+		f.SetValue(req.Value)
+		err := s.Host.SubmitScreen()
+		app.auditEvent(s, audit.SourceAPI, "", []audit.Field{audit.HiddenField(f, f.StartY+1, f.StartX+1, req.Value)}, err)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "write failed: " + err.Error()})
+			return
+		}
+	} else {
+		screen := s.Host.GetScreen()
+		if req.Row < 1 || req.Column < 1 || req.Row > screen.Height || req.Column > screen.Width {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "row and column must be 1-based positions on the screen"})
+			return
+		}
+		err := s.Host.WriteStringAt(req.Row-1, req.Column-1, req.Value)
+		field := audit.HiddenField(screen.GetFieldAt(req.Column-1, req.Row-1), req.Row, req.Column, req.Value)
+		app.auditEvent(s, audit.SourceAPI, "", []audit.Field{field}, err)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "write failed: " + err.Error()})
+			return
+		}
+	}
+	app.writeAPIScreen(c, s)
+}
+
+func (app *App) APISendKeyHandler(c *gin.Context) {
+	s := app.apiSessionFromPath(c)
+	if s == nil {
+		return
+	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	var req apiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+		return
+	}
+	key, ok := apiKey(req.Key)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown key: " + req.Key})
+		return
+	}
+	app.noteScreenAID(s, key)
+	err := s.Host.SendKey(key)
+	app.auditEvent(s, audit.SourceAPI, key, nil, err)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "send key failed: " + err.Error()})
+		return
+	}
+	app.writeAPIScreen(c, s)
+}
+
+// apiKey normalizes key, rejecting names normalizeKey would silently map to
+// Enter.
+func apiKey(key string) (string, bool) {
+	trimmed := strings.TrimSpace(key)
+	if trimmed == "" {
+		return "", false
+	}
+	normalized := normalizeKey(trimmed)
+	if normalized == "Enter" && !strings.EqualFold(trimmed, "Enter") {
+		return "", false
+	}
+	return normalized, true
+}
+
+func (app *App) writeAPIScreen(c *gin.Context, s *session.Session) {
+	if err := s.Host.UpdateScreen(); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "update screen failed: " + err.Error()})
+		return
+	}
+	app.observeScreen(s)
+	c.JSON(http.StatusOK, buildAPIScreen(s.Host.GetScreen()))
+}
+
+func apiSessionInfo(s *session.Session) apiSession {
+	info := apiSession{ID: s.ID}
+	withSessionLock(s, func() {
+		info.Host = s.TargetHost
+		info.Port = s.TargetPort
+		info.Profile = s.Profile
+		info.Engine = s.HostEngine
+	})
+	info.Connected = s.Host.IsConnected()
+	return info
+}
+
+func buildAPIScreen(screen *host.Screen) apiScreen {
+	out := apiScreen{
+		Rows:      screen.Height,
+		Columns:   screen.Width,
+		Formatted: screen.IsFormatted,
+		Text:      make([]string, 0, len(screen.Buffer)),
+		Fields:    make([]apiField, 0, len(screen.Fields)),
+	}
+	for _, row := range screen.Buffer {
+		line := make([]rune, len(row))
+		for i, r := range row {
+			if r == 0 {
+				r = ' '
+			}
+			line[i] = r
+		}
+		out.Text = append(out.Text, string(line))
+	}
+	for i, f := range screen.Fields {
+		if f.IsHidden() {
+			blankFieldText(out.Text, f, screen.Width)
+		}
+		field := apiField{
+			Index:       i,
+			Row:         f.StartY + 1,
+			Column:      f.StartX + 1,
+			EndRow:      f.EndY + 1,
+			EndColumn:   f.EndX + 1,
+			Protected:   f.IsProtected(),
+			Numeric:     f.IsNumeric(),
+			Hidden:      f.IsHidden(),
+			Intensified: f.IsIntensified(),
+			Color:       f.Color,
+			Highlight:   f.ExtendedHighlight,
+		}
+		if !field.Hidden {
+			field.Value = f.GetValue()
+		}
+		out.Fields = append(out.Fields, field)
+	}
+	if row, col, ok := screen.StatusCursor(); ok {
+		out.Cursor = &apiPosition{Row: row + 1, Column: col + 1}
+	}
+	if locked, ok := screen.StatusKeyboardLocked(); ok {
+		out.KeyboardLocked = &locked
+	}
+	if model, ok := screen.StatusModel(); ok {
+		out.Model = model
+	}
+	return out
+}
+
+// blankFieldText clears a hidden field's characters from the screen text so
+// passwords are not exposed through the API.
+func blankFieldText(text []string, f *host.Field, width int) {
+	if width <= 0 {
+		return
+	}
+	start := f.StartY*width + f.StartX
+	end := f.EndY*width + f.EndX
+	for pos := start; pos <= end; pos++ {
+		row, col := pos/width, pos%width
+		if row < 0 || row >= len(text) {
+			continue
+		}
+		line := []rune(text[row])
+		if col < len(line) {
+			line[col] = ' '
+			text[row] = string(line)
+		}
+	}
+}
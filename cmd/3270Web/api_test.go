@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const testAPIToken = "test-token"
+
+func setupAPITest(t *testing.T) (*App, *gin.Engine, *host.MockHost, *session.Session) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv(apiTokenEnv, testAPIToken)
+
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	mockHost.Connected = true
+	screen := mockHost.Screen
+	copy(screen.Buffer[0], []rune("USER"))
+	copy(screen.Buffer[1], []rune("PASS"))
+	copy(screen.Buffer[1][5:], []rune("SECRET"))
+	screen.Fields = []*host.Field{
+		host.NewField(screen, host.AttrProtected, 0, 0, 3, 0, host.AttrColDefault, host.AttrEhDefault),
+		host.NewField(screen, 0, 5, 0, 12, 0, host.AttrColGreen, host.AttrEhUnderscore),
+		host.NewField(screen, host.AttrDisp1|host.AttrDisp2, 5, 1, 12, 1, host.AttrColDefault, host.AttrEhDefault),
+	}
+	screen.Status = "U F U C(localhost) I 2 24 80 4 6 0x0 -"
+
+	app := &App{
+		SessionManager: session.NewManager(),
+		chaosEngines:   newChaosEngineStore(),
+	}
+	sess := app.SessionManager.CreateSession(mockHost)
+	withSessionLock(sess, func() {
+		sess.APIOwned = true
+		sess.TargetHost = "localhost"
+		sess.TargetPort = 3270
+		sess.HostEngine = hostEngineS3270
+	})
+
+	r := gin.New()
+	r.Use(OriginRefererCheckMiddleware())
+	registerAPIRoutes(r, app)
+	return app, r, mockHost, sess
+}
+
+func apiRequest(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPITokenMiddleware(t *testing.T) {
+	_, r, _, sess := setupAPITest(t)
+	path := "/api/v1/sessions/" + sess.ID
+
+	if w := apiRequest(r, http.MethodGet, path, "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("missing token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := apiRequest(r, http.MethodGet, path, "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := apiRequest(r, http.MethodGet, path, testAPIToken, ""); w.Code != http.StatusOK {
+		t.Fatalf("valid token status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	t.Setenv(apiTokenEnv, "")
+	if w := apiRequest(r, http.MethodGet, path, testAPIToken, ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("disabled API status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAPISessionHandlers(t *testing.T) {
+	app, r, _, sess := setupAPITest(t)
+
+	w := apiRequest(r, http.MethodGet, "/api/v1/sessions/"+sess.ID, testAPIToken, "")
+	var info apiSession
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode session: %v", err)
+	}
+	want := apiSession{ID: sess.ID, Host: "localhost", Port: 3270, Engine: hostEngineS3270, Connected: true}
+	if info != want {
+		t.Fatalf("session = %+v, want %+v", info, want)
+	}
+
+	browser := app.SessionManager.CreateSession(&host.MockHost{})
+	if w := apiRequest(r, http.MethodGet, "/api/v1/sessions/"+browser.ID, testAPIToken, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("browser session status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "invalid json", body: "{"},
+		{name: "missing host", body: `{"engine":"native"}`},
+		{name: "invalid engine", body: `{"host":"localhost","engine":"bogus"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := apiRequest(r, http.MethodPost, "/api/v1/sessions", testAPIToken, tt.body); w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	if w := apiRequest(r, http.MethodDelete, "/api/v1/sessions/"+sess.ID, testAPIToken, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if _, ok := app.SessionManager.GetSession(sess.ID); ok {
+		t.Fatal("session still registered after delete")
+	}
+}
+
+func TestAPIScreenHandler(t *testing.T) {
+	_, r, _, sess := setupAPITest(t)
+
+	w := apiRequest(r, http.MethodGet, "/api/v1/sessions/"+sess.ID+"/screen", testAPIToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var screen apiScreen
+	if err := json.Unmarshal(w.Body.Bytes(), &screen); err != nil {
+		t.Fatalf("decode screen: %v", err)
+	}
+	if screen.Rows != 24 || screen.Columns != 80 || len(screen.Text) != 24 {
+		t.Fatalf("dimensions = %dx%d with %d rows of text, want 24x80", screen.Rows, screen.Columns, len(screen.Text))
+	}
+	if !strings.HasPrefix(screen.Text[0], "USER ") {
+		t.Fatalf("text[0] = %q, want USER prefix", screen.Text[0])
+	}
+	if strings.Contains(screen.Text[1], "SECRET") {
+		t.Fatalf("text[1] = %q exposes hidden field", screen.Text[1])
+	}
+	if len(screen.Fields) != 3 {
+		t.Fatalf("fields = %d, want 3", len(screen.Fields))
+	}
+	input := screen.Fields[1]
+	if input.Row != 1 || input.Column != 6 || input.Protected || input.Color != host.AttrColGreen {
+		t.Fatalf("field 1 = %+v, want unprotected green field at 1,6", input)
+	}
+	if hidden := screen.Fields[2]; !hidden.Hidden || hidden.Value != "" {
+		t.Fatalf("field 2 = %+v, want hidden field without value", hidden)
+	}
+	if screen.Cursor == nil || *screen.Cursor != (apiPosition{Row: 5, Column: 7}) {
+		t.Fatalf("cursor = %+v, want 5,7", screen.Cursor)
+	}
+	if screen.KeyboardLocked == nil || *screen.KeyboardLocked {
+		t.Fatalf("keyboardLocked = %v, want false", screen.KeyboardLocked)
+	}
+}
+
+func TestAPIWriteFieldAndSendKey(t *testing.T) {
+	_, r, mockHost, sess := setupAPITest(t)
+	base := "/api/v1/sessions/" + sess.ID
+
+	if w := apiRequest(r, http.MethodPost, base+"/fields", testAPIToken, `{"index":1,"value":"ALICE"}`); w.Code != http.StatusOK {
+		t.Fatalf("index write status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if w := apiRequest(r, http.MethodPost, base+"/fields", testAPIToken, `{"row":3,"column":10,"value":"XY"}`); w.Code != http.StatusOK {
+		t.Fatalf("position write status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if w := apiRequest(r, http.MethodPost, base+"/keys", testAPIToken, `{"key":"pf3"}`); w.Code != http.StatusOK {
+		t.Fatalf("key status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	want := []string{"submit", "write", "key:PF(3)"}
+	if strings.Join(mockHost.Commands, ",") != strings.Join(want, ",") {
+		t.Fatalf("commands = %v, want %v", mockHost.Commands, want)
+	}
+	if got := string(mockHost.Screen.Buffer[2][9:11]); got != "XY" {
+		t.Fatalf("buffer at 3,10 = %q, want XY", got)
+	}
+	if got := mockHost.Screen.Fields[1].Value; got != "ALICE" {
+		t.Fatalf("field value = %q, want ALICE", got)
+	}
+
+	errorTests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{name: "protected field", path: "/fields", body: `{"index":0,"value":"X"}`, want: http.StatusConflict},
+		{name: "index out of range", path: "/fields", body: `{"index":9,"value":"X"}`, want: http.StatusBadRequest},
+		{name: "position off screen", path: "/fields", body: `{"row":25,"column":1,"value":"X"}`, want: http.StatusBadRequest},
+		{name: "unknown key", path: "/keys", body: `{"key":"bogus"}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := apiRequest(r, http.MethodPost, base+tt.path, testAPIToken, tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -13,7 +13,7 @@ func TestBuildS3270Args_QuoteHandling(t *testing.T) {
 		Additional: `-set "toggle allowRemote"`,
 	}
 
-	args := buildS3270Args(opts, "")
+	args := buildS3270Args(opts, "", nil)
 
 	// We expect the arguments to be preserved as ["-set", "toggle allowRemote"]
 	// But strings.Fields will split it into ["-set", "\"toggle", "allowRemote\""]
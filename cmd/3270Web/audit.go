@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/chaos"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const (
+	defaultAuditLimit = 200
+	maxAuditLimit     = 5000
+)
+
+// auditEvent appends one submission on s to the audit log, and to the
+// session's screen recording when one is running. Failures to write are
+// logged rather than returned: the terminal keeps working, and the
+// application log shows the gap.
+func (app *App) auditEvent(s *session.Session, source, aid string, fields []audit.Field, sendErr error) {
+	app.auditEventBy(s, nil, source, aid, fields, sendErr)
+}
+
+// auditEventBy is auditEvent for input typed by the viewer v of a shared
+// session, who is logged as the user in place of the session's owner.
+func (app *App) auditEventBy(s *session.Session, v *shareViewer, source, aid string, fields []audit.Field, sendErr error) {
+	if s == nil {
+		return
+	}
+	app.recordScreenKey(s, aid, fields)
+	if app.audit == nil {
+		return
+	}
+	e := audit.Event{Source: source, AID: aid, Fields: fields}
+	withSessionLock(s, func() {
+		e.User = s.User
+		e.Client = s.ClientAddr
+		e.SessionID = s.ID
+		e.Host = auditHost(s)
+	})
+	if v != nil {
+		e.User = v.name
+		e.Client = v.addr
+	}
+	if sendErr != nil {
+		e.Error = sendErr.Error()
+	}
+	if err := app.audit.Append(e); err != nil {
+		log.Printf("Warning: could not write audit log: %v", err)
+	}
+}
+
+// shareEventLogger returns the logEvent hook that writes the events of a
+// share of s to the audit log, or nil when auditing is off. The owner and
+// host are read when the share starts because events are logged under the
+// share's lock.
+func (app *App) shareEventLogger(s *session.Session) func(shareEvent) {
+	if app.audit == nil {
+		return nil
+	}
+	base := audit.Event{Source: audit.SourceShare}
+	withSessionLock(s, func() {
+		base.User = s.User
+		base.Client = s.ClientAddr
+		base.SessionID = s.ID
+		base.Host = auditHost(s)
+	})
+	return func(e shareEvent) {
+		ev := base
+		ev.Time = e.Time
+		ev.Share = e.Type
+		ev.Viewer = e.Viewer
+		if err := app.audit.Append(ev); err != nil {
+			log.Printf("Warning: could not write audit log: %v", err)
+		}
+	}
+}
+
+// auditHost names the session's target as host:port, with the profile when
+// the session was opened from one. Callers hold the session lock.
+func auditHost(s *session.Session) string {
+	if s.TargetHost == "" {
+		return s.Profile
+	}
+	port := s.TargetPort
+	if port == 0 {
+		port = 3270
+	}
+	address := net.JoinHostPort(s.TargetHost, strconv.Itoa(port))
+	if s.Profile != "" {
+		return address + " (" + profileHostPrefix + s.Profile + ")"
+	}
+	return address
+}
+
+// changedAuditFields lists the input fields the user changed on screen, with
+// hidden values redacted.
+func changedAuditFields(screen *host.Screen) []audit.Field {
+	if screen == nil {
+		return nil
+	}
+	var fields []audit.Field
+	for _, f := range screen.Fields {
+		if f.IsProtected() || !f.Changed {
+			continue
+		}
+		value := normalizeInputValue(strings.Join(f.GetValueLines(), "\n"))
+		fields = append(fields, audit.HiddenField(f, f.StartY+1, f.StartX+1, value))
+	}
+	return fields
+}
+
+// auditPlaybackFill notes a workflow fill for the next key's audit event.
+// step is the fill as written in the workflow, so secret placeholders are
+// logged rather than their values; fills into hidden fields are redacted.
+func auditPlaybackFill(s *session.Session, step session.WorkflowStep) {
+	if step.Coordinates == nil {
+		return
+	}
+	row, col := step.Coordinates.Row, step.Coordinates.Column
+	var f *host.Field
+	if screen := s.Host.GetScreen(); screen != nil {
+		f = screen.GetFieldAt(col-1, row-1)
+	}
+	field := audit.HiddenField(f, row, col, step.Text)
+	withSessionLock(s, func() {
+		if s.Playback != nil {
+			s.Playback.AuditFields = append(s.Playback.AuditFields, field)
+		}
+	})
+}
+
+// auditPlaybackKey logs a key sent by workflow playback with the fills made
+// since the previous key.
+func (app *App) auditPlaybackKey(s *session.Session, key string, sendErr error) {
+	var fields []audit.Field
+	withSessionLock(s, func() {
+		if s.Playback != nil {
+			fields = s.Playback.AuditFields
+			s.Playback.AuditFields = nil
+		}
+	})
+	app.auditEvent(s, audit.SourcePlayback, key, fields, sendErr)
+}
+
+// chaosAuditor returns a chaos OnAttempt callback that logs each submission
+// the engine makes on s. Hidden fields carry secret placeholders already and
+// are redacted as well.
+func (app *App) chaosAuditor(s *session.Session) func(chaos.Attempt) {
+	return func(a chaos.Attempt) {
+		var fields []audit.Field
+		for _, w := range a.FieldWrites {
+			if !w.Success {
+				continue
+			}
+			if w.Secret {
+				fields = append(fields, audit.RedactedField(w.Row, w.Column, w.Value))
+				continue
+			}
+			fields = append(fields, audit.Field{Row: w.Row, Column: w.Column, Value: w.Value})
+		}
+		var err error
+		if a.Error != "" {
+			err = fmt.Errorf("%s", a.Error)
+		}
+		app.auditEvent(s, audit.SourceChaos, a.AIDKey, fields, err)
+	}
+}
+
+// cliUserName names the operating-system user running a CLI command, for
+// the audit log.
+func cliUserName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "cli"
+}
+
+// auditQuery reads the search filters shared by the audit endpoints.
+func auditQuery(c *gin.Context) (audit.Query, error) {
+	q := audit.Query{
+		User:      strings.TrimSpace(c.Query("user")),
+		Source:    strings.TrimSpace(c.Query("source")),
+		SessionID: strings.TrimSpace(c.Query("session")),
+		Host:      strings.TrimSpace(c.Query("host")),
+		AID:       strings.TrimSpace(c.Query("aid")),
+		Text:      strings.TrimSpace(c.Query("q")),
+	}
+	var err error
+	if q.Since, err = parseAuditTime(c.Query("since"), false); err != nil {
+		return q, fmt.Errorf("since: %w", err)
+	}
+	if q.Until, err = parseAuditTime(c.Query("until"), true); err != nil {
+		return q, fmt.Errorf("until: %w", err)
+	}
+	return q, nil
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp or a date. A date used as the
+// end of a range covers the whole day.
+func parseAuditTime(value string, end bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("use a date (2006-01-02) or an RFC 3339 time, got %q", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// AuditSearchHandler handles GET /audit, returning the newest matching
+// events first.
+func (app *App) AuditSearchHandler(c *gin.Context) {
+	q, err := auditQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := defaultAuditLimit
+	if v := strings.TrimSpace(c.Query("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit)})
+			return
+		}
+		limit = n
+	}
+	events, total, err := app.audit.Search(q, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "total": total})
+}
+
+// AuditExportHandler handles GET /audit/export, downloading the matching
+// events as JSON lines in the order they were logged.
+func (app *App) AuditExportHandler(c *gin.Context) {
+	q, err := auditQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filename := fmt.Sprintf("3270Web-audit-%s.jsonl", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	if _, err := app.audit.Export(c.Writer, q); err != nil {
+		log.Printf("Audit export failed: %v", err)
+	}
+}
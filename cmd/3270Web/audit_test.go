@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/chaos"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func newAuditTestApp(t *testing.T) *App {
+	t.Helper()
+	return &App{
+		SessionManager: session.NewManager(),
+		chaosEngines:   newChaosEngineStore(),
+		audit:          audit.NewLog(filepath.Join(t.TempDir(), "audit.jsonl")),
+	}
+}
+
+func auditEvents(t *testing.T, app *App) []audit.Event {
+	t.Helper()
+	events, _, err := app.audit.Search(audit.Query{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestProcessSubmitWritesAuditEvent(t *testing.T) {
+	app := newAuditTestApp(t)
+	s, mockHost := newCheckStepSession(t)
+	s.ID, s.User, s.ClientAddr = "sess-1", "alice", "10.0.0.5"
+	s.TargetHost, s.TargetPort = "mainframe.example", 23
+	mockHost.Screen.IsFormatted = true
+
+	form := map[string]string{"key": "PF3", "field_7_4": "BOB", "field_7_5": "HUNTER2"}
+	if err := app.processSubmit(s, func(k string) string { return form[k] }); err != nil {
+		t.Fatalf("processSubmit: %v", err)
+	}
+
+	events := auditEvents(t, app)
+	if len(events) != 1 {
+		t.Fatalf("audit events = %d, want 1", len(events))
+	}
+	e := events[0]
+	if e.User != "alice" || e.Client != "10.0.0.5" || e.SessionID != "sess-1" || e.Host != "mainframe.example:23" || e.AID != "PF(3)" || e.Source != audit.SourceTerminal {
+		t.Fatalf("event = %+v, want alice's PF(3) on mainframe.example:23", e)
+	}
+	want := []audit.Field{
+		{Row: 5, Column: 8, Value: "BOB"},
+		{Row: 6, Column: 8, Value: audit.Redacted, Redacted: true},
+	}
+	if len(e.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %+v", e.Fields, want)
+	}
+	for i := range want {
+		if e.Fields[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, e.Fields[i], want[i])
+		}
+	}
+}
+
+func TestPlaybackAuditKeepsSecretPlaceholders(t *testing.T) {
+	t.Setenv("APP_SECRET_PASSWD", "TOPSECRET")
+	app := newAuditTestApp(t)
+	s, _ := newCheckStepSession(t)
+	s.ID, s.User = "sess-2", "bob"
+
+	steps := []session.WorkflowStep{
+		{Type: "FillString", Coordinates: &session.WorkflowCoordinates{Row: 5, Column: 8}, Text: "CAROL"},
+		{Type: "FillString", Coordinates: &session.WorkflowCoordinates{Row: 6, Column: 8}, Text: "${secret:PASSWD}"},
+		{Type: "PressEnter"},
+	}
+	for _, step := range steps {
+		if err := app.applyWorkflowStep(s, step); err != nil {
+			t.Fatalf("applyWorkflowStep(%s): %v", step.Type, err)
+		}
+	}
+
+	events := auditEvents(t, app)
+	if len(events) != 1 || events[0].Source != audit.SourcePlayback || events[0].AID != "Enter" {
+		t.Fatalf("events = %+v, want one playback Enter", events)
+	}
+	fields := events[0].Fields
+	if len(fields) != 2 || fields[0].Value != "CAROL" || !fields[1].Redacted || fields[1].Value != audit.Redacted {
+		t.Fatalf("fields = %+v, want CAROL and a redacted password", fields)
+	}
+	if s.Playback.AuditFields != nil {
+		t.Fatalf("pending fills = %+v, want them cleared by the key", s.Playback.AuditFields)
+	}
+}
+
+func TestChaosAuditorRedactsSecrets(t *testing.T) {
+	app := newAuditTestApp(t)
+	s := &session.Session{ID: "sess-3", User: "qa"}
+	app.chaosAuditor(s)(chaos.Attempt{
+		AIDKey: "PF(1)",
+		Error:  "host gone",
+		FieldWrites: []chaos.AttemptFieldWrite{
+			{Row: 2, Column: 3, Value: "XYZ", Success: true},
+			{Row: 3, Column: 3, Value: "${secret:PASS}", Secret: true, Success: true},
+			{Row: 4, Column: 3, Value: "dropped", Error: "write failed"},
+		},
+	})
+
+	events := auditEvents(t, app)
+	if len(events) != 1 {
+		t.Fatalf("audit events = %d, want 1", len(events))
+	}
+	e := events[0]
+	if e.Source != audit.SourceChaos || e.AID != "PF(1)" || e.Error != "host gone" || e.User != "qa" {
+		t.Fatalf("event = %+v, want qa's failed chaos PF(1)", e)
+	}
+	if len(e.Fields) != 2 || e.Fields[0].Value != "XYZ" || e.Fields[1].Value != audit.Redacted {
+		t.Fatalf("fields = %+v, want the written fields with the secret redacted", e.Fields)
+	}
+}
+
+func TestAuditEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := newAuditTestApp(t)
+	for _, s := range []*session.Session{
+		{ID: "a", User: "alice", TargetHost: "prod"},
+		{ID: "b", User: "bob", TargetHost: "test"},
+		{ID: "c", User: "alice", TargetHost: "test"},
+	} {
+		app.auditEvent(s, audit.SourceTerminal, "Enter", []audit.Field{{Row: 1, Column: 1, Value: "LOGON " + s.ID}}, nil)
+	}
+	app.auditEvent(&session.Session{ID: "d", User: "alice"}, audit.SourceAPI, "Clear", nil, errors.New("not connected"))
+
+	r := gin.New()
+	r.GET("/audit", app.AuditSearchHandler)
+	r.GET("/audit/export", app.AuditExportHandler)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	tests := []struct {
+		query     string
+		wantIDs   string
+		wantTotal int
+	}{
+		{"", "d,c,b,a", 4},
+		{"?user=ALICE", "d,c,a", 3},
+		{"?user=alice&host=test", "c", 1},
+		{"?aid=clear", "d", 1},
+		{"?q=logon+b", "b", 1},
+		{"?source=terminal&limit=2", "c,b", 3},
+		{"?until=2000-01-01", "", 0},
+	}
+	for _, tt := range tests {
+		w := get("/audit" + tt.query)
+		var resp struct {
+			Events []audit.Event `json:"events"`
+			Total  int           `json:"total"`
+		}
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+			t.Fatalf("GET /audit%s = %d %s", tt.query, w.Code, w.Body.String())
+		}
+		var ids []string
+		for _, e := range resp.Events {
+			ids = append(ids, e.SessionID)
+		}
+		if got := strings.Join(ids, ","); got != tt.wantIDs || resp.Total != tt.wantTotal {
+			t.Errorf("GET /audit%s = %q (total %d), want %q (total %d)", tt.query, got, resp.Total, tt.wantIDs, tt.wantTotal)
+		}
+	}
+
+	for _, query := range []string{"?limit=0", "?since=yesterday"} {
+		if w := get("/audit" + query); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /audit%s = %d, want 400", query, w.Code)
+		}
+	}
+
+	w := get("/audit/export?user=alice")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Code != http.StatusOK || len(lines) != 3 || !strings.Contains(w.Header().Get("Content-Disposition"), ".jsonl") {
+		t.Fatalf("export = %d %q, want three lines as a .jsonl attachment", w.Code, w.Body.String())
+	}
+	var first audit.Event
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.SessionID != "a" {
+		t.Fatalf("first exported event = %+v, %v, want session a (log order)", first, err)
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/auth"
+)
+
+// Web UI sign-in is enabled once users.json holds an account or an OIDC
+// issuer is configured. Until then every visitor is treated as an admin, as
+// before accounts existed.
+const (
+	oidcIssuerEnv       = "APP_OIDC_ISSUER"
+	oidcClientIDEnv     = "APP_OIDC_CLIENT_ID"
+	oidcClientSecretEnv = "APP_OIDC_CLIENT_SECRET"
+	oidcRedirectURLEnv  = "APP_OIDC_REDIRECT_URL"
+	oidcRoleClaimEnv    = "APP_OIDC_ROLE_CLAIM"
+	oidcDefaultRoleEnv  = "APP_OIDC_DEFAULT_ROLE"
+)
+
+const (
+	authCookieName = "3270Web_auth"
+	authSessionTTL = 12 * time.Hour
+	oidcPendingTTL = 10 * time.Minute
+	authContextKey = "authUser"
+)
+
+// authUser is a signed-in browser.
+type authUser struct {
+	Name    string
+	Role    auth.Role
+	Source  string
+	expires time.Time
+}
+
+type oidcPending struct {
+	nonce    string
+	verifier string
+	next     string
+	expires  time.Time
+}
+
+// authStore holds signed-in browsers keyed by their auth cookie, and OIDC
+// logins waiting for the provider's callback keyed by state.
+type authStore struct {
+	mu       sync.Mutex
+	sessions map[string]*authUser
+	pending  map[string]oidcPending
+	provider *auth.OIDCProvider
+}
+
+func newAuthStore() *authStore {
+	return &authStore{sessions: make(map[string]*authUser), pending: make(map[string]oidcPending)}
+}
+
+func newAuthToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (as *authStore) create(name string, role auth.Role, source string) (string, error) {
+	token, err := newAuthToken()
+	if err != nil {
+		return "", err
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	now := time.Now()
+	for t, u := range as.sessions {
+		if now.After(u.expires) {
+			delete(as.sessions, t)
+		}
+	}
+	as.sessions[token] = &authUser{Name: name, Role: role, Source: source, expires: now.Add(authSessionTTL)}
+	return token, nil
+}
+
+func (as *authStore) get(token string) (*authUser, bool) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	u, ok := as.sessions[token]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(u.expires) {
+		delete(as.sessions, token)
+		return nil, false
+	}
+	return u, true
+}
+
+func (as *authStore) delete(token string) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	delete(as.sessions, token)
+}
+
+func (as *authStore) addPending(state string, p oidcPending) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	now := time.Now()
+	for s, old := range as.pending {
+		if now.After(old.expires) {
+			delete(as.pending, s)
+		}
+	}
+	as.pending[state] = p
+}
+
+// takePending returns and forgets the login started with state, so each
+// callback can be used once.
+func (as *authStore) takePending(state string) (oidcPending, bool) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	p, ok := as.pending[state]
+	delete(as.pending, state)
+	if !ok || time.Now().After(p.expires) {
+		return oidcPending{}, false
+	}
+	return p, true
+}
+
+func oidcConfigFromEnv() (auth.OIDCConfig, bool) {
+	cfg := auth.OIDCConfig{
+		Issuer:       strings.TrimRight(strings.TrimSpace(os.Getenv(oidcIssuerEnv)), "/"),
+		ClientID:     strings.TrimSpace(os.Getenv(oidcClientIDEnv)),
+		ClientSecret: os.Getenv(oidcClientSecretEnv),
+		RedirectURL:  strings.TrimSpace(os.Getenv(oidcRedirectURLEnv)),
+		RoleClaim:    strings.TrimSpace(os.Getenv(oidcRoleClaimEnv)),
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "role"
+	}
+	if role, err := auth.ParseRole(os.Getenv(oidcDefaultRoleEnv)); err == nil {
+		cfg.DefaultRole = role
+	}
+	return cfg, cfg.Issuer != ""
+}
+
+// oidcProvider returns the provider for the current settings, running
+// discovery again when they have changed.
+func (app *App) oidcProvider(c *gin.Context) (*auth.OIDCProvider, error) {
+	cfg, ok := oidcConfigFromEnv()
+	if !ok {
+		return nil, errors.New("single sign-on is not configured")
+	}
+	if cfg.RedirectURL == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		cfg.RedirectURL = scheme + "://" + c.Request.Host + "/auth/oidc/callback"
+	}
+	app.logins.mu.Lock()
+	cached := app.logins.provider
+	app.logins.mu.Unlock()
+	if cached != nil && cached.Config() == cfg {
+		return cached, nil
+	}
+	provider, err := auth.NewOIDCProvider(c.Request.Context(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	app.logins.mu.Lock()
+	app.logins.provider = provider
+	app.logins.mu.Unlock()
+	return provider, nil
+}
+
+// authEnabled reports whether the web UI requires sign-in.
+func (app *App) authEnabled() bool {
+	if _, ok := oidcConfigFromEnv(); ok {
+		return true
+	}
+	if app.users == nil {
+		return false
+	}
+	has, err := app.users.HasUsers()
+	if err != nil {
+		// An unreadable users file must not open the UI to everyone.
+		log.Printf("Warning: could not read users file: %v", err)
+		return true
+	}
+	return has
+}
+
+func (app *App) currentUser(c *gin.Context) *authUser {
+	if v, ok := c.Get(authContextKey); ok {
+		return v.(*authUser)
+	}
+	token, err := c.Cookie(authCookieName)
+	if err != nil || token == "" || app.logins == nil {
+		return nil
+	}
+	u, ok := app.logins.get(token)
+	if !ok {
+		return nil
+	}
+	return u
+}
+
+func isPublicAuthPath(path string) bool {
+	switch path {
+	case "/login", "/logout", "/auth/oidc/login", "/auth/oidc/callback":
+		return true
+	}
+	return strings.HasPrefix(path, "/static/") || path == apiPrefix || strings.HasPrefix(path, apiPrefix+"/")
+}
+
+// AuthMiddleware requires a signed-in user for everything except the login
+// pages, static assets and the token-authenticated automation API.
+func (app *App) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublicAuthPath(c.Request.URL.Path) || !app.authEnabled() {
+			c.Next()
+			return
+		}
+		u := app.currentUser(c)
+		if u == nil {
+			if c.Request.Method == http.MethodGet && !strings.Contains(c.GetHeader("Accept"), "application/json") && c.GetHeader("Upgrade") == "" {
+				c.Redirect(http.StatusFound, "/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "sign in required"})
+			return
+		}
+		c.Set(authContextKey, u)
+		c.Next()
+	}
+}
+
+// requireRole rejects signed-in users whose role does not include role.
+func (app *App) requireRole(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.authEnabled() {
+			c.Next()
+			return
+		}
+		u := app.currentUser(c)
+		if u == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "sign in required"})
+			return
+		}
+		if !u.Role.Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("this action requires the %s role", role)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// authView is what templates need to show the signed-in user and hide
+// controls their role cannot use.
+type authView struct {
+	Enabled  bool
+	User     string
+	Role     string
+	CanTest  bool
+	CanAdmin bool
+}
+
+func (app *App) authView(c *gin.Context) authView {
+	if !app.authEnabled() {
+		return authView{CanTest: true, CanAdmin: true}
+	}
+	u := app.currentUser(c)
+	if u == nil {
+		return authView{Enabled: true}
+	}
+	return authView{
+		Enabled:  true,
+		User:     u.Name,
+		Role:     string(u.Role),
+		CanTest:  u.Role.Allows(auth.RoleTester),
+		CanAdmin: u.Role.Allows(auth.RoleAdmin),
+	}
+}
+
+// safeNextPath keeps post-login redirects on this site.
+func safeNextPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") || strings.HasPrefix(next, "/login") {
+		return "/"
+	}
+	return next
+}
+
+func setAuthCookie(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	maxAge := int(authSessionTTL / time.Second)
+	if token == "" {
+		maxAge = -1
+	}
+	c.SetCookie(authCookieName, token, maxAge, "/", "", c.Request.TLS != nil, true)
+}
+
+func (app *App) renderLoginPage(c *gin.Context, status int, next, username, loginError string) {
+	hasUsers := false
+	if app.users != nil {
+		hasUsers, _ = app.users.HasUsers()
+	}
+	_, oidcEnabled := oidcConfigFromEnv()
+	c.HTML(status, "login.html", gin.H{
+		"Next":         safeNextPath(next),
+		"Username":     username,
+		"LoginError":   loginError,
+		"LocalEnabled": hasUsers,
+		"OIDCEnabled":  oidcEnabled,
+	})
+}
+
+// signIn starts an auth session for the browser and redirects to next.
+func (app *App) signIn(c *gin.Context, name string, role auth.Role, source, next string) {
+	token, err := app.logins.create(name, role, source)
+	if err != nil {
+		app.renderLoginPage(c, http.StatusInternalServerError, next, name, "Could not start a session.")
+		return
+	}
+	log.Printf("User %s signed in (%s, role %s)", name, source, role)
+	setAuthCookie(c, token)
+	c.Redirect(http.StatusFound, safeNextPath(next))
+}
+
+// LoginPageHandler handles GET /login.
+func (app *App) LoginPageHandler(c *gin.Context) {
+	if !app.authEnabled() || app.currentUser(c) != nil {
+		c.Redirect(http.StatusFound, safeNextPath(c.Query("next")))
+		return
+	}
+	app.renderLoginPage(c, http.StatusOK, c.Query("next"), "", "")
+}
+
+// LoginHandler handles POST /login with a local account's credentials.
+func (app *App) LoginHandler(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("username"))
+	next := c.PostForm("next")
+	if app.users == nil {
+		app.renderLoginPage(c, http.StatusBadRequest, next, name, "Local accounts are not available.")
+		return
+	}
+	user, err := app.users.Authenticate(name, c.PostForm("password"))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Printf("Failed sign-in for %q from %s", name, c.ClientIP())
+			app.renderLoginPage(c, http.StatusUnauthorized, next, name, "Invalid username or password.")
+			return
+		}
+		log.Printf("Sign-in failed: %v", err)
+		app.renderLoginPage(c, http.StatusInternalServerError, next, name, "Could not read the user accounts.")
+		return
+	}
+	app.signIn(c, user.Name, user.Role, "local", next)
+}
+
+// LogoutHandler handles POST /logout. It also closes the browser's terminal
+// sessions so the next user does not inherit them.
+func (app *App) LogoutHandler(c *gin.Context) {
+	for _, s := range app.browserSessions(c) {
+		app.closeSession(s)
+	}
+	if token, err := c.Cookie(authCookieName); err == nil && app.logins != nil {
+		app.logins.delete(token)
+	}
+	setSessionCookie(c, "3270Web_session", "")
+	setSessionCookie(c, sessionListCookie, "")
+	setAuthCookie(c, "")
+	c.Redirect(http.StatusFound, "/login")
+}
+
+// OIDCLoginHandler handles GET /auth/oidc/login by sending the browser to
+// the identity provider.
+func (app *App) OIDCLoginHandler(c *gin.Context) {
+	provider, err := app.oidcProvider(c)
+	if err != nil {
+		log.Printf("OIDC login unavailable: %v", err)
+		app.renderLoginPage(c, http.StatusServiceUnavailable, c.Query("next"), "", "Single sign-on is unavailable. "+err.Error())
+		return
+	}
+	state, nonce, verifier, err := auth.NewOIDCRequest()
+	if err != nil {
+		app.renderLoginPage(c, http.StatusInternalServerError, c.Query("next"), "", "Could not start single sign-on.")
+		return
+	}
+	app.logins.addPending(state, oidcPending{
+		nonce:    nonce,
+		verifier: verifier,
+		next:     safeNextPath(c.Query("next")),
+		expires:  time.Now().Add(oidcPendingTTL),
+	})
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, nonce, verifier))
+}
+
+// OIDCCallbackHandler handles GET /auth/oidc/callback, where the identity
+// provider returns the browser with an authorization code.
+func (app *App) OIDCCallbackHandler(c *gin.Context) {
+	if msg := c.Query("error"); msg != "" {
+		if desc := c.Query("error_description"); desc != "" {
+			msg += ": " + desc
+		}
+		app.renderLoginPage(c, http.StatusUnauthorized, "", "", "Single sign-on failed. "+msg)
+		return
+	}
+	pending, ok := app.logins.takePending(c.Query("state"))
+	if !ok {
+		app.renderLoginPage(c, http.StatusBadRequest, "", "", "Single sign-on expired or was already used. Please try again.")
+		return
+	}
+	provider, err := app.oidcProvider(c)
+	if err != nil {
+		app.renderLoginPage(c, http.StatusServiceUnavailable, pending.next, "", "Single sign-on is unavailable. "+err.Error())
+		return
+	}
+	id, err := provider.Exchange(c.Request.Context(), c.Query("code"), pending.verifier, pending.nonce)
+	if err != nil {
+		log.Printf("OIDC sign-in failed: %v", err)
+		app.renderLoginPage(c, http.StatusUnauthorized, pending.next, "", "Single sign-on failed. "+err.Error())
+		return
+	}
+	app.signIn(c, id.Name, id.Role, "oidc", pending.next)
+}
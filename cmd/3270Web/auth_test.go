@@ -0,0 +1,242 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/auth"
+	"github.com/jnnngs/3270Web/internal/auth/oidctest"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func newAuthTestRouter(t *testing.T) (*App, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	app := &App{
+		SessionManager: session.NewManager(),
+		chaosEngines:   newChaosEngineStore(),
+		users:          auth.NewStore(filepath.Join(t.TempDir(), "users.json")),
+		logins:         newAuthStore(),
+	}
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) }
+	r := gin.New()
+	r.LoadHTMLGlob(filepath.Join("..", "..", "web", "templates", "*.html"))
+	r.Use(app.AuthMiddleware())
+	r.GET("/login", app.LoginPageHandler)
+	r.POST("/login", app.LoginHandler)
+	r.POST("/logout", app.LogoutHandler)
+	r.GET("/auth/oidc/login", app.OIDCLoginHandler)
+	r.GET("/auth/oidc/callback", app.OIDCCallbackHandler)
+	r.GET("/", ok)
+	r.GET("/api/settings", app.requireRole(auth.RoleAdmin), ok)
+	r.GET("/chaos/status", app.requireRole(auth.RoleTester), ok)
+	r.GET("/api/v1/sessions", ok)
+	return app, r
+}
+
+func serveAuth(r *gin.Engine, method, target, cookie string, form url.Values) *httptest.ResponseRecorder {
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, target, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if strings.HasPrefix(target, "/api/") || strings.HasPrefix(target, "/chaos/") {
+		req.Header.Set("Accept", "application/json")
+	}
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: authCookieName, Value: cookie})
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func authCookie(w *httptest.ResponseRecorder) string {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == authCookieName {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestAuthDisabledWithoutAccounts(t *testing.T) {
+	_, r := newAuthTestRouter(t)
+	for _, target := range []string{"/", "/api/settings", "/chaos/status"} {
+		if w := serveAuth(r, http.MethodGet, target, "", nil); w.Code != http.StatusOK {
+			t.Errorf("GET %s without accounts = %d, want 200", target, w.Code)
+		}
+	}
+}
+
+func TestLocalLoginAndRoles(t *testing.T) {
+	app, r := newAuthTestRouter(t)
+	for _, u := range []struct {
+		name string
+		role auth.Role
+	}{{"root", auth.RoleAdmin}, {"qa", auth.RoleTester}, {"op", auth.RoleUser}} {
+		if err := app.users.Put(u.name, u.name+"-pw", u.role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := serveAuth(r, http.MethodGet, "/", "", nil)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login?next=%2F" {
+		t.Fatalf("anonymous GET / = %d %q, want redirect to /login", w.Code, w.Header().Get("Location"))
+	}
+	if w := serveAuth(r, http.MethodGet, "/api/settings", "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous GET /api/settings = %d, want 401", w.Code)
+	}
+	if w := serveAuth(r, http.MethodGet, "/api/v1/sessions", "", nil); w.Code != http.StatusOK {
+		t.Fatalf("automation API = %d, want it left to its bearer token", w.Code)
+	}
+	if w := serveAuth(r, http.MethodGet, "/login", "", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="password"`) {
+		t.Fatalf("login page = %d, want the password form", w.Code)
+	}
+
+	w = serveAuth(r, http.MethodPost, "/login", "", url.Values{"username": {"op"}, "password": {"wrong"}})
+	if w.Code != http.StatusUnauthorized || authCookie(w) != "" {
+		t.Fatalf("wrong password = %d, want 401 without a cookie", w.Code)
+	}
+
+	login := func(name string) string {
+		t.Helper()
+		w := serveAuth(r, http.MethodPost, "/login", "", url.Values{"username": {name}, "password": {name + "-pw"}, "next": {"/screen"}})
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/screen" || authCookie(w) == "" {
+			t.Fatalf("login %s = %d %q, want redirect to /screen with a cookie", name, w.Code, w.Header().Get("Location"))
+		}
+		return authCookie(w)
+	}
+	tests := []struct {
+		user   string
+		target string
+		want   int
+	}{
+		{"op", "/", http.StatusOK},
+		{"op", "/chaos/status", http.StatusForbidden},
+		{"op", "/api/settings", http.StatusForbidden},
+		{"qa", "/chaos/status", http.StatusOK},
+		{"qa", "/api/settings", http.StatusForbidden},
+		{"root", "/chaos/status", http.StatusOK},
+		{"root", "/api/settings", http.StatusOK},
+	}
+	cookies := map[string]string{}
+	for _, tt := range tests {
+		if cookies[tt.user] == "" {
+			cookies[tt.user] = login(tt.user)
+		}
+		if w := serveAuth(r, http.MethodGet, tt.target, cookies[tt.user], nil); w.Code != tt.want {
+			t.Errorf("%s GET %s = %d, want %d", tt.user, tt.target, w.Code, tt.want)
+		}
+	}
+
+	if w := serveAuth(r, http.MethodPost, "/logout", cookies["root"], nil); w.Code != http.StatusFound {
+		t.Fatalf("logout = %d, want 302", w.Code)
+	}
+	if w := serveAuth(r, http.MethodGet, "/api/settings", cookies["root"], nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("after logout = %d, want 401", w.Code)
+	}
+}
+
+func TestSafeNextPath(t *testing.T) {
+	tests := map[string]string{
+		"/screen?x=1":        "/screen?x=1",
+		"":                   "/",
+		"https://evil.test/": "/",
+		"//evil.test":        "/",
+		"/\\evil.test":       "/",
+		"/login?next=/":      "/",
+	}
+	for next, want := range tests {
+		if got := safeNextPath(next); got != want {
+			t.Errorf("safeNextPath(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
+
+func TestOIDCLoginAgainstLocalProvider(t *testing.T) {
+	idp := oidctest.NewServer("3270web", "client-secret")
+	defer idp.Close()
+	t.Setenv(oidcIssuerEnv, idp.URL)
+	t.Setenv(oidcClientIDEnv, "3270web")
+	t.Setenv(oidcClientSecretEnv, "client-secret")
+	t.Setenv(oidcRedirectURLEnv, "http://3270web.test/auth/oidc/callback")
+	t.Setenv(oidcRoleClaimEnv, "groups")
+	t.Setenv(oidcDefaultRoleEnv, "")
+	_, r := newAuthTestRouter(t)
+
+	// The browser leg: our redirect to the provider, and its redirect back.
+	follow := func() *httptest.ResponseRecorder {
+		t.Helper()
+		w := serveAuth(r, http.MethodGet, "/auth/oidc/login?next=%2Fscreen", "", nil)
+		if w.Code != http.StatusFound || !strings.HasPrefix(w.Header().Get("Location"), idp.URL+"/authorize?") {
+			t.Fatalf("oidc login = %d %q, want redirect to the provider", w.Code, w.Header().Get("Location"))
+		}
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Get(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		callback, _ := url.Parse(resp.Header.Get("Location"))
+		return serveAuth(r, http.MethodGet, callback.RequestURI(), "", nil)
+	}
+
+	idp.SetClaims(map[string]any{"sub": "42", "preferred_username": "dana", "groups": []any{"tester"}})
+	w := follow()
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/screen" || authCookie(w) == "" {
+		t.Fatalf("oidc callback = %d %q, want redirect to /screen with a cookie", w.Code, w.Header().Get("Location"))
+	}
+	cookie := authCookie(w)
+	if w := serveAuth(r, http.MethodGet, "/chaos/status", cookie, nil); w.Code != http.StatusOK {
+		t.Fatalf("tester from OIDC GET /chaos/status = %d, want 200", w.Code)
+	}
+	if w := serveAuth(r, http.MethodGet, "/api/settings", cookie, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("tester from OIDC GET /api/settings = %d, want 403", w.Code)
+	}
+
+	// Without a role claim or default role the user is turned away.
+	idp.SetClaims(map[string]any{"sub": "43", "preferred_username": "eve"})
+	if w := follow(); w.Code != http.StatusUnauthorized || authCookie(w) != "" {
+		t.Fatalf("oidc user without role = %d, want 401 without a cookie", w.Code)
+	}
+	if w := serveAuth(r, http.MethodGet, "/auth/oidc/callback?state=unknown&code=x", "", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("callback with unknown state = %d, want 400", w.Code)
+	}
+}
+
+func TestCLIUsers(t *testing.T) {
+	c, stdout, stderr, _ := newTestCLI(t)
+	c.app.users = auth.NewStore(filepath.Join(t.TempDir(), "users.json"))
+
+	c.stdin = strings.NewReader("pa55word\n")
+	if code, _ := c.run([]string{"users", "add", "alice", "-role", "admin"}); code != cliExitOK {
+		t.Fatalf("users add = %d, want %d (stderr %q)", code, cliExitOK, stderr.String())
+	}
+	if _, err := c.app.users.Authenticate("alice", "pa55word"); err != nil {
+		t.Fatalf("Authenticate = %v, want the password without the newline", err)
+	}
+	stdout.Reset()
+	if code, _ := c.run([]string{"users", "list"}); code != cliExitOK || stdout.String() != "alice\tadmin\n" {
+		t.Fatalf("users list = %d, %q, want alice admin", code, stdout.String())
+	}
+	if code, _ := c.run([]string{"users", "add", "bob", "-role", "root"}); code != cliExitUsage {
+		t.Fatalf("add with unknown role = %d, want %d", code, cliExitUsage)
+	}
+	if code, _ := c.run([]string{"users", "remove", "alice"}); code != cliExitOK {
+		t.Fatalf("users remove = %d, want %d", code, cliExitOK)
+	}
+	if code, _ := c.run([]string{"users", "remove", "alice"}); code != cliExitFailed {
+		t.Fatalf("second remove = %d, want %d", code, cliExitFailed)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jnnngs/3270Web/internal/chaos"
+	"github.com/jnnngs/3270Web/internal/secrets"
 	"github.com/jnnngs/3270Web/internal/session"
 )
 
@@ -102,6 +103,13 @@ type chaosStartRequest struct {
 	MaxFieldLength          int            `json:"maxFieldLength"`
 	Hints                   []chaos.Hint   `json:"hints"`
 	ExcludeNoProgressEvents *bool          `json:"excludeNoProgressEvents"`
+	Strategy                string         `json:"strategy"`
+	ValueWeights            map[string]int `json:"valueWeights"`
+	MaskRules               string         `json:"maskRules"`
+	AutoMask                *bool          `json:"autoMask"`
+	// FindingRules replace the saved findings oracle rules for this run
+	// when present.
+	FindingRules []chaos.FindingRule `json:"findingRules"`
 }
 
 // ChaosStartHandler handles POST /chaos/start.
@@ -111,6 +119,10 @@ func (app *App) ChaosStartHandler(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
 		return
 	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	app.chaosEngines.clearRemoved(s.ID)
 
 	// Parse optional body; fall back to defaults if empty.
@@ -144,7 +156,35 @@ func (app *App) ChaosStartHandler(c *gin.Context) {
 		if req.ExcludeNoProgressEvents != nil {
 			cfg.ExcludeNoProgressEvents = *req.ExcludeNoProgressEvents
 		}
+		strategy, err := chaos.ParseStrategy(req.Strategy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cfg.Strategy = strategy
+		if len(req.ValueWeights) > 0 {
+			if err := chaos.ValidateValueWeights(req.ValueWeights); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			cfg.ValueWeights = req.ValueWeights
+		}
+		masks, err := chaos.ParseMaskRules(req.MaskRules)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cfg.MaskRules = masks
+		if req.AutoMask != nil {
+			cfg.AutoMask = *req.AutoMask
+		}
 	}
+	rules, err := app.chaosFindingRules(req.FindingRules)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cfg.FindingRules = rules
 	if len(cfg.Hints) == 0 {
 		if savedHints, err := app.loadChaosHints(); err == nil && len(savedHints) > 0 {
 			cfg.Hints = savedHints
@@ -155,6 +195,7 @@ func (app *App) ChaosStartHandler(c *gin.Context) {
 		cfg.ExportHost = s.TargetHost
 		cfg.ExportPort = s.TargetPort
 	})
+	cfg.OnAttempt = app.onChaosAttempt(s)
 
 	// Reject if an engine is already running for this session.
 	if existing, ok := app.chaosEngines.get(s.ID); ok {
@@ -234,7 +275,7 @@ func (app *App) ChaosRemoveHandler(c *gin.Context) {
 
 // ChaosStatusHandler handles GET /chaos/status.
 func (app *App) ChaosStatusHandler(c *gin.Context) {
-	s := app.getSession(c)
+	s := app.peekSession(c)
 	if s == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
 		return
@@ -283,6 +324,17 @@ func (app *App) ChaosStatusHandler(c *gin.Context) {
 					resp["mindMap"] = mindMapJSON
 				}
 			}
+			if len(loaded.Findings) > 0 {
+				findings := make([]chaos.Finding, len(loaded.Findings))
+				for i, f := range loaded.Findings {
+					f.Steps = nil
+					findings[i] = f
+				}
+				resp["findings"] = findings
+			}
+			if len(loaded.MaskRules) > 0 {
+				resp["maskRules"] = loaded.MaskRules
+			}
 		}
 		c.JSON(http.StatusOK, resp)
 		return
@@ -406,6 +458,7 @@ func (app *App) syncChaosStatus(s *session.Session, eng *chaos.Engine) {
 			runID := chaos.NewRunID()
 			snapshot := eng.Snapshot(runID)
 			app.chaosEngines.setLoadedRun(s.ID, snapshot)
+			app.storeRecordedSecrets(eng.Secrets())
 			withSessionLock(s, func() {
 				if s.Chaos != nil {
 					s.Chaos.LoadedRunID = runID
@@ -543,6 +596,10 @@ func (app *App) ChaosResumeHandler(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
 		return
 	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 
 	loaded, ok := app.chaosEngines.getLoadedRun(s.ID)
 	if !ok {
@@ -598,7 +655,35 @@ func (app *App) ChaosResumeHandler(c *gin.Context) {
 		if req.ExcludeNoProgressEvents != nil {
 			cfg.ExcludeNoProgressEvents = *req.ExcludeNoProgressEvents
 		}
+		strategy, err := chaos.ParseStrategy(req.Strategy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cfg.Strategy = strategy
+		if len(req.ValueWeights) > 0 {
+			if err := chaos.ValidateValueWeights(req.ValueWeights); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			cfg.ValueWeights = req.ValueWeights
+		}
+		masks, err := chaos.ParseMaskRules(req.MaskRules)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cfg.MaskRules = masks
+		if req.AutoMask != nil {
+			cfg.AutoMask = *req.AutoMask
+		}
+	}
+	rules, err := app.chaosFindingRules(req.FindingRules)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	cfg.FindingRules = rules
 	if len(cfg.Hints) == 0 {
 		if savedHints, err := app.loadChaosHints(); err == nil && len(savedHints) > 0 {
 			cfg.Hints = savedHints
@@ -609,6 +694,8 @@ func (app *App) ChaosResumeHandler(c *gin.Context) {
 		cfg.ExportHost = s.TargetHost
 		cfg.ExportPort = s.TargetPort
 	})
+	cfg.OnAttempt = app.onChaosAttempt(s)
+	cfg.SecretLookup = app.secrets.lookup
 
 	var eng *chaos.Engine
 	withSessionLock(s, func() {
@@ -858,6 +945,16 @@ func looksLikeTransactionCode(value string) bool {
 	return hasLetter
 }
 
+// onChaosAttempt returns the engine's OnAttempt callback for s: each
+// submission is audited, and labels the screen it leads to in the history.
+func (app *App) onChaosAttempt(s *session.Session) func(chaos.Attempt) {
+	logAttempt := app.chaosAuditor(s)
+	return func(a chaos.Attempt) {
+		app.noteScreenAID(s, a.AIDKey)
+		logAttempt(a)
+	}
+}
+
 func (app *App) loadChaosHints() ([]chaos.Hint, error) {
 	if app == nil || strings.TrimSpace(app.chaosHintsPath) == "" {
 		return []chaos.Hint{}, nil
@@ -935,6 +1032,18 @@ func chaosStatusToJSON(st chaos.Status) gin.H {
 	if mindMapJSON := chaosMindMapToJSON(st.MindMap); mindMapJSON != nil {
 		resp["mindMap"] = mindMapJSON
 	}
+	if st.Strategy != "" {
+		resp["strategy"] = st.Strategy
+	}
+	if st.Strategy == chaos.StrategyFrontier {
+		resp["frontierAreas"] = st.FrontierAreas
+	}
+	if len(st.Findings) > 0 {
+		resp["findings"] = st.Findings
+	}
+	if len(st.MaskRules) > 0 {
+		resp["maskRules"] = st.MaskRules
+	}
 	if st.Error != "" {
 		resp["error"] = st.Error
 	}
@@ -1024,7 +1133,7 @@ func chaosAttemptToJSON(attempt chaos.Attempt) gin.H {
 			"error":   fw.Error,
 		})
 	}
-	return gin.H{
+	resp := gin.H{
 		"attempt":        attempt.Attempt,
 		"time":           attempt.Time.Format(time.RFC3339),
 		"fromHash":       attempt.FromHash,
@@ -1036,6 +1145,10 @@ func chaosAttemptToJSON(attempt chaos.Attempt) gin.H {
 		"error":          attempt.Error,
 		"fieldWrites":    fieldWrites,
 	}
+	if attempt.NavigateTo != "" {
+		resp["navigateTo"] = attempt.NavigateTo
+	}
+	return resp
 }
 
 func sessionChaosAttemptToJSON(attempt session.ChaosAttempt) gin.H {
@@ -1050,7 +1163,7 @@ func sessionChaosAttemptToJSON(attempt session.ChaosAttempt) gin.H {
 			"error":   fw.Error,
 		})
 	}
-	return gin.H{
+	resp := gin.H{
 		"attempt":        attempt.Attempt,
 		"time":           attempt.Time.Format(time.RFC3339),
 		"fromHash":       attempt.FromHash,
@@ -1062,6 +1175,10 @@ func sessionChaosAttemptToJSON(attempt session.ChaosAttempt) gin.H {
 		"error":          attempt.Error,
 		"fieldWrites":    fieldWrites,
 	}
+	if attempt.NavigateTo != "" {
+		resp["navigateTo"] = attempt.NavigateTo
+	}
+	return resp
 }
 
 func toSessionChaosAttempts(attempts []chaos.Attempt) []session.ChaosAttempt {
@@ -1106,6 +1223,7 @@ func toSessionChaosAttemptValue(attempt chaos.Attempt) session.ChaosAttempt {
 		Transitioned:   attempt.Transitioned,
 		Error:          attempt.Error,
 		FieldWrites:    fieldWrites,
+		NavigateTo:     attempt.NavigateTo,
 	}
 }
 
@@ -1318,7 +1436,7 @@ func buildChaosSeedMindMap(steps []session.WorkflowStep) *chaos.MindMap {
 
 	for _, step := range steps {
 		stepType := strings.TrimSpace(step.Type)
-		if stepType == "" || strings.EqualFold(stepType, "Connect") || strings.EqualFold(stepType, "Disconnect") {
+		if stepType == "" || strings.EqualFold(stepType, "Connect") || strings.EqualFold(stepType, "Disconnect") || isWorkflowCheckStep(stepType) {
 			continue
 		}
 		area := ensureArea(currentAreaID)
@@ -1327,7 +1445,7 @@ func buildChaosSeedMindMap(steps []session.WorkflowStep) *chaos.MindMap {
 
 		if strings.EqualFold(stepType, "FillString") {
 			text := strings.TrimSpace(step.Text)
-			if text == "" {
+			if text == "" || secrets.HasPlaceholder(text) {
 				continue
 			}
 			row := syntheticRow
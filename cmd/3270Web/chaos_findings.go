@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/chaos"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// chaosFindingRulesPayload is the JSON body of GET and POST
+// /chaos/finding-rules, and the format of the saved rules file.
+type chaosFindingRulesPayload struct {
+	Rules []chaos.FindingRule `json:"rules"`
+}
+
+// loadChaosFindingRules returns the saved findings oracle rules, or the
+// default rules when none were saved.
+func (app *App) loadChaosFindingRules() ([]chaos.FindingRule, error) {
+	if app == nil || strings.TrimSpace(app.findingRulesPath) == "" {
+		return chaos.DefaultFindingRules(), nil
+	}
+	app.findingRulesMu.Lock()
+	defer app.findingRulesMu.Unlock()
+	data, err := os.ReadFile(app.findingRulesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return chaos.DefaultFindingRules(), nil
+		}
+		return nil, fmt.Errorf("read chaos finding rules: %w", err)
+	}
+	var payload chaosFindingRulesPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("parse chaos finding rules: %w", err)
+	}
+	if err := chaos.ValidateFindingRules(payload.Rules); err != nil {
+		return nil, err
+	}
+	return payload.Rules, nil
+}
+
+func (app *App) saveChaosFindingRules(rules []chaos.FindingRule) error {
+	if app == nil || strings.TrimSpace(app.findingRulesPath) == "" {
+		return fmt.Errorf("chaos finding rules path not configured")
+	}
+	app.findingRulesMu.Lock()
+	defer app.findingRulesMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(app.findingRulesPath), 0750); err != nil {
+		return fmt.Errorf("create chaos finding rules directory: %w", err)
+	}
+	data, err := json.MarshalIndent(chaosFindingRulesPayload{Rules: rules}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal chaos finding rules: %w", err)
+	}
+	if err := os.WriteFile(app.findingRulesPath, data, 0600); err != nil {
+		return fmt.Errorf("write chaos finding rules: %w", err)
+	}
+	return nil
+}
+
+// chaosFindingRules picks the rules for a run: those sent with the
+// request, else the saved ones.
+func (app *App) chaosFindingRules(requested []chaos.FindingRule) ([]chaos.FindingRule, error) {
+	if requested != nil {
+		if err := chaos.ValidateFindingRules(requested); err != nil {
+			return nil, err
+		}
+		return requested, nil
+	}
+	return app.loadChaosFindingRules()
+}
+
+// ChaosFindingRulesGetHandler handles GET /chaos/finding-rules.
+func (app *App) ChaosFindingRulesGetHandler(c *gin.Context) {
+	if app.getSession(c) == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	rules, err := app.loadChaosFindingRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules, "defaults": chaos.DefaultFindingRules()})
+}
+
+// ChaosFindingRulesSaveHandler handles POST /chaos/finding-rules. The saved
+// rules apply to chaos runs started or resumed afterwards.
+func (app *App) ChaosFindingRulesSaveHandler(c *gin.Context) {
+	if app.getSession(c) == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	var req chaosFindingRulesPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+		return
+	}
+	if req.Rules == nil {
+		req.Rules = []chaos.FindingRule{}
+	}
+	for i := range req.Rules {
+		req.Rules[i].Name = strings.TrimSpace(req.Rules[i].Name)
+	}
+	if err := chaos.ValidateFindingRules(req.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := app.saveChaosFindingRules(req.Rules); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "saved", "rules": req.Rules})
+}
+
+// chaosRunFindings returns the findings of the session's chaos run: the
+// running engine's, else those of the loaded or last saved run.
+func (app *App) chaosRunFindings(s *session.Session) ([]chaos.Finding, *chaos.Engine, *chaos.SavedRun) {
+	if eng, ok := app.chaosEngines.get(s.ID); ok {
+		return eng.Findings(), eng, nil
+	}
+	run, ok := app.chaosEngines.getLoadedRun(s.ID)
+	if !ok {
+		if run = app.loadSessionChaosRunFromDisk(s); run != nil {
+			app.chaosEngines.setLoadedRun(s.ID, run)
+		}
+	}
+	if run == nil {
+		return nil, nil, nil
+	}
+	return run.Findings, nil, run
+}
+
+// ChaosFindingsHandler handles GET /chaos/findings, the findings of the
+// session's chaos run with their reproducing steps.
+func (app *App) ChaosFindingsHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	findings, _, _ := app.chaosRunFindings(s)
+	if findings == nil {
+		findings = []chaos.Finding{}
+	}
+	c.JSON(http.StatusOK, gin.H{"findings": findings})
+}
+
+// ChaosFindingWorkflowHandler handles GET /chaos/findings/:id/workflow, the
+// workflow JSON that replays the steps leading to one finding.
+func (app *App) ChaosFindingWorkflowHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	var targetHost string
+	var targetPort int
+	withSessionLock(s, func() {
+		targetHost = s.TargetHost
+		targetPort = s.TargetPort
+	})
+
+	id := c.Param("id")
+	findings, eng, run := app.chaosRunFindings(s)
+	var data []byte
+	var err error
+	switch {
+	case eng != nil:
+		data, err = eng.ExportFinding(id, targetHost, targetPort)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+	case run != nil:
+		var steps []session.WorkflowStep
+		found := false
+		for _, f := range findings {
+			if f.ID == id {
+				steps, found = f.Steps, true
+				break
+			}
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("finding %q not found", id)})
+			return
+		}
+		data, err = marshalWorkflowExport(targetHost, targetPort, steps, run.WorkflowHeader)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no chaos run data for this session"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="chaos-finding-%s.json"`, safeFindingFileID(id)))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// safeFindingFileID keeps letters and digits of id for a download name.
+func safeFindingFileID(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, id)
+}
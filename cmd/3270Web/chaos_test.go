@@ -8,10 +8,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/chaos"
 	"github.com/jnnngs/3270Web/internal/host"
 	"github.com/jnnngs/3270Web/internal/session"
 )
@@ -539,10 +541,11 @@ func setupFullChaosTestApp(t *testing.T, mockHost *host.MockHost) (*App, *gin.En
 	sess.TargetPort = 3270
 
 	app := &App{
-		SessionManager: mgr,
-		chaosEngines:   newChaosEngineStore(),
-		chaosRunsDir:   t.TempDir(),
-		chaosHintsPath: filepath.Join(t.TempDir(), "chaos-hints.json"),
+		SessionManager:   mgr,
+		chaosEngines:     newChaosEngineStore(),
+		chaosRunsDir:     t.TempDir(),
+		chaosHintsPath:   filepath.Join(t.TempDir(), "chaos-hints.json"),
+		findingRulesPath: filepath.Join(t.TempDir(), "chaos-finding-rules.json"),
 	}
 
 	r := gin.New()
@@ -558,6 +561,10 @@ func setupFullChaosTestApp(t *testing.T, mockHost *host.MockHost) (*App, *gin.En
 	r.GET("/chaos/hints", app.ChaosHintsGetHandler)
 	r.POST("/chaos/hints", app.ChaosHintsSaveHandler)
 	r.POST("/chaos/hints/extract-recording", app.ChaosHintsExtractHandler)
+	r.GET("/chaos/finding-rules", app.ChaosFindingRulesGetHandler)
+	r.POST("/chaos/finding-rules", app.ChaosFindingRulesSaveHandler)
+	r.GET("/chaos/findings", app.ChaosFindingsHandler)
+	r.GET("/chaos/findings/:id/workflow", app.ChaosFindingWorkflowHandler)
 
 	return app, r, sess.ID
 }
@@ -615,6 +622,150 @@ func TestChaosStatus_MetadataFields(t *testing.T) {
 	}
 }
 
+// TestChaosStart_Strategy verifies that the strategy is validated and
+// reported in the status.
+func TestChaosStart_Strategy(t *testing.T) {
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("failed to create mock host: %v", err)
+	}
+	mockHost.Screen = buildSampleApp1Screen()
+	mockHost.Connected = true
+
+	_, r, sessID := setupFullChaosTestApp(t, mockHost)
+
+	bad, _ := json.Marshal(map[string]interface{}{"strategy": "breadth"})
+	if w := chaosRequest(r, http.MethodPost, "/chaos/start", bad, sessID); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown strategy: want 400, got %d", w.Code)
+	}
+	bad, _ = json.Marshal(map[string]interface{}{"valueWeights": map[string]int{"fuzzy": 1}})
+	if w := chaosRequest(r, http.MethodPost, "/chaos/start", bad, sessID); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown value generator: want 400, got %d", w.Code)
+	}
+	bad, _ = json.Marshal(map[string]interface{}{"maskRules": "R1C1W5; /(/"})
+	if w := chaosRequest(r, http.MethodPost, "/chaos/start", bad, sessID); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid mask rule: want 400, got %d", w.Code)
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"maxSteps":     3,
+		"stepDelaySec": 0,
+		"seed":         10,
+		"strategy":     "frontier",
+		"valueWeights": map[string]int{"boundary": 1, "label": 1},
+		"maskRules":    "R1C72W8",
+		"autoMask":     false,
+	})
+	if w := chaosRequest(r, http.MethodPost, "/chaos/start", payload, sessID); w.Code != http.StatusOK {
+		t.Fatalf("start: want 200, got %d", w.Code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var statusResp map[string]interface{}
+	for time.Now().Before(deadline) {
+		w := chaosRequest(r, http.MethodGet, "/chaos/status", nil, sessID)
+		json.Unmarshal(w.Body.Bytes(), &statusResp) //nolint:errcheck
+		if active, _ := statusResp["active"].(bool); !active {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if statusResp["strategy"] != "frontier" {
+		t.Errorf("status strategy = %v, want frontier", statusResp["strategy"])
+	}
+	if _, ok := statusResp["frontierAreas"]; !ok {
+		t.Error("status response missing frontierAreas field")
+	}
+	masks, _ := statusResp["maskRules"].([]interface{})
+	if len(masks) != 1 {
+		t.Errorf("status maskRules = %v, want the requested rule", statusResp["maskRules"])
+	}
+}
+
+func TestChaosFindings(t *testing.T) {
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("failed to create mock host: %v", err)
+	}
+	mockHost.Screen = buildSampleApp1Screen()
+	copy(mockHost.Screen.Buffer[20], []rune("ASRA ABEND IN PROGRAM ACCT01"))
+	mockHost.Connected = true
+
+	_, r, sessID := setupFullChaosTestApp(t, mockHost)
+
+	w := chaosRequest(r, http.MethodGet, "/chaos/findings", nil, sessID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"findings":[]`) {
+		t.Fatalf("findings before a run: %d %s", w.Code, w.Body.String())
+	}
+
+	bad, _ := json.Marshal(map[string]interface{}{
+		"rules": []map[string]string{{"name": "broken", "kind": "text", "pattern": "("}},
+	})
+	if w := chaosRequest(r, http.MethodPost, "/chaos/finding-rules", bad, sessID); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid rule: want 400, got %d", w.Code)
+	}
+	rules, _ := json.Marshal(map[string]interface{}{
+		"rules": []map[string]string{{"name": " Abend ", "kind": "text", "pattern": `\bABEND\b`}},
+	})
+	if w := chaosRequest(r, http.MethodPost, "/chaos/finding-rules", rules, sessID); w.Code != http.StatusOK {
+		t.Fatalf("save rules: want 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	w = chaosRequest(r, http.MethodGet, "/chaos/finding-rules", nil, sessID)
+	var rulesResp struct {
+		Rules    []chaos.FindingRule `json:"rules"`
+		Defaults []chaos.FindingRule `json:"defaults"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &rulesResp); err != nil {
+		t.Fatalf("rules response is not valid JSON: %v", err)
+	}
+	if len(rulesResp.Rules) != 1 || rulesResp.Rules[0].Name != "Abend" || len(rulesResp.Defaults) == 0 {
+		t.Fatalf("rules response = %+v", rulesResp)
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{"maxSteps": 2, "stepDelaySec": 0, "seed": 4})
+	if w := chaosRequest(r, http.MethodPost, "/chaos/start", payload, sessID); w.Code != http.StatusOK {
+		t.Fatalf("start: want 200, got %d", w.Code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var statusResp map[string]interface{}
+	for time.Now().Before(deadline) {
+		w := chaosRequest(r, http.MethodGet, "/chaos/status", nil, sessID)
+		json.Unmarshal(w.Body.Bytes(), &statusResp) //nolint:errcheck
+		if active, _ := statusResp["active"].(bool); !active {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if summary, _ := statusResp["findings"].([]interface{}); len(summary) != 1 {
+		t.Fatalf("status findings = %v, want one", statusResp["findings"])
+	}
+
+	w = chaosRequest(r, http.MethodGet, "/chaos/findings", nil, sessID)
+	var findingsResp struct {
+		Findings []chaos.Finding `json:"findings"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &findingsResp); err != nil {
+		t.Fatalf("findings response is not valid JSON: %v", err)
+	}
+	if len(findingsResp.Findings) != 1 {
+		t.Fatalf("findings = %+v, want one", findingsResp.Findings)
+	}
+	f := findingsResp.Findings[0]
+	if f.Rule != "Abend" || f.Detail != "ASRA ABEND IN PROGRAM ACCT01" || len(f.Steps) == 0 {
+		t.Fatalf("finding = %+v", f)
+	}
+
+	w = chaosRequest(r, http.MethodGet, "/chaos/findings/"+f.ID+"/workflow", nil, sessID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), "chaos-finding-"+f.ID+".json") {
+		t.Fatalf("finding workflow: %d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"Host": "127.0.0.1"`) {
+		t.Fatalf("finding workflow = %s", w.Body.String())
+	}
+	if w := chaosRequest(r, http.MethodGet, "/chaos/findings/F99/workflow", nil, sessID); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown finding: want 404, got %d", w.Code)
+	}
+}
+
 // TestChaosListRuns_Empty verifies that listing runs when the directory is
 // empty returns an empty JSON array.
 func TestChaosListRuns_Empty(t *testing.T) {
@@ -0,0 +1,713 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/auth"
+	"github.com/jnnngs/3270Web/internal/chaos"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// Exit codes for headless subcommands.
+const (
+	cliExitOK     = 0
+	cliExitFailed = 1
+	cliExitUsage  = 2
+)
+
+const cliPollEvery = 500 * time.Millisecond
+
+const cliUsageBanner = `Usage:
+  3270Web                          start the web server
+  3270Web play <workflow.json>     play a recording headlessly
+  3270Web chaos --host <host>      run chaos exploration headlessly
+  3270Web minimize --run <id>      shrink a chaos run to a minimal failing workflow
+  3270Web validate <workflow.json> check recordings without connecting
+  3270Web secrets list|set|delete  manage the encrypted secrets file
+  3270Web users list|add|remove    manage web UI sign-in accounts
+
+Run "3270Web <command> -h" for the options of a command.
+`
+
+// cli runs the headless subcommands. newHost is app.newHost outside tests.
+type cli struct {
+	app     *App
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	newHost func(hostname, engine string) (host.Host, error)
+}
+
+func newCLI(app *App, stdout, stderr io.Writer) *cli {
+	return &cli{app: app, stdin: os.Stdin, stdout: stdout, stderr: stderr, newHost: app.newHost}
+}
+
+// run dispatches args to a subcommand and returns its exit code. handled is
+// false when args name no subcommand, in which case main starts the server.
+func (c *cli) run(args []string) (code int, handled bool) {
+	if len(args) == 0 {
+		return cliExitOK, false
+	}
+	switch args[0] {
+	case "play":
+		return c.play(args[1:]), true
+	case "chaos":
+		return c.chaos(args[1:]), true
+	case "minimize":
+		return c.minimize(args[1:]), true
+	case "validate":
+		return c.validate(args[1:]), true
+	case "secrets":
+		return c.secrets(args[1:]), true
+	case "users":
+		return c.users(args[1:]), true
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(c.stdout, cliUsageBanner)
+		return cliExitOK, true
+	}
+	return cliExitOK, false
+}
+
+func (c *cli) flagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: 3270Web %s\n\nOptions:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseCLIArgs parses flags that may appear before or after positional
+// arguments and returns the positional ones.
+func parseCLIArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func usageExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return cliExitOK
+	}
+	return cliExitUsage
+}
+
+func readWorkflowFile(path string) (*WorkflowConfig, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxWorkflowUploadBytes {
+		return nil, fmt.Errorf("workflow file exceeds %d bytes", maxWorkflowUploadBytes)
+	}
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseWorkflowPayload(payload)
+}
+
+// notifyInterrupt calls stop on the first Ctrl+C and returns a function that
+// releases the signal handler.
+func notifyInterrupt(stop func()) func() {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		select {
+		case <-signals:
+			stop()
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// validate handles "3270Web validate": it parses each workflow file the same
+// way the web loader does and reports every problem found.
+func (c *cli) validate(args []string) int {
+	fs := c.flagSet("validate", "validate <workflow.json>...")
+	files, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return cliExitUsage
+	}
+	code := cliExitOK
+	for _, path := range files {
+		workflow, err := readWorkflowFile(path)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "%s: %v\n", path, err)
+			code = cliExitFailed
+			continue
+		}
+		summary := fmt.Sprintf("%d steps", len(workflow.Steps))
+		if rows, _ := workflow.Dataset.records(); len(rows) > 0 {
+			summary += fmt.Sprintf(", %d dataset rows", len(rows))
+		}
+		fmt.Fprintf(c.stdout, "%s: ok (%s)\n", path, summary)
+	}
+	return code
+}
+
+// play handles "3270Web play": it connects to the workflow host, or --host,
+// runs the recording through the same playback code as the web UI and exits
+// non-zero when any step fails.
+func (c *cli) play(args []string) int {
+	fs := c.flagSet("play", "play <workflow.json> [options]")
+	target := fs.String("host", "", "host[:port] or profile:NAME to play against (default: the workflow's Profile, or Host and Port)")
+	engine := fs.String("engine", "", "host engine: s3270 or native (default: APP_HOST_ENGINE)")
+	junitPath := fs.String("junit", "", "write a JUnit XML report to this file")
+	htmlPath := fs.String("html", "", "write an HTML report to this file")
+	files, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return cliExitUsage
+	}
+	path := files[0]
+	workflow, err := readWorkflowFile(path)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "%s: %v\n", path, err)
+		return cliExitFailed
+	}
+	hostname := strings.TrimSpace(*target)
+	if hostname == "" {
+		if hostname, err = workflowTargetHost(nil, workflow); err != nil {
+			fmt.Fprintf(c.stderr, "%s: %v; use --host\n", path, err)
+			return cliExitUsage
+		}
+	}
+	if !isValidHostname(hostname) {
+		fmt.Fprintf(c.stderr, "invalid hostname format: %q\n", hostname)
+		return cliExitUsage
+	}
+
+	h, err := c.newHost(hostname, *engine)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "failed to create host: %v\n", err)
+		return cliExitFailed
+	}
+	if err := h.Start(); err != nil {
+		fmt.Fprintf(c.stderr, "failed to start host connection: %v\n", err)
+		return cliExitFailed
+	}
+	defer h.Stop()
+
+	s := &session.Session{
+		Host:           h,
+		HostEngine:     resolveHostEngine(*engine),
+		User:           cliUserName(),
+		ClientAddr:     "cli",
+		LoadedWorkflow: &session.LoadedWorkflow{Name: filepath.Base(path), LoadedAt: time.Now()},
+		Playback:       &session.WorkflowPlayback{StartedAt: time.Now(), Mode: "play", TotalSteps: len(workflow.Steps)},
+	}
+	c.app.setSessionTarget(s, hostname)
+	release := notifyInterrupt(func() { stopWorkflowPlayback(s) })
+	c.app.playWorkflow(s, workflow)
+	release()
+
+	result := playbackResultSnapshot(s)
+	if result == nil || len(result.Steps) == 0 {
+		for _, event := range playbackEvents(s) {
+			fmt.Fprintln(c.stderr, event.Message)
+		}
+		return cliExitFailed
+	}
+	passed, failed, skipped := c.printStepResults(result)
+	fmt.Fprintf(c.stdout, "%s against %s: %d passed, %d failed, %d skipped\n",
+		result.Name, result.Host, passed, failed, skipped)
+
+	code := cliExitOK
+	if failed > 0 || skipped > 0 {
+		code = cliExitFailed
+	}
+	if *junitPath != "" {
+		if err := writeCLIReport(*junitPath, result, workflowResultJUnit); err != nil {
+			fmt.Fprintf(c.stderr, "JUnit report: %v\n", err)
+			code = cliExitFailed
+		}
+	}
+	if *htmlPath != "" {
+		if err := writeCLIReport(*htmlPath, result, workflowResultHTML); err != nil {
+			fmt.Fprintf(c.stderr, "HTML report: %v\n", err)
+			code = cliExitFailed
+		}
+	}
+	return code
+}
+
+func (c *cli) printStepResults(result *session.WorkflowResult) (passed, failed, skipped int) {
+	for _, step := range result.Steps {
+		label := fmt.Sprintf("step %d %s", step.Step, step.Type)
+		if step.Row > 0 {
+			label = fmt.Sprintf("row %d %s", step.Row, label)
+		}
+		switch step.Status {
+		case session.StepPassed:
+			passed++
+			fmt.Fprintf(c.stdout, "PASS %s (%s)\n", label, step.Duration.Round(time.Millisecond))
+		case session.StepFailed:
+			failed++
+			fmt.Fprintf(c.stdout, "FAIL %s (%s): %s\n", label, step.Duration.Round(time.Millisecond), step.Error)
+		case session.StepSkipped:
+			skipped++
+			fmt.Fprintf(c.stdout, "SKIP %s\n", label)
+		}
+		if step.Drift != "" {
+			fmt.Fprintf(c.stdout, "     screen differs from the recording:\n%s\n", indentLines(step.Drift, "     "))
+		}
+	}
+	return passed, failed, skipped
+}
+
+func indentLines(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
+
+func writeCLIReport(path string, result *session.WorkflowResult, render func(*session.WorkflowResult) ([]byte, error)) error {
+	data, err := render(result)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// chaos handles "3270Web chaos": it explores the host until the step or time
+// limit, saves the run where the web UI lists saved runs and optionally
+// exports the learned workflow. It exits non-zero when the run ends on an
+// error.
+func (c *cli) chaos(args []string) int {
+	defaults := chaos.DefaultConfig()
+	fs := c.flagSet("chaos", "chaos --host <host[:port]> [options]")
+	target := fs.String("host", "", "host[:port] or profile:NAME to explore (required)")
+	engine := fs.String("engine", "", "host engine: s3270 or native (default: APP_HOST_ENGINE)")
+	maxSteps := fs.Int("max-steps", defaults.MaxSteps, "stop after this many submissions (0 = unlimited)")
+	timeBudget := fs.Duration("time-budget", defaults.TimeBudget, "stop after this long (0 = unlimited)")
+	stepDelay := fs.Duration("step-delay", defaults.StepDelay, "pause between submissions")
+	seed := fs.Int64("seed", 0, "random seed for a repeatable run (0 = random)")
+	maxFieldLength := fs.Int("max-field-length", defaults.MaxFieldLength, "maximum characters generated per field")
+	strategyName := fs.String("strategy", defaults.Strategy, "exploration strategy: random or frontier")
+	valueWeights := fs.String("values", "", "field value generator weights as name=weight pairs, e.g. random=50,boundary=20 (default: a built-in mix)")
+	maskSpec := fs.String("mask", "", "volatile screen regions to ignore, e.g. 'R1C72W8; /\\d\\d:\\d\\d/'")
+	autoMask := fs.Bool("auto-mask", defaults.AutoMask, "also mask regions that change between visits with the same input")
+	output := fs.String("output", "", "write the learned workflow JSON to this file")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	hostname := strings.TrimSpace(*target)
+	if len(positional) > 0 || hostname == "" {
+		fs.Usage()
+		return cliExitUsage
+	}
+	if !isValidHostname(hostname) {
+		fmt.Fprintf(c.stderr, "invalid hostname format: %q\n", hostname)
+		return cliExitUsage
+	}
+	if *maxSteps < 0 || *timeBudget < 0 || *stepDelay < 0 || *maxFieldLength <= 0 {
+		fmt.Fprintln(c.stderr, "limits must not be negative and --max-field-length must be positive")
+		return cliExitUsage
+	}
+	strategy, err := chaos.ParseStrategy(*strategyName)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return cliExitUsage
+	}
+	weights := defaults.ValueWeights
+	if strings.TrimSpace(*valueWeights) != "" {
+		if weights, err = chaos.ParseValueWeights(*valueWeights); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitUsage
+		}
+	}
+
+	masks, err := chaos.ParseMaskRules(*maskSpec)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return cliExitUsage
+	}
+
+	cfg := defaults
+	cfg.MaxSteps = *maxSteps
+	cfg.TimeBudget = *timeBudget
+	cfg.StepDelay = *stepDelay
+	cfg.Seed = *seed
+	cfg.MaxFieldLength = *maxFieldLength
+	cfg.Strategy = strategy
+	cfg.ValueWeights = weights
+	cfg.MaskRules = masks
+	cfg.AutoMask = *autoMask
+	if address, _, err := c.app.resolveTarget(hostname); err == nil {
+		cfg.ExportHost, cfg.ExportPort = parseHostPort(address)
+	}
+	if hints, err := c.app.loadChaosHints(); err == nil && len(hints) > 0 {
+		cfg.Hints = hints
+	}
+	rules, err := c.app.loadChaosFindingRules()
+	if err != nil {
+		fmt.Fprintf(c.stderr, "finding rules: %v\n", err)
+		return cliExitFailed
+	}
+	cfg.FindingRules = rules
+
+	h, err := c.newHost(hostname, *engine)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "failed to create host: %v\n", err)
+		return cliExitFailed
+	}
+	if err := h.Start(); err != nil {
+		fmt.Fprintf(c.stderr, "failed to start host connection: %v\n", err)
+		return cliExitFailed
+	}
+	defer h.Stop()
+
+	eng := chaos.New(h, cfg)
+	if err := eng.Start(); err != nil {
+		fmt.Fprintf(c.stderr, "failed to start: %v\n", err)
+		return cliExitFailed
+	}
+	release := notifyInterrupt(eng.Stop)
+	for eng.Status().Active {
+		time.Sleep(cliPollEvery)
+	}
+	release()
+
+	st := eng.Status()
+	fmt.Fprintf(c.stdout, "Chaos run against %s: %d steps, %d transitions, %d unique screens, %d unique inputs in %s\n",
+		hostname, st.StepsRun, st.Transitions, st.UniqueScreens, st.UniqueInputs,
+		st.StoppedAt.Sub(st.StartedAt).Round(time.Second))
+	for _, f := range st.Findings {
+		fmt.Fprintf(c.stdout, "Finding %s: %s at attempt %d (%d steps to reproduce): %s\n",
+			f.ID, f.Rule, f.Attempt, f.StepCount, f.Detail)
+	}
+
+	code := cliExitOK
+	if st.Error != "" {
+		fmt.Fprintf(c.stderr, "Chaos run stopped on error: %s\n", st.Error)
+		code = cliExitFailed
+	}
+	c.app.storeRecordedSecrets(eng.Secrets())
+	if c.app.chaosRunsDir != "" {
+		runID := chaos.NewRunID()
+		if err := chaos.SaveRun(c.app.chaosRunsDir, eng.Snapshot(runID)); err != nil {
+			fmt.Fprintf(c.stderr, "save run: %v\n", err)
+		} else {
+			fmt.Fprintf(c.stdout, "Saved run %s\n", runID)
+		}
+	}
+	if *output != "" {
+		data, err := eng.ExportWorkflow("", 0)
+		if err == nil {
+			err = os.WriteFile(*output, data, 0600)
+		}
+		if err != nil {
+			fmt.Fprintf(c.stderr, "export workflow: %v\n", err)
+			return cliExitFailed
+		}
+		fmt.Fprintf(c.stdout, "Wrote workflow to %s\n", *output)
+	}
+	return code
+}
+
+// minimize handles "3270Web minimize": it replays subsets of a saved chaos
+// run's steps on fresh connections until it finds the smallest workflow that
+// still fails the same way, then prints it and optionally saves it. The
+// failure is one of the run's findings, or a pattern on the screen text.
+func (c *cli) minimize(args []string) int {
+	fs := c.flagSet("minimize", "minimize --run <id> --host <host[:port]> (--finding <id> | --pattern <regexp>) [options]")
+	runID := fs.String("run", "", "saved chaos run ID (required)")
+	target := fs.String("host", "", "host[:port] or profile:NAME to replay against (required)")
+	engine := fs.String("engine", "", "host engine: s3270 or native (default: APP_HOST_ENGINE)")
+	findingID := fs.String("finding", "", "finding ID of the run whose reproducer to shrink")
+	pattern := fs.String("pattern", "", "regular expression on the screen text that marks the failure")
+	maxReplays := fs.Int("max-replays", 200, "stop after this many replays and keep the best result (0 = unlimited)")
+	output := fs.String("output", "", "write the minimized workflow JSON to this file")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	hostname := strings.TrimSpace(*target)
+	if len(positional) > 0 || hostname == "" || strings.TrimSpace(*runID) == "" || (*findingID == "") == (*pattern == "") || *maxReplays < 0 {
+		fs.Usage()
+		return cliExitUsage
+	}
+	if !isValidHostname(hostname) {
+		fmt.Fprintf(c.stderr, "invalid hostname format: %q\n", hostname)
+		return cliExitUsage
+	}
+	run, err := chaos.LoadRun(c.app.chaosRunsDir, strings.TrimSpace(*runID))
+	if err != nil {
+		fmt.Fprintf(c.stderr, "load run: %v\n", err)
+		return cliExitFailed
+	}
+
+	steps := run.Steps
+	var check chaos.FailureCheck
+	if *pattern != "" {
+		check, err = chaos.PatternCheck(*pattern)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitUsage
+		}
+	} else {
+		var finding *chaos.Finding
+		for i := range run.Findings {
+			if run.Findings[i].ID == *findingID {
+				finding = &run.Findings[i]
+				break
+			}
+		}
+		if finding == nil {
+			fmt.Fprintf(c.stderr, "run %s has no finding %q\n", run.ID, *findingID)
+			return cliExitFailed
+		}
+		rules := run.FindingRules
+		if len(rules) == 0 {
+			if rules, err = c.app.loadChaosFindingRules(); err != nil {
+				fmt.Fprintf(c.stderr, "finding rules: %v\n", err)
+				return cliExitFailed
+			}
+		}
+		if check, err = chaos.FindingCheck(rules, run.MaskRules, *finding); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitFailed
+		}
+		steps = finding.Steps
+	}
+
+	runSecrets := chaos.RunSecrets(run, c.app.secrets.lookup)
+	var stopped atomic.Bool
+	release := notifyInterrupt(func() { stopped.Store(true) })
+	result, err := chaos.Minimize(steps, func(candidate []session.WorkflowStep) (bool, error) {
+		return c.replayForFailure(hostname, *engine, candidate, runSecrets, check, &stopped)
+	}, *maxReplays)
+	release()
+
+	code := cliExitOK
+	switch {
+	case errors.Is(err, chaos.ErrNotReproduced):
+		fmt.Fprintf(c.stderr, "Replaying all %d steps did not reproduce the failure\n", len(steps))
+		return cliExitFailed
+	case errors.Is(err, errPlaybackStopped):
+		fmt.Fprintln(c.stderr, "Stopped; keeping the smallest failing workflow found so far")
+	case err != nil:
+		fmt.Fprintf(c.stderr, "Minimize stopped on error: %v\n", err)
+		code = cliExitFailed
+	}
+	fmt.Fprintf(c.stdout, "Minimized %d steps to %d in %d replays\n", result.OriginalSteps, len(result.Steps), result.Replays)
+	for i, step := range result.Steps {
+		line := fmt.Sprintf("%d %s", i+1, step.Type)
+		if step.Coordinates != nil {
+			line += fmt.Sprintf(" at %d,%d: %q", step.Coordinates.Row, step.Coordinates.Column, step.Text)
+		}
+		fmt.Fprintln(c.stdout, line)
+	}
+	if *output != "" && len(result.Steps) > 0 {
+		var exportHost string
+		var exportPort int
+		if address, _, err := c.app.resolveTarget(hostname); err == nil {
+			exportHost, exportPort = parseHostPort(address)
+		}
+		data, err := marshalWorkflowExport(exportHost, exportPort, result.Steps, run.WorkflowHeader)
+		if err == nil {
+			err = os.WriteFile(*output, data, 0600)
+		}
+		if err != nil {
+			fmt.Fprintf(c.stderr, "export workflow: %v\n", err)
+			return cliExitFailed
+		}
+		fmt.Fprintf(c.stdout, "Wrote workflow to %s\n", *output)
+	}
+	return code
+}
+
+// replayForFailure plays steps on a new connection to hostname and reports
+// whether check fires on any screen along the way. Secret placeholders are
+// resolved from runSecrets only, the hidden values the run generated as
+// found in the secrets file. A step
+// that fails to apply ends the replay as not failing, since the candidate is
+// then just a workflow that does not fit the host.
+func (c *cli) replayForFailure(hostname, engine string, steps []session.WorkflowStep, runSecrets map[string]string, check chaos.FailureCheck, stopped *atomic.Bool) (bool, error) {
+	h, err := c.newHost(hostname, engine)
+	if err != nil {
+		return false, fmt.Errorf("create host: %w", err)
+	}
+	if err := h.Start(); err != nil {
+		return false, fmt.Errorf("start host connection: %w", err)
+	}
+	defer h.Stop()
+
+	vs := &session.Session{
+		Host:       h,
+		HostEngine: resolveHostEngine(engine),
+		User:       cliUserName(),
+		ClientAddr: "cli",
+		Playback:   &session.WorkflowPlayback{Active: true, Mode: "play", Secrets: map[string]string{}},
+	}
+	for name, value := range runSecrets {
+		vs.Playback.Secrets[name] = value
+	}
+	c.app.setSessionTarget(vs, hostname)
+	for _, step := range steps {
+		if stopped.Load() {
+			return false, errPlaybackStopped
+		}
+		err := c.app.applyWorkflowStep(vs, step)
+		if check(h.GetScreen(), h.IsConnected()) {
+			return true, nil
+		}
+		if err != nil {
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// secrets handles "3270Web secrets": it lists, sets or deletes entries in the
+// encrypted secrets file. set reads the value from the first line of standard
+// input so it stays out of shell history.
+func (c *cli) secrets(args []string) int {
+	fs := c.flagSet("secrets", "secrets list | set <name> | delete <name>")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return cliExitUsage
+	}
+	action, rest := positional[0], positional[1:]
+	if (action == "list" && len(rest) != 0) || (action != "list" && len(rest) != 1) {
+		fs.Usage()
+		return cliExitUsage
+	}
+	store, err := c.app.secrets.open()
+	if err != nil {
+		fmt.Fprintf(c.stderr, "secrets file: %v (set %s)\n", err, secretsKeyEnv)
+		return cliExitFailed
+	}
+	switch action {
+	case "list":
+		for _, name := range store.Names() {
+			fmt.Fprintln(c.stdout, name)
+		}
+	case "set":
+		value, err := bufio.NewReader(c.stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			fmt.Fprintf(c.stderr, "read value: %v\n", err)
+			return cliExitFailed
+		}
+		if err := store.Set(rest[0], strings.TrimRight(value, "\r\n")); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitFailed
+		}
+		fmt.Fprintf(c.stdout, "Saved secret %s\n", rest[0])
+	case "delete":
+		existed, err := store.Delete(rest[0])
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitFailed
+		}
+		if !existed {
+			fmt.Fprintf(c.stderr, "no secret named %s\n", rest[0])
+			return cliExitFailed
+		}
+		fmt.Fprintf(c.stdout, "Deleted secret %s\n", rest[0])
+	default:
+		fs.Usage()
+		return cliExitUsage
+	}
+	return cliExitOK
+}
+
+// users manages the local accounts in users.json. Adding an account reads
+// its password from the first line of stdin and enables sign-in.
+func (c *cli) users(args []string) int {
+	fs := c.flagSet("users", "users list | add <name> [-role user|tester|admin] | remove <name>")
+	roleName := fs.String("role", string(auth.RoleUser), "role for add: user, tester or admin")
+	positional, err := parseCLIArgs(fs, args)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return cliExitUsage
+	}
+	action, rest := positional[0], positional[1:]
+	if (action == "list" && len(rest) != 0) || (action != "list" && len(rest) != 1) {
+		fs.Usage()
+		return cliExitUsage
+	}
+	store := c.app.users
+	switch action {
+	case "list":
+		users, err := store.List()
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitFailed
+		}
+		for _, u := range users {
+			fmt.Fprintf(c.stdout, "%s\t%s\n", u.Name, u.Role)
+		}
+	case "add":
+		role, err := auth.ParseRole(*roleName)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitUsage
+		}
+		password, err := bufio.NewReader(c.stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			fmt.Fprintf(c.stderr, "read password: %v\n", err)
+			return cliExitFailed
+		}
+		if err := store.Put(rest[0], strings.TrimRight(password, "\r\n"), role); err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitFailed
+		}
+		fmt.Fprintf(c.stdout, "Saved user %s (%s)\n", rest[0], role)
+	case "remove":
+		existed, err := store.Remove(rest[0])
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			return cliExitFailed
+		}
+		if !existed {
+			fmt.Fprintf(c.stderr, "no user named %s\n", rest[0])
+			return cliExitFailed
+		}
+		fmt.Fprintf(c.stdout, "Removed user %s\n", rest[0])
+	default:
+		fs.Usage()
+		return cliExitUsage
+	}
+	return cliExitOK
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/chaos"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func newTestCLI(t *testing.T) (*cli, *bytes.Buffer, *bytes.Buffer, *[]string) {
+	t.Helper()
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), chaosRunsDir: t.TempDir()}
+	var stdout, stderr bytes.Buffer
+	var targets []string
+	c := newCLI(app, &stdout, &stderr)
+	c.newHost = func(hostname, engine string) (host.Host, error) {
+		targets = append(targets, hostname)
+		h, err := host.NewMockHost("")
+		if err != nil {
+			return nil, err
+		}
+		copy(h.Screen.Buffer[0], []rune("READY"))
+		return h, nil
+	}
+	return c, &stdout, &stderr, &targets
+}
+
+func writeCLIWorkflow(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatalf("write workflow: %v", err)
+	}
+	return path
+}
+
+func TestCLIRunOnlyHandlesSubcommands(t *testing.T) {
+	c, stdout, _, _ := newTestCLI(t)
+	for _, args := range [][]string{nil, {"--port", "8080"}, {"serve"}} {
+		if _, handled := c.run(args); handled {
+			t.Fatalf("run(%q) handled = true, want false", args)
+		}
+	}
+	code, handled := c.run([]string{"help"})
+	if !handled || code != cliExitOK {
+		t.Fatalf("run(help) = %d, %v, want %d, true", code, handled, cliExitOK)
+	}
+	if !strings.Contains(stdout.String(), "3270Web play") {
+		t.Fatalf("help output = %q, want usage", stdout.String())
+	}
+}
+
+func TestParseCLIArgsAcceptsFlagsAfterFiles(t *testing.T) {
+	c, _, _, _ := newTestCLI(t)
+	fs := c.flagSet("play", "play")
+	target := fs.String("host", "", "")
+	files, err := parseCLIArgs(fs, []string{"a.json", "--host", "example.com:23", "b.json"})
+	if err != nil {
+		t.Fatalf("parseCLIArgs: %v", err)
+	}
+	if *target != "example.com:23" {
+		t.Fatalf("host = %q, want %q", *target, "example.com:23")
+	}
+	if strings.Join(files, ",") != "a.json,b.json" {
+		t.Fatalf("files = %v, want [a.json b.json]", files)
+	}
+}
+
+func TestCLIValidate(t *testing.T) {
+	good := writeCLIWorkflow(t, "good.json", `{"Host":"localhost","Port":3270,"Steps":[{"Type":"Connect"},{"Type":"AssertText","Text":"${greeting}"}],"Dataset":{"CSV":"greeting\nREADY\nHELLO"}}`)
+	bad := writeCLIWorkflow(t, "bad.json", `{"Steps":[{"Type":"AssertText"}]}`)
+
+	c, stdout, stderr, _ := newTestCLI(t)
+	if code, _ := c.run([]string{"validate", good}); code != cliExitOK {
+		t.Fatalf("validate good = %d, want %d (stderr %q)", code, cliExitOK, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "ok (2 steps, 2 dataset rows)") {
+		t.Fatalf("stdout = %q, want step and row counts", stdout.String())
+	}
+
+	if code, _ := c.run([]string{"validate", good, bad}); code != cliExitFailed {
+		t.Fatalf("validate good bad = %d, want %d", code, cliExitFailed)
+	}
+	if !strings.Contains(stderr.String(), "step 1 (AssertText): Text or Regex is required") {
+		t.Fatalf("stderr = %q, want the step error", stderr.String())
+	}
+
+	if code, _ := c.run([]string{"validate"}); code != cliExitUsage {
+		t.Fatalf("validate without files = %d, want %d", code, cliExitUsage)
+	}
+}
+
+func TestCLIPlay(t *testing.T) {
+	passing := writeCLIWorkflow(t, "pass.json", `{"Host":"localhost","Port":3270,"Steps":[{"Type":"Connect"},{"Type":"AssertText","Text":"READY"},{"Type":"PressEnter"}]}`)
+	failing := writeCLIWorkflow(t, "fail.json", `{"Host":"localhost","Port":3270,"Steps":[{"Type":"Connect"},{"Type":"AssertText","Text":"SIGN ON"},{"Type":"PressEnter"}]}`)
+
+	c, stdout, stderr, targets := newTestCLI(t)
+	junit := filepath.Join(t.TempDir(), "report.xml")
+	code, _ := c.run([]string{"play", passing, "--host", "example.com:992", "--junit", junit})
+	if code != cliExitOK {
+		t.Fatalf("play passing = %d, want %d (stdout %q, stderr %q)", code, cliExitOK, stdout.String(), stderr.String())
+	}
+	if len(*targets) != 1 || (*targets)[0] != "example.com:992" {
+		t.Fatalf("host targets = %v, want the --host override", *targets)
+	}
+	if !strings.Contains(stdout.String(), "pass.json against example.com:992: 3 passed, 0 failed, 0 skipped") {
+		t.Fatalf("stdout = %q, want summary", stdout.String())
+	}
+	data, err := os.ReadFile(junit)
+	if err != nil {
+		t.Fatalf("read JUnit report: %v", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("parse JUnit report: %v", err)
+	}
+	if suites.Tests != 3 || suites.Failures != 0 {
+		t.Fatalf("JUnit tests = %d, failures = %d, want 3, 0", suites.Tests, suites.Failures)
+	}
+
+	stdout.Reset()
+	code, _ = c.run([]string{"play", failing})
+	if code != cliExitFailed {
+		t.Fatalf("play failing = %d, want %d", code, cliExitFailed)
+	}
+	if (*targets)[1] != "localhost:3270" {
+		t.Fatalf("host target = %q, want the workflow host", (*targets)[1])
+	}
+	out := stdout.String()
+	if !strings.Contains(out, `FAIL step 2 AssertText`) || !strings.Contains(out, `expected "SIGN ON" on screen`) {
+		t.Fatalf("stdout = %q, want the failing step", out)
+	}
+	if !strings.Contains(out, "SKIP step 3 PressEnter") {
+		t.Fatalf("stdout = %q, want the skipped step", out)
+	}
+}
+
+func TestCLIPlayRequiresHost(t *testing.T) {
+	path := writeCLIWorkflow(t, "nohost.json", `{"Steps":[{"Type":"Connect"}]}`)
+	c, _, stderr, _ := newTestCLI(t)
+	if code, _ := c.run([]string{"play", path}); code != cliExitUsage {
+		t.Fatalf("play without host = %d, want %d", code, cliExitUsage)
+	}
+	if !strings.Contains(stderr.String(), "use --host") {
+		t.Fatalf("stderr = %q, want --host hint", stderr.String())
+	}
+}
+
+func TestCLIChaos(t *testing.T) {
+	c, stdout, stderr, _ := newTestCLI(t)
+	if code, _ := c.run([]string{"chaos", "--max-steps", "3"}); code != cliExitUsage {
+		t.Fatalf("chaos without host = %d, want %d", code, cliExitUsage)
+	}
+	if code, _ := c.run([]string{"chaos", "--host", "example.com:23", "--values", "fuzzy=1"}); code != cliExitUsage {
+		t.Fatalf("chaos with an unknown value generator = %d, want %d", code, cliExitUsage)
+	}
+	if code, _ := c.run([]string{"chaos", "--host", "example.com:23", "--mask", "R1C1"}); code != cliExitUsage {
+		t.Fatalf("chaos with an invalid mask rule = %d, want %d", code, cliExitUsage)
+	}
+
+	output := filepath.Join(t.TempDir(), "learned.json")
+	code, _ := c.run([]string{"chaos", "--host", "example.com:23", "--max-steps", "3", "--step-delay", "0", "--seed", "7", "--values", "random=3,date=1", "--mask", "R1C72W8", "--output", output})
+	if code != cliExitOK {
+		t.Fatalf("chaos = %d, want %d (stderr %q)", code, cliExitOK, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "3 steps") {
+		t.Fatalf("stdout = %q, want step count", stdout.String())
+	}
+	workflow, err := readWorkflowFile(output)
+	if err != nil {
+		t.Fatalf("exported workflow: %v", err)
+	}
+	if workflow.Host != "example.com" || workflow.Port != 23 {
+		t.Fatalf("exported host = %s:%d, want example.com:23", workflow.Host, workflow.Port)
+	}
+	runs, err := chaos.ListRuns(c.app.chaosRunsDir)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("saved runs = %v, %v, want one run", runs, err)
+	}
+	saved, err := chaos.LoadRun(c.app.chaosRunsDir, runs[0].ID)
+	if err != nil || len(saved.MaskRules) == 0 || saved.MaskRules[0] != (chaos.MaskRule{Kind: chaos.MaskRect, Row: 1, Column: 72, Width: 8}) {
+		t.Fatalf("saved mask rules = %+v, %v", saved, err)
+	}
+}
+
+// abendHost abends on PF3 once BAD has been submitted with PF5.
+type abendHost struct {
+	*host.MockHost
+	armed bool
+}
+
+func (h *abendHost) SendKey(key string) error {
+	switch key {
+	case "PF(5)":
+		h.armed = h.armed || strings.HasPrefix(string(h.Screen.Buffer[2]), "BAD")
+	case "PF(3)":
+		if h.armed {
+			copy(h.Screen.Buffer[20], []rune("ASRA ABEND IN PROGRAM ACCT01"))
+		}
+	}
+	copy(h.Screen.Buffer[2], make([]rune, 10))
+	return h.MockHost.SendKey(key)
+}
+
+func TestCLIMinimize(t *testing.T) {
+	c, stdout, stderr, _ := newTestCLI(t)
+	replays := 0
+	c.newHost = func(hostname, engine string) (host.Host, error) {
+		replays++
+		h, err := host.NewMockHost("")
+		return &abendHost{MockHost: h}, err
+	}
+	fill := func(text string) session.WorkflowStep {
+		return session.WorkflowStep{Type: "FillString", Coordinates: &session.WorkflowCoordinates{Row: 3, Column: 1}, Text: text}
+	}
+	steps := []session.WorkflowStep{
+		fill("A"), {Type: "PressEnter"},
+		fill("BAD"), {Type: "PressPF5"},
+		fill("C"), {Type: "PressEnter"},
+		{Type: "PressClear"},
+		fill("D"), {Type: "PressPF3"},
+		fill("E"), {Type: "PressEnter"},
+	}
+	run := &chaos.SavedRun{
+		SavedRunMeta: chaos.SavedRunMeta{ID: "run1"},
+		Steps:        steps,
+		Findings: []chaos.Finding{{
+			ID: "F1", Rule: "ABEND", Kind: chaos.FindingText, Detail: "ASRA ABEND IN PROGRAM ACCT01",
+			StepCount: 9, Steps: steps[:9],
+		}},
+		FindingRules: chaos.DefaultFindingRules(),
+	}
+	if err := chaos.SaveRun(c.app.chaosRunsDir, run); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := c.run([]string{"minimize", "--run", "run1", "--host", "example.com:23"}); code != cliExitUsage {
+		t.Fatalf("minimize without a failure = %d, want %d", code, cliExitUsage)
+	}
+
+	output := filepath.Join(t.TempDir(), "minimal.json")
+	code, _ := c.run([]string{"minimize", "--run", "run1", "--host", "example.com:23", "--finding", "F1", "--output", output})
+	if code != cliExitOK {
+		t.Fatalf("minimize = %d, want %d (stderr %q)", code, cliExitOK, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Minimized 9 steps to 3 in") {
+		t.Fatalf("stdout = %q, want the minimized size", stdout.String())
+	}
+	workflow, err := readWorkflowFile(output)
+	if err != nil {
+		t.Fatalf("minimized workflow: %v", err)
+	}
+	var types []string
+	for _, step := range workflow.Steps {
+		types = append(types, step.Type+step.Text)
+	}
+	if strings.Join(types, " ") != "FillStringBAD PressPF5 PressPF3" || workflow.Host != "example.com" {
+		t.Fatalf("minimized workflow = %s:%d %v", workflow.Host, workflow.Port, types)
+	}
+
+	stdout.Reset()
+	code, _ = c.run([]string{"minimize", "--run", "run1", "--host", "example.com:23", "--pattern", "ABEND", "--max-replays", "2"})
+	if code != cliExitOK || !strings.Contains(stdout.String(), "Minimized 11 steps to 11 in 2 replays") {
+		t.Fatalf("capped minimize = %d, %q", code, stdout.String())
+	}
+
+	stderr.Reset()
+	if code, _ := c.run([]string{"minimize", "--run", "run1", "--host", "example.com:23", "--pattern", "SIGN ON"}); code != cliExitFailed {
+		t.Fatalf("minimize of a passing run = %d, want %d", code, cliExitFailed)
+	}
+	if !strings.Contains(stderr.String(), "did not reproduce") {
+		t.Fatalf("stderr = %q, want the not reproduced message", stderr.String())
+	}
+	if replays == 0 {
+		t.Fatal("minimize never replayed against a host")
+	}
+}
+
+func TestCLISecrets(t *testing.T) {
+	t.Setenv(secretsKeyEnv, "passphrase")
+	c, stdout, stderr, _ := newTestCLI(t)
+	c.app.secrets = newSecretVault(filepath.Join(t.TempDir(), "secrets.json"))
+
+	c.stdin = strings.NewReader("s3cret\n")
+	if code, _ := c.run([]string{"secrets", "set", "PASSWD"}); code != cliExitOK {
+		t.Fatalf("secrets set = %d, want %d (stderr %q)", code, cliExitOK, stderr.String())
+	}
+	if value, ok := c.app.secrets.lookup("PASSWD"); !ok || value != "s3cret" {
+		t.Fatalf("lookup = %q, %v, want s3cret without the newline", value, ok)
+	}
+
+	stdout.Reset()
+	if code, _ := c.run([]string{"secrets", "list"}); code != cliExitOK || stdout.String() != "PASSWD\n" {
+		t.Fatalf("secrets list = %d, %q, want PASSWD", code, stdout.String())
+	}
+	if code, _ := c.run([]string{"secrets", "delete", "PASSWD"}); code != cliExitOK {
+		t.Fatalf("secrets delete = %d, want %d", code, cliExitOK)
+	}
+	if code, _ := c.run([]string{"secrets", "delete", "PASSWD"}); code != cliExitFailed {
+		t.Fatalf("second delete = %d, want %d", code, cliExitFailed)
+	}
+	if code, _ := c.run([]string{"secrets", "set"}); code != cliExitUsage {
+		t.Fatalf("set without name = %d, want %d", code, cliExitUsage)
+	}
+}
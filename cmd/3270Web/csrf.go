@@ -18,6 +18,12 @@ func OriginRefererCheckMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Token-authenticated API calls are not driven by browser cookies.
+		if isAPITokenRequest(c.Request) {
+			c.Next()
+			return
+		}
+
 		// Check Origin header first (preferred)
 		origin := c.Request.Header.Get("Origin")
 		if origin != "" {
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const maxTransferUploadBytes = 64 * 1024 * 1024
+
+// transferStore tracks the IND$FILE transfer started from each browser
+// session. Only one transfer runs per session because it occupies the
+// session's host connection.
+type transferStore struct {
+	mu        sync.Mutex
+	transfers map[string]*fileTransfer
+}
+
+func newTransferStore() *transferStore {
+	return &transferStore{transfers: make(map[string]*fileTransfer)}
+}
+
+func (s *transferStore) get(sessionID string) (*fileTransfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tr, ok := s.transfers[sessionID]
+	return tr, ok
+}
+
+func (s *transferStore) set(sessionID string, tr *fileTransfer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transfers[sessionID] = tr
+}
+
+func (s *transferStore) delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.transfers, sessionID)
+}
+
+// fileTransfer is one upload or download. The local side lives in its own
+// temp directory, removed once an upload finishes or the download is
+// replaced or the session closes.
+type fileTransfer struct {
+	opts       host.TransferOptions
+	fileName   string
+	dir        string
+	totalBytes int64
+
+	mu         sync.Mutex
+	active     bool
+	discarded  bool
+	startedAt  time.Time
+	finishedAt time.Time
+	message    string
+	err        string
+}
+
+// fileTransferStatus is the JSON shape polled by the transfer dialog.
+type fileTransferStatus struct {
+	Direction     string     `json:"direction"`
+	HostFile      string     `json:"hostFile"`
+	FileName      string     `json:"fileName"`
+	Active        bool       `json:"active"`
+	Bytes         int64      `json:"bytes"`
+	TotalBytes    int64      `json:"totalBytes,omitempty"`
+	StartedAt     time.Time  `json:"startedAt"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+	ElapsedSec    float64    `json:"elapsedSec"`
+	Message       string     `json:"message,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	DownloadReady bool       `json:"downloadReady"`
+}
+
+func (tr *fileTransfer) run(ft host.FileTransferer) {
+	message, err := ft.Transfer(tr.opts)
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.active = false
+	tr.finishedAt = time.Now()
+	tr.message = message
+	if err != nil {
+		tr.err = err.Error()
+		log.Printf("File transfer %s %q failed: %v", tr.opts.Direction, tr.opts.HostFile, err)
+	}
+	if tr.discarded || tr.opts.Direction == host.TransferSend || err != nil {
+		_ = os.RemoveAll(tr.dir)
+	}
+}
+
+// Active reports whether the transfer is still running on the host.
+func (tr *fileTransfer) Active() bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.active
+}
+
+// Status snapshots the transfer. Downloads report the bytes written so far;
+// s3270 gives no progress for uploads until they complete.
+func (tr *fileTransfer) Status() fileTransferStatus {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	st := fileTransferStatus{
+		Direction:  tr.opts.Direction,
+		HostFile:   tr.opts.HostFile,
+		FileName:   tr.fileName,
+		Active:     tr.active,
+		TotalBytes: tr.totalBytes,
+		StartedAt:  tr.startedAt,
+		Message:    tr.message,
+		Error:      tr.err,
+	}
+	end := time.Now()
+	if !tr.active {
+		finished := tr.finishedAt
+		st.FinishedAt = &finished
+		end = finished
+	}
+	st.ElapsedSec = end.Sub(tr.startedAt).Seconds()
+	switch {
+	case tr.opts.Direction == host.TransferReceive:
+		if info, err := os.Stat(tr.opts.LocalFile); err == nil {
+			st.Bytes = info.Size()
+		}
+		st.DownloadReady = !tr.active && tr.err == "" && !tr.discarded
+	case !tr.active && tr.err == "":
+		st.Bytes = tr.totalBytes
+	}
+	return st
+}
+
+// discard removes the local file, or marks it for removal when the transfer
+// is still running.
+func (tr *fileTransfer) discard() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.discarded = true
+	if !tr.active {
+		_ = os.RemoveAll(tr.dir)
+	}
+}
+
+// transferOptionsFromRequest reads the transfer settings shared by uploads
+// and downloads from the posted form.
+func transferOptionsFromRequest(c *gin.Context, direction string) (host.TransferOptions, error) {
+	opts := host.TransferOptions{
+		Direction:  direction,
+		HostFile:   strings.TrimSpace(c.PostForm("hostFile")),
+		HostType:   strings.ToLower(strings.TrimSpace(c.PostForm("hostType"))),
+		Mode:       strings.ToLower(strings.TrimSpace(c.PostForm("mode"))),
+		CR:         strings.ToLower(strings.TrimSpace(c.PostForm("cr"))),
+		Exist:      strings.ToLower(strings.TrimSpace(c.PostForm("exist"))),
+		Recfm:      strings.ToLower(strings.TrimSpace(c.PostForm("recfm"))),
+		Allocation: strings.ToLower(strings.TrimSpace(c.PostForm("allocation"))),
+	}
+	numbers := []struct {
+		field  string
+		target *int
+	}{
+		{"lrecl", &opts.Lrecl},
+		{"blksize", &opts.Blksize},
+		{"primary", &opts.Primary},
+		{"secondary", &opts.Secondary},
+		{"avblock", &opts.Avblock},
+	}
+	for _, n := range numbers {
+		raw := strings.TrimSpace(c.PostForm(n.field))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return opts, fmt.Errorf("%s must be a non-negative number", n.field)
+		}
+		*n.target = v
+	}
+	if opts.HostFile == "" {
+		return opts, errors.New("host file is required")
+	}
+	return opts, nil
+}
+
+// transferFileName turns an upload name or host data set name into a safe
+// local file name.
+func transferFileName(name string) string {
+	name = strings.Trim(filepath.Base(strings.TrimSpace(name)), "'\"")
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	cleaned := strings.Trim(b.String(), "._")
+	if cleaned == "" {
+		return "transfer.dat"
+	}
+	return cleaned
+}
+
+// transferHost returns the session's host when it can take a transfer now.
+func (app *App) transferHost(s *session.Session) (host.FileTransferer, int, error) {
+	if tr, ok := app.transfers.get(s.ID); ok && tr.Active() {
+		return nil, http.StatusConflict, errors.New("a file transfer is already running")
+	}
+	if playbackActive(s) {
+		return nil, http.StatusConflict, errors.New("stop playback before transferring files")
+	}
+	if eng, ok := app.chaosEngines.get(s.ID); ok && eng.Status().Active {
+		return nil, http.StatusConflict, errors.New("stop chaos exploration before transferring files")
+	}
+	var h host.Host
+	withSessionLock(s, func() { h = s.Host })
+	if h == nil || !h.IsConnected() {
+		return nil, http.StatusBadRequest, errors.New("not connected to host")
+	}
+	ft, ok := h.(host.FileTransferer)
+	if !ok {
+		return nil, http.StatusBadRequest, errors.New("file transfer requires the s3270 host engine")
+	}
+	return ft, http.StatusOK, nil
+}
+
+// startFileTransfer replaces the session's previous transfer and runs tr in
+// the background.
+func (app *App) startFileTransfer(s *session.Session, ft host.FileTransferer, tr *fileTransfer) error {
+	if err := tr.opts.Validate(); err != nil {
+		_ = os.RemoveAll(tr.dir)
+		return err
+	}
+	if previous, ok := app.transfers.get(s.ID); ok {
+		previous.discard()
+	}
+	tr.active = true
+	tr.startedAt = time.Now()
+	app.transfers.set(s.ID, tr)
+	go tr.run(ft)
+	return nil
+}
+
+// TransferUploadHandler handles POST /transfer/upload. It sends the posted
+// file to the host with IND$FILE over the session's connection.
+func (app *App) TransferUploadHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTransferUploadBytes+1024*1024)
+	opts, err := transferOptionsFromRequest(c, host.TransferSend)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ft, status, err := app.transferHost(s)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	upload, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if upload.Size > maxTransferUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds %d bytes", maxTransferUploadBytes)})
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "3270Web-transfer-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	name := transferFileName(upload.Filename)
+	opts.LocalFile = filepath.Join(dir, name)
+	size, err := saveTransferUpload(upload, opts.LocalFile)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tr := &fileTransfer{opts: opts, fileName: name, dir: dir, totalBytes: size}
+	if err := app.startFileTransfer(s, ft, tr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "started"})
+}
+
+func saveTransferUpload(upload *multipart.FileHeader, path string) (int64, error) {
+	src, err := upload.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// TransferDownloadHandler handles POST /transfer/download. It receives the
+// host file into a temp file that GET /transfer/file then serves.
+func (app *App) TransferDownloadHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := transferOptionsFromRequest(c, host.TransferReceive)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ft, status, err := app.transferHost(s)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	dir, err := os.MkdirTemp("", "3270Web-transfer-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	name := transferFileName(opts.HostFile)
+	opts.LocalFile = filepath.Join(dir, name)
+	tr := &fileTransfer{opts: opts, fileName: name, dir: dir}
+	if err := app.startFileTransfer(s, ft, tr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "started"})
+}
+
+// TransferStatusHandler handles GET /transfer/status for the progress display.
+func (app *App) TransferStatusHandler(c *gin.Context) {
+	s := app.peekSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	tr, ok := app.transfers.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"transfer": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transfer": tr.Status()})
+}
+
+// TransferFileHandler handles GET /transfer/file and returns the last
+// completed download.
+func (app *App) TransferFileHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	tr, ok := app.transfers.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no download for this session"})
+		return
+	}
+	st := tr.Status()
+	if !st.DownloadReady {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no completed download for this session"})
+		return
+	}
+	c.FileAttachment(tr.opts.LocalFile, tr.fileName)
+}
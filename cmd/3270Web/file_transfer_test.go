@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func newTransferTestRouter(t *testing.T) (*gin.Engine, *App, *host.MockHost, *session.Session) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), transfers: newTransferStore()}
+	mockHost, _ := host.NewMockHost("")
+	mockHost.Connected = true
+	sess := app.SessionManager.CreateSession(mockHost)
+
+	r := gin.New()
+	r.POST("/transfer/upload", app.TransferUploadHandler)
+	r.POST("/transfer/download", app.TransferDownloadHandler)
+	r.GET("/transfer/status", app.TransferStatusHandler)
+	r.GET("/transfer/file", app.TransferFileHandler)
+	return r, app, mockHost, sess
+}
+
+func serveTransfer(r *gin.Engine, sess *session.Session, req *http.Request) *httptest.ResponseRecorder {
+	req.AddCookie(&http.Cookie{Name: "3270Web_session", Value: sess.ID})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func waitForTransfer(t *testing.T, tr *fileTransfer) fileTransferStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for tr.Active() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return tr.Status()
+}
+
+func TestTransferUploadSendsFileToHost(t *testing.T) {
+	r, app, mockHost, sess := newTransferTestRouter(t)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("hostFile", "'USER.JCL(BUILD)'")
+	_ = mw.WriteField("mode", "ascii")
+	_ = mw.WriteField("cr", "add")
+	_ = mw.WriteField("recfm", "fixed")
+	_ = mw.WriteField("lrecl", "80")
+	part, _ := mw.CreateFormFile("file", "build.jcl")
+	_, _ = part.Write([]byte("//BUILD JOB\n"))
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/transfer/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if w := serveTransfer(r, sess, req); w.Code != http.StatusOK {
+		t.Fatalf("upload = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	tr, ok := app.transfers.get(sess.ID)
+	if !ok {
+		t.Fatal("no transfer recorded for the session")
+	}
+	st := waitForTransfer(t, tr)
+	if st.Active || st.Error != "" || st.Bytes != 12 || st.TotalBytes != 12 {
+		t.Fatalf("status = %+v, want a finished 12 byte upload", st)
+	}
+	if len(mockHost.Commands) != 1 || !strings.HasPrefix(mockHost.Commands[0], "Transfer(Direction=send,") ||
+		!strings.Contains(mockHost.Commands[0], `"HostFile='USER.JCL(BUILD)'"`) || !strings.Contains(mockHost.Commands[0], "Lrecl=80") {
+		t.Fatalf("host commands = %v, want one send transfer", mockHost.Commands)
+	}
+	if _, err := os.Stat(tr.dir); !os.IsNotExist(err) {
+		t.Fatalf("upload temp dir still exists: %v", err)
+	}
+}
+
+func TestTransferDownloadServesReceivedFile(t *testing.T) {
+	r, app, mockHost, sess := newTransferTestRouter(t)
+	mockHost.TransferData = []byte("HELLO FROM TSO\n")
+
+	form := url.Values{"hostFile": {"'USER.DATA'"}, "mode": {"ascii"}, "cr": {"remove"}}
+	req := httptest.NewRequest(http.MethodPost, "/transfer/download", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if w := serveTransfer(r, sess, req); w.Code != http.StatusOK {
+		t.Fatalf("download = %d %s, want 200", w.Code, w.Body.String())
+	}
+	tr, _ := app.transfers.get(sess.ID)
+	waitForTransfer(t, tr)
+
+	w := serveTransfer(r, sess, httptest.NewRequest(http.MethodGet, "/transfer/status", nil))
+	var status struct {
+		Transfer fileTransferStatus `json:"transfer"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if !status.Transfer.DownloadReady || status.Transfer.Bytes != 15 || status.Transfer.FileName != "USER.DATA" {
+		t.Fatalf("status = %+v, want a ready 15 byte download named USER.DATA", status.Transfer)
+	}
+
+	w = serveTransfer(r, sess, httptest.NewRequest(http.MethodGet, "/transfer/file", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "HELLO FROM TSO\n" {
+		t.Fatalf("file = %d %q, want the received data", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), "USER.DATA") {
+		t.Fatalf("Content-Disposition = %q, want the host file name", w.Header().Get("Content-Disposition"))
+	}
+
+	app.closeSession(sess)
+	if _, err := os.Stat(tr.dir); !os.IsNotExist(err) {
+		t.Fatalf("download temp dir survives the session: %v", err)
+	}
+}
+
+func TestTransferRejectsUnsupportedRequests(t *testing.T) {
+	r, _, mockHost, sess := newTransferTestRouter(t)
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/transfer/download", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return serveTransfer(r, sess, req)
+	}
+
+	tests := []struct {
+		name    string
+		form    url.Values
+		want    int
+		wantErr string
+	}{
+		{name: "missing host file", form: url.Values{}, want: http.StatusBadRequest, wantErr: "host file is required"},
+		{name: "bad lrecl", form: url.Values{"hostFile": {"A"}, "lrecl": {"x"}}, want: http.StatusBadRequest, wantErr: "lrecl"},
+		{name: "bad host type", form: url.Values{"hostFile": {"A"}, "hostType": {"ims"}}, want: http.StatusBadRequest, wantErr: "host type"},
+	}
+	for _, tt := range tests {
+		w := post(tt.form)
+		if w.Code != tt.want || !strings.Contains(w.Body.String(), tt.wantErr) {
+			t.Fatalf("%s: = %d %s, want %d %q", tt.name, w.Code, w.Body.String(), tt.want, tt.wantErr)
+		}
+	}
+
+	mockHost.Connected = false
+	if w := post(url.Values{"hostFile": {"A"}}); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "not connected") {
+		t.Fatalf("disconnected = %d %s, want not connected", w.Code, w.Body.String())
+	}
+
+	mockHost.Connected = true
+	withSessionLock(sess, func() { sess.Host = struct{ host.Host }{mockHost} })
+	if w := post(url.Values{"hostFile": {"A"}}); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "s3270") {
+		t.Fatalf("native engine = %d %s, want an s3270 engine error", w.Code, w.Body.String())
+	}
+}
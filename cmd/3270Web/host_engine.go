@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jnnngs/3270Web/internal/config"
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+// Host engines selectable per connection.
+const (
+	hostEngineS3270  = "s3270"
+	hostEngineNative = "native"
+)
+
+var hostEngineValues = map[string]struct{}{
+	hostEngineS3270:  {},
+	hostEngineNative: {},
+}
+
+// resolveHostEngine returns the requested engine when valid, otherwise the
+// APP_HOST_ENGINE default, otherwise s3270.
+func resolveHostEngine(requested string) string {
+	for _, candidate := range []string{requested, os.Getenv("APP_HOST_ENGINE")} {
+		engine := strings.ToLower(strings.TrimSpace(candidate))
+		if _, ok := hostEngineValues[engine]; ok {
+			return engine
+		}
+	}
+	return hostEngineS3270
+}
+
+// nativeHostOptions maps the s3270 model and code page settings onto the
+// native client, honouring .env overrides and the profile the same way
+// buildS3270Args does.
+func nativeHostOptions(opts config.S3270Options, profile *config.ConnectionProfile) host.TN3270Options {
+	envOverrides, err := config.S3270EnvOverridesFromEnv()
+	if err != nil {
+		log.Printf("Warning: invalid .env s3270 options: %v", err)
+	}
+	native := host.TN3270Options{
+		Model:    opts.Model,
+		CodePage: opts.Charset,
+	}
+	if envOverrides.HasModel {
+		native.Model = envOverrides.Model
+	}
+	if envOverrides.HasCodePage {
+		native.CodePage = envOverrides.CodePage
+	}
+	if profile != nil {
+		if profile.Model != "" {
+			native.Model = profile.Model
+		}
+		if profile.CodePage != "" {
+			native.CodePage = profile.CodePage
+		}
+		native.LU = profile.LU
+	}
+	return native
+}
+
+// newHost builds the host for hostname using the given engine. Sample app and
+// demo targets start their bundled server first; profile targets connect with
+// the profile's settings.
+func (app *App) newHost(hostname, engine string) (host.Host, error) {
+	hostname, profile, err := app.resolveTarget(hostname)
+	if err != nil {
+		return nil, err
+	}
+	native := resolveHostEngine(engine) == hostEngineNative
+	if native && profile != nil && (profile.TLS || profile.Proxy != "") {
+		return nil, fmt.Errorf("profile %q uses TLS or a proxy, which need the s3270 engine", profile.Name)
+	}
+	sampleID, samplePort, isSample := parseSampleAppHost(hostname)
+	if isSample {
+		if samplePort > 0 && !isAllowedSampleAppPort(samplePort) {
+			return nil, fmt.Errorf("invalid sample app port %d", samplePort)
+		}
+	} else if hostname == "mock" || hostname == "demo" {
+		sampleID, samplePort, isSample = "app1", defaultSampleAppPort, true
+	}
+
+	if isSample {
+		if native {
+			return newNativeSampleAppHost(sampleID, samplePort, nativeHostOptions(app.Config.S3270Options, nil))
+		}
+		execPath := resolveS3270Path(app.Config.ExecPath)
+		return newSampleAppHost(sampleID, samplePort, execPath, app.Config.S3270Options)
+	}
+	if native {
+		return host.NewTN3270(hostname, nativeHostOptions(app.Config.S3270Options, profile)), nil
+	}
+	execPath := resolveS3270Path(app.Config.ExecPath)
+	args := buildS3270Args(app.Config.S3270Options, hostname, profile)
+	return host.NewS3270(execPath, args...), nil
+}
+
+func newNativeSampleAppHost(id string, port int, opts host.TN3270Options) (host.Host, error) {
+	cfg, ok := sampleAppConfig(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown sample app %q", id)
+	}
+	port = sampleAppPort(port)
+	target := fmt.Sprintf("127.0.0.1:%d", port)
+	return host.NewGoSampleAppNativeHost(cfg.ID, port, target, opts)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/config"
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+func TestResolveHostEngine(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		env       string
+		want      string
+	}{
+		{name: "default", want: hostEngineS3270},
+		{name: "requested native", requested: "native", want: hostEngineNative},
+		{name: "requested wins over env", requested: "s3270", env: "native", want: hostEngineS3270},
+		{name: "env default", env: "Native", want: hostEngineNative},
+		{name: "invalid falls back", requested: "bogus", env: "bogus", want: hostEngineS3270},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_HOST_ENGINE", tt.env)
+			if got := resolveHostEngine(tt.requested); got != tt.want {
+				t.Fatalf("resolveHostEngine(%q) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHost_SelectsEngine(t *testing.T) {
+	t.Setenv("APP_HOST_ENGINE", "")
+	t.Setenv("S3270_MODEL", "3279-4-E")
+	t.Setenv("S3270_CODE_PAGE", "cp037")
+	app := &App{Config: &config.Config{}}
+
+	h, err := app.newHost("mainframe.example.com:23", hostEngineNative)
+	if err != nil {
+		t.Fatalf("newHost failed: %v", err)
+	}
+	native, ok := h.(*host.TN3270)
+	if !ok {
+		t.Fatalf("newHost native = %T, want *host.TN3270", h)
+	}
+	if native.Options.Model != "3279-4-E" || native.Options.CodePage != "cp037" {
+		t.Fatalf("native options = %+v, want model and code page from .env", native.Options)
+	}
+
+	h, err = app.newHost("mainframe.example.com:23", "")
+	if err != nil {
+		t.Fatalf("newHost failed: %v", err)
+	}
+	if _, ok := h.(*host.S3270); !ok {
+		t.Fatalf("newHost default = %T, want *host.S3270", h)
+	}
+
+	h, err = app.newHost("sampleapp:app1", hostEngineNative)
+	if err != nil {
+		t.Fatalf("newHost sample app failed: %v", err)
+	}
+	sample, ok := h.(*host.GoSampleAppHost)
+	if !ok || !sample.Native {
+		t.Fatalf("newHost sample app = %#v, want native sample app host", h)
+	}
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const (
+	maxLoadTestUsers      = 250
+	maxLoadTestIterations = 1000
+	maxLoadTestErrors     = 20
+)
+
+// loadTestStore tracks the load test started from each browser session.
+type loadTestStore struct {
+	mu   sync.Mutex
+	runs map[string]*loadTestRun
+}
+
+func newLoadTestStore() *loadTestStore {
+	return &loadTestStore{runs: make(map[string]*loadTestRun)}
+}
+
+func (s *loadTestStore) get(sessionID string) (*loadTestRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[sessionID]
+	return run, ok
+}
+
+func (s *loadTestStore) set(sessionID string, run *loadTestRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[sessionID] = run
+}
+
+func (s *loadTestStore) delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, sessionID)
+}
+
+// loadTestRun drives one workflow from many virtual users, each with its own
+// host connection, and collects per-step response times.
+type loadTestRun struct {
+	workflow     *WorkflowConfig
+	workflowName string
+	target       string
+	users        int
+	iterations   int
+	newHost      func() (host.Host, error)
+	// sessionID and user identify who started the run, for the audit log.
+	sessionID string
+	user      string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu                  sync.Mutex
+	active              bool
+	startedAt           time.Time
+	finishedAt          time.Time
+	vusers              []*session.Session
+	usersStarted        int
+	usersActive         int
+	usersCompleted      int
+	usersFailed         int
+	iterationsCompleted int
+	iterationsFailed    int
+	stepsRun            int
+	errorCount          int
+	stepDurations       [][]time.Duration
+	stepErrors          []int
+	recentErrors        []loadTestError
+}
+
+type loadTestError struct {
+	Time    time.Time `json:"time"`
+	User    int       `json:"user"`
+	Step    int       `json:"step"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+type loadTestStepReport struct {
+	Step   int     `json:"step"`
+	Type   string  `json:"type"`
+	Count  int     `json:"count"`
+	Errors int     `json:"errors"`
+	MinMs  float64 `json:"minMs"`
+	AvgMs  float64 `json:"avgMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P90Ms  float64 `json:"p90Ms"`
+	P95Ms  float64 `json:"p95Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+// loadTestReport is the dashboard snapshot and the downloadable report.
+type loadTestReport struct {
+	Workflow            string               `json:"workflow"`
+	Host                string               `json:"host"`
+	Active              bool                 `json:"active"`
+	StartedAt           time.Time            `json:"startedAt"`
+	FinishedAt          *time.Time           `json:"finishedAt,omitempty"`
+	ElapsedSec          float64              `json:"elapsedSec"`
+	Users               int                  `json:"users"`
+	IterationsPerUser   int                  `json:"iterationsPerUser"`
+	RampUpBatchSize     int                  `json:"rampUpBatchSize"`
+	RampUpDelaySec      float64              `json:"rampUpDelaySec"`
+	UsersStarted        int                  `json:"usersStarted"`
+	UsersActive         int                  `json:"usersActive"`
+	UsersCompleted      int                  `json:"usersCompleted"`
+	UsersFailed         int                  `json:"usersFailed"`
+	IterationsCompleted int                  `json:"iterationsCompleted"`
+	IterationsFailed    int                  `json:"iterationsFailed"`
+	StepsRun            int                  `json:"stepsRun"`
+	Errors              int                  `json:"errors"`
+	IterationsPerSec    float64              `json:"iterationsPerSec"`
+	StepsPerSec         float64              `json:"stepsPerSec"`
+	Steps               []loadTestStepReport `json:"steps"`
+	RecentErrors        []loadTestError      `json:"recentErrors"`
+}
+
+func newLoadTestRun(workflow *WorkflowConfig, name, target string, users, iterations int, newHost func() (host.Host, error)) *loadTestRun {
+	return &loadTestRun{
+		workflow:      workflow,
+		workflowName:  name,
+		target:        target,
+		users:         users,
+		iterations:    iterations,
+		newHost:       newHost,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		active:        true,
+		startedAt:     time.Now(),
+		stepDurations: make([][]time.Duration, len(workflow.Steps)),
+		stepErrors:    make([]int, len(workflow.Steps)),
+	}
+}
+
+// Stop asks every virtual user to finish its current step and disconnect.
+func (run *loadTestRun) Stop() {
+	run.stopOnce.Do(func() {
+		close(run.stop)
+		run.mu.Lock()
+		vusers := append([]*session.Session(nil), run.vusers...)
+		run.mu.Unlock()
+		for _, vs := range vusers {
+			withSessionLock(vs, func() {
+				vs.Playback.StopRequested = true
+			})
+		}
+	})
+}
+
+func (run *loadTestRun) stopped() bool {
+	select {
+	case <-run.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Active reports whether virtual users are still running.
+func (run *loadTestRun) Active() bool {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return run.active
+}
+
+// runLoadTest starts virtual users in batches of RampUpBatchSize, RampUpDelay
+// seconds apart, and returns once every user has finished.
+func (app *App) runLoadTest(run *loadTestRun) {
+	defer func() {
+		run.mu.Lock()
+		run.active = false
+		run.finishedAt = time.Now()
+		run.mu.Unlock()
+		close(run.done)
+	}()
+
+	batch := run.workflow.RampUpBatchSize
+	if batch <= 0 || batch > run.users {
+		batch = run.users
+	}
+	delay := time.Duration(run.workflow.RampUpDelay * float64(time.Second))
+	var wg sync.WaitGroup
+	for first := 0; first < run.users; first += batch {
+		if first > 0 && delay > 0 {
+			select {
+			case <-run.stop:
+			case <-time.After(delay):
+			}
+		}
+		if run.stopped() {
+			break
+		}
+		for user := first; user < first+batch && user < run.users; user++ {
+			wg.Add(1)
+			go func(user int) {
+				defer wg.Done()
+				app.runLoadTestUser(run, user)
+			}(user)
+		}
+	}
+	wg.Wait()
+}
+
+func (app *App) runLoadTestUser(run *loadTestRun, user int) {
+	run.mu.Lock()
+	run.usersStarted++
+	run.usersActive++
+	run.mu.Unlock()
+	failed, finished := false, false
+	defer func() {
+		run.mu.Lock()
+		run.usersActive--
+		if failed {
+			run.usersFailed++
+		} else if finished {
+			run.usersCompleted++
+		}
+		run.mu.Unlock()
+	}()
+
+	h, err := run.newHost()
+	if err == nil {
+		err = h.Start()
+	}
+	if err != nil {
+		run.recordError(user, 0, "Connect", err)
+		failed = true
+		return
+	}
+	defer h.Stop()
+
+	vs := &session.Session{
+		ID:         fmt.Sprintf("%s/vu%d", run.sessionID, user+1),
+		Host:       h,
+		User:       run.user,
+		ClientAddr: "loadtest",
+		Playback:   &session.WorkflowPlayback{Active: true, Mode: "play"},
+	}
+	app.setSessionTarget(vs, run.target)
+	run.mu.Lock()
+	run.vusers = append(run.vusers, vs)
+	run.mu.Unlock()
+	if run.stopped() {
+		return
+	}
+
+	rows, _ := run.workflow.Dataset.records()
+	for it := 0; it < run.iterations; it++ {
+		if it > 0 {
+			if err := restartWorkflowHost(vs); err != nil {
+				run.recordError(user, 0, "Connect", err)
+				failed = true
+				return
+			}
+			withSessionLock(vs, func() {
+				vs.Playback.PendingInput = false
+				vs.Playback.AuditFields = nil
+			})
+		}
+		var row map[string]string
+		if len(rows) > 0 {
+			row = rows[(it*run.users+user)%len(rows)]
+		}
+		ok, stopped := app.runLoadTestIteration(run, vs, user, row)
+		if stopped {
+			return
+		}
+		run.mu.Lock()
+		if ok {
+			run.iterationsCompleted++
+		} else {
+			run.iterationsFailed++
+			failed = true
+		}
+		run.mu.Unlock()
+
+		delayMin, delayMax := 0.0, 0.0
+		if run.workflow.EndOfTaskDelay != nil {
+			delayMin, delayMax = normalizeDelayRange(run.workflow.EndOfTaskDelay.Min, run.workflow.EndOfTaskDelay.Max)
+		}
+		if sleepCanceled(vs, randomDelay(delayMin, delayMax)) {
+			return
+		}
+	}
+	finished = true
+}
+
+// runLoadTestIteration plays the workflow once for a virtual user, timing
+// each step from input to updated screen.
+func (app *App) runLoadTestIteration(run *loadTestRun, vs *session.Session, user int, row map[string]string) (ok bool, stopped bool) {
+	for i, step := range run.workflow.Steps {
+		delayMin, delayMax := workflowDelayForStep(run.workflow, step)
+		if sleepCanceled(vs, randomDelay(delayMin, delayMax)) || shouldStopPlayback(vs) {
+			return false, true
+		}
+		started := time.Now()
+		err := app.applyWorkflowStep(vs, expandWorkflowStep(step, row))
+		elapsed := time.Since(started)
+		if errors.Is(err, errPlaybackStopped) {
+			return false, true
+		}
+		run.mu.Lock()
+		run.stepsRun++
+		run.stepDurations[i] = append(run.stepDurations[i], elapsed)
+		run.mu.Unlock()
+		if err != nil {
+			run.recordError(user, i+1, step.Type, err)
+			return false, false
+		}
+	}
+	return true, false
+}
+
+func (run *loadTestRun) recordError(user, step int, stepType string, err error) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.errorCount++
+	if step > 0 {
+		run.stepErrors[step-1]++
+	}
+	run.recentErrors = append(run.recentErrors, loadTestError{
+		Time:    time.Now(),
+		User:    user + 1,
+		Step:    step,
+		Type:    stepType,
+		Message: err.Error(),
+	})
+	if len(run.recentErrors) > maxLoadTestErrors {
+		run.recentErrors = run.recentErrors[len(run.recentErrors)-maxLoadTestErrors:]
+	}
+}
+
+// Report snapshots the run's counters and step percentiles.
+func (run *loadTestRun) Report() loadTestReport {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	end := time.Now()
+	report := loadTestReport{
+		Workflow:            run.workflowName,
+		Host:                run.target,
+		Active:              run.active,
+		StartedAt:           run.startedAt,
+		Users:               run.users,
+		IterationsPerUser:   run.iterations,
+		RampUpBatchSize:     run.workflow.RampUpBatchSize,
+		RampUpDelaySec:      run.workflow.RampUpDelay,
+		UsersStarted:        run.usersStarted,
+		UsersActive:         run.usersActive,
+		UsersCompleted:      run.usersCompleted,
+		UsersFailed:         run.usersFailed,
+		IterationsCompleted: run.iterationsCompleted,
+		IterationsFailed:    run.iterationsFailed,
+		StepsRun:            run.stepsRun,
+		Errors:              run.errorCount,
+		RecentErrors:        append([]loadTestError{}, run.recentErrors...),
+	}
+	if !run.active {
+		finished := run.finishedAt
+		report.FinishedAt = &finished
+		end = finished
+	}
+	if elapsed := end.Sub(run.startedAt).Seconds(); elapsed > 0 {
+		report.ElapsedSec = elapsed
+		report.IterationsPerSec = float64(run.iterationsCompleted+run.iterationsFailed) / elapsed
+		report.StepsPerSec = float64(run.stepsRun) / elapsed
+	}
+	for i, step := range run.workflow.Steps {
+		stats := loadTestStepReport{Step: i + 1, Type: step.Type, Errors: run.stepErrors[i]}
+		durations := append([]time.Duration(nil), run.stepDurations[i]...)
+		stats.Count = len(durations)
+		if len(durations) > 0 {
+			sort.Slice(durations, func(a, b int) bool { return durations[a] < durations[b] })
+			var total time.Duration
+			for _, d := range durations {
+				total += d
+			}
+			stats.MinMs = durationMs(durations[0])
+			stats.MaxMs = durationMs(durations[len(durations)-1])
+			stats.AvgMs = durationMs(total / time.Duration(len(durations)))
+			stats.P50Ms = durationMs(percentile(durations, 50))
+			stats.P90Ms = durationMs(percentile(durations, 90))
+			stats.P95Ms = durationMs(percentile(durations, 95))
+			stats.P99Ms = durationMs(percentile(durations, 99))
+		}
+		report.Steps = append(report.Steps, stats)
+	}
+	return report
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func durationMs(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*10) / 10
+}
+
+// loadTestReportCSV renders the per-step statistics as CSV.
+func loadTestReportCSV(report loadTestReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"step", "type", "count", "errors", "min_ms", "avg_ms", "p50_ms", "p90_ms", "p95_ms", "p99_ms", "max_ms"})
+	ms := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	for _, s := range report.Steps {
+		_ = w.Write([]string{
+			strconv.Itoa(s.Step), s.Type, strconv.Itoa(s.Count), strconv.Itoa(s.Errors),
+			ms(s.MinMs), ms(s.AvgMs), ms(s.P50Ms), ms(s.P90Ms), ms(s.P95Ms), ms(s.P99Ms), ms(s.MaxMs),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// loadTestStartRequest is the JSON body accepted by POST /loadtest/start.
+type loadTestStartRequest struct {
+	Users      int `json:"users"`
+	Iterations int `json:"iterations"`
+}
+
+// LoadTestStartHandler handles POST /loadtest/start. It runs the loaded
+// recording against new host sessions that are separate from the user's own.
+func (app *App) LoadTestStartHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	var req loadTestStartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if req.Iterations == 0 {
+		req.Iterations = 1
+	}
+	if req.Users < 1 || req.Users > maxLoadTestUsers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("users must be between 1 and %d", maxLoadTestUsers)})
+		return
+	}
+	if req.Iterations < 1 || req.Iterations > maxLoadTestIterations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("iterations must be between 1 and %d", maxLoadTestIterations)})
+		return
+	}
+	if existing, ok := app.loadTests.get(s.ID); ok && existing.Active() {
+		c.JSON(http.StatusConflict, gin.H{"error": "a load test is already running"})
+		return
+	}
+
+	var payload []byte
+	var name, engine string
+	withSessionLock(s, func() {
+		if s.LoadedWorkflow != nil {
+			payload = append([]byte(nil), s.LoadedWorkflow.Payload...)
+			name = s.LoadedWorkflow.Name
+		}
+		engine = s.HostEngine
+	})
+	if len(payload) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "load a recording first"})
+		return
+	}
+	workflow, err := parseWorkflowPayload(payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target, err := workflowTargetHost(s, workflow)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isValidHostname(target) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid hostname format: %q", target)})
+		return
+	}
+
+	run := newLoadTestRun(workflow, name, target, req.Users, req.Iterations, func() (host.Host, error) {
+		return app.newHost(target, engine)
+	})
+	withSessionLock(s, func() {
+		run.sessionID, run.user = s.ID, s.User
+	})
+	app.loadTests.set(s.ID, run)
+	go app.runLoadTest(run)
+	c.JSON(http.StatusOK, gin.H{"status": "started"})
+}
+
+// LoadTestStopHandler handles POST /loadtest/stop.
+func (app *App) LoadTestStopHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	run, ok := app.loadTests.get(s.ID)
+	if !ok || !run.Active() {
+		c.JSON(http.StatusOK, gin.H{"status": "not running"})
+		return
+	}
+	run.Stop()
+	c.JSON(http.StatusOK, gin.H{"status": "stopping"})
+}
+
+// LoadTestStatusHandler handles GET /loadtest/status for the live dashboard.
+func (app *App) LoadTestStatusHandler(c *gin.Context) {
+	s := app.peekSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	run, ok := app.loadTests.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"run": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"run": run.Report()})
+}
+
+// LoadTestReportHandler handles GET /loadtest/report and downloads the latest
+// run as JSON, or as per-step CSV with ?format=csv.
+func (app *App) LoadTestReportHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	run, ok := app.loadTests.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no load test for this session"})
+		return
+	}
+	report := run.Report()
+	stamp := report.StartedAt.Format("20060102-150405")
+	if c.Query("format") == "csv" {
+		data, err := loadTestReportCSV(report)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=loadtest-%s.csv", stamp))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=loadtest-%s.json", stamp))
+	c.IndentedJSON(http.StatusOK, report)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func loadTestHostFactory(t *testing.T, failEvery int) (func() (host.Host, error), *int) {
+	t.Helper()
+	var mu sync.Mutex
+	created := 0
+	return func() (host.Host, error) {
+		mu.Lock()
+		created++
+		n := created
+		mu.Unlock()
+		if failEvery > 0 && n%failEvery == 0 {
+			return nil, errors.New("connection refused")
+		}
+		h, err := host.NewMockHost("")
+		if err != nil {
+			return nil, err
+		}
+		copy(h.Screen.Buffer[0], []rune("READY"))
+		return h, nil
+	}, &created
+}
+
+func TestRunLoadTestRampsUpAndCollectsStats(t *testing.T) {
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), loadTests: newLoadTestStore()}
+	workflow := &WorkflowConfig{
+		RampUpBatchSize: 2,
+		RampUpDelay:     0.02,
+		Steps: []session.WorkflowStep{
+			{Type: "Connect"},
+			{Type: "WaitForText", Text: "READY"},
+			{Type: "PressEnter"},
+			{Type: "Disconnect"},
+		},
+	}
+	newHost, created := loadTestHostFactory(t, 0)
+	run := newLoadTestRun(workflow, "flow.json", "localhost:3270", 5, 2, newHost)
+
+	started := time.Now()
+	app.runLoadTest(run)
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Fatalf("run took %v, want at least two ramp-up delays", elapsed)
+	}
+
+	report := run.Report()
+	if report.Active || report.FinishedAt == nil {
+		t.Fatalf("report active = %v finished = %v, want finished run", report.Active, report.FinishedAt)
+	}
+	if *created != 5 {
+		t.Fatalf("hosts created = %d, want 5", *created)
+	}
+	if report.UsersStarted != 5 || report.UsersCompleted != 5 || report.UsersFailed != 0 || report.UsersActive != 0 {
+		t.Fatalf("users = %+v, want 5 started and completed", report)
+	}
+	if report.IterationsCompleted != 10 || report.StepsRun != 40 || report.Errors != 0 {
+		t.Fatalf("iterations = %d steps = %d errors = %d, want 10, 40, 0", report.IterationsCompleted, report.StepsRun, report.Errors)
+	}
+	if len(report.Steps) != 4 {
+		t.Fatalf("step stats = %d, want 4", len(report.Steps))
+	}
+	for _, step := range report.Steps {
+		if step.Count != 10 || step.P50Ms > step.P99Ms || step.MaxMs < step.P99Ms {
+			t.Fatalf("step %d stats = %+v, want 10 ordered samples", step.Step, step)
+		}
+	}
+	if report.StepsPerSec <= 0 {
+		t.Fatalf("StepsPerSec = %v, want > 0", report.StepsPerSec)
+	}
+}
+
+func TestRunLoadTestRecordsFailures(t *testing.T) {
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), loadTests: newLoadTestStore()}
+	workflow := &WorkflowConfig{Steps: []session.WorkflowStep{
+		{Type: "Connect"},
+		{Type: "AssertText", Text: "MAIN MENU"},
+	}}
+	newHost, _ := loadTestHostFactory(t, 3)
+	run := newLoadTestRun(workflow, "flow.json", "localhost:3270", 3, 1, newHost)
+
+	app.runLoadTest(run)
+
+	report := run.Report()
+	if report.UsersFailed != 3 || report.UsersCompleted != 0 {
+		t.Fatalf("users failed = %d completed = %d, want 3 and 0", report.UsersFailed, report.UsersCompleted)
+	}
+	if report.Errors != 3 || report.Steps[1].Errors != 2 {
+		t.Fatalf("errors = %d, step 2 errors = %d, want 3 and 2", report.Errors, report.Steps[1].Errors)
+	}
+	connectErrors := 0
+	for _, e := range report.RecentErrors {
+		if e.Step == 0 && strings.Contains(e.Message, "connection refused") {
+			connectErrors++
+		}
+	}
+	if connectErrors != 1 {
+		t.Fatalf("recent errors = %+v, want one connection failure", report.RecentErrors)
+	}
+}
+
+func TestRunLoadTestStop(t *testing.T) {
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), loadTests: newLoadTestStore()}
+	workflow := &WorkflowConfig{Steps: []session.WorkflowStep{
+		{Type: "Connect"},
+		{Type: "WaitForText", Text: "NEVER", Timeout: 30},
+	}}
+	newHost, _ := loadTestHostFactory(t, 0)
+	run := newLoadTestRun(workflow, "flow.json", "localhost:3270", 2, 1, newHost)
+
+	go app.runLoadTest(run)
+	time.Sleep(50 * time.Millisecond)
+	run.Stop()
+	select {
+	case <-run.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("load test did not stop")
+	}
+	if report := run.Report(); report.UsersActive != 0 || report.UsersFailed != 0 {
+		t.Fatalf("report after stop = %+v, want no active or failed users", report)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 50, want: 5},
+		{p: 90, want: 9},
+		{p: 99, want: 10},
+		{p: 0, want: 1},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Fatalf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Fatalf("percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestLoadTestHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), loadTests: newLoadTestStore()}
+	mockHost, _ := host.NewMockHost("")
+	sess := app.SessionManager.CreateSession(mockHost)
+
+	r := gin.New()
+	r.POST("/loadtest/start", app.LoadTestStartHandler)
+	r.GET("/loadtest/status", app.LoadTestStatusHandler)
+	r.GET("/loadtest/report", app.LoadTestReportHandler)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: "3270Web_session", Value: sess.ID})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "no recording", body: `{"users":2}`, want: http.StatusBadRequest},
+		{name: "too many users", body: `{"users":100000}`, want: http.StatusBadRequest},
+		{name: "invalid json", body: `{`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(http.MethodPost, "/loadtest/start", tt.body); w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	w := do(http.MethodGet, "/loadtest/status", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"run":null`) {
+		t.Fatalf("status before run = %d %s, want null run", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/loadtest/report", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("report before run = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	workflow := &WorkflowConfig{Steps: []session.WorkflowStep{{Type: "Connect"}, {Type: "PressEnter"}}}
+	newHost, _ := loadTestHostFactory(t, 0)
+	run := newLoadTestRun(workflow, "flow.json", "localhost:3270", 1, 1, newHost)
+	app.loadTests.set(sess.ID, run)
+	app.runLoadTest(run)
+
+	w = do(http.MethodGet, "/loadtest/status", "")
+	var status struct {
+		Run loadTestReport `json:"run"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Run.UsersCompleted != 1 || len(status.Run.Steps) != 2 {
+		t.Fatalf("status run = %+v, want one completed user with two steps", status.Run)
+	}
+	w = do(http.MethodGet, "/loadtest/report?format=csv", "")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Code != http.StatusOK || len(lines) != 3 || !strings.HasPrefix(lines[2], "2,PressEnter,1,0,") {
+		t.Fatalf("csv report = %d %q, want header and two step rows", w.Code, w.Body.String())
+	}
+}
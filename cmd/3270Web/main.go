@@ -13,6 +13,7 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -27,36 +28,57 @@ import (
 
 	"github.com/gin-gonic/gin"
 	webassets "github.com/jnnngs/3270Web"
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/auth"
+	"github.com/jnnngs/3270Web/internal/chaos"
 	"github.com/jnnngs/3270Web/internal/config"
 	"github.com/jnnngs/3270Web/internal/host"
 	"github.com/jnnngs/3270Web/internal/render"
+	"github.com/jnnngs/3270Web/internal/secrets"
 	"github.com/jnnngs/3270Web/internal/session"
 )
 
 type App struct {
-	SessionManager *session.Manager
-	Renderer       render.Renderer
-	Config         *config.Config
-	themeCache     map[string]string
-	themeCacheMu   sync.RWMutex
-	logFilePath    string
-	envPath        string
-	baseDir        string
-	shutdown       func()
-	chaosEngines   *chaosEngineStore
-	chaosRunsDir   string
-	chaosHintsPath string
-	chaosHintsMu   sync.Mutex
+	SessionManager   *session.Manager
+	Renderer         render.Renderer
+	Config           *config.Config
+	themeCache       map[string]string
+	themeCacheMu     sync.RWMutex
+	logFilePath      string
+	envPath          string
+	baseDir          string
+	shutdown         func()
+	chaosEngines     *chaosEngineStore
+	loadTests        *loadTestStore
+	transfers        *transferStore
+	printers         *printerStore
+	screenHistory    *screenHistoryStore
+	screenRecordings *screenRecordingStore
+	shares           *shareStore
+	screenWatches    screenWatchStore
+	chaosRunsDir     string
+	chaosHintsPath   string
+	chaosHintsMu     sync.Mutex
+	findingRulesPath string
+	findingRulesMu   sync.Mutex
+	secrets          *secretVault
+	profiles         *profileStore
+	users            *auth.Store
+	logins           *authStore
+	audit            *audit.Log
 }
 
 type WorkflowConfig struct {
 	Host            string                      `json:"Host"`
 	Port            int                         `json:"Port"`
+	Profile         string                      `json:"Profile,omitempty"`
 	EveryStepDelay  *session.WorkflowDelayRange `json:"EveryStepDelay,omitempty"`
 	OutputFilePath  string                      `json:"OutputFilePath,omitempty"`
 	RampUpBatchSize int                         `json:"RampUpBatchSize,omitempty"`
 	RampUpDelay     float64                     `json:"RampUpDelay,omitempty"`
 	EndOfTaskDelay  *session.WorkflowDelayRange `json:"EndOfTaskDelay,omitempty"`
+	Dataset         *WorkflowDataset            `json:"Dataset,omitempty"`
+	ScreenCheck     string                      `json:"ScreenCheck,omitempty"`
 	Steps           []session.WorkflowStep      `json:"Steps"`
 }
 
@@ -76,6 +98,7 @@ const sampleAppPrefix = "sampleapp:"
 var sampleAppConfigs = []SampleAppConfig{
 	{ID: "app1", Name: "Sample App 1 - Name Entry & Validation"},
 	{ID: "app2", Name: "Sample App 2 - RSS Newsreader"},
+	{ID: "app3", Name: "Sample App 3 - Print Demo"},
 }
 
 const defaultSampleAppPort = 3270
@@ -123,16 +146,32 @@ func main() {
 	}
 
 	app := &App{
-		SessionManager: session.NewManager(),
-		Renderer:       render.NewHtmlRenderer(),
-		Config:         cfg,
-		themeCache:     make(map[string]string),
-		logFilePath:    filepath.Join(baseDir, "3270Web.log"),
-		envPath:        envPath,
-		baseDir:        baseDir,
-		chaosEngines:   newChaosEngineStore(),
-		chaosRunsDir:   filepath.Join(baseDir, "chaos-runs"),
-		chaosHintsPath: filepath.Join(baseDir, "chaos-hints.json"),
+		SessionManager:   session.NewManager(),
+		Renderer:         render.NewHtmlRenderer(),
+		Config:           cfg,
+		themeCache:       make(map[string]string),
+		logFilePath:      filepath.Join(baseDir, "3270Web.log"),
+		envPath:          envPath,
+		baseDir:          baseDir,
+		chaosEngines:     newChaosEngineStore(),
+		loadTests:        newLoadTestStore(),
+		transfers:        newTransferStore(),
+		printers:         newPrinterStore(),
+		screenHistory:    newScreenHistoryStore(),
+		screenRecordings: newScreenRecordingStore(),
+		shares:           newShareStore(),
+		chaosRunsDir:     filepath.Join(baseDir, "chaos-runs"),
+		chaosHintsPath:   filepath.Join(baseDir, "chaos-hints.json"),
+		findingRulesPath: filepath.Join(baseDir, "chaos-finding-rules.json"),
+		secrets:          newSecretVault(filepath.Join(baseDir, "secrets.json")),
+		profiles:         newProfileStore(filepath.Join(baseDir, "profiles.json")),
+		users:            auth.NewStore(filepath.Join(baseDir, "users.json")),
+		logins:           newAuthStore(),
+		audit:            audit.NewLog(filepath.Join(baseDir, "audit.jsonl")),
+	}
+
+	if code, handled := newCLI(app, os.Stdout, os.Stderr).run(os.Args[1:]); handled {
+		os.Exit(code)
 	}
 
 	r := gin.Default()
@@ -141,6 +180,7 @@ func main() {
 	}
 	r.Use(SecurityHeadersMiddleware())
 	r.Use(OriginRefererCheckMiddleware())
+	r.Use(app.AuthMiddleware())
 	templatesGlob, tmplErr := resolveTemplatesGlob(baseDir)
 	if tmplErr == nil {
 		r.LoadHTMLGlob(templatesGlob)
@@ -167,10 +207,43 @@ func main() {
 		r.StaticFS("/static", http.FS(staticFS))
 	}
 
+	// Sign-in (see AuthMiddleware); requireRole gates the admin and tester
+	// features below.
+	adminOnly := app.requireRole(auth.RoleAdmin)
+	testerOnly := app.requireRole(auth.RoleTester)
+	r.GET("/login", app.LoginPageHandler)
+	r.POST("/login", app.LoginHandler)
+	r.POST("/logout", app.LogoutHandler)
+	r.GET("/auth/oidc/login", app.OIDCLoginHandler)
+	r.GET("/auth/oidc/callback", app.OIDCCallbackHandler)
+
 	r.GET("/", app.HomeHandler)
 	r.POST("/connect", app.ConnectHandler)
 	r.GET("/screen", app.ScreenHandler)
 	r.GET("/screen/content", app.ScreenContentHandler)
+	r.GET("/screen/ws", app.ScreenWSHandler)
+	r.GET("/screen/history", app.ScreenHistoryHandler)
+	r.GET("/screen/history/:seq", app.ScreenHistorySnapshotHandler)
+	r.POST("/screenrec/start", app.ScreenRecordingStartHandler)
+	r.POST("/screenrec/stop", app.ScreenRecordingStopHandler)
+	r.GET("/screenrec/status", app.ScreenRecordingStatusHandler)
+	r.GET("/screenrec/download", app.ScreenRecordingDownloadHandler)
+	r.GET("/screenrec/events", app.ScreenRecordingEventsHandler)
+	r.GET("/replay", app.ReplayHandler)
+	r.POST("/replay/load", app.ReplayLoadHandler)
+
+	// Shared sessions: the owner's share link and the viewers' watch page
+	r.POST("/share/start", app.ShareStartHandler)
+	r.POST("/share/stop", app.ShareStopHandler)
+	r.GET("/share/status", app.ShareStatusHandler)
+	r.POST("/share/:action", app.ShareControlHandler)
+	r.GET("/watch/:token", app.WatchHandler)
+	r.GET("/watch/:token/ws", app.WatchWSHandler)
+	r.GET("/watch/:token/status", app.WatchStatusHandler)
+	r.GET("/watch/:token/content", app.WatchContentHandler)
+	r.POST("/watch/:token/submit", app.WatchSubmitHandler)
+	r.POST("/watch/:token/submit/async", app.WatchSubmitAsyncHandler)
+	r.POST("/watch/:token/control/:action", app.WatchControlHandler)
 	r.POST("/submit", app.SubmitHandler)
 	r.POST("/submit/async", app.SubmitAsyncHandler)
 	r.POST("/prefs", app.PrefsHandler)
@@ -186,36 +259,78 @@ func main() {
 	r.POST("/workflow/stop", app.StopWorkflowHandler)
 	r.POST("/workflow/remove", app.RemoveWorkflowHandler)
 	r.GET("/workflow/status", app.WorkflowStatusHandler)
-	r.GET("/api/settings", app.SettingsHandler)
-	r.POST("/api/settings", app.SettingsHandler)
+	r.GET("/workflow/report", app.WorkflowReportHandler)
+	r.GET("/api/settings", adminOnly, app.SettingsHandler)
+	r.POST("/api/settings", adminOnly, app.SettingsHandler)
 	r.GET("/api/themes", app.ThemeListHandler)
-	r.POST("/api/themes/save", app.ThemeSaveHandler)
-	r.POST("/app/restart", app.RestartHandler)
+	r.GET("/profiles", app.ProfilesListHandler)
+	r.POST("/profiles", adminOnly, app.ProfileSaveHandler)
+	r.POST("/profiles/delete", adminOnly, app.ProfileDeleteHandler)
+	r.POST("/api/themes/save", adminOnly, app.ThemeSaveHandler)
+	r.POST("/app/restart", adminOnly, app.RestartHandler)
 
 	// Logging handlers
-	r.GET("/logs", app.LogsHandler)
-	r.GET("/logs/access", app.LogsAccessHandler)
-	r.POST("/logs/access", app.LogsAccessHandler)
-	r.POST("/logs/toggle", app.LogsToggleHandler)
-	r.POST("/logs/clear", app.LogsClearHandler)
-	r.GET("/logs/download", app.LogsDownloadHandler)
+	r.GET("/logs", adminOnly, app.LogsHandler)
+	r.GET("/logs/access", adminOnly, app.LogsAccessHandler)
+	r.POST("/logs/access", adminOnly, app.LogsAccessHandler)
+	r.POST("/logs/toggle", adminOnly, app.LogsToggleHandler)
+	r.POST("/logs/clear", adminOnly, app.LogsClearHandler)
+	r.GET("/logs/download", adminOnly, app.LogsDownloadHandler)
+	r.GET("/audit", adminOnly, app.AuditSearchHandler)
+	r.GET("/audit/export", adminOnly, app.AuditExportHandler)
 
 	// Disconnect handler
 	r.POST("/disconnect", app.DisconnectHandler)
 
+	// Session tabs: several sessions per browser
+	r.GET("/sessions", app.SessionsHandler)
+	r.POST("/sessions/switch", app.SessionSwitchHandler)
+	r.POST("/sessions/rename", app.SessionRenameHandler)
+	r.POST("/sessions/close", app.SessionCloseHandler)
+
+	// Idle expiry
+	r.GET("/session/status", app.SessionStatusHandler)
+	r.POST("/session/keepalive", app.SessionKeepAliveHandler)
+
+	// Automation API (bearer token auth)
+	registerAPIRoutes(r, app)
+
+	// Load testing
+	r.POST("/loadtest/start", testerOnly, app.LoadTestStartHandler)
+	r.POST("/loadtest/stop", testerOnly, app.LoadTestStopHandler)
+	r.GET("/loadtest/status", testerOnly, app.LoadTestStatusHandler)
+	r.GET("/loadtest/report", testerOnly, app.LoadTestReportHandler)
+
+	// IND$FILE file transfer
+	r.POST("/transfer/upload", app.TransferUploadHandler)
+	r.POST("/transfer/download", app.TransferDownloadHandler)
+	r.GET("/transfer/status", app.TransferStatusHandler)
+	r.GET("/transfer/file", app.TransferFileHandler)
+
+	// Printer session (pr3287-style) capture
+	r.POST("/printer/start", app.PrinterStartHandler)
+	r.POST("/printer/stop", app.PrinterStopHandler)
+	r.POST("/printer/clear", app.PrinterClearHandler)
+	r.GET("/printer/status", app.PrinterStatusHandler)
+	r.GET("/printer/jobs/:id", app.PrinterJobHandler)
+
 	// Chaos exploration handlers
-	r.POST("/chaos/start", app.ChaosStartHandler)
-	r.POST("/chaos/stop", app.ChaosStopHandler)
-	r.POST("/chaos/remove", app.ChaosRemoveHandler)
-	r.GET("/chaos/status", app.ChaosStatusHandler)
-	r.POST("/chaos/export", app.ChaosExportHandler)
-	r.GET("/chaos/runs", app.ChaosListRunsHandler)
-	r.POST("/chaos/load", app.ChaosLoadHandler)
-	r.POST("/chaos/load-recording", app.ChaosLoadRecordingHandler)
-	r.POST("/chaos/resume", app.ChaosResumeHandler)
-	r.GET("/chaos/hints", app.ChaosHintsGetHandler)
-	r.POST("/chaos/hints", app.ChaosHintsSaveHandler)
-	r.POST("/chaos/hints/extract-recording", app.ChaosHintsExtractHandler)
+	r.POST("/chaos/start", testerOnly, app.ChaosStartHandler)
+	r.POST("/chaos/stop", testerOnly, app.ChaosStopHandler)
+	r.POST("/chaos/remove", testerOnly, app.ChaosRemoveHandler)
+	r.GET("/chaos/status", testerOnly, app.ChaosStatusHandler)
+	r.POST("/chaos/export", testerOnly, app.ChaosExportHandler)
+	r.GET("/chaos/runs", testerOnly, app.ChaosListRunsHandler)
+	r.POST("/chaos/load", testerOnly, app.ChaosLoadHandler)
+	r.POST("/chaos/load-recording", testerOnly, app.ChaosLoadRecordingHandler)
+	r.POST("/chaos/resume", testerOnly, app.ChaosResumeHandler)
+	r.GET("/chaos/hints", testerOnly, app.ChaosHintsGetHandler)
+	r.POST("/chaos/hints", testerOnly, app.ChaosHintsSaveHandler)
+	r.POST("/chaos/hints/extract-recording", testerOnly, app.ChaosHintsExtractHandler)
+	r.GET("/chaos/findings", testerOnly, app.ChaosFindingsHandler)
+	r.GET("/chaos/findings/:id/workflow", testerOnly, app.ChaosFindingWorkflowHandler)
+	r.GET("/chaos/finding-rules", testerOnly, app.ChaosFindingRulesGetHandler)
+	r.POST("/chaos/finding-rules", testerOnly, app.ChaosFindingRulesSaveHandler)
 
 	shutdownCh := make(chan struct{})
 	requestShutdown := func() {
@@ -227,6 +342,7 @@ func main() {
 		}
 	}
 	app.shutdown = requestShutdown
+	go app.runSessionReaper(shutdownCh)
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
@@ -411,6 +527,7 @@ type sessionSnapshot struct {
 	PlaybackDelayRange    string
 	PlaybackDelayApplied  string
 	PlaybackEvents        []session.WorkflowEvent
+	PlaybackReport        bool
 	LoadedWorkflow        bool
 	LoadedWorkflowName    string
 	LoadedWorkflowPreview string
@@ -457,6 +574,7 @@ func (app *App) snapshotSession(s *session.Session) sessionSnapshot {
 	if len(s.PlaybackEvents) > 0 {
 		snap.PlaybackEvents = append([]session.WorkflowEvent(nil), s.PlaybackEvents...)
 	}
+	snap.PlaybackReport = s.PlaybackResult != nil && !snap.PlaybackActive
 	if s.LoadedWorkflow != nil {
 		snap.LoadedWorkflow = true
 		snap.LoadedWorkflowName = s.LoadedWorkflow.Name
@@ -483,25 +601,35 @@ func (app *App) renderConnectPage(c *gin.Context, status int, hostname string, c
 	if defaultHost == "" {
 		defaultHost = "localhost:3270"
 	}
+	selectedProfile, _ := parseProfileHost(defaultHost)
+	profiles, err := app.profiles.list()
+	if err != nil {
+		log.Printf("Warning: could not load connection profiles: %v", err)
+	}
 	samplePorts := allowedSampleAppPorts()
 	c.HTML(status, "connect.html", gin.H{
-		"DefaultHost":  defaultHost,
-		"SampleApps":   availableSampleApps(),
-		"SamplePorts":  samplePorts,
-		"ConnectError": connectError,
-		"Version":      appVersion,
+		"DefaultHost":     defaultHost,
+		"DefaultEngine":   resolveHostEngine(""),
+		"Profiles":        profiles,
+		"SelectedProfile": selectedProfile,
+		"SampleApps":      availableSampleApps(),
+		"SamplePorts":     samplePorts,
+		"ConnectError":    connectError,
+		"OpenSessions":    len(app.browserSessions(c)),
+		"Auth":            app.authView(c),
+		"Version":         appVersion,
 	})
 }
 
 func (app *App) HomeHandler(c *gin.Context) {
-	// Check session
-	if s := app.getSession(c); s != nil {
+	// Check session; ?new=1 opens the connect page for another session
+	if s := app.getSession(c); s != nil && c.Query("new") == "" {
 		c.Redirect(http.StatusFound, "/screen")
 		return
 	}
 	targetHost := strings.TrimSpace(app.Config.TargetHost.Value)
 	if targetHost != "" && app.Config.TargetHost.AutoConnect {
-		if err := app.connectToHost(c, targetHost); err != nil {
+		if err := app.connectToHost(c, targetHost, "", ""); err != nil {
 			log.Printf("Auto-connect failed for %q: %v", targetHost, err)
 			app.renderConnectPage(c, http.StatusServiceUnavailable, targetHost, connectErrorMessage(targetHost, err))
 			return
@@ -516,6 +644,8 @@ func (app *App) ConnectHandler(c *gin.Context) {
 	hostname := c.PostForm("hostname")
 	if app.Config.TargetHost.Value != "" {
 		hostname = strings.TrimSpace(app.Config.TargetHost.Value)
+	} else if profile := strings.TrimSpace(c.PostForm("profile")); profile != "" {
+		hostname = profileHost(profile)
 	} else {
 		hostname = strings.TrimSpace(hostname)
 	}
@@ -524,7 +654,7 @@ func (app *App) ConnectHandler(c *gin.Context) {
 		return
 	}
 
-	if err := app.connectToHost(c, hostname); err != nil {
+	if err := app.connectToHost(c, hostname, c.PostForm("engine"), c.PostForm("sessionName")); err != nil {
 		log.Printf("Connect failed for %q: %v", hostname, err)
 		app.renderConnectPage(c, http.StatusServiceUnavailable, hostname, connectErrorMessage(hostname, err))
 		return
@@ -536,9 +666,15 @@ func connectErrorMessage(hostname string, err error) string {
 	if hostname == "" {
 		return "Please enter a hostname or IP address to connect."
 	}
+	if errors.Is(err, errTooManySessions) {
+		return "You already have the maximum number of sessions open. Close one of them first."
+	}
 	if _, _, ok := parseSampleAppHost(hostname); ok && err != nil {
 		return fmt.Sprintf("We couldn't start the sample app at %s. %v", hostname, err)
 	}
+	if name, ok := parseProfileHost(hostname); ok && err != nil {
+		return fmt.Sprintf("We couldn't connect with profile %s. %v", name, err)
+	}
 	return fmt.Sprintf("We couldn't connect to %s. Please verify the address and that the TN3270 service is available, then try again.", hostname)
 }
 
@@ -553,9 +689,10 @@ func (app *App) ScreenHandler(c *gin.Context) {
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"Error": fmt.Sprintf("Update screen failed: %v", err)})
 		return
 	}
+	app.observeScreen(s)
 
 	screen := s.Host.GetScreen()
-	if rows, cols, ok := app.modelDimensions(); ok {
+	if rows, cols, ok := app.modelDimensions(s); ok {
 		screen = limitScreenForDisplay(screen, rows, cols)
 	}
 	rendered := app.Renderer.Render(screen, "/submit", s.ID)
@@ -620,6 +757,7 @@ func (app *App) ScreenHandler(c *gin.Context) {
 		"PlaybackDelayRange":    snap.PlaybackDelayRange,
 		"PlaybackDelayApplied":  snap.PlaybackDelayApplied,
 		"PlaybackEvents":        snap.PlaybackEvents,
+		"PlaybackReport":        snap.PlaybackReport,
 		"LoadedWorkflow":        snap.LoadedWorkflow,
 		"LoadedWorkflowName":    snap.LoadedWorkflowName,
 		"LoadedWorkflowPreview": snap.LoadedWorkflowPreview,
@@ -630,38 +768,84 @@ func (app *App) ScreenHandler(c *gin.Context) {
 		"StatusCursor":          cursorLabel,
 		"SampleAppName":         sampleAppName,
 		"SampleAppPort":         sampleAppPort,
+		"Sessions":              app.sessionTabs(c, s),
+		"Auth":                  app.authView(c),
 		"Version":               appVersion,
 	})
 }
 
 func (app *App) ScreenContentHandler(c *gin.Context) {
-	s := app.getSession(c)
+	s := app.peekSession(c)
 	if s == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
 		return
 	}
-	if err := s.Host.UpdateScreen(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Update screen failed: %v", err)})
+	content, err := app.renderScreenContent(s)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	screen := s.Host.GetScreen()
-	if rows, cols, ok := app.modelDimensions(); ok {
-		screen = limitScreenForDisplay(screen, rows, cols)
-	}
-	rendered := app.Renderer.Render(screen, "/submit", s.ID)
-	cursorRow, cursorCol, cursorOK := screen.StatusCursor()
-	if cursorOK {
+	writeScreenContent(c, content)
+}
+
+// writeScreenContent writes a rendered screen as the JSON polled by the
+// terminal page.
+func writeScreenContent(c *gin.Context, content screenContent) {
+	if content.CursorOK {
 		c.JSON(http.StatusOK, gin.H{
-			"html":      rendered,
-			"cursorRow": cursorRow,
-			"cursorCol": cursorCol,
+			"html":      content.HTML,
+			"cursorRow": content.CursorRow,
+			"cursorCol": content.CursorCol,
 		})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"html": rendered})
+	c.JSON(http.StatusOK, gin.H{"html": content.HTML})
+}
+
+// screenContent is the rendered screen fragment served to the browser.
+type screenContent struct {
+	HTML      string
+	CursorRow int
+	CursorCol int
+	CursorOK  bool
+}
+
+// renderScreenContent refreshes the host screen and renders it for display.
+func (app *App) renderScreenContent(s *session.Session) (screenContent, error) {
+	return app.renderScreenContentTo(s, "/submit", false)
+}
+
+// renderScreenContentTo renders the screen with its form posting to action.
+// A shared rendering is for someone other than the session owner: it leaves
+// the session ID out of the form and blanks hidden fields.
+func (app *App) renderScreenContentTo(s *session.Session, action string, shared bool) (screenContent, error) {
+	if err := s.Host.UpdateScreen(); err != nil {
+		return screenContent{}, fmt.Errorf("Update screen failed: %v", err)
+	}
+	app.observeScreen(s)
+	return app.renderCurrentScreenTo(s, action, shared), nil
 }
 
-func (app *App) modelDimensions() (int, int, bool) {
+// renderCurrentScreenTo is renderScreenContentTo for the screen the host
+// last read, without asking the host for an update.
+func (app *App) renderCurrentScreenTo(s *session.Session, action string, shared bool) screenContent {
+	screen := s.Host.GetScreen()
+	if rows, cols, ok := app.modelDimensions(s); ok {
+		screen = limitScreenForDisplay(screen, rows, cols)
+	}
+	formID := s.ID
+	if shared {
+		screen = blankHiddenFields(screen)
+		formID = ""
+	}
+	content := screenContent{HTML: app.Renderer.Render(screen, action, formID)}
+	content.CursorRow, content.CursorCol, content.CursorOK = screen.StatusCursor()
+	return content
+}
+
+// modelDimensions returns the screen size of the model the session was
+// started with. Sessions whose profile sets an oversize have no fixed limit.
+func (app *App) modelDimensions(s *session.Session) (int, int, bool) {
 	model := ""
 	if app != nil && app.Config != nil {
 		model = strings.TrimSpace(app.Config.S3270Options.Model)
@@ -669,6 +853,14 @@ func (app *App) modelDimensions() (int, int, bool) {
 	if overrides, err := config.S3270EnvOverridesFromEnv(); err == nil && overrides.HasModel {
 		model = strings.TrimSpace(overrides.Model)
 	}
+	if profile := app.sessionProfile(s); profile != nil {
+		if profile.Oversize != "" {
+			return 0, 0, false
+		}
+		if profile.Model != "" {
+			model = profile.Model
+		}
+	}
 	if model == "" {
 		return 0, 0, false
 	}
@@ -722,14 +914,72 @@ func limitScreenForDisplay(screen *host.Screen, maxRows, maxCols int) *host.Scre
 	return &limited
 }
 
+// blankHiddenFields returns a copy of screen with the contents of hidden
+// fields replaced by spaces, or screen itself when it has none.
+func blankHiddenFields(screen *host.Screen) *host.Screen {
+	if screen == nil {
+		return screen
+	}
+	hidden := false
+	for _, f := range screen.Fields {
+		if f != nil && f.IsHidden() {
+			hidden = true
+			break
+		}
+	}
+	if !hidden {
+		return screen
+	}
+
+	masked := *screen
+	masked.Buffer = make([][]rune, len(screen.Buffer))
+	for y, row := range screen.Buffer {
+		masked.Buffer[y] = append([]rune(nil), row...)
+	}
+	masked.Fields = make([]*host.Field, 0, len(screen.Fields))
+	for _, f := range screen.Fields {
+		if f == nil {
+			continue
+		}
+		nf := *f
+		nf.Screen = &masked
+		nf.Value = ""
+		nf.Changed = false
+		if nf.IsHidden() {
+			for y := nf.StartY; y <= nf.EndY && y < len(masked.Buffer); y++ {
+				if y < 0 {
+					continue
+				}
+				row := masked.Buffer[y]
+				from, to := 0, len(row)-1
+				if y == nf.StartY {
+					from = nf.StartX
+				}
+				if y == nf.EndY && nf.EndX < to {
+					to = nf.EndX
+				}
+				for x := max(from, 0); x <= to; x++ {
+					row[x] = ' '
+				}
+			}
+		}
+		masked.Fields = append(masked.Fields, &nf)
+	}
+	return &masked
+}
+
 func (app *App) SubmitHandler(c *gin.Context) {
 	s := app.getSession(c)
 	if s == nil {
 		c.Redirect(http.StatusFound, "/")
 		return
 	}
-	if err := app.processSubmit(c, s); err != nil {
-		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"Error": err.Error()})
+	if err := app.processSubmit(s, c.PostForm); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errNoKeyboardControl) || errors.Is(err, errSessionSwitched) {
+			status = http.StatusConflict
+		}
+		c.HTML(status, "error.html", gin.H{"Error": err.Error()})
 		return
 	}
 
@@ -742,29 +992,54 @@ func (app *App) SubmitAsyncHandler(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
 		return
 	}
-	if err := app.processSubmit(c, s); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := app.processSubmit(s, c.PostForm); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errNoKeyboardControl) || errors.Is(err, errSessionSwitched) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
-func (app *App) processSubmit(c *gin.Context, s *session.Session) error {
-	key := c.PostForm("key")
-	cursorRow := strings.TrimSpace(c.PostForm("cursor_row"))
-	cursorCol := strings.TrimSpace(c.PostForm("cursor_col"))
+// processSubmit applies a submitted screen form to the host. formValue looks
+// up submitted values by form field name so both HTTP forms and WebSocket
+// messages share the same path.
+func (app *App) processSubmit(s *session.Session, formValue func(string) string) error {
+	return app.processSubmitBy(s, nil, formValue)
+}
+
+// processSubmitBy applies a screen form from the viewer v of a shared
+// session, or from the owner when v is nil. Only the holder of the keyboard
+// may submit.
+func (app *App) processSubmitBy(s *session.Session, v *shareViewer, formValue func(string) string) error {
+	if err := app.checkKeyboardControl(s, v); err != nil {
+		return err
+	}
+	if id := formValue("TERMINAL"); id != "" && id != s.ID {
+		return errSessionSwitched
+	}
+	key := formValue("key")
+	cursorRow := strings.TrimSpace(formValue("cursor_row"))
+	cursorCol := strings.TrimSpace(formValue("cursor_col"))
 
+	var fields []audit.Field
 	if s.Host.GetScreen().IsFormatted {
 		// 1. Update fields from form data
-		app.updateFields(c, s)
-		recordFieldUpdates(s)
+		app.updateFields(s, formValue)
+		app.storeRecordedSecrets(app.recordFieldUpdates(s))
+		fields = changedAuditFields(s.Host.GetScreen())
 
 		// 2. Submit changes to host
 		if err := s.Host.SubmitScreen(); err != nil {
 			return fmt.Errorf("submit failed: %w", err)
 		}
 	} else {
-		data := c.PostForm("field")
+		data := formValue("field")
+		if data != "" {
+			fields = []audit.Field{{Value: data}}
+		}
 		if err := s.Host.SubmitUnformatted(data); err != nil {
 			return fmt.Errorf("submit failed: %w", err)
 		}
@@ -787,23 +1062,19 @@ func (app *App) processSubmit(c *gin.Context, s *session.Session) error {
 	}
 	log.Printf("Submit: normalized key=%q", actionKey)
 	recordActionKey(s, actionKey)
+	app.noteScreenAID(s, actionKey)
 
-	if err := s.Host.SendKey(actionKey); err != nil {
+	err := s.Host.SendKey(actionKey)
+	app.auditEventBy(s, v, audit.SourceTerminal, actionKey, fields, err)
+	if err != nil {
 		return fmt.Errorf("send key failed: %w", err)
 	}
 	return nil
 }
 
+// DisconnectHandler closes the active session and moves to the next tab.
 func (app *App) DisconnectHandler(c *gin.Context) {
-	if s := app.getSession(c); s != nil {
-		cleanupRecordingFile(s)
-		app.chaosEngines.delete(s.ID)
-		app.chaosEngines.deleteLoadedRun(s.ID)
-		app.chaosEngines.clearRemoved(s.ID)
-		app.SessionManager.RemoveSession(s.ID)
-	}
-	setSessionCookie(c, "3270Web_session", "")
-	c.Redirect(http.StatusFound, "/")
+	app.closeBrowserSession(c, app.getSession(c))
 }
 
 func (app *App) RecordStartHandler(c *gin.Context) {
@@ -832,9 +1103,11 @@ func (app *App) RecordStartHandler(c *gin.Context) {
 			Active:         true,
 			Host:           host,
 			Port:           port,
+			Profile:        s.Profile,
 			OutputFilePath: "output.html",
 			Steps:          []session.WorkflowStep{{Type: "Connect"}},
 			StartedAt:      time.Now(),
+			CaptureScreens: recordScreensEnabled(),
 		}
 	})
 	if blocked {
@@ -943,6 +1216,10 @@ func (app *App) PlayWorkflowHandler(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/")
 		return
 	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.HTML(http.StatusConflict, "error.html", gin.H{"Error": err.Error()})
+		return
+	}
 	playing := false
 	withSessionLock(s, func() {
 		playing = s.Playback != nil && s.Playback.Active
@@ -970,6 +1247,7 @@ func (app *App) PlayWorkflowHandler(c *gin.Context) {
 		s.PlaybackCompletedAt = time.Time{}
 		s.Playback = &session.WorkflowPlayback{StartedAt: time.Now(), Mode: "play", TotalSteps: len(workflow.Steps)}
 		s.PlaybackEvents = nil
+		s.PlaybackResult = nil
 	})
 	addPlaybackEvent(s, "Playback started (Play mode)")
 	go app.playWorkflow(s, workflow)
@@ -982,6 +1260,10 @@ func (app *App) DebugWorkflowHandler(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/")
 		return
 	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.HTML(http.StatusConflict, "error.html", gin.H{"Error": err.Error()})
+		return
+	}
 	playing := false
 	withSessionLock(s, func() {
 		playing = s.Playback != nil && s.Playback.Active
@@ -1009,6 +1291,7 @@ func (app *App) DebugWorkflowHandler(c *gin.Context) {
 		s.PlaybackCompletedAt = time.Time{}
 		s.Playback = &session.WorkflowPlayback{StartedAt: time.Now(), Mode: "debug", TotalSteps: len(workflow.Steps), Paused: true}
 		s.PlaybackEvents = nil
+		s.PlaybackResult = nil
 	})
 	addPlaybackEvent(s, "Playback started (Debug mode)")
 	go app.playWorkflow(s, workflow)
@@ -1033,6 +1316,7 @@ func clearWorkflowStatus(s *session.Session) {
 	withSessionLock(s, func() {
 		resetPlaybackSummary(s)
 		s.PlaybackEvents = nil
+		s.PlaybackResult = nil
 		s.PlaybackCompletedAt = time.Time{}
 	})
 }
@@ -1043,6 +1327,10 @@ func (app *App) StepWorkflowHandler(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/")
 		return
 	}
+	if err := app.checkKeyboardControl(s, nil); err != nil {
+		c.HTML(http.StatusConflict, "error.html", gin.H{"Error": err.Error()})
+		return
+	}
 	canStep := false
 	withSessionLock(s, func() {
 		if s.Playback != nil && s.Playback.Active && s.Playback.Mode == "debug" {
@@ -1113,7 +1401,7 @@ func (app *App) RemoveWorkflowHandler(c *gin.Context) {
 }
 
 func (app *App) WorkflowStatusHandler(c *gin.Context) {
-	s := app.getSession(c)
+	s := app.peekSession(c)
 	if s == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
 		return
@@ -1183,6 +1471,7 @@ func (app *App) WorkflowStatusHandler(c *gin.Context) {
 		"playbackDelayRange":   playbackDelayRangeLabel(s),
 		"playbackDelayApplied": playbackDelayAppliedLabel(s),
 		"playbackEvents":       events,
+		"playbackReport":       playbackReportAvailable(s),
 		"chaosActive":          chaosState != nil && chaosState.Active,
 		"chaosStepsRun":        chaosStateStepsRun(chaosState),
 		"chaosTransitions":     chaosStateTransitions(chaosState),
@@ -1338,6 +1627,18 @@ func (app *App) settingsSnapshot(includeSensitive bool) (map[string]string, []st
 	}
 	defaults["ALLOW_LOG_ACCESS"] = "false"
 	defaults["APP_USE_KEYPAD"] = "false"
+	defaults[recordScreensEnv] = "false"
+	defaults["APP_HOST_ENGINE"] = hostEngineS3270
+	defaults[apiTokenEnv] = ""
+	defaults[secretsKeyEnv] = ""
+	defaults[sessionIdleTimeoutEnv] = "30"
+	defaults[screenHistorySizeEnv] = "50"
+	defaults[oidcIssuerEnv] = ""
+	defaults[oidcClientIDEnv] = ""
+	defaults[oidcClientSecretEnv] = ""
+	defaults[oidcRedirectURLEnv] = ""
+	defaults[oidcRoleClaimEnv] = "role"
+	defaults[oidcDefaultRoleEnv] = ""
 	defaults["CHAOS_MAX_STEPS"] = "100"
 	defaults["CHAOS_TIME_BUDGET_SEC"] = "300"
 	defaults["CHAOS_STEP_DELAY_SEC"] = "0.5"
@@ -1345,6 +1646,9 @@ func (app *App) settingsSnapshot(includeSensitive bool) (map[string]string, []st
 	defaults["CHAOS_MAX_FIELD_LENGTH"] = "40"
 	defaults["CHAOS_OUTPUT_FILE"] = ""
 	defaults["CHAOS_EXCLUDE_NO_PROGRESS_EVENTS"] = "true"
+	defaults["CHAOS_STRATEGY"] = "random"
+	defaults["CHAOS_MASK_RULES"] = ""
+	defaults["CHAOS_AUTO_MASK"] = "true"
 
 	settings := make(map[string]string)
 	for key, value := range defaults {
@@ -1366,7 +1670,7 @@ func (app *App) settingsSnapshot(includeSensitive bool) (map[string]string, []st
 
 	masked := []string{}
 	if !includeSensitive {
-		for _, key := range []string{"S3270_KEY_PASSWORD"} {
+		for _, key := range []string{"S3270_KEY_PASSWORD", apiTokenEnv, secretsKeyEnv, oidcClientSecretEnv} {
 			if value, ok := settings[key]; ok && value != "" {
 				settings[key] = "********"
 				masked = append(masked, key)
@@ -1672,12 +1976,19 @@ func (app *App) applyRuntimeSetting(key, value string) {
 		} else {
 			_ = os.Unsetenv(key)
 		}
-	case "APP_USE_KEYPAD":
+	case "APP_USE_KEYPAD", "APP_HOST_ENGINE", recordScreensEnv, oidcDefaultRoleEnv:
 		if value == "" {
 			_ = os.Unsetenv(key)
 		} else {
 			_ = os.Setenv(key, strings.ToLower(value))
 		}
+	case apiTokenEnv, secretsKeyEnv, sessionIdleTimeoutEnv, screenHistorySizeEnv, oidcIssuerEnv, oidcClientIDEnv,
+		oidcClientSecretEnv, oidcRedirectURLEnv, oidcRoleClaimEnv:
+		if value == "" {
+			_ = os.Unsetenv(key)
+		} else {
+			_ = os.Setenv(key, value)
+		}
 	}
 }
 
@@ -1686,13 +1997,27 @@ func validateSettingValue(key, value string, specs map[string]config.S3270Option
 		return nil
 	}
 
-	if key == "ALLOW_LOG_ACCESS" || key == "APP_USE_KEYPAD" || key == "CHAOS_EXCLUDE_NO_PROGRESS_EVENTS" {
+	if key == "ALLOW_LOG_ACCESS" || key == "APP_USE_KEYPAD" || key == recordScreensEnv || key == "CHAOS_EXCLUDE_NO_PROGRESS_EVENTS" ||
+		key == "CHAOS_AUTO_MASK" {
 		if !isStrictBool(value) {
 			return fmt.Errorf("must be true or false")
 		}
 		return nil
 	}
 
+	if key == "CHAOS_MASK_RULES" {
+		_, err := chaos.ParseMaskRules(value)
+		return err
+	}
+
+	if key == oidcIssuerEnv || key == oidcRedirectURLEnv {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("must be an http or https URL")
+		}
+		return nil
+	}
+
 	if allowed := s3270EnumValues[key]; len(allowed) > 0 {
 		if _, ok := allowed[strings.ToLower(value)]; !ok {
 			return fmt.Errorf("must be one of %s", strings.Join(sortedKeys(allowed), ", "))
@@ -1726,6 +2051,16 @@ func isStrictBool(value string) bool {
 }
 
 var s3270EnumValues = map[string]map[string]struct{}{
+	"APP_HOST_ENGINE": hostEngineValues,
+	"CHAOS_STRATEGY": {
+		"random":   {},
+		"frontier": {},
+	},
+	oidcDefaultRoleEnv: {
+		"user":   {},
+		"tester": {},
+		"admin":  {},
+	},
 	"S3270_CERT_FILE_TYPE": {
 		"pem":  {},
 		"asn1": {},
@@ -1756,6 +2091,20 @@ var s3270NumericValidators = map[string]func(string) error{
 		}
 		return nil
 	},
+	sessionIdleTimeoutEnv: func(value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("must be a non-negative number of minutes")
+		}
+		return nil
+	},
+	screenHistorySizeEnv: func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > maxScreenHistorySize {
+			return fmt.Errorf("must be a number of screens from 0 to %d", maxScreenHistorySize)
+		}
+		return nil
+	},
 	"S3270_CONNECT_TIMEOUT": func(value string) error {
 		parsed, err := strconv.Atoi(value)
 		if err != nil || parsed < 0 {
@@ -1960,9 +2309,9 @@ func (app *App) LogsDownloadHandler(c *gin.Context) {
 	c.Data(http.StatusOK, "text/plain", content)
 }
 
-func (app *App) updateFields(c *gin.Context, s *session.Session) {
+func (app *App) updateFields(s *session.Session, formValue func(string) string) {
 	screen := s.Host.GetScreen()
-	maxRows, maxCols, hasLimit := app.modelDimensions()
+	maxRows, maxCols, hasLimit := app.modelDimensions(s)
 	for _, f := range screen.Fields {
 		if !f.IsProtected() {
 			if hasLimit && !fieldWithinBounds(f, maxRows, maxCols) {
@@ -1974,7 +2323,7 @@ func (app *App) updateFields(c *gin.Context, s *session.Session) {
 			if f.IsMultiline() {
 				var parts []string
 				for i := 0; i < f.Height(); i++ {
-					val := c.PostForm(fmt.Sprintf("%s_%d", fieldName, i))
+					val := formValue(fmt.Sprintf("%s_%d", fieldName, i))
 					// Java trimmed spaces? We should check if Gin returns empty string for missing fields.
 					// If field is missing, it might mean user didn't change it or browser didn't send it?
 					// Input type text sends empty string if empty.
@@ -1985,7 +2334,7 @@ func (app *App) updateFields(c *gin.Context, s *session.Session) {
 					f.SetValue(newValue)
 				}
 			} else {
-				val := normalizeInputValue(c.PostForm(fieldName))
+				val := normalizeInputValue(formValue(fieldName))
 				if val != original {
 					f.SetValue(val)
 				}
@@ -2010,14 +2359,19 @@ func fieldWithinBounds(f *host.Field, maxRows, maxCols int) bool {
 	return true
 }
 
-func recordFieldUpdates(s *session.Session) {
+// recordFieldUpdates records a FillString step for each changed input field.
+// Hidden fields are recorded as ${secret:NAME} placeholders, named uniquely
+// within the recording and apart from the secrets already stored; their
+// values are returned by name so the caller can store them.
+func (app *App) recordFieldUpdates(s *session.Session) map[string]string {
 	if s == nil {
-		return
+		return nil
 	}
 	screen := s.Host.GetScreen()
 	if screen == nil {
-		return
+		return nil
 	}
+	var captured map[string]string
 	withSessionLock(s, func() {
 		if s.Recording == nil || !s.Recording.Active {
 			return
@@ -2027,6 +2381,29 @@ func recordFieldUpdates(s *session.Session) {
 				continue
 			}
 			lines := f.GetValueLines()
+			if f.IsHidden() {
+				value := normalizeInputValue(strings.Join(lines, ""))
+				text := ""
+				if value != "" {
+					name := app.secrets.claimName(secrets.FieldName(screen, f), func(name string) bool {
+						return recordingUsesSecret(s.Recording, name)
+					})
+					text = secrets.Placeholder(name)
+					if captured == nil {
+						captured = make(map[string]string)
+					}
+					captured[name] = value
+				}
+				s.Recording.Steps = append(s.Recording.Steps, session.WorkflowStep{
+					Type: "FillString",
+					Coordinates: &session.WorkflowCoordinates{
+						Row:    f.StartY + 1,
+						Column: f.StartX + 1,
+					},
+					Text: text,
+				})
+				continue
+			}
 			if !f.IsMultiline() {
 				text := ""
 				if len(lines) > 0 {
@@ -2054,6 +2431,20 @@ func recordFieldUpdates(s *session.Session) {
 			}
 		}
 	})
+	return captured
+}
+
+// recordingUsesSecret reports whether a step of rec already refers to the
+// named secret.
+func recordingUsesSecret(rec *session.WorkflowRecording, name string) bool {
+	for _, step := range rec.Steps {
+		for _, used := range secrets.Names(step.Text) {
+			if used == name {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func normalizeInputValue(value string) string {
@@ -2078,11 +2469,18 @@ func recordActionKey(s *session.Session, key string) {
 		step = *mapped
 	}
 	now := time.Now()
+	var screen *host.Screen
+	if s.Host != nil {
+		screen = s.Host.GetScreen()
+	}
 	withSessionLock(s, func() {
 		if s.Recording == nil || !s.Recording.Active {
 			return
 		}
 		updateRecordingDelayStats(s.Recording, now)
+		if s.Recording.CaptureScreens {
+			step.Expect = expectedWorkflowScreen(screen)
+		}
 		s.Recording.Steps = append(s.Recording.Steps, step)
 	})
 }
@@ -2131,6 +2529,7 @@ func buildWorkflowConfig(s *session.Session) *WorkflowConfig {
 	return &WorkflowConfig{
 		Host:            host,
 		Port:            port,
+		Profile:         s.Recording.Profile,
 		EveryStepDelay:  everyStepDelay,
 		OutputFilePath:  s.Recording.OutputFilePath,
 		RampUpBatchSize: 50,
@@ -2263,6 +2662,20 @@ func parseWorkflowPayload(payload []byte) (*WorkflowConfig, error) {
 	if len(workflow.Steps) == 0 {
 		return nil, errors.New("workflow contains no steps")
 	}
+	for i, step := range workflow.Steps {
+		if !isWorkflowCheckStep(step.Type) {
+			continue
+		}
+		if err := validateWorkflowCheckStep(step); err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i+1, step.Type, err)
+		}
+	}
+	if err := validateWorkflowDataset(&workflow); err != nil {
+		return nil, err
+	}
+	if err := validateScreenCheck(workflow.ScreenCheck); err != nil {
+		return nil, err
+	}
 	return &workflow, nil
 }
 
@@ -2410,12 +2823,27 @@ func playbackStepLabel(s *session.Session) string {
 	if total := playbackStepTotal(s); total > 0 {
 		label = fmt.Sprintf("%s/%d", label, total)
 	}
+	if row, rows := playbackRow(s); rows > 0 {
+		label = fmt.Sprintf("Row %d/%d, %s", row, rows, label)
+	}
 	if t := playbackStepType(s); t != "" {
 		label = fmt.Sprintf("%s: %s", label, t)
 	}
 	return label
 }
 
+func playbackRow(s *session.Session) (int, int) {
+	if s == nil {
+		return 0, 0
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.Playback == nil {
+		return 0, 0
+	}
+	return s.Playback.CurrentRow, s.Playback.TotalRows
+}
+
 func playbackDelayRangeLabel(s *session.Session) string {
 	if s == nil {
 		return ""
@@ -2487,6 +2915,15 @@ func playbackCompleted(s *session.Session) bool {
 	return !s.PlaybackCompletedAt.IsZero()
 }
 
+func playbackReportAvailable(s *session.Session) bool {
+	if s == nil {
+		return false
+	}
+	s.Lock()
+	defer s.Unlock()
+	return s.PlaybackResult != nil && (s.Playback == nil || !s.Playback.Active)
+}
+
 func playbackStartedAt(s *session.Session) string {
 	if s == nil {
 		return ""
@@ -2511,7 +2948,12 @@ func playbackEvents(s *session.Session) []session.WorkflowEvent {
 	return append([]session.WorkflowEvent(nil), s.PlaybackEvents...)
 }
 
+// workflowTargetHost returns the workflow's profile or host, falling back to
+// where the session is connected.
 func workflowTargetHost(s *session.Session, workflow *WorkflowConfig) (string, error) {
+	if workflow != nil && workflow.Profile != "" {
+		return profileHost(workflow.Profile), nil
+	}
 	if workflow != nil && workflow.Host != "" {
 		host := workflow.Host
 		if workflow.Port > 0 {
@@ -2520,12 +2962,16 @@ func workflowTargetHost(s *session.Session, workflow *WorkflowConfig) (string, e
 		return host, nil
 	}
 	if s != nil {
-		var host string
+		var host, profile string
 		var port int
 		withSessionLock(s, func() {
 			host = s.TargetHost
 			port = s.TargetPort
+			profile = s.Profile
 		})
+		if profile != "" {
+			return profileHost(profile), nil
+		}
 		if host != "" {
 			if port > 0 {
 				host = fmt.Sprintf("%s:%d", host, port)
@@ -2547,26 +2993,18 @@ func (app *App) resetSessionHost(s *session.Session, hostname string) error {
 	if existing != nil {
 		_ = existing.Stop()
 	}
-	hostName, port := parseHostPort(hostname)
-	if hostName == "" {
-		return errors.New("invalid host")
+	address, _, err := app.resolveTarget(hostname)
+	if err != nil {
+		return err
 	}
-	var h host.Host
-	var err error
-	if sampleID, samplePort, ok := parseSampleAppHost(hostname); ok {
-		if samplePort > 0 && !isAllowedSampleAppPort(samplePort) {
-			return fmt.Errorf("invalid sample app port %d", samplePort)
-		}
-		execPath := resolveS3270Path(app.Config.ExecPath)
-		h, err = newSampleAppHost(sampleID, samplePort, execPath, app.Config.S3270Options)
-	} else if hostname == "mock" || hostname == "demo" {
-		execPath := resolveS3270Path(app.Config.ExecPath)
-		h, err = newSampleAppHost("app1", defaultSampleAppPort, execPath, app.Config.S3270Options)
-	} else {
-		execPath := resolveS3270Path(app.Config.ExecPath)
-		args := buildS3270Args(app.Config.S3270Options, hostname)
-		h = host.NewS3270(execPath, args...)
+	if hostName, _ := parseHostPort(address); hostName == "" {
+		return errors.New("invalid host")
 	}
+	var engine string
+	withSessionLock(s, func() {
+		engine = s.HostEngine
+	})
+	h, err := app.newHost(hostname, engine)
 	if err != nil {
 		return fmt.Errorf("failed to create host: %w", err)
 	}
@@ -2575,8 +3013,8 @@ func (app *App) resetSessionHost(s *session.Session, hostname string) error {
 	}
 	withSessionLock(s, func() {
 		s.Host = h
-		s.TargetHost = hostName
-		s.TargetPort = port
+		s.HostEngine = resolveHostEngine(engine)
+		app.setSessionTarget(s, hostname)
 		// Apply verbose logging preference
 		if logger, ok := h.(interface{ SetVerboseLogging(bool) }); ok {
 			logger.SetVerboseLogging(s.Prefs.VerboseLogging)
@@ -2585,53 +3023,105 @@ func (app *App) resetSessionHost(s *session.Session, hostname string) error {
 	return nil
 }
 
+// getSession returns the browser's active session and counts the request
+// as activity. Like peekSession, it never returns another signed-in user's
+// session or an API session.
 func (app *App) getSession(c *gin.Context) *session.Session {
-	id, err := c.Cookie("3270Web_session")
-	if err != nil {
-		return nil
-	}
-	s, ok := app.SessionManager.GetSession(id)
-	if !ok {
-		return nil
+	s := app.peekSession(c)
+	if s != nil {
+		s.Touch()
 	}
 	return s
 }
 
-func (app *App) connectToHost(c *gin.Context, hostname string) error {
-	if !isValidHostname(hostname) {
-		return fmt.Errorf("invalid hostname format: %q", hostname)
+// connectToHost opens a new session for the browser and makes it the
+// active tab. An empty name defaults to the host.
+func (app *App) connectToHost(c *gin.Context, hostname, engine, name string) error {
+	sessions := app.browserSessions(c)
+	if len(sessions) >= maxBrowserSessions {
+		return errTooManySessions
 	}
-
-	var h host.Host
-	var err error
-
-	if sampleID, samplePort, ok := parseSampleAppHost(hostname); ok {
-		if samplePort > 0 && !isAllowedSampleAppPort(samplePort) {
-			return fmt.Errorf("invalid sample app port %d", samplePort)
+	sess, err := app.openSession(hostname, engine)
+	if err != nil {
+		return err
+	}
+	name = cleanSessionName(name)
+	if name == "" {
+		name = sessionTargetLabel(sess)
+	}
+	name = uniqueSessionName(sessions, name)
+	withSessionLock(sess, func() {
+		if u := app.currentUser(c); u != nil {
+			sess.User = u.Name
 		}
-		execPath := resolveS3270Path(app.Config.ExecPath)
-		h, err = newSampleAppHost(sampleID, samplePort, execPath, app.Config.S3270Options)
-	} else if hostname == "mock" || hostname == "demo" {
-		execPath := resolveS3270Path(app.Config.ExecPath)
-		h, err = newSampleAppHost("app1", defaultSampleAppPort, execPath, app.Config.S3270Options)
-	} else {
-		execPath := resolveS3270Path(app.Config.ExecPath)
-		args := buildS3270Args(app.Config.S3270Options, hostname)
-		h = host.NewS3270(execPath, args...)
+		sess.ClientAddr = c.ClientIP()
+		sess.Name = name
+	})
+	setBrowserSessions(c, append(sessions, sess))
+	setSessionCookie(c, "3270Web_session", sess.ID)
+	return nil
+}
+
+// openSession connects to hostname with the given engine and registers a new
+// session for it.
+func (app *App) openSession(hostname, engine string) (*session.Session, error) {
+	if !isValidHostname(hostname) {
+		return nil, fmt.Errorf("invalid hostname format: %q", hostname)
 	}
 
+	h, err := app.newHost(hostname, engine)
 	if err != nil {
-		return fmt.Errorf("failed to create host: %w", err)
+		return nil, fmt.Errorf("failed to create host: %w", err)
 	}
 	if err := h.Start(); err != nil {
-		return fmt.Errorf("failed to start host connection: %w", err)
+		return nil, fmt.Errorf("failed to start host connection: %w", err)
 	}
 
 	sess := app.SessionManager.CreateSession(h)
-	sess.TargetHost, sess.TargetPort = parseHostPort(hostname)
+	app.setSessionTarget(sess, hostname)
+	sess.HostEngine = resolveHostEngine(engine)
 	app.applyDefaultPrefs(sess)
-	setSessionCookie(c, "3270Web_session", sess.ID)
-	return nil
+	return sess, nil
+}
+
+// closeSession stops the session's host and releases everything attached to
+// it: running playback and chaos, recording and transfer temp files, the
+// printer session and the session itself.
+func (app *App) closeSession(s *session.Session) {
+	if eng, ok := app.chaosEngines.get(s.ID); ok && eng.Status().Active {
+		eng.Stop()
+	}
+	if playbackActive(s) {
+		stopWorkflowPlayback(s)
+	}
+	if app.loadTests != nil {
+		if run, ok := app.loadTests.get(s.ID); ok {
+			run.Stop()
+			app.loadTests.delete(s.ID)
+		}
+	}
+	if app.transfers != nil {
+		if tr, ok := app.transfers.get(s.ID); ok {
+			tr.discard()
+			app.transfers.delete(s.ID)
+		}
+	}
+	if app.printers != nil {
+		if ps, ok := app.printers.get(s.ID); ok {
+			ps.stop()
+			app.printers.delete(s.ID)
+		}
+	}
+	if app.screenHistory != nil {
+		app.screenHistory.delete(s.ID)
+	}
+	app.stopScreenRecording(s)
+	app.stopShare(s)
+	cleanupRecordingFile(s)
+	app.chaosEngines.delete(s.ID)
+	app.chaosEngines.deleteLoadedRun(s.ID)
+	app.chaosEngines.clearRemoved(s.ID)
+	app.SessionManager.RemoveSession(s.ID)
 }
 
 func fileExists(path string) bool {
@@ -2704,7 +3194,7 @@ func newSampleAppHost(id string, port int, execPath string, opts config.S3270Opt
 	}
 	port = sampleAppPort(port)
 	target := fmt.Sprintf("127.0.0.1:%d", port)
-	args := buildS3270Args(opts, "")
+	args := buildS3270Args(opts, "", nil)
 	return host.NewGoSampleAppHost(cfg.ID, port, execPath, args, target)
 }
 
@@ -219,6 +219,9 @@ func TestSettingsSnapshot_ChaosDefaultsWhenMissing(t *testing.T) {
 		"CHAOS_MAX_FIELD_LENGTH":           "40",
 		"CHAOS_OUTPUT_FILE":                "",
 		"CHAOS_EXCLUDE_NO_PROGRESS_EVENTS": "true",
+		"CHAOS_STRATEGY":                   "random",
+		"CHAOS_MASK_RULES":                 "",
+		"CHAOS_AUTO_MASK":                  "true",
 	}
 	for key, want := range cases {
 		got, ok := settings[key]
@@ -298,7 +301,7 @@ func TestBuildS3270Args_Precedence(t *testing.T) {
 	}
 	hostname := "localhost"
 
-	args := buildS3270Args(opts, hostname)
+	args := buildS3270Args(opts, hostname, nil)
 
 	// Verify Model override
 	hasModel := false
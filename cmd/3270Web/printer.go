@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/pdf"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// maxPrintJobs caps the jobs kept per session; the oldest are dropped first.
+const maxPrintJobs = 50
+
+// printerStore tracks the printer session attached to each browser session.
+type printerStore struct {
+	mu       sync.Mutex
+	printers map[string]*printerSession
+}
+
+func newPrinterStore() *printerStore {
+	return &printerStore{printers: make(map[string]*printerSession)}
+}
+
+func (s *printerStore) get(sessionID string) (*printerSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.printers[sessionID]
+	return ps, ok
+}
+
+func (s *printerStore) set(sessionID string, ps *printerSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.printers[sessionID] = ps
+}
+
+func (s *printerStore) delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.printers, sessionID)
+}
+
+// printerSession is a printer emulation paired with a terminal session and
+// the jobs it has captured. Jobs outlive the printer connection so they can
+// still be downloaded after it stops.
+type printerSession struct {
+	mu          sync.Mutex
+	printer     *host.Printer
+	associateLU string
+	jobs        []printJob
+	nextID      int
+}
+
+type printJob struct {
+	id  int
+	job host.PrintJob
+}
+
+// printerStatus is the JSON shape polled by the printer dialog.
+type printerStatus struct {
+	Connected   bool             `json:"connected"`
+	LU          string           `json:"lu,omitempty"`
+	AssociateLU string           `json:"associateLu,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	Jobs        []printJobStatus `json:"jobs"`
+}
+
+type printJobStatus struct {
+	ID       int       `json:"id"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Pages    int       `json:"pages"`
+	Lines    int       `json:"lines"`
+}
+
+func (ps *printerSession) addJob(job host.PrintJob) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.nextID++
+	ps.jobs = append(ps.jobs, printJob{id: ps.nextID, job: job})
+	if len(ps.jobs) > maxPrintJobs {
+		ps.jobs = ps.jobs[len(ps.jobs)-maxPrintJobs:]
+	}
+}
+
+func (ps *printerSession) job(id int) (host.PrintJob, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, j := range ps.jobs {
+		if j.id == id {
+			return j.job, true
+		}
+	}
+	return host.PrintJob{}, false
+}
+
+func (ps *printerSession) clearJobs() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.jobs = nil
+}
+
+// stop disconnects the printer, keeping captured jobs.
+func (ps *printerSession) stop() {
+	ps.mu.Lock()
+	p := ps.printer
+	ps.mu.Unlock()
+	if p != nil {
+		_ = p.Stop()
+	}
+}
+
+// Status snapshots the printer and lists jobs newest first.
+func (ps *printerSession) Status() printerStatus {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	st := printerStatus{AssociateLU: ps.associateLU, Jobs: make([]printJobStatus, 0, len(ps.jobs))}
+	if ps.printer != nil {
+		st.Connected = ps.printer.IsConnected()
+		st.LU = ps.printer.LU()
+		st.Error = ps.printer.LastError()
+	}
+	for i := len(ps.jobs) - 1; i >= 0; i-- {
+		j := ps.jobs[i]
+		st.Jobs = append(st.Jobs, printJobStatus{
+			ID:       j.id,
+			Started:  j.job.Started,
+			Finished: j.job.Finished,
+			Pages:    len(j.job.Pages),
+			Lines:    j.job.LineCount(),
+		})
+	}
+	return st
+}
+
+// printerCodePage returns the code page the terminal uses, so print output
+// decodes the same way as the screen.
+func (app *App) printerCodePage(s *session.Session) string {
+	if app.Config == nil {
+		return ""
+	}
+	return nativeHostOptions(app.Config.S3270Options, app.sessionProfile(s)).CodePage
+}
+
+// startPrinter connects a printer for the session, associated with the
+// terminal's LU unless printerLU names a specific printer LU.
+func (app *App) startPrinter(s *session.Session, printerLU string) (int, error) {
+	var h host.Host
+	withSessionLock(s, func() { h = s.Host })
+	if h == nil || !h.IsConnected() {
+		return http.StatusBadRequest, errors.New("not connected to host")
+	}
+	endpoint, ok := h.(host.PrinterEndpoint)
+	if !ok {
+		return http.StatusBadRequest, errors.New("this host does not support printer sessions")
+	}
+	target, terminalLU := endpoint.PrinterEndpoint()
+	opts := host.PrinterOptions{CodePage: app.printerCodePage(s)}
+	if printerLU != "" {
+		opts.LU = printerLU
+	} else if terminalLU != "" {
+		opts.AssociateLU = terminalLU
+	} else {
+		return http.StatusBadRequest, errors.New("the host did not assign the terminal an LU name; enter a printer LU to connect to")
+	}
+
+	ps, ok := app.printers.get(s.ID)
+	if !ok {
+		ps = &printerSession{}
+		app.printers.set(s.ID, ps)
+	}
+	ps.stop()
+	opts.OnJob = ps.addJob
+	p := host.NewPrinter(target, opts)
+	if err := p.Start(); err != nil {
+		return http.StatusBadGateway, err
+	}
+	ps.mu.Lock()
+	ps.printer = p
+	ps.associateLU = opts.AssociateLU
+	ps.mu.Unlock()
+	return http.StatusOK, nil
+}
+
+// PrinterStartHandler handles POST /printer/start. It opens a printer
+// session next to the terminal, like running pr3287 alongside x3270.
+func (app *App) PrinterStartHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	lu := strings.TrimSpace(c.PostForm("lu"))
+	if !isValidLUName(lu) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "LU names may only contain letters, digits, @, # and $"})
+		return
+	}
+	if status, err := app.startPrinter(s, lu); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	ps, _ := app.printers.get(s.ID)
+	c.JSON(http.StatusOK, gin.H{"printer": ps.Status()})
+}
+
+func isValidLUName(lu string) bool {
+	if len(lu) > 8 {
+		return false
+	}
+	for _, r := range lu {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '@' || r == '#' || r == '$') {
+			return false
+		}
+	}
+	return true
+}
+
+// PrinterStopHandler handles POST /printer/stop.
+func (app *App) PrinterStopHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	ps, ok := app.printers.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"printer": nil})
+		return
+	}
+	ps.stop()
+	c.JSON(http.StatusOK, gin.H{"printer": ps.Status()})
+}
+
+// PrinterStatusHandler handles GET /printer/status for the printer dialog.
+func (app *App) PrinterStatusHandler(c *gin.Context) {
+	s := app.peekSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	ps, ok := app.printers.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"printer": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"printer": ps.Status()})
+}
+
+// PrinterClearHandler handles POST /printer/clear and deletes captured jobs.
+func (app *App) PrinterClearHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	if ps, ok := app.printers.get(s.ID); ok {
+		ps.clearJobs()
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cleared"})
+}
+
+// PrinterJobHandler handles GET /printer/jobs/:id and downloads the job as
+// plain text, or as PDF with ?format=pdf.
+func (app *App) PrinterJobHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+	ps, ok := app.printers.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "print job not found"})
+		return
+	}
+	job, ok := ps.job(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "print job not found"})
+		return
+	}
+	name := fmt.Sprintf("print-job-%d-%s", id, job.Started.Format("20060102-150405"))
+	switch c.DefaultQuery("format", "text") {
+	case "text":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".txt"))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(job.Text()))
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".pdf"))
+		c.Data(http.StatusOK, "application/pdf", pdf.Text(fmt.Sprintf("Print job %d", id), job.Pages))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be text or pdf"})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func newPrinterTestRouter(t *testing.T, h host.Host) (*gin.Engine, *App, *session.Session) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), printers: newPrinterStore()}
+	sess := app.SessionManager.CreateSession(h)
+
+	r := gin.New()
+	r.POST("/printer/start", app.PrinterStartHandler)
+	r.POST("/printer/stop", app.PrinterStopHandler)
+	r.POST("/printer/clear", app.PrinterClearHandler)
+	r.GET("/printer/status", app.PrinterStatusHandler)
+	r.GET("/printer/jobs/:id", app.PrinterJobHandler)
+	return r, app, sess
+}
+
+func postPrinterForm(path string, form url.Values) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestPrinterCapturesSampleAppJob(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	sample, err := newNativeSampleAppHost("app3", port, host.TN3270Options{})
+	if err != nil {
+		t.Fatalf("newNativeSampleAppHost: %v", err)
+	}
+	if err := sample.Start(); err != nil {
+		t.Fatalf("sample Start: %v", err)
+	}
+	defer sample.Stop()
+
+	r, app, sess := newPrinterTestRouter(t, sample)
+	w := serveTransfer(r, sess, postPrinterForm("/printer/start", url.Values{}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("start = %d %s, want 200", w.Code, w.Body.String())
+	}
+	var started struct{ Printer printerStatus }
+	_ = json.Unmarshal(w.Body.Bytes(), &started)
+	if !started.Printer.Connected || !strings.HasPrefix(started.Printer.LU, "SAMPP") || !strings.HasPrefix(started.Printer.AssociateLU, "SAMPT") {
+		t.Fatalf("printer = %+v, want connected and associated", started.Printer)
+	}
+
+	if err := sample.SendKey("PF(4)"); err != nil {
+		t.Fatalf("SendKey(PF4): %v", err)
+	}
+	ps, _ := app.printers.get(sess.ID)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ps.Status().Jobs) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/printer/status", nil)
+	w = serveTransfer(r, sess, req)
+	var status struct{ Printer printerStatus }
+	_ = json.Unmarshal(w.Body.Bytes(), &status)
+	if len(status.Printer.Jobs) != 1 || status.Printer.Jobs[0].ID != 1 || status.Printer.Jobs[0].Pages != 1 {
+		t.Fatalf("jobs = %+v, want one single-page job", status.Printer.Jobs)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/printer/jobs/1", nil)
+	w = serveTransfer(r, sess, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "DAILY SALES REPORT") {
+		t.Fatalf("text download = %d %q, want the report", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, ".txt") {
+		t.Fatalf("Content-Disposition = %q, want a .txt attachment", got)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "/printer/jobs/1?format=pdf", nil)
+	w = serveTransfer(r, sess, req)
+	if w.Code != http.StatusOK || !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("pdf download = %d, want a PDF", w.Code)
+	}
+
+	w = serveTransfer(r, sess, postPrinterForm("/printer/stop", url.Values{}))
+	if w.Code != http.StatusOK || ps.Status().Connected {
+		t.Fatalf("stop = %d, connected = %v, want 200 and disconnected", w.Code, ps.Status().Connected)
+	}
+	w = serveTransfer(r, sess, postPrinterForm("/printer/clear", url.Values{}))
+	if w.Code != http.StatusOK || len(ps.Status().Jobs) != 0 {
+		t.Fatalf("clear = %d, jobs = %d, want 200 and none", w.Code, len(ps.Status().Jobs))
+	}
+}
+
+func TestPrinterRejectsUnsupportedRequests(t *testing.T) {
+	mockHost, _ := host.NewMockHost("")
+	mockHost.Connected = true
+	r, app, sess := newPrinterTestRouter(t, mockHost)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		code int
+		want string
+	}{
+		{"host without printer support", postPrinterForm("/printer/start", url.Values{}), http.StatusBadRequest, "does not support printer sessions"},
+		{"invalid LU", postPrinterForm("/printer/start", url.Values{"lu": {"BAD LU"}}), http.StatusBadRequest, "LU names may only contain"},
+		{"unknown job", func() *http.Request { req, _ := http.NewRequest(http.MethodGet, "/printer/jobs/7", nil); return req }(), http.StatusNotFound, "print job not found"},
+	}
+	for _, tt := range tests {
+		w := serveTransfer(r, sess, tt.req)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s = %d %s, want %d %q", tt.name, w.Code, w.Body.String(), tt.code, tt.want)
+		}
+	}
+
+	ps := &printerSession{}
+	app.printers.set(sess.ID, ps)
+	ps.addJob(host.PrintJob{Started: time.Now(), Pages: [][]string{{"LINE"}}})
+	req, _ := http.NewRequest(http.MethodGet, "/printer/jobs/1?format=doc", nil)
+	if w := serveTransfer(r, sess, req); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/config"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// profileHostPrefix marks a connection target that names a saved connection
+// profile, e.g. "profile:Production". Such targets work anywhere a hostname
+// does: the connect form, workflows, the API and the CLI --host flag.
+const profileHostPrefix = "profile:"
+
+var (
+	profileNamePattern     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._-]{0,63}$`)
+	profileModelPattern    = regexp.MustCompile(`^327[89]-[2-5](-E)?$`)
+	profileOversizePattern = regexp.MustCompile(`^(auto|[0-9]{2,3}x[0-9]{2,3})$`)
+	profileCodePagePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+	profileDevNamePattern  = regexp.MustCompile(`^[A-Za-z0-9@#$=]{1,16}$`)
+)
+
+// profileStore keeps the saved connection profiles, loading the file on first
+// use and writing it back on every change.
+type profileStore struct {
+	path     string
+	mu       sync.Mutex
+	loaded   bool
+	profiles []config.ConnectionProfile
+}
+
+func newProfileStore(path string) *profileStore {
+	return &profileStore{path: path}
+}
+
+func (ps *profileStore) loadLocked() error {
+	if ps.loaded {
+		return nil
+	}
+	profiles, err := config.LoadProfiles(ps.path)
+	if err != nil {
+		return err
+	}
+	ps.profiles = profiles
+	ps.loaded = true
+	return nil
+}
+
+// list returns the profiles sorted by name.
+func (ps *profileStore) list() ([]config.ConnectionProfile, error) {
+	if ps == nil {
+		return []config.ConnectionProfile{}, nil
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if err := ps.loadLocked(); err != nil {
+		return nil, err
+	}
+	profiles := append([]config.ConnectionProfile{}, ps.profiles...)
+	sort.Slice(profiles, func(i, j int) bool {
+		return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+	})
+	return profiles, nil
+}
+
+// get looks a profile up by name, ignoring case.
+func (ps *profileStore) get(name string) (config.ConnectionProfile, error) {
+	if ps == nil {
+		return config.ConnectionProfile{}, fmt.Errorf("connection profile %q not found", name)
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if err := ps.loadLocked(); err != nil {
+		return config.ConnectionProfile{}, err
+	}
+	if i := ps.indexLocked(name); i >= 0 {
+		return ps.profiles[i], nil
+	}
+	return config.ConnectionProfile{}, fmt.Errorf("connection profile %q not found", name)
+}
+
+// put adds the profile, replacing any profile with the same name.
+func (ps *profileStore) put(p config.ConnectionProfile) error {
+	if ps == nil {
+		return errors.New("connection profiles are not available")
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if err := ps.loadLocked(); err != nil {
+		return err
+	}
+	next := append([]config.ConnectionProfile{}, ps.profiles...)
+	if i := ps.indexLocked(p.Name); i >= 0 {
+		next[i] = p
+	} else {
+		next = append(next, p)
+	}
+	if err := config.SaveProfiles(ps.path, next); err != nil {
+		return err
+	}
+	ps.profiles = next
+	return nil
+}
+
+// remove deletes the named profile and reports whether it existed.
+func (ps *profileStore) remove(name string) (bool, error) {
+	if ps == nil {
+		return false, nil
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if err := ps.loadLocked(); err != nil {
+		return false, err
+	}
+	i := ps.indexLocked(name)
+	if i < 0 {
+		return false, nil
+	}
+	next := append(append([]config.ConnectionProfile{}, ps.profiles[:i]...), ps.profiles[i+1:]...)
+	if err := config.SaveProfiles(ps.path, next); err != nil {
+		return false, err
+	}
+	ps.profiles = next
+	return true, nil
+}
+
+func (ps *profileStore) indexLocked(name string) int {
+	for i, p := range ps.profiles {
+		if strings.EqualFold(p.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func profileHost(name string) string {
+	return profileHostPrefix + name
+}
+
+func parseProfileHost(hostname string) (string, bool) {
+	trimmed := strings.TrimSpace(hostname)
+	if !strings.HasPrefix(trimmed, profileHostPrefix) {
+		return "", false
+	}
+	name := strings.TrimSpace(strings.TrimPrefix(trimmed, profileHostPrefix))
+	return name, name != ""
+}
+
+// resolveTarget expands a profile: target into the profile's address. Other
+// targets are returned unchanged with a nil profile.
+func (app *App) resolveTarget(hostname string) (string, *config.ConnectionProfile, error) {
+	name, ok := parseProfileHost(hostname)
+	if !ok {
+		return hostname, nil, nil
+	}
+	p, err := app.profiles.get(name)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.Address(), &p, nil
+}
+
+// sessionProfile returns the profile the session connected with, or nil.
+func (app *App) sessionProfile(s *session.Session) *config.ConnectionProfile {
+	var name string
+	withSessionLock(s, func() { name = s.Profile })
+	if name == "" {
+		return nil
+	}
+	p, err := app.profiles.get(name)
+	if err != nil {
+		return nil
+	}
+	return &p
+}
+
+// setSessionTarget records where the session is connected: the host and port
+// actually dialled, and the profile when the target named one.
+func (app *App) setSessionTarget(s *session.Session, hostname string) {
+	address, profile, err := app.resolveTarget(hostname)
+	if err != nil {
+		address = hostname
+	}
+	s.TargetHost, s.TargetPort = parseHostPort(address)
+	s.Profile = ""
+	if profile != nil {
+		s.Profile = profile.Name
+	}
+}
+
+// validateConnectionProfile trims the profile's fields and checks them, so
+// nothing saved can inject extra s3270 arguments or an unusable target.
+func validateConnectionProfile(p *config.ConnectionProfile) error {
+	for _, field := range []*string{&p.Name, &p.Host, &p.Model, &p.Oversize, &p.CodePage, &p.LU,
+		&p.DeviceName, &p.CAFile, &p.CertFile, &p.KeyFile, &p.LoginMacro, &p.Proxy} {
+		*field = strings.TrimSpace(*field)
+		if strings.ContainsAny(*field, "\r\n\x00") {
+			return errors.New("profile values must be a single line")
+		}
+	}
+	switch {
+	case !profileNamePattern.MatchString(p.Name):
+		return errors.New("profile name must start with a letter or digit and use only letters, digits, spaces, '.', '_' and '-' (64 characters max)")
+	case p.Host == "" || strings.Contains(p.Host, "@") || strings.HasPrefix(p.Host, sampleAppPrefix) || strings.HasPrefix(p.Host, profileHostPrefix):
+		return errors.New("profile host must be a hostname or IP address")
+	case p.Port < 0 || p.Port > 65535:
+		return errors.New("profile port must be between 1 and 65535, or 0 for the default")
+	case !isValidHostname(p.Address()):
+		return fmt.Errorf("invalid profile host %q", p.Address())
+	case p.Model != "" && !profileModelPattern.MatchString(p.Model):
+		return errors.New("model must look like 3278-2 or 3279-4-E")
+	case p.Oversize != "" && !profileOversizePattern.MatchString(p.Oversize):
+		return errors.New("oversize must be auto or COLSxROWS, e.g. 132x43")
+	case p.CodePage != "" && !profileCodePagePattern.MatchString(p.CodePage):
+		return errors.New("code page may only contain letters, digits, '_' and '-'")
+	case !isValidLUName(p.LU):
+		return errors.New("LU names may only contain letters, digits, @, # and $")
+	case p.DeviceName != "" && !profileDevNamePattern.MatchString(p.DeviceName):
+		return errors.New("device name may only contain letters, digits, @, #, $ and = (16 characters max)")
+	case strings.ContainsAny(p.Proxy, " \t"):
+		return errors.New("proxy must be in the form type:host[:port]")
+	}
+	return nil
+}
+
+// ProfilesListHandler handles GET /profiles.
+func (app *App) ProfilesListHandler(c *gin.Context) {
+	profiles, err := app.profiles.list()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+// ProfileSaveHandler handles POST /profiles with a JSON profile, creating it
+// or replacing the profile with the same name.
+func (app *App) ProfileSaveHandler(c *gin.Context) {
+	var p config.ConnectionProfile
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON payload"})
+		return
+	}
+	if err := validateConnectionProfile(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := app.profiles.put(p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profile": p})
+}
+
+// ProfileDeleteHandler handles POST /profiles/delete.
+func (app *App) ProfileDeleteHandler(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	removed, err := app.profiles.remove(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "connection profile not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
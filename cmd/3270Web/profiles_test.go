@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/config"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/sampleapps"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func newProfileTestApp(t *testing.T) *App {
+	t.Helper()
+	return &App{
+		SessionManager: session.NewManager(),
+		Config:         &config.Config{},
+		chaosEngines:   newChaosEngineStore(),
+		profiles:       newProfileStore(filepath.Join(t.TempDir(), "profiles.json")),
+	}
+}
+
+func TestProfileHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := newProfileTestApp(t)
+	r := gin.New()
+	r.GET("/profiles", app.ProfilesListHandler)
+	r.POST("/profiles", app.ProfileSaveHandler)
+	r.POST("/profiles/delete", app.ProfileDeleteHandler)
+
+	save := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/profiles", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+		want string
+	}{
+		{"valid", `{"name":" Prod ","host":"mvs.example.com","port":992,"tls":true,"model":"3279-4-E"}`, http.StatusOK, `"name":"Prod"`},
+		{"second", `{"name":"dev","host":"10.0.0.5"}`, http.StatusOK, `"host":"10.0.0.5"`},
+		{"bad name", `{"name":"-x","host":"a"}`, http.StatusBadRequest, "profile name must start"},
+		{"host with LU", `{"name":"x","host":"LU@a"}`, http.StatusBadRequest, "profile host must be"},
+		{"sample app host", `{"name":"x","host":"sampleapp:app1"}`, http.StatusBadRequest, "profile host must be"},
+		{"bad port", `{"name":"x","host":"a","port":70000}`, http.StatusBadRequest, "profile port"},
+		{"bad model", `{"name":"x","host":"a","model":"-e x"}`, http.StatusBadRequest, "model must look like"},
+		{"bad oversize", `{"name":"x","host":"a","oversize":"big"}`, http.StatusBadRequest, "oversize must be"},
+		{"bad LU", `{"name":"x","host":"a","lu":"TOO-LONG-LU"}`, http.StatusBadRequest, "LU names"},
+		{"multi-line macro", `{"name":"x","host":"a","loginMacro":"Enter\nQuit"}`, http.StatusBadRequest, "single line"},
+		{"invalid JSON", `{`, http.StatusBadRequest, "invalid JSON"},
+	}
+	for _, tt := range tests {
+		w := save(tt.body)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s = %d %s, want %d %q", tt.name, w.Code, w.Body.String(), tt.code, tt.want)
+		}
+	}
+
+	// Saving under an existing name in another case replaces the profile.
+	if w := save(`{"name":"PROD","host":"mvs2.example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("replace = %d %s, want 200", w.Code, w.Body.String())
+	}
+	req, _ := http.NewRequest(http.MethodGet, "/profiles", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var listed struct{ Profiles []config.ConnectionProfile }
+	_ = json.Unmarshal(w.Body.Bytes(), &listed)
+	if len(listed.Profiles) != 2 || listed.Profiles[0].Name != "dev" || listed.Profiles[1].Host != "mvs2.example.com" {
+		t.Fatalf("profiles = %+v, want dev and the replaced PROD", listed.Profiles)
+	}
+
+	// A fresh store reads what was saved.
+	stored, err := newProfileStore(app.profiles.path).get("prod")
+	if err != nil || stored.Host != "mvs2.example.com" {
+		t.Fatalf("stored profile = %+v, %v", stored, err)
+	}
+
+	del := func(name string) int {
+		req, _ := http.NewRequest(http.MethodPost, "/profiles/delete", strings.NewReader(url.Values{"name": {name}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := del("dev"); code != http.StatusOK {
+		t.Fatalf("delete = %d, want 200", code)
+	}
+	if code := del("dev"); code != http.StatusNotFound {
+		t.Fatalf("second delete = %d, want 404", code)
+	}
+}
+
+func TestBuildS3270ArgsWithProfile(t *testing.T) {
+	os.Setenv("S3270_MODEL", "2")
+	os.Setenv("S3270_PROXY", "socks5:global:1080")
+	os.Setenv("S3270_TRACE", "true")
+	defer os.Unsetenv("S3270_MODEL")
+	defer os.Unsetenv("S3270_PROXY")
+	defer os.Unsetenv("S3270_TRACE")
+
+	profile := &config.ConnectionProfile{
+		Name:     "prod",
+		Host:     "mvs.example.com",
+		Port:     992,
+		Model:    "3279-2-E",
+		CodePage: "cp037",
+		LU:       "TSO001",
+		TLS:      true,
+		Proxy:    "http:proxy.example.com:8080",
+	}
+	args := buildS3270Args(config.S3270Options{Model: "4", Charset: "german"}, "ignored:23", profile)
+	joined := strings.Join(args, " ")
+	for _, want := range []string{"-model 3279-2-E", "-codepage cp037", "-trace", "-proxy http:proxy.example.com:8080"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("args %q missing %q", joined, want)
+		}
+	}
+	for _, unwanted := range []string{"-model 2", "-charset", "socks5:global"} {
+		if strings.Contains(joined, unwanted) {
+			t.Errorf("args %q should not contain %q", joined, unwanted)
+		}
+	}
+	if last := args[len(args)-1]; last != "L:TSO001@mvs.example.com:992" {
+		t.Errorf("host argument = %q, want L:TSO001@mvs.example.com:992", last)
+	}
+}
+
+func TestWorkflowTargetHostPrefersProfile(t *testing.T) {
+	s := &session.Session{TargetHost: "localhost", TargetPort: 3270, Profile: "dev"}
+	got, err := workflowTargetHost(s, &WorkflowConfig{Host: "example.com", Profile: "prod"})
+	if err != nil || got != "profile:prod" {
+		t.Fatalf("workflowTargetHost = %q, %v; want profile:prod", got, err)
+	}
+	got, err = workflowTargetHost(s, &WorkflowConfig{})
+	if err != nil || got != "profile:dev" {
+		t.Fatalf("session fallback = %q, %v; want profile:dev", got, err)
+	}
+	if !isValidHostname("profile:dev") || isValidHostname("profile:bad/name") {
+		t.Fatal("isValidHostname should accept profile names and reject invalid ones")
+	}
+}
+
+func TestOpenSessionWithProfile(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	server, err := sampleapps.StartServer("app3", port)
+	if err != nil {
+		t.Fatalf("StartServer: %v", err)
+	}
+	defer server.Stop()
+
+	app := newProfileTestApp(t)
+	if err := app.profiles.put(config.ConnectionProfile{Name: "local", Host: "127.0.0.1", Port: port, LU: "MYLU01"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.profiles.put(config.ConnectionProfile{Name: "secure", Host: "127.0.0.1", Port: port, TLS: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := app.openSession("profile:local", hostEngineNative)
+	if err != nil {
+		t.Fatalf("openSession: %v", err)
+	}
+	defer s.Host.Stop()
+	if s.Profile != "local" || s.TargetHost != "127.0.0.1" || s.TargetPort != port {
+		t.Fatalf("session target = %q %s:%d, want profile local at 127.0.0.1:%d", s.Profile, s.TargetHost, s.TargetPort, port)
+	}
+	if _, lu := s.Host.(host.PrinterEndpoint).PrinterEndpoint(); lu != "MYLU01" {
+		t.Fatalf("host assigned LU %q, want the profile's MYLU01", lu)
+	}
+
+	if _, err := app.openSession("profile:secure", hostEngineNative); err == nil || !strings.Contains(err.Error(), "s3270 engine") {
+		t.Fatalf("native TLS profile error = %v, want s3270 engine required", err)
+	}
+	if _, err := app.openSession("profile:missing", hostEngineNative); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("unknown profile error = %v, want not found", err)
+	}
+}
+
+func TestRecordedWorkflowKeepsProfile(t *testing.T) {
+	s := &session.Session{Recording: &session.WorkflowRecording{Host: "127.0.0.1", Port: 3270, Profile: "local"}}
+	data, err := json.Marshal(buildWorkflowConfig(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"Profile":"local"`)) {
+		t.Fatalf("workflow %s, want Profile local", data)
+	}
+}
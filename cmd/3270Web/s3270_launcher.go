@@ -64,8 +64,16 @@ func s3270BinaryName() string {
 	return "s3270"
 }
 
-func buildS3270Args(opts config.S3270Options, hostname string) []string {
-	envOverrides, err := config.S3270EnvOverridesFromEnv()
+// buildS3270Args translates the configured options, .env overrides and an
+// optional connection profile into s3270 arguments. Profile settings win over
+// both, and the profile's own target replaces hostname.
+func buildS3270Args(opts config.S3270Options, hostname string, profile *config.ConnectionProfile) []string {
+	var skip []string
+	if profile != nil {
+		skip = profile.OverriddenEnvVars()
+		hostname = profile.S3270Host()
+	}
+	envOverrides, err := config.S3270EnvOverridesFromEnv(skip...)
 	if err != nil {
 		log.Printf("Warning: invalid .env s3270 options: %v", err)
 	}
@@ -74,12 +82,17 @@ func buildS3270Args(opts config.S3270Options, hostname string) []string {
 	if envOverrides.HasModel {
 		model = envOverrides.Model
 	}
+	if profile != nil && profile.Model != "" {
+		model = profile.Model
+	}
 	args := []string{}
 	if model != "" {
 		args = append(args, "-model", model)
 	}
 
-	if envOverrides.HasCodePage {
+	if profile != nil && profile.CodePage != "" {
+		args = append(args, "-codepage", profile.CodePage)
+	} else if envOverrides.HasCodePage {
 		if envOverrides.CodePage != "" {
 			args = append(args, "-codepage", envOverrides.CodePage)
 		}
@@ -88,6 +101,9 @@ func buildS3270Args(opts config.S3270Options, hostname string) []string {
 	}
 
 	args = append(args, envOverrides.Args...)
+	if profile != nil {
+		args = append(args, profile.S3270Args()...)
+	}
 	if opts.Additional != "" {
 		if additional, err := config.SplitArgs(opts.Additional); err == nil {
 			args = append(args, additional...)
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const (
+	// screenHistorySizeEnv sets how many screens each session keeps; 0
+	// disables the history.
+	screenHistorySizeEnv     = "APP_SCREEN_HISTORY_SIZE"
+	defaultScreenHistorySize = 50
+	maxScreenHistorySize     = 1000
+	maxScreenHistoryMatches  = 200
+)
+
+// screenHistorySize returns the configured number of screens kept per
+// session.
+func screenHistorySize() int {
+	raw := strings.TrimSpace(os.Getenv(screenHistorySizeEnv))
+	if raw == "" {
+		return defaultScreenHistorySize
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 || n > maxScreenHistorySize {
+		log.Printf("Warning: invalid %s=%q; using %d", screenHistorySizeEnv, raw, defaultScreenHistorySize)
+		return defaultScreenHistorySize
+	}
+	return n
+}
+
+// screenHistoryStore keeps the screen history of each browser and API
+// session.
+type screenHistoryStore struct {
+	mu        sync.Mutex
+	histories map[string]*screenHistory
+}
+
+func newScreenHistoryStore() *screenHistoryStore {
+	return &screenHistoryStore{histories: make(map[string]*screenHistory)}
+}
+
+func (s *screenHistoryStore) get(sessionID string) (*screenHistory, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.histories[sessionID]
+	return h, ok
+}
+
+// getOrCreate returns the session's history, creating it with the
+// configured size on first use.
+func (s *screenHistoryStore) getOrCreate(sessionID string) *screenHistory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.histories[sessionID]
+	if !ok {
+		h = newScreenHistory(screenHistorySize())
+		s.histories[sessionID] = h
+	}
+	return h
+}
+
+func (s *screenHistoryStore) delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.histories, sessionID)
+}
+
+// screenSnapshot is a read-only copy of a screen as it was shown, with
+// hidden fields blanked. AID is the key that led to it, when known.
+type screenSnapshot struct {
+	Seq    int       `json:"seq"`
+	Time   time.Time `json:"time"`
+	AID    string    `json:"aid,omitempty"`
+	Title  string    `json:"title"`
+	Screen apiScreen `json:"screen"`
+}
+
+// screenHistoryEntry summarizes a snapshot for the history list.
+type screenHistoryEntry struct {
+	Seq   int       `json:"seq"`
+	Time  time.Time `json:"time"`
+	AID   string    `json:"aid,omitempty"`
+	Title string    `json:"title"`
+}
+
+// screenHistoryMatch is one row of a snapshot that matched a search.
+type screenHistoryMatch struct {
+	screenHistoryEntry
+	Row    int    `json:"row"`
+	Column int    `json:"column"`
+	Line   string `json:"line"`
+}
+
+// screenHistory is a ring of the most recent distinct screens of a
+// session, oldest first.
+type screenHistory struct {
+	mu         sync.Mutex
+	size       int
+	snapshots  []screenSnapshot
+	nextSeq    int
+	pendingAID string
+}
+
+func newScreenHistory(size int) *screenHistory {
+	return &screenHistory{size: size, nextSeq: 1}
+}
+
+// noteAID remembers the key just sent, to label the screen it produces.
+func (h *screenHistory) noteAID(aid string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pendingAID = aid
+}
+
+// capture adds screen unless it looks the same as the newest snapshot.
+// Cursor moves alone do not count as a new screen.
+func (h *screenHistory) capture(screen *host.Screen, at time.Time) bool {
+	if h.size <= 0 || screen == nil || len(screen.Buffer) == 0 {
+		return false
+	}
+	view := buildAPIScreen(screen)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	aid := h.pendingAID
+	h.pendingAID = ""
+	if n := len(h.snapshots); n > 0 {
+		last := h.snapshots[n-1].Screen
+		if reflect.DeepEqual(last.Text, view.Text) && reflect.DeepEqual(last.Fields, view.Fields) {
+			return false
+		}
+	}
+	h.snapshots = append(h.snapshots, screenSnapshot{
+		Seq:    h.nextSeq,
+		Time:   at,
+		AID:    aid,
+		Title:  screenTitle(view.Text),
+		Screen: view,
+	})
+	h.nextSeq++
+	if len(h.snapshots) > h.size {
+		h.snapshots = append(h.snapshots[:0:0], h.snapshots[len(h.snapshots)-h.size:]...)
+	}
+	return true
+}
+
+// screenTitle names a screen by its first non-blank row.
+func screenTitle(text []string) string {
+	for _, line := range text {
+		if trimmed := strings.Join(strings.Fields(line), " "); trimmed != "" {
+			return trimmed
+		}
+	}
+	return "(blank screen)"
+}
+
+func (h *screenHistory) entries() []screenHistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]screenHistoryEntry, 0, len(h.snapshots))
+	for _, snap := range h.snapshots {
+		out = append(out, snap.entry())
+	}
+	return out
+}
+
+func (snap screenSnapshot) entry() screenHistoryEntry {
+	return screenHistoryEntry{Seq: snap.Seq, Time: snap.Time, AID: snap.AID, Title: snap.Title}
+}
+
+func (h *screenHistory) snapshot(seq int) (screenSnapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, snap := range h.snapshots {
+		if snap.Seq == seq {
+			return snap, true
+		}
+	}
+	return screenSnapshot{}, false
+}
+
+// search finds rows containing query, ignoring case, newest screens first.
+func (h *screenHistory) search(query string) []screenHistoryMatch {
+	needle := strings.ToLower(query)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var matches []screenHistoryMatch
+	for i := len(h.snapshots) - 1; i >= 0; i-- {
+		snap := h.snapshots[i]
+		for row, line := range snap.Screen.Text {
+			idx := strings.Index(strings.ToLower(line), needle)
+			if idx < 0 {
+				continue
+			}
+			matches = append(matches, screenHistoryMatch{
+				screenHistoryEntry: snap.entry(),
+				Row:                row + 1,
+				Column:             len([]rune(line[:idx])) + 1,
+				Line:               strings.TrimRight(line, " "),
+			})
+			if len(matches) == maxScreenHistoryMatches {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// noteScreenAID labels the next captured screen of s with the key sent.
+func (app *App) noteScreenAID(s *session.Session, aid string) {
+	if app.screenHistory == nil || s == nil || s.ID == "" {
+		return
+	}
+	if h, ok := app.screenHistory.get(s.ID); ok {
+		h.noteAID(aid)
+	}
+}
+
+// observeScreen adds the session's current screen to its history and to
+// its screen recording, if one is running. Sessions the manager does not
+// know, such as load test virtual users and CLI playback, keep neither.
+func (app *App) observeScreen(s *session.Session) {
+	if app.screenHistory == nil && app.screenRecordings == nil {
+		return
+	}
+	if s == nil || s.Host == nil {
+		return
+	}
+	if _, ok := app.SessionManager.PeekSession(s.ID); !ok {
+		return
+	}
+	screen, now := s.Host.GetScreen(), time.Now()
+	if app.screenHistory != nil {
+		app.screenHistory.getOrCreate(s.ID).capture(screen, now)
+	}
+	app.recordScreen(s, screen, now)
+}
+
+// ScreenHistoryHandler handles GET /screen/history, listing the session's
+// screens oldest first, or with q the rows that contain it.
+func (app *App) ScreenHistoryHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	h := app.screenHistory.getOrCreate(s.ID)
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		matches := h.search(query)
+		if matches == nil {
+			matches = []screenHistoryMatch{}
+		}
+		c.JSON(http.StatusOK, gin.H{"size": h.size, "matches": matches})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"size": h.size, "entries": h.entries()})
+}
+
+// ScreenHistorySnapshotHandler handles GET /screen/history/:seq.
+func (app *App) ScreenHistorySnapshotHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	seq, err := strconv.Atoi(c.Param("seq"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid screen number"})
+		return
+	}
+	h, ok := app.screenHistory.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "screen is no longer in the history"})
+		return
+	}
+	snap, ok := h.snapshot(seq)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "screen is no longer in the history"})
+		return
+	}
+	c.JSON(http.StatusOK, snap)
+}
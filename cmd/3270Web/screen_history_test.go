@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/render"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func setupScreenHistoryTest(t *testing.T) (*App, *gin.Engine, *session.Session, *host.MockHost) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	mockHost.Connected = true
+	screen := mockHost.Screen
+	copy(screen.Buffer[0], []rune("SIGN ON"))
+	copy(screen.Buffer[5], []rune("PASSWD SECRET"))
+	screen.Fields = []*host.Field{
+		host.NewField(screen, host.AttrProtected, 0, 0, 6, 0, host.AttrColDefault, host.AttrEhDefault),
+		host.NewField(screen, host.AttrDisp1|host.AttrDisp2, 7, 5, 14, 5, host.AttrColDefault, host.AttrEhDefault),
+	}
+
+	app := &App{
+		SessionManager: session.NewManager(),
+		Renderer:       render.NewHtmlRenderer(),
+		chaosEngines:   newChaosEngineStore(),
+		screenHistory:  newScreenHistoryStore(),
+	}
+	s := app.SessionManager.CreateSession(mockHost)
+	r := gin.New()
+	r.GET("/screen/content", app.ScreenContentHandler)
+	r.GET("/screen/history", app.ScreenHistoryHandler)
+	r.GET("/screen/history/:seq", app.ScreenHistorySnapshotHandler)
+	return app, r, s, mockHost
+}
+
+func serveHistory(t *testing.T, r *gin.Engine, s *session.Session, target string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.AddCookie(&http.Cookie{Name: "3270Web_session", Value: s.ID})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+	}
+	return w.Code
+}
+
+func TestScreenHistoryCapturesDistinctScreens(t *testing.T) {
+	app, r, s, mockHost := setupScreenHistoryTest(t)
+
+	serveHistory(t, r, s, "/screen/content", nil)
+	serveHistory(t, r, s, "/screen/content", nil)
+	app.noteScreenAID(s, "PF(3)")
+	copy(mockHost.Screen.Buffer[0], []rune("MAIN MENU"))
+	mockHost.Screen.CursorX = 12
+	serveHistory(t, r, s, "/screen/content", nil)
+
+	var list struct {
+		Size    int                  `json:"size"`
+		Entries []screenHistoryEntry `json:"entries"`
+	}
+	if code := serveHistory(t, r, s, "/screen/history", &list); code != http.StatusOK {
+		t.Fatalf("GET /screen/history = %d", code)
+	}
+	if list.Size != defaultScreenHistorySize || len(list.Entries) != 2 {
+		t.Fatalf("history = %+v, want two screens of %d", list, defaultScreenHistorySize)
+	}
+	if got := list.Entries[0]; got.Seq != 1 || got.AID != "" || got.Title != "SIGN ON" {
+		t.Errorf("first entry = %+v, want the sign-on screen without a key", got)
+	}
+	if got := list.Entries[1]; got.Seq != 2 || got.AID != "PF(3)" || got.Title != "MAIN MENU" {
+		t.Errorf("second entry = %+v, want MAIN MENU after PF(3)", got)
+	}
+
+	var snap screenSnapshot
+	if code := serveHistory(t, r, s, "/screen/history/1", &snap); code != http.StatusOK {
+		t.Fatalf("GET /screen/history/1 = %d", code)
+	}
+	if !strings.HasPrefix(snap.Screen.Text[0], "SIGN ON") || strings.Contains(strings.Join(snap.Screen.Text, "\n"), "SECRET") {
+		t.Fatalf("snapshot text = %q, want the sign-on screen with the hidden field blanked", snap.Screen.Text[:6])
+	}
+	if code := serveHistory(t, r, s, "/screen/history/99", nil); code != http.StatusNotFound {
+		t.Fatalf("GET unknown snapshot = %d, want 404", code)
+	}
+
+	var search struct {
+		Matches []screenHistoryMatch `json:"matches"`
+	}
+	serveHistory(t, r, s, "/screen/history?q=menu", &search)
+	if len(search.Matches) != 1 || search.Matches[0].Seq != 2 || search.Matches[0].Row != 1 || search.Matches[0].Column != 6 {
+		t.Fatalf("search matches = %+v, want MENU at row 1, column 6 of screen 2", search.Matches)
+	}
+	serveHistory(t, r, s, "/screen/history?q=secret", &search)
+	if len(search.Matches) != 0 {
+		t.Fatalf("search for hidden value = %+v, want no matches", search.Matches)
+	}
+
+	app.closeSession(s)
+	if _, ok := app.screenHistory.get(s.ID); ok {
+		t.Fatal("history kept after the session closed")
+	}
+}
+
+func TestScreenHistoryRing(t *testing.T) {
+	t.Setenv(screenHistorySizeEnv, "2")
+	app, _, s, mockHost := setupScreenHistoryTest(t)
+	for _, title := range []string{"ONE", "TWO", "THREE"} {
+		copy(mockHost.Screen.Buffer[0], []rune(title+"     "))
+		app.observeScreen(s)
+	}
+	h, _ := app.screenHistory.get(s.ID)
+	entries := h.entries()
+	if len(entries) != 2 || entries[0].Seq != 2 || entries[1].Seq != 3 {
+		t.Fatalf("entries = %+v, want screens 2 and 3", entries)
+	}
+
+	t.Setenv(screenHistorySizeEnv, "0")
+	app.screenHistory.delete(s.ID)
+	app.observeScreen(s)
+	if h, _ := app.screenHistory.get(s.ID); len(h.entries()) != 0 {
+		t.Fatalf("disabled history kept %d screens", len(h.entries()))
+	}
+}
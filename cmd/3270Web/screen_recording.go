@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/screenrec"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// maxReplayUploadBytes bounds recordings uploaded to the replay viewer.
+const maxReplayUploadBytes = 32 << 20
+
+// screenRecordingStore tracks the screen recording of each browser session.
+// A stopped recording is kept for download until the next one starts or the
+// session closes.
+type screenRecordingStore struct {
+	mu         sync.Mutex
+	recordings map[string]*screenRecording
+}
+
+func newScreenRecordingStore() *screenRecordingStore {
+	return &screenRecordingStore{recordings: make(map[string]*screenRecording)}
+}
+
+func (s *screenRecordingStore) get(sessionID string) (*screenRecording, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.recordings[sessionID]
+	return r, ok
+}
+
+func (s *screenRecordingStore) set(sessionID string, r *screenRecording) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordings[sessionID] = r
+}
+
+func (s *screenRecordingStore) delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.recordings, sessionID)
+}
+
+// screenRecording streams a session's screens and keys to a temporary
+// file. The file is only complete, and so only readable, once stopped.
+type screenRecording struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	writer  *screenrec.Writer
+	started time.Time
+	stopped time.Time
+	screens int
+	keys    int
+	err     error
+}
+
+// screenRecordingStatus is the JSON shape polled by the recording dialog.
+type screenRecordingStatus struct {
+	Active  bool       `json:"active"`
+	Started time.Time  `json:"started"`
+	Stopped *time.Time `json:"stopped,omitempty"`
+	Screens int        `json:"screens"`
+	Keys    int        `json:"keys"`
+	Bytes   int64      `json:"bytes"`
+	Error   string     `json:"error,omitempty"`
+}
+
+func startScreenRecording(h screenrec.Header) (*screenRecording, error) {
+	file, err := os.CreateTemp("", "3270Web-screenrec-*"+screenrec.FileExt)
+	if err != nil {
+		return nil, fmt.Errorf("create recording file: %w", err)
+	}
+	w, err := screenrec.NewWriter(file, h)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("write recording header: %w", err)
+	}
+	return &screenRecording{path: file.Name(), file: file, writer: w, started: h.Started}, nil
+}
+
+// fail stops recording after a write error, keeping what was written.
+// Callers hold r.mu.
+func (r *screenRecording) fail(err error) {
+	log.Printf("Warning: screen recording stopped: %v", err)
+	r.err = err
+	r.closeLocked()
+}
+
+func (r *screenRecording) screen(screen *host.Screen, at time.Time) {
+	if screen == nil || len(screen.Buffer) == 0 {
+		return
+	}
+	frame := screenrec.NewFrame(screen)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer == nil {
+		return
+	}
+	if _, err := r.writer.Screen(at, frame); err != nil {
+		r.fail(err)
+	}
+}
+
+func (r *screenRecording) key(aid string, fields []audit.Field, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writer == nil {
+		return
+	}
+	if err := r.writer.Key(at, aid, fields); err != nil {
+		r.fail(err)
+	}
+}
+
+func (r *screenRecording) closeLocked() {
+	if r.writer == nil {
+		return
+	}
+	if err := r.writer.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	r.screens, r.keys = r.writer.Counts()
+	r.writer = nil
+	r.file = nil
+	r.stopped = time.Now()
+}
+
+func (r *screenRecording) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeLocked()
+}
+
+// discard stops the recording and deletes its file.
+func (r *screenRecording) discard() {
+	r.stop()
+	cleanupWorkflowFile(r.path)
+}
+
+// finishedPath returns the file of a stopped recording.
+func (r *screenRecording) finishedPath() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.path, r.writer == nil
+}
+
+func (r *screenRecording) Status() screenRecordingStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := screenRecordingStatus{Active: r.writer != nil, Started: r.started}
+	if !r.stopped.IsZero() {
+		stopped := r.stopped
+		st.Stopped = &stopped
+	}
+	st.Screens, st.Keys = r.screens, r.keys
+	if r.writer != nil {
+		st.Screens, st.Keys = r.writer.Counts()
+	}
+	if info, err := os.Stat(r.path); err == nil {
+		st.Bytes = info.Size()
+	}
+	if r.err != nil {
+		st.Error = r.err.Error()
+	}
+	return st
+}
+
+// recordScreen adds the session's current screen to its screen recording.
+func (app *App) recordScreen(s *session.Session, screen *host.Screen, at time.Time) {
+	if app.screenRecordings == nil || s == nil {
+		return
+	}
+	if r, ok := app.screenRecordings.get(s.ID); ok {
+		r.screen(screen, at)
+	}
+}
+
+// recordScreenKey adds a key, with the fields sent along with it, to the
+// session's screen recording.
+func (app *App) recordScreenKey(s *session.Session, aid string, fields []audit.Field) {
+	if app.screenRecordings == nil || s == nil {
+		return
+	}
+	if r, ok := app.screenRecordings.get(s.ID); ok {
+		r.key(aid, fields, time.Now())
+	}
+}
+
+// stopScreenRecording stops and deletes the session's recording, if any.
+func (app *App) stopScreenRecording(s *session.Session) {
+	if app.screenRecordings == nil {
+		return
+	}
+	if r, ok := app.screenRecordings.get(s.ID); ok {
+		r.discard()
+		app.screenRecordings.delete(s.ID)
+	}
+}
+
+// ScreenRecordingStartHandler handles POST /screenrec/start. Starting
+// replaces the previous recording of the session.
+func (app *App) ScreenRecordingStartHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	app.stopScreenRecording(s)
+	h := screenrec.Header{Started: time.Now()}
+	withSessionLock(s, func() {
+		h.Host = auditHost(s)
+		h.User = s.User
+	})
+	r, err := startScreenRecording(h)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	app.screenRecordings.set(s.ID, r)
+	if s.Host != nil {
+		r.screen(s.Host.GetScreen(), time.Now())
+	}
+	c.JSON(http.StatusOK, gin.H{"recording": r.Status()})
+}
+
+// ScreenRecordingStopHandler handles POST /screenrec/stop.
+func (app *App) ScreenRecordingStopHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	r, ok := app.screenRecordings.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"recording": nil})
+		return
+	}
+	r.stop()
+	c.JSON(http.StatusOK, gin.H{"recording": r.Status()})
+}
+
+// ScreenRecordingStatusHandler handles GET /screenrec/status.
+func (app *App) ScreenRecordingStatusHandler(c *gin.Context) {
+	s := app.peekSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	r, ok := app.screenRecordings.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"recording": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recording": r.Status()})
+}
+
+// finishedScreenRecording returns the session's stopped recording, writing
+// an error response when there is none.
+func (app *App) finishedScreenRecording(c *gin.Context) (*screenRecording, string, bool) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return nil, "", false
+	}
+	r, ok := app.screenRecordings.get(s.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no screen recording"})
+		return nil, "", false
+	}
+	path, done := r.finishedPath()
+	if !done {
+		c.JSON(http.StatusConflict, gin.H{"error": "stop the screen recording first"})
+		return nil, "", false
+	}
+	return r, path, true
+}
+
+// ScreenRecordingDownloadHandler handles GET /screenrec/download.
+func (app *App) ScreenRecordingDownloadHandler(c *gin.Context) {
+	r, path, ok := app.finishedScreenRecording(c)
+	if !ok {
+		return
+	}
+	name := "3270Web-screen-" + r.started.Format("20060102-150405") + screenrec.FileExt
+	c.FileAttachment(path, name)
+}
+
+// ScreenRecordingEventsHandler handles GET /screenrec/events, returning the
+// session's stopped recording for the replay viewer.
+func (app *App) ScreenRecordingEventsHandler(c *gin.Context) {
+	_, path, ok := app.finishedScreenRecording(c)
+	if !ok {
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "screen recording file is gone"})
+		return
+	}
+	defer file.Close()
+	rec, err := screenrec.Read(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
+
+// ReplayHandler handles GET /replay, the screen recording viewer. It does
+// not need a host session.
+func (app *App) ReplayHandler(c *gin.Context) {
+	c.HTML(http.StatusOK, "replay.html", gin.H{
+		"FromSession": c.Query("source") == "session",
+	})
+}
+
+// ReplayLoadHandler handles POST /replay/load, parsing an uploaded
+// recording for the viewer.
+func (app *App) ReplayLoadHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReplayUploadBytes)
+	fh, err := c.FormFile("recording")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "screen recording is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "choose a screen recording file"})
+		return
+	}
+	file, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	rec, err := screenrec.Read(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
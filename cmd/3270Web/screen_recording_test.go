@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/screenrec"
+)
+
+func TestScreenRecordingLifecycle(t *testing.T) {
+	app, r, s, mockHost := setupScreenHistoryTest(t)
+	app.screenRecordings = newScreenRecordingStore()
+	r.POST("/screenrec/start", app.ScreenRecordingStartHandler)
+	r.POST("/screenrec/stop", app.ScreenRecordingStopHandler)
+	r.GET("/screenrec/status", app.ScreenRecordingStatusHandler)
+	r.GET("/screenrec/download", app.ScreenRecordingDownloadHandler)
+	r.GET("/screenrec/events", app.ScreenRecordingEventsHandler)
+	r.POST("/replay/load", app.ReplayLoadHandler)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.AddCookie(&http.Cookie{Name: "3270Web_session", Value: s.ID})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(http.MethodPost, "/screenrec/start"); w.Code != http.StatusOK {
+		t.Fatalf("start = %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/screenrec/download"); w.Code != http.StatusConflict {
+		t.Fatalf("download while recording = %d, want 409", w.Code)
+	}
+	app.auditEvent(s, audit.SourceTerminal, "Enter", []audit.Field{audit.RedactedField(6, 8, "hunter2")}, nil)
+	copy(mockHost.Screen.Buffer[0], []rune("MAIN MENU"))
+	app.observeScreen(s)
+	app.observeScreen(s)
+
+	var status struct {
+		Recording screenRecordingStatus `json:"recording"`
+	}
+	w := serve(http.MethodPost, "/screenrec/stop")
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if got := status.Recording; got.Active || got.Screens != 2 || got.Keys != 1 || got.Bytes == 0 || got.Stopped == nil {
+		t.Fatalf("status after stop = %+v, want 2 screens and 1 key", got)
+	}
+
+	w = serve(http.MethodGet, "/screenrec/download")
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), screenrec.FileExt) {
+		t.Fatalf("download = %d, %q", w.Code, w.Header().Get("Content-Disposition"))
+	}
+	file := w.Body.Bytes()
+	if bytes.Contains(file, []byte("hunter2")) {
+		t.Fatal("redacted value written to the recording")
+	}
+
+	var rec screenrec.Recording
+	w = serve(http.MethodGet, "/screenrec/events")
+	if err := json.Unmarshal(w.Body.Bytes(), &rec); err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	if len(rec.Events) != 3 || rec.Events[1].Key != "Enter" || !strings.HasPrefix(rec.Events[2].Screen.Text[0], "MAIN MENU") {
+		t.Fatalf("events = %+v, want sign-on, Enter, main menu", rec.Events)
+	}
+	if strings.Contains(strings.Join(rec.Events[0].Screen.Text, "\n"), "SECRET") {
+		t.Fatal("hidden field shown in the recording")
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("recording", "session.3270rec")
+	part.Write(file)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/replay/load", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "MAIN MENU") {
+		t.Fatalf("replay load = %d: %s", w.Code, w.Body.String())
+	}
+
+	recording, _ := app.screenRecordings.get(s.ID)
+	path, _ := recording.finishedPath()
+	app.closeSession(s)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("recording file kept after the session closed: %v", err)
+	}
+}
+
+func TestReplayLoadRejectsOtherFiles(t *testing.T) {
+	app, r, _, _ := setupScreenHistoryTest(t)
+	r.POST("/replay/load", app.ReplayLoadHandler)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("recording", "workflow.json")
+	part.Write([]byte(`{"Host":"example"}`))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/replay/load", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("replay load of a workflow = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+	"golang.org/x/net/websocket"
+)
+
+// screenWSPollInterval is how often a host that cannot signal screen
+// changes is asked for its screen, once per session however many sockets
+// watch it. Sockets also check their session and workflow status this often.
+const screenWSPollInterval = 500 * time.Millisecond
+
+// screenWatchStore holds the screen watch of each session with an open
+// screen socket. The zero value is ready to use.
+type screenWatchStore struct {
+	mu      sync.Mutex
+	watches map[*session.Session]*screenWatch
+}
+
+// screenWatch follows one session's host screen for all of its sockets. A
+// host implementing host.ScreenNotifier is read when it signals a change;
+// any other host is read every screenWSPollInterval.
+type screenWatch struct {
+	s     *session.Session
+	users int
+	stop  chan struct{}
+
+	mu      sync.Mutex
+	changed chan struct{}
+	err     error
+}
+
+// subscribe returns the session's watch, starting it for the first socket.
+// Each subscribe must be paired with unsubscribe.
+func (st *screenWatchStore) subscribe(app *App, s *session.Session) *screenWatch {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.watches == nil {
+		st.watches = make(map[*session.Session]*screenWatch)
+	}
+	w := st.watches[s]
+	if w == nil {
+		w = &screenWatch{s: s, stop: make(chan struct{}), changed: make(chan struct{})}
+		st.watches[s] = w
+		go w.run(app)
+	}
+	w.users++
+	return w
+}
+
+// unsubscribe stops the watch once its last socket has closed.
+func (st *screenWatchStore) unsubscribe(w *screenWatch) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if w.users--; w.users == 0 {
+		close(w.stop)
+		delete(st.watches, w.s)
+	}
+}
+
+// next returns a channel closed when the screen next changes, and the
+// error of the latest host read.
+func (w *screenWatch) next() (<-chan struct{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.changed, w.err
+}
+
+func (w *screenWatch) broadcast(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+func (w *screenWatch) run(app *App) {
+	notifier, _ := w.s.Host.(host.ScreenNotifier)
+	ticker := time.NewTicker(screenWSPollInterval)
+	defer ticker.Stop()
+	last := ""
+	for {
+		var hostChanged <-chan struct{}
+		if notifier != nil {
+			hostChanged = notifier.ScreenChanged()
+		}
+		var tick <-chan time.Time
+		if hostChanged == nil {
+			tick = ticker.C
+		}
+		select {
+		case <-w.stop:
+			return
+		case <-hostChanged:
+		case <-tick:
+		}
+		var fingerprint string
+		err := w.s.Host.UpdateScreen()
+		if err != nil {
+			err = fmt.Errorf("Update screen failed: %v", err)
+			fingerprint = err.Error()
+		} else {
+			app.observeScreen(w.s)
+			fingerprint = app.renderCurrentScreenTo(w.s, "/submit", false).HTML
+		}
+		if fingerprint != last {
+			last = fingerprint
+			w.broadcast(err)
+		}
+	}
+}
+
+// screenWSInbound is a message sent by the browser over /screen/ws.
+//
+// A "submit" message carries the same values as the screen form (field_X_Y,
+// cursor_row, cursor_col, field) plus the action key, and is applied exactly
+// like POST /submit/async.
+type screenWSInbound struct {
+	Type   string            `json:"type"`
+	ID     int               `json:"id,omitempty"`
+	Key    string            `json:"key,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// screenWSOutbound is a message pushed to the browser over /screen/ws.
+type screenWSOutbound struct {
+	Type      string `json:"type"`
+	Ack       int    `json:"ack,omitempty"`
+	HTML      string `json:"html,omitempty"`
+	CursorRow *int   `json:"cursorRow,omitempty"`
+	CursorCol *int   `json:"cursorCol,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	PlaybackActive bool `json:"playbackActive"`
+	PlaybackPaused bool `json:"playbackPaused"`
+	PlaybackStep   int  `json:"playbackStep"`
+	ChaosActive    bool `json:"chaosActive"`
+	ChaosStepsRun  int  `json:"chaosStepsRun"`
+}
+
+// screenWSStatus is the workflow progress summary streamed alongside screens
+// so the status widget can refresh without polling.
+type screenWSStatus struct {
+	PlaybackActive bool
+	PlaybackPaused bool
+	PlaybackStep   int
+	ChaosActive    bool
+	ChaosStepsRun  int
+}
+
+func screenWSStatusSnapshot(s *session.Session) screenWSStatus {
+	chaosState := chaosStateSnapshot(s)
+	return screenWSStatus{
+		PlaybackActive: playbackActive(s),
+		PlaybackPaused: playbackPaused(s),
+		PlaybackStep:   playbackStepIndex(s),
+		ChaosActive:    chaosState != nil && chaosState.Active,
+		ChaosStepsRun:  chaosStateStepsRun(chaosState),
+	}
+}
+
+// screenWSViewer is the viewer of a shared session on the other end of a
+// screen socket.
+type screenWSViewer struct {
+	share  *screenShare
+	viewer *shareViewer
+}
+
+// ScreenWSHandler upgrades to a WebSocket that pushes the rendered screen
+// whenever it changes and accepts submit messages from the browser.
+func (app *App) ScreenWSHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	app.serveScreenSocket(c, s, nil)
+}
+
+// serveScreenSocket upgrades the request to a screen socket for s, as its
+// owner or, when w is set, as a viewer of its share.
+func (app *App) serveScreenSocket(c *gin.Context, s *session.Session, w *screenWSViewer) {
+	server := websocket.Server{
+		Handshake: checkScreenWSOrigin,
+		Handler: func(ws *websocket.Conn) {
+			app.serveScreenWS(ws, s, w)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkScreenWSOrigin rejects cross-site WebSocket handshakes. The upgrade is
+// a GET request, so OriginRefererCheckMiddleware does not cover it.
+func checkScreenWSOrigin(cfg *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	if !isValidHost(u.Host, req.Host) {
+		log.Printf("CSRF protection: WebSocket origin mismatch. Got %q, want %q", u.Host, req.Host)
+		return fmt.Errorf("origin mismatch")
+	}
+	cfg.Origin = u
+	return nil
+}
+
+func (app *App) serveScreenWS(ws *websocket.Conn, s *session.Session, w *screenWSViewer) {
+	defer ws.Close()
+
+	inbound := make(chan screenWSInbound)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(done)
+		for {
+			var msg screenWSInbound
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			select {
+			case inbound <- msg:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	watch := app.screenWatches.subscribe(app, s)
+	defer app.screenWatches.unsubscribe(watch)
+	ticker := time.NewTicker(screenWSPollInterval)
+	defer ticker.Stop()
+
+	lastHTML, lastError := "", ""
+	var lastStatus screenWSStatus
+	alive := func() bool {
+		if current, ok := app.SessionManager.PeekSession(s.ID); !ok || current != s || !w.stillShared(app) {
+			_ = websocket.JSON.Send(ws, screenWSOutbound{Type: "closed"})
+			return false
+		}
+		return true
+	}
+	// push sends the screen when it or the workflow status has changed, or
+	// always when force is set. A forced push reads the host; any other
+	// renders what the watch last read, or reports readErr, its read error.
+	push := func(ack int, force bool, readErr error) bool {
+		if !alive() {
+			return false
+		}
+		status := screenWSStatusSnapshot(s)
+		msg := screenWSOutbound{
+			Type:           "screen",
+			Ack:            ack,
+			PlaybackActive: status.PlaybackActive,
+			PlaybackPaused: status.PlaybackPaused,
+			PlaybackStep:   status.PlaybackStep,
+			ChaosActive:    status.ChaosActive,
+			ChaosStepsRun:  status.ChaosStepsRun,
+		}
+		var content screenContent
+		err := readErr
+		if force {
+			content, err = w.render(app, s)
+		} else if err == nil {
+			content = w.renderCurrent(app, s)
+		}
+		if err != nil {
+			if !force && err.Error() == lastError {
+				return true
+			}
+			lastError = err.Error()
+			msg.Type = "error"
+			msg.Error = lastError
+			return websocket.JSON.Send(ws, msg) == nil
+		}
+		lastError = ""
+		if !force && content.HTML == lastHTML {
+			if status == lastStatus {
+				return true
+			}
+			msg.Type = "status"
+		} else {
+			msg.HTML = content.HTML
+			if content.CursorOK {
+				row, col := content.CursorRow, content.CursorCol
+				msg.CursorRow, msg.CursorCol = &row, &col
+			}
+			lastHTML = content.HTML
+		}
+		lastStatus = status
+		return websocket.JSON.Send(ws, msg) == nil
+	}
+
+	if !push(0, true, nil) {
+		return
+	}
+	changed, _ := watch.next()
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-inbound:
+			if msg.Type != "submit" {
+				continue
+			}
+			s.Touch()
+			if err := app.processSubmitBy(s, w.shareViewer(), msg.formValue); err != nil {
+				reply := screenWSOutbound{Type: "error", Ack: msg.ID, Error: err.Error()}
+				if websocket.JSON.Send(ws, reply) != nil {
+					return
+				}
+				continue
+			}
+			if !push(msg.ID, true, nil) {
+				return
+			}
+		case <-changed:
+			var err error
+			changed, err = watch.next()
+			if !push(0, false, err) {
+				return
+			}
+		case <-ticker.C:
+			if !alive() {
+				return
+			}
+			if screenWSStatusSnapshot(s) == lastStatus {
+				continue
+			}
+			if _, err := watch.next(); !push(0, false, err) {
+				return
+			}
+		}
+	}
+}
+
+// stillShared reports whether the viewer's share is still open and the
+// viewer has not been dropped from it. It is always true for the owner's
+// socket.
+func (w *screenWSViewer) stillShared(app *App) bool {
+	if w == nil {
+		return true
+	}
+	current, ok := app.shares.get(w.share.token)
+	return ok && current == w.share && w.share.present(w.viewer, time.Now())
+}
+
+// render renders the screen with its form posting to the owner's or the
+// viewer's submit endpoint.
+func (w *screenWSViewer) render(app *App, s *session.Session) (screenContent, error) {
+	if w == nil {
+		return app.renderScreenContent(s)
+	}
+	return app.renderWatchContent(s, w.share)
+}
+
+// renderCurrent is render without asking the host for an update.
+func (w *screenWSViewer) renderCurrent(app *App, s *session.Session) screenContent {
+	if w == nil {
+		return app.renderCurrentScreenTo(s, "/submit", false)
+	}
+	return app.renderCurrentScreenTo(s, w.share.url()+"/submit", true)
+}
+
+func (w *screenWSViewer) shareViewer() *shareViewer {
+	if w == nil {
+		return nil
+	}
+	return w.viewer
+}
+
+func (m screenWSInbound) formValue(name string) string {
+	if name == "key" && m.Key != "" {
+		return m.Key
+	}
+	return m.Fields[name]
+}
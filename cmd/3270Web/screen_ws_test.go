@@ -0,0 +1,313 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/render"
+	"github.com/jnnngs/3270Web/internal/session"
+	"golang.org/x/net/websocket"
+)
+
+// keyNotifyHost reports keys on a channel and writes them to row 0 so the
+// stream has a screen change to push.
+type keyNotifyHost struct {
+	*host.MockHost
+	keys chan string
+}
+
+func (h *keyNotifyHost) SendKey(key string) error {
+	copy(h.Screen.Buffer[0][1:], []rune(key))
+	h.Screen.Fields[0].Value = ""
+	h.keys <- key
+	return nil
+}
+
+func setupScreenWSTest(t *testing.T) (*App, *httptest.Server, *session.Session, *keyNotifyHost) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	mockHost.Connected = true
+	mockHost.Screen.Fields = append(mockHost.Screen.Fields,
+		host.NewField(mockHost.Screen, host.AttrProtected, 1, 0, 79, 0, host.AttrColDefault, host.AttrEhDefault))
+	h := &keyNotifyHost{MockHost: mockHost, keys: make(chan string, 1)}
+
+	app := &App{
+		SessionManager: session.NewManager(),
+		Renderer:       render.NewHtmlRenderer(),
+		chaosEngines:   newChaosEngineStore(),
+	}
+	sess := app.SessionManager.CreateSession(h)
+
+	r := gin.New()
+	r.GET("/screen/ws", app.ScreenWSHandler)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return app, srv, sess, h
+}
+
+func dialScreenWS(srv *httptest.Server, sessionID, origin string) (*websocket.Conn, error) {
+	cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http")+"/screen/ws", origin)
+	if err != nil {
+		return nil, err
+	}
+	if sessionID != "" {
+		cfg.Header.Set("Cookie", "3270Web_session="+sessionID)
+	}
+	return websocket.DialConfig(cfg)
+}
+
+func receiveScreenWS(t *testing.T, ws *websocket.Conn) screenWSOutbound {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg screenWSOutbound
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	return msg
+}
+
+func TestScreenWSHandler_StreamsScreenAndAcceptsSubmit(t *testing.T) {
+	_, srv, sess, h := setupScreenWSTest(t)
+
+	ws, err := dialScreenWS(srv, sess.ID, srv.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+
+	initial := receiveScreenWS(t, ws)
+	if initial.Type != "screen" || !strings.Contains(initial.HTML, "<form") {
+		t.Fatalf("initial message = %+v, want rendered screen", initial)
+	}
+
+	submit := screenWSInbound{Type: "submit", ID: 7, Key: "PF3"}
+	if err := websocket.JSON.Send(ws, submit); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	select {
+	case key := <-h.keys:
+		if key != "PF(3)" {
+			t.Fatalf("SendKey = %q, want %q", key, "PF(3)")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for key")
+	}
+
+	reply := receiveScreenWS(t, ws)
+	if reply.Type != "screen" || reply.Ack != 7 {
+		t.Fatalf("reply = type %q ack %d, want screen ack 7", reply.Type, reply.Ack)
+	}
+	if !strings.Contains(reply.HTML, "PF(3)") {
+		t.Fatalf("reply html does not contain updated screen: %q", reply.HTML)
+	}
+}
+
+func TestScreenWSHandler_ClosesWhenSessionRemoved(t *testing.T) {
+	app, srv, sess, _ := setupScreenWSTest(t)
+
+	ws, err := dialScreenWS(srv, sess.ID, srv.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	receiveScreenWS(t, ws)
+
+	app.SessionManager.RemoveSession(sess.ID)
+	if msg := receiveScreenWS(t, ws); msg.Type != "closed" {
+		t.Fatalf("message after removal = %q, want closed", msg.Type)
+	}
+}
+
+func TestWatchWSHandler_ClosesWhenViewerDropped(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app, _, sess, _ := setupScreenWSTest(t)
+	app.shares = newShareStore()
+	sh, err := newScreenShare(sess.ID, "alice", false, time.Now(), nil)
+	if err != nil {
+		t.Fatalf("newScreenShare: %v", err)
+	}
+	app.shares.set(sh)
+	v, err := sh.join("", "Guest", "192.0.2.1", time.Now())
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	r := gin.New()
+	r.GET("/watch/:token/ws", app.WatchWSHandler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http")+sh.url()+"/ws", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Header.Set("Cookie", shareViewerCookie+"="+v.key)
+	ws, err := websocket.DialConfig(cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	if msg := receiveScreenWS(t, ws); msg.Type != "screen" {
+		t.Fatalf("initial message = %q, want screen", msg.Type)
+	}
+
+	sh.mu.Lock()
+	v.lastSeen = time.Now().Add(-2 * shareViewerTimeout)
+	sh.mu.Unlock()
+	if msg := receiveScreenWS(t, ws); msg.Type != "closed" {
+		t.Fatalf("message after the viewer was dropped = %q, want closed", msg.Type)
+	}
+	if st := sh.Status(time.Now()); len(st.Viewers) != 0 || st.Events[len(st.Events)-1].Type != shareEventLeft {
+		t.Fatalf("share = %+v, want the viewer recorded as left", st)
+	}
+}
+
+func TestScreenWSHandler_RejectsInvalidRequests(t *testing.T) {
+	_, srv, sess, _ := setupScreenWSTest(t)
+
+	tests := []struct {
+		name      string
+		sessionID string
+		origin    string
+	}{
+		{name: "missing session", origin: srv.URL},
+		{name: "unknown session", sessionID: "missing", origin: srv.URL},
+		{name: "cross origin", sessionID: sess.ID, origin: "http://evil.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := dialScreenWS(srv, tt.sessionID, tt.origin)
+			if err == nil {
+				ws.Close()
+				t.Fatal("dial succeeded, want handshake failure")
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/screen/ws", nil)
+	if err := checkScreenWSOrigin(&websocket.Config{}, req); err != nil {
+		t.Fatalf("checkScreenWSOrigin without Origin = %v, want nil", err)
+	}
+}
+
+// countingHost counts screen reads. When notify is set it implements
+// host.ScreenNotifier with a channel the test closes.
+type countingHost struct {
+	*host.MockHost
+	mu      sync.Mutex
+	updates int
+	changed chan struct{}
+}
+
+func (h *countingHost) UpdateScreen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.updates++
+	return nil
+}
+
+func (h *countingHost) reads() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.updates
+}
+
+type notifyingHost struct{ *countingHost }
+
+func (h notifyingHost) ScreenChanged() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.changed
+}
+
+func (h notifyingHost) notify(row string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	copy(h.Screen.Buffer[0][1:], []rune(row))
+	h.Screen.Fields[0].Value = ""
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+func setupCountingScreenWSTest(t *testing.T, notify bool) (*httptest.Server, *session.Session, *countingHost, host.Host) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	mockHost.Connected = true
+	mockHost.Screen.Fields = append(mockHost.Screen.Fields,
+		host.NewField(mockHost.Screen, host.AttrProtected, 1, 0, 79, 0, host.AttrColDefault, host.AttrEhDefault))
+	counting := &countingHost{MockHost: mockHost, changed: make(chan struct{})}
+	var h host.Host = counting
+	if notify {
+		h = notifyingHost{counting}
+	}
+	app := &App{SessionManager: session.NewManager(), Renderer: render.NewHtmlRenderer(), chaosEngines: newChaosEngineStore()}
+	sess := app.SessionManager.CreateSession(h)
+	r := gin.New()
+	r.GET("/screen/ws", app.ScreenWSHandler)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, sess, counting, h
+}
+
+func TestScreenWSHandler_PushesHostNotifications(t *testing.T) {
+	srv, sess, counting, h := setupCountingScreenWSTest(t, true)
+	var sockets []*websocket.Conn
+	for i := 0; i < 2; i++ {
+		ws, err := dialScreenWS(srv, sess.ID, srv.URL)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer ws.Close()
+		receiveScreenWS(t, ws)
+		sockets = append(sockets, ws)
+	}
+
+	before := counting.reads()
+	time.Sleep(3 * screenWSPollInterval)
+	if got := counting.reads(); got != before {
+		t.Fatalf("host read %d times without a change notification", got-before)
+	}
+
+	h.(notifyingHost).notify("RECORD ARRIVED")
+	for _, ws := range sockets {
+		if msg := receiveScreenWS(t, ws); msg.Type != "screen" || !strings.Contains(msg.HTML, "RECORD ARRIVED") {
+			t.Fatalf("message after notification = %+v, want the new screen", msg)
+		}
+	}
+	if got := counting.reads() - before; got != 1 {
+		t.Fatalf("host read %d times for one notification, want once for both sockets", got)
+	}
+}
+
+func TestScreenWSHandler_PollsOncePerSession(t *testing.T) {
+	srv, sess, counting, _ := setupCountingScreenWSTest(t, false)
+	for i := 0; i < 3; i++ {
+		ws, err := dialScreenWS(srv, sess.ID, srv.URL)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer ws.Close()
+		receiveScreenWS(t, ws)
+	}
+
+	before := counting.reads()
+	time.Sleep(4 * screenWSPollInterval)
+	// Polling per socket would read about 12 times.
+	if got := counting.reads() - before; got < 2 || got > 6 {
+		t.Fatalf("host read %d times in four poll intervals with three sockets, want about four", got)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jnnngs/3270Web/internal/secrets"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// secretsKeyEnv holds the passphrase for the local secrets file. Without it
+// secrets can only come from APP_SECRET_<NAME> environment variables.
+const secretsKeyEnv = "APP_SECRETS_KEY"
+
+// secretVault opens the secrets file on first use and keeps it open until the
+// passphrase changes.
+type secretVault struct {
+	path  string
+	mu    sync.Mutex
+	key   string
+	store *secrets.Store
+	// claimed holds names handed out by claimName, which may not be
+	// stored yet.
+	claimed map[string]bool
+}
+
+func newSecretVault(path string) *secretVault {
+	return &secretVault{path: path}
+}
+
+func (v *secretVault) open() (*secrets.Store, error) {
+	if v == nil {
+		return nil, secrets.ErrNoKey
+	}
+	key := os.Getenv(secretsKeyEnv)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.store != nil && v.key == key {
+		return v.store, nil
+	}
+	store, err := secrets.Open(v.path, key)
+	if err != nil {
+		return nil, err
+	}
+	v.key = key
+	v.store = store
+	return store, nil
+}
+
+// lookup returns the named secret from its environment variable, falling
+// back to the secrets file.
+func (v *secretVault) lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(secrets.EnvName(name)); ok {
+		return value, true
+	}
+	store, err := v.open()
+	if err != nil {
+		if !errors.Is(err, secrets.ErrNoKey) {
+			log.Printf("Warning: secrets file unavailable: %v", err)
+		}
+		return "", false
+	}
+	return store.Get(name)
+}
+
+// claimName returns name, or name with a numeric suffix, for a hidden value
+// about to be recorded. The name is free in the secrets file, in the
+// APP_SECRET_<NAME> variables and among earlier claims, so recordings never
+// replace each other's values. taken rejects names the caller already uses.
+// Without a secrets file nothing is stored, and only taken applies.
+func (v *secretVault) claimName(name string, taken func(name string) bool) string {
+	store, err := v.open()
+	if err != nil {
+		return secrets.UniqueName(name, taken)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	name = secrets.UniqueName(name, func(candidate string) bool {
+		if taken(candidate) || v.claimed[candidate] {
+			return true
+		}
+		if _, ok := os.LookupEnv(secrets.EnvName(candidate)); ok {
+			return true
+		}
+		_, ok := store.Get(candidate)
+		return ok
+	})
+	if v.claimed == nil {
+		v.claimed = make(map[string]bool)
+	}
+	v.claimed[name] = true
+	return name
+}
+
+// resolveWorkflowSecrets replaces ${secret:NAME} placeholders in a step's
+// text just before it is sent to the host.
+func (app *App) resolveWorkflowSecrets(step session.WorkflowStep) (session.WorkflowStep, error) {
+	if !secrets.HasPlaceholder(step.Text) {
+		return step, nil
+	}
+	text, err := secrets.Resolve(step.Text, app.secrets.lookup)
+	if err != nil {
+		return step, err
+	}
+	step.Text = text
+	return step, nil
+}
+
+// resolvePlaybackSecrets resolves a step's placeholders for playback in s.
+// Playback that carries its own secrets, such as a chaos run replay, never
+// falls back to the user's environment or secrets file.
+func (app *App) resolvePlaybackSecrets(s *session.Session, step session.WorkflowStep) (session.WorkflowStep, error) {
+	var values map[string]string
+	withSessionLock(s, func() {
+		if s.Playback != nil {
+			values = s.Playback.Secrets
+		}
+	})
+	if values == nil {
+		return app.resolveWorkflowSecrets(step)
+	}
+	if !secrets.HasPlaceholder(step.Text) {
+		return step, nil
+	}
+	text, err := secrets.Resolve(step.Text, func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	})
+	if err != nil {
+		return step, err
+	}
+	step.Text = text
+	return step, nil
+}
+
+// storeRecordedSecrets saves hidden-field values captured while recording, or
+// generated by a chaos run, so playback on this install resolves them without
+// further setup. Secrets already in the file are kept as they are.
+func (app *App) storeRecordedSecrets(captured map[string]string) {
+	if len(captured) == 0 {
+		return
+	}
+	store, err := app.secrets.open()
+	if err != nil {
+		names := make([]string, 0, len(captured))
+		for name := range captured {
+			names = append(names, name)
+		}
+		log.Printf("Recorded hidden input as secret placeholders (%s) without saving the values: %v", strings.Join(names, ", "), err)
+		return
+	}
+	existing, err := store.AddAll(captured)
+	if err != nil {
+		log.Printf("Warning: could not save secrets: %v", err)
+		return
+	}
+	if len(existing) > 0 {
+		log.Printf("Warning: kept the stored secrets %s rather than replacing them with recorded values", strings.Join(existing, ", "))
+	}
+}
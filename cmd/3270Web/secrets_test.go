@@ -0,0 +1,144 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func TestRecordFieldUpdatesRecordsHiddenInputAsSecret(t *testing.T) {
+	s, mockHost := newCheckStepSession(t)
+	s.Recording = &session.WorkflowRecording{Active: true}
+	for _, f := range mockHost.Screen.Fields[1:] {
+		f.Changed = true
+	}
+
+	app := &App{}
+	captured := app.recordFieldUpdates(s)
+	if len(s.Recording.Steps) != 2 {
+		t.Fatalf("recorded %d steps, want 2", len(s.Recording.Steps))
+	}
+	if got := s.Recording.Steps[0].Text; got != "ALICE" {
+		t.Fatalf("visible field Text = %q, want ALICE", got)
+	}
+	if got := s.Recording.Steps[1].Text; got != "${secret:PASSWD}" {
+		t.Fatalf("hidden field Text = %q, want ${secret:PASSWD}", got)
+	}
+	if len(captured) != 1 || captured["PASSWD"] != "SECRET" {
+		t.Fatalf("captured = %v, want PASSWD=SECRET", captured)
+	}
+}
+
+func TestRecordFieldUpdatesNamesSharedLabelsApart(t *testing.T) {
+	s, mockHost := newCheckStepSession(t)
+	s.Recording = &session.WorkflowRecording{Active: true}
+	screen := mockHost.Screen
+	copy(screen.Buffer[6], []rune("PASSWD NEWPASS"))
+	copy(screen.Buffer[7], []rune("CONFIRM PASSWD NEWPASS"))
+	screen.Fields = append(screen.Fields,
+		host.NewField(screen, host.AttrDisp1|host.AttrDisp2, 7, 6, 14, 6, host.AttrColDefault, host.AttrEhDefault),
+		host.NewField(screen, host.AttrDisp1|host.AttrDisp2, 15, 7, 22, 7, host.AttrColDefault, host.AttrEhDefault),
+	)
+	for _, f := range screen.Fields[2:] {
+		f.Changed = true
+	}
+
+	app := &App{}
+	captured := app.recordFieldUpdates(s)
+	var texts []string
+	for _, step := range s.Recording.Steps {
+		texts = append(texts, step.Text)
+	}
+	want := []string{"${secret:PASSWD}", "${secret:PASSWD_2}", "${secret:CONFIRM_PASSWD}"}
+	if strings.Join(texts, " ") != strings.Join(want, " ") {
+		t.Fatalf("recorded %v, want %v", texts, want)
+	}
+	if captured["PASSWD"] != "SECRET" || captured["PASSWD_2"] != "NEWPASS" || captured["CONFIRM_PASSWD"] != "NEWPASS" {
+		t.Fatalf("captured = %v, want each hidden field under its own name", captured)
+	}
+}
+
+func TestRecordFieldUpdatesKeepsStoredSecrets(t *testing.T) {
+	t.Setenv(secretsKeyEnv, "passphrase")
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), secrets: newSecretVault(filepath.Join(t.TempDir(), "secrets.json"))}
+	app.storeRecordedSecrets(map[string]string{"PASSWD": "older-workflow"})
+
+	var texts []string
+	for i := 0; i < 2; i++ {
+		s, mockHost := newCheckStepSession(t)
+		s.Recording = &session.WorkflowRecording{Active: true}
+		mockHost.Screen.Fields[2].Changed = true
+		app.storeRecordedSecrets(app.recordFieldUpdates(s))
+		texts = append(texts, s.Recording.Steps[0].Text)
+	}
+	want := []string{"${secret:PASSWD_2}", "${secret:PASSWD_3}"}
+	if strings.Join(texts, " ") != strings.Join(want, " ") {
+		t.Fatalf("recorded %v, want names apart from the stored PASSWD", texts)
+	}
+	for name, value := range map[string]string{"PASSWD": "older-workflow", "PASSWD_2": "SECRET", "PASSWD_3": "SECRET"} {
+		if got, _ := app.secrets.lookup(name); got != value {
+			t.Fatalf("secret %s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestStoreRecordedSecretsAndResolve(t *testing.T) {
+	t.Setenv(secretsKeyEnv, "passphrase")
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore(), secrets: newSecretVault(filepath.Join(t.TempDir(), "secrets.json"))}
+	app.storeRecordedSecrets(map[string]string{"PASSWD": "from-file"})
+
+	step := session.WorkflowStep{Type: "FillString", Text: "${secret:PASSWD}"}
+	resolved, err := app.resolveWorkflowSecrets(step)
+	if err != nil || resolved.Text != "from-file" {
+		t.Fatalf("resolve from file = %q, %v, want from-file", resolved.Text, err)
+	}
+
+	t.Setenv("APP_SECRET_PASSWD", "from-env")
+	if resolved, _ = app.resolveWorkflowSecrets(step); resolved.Text != "from-env" {
+		t.Fatalf("resolve with env = %q, want the environment to win", resolved.Text)
+	}
+
+	_, err = app.resolveWorkflowSecrets(session.WorkflowStep{Type: "FillString", Text: "${secret:OTHER}"})
+	if err == nil || !strings.Contains(err.Error(), "APP_SECRET_OTHER") {
+		t.Fatalf("missing secret error = %v, want a hint naming APP_SECRET_OTHER", err)
+	}
+}
+
+func TestPlaybackFillsResolvedSecret(t *testing.T) {
+	t.Setenv("APP_SECRET_PASSWD", "TOPSECRET")
+	s, mockHost := newCheckStepSession(t)
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	step := session.WorkflowStep{
+		Type:        "FillString",
+		Coordinates: &session.WorkflowCoordinates{Row: 6, Column: 8},
+		Text:        "${secret:PASSWD}",
+	}
+	if err := app.applyWorkflowStep(s, step); err != nil {
+		t.Fatalf("applyWorkflowStep: %v", err)
+	}
+	if got := string(mockHost.Screen.Buffer[5][7:16]); got != "TOPSECRET" {
+		t.Fatalf("row 6 = %q, want the resolved secret", got)
+	}
+}
+
+func TestChaosReplaySecretsNeverUseUserSecrets(t *testing.T) {
+	t.Setenv("APP_SECRET_PASSWORD", "users-real-password")
+	s, mockHost := newCheckStepSession(t)
+	s.Playback.Secrets = map[string]string{"CHAOS_1A2B3C4D_1": "GENERATED"}
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	at := &session.WorkflowCoordinates{Row: 6, Column: 8}
+
+	if err := app.applyWorkflowStep(s, session.WorkflowStep{Type: "FillString", Coordinates: at, Text: "${secret:CHAOS_1A2B3C4D_1}"}); err != nil {
+		t.Fatalf("applyWorkflowStep: %v", err)
+	}
+	if got := string(mockHost.Screen.Buffer[5][7:16]); got != "GENERATED" {
+		t.Fatalf("row 6 = %q, want the value the run generated", got)
+	}
+	err := app.applyWorkflowStep(s, session.WorkflowStep{Type: "FillString", Coordinates: at, Text: "${secret:PASSWORD}"})
+	if err == nil {
+		t.Fatal("chaos replay resolved a placeholder from the user's secrets")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const (
+	// sessionIdleTimeoutEnv sets the idle timeout in minutes; 0 disables expiry.
+	sessionIdleTimeoutEnv     = "APP_SESSION_IDLE_TIMEOUT_MIN"
+	defaultSessionIdleTimeout = 30 * time.Minute
+	sessionReapInterval       = 30 * time.Second
+	sessionExpiryWarning      = 2 * time.Minute
+)
+
+// sessionIdleTimeout returns the configured idle timeout, or 0 when expiry is
+// disabled.
+func sessionIdleTimeout() time.Duration {
+	raw := strings.TrimSpace(os.Getenv(sessionIdleTimeoutEnv))
+	if raw == "" {
+		return defaultSessionIdleTimeout
+	}
+	minutes, err := strconv.ParseFloat(raw, 64)
+	if err != nil || minutes < 0 {
+		log.Printf("Warning: invalid %s=%q; using %s", sessionIdleTimeoutEnv, raw, defaultSessionIdleTimeout)
+		return defaultSessionIdleTimeout
+	}
+	return time.Duration(minutes * float64(time.Minute))
+}
+
+// sessionExpiryWarningWindow is how long before expiry the browser warns the
+// user, capped at half the timeout so short timeouts still leave idle time.
+func sessionExpiryWarningWindow(timeout time.Duration) time.Duration {
+	if half := timeout / 2; half < sessionExpiryWarning {
+		return half
+	}
+	return sessionExpiryWarning
+}
+
+// runSessionReaper expires idle sessions until stop is closed.
+func (app *App) runSessionReaper(stop <-chan struct{}) {
+	ticker := time.NewTicker(sessionReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			app.reapIdleSessions(now)
+		}
+	}
+}
+
+// reapIdleSessions closes every session idle for longer than the timeout and
+// returns how many were closed.
+func (app *App) reapIdleSessions(now time.Time) int {
+	timeout := sessionIdleTimeout()
+	if timeout <= 0 {
+		return 0
+	}
+	idle := app.SessionManager.IdleSessions(timeout, now)
+	for _, s := range idle {
+		log.Printf("Expiring session for %s after %s idle", sessionTargetLabel(s), s.IdleFor(now).Round(time.Second))
+		app.closeSession(s)
+	}
+	return len(idle)
+}
+
+func sessionTargetLabel(s *session.Session) string {
+	label := ""
+	withSessionLock(s, func() {
+		label = s.TargetHost
+		if s.TargetPort > 0 {
+			label += ":" + strconv.Itoa(s.TargetPort)
+		}
+	})
+	if label == "" {
+		return "unknown host"
+	}
+	return label
+}
+
+// peekSession looks up the request's session without counting the request as
+// user activity. Background polling uses it so an unattended tab still expires.
+func (app *App) peekSession(c *gin.Context) *session.Session {
+	id, err := c.Cookie("3270Web_session")
+	if err != nil {
+		return nil
+	}
+	s, ok := app.SessionManager.PeekSession(id)
+	if !ok || !browserMayUse(s, app.currentUserName(c)) {
+		return nil
+	}
+	return s
+}
+
+// SessionStatusHandler reports how long the session has before it expires.
+func (app *App) SessionStatusHandler(c *gin.Context) {
+	s := app.peekSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found", "expired": true})
+		return
+	}
+	c.JSON(http.StatusOK, sessionExpiryStatus(s, time.Now()))
+}
+
+// SessionKeepAliveHandler records activity so the session does not expire.
+func (app *App) SessionKeepAliveHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found", "expired": true})
+		return
+	}
+	c.JSON(http.StatusOK, sessionExpiryStatus(s, time.Now()))
+}
+
+func sessionExpiryStatus(s *session.Session, now time.Time) gin.H {
+	timeout := sessionIdleTimeout()
+	if timeout <= 0 {
+		return gin.H{"idleTimeoutSec": 0}
+	}
+	remaining := timeout - s.IdleFor(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return gin.H{
+		"idleTimeoutSec": int(timeout.Seconds()),
+		"expiresInSec":   int(remaining.Seconds()),
+		"warningSec":     int(sessionExpiryWarningWindow(timeout).Seconds()),
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func TestSessionIdleTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: defaultSessionIdleTimeout},
+		{value: "0", want: 0},
+		{value: "5", want: 5 * time.Minute},
+		{value: "0.5", want: 30 * time.Second},
+		{value: "-1", want: defaultSessionIdleTimeout},
+		{value: "soon", want: defaultSessionIdleTimeout},
+	}
+	for _, tt := range tests {
+		t.Setenv(sessionIdleTimeoutEnv, tt.value)
+		if got := sessionIdleTimeout(); got != tt.want {
+			t.Fatalf("sessionIdleTimeout(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestReapIdleSessions(t *testing.T) {
+	t.Setenv(sessionIdleTimeoutEnv, "10")
+
+	idleHost, _ := host.NewMockHost("")
+	idleHost.Connected = true
+	activeHost, _ := host.NewMockHost("")
+	activeHost.Connected = true
+
+	app := &App{
+		SessionManager: session.NewManager(),
+		chaosEngines:   newChaosEngineStore(),
+	}
+	idle := app.SessionManager.CreateSession(idleHost)
+	active := app.SessionManager.CreateSession(activeHost)
+	withSessionLock(idle, func() {
+		idle.Playback = &session.WorkflowPlayback{Active: true}
+	})
+
+	now := time.Now().Add(11 * time.Minute)
+	withSessionLock(active, func() {
+		active.LastAccess = now.Add(-time.Minute)
+	})
+
+	if got := app.reapIdleSessions(now); got != 1 {
+		t.Fatalf("reapIdleSessions = %d, want 1", got)
+	}
+	if _, ok := app.SessionManager.PeekSession(idle.ID); ok {
+		t.Fatal("idle session still registered")
+	}
+	if idleHost.Connected {
+		t.Fatal("idle session host was not stopped")
+	}
+	withSessionLock(idle, func() {
+		if !idle.Playback.StopRequested {
+			t.Error("idle session playback was not stopped")
+		}
+	})
+	if _, ok := app.SessionManager.PeekSession(active.ID); !ok {
+		t.Fatal("active session was reaped")
+	}
+
+	t.Setenv(sessionIdleTimeoutEnv, "0")
+	if got := app.reapIdleSessions(now.Add(time.Hour)); got != 0 {
+		t.Fatalf("reapIdleSessions with expiry disabled = %d, want 0", got)
+	}
+}
+
+func TestSessionStatusHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv(sessionIdleTimeoutEnv, "10")
+
+	mockHost, _ := host.NewMockHost("")
+	app := &App{
+		SessionManager: session.NewManager(),
+		chaosEngines:   newChaosEngineStore(),
+	}
+	sess := app.SessionManager.CreateSession(mockHost)
+	stale := time.Now().Add(-9 * time.Minute)
+	withSessionLock(sess, func() {
+		sess.LastAccess = stale
+	})
+
+	r := gin.New()
+	r.GET("/session/status", app.SessionStatusHandler)
+	r.POST("/session/keepalive", app.SessionKeepAliveHandler)
+
+	get := func(method, path string) (int, map[string]int) {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(&http.Cookie{Name: "3270Web_session", Value: sess.ID})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var body map[string]int
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	code, body := get(http.MethodGet, "/session/status")
+	if code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", code, http.StatusOK)
+	}
+	if body["idleTimeoutSec"] != 600 || body["warningSec"] != 120 || body["expiresInSec"] > 60 {
+		t.Fatalf("status = %v, want about a minute left of 600s", body)
+	}
+	withSessionLock(sess, func() {
+		if !sess.LastAccess.Equal(stale) {
+			t.Error("status poll counted as activity")
+		}
+	})
+
+	code, body = get(http.MethodPost, "/session/keepalive")
+	if code != http.StatusOK || body["expiresInSec"] < 590 {
+		t.Fatalf("keepalive = %d %v, want refreshed expiry", code, body)
+	}
+
+	app.SessionManager.RemoveSession(sess.ID)
+	if code, _ := get(http.MethodGet, "/session/status"); code != http.StatusUnauthorized {
+		t.Fatalf("expired status code = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// A browser can hold several terminal sessions, shown as tabs. The
+// 3270Web_session cookie names the active one and 3270Web_sessions lists
+// them all in tab order. Switching tabs only moves the active cookie, so
+// each session keeps its own recording, playback and chaos state.
+const (
+	sessionListCookie  = "3270Web_sessions"
+	maxBrowserSessions = 8
+	maxSessionNameLen  = 40
+)
+
+var errTooManySessions = fmt.Errorf("this browser already has %d sessions open; close one first", maxBrowserSessions)
+
+// errSessionSwitched rejects a screen form rendered for another session,
+// which happens when a second browser tab switched sessions since.
+var errSessionSwitched = errors.New("this page shows a session that is no longer active; reload to continue")
+
+// sessionTab is one tab of the session switcher.
+type sessionTab struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	Active    bool   `json:"active"`
+	Recording bool   `json:"recording,omitempty"`
+	Playback  bool   `json:"playback,omitempty"`
+	Chaos     bool   `json:"chaos,omitempty"`
+}
+
+func parseSessionList(raw string) []string {
+	var ids []string
+	for _, id := range strings.Split(raw, ".") {
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// browserSessions returns the sessions this browser has open, in tab order.
+// Sessions that have closed, belong to another signed-in user or belong to
+// the API are left out.
+func (app *App) browserSessions(c *gin.Context) []*session.Session {
+	raw, _ := c.Cookie(sessionListCookie)
+	ids := parseSessionList(raw)
+	if id, err := c.Cookie("3270Web_session"); err == nil && id != "" && !slices.Contains(ids, id) {
+		ids = append(ids, id)
+	}
+	user := app.currentUserName(c)
+	var sessions []*session.Session
+	for _, id := range ids {
+		s, ok := app.SessionManager.PeekSession(id)
+		if !ok || !browserMayUse(s, user) {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// currentUserName returns the signed-in user's name, or "" without sign-in.
+func (app *App) currentUserName(c *gin.Context) string {
+	if u := app.currentUser(c); u != nil {
+		return u.Name
+	}
+	return ""
+}
+
+// browserMayUse reports whether a browser signed in as user, or not signed
+// in when user is empty, may use s. API sessions are never reachable from a
+// browser, and a signed-in user only reaches their own sessions.
+func browserMayUse(s *session.Session, user string) bool {
+	ok := false
+	withSessionLock(s, func() {
+		ok = !s.APIOwned && (user == "" || s.User == user)
+	})
+	return ok
+}
+
+// browserSession returns the session with id when this browser has it open.
+func (app *App) browserSession(c *gin.Context, id string) *session.Session {
+	for _, s := range app.browserSessions(c) {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func setBrowserSessions(c *gin.Context, sessions []*session.Session) {
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.ID
+	}
+	setSessionCookie(c, sessionListCookie, strings.Join(ids, "."))
+}
+
+// cleanSessionName trims a session name and drops control characters.
+func cleanSessionName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxSessionNameLen {
+		name = strings.TrimSpace(string(runes[:maxSessionNameLen]))
+	}
+	return name
+}
+
+// uniqueSessionName numbers name when another open session already uses
+// it, so two sessions on the same host get separate tabs.
+func uniqueSessionName(sessions []*session.Session, name string) string {
+	taken := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		withSessionLock(s, func() {
+			taken[s.Name] = true
+		})
+	}
+	candidate := name
+	for n := 2; taken[candidate]; n++ {
+		candidate = fmt.Sprintf("%s (%d)", name, n)
+	}
+	return candidate
+}
+
+// sessionTabs describes the browser's sessions for the switcher.
+func (app *App) sessionTabs(c *gin.Context, active *session.Session) []sessionTab {
+	sessions := app.browserSessions(c)
+	tabs := make([]sessionTab, 0, len(sessions))
+	for _, s := range sessions {
+		tab := sessionTab{
+			ID:     s.ID,
+			Host:   sessionTargetLabel(s),
+			Active: active != nil && s.ID == active.ID,
+		}
+		withSessionLock(s, func() {
+			tab.Name = s.Name
+			tab.Recording = s.Recording != nil && s.Recording.Active
+			tab.Playback = s.Playback != nil && s.Playback.Active
+		})
+		if tab.Name == "" {
+			tab.Name = tab.Host
+		}
+		if eng, ok := app.chaosEngines.get(s.ID); ok && eng.Status().Active {
+			tab.Chaos = true
+		}
+		tabs = append(tabs, tab)
+	}
+	return tabs
+}
+
+// closeBrowserSession closes s and removes its tab. When s was the active
+// session, its neighbour takes over; the browser goes to the connect page
+// once no session is left.
+func (app *App) closeBrowserSession(c *gin.Context, s *session.Session) {
+	sessions := app.browserSessions(c)
+	active := app.peekSession(c)
+	if s != nil {
+		app.closeSession(s)
+		i := slices.IndexFunc(sessions, func(o *session.Session) bool { return o.ID == s.ID })
+		if i >= 0 {
+			sessions = slices.Delete(sessions, i, i+1)
+		}
+		if active != nil && active.ID == s.ID {
+			active = nil
+			if len(sessions) > 0 {
+				active = sessions[min(max(i, 0), len(sessions)-1)]
+			}
+		}
+	}
+	if active == nil && len(sessions) > 0 {
+		active = sessions[0]
+	}
+	setBrowserSessions(c, sessions)
+	if active == nil {
+		setSessionCookie(c, "3270Web_session", "")
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	setSessionCookie(c, "3270Web_session", active.ID)
+	c.Redirect(http.StatusFound, "/screen")
+}
+
+// SessionsHandler handles GET /sessions, the tabs of the session switcher.
+func (app *App) SessionsHandler(c *gin.Context) {
+	active := app.peekSession(c)
+	activeID := ""
+	if active != nil {
+		activeID = active.ID
+	}
+	c.JSON(http.StatusOK, gin.H{"active": activeID, "sessions": app.sessionTabs(c, active)})
+}
+
+// SessionSwitchHandler handles POST /sessions/switch, making another of the
+// browser's sessions the active one.
+func (app *App) SessionSwitchHandler(c *gin.Context) {
+	s := app.browserSession(c, c.PostForm("id"))
+	if s == nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{"Error": "That session is no longer open."})
+		return
+	}
+	s.Touch()
+	setSessionCookie(c, "3270Web_session", s.ID)
+	c.Redirect(http.StatusFound, "/screen")
+}
+
+// SessionRenameHandler handles POST /sessions/rename.
+func (app *App) SessionRenameHandler(c *gin.Context) {
+	s := app.browserSession(c, c.PostForm("id"))
+	if s == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	name := cleanSessionName(c.PostForm("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enter a session name"})
+		return
+	}
+	withSessionLock(s, func() {
+		s.Name = name
+	})
+	c.JSON(http.StatusOK, gin.H{"name": name})
+}
+
+// SessionCloseHandler handles POST /sessions/close, disconnecting one of
+// the browser's sessions.
+func (app *App) SessionCloseHandler(c *gin.Context) {
+	s := app.browserSession(c, c.PostForm("id"))
+	if s == nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{"Error": "That session is no longer open."})
+		return
+	}
+	app.closeBrowserSession(c, s)
+}
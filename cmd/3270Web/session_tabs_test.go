@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/render"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// tabsBrowser keeps cookies by name, like a browser.
+type tabsBrowser struct {
+	t       *testing.T
+	r       *gin.Engine
+	cookies map[string]string
+}
+
+func (b *tabsBrowser) do(method, target string, form url.Values) *httptest.ResponseRecorder {
+	b.t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for name, value := range b.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	w := httptest.NewRecorder()
+	b.r.ServeHTTP(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(b.cookies, cookie.Name)
+		} else {
+			b.cookies[cookie.Name] = cookie.Value
+		}
+	}
+	return w
+}
+
+func newTabsSession(t *testing.T, app *App, name string) *session.Session {
+	t.Helper()
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	mockHost.Connected = true
+	s := app.SessionManager.CreateSession(mockHost)
+	s.Name = name
+	s.TargetHost = "mainframe.example.com"
+	return s
+}
+
+func TestSessionTabs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := &App{
+		SessionManager: session.NewManager(),
+		Renderer:       render.NewHtmlRenderer(),
+		chaosEngines:   newChaosEngineStore(),
+	}
+	first := newTabsSession(t, app, "Payroll")
+	second := newTabsSession(t, app, "Payroll (2)")
+
+	r := gin.New()
+	r.LoadHTMLGlob(filepath.Join("..", "..", "web", "templates", "*.html"))
+	r.POST("/submit/async", app.SubmitAsyncHandler)
+	r.POST("/disconnect", app.DisconnectHandler)
+	r.GET("/sessions", app.SessionsHandler)
+	r.POST("/sessions/switch", app.SessionSwitchHandler)
+	r.POST("/sessions/rename", app.SessionRenameHandler)
+	r.POST("/sessions/close", app.SessionCloseHandler)
+
+	b := &tabsBrowser{t: t, r: r, cookies: map[string]string{
+		"3270Web_session": second.ID,
+		sessionListCookie: first.ID + "." + second.ID + ".gone",
+	}}
+
+	var list struct {
+		Active   string       `json:"active"`
+		Sessions []sessionTab `json:"sessions"`
+	}
+	if err := json.Unmarshal(b.do(http.MethodGet, "/sessions", nil).Body.Bytes(), &list); err != nil {
+		t.Fatalf("GET /sessions: %v", err)
+	}
+	if list.Active != second.ID || len(list.Sessions) != 2 || list.Sessions[0].Name != "Payroll" || !list.Sessions[1].Active {
+		t.Fatalf("sessions = %+v, want both tabs with the second active", list)
+	}
+
+	w := b.do(http.MethodPost, "/sessions/switch", url.Values{"id": {first.ID}})
+	if w.Code != http.StatusFound || b.cookies["3270Web_session"] != first.ID {
+		t.Fatalf("switch = %d, active %q, want the first session", w.Code, b.cookies["3270Web_session"])
+	}
+	if w := b.do(http.MethodPost, "/submit/async", url.Values{"key": {"Enter"}, "TERMINAL": {second.ID}}); w.Code != http.StatusConflict {
+		t.Fatalf("submit from a page of the other session = %d, want 409", w.Code)
+	}
+	if w := b.do(http.MethodPost, "/sessions/switch", url.Values{"id": {"unknown"}}); w.Code != http.StatusNotFound {
+		t.Fatalf("switch to an unknown session = %d, want 404", w.Code)
+	}
+
+	b.do(http.MethodPost, "/sessions/rename", url.Values{"id": {first.ID}, "name": {"  Payroll\tprod  "}})
+	if first.Name != "Payrollprod" {
+		t.Fatalf("renamed to %q, want control characters dropped", first.Name)
+	}
+	if got := uniqueSessionName([]*session.Session{first, second}, "Payroll (2)"); got != "Payroll (2) (2)" {
+		t.Fatalf("uniqueSessionName = %q", got)
+	}
+
+	w = b.do(http.MethodPost, "/disconnect", url.Values{})
+	if _, ok := app.SessionManager.PeekSession(first.ID); ok {
+		t.Fatal("disconnect left the active session open")
+	}
+	if w.Header().Get("Location") != "/screen" || b.cookies["3270Web_session"] != second.ID || b.cookies[sessionListCookie] != second.ID {
+		t.Fatalf("after disconnect: location %q, cookies %v, want the remaining tab active", w.Header().Get("Location"), b.cookies)
+	}
+
+	w = b.do(http.MethodPost, "/sessions/close", url.Values{"id": {second.ID}})
+	if w.Header().Get("Location") != "/" || len(b.cookies) != 0 {
+		t.Fatalf("after closing the last tab: location %q, cookies %v, want the connect page", w.Header().Get("Location"), b.cookies)
+	}
+}
+
+func TestSessionCookieOnlyReachesOwnSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := &App{
+		SessionManager: session.NewManager(),
+		Renderer:       render.NewHtmlRenderer(),
+		chaosEngines:   newChaosEngineStore(),
+	}
+	alice := newTabsSession(t, app, "Alice")
+	alice.User = "alice"
+	bob := newTabsSession(t, app, "Bob")
+	bob.User = "bob"
+	api := newTabsSession(t, app, "API")
+	api.User = "api"
+	api.APIOwned = true
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if name := c.GetHeader("X-Test-User"); name != "" {
+			c.Set(authContextKey, &authUser{Name: name})
+		}
+	})
+	r.POST("/submit/async", app.SubmitAsyncHandler)
+	r.GET("/session/status", app.SessionStatusHandler)
+
+	tests := []struct {
+		name string
+		user string
+		s    *session.Session
+		want int
+	}{
+		{"own session", "bob", bob, http.StatusOK},
+		{"another user's session", "bob", alice, http.StatusUnauthorized},
+		{"API session", "bob", api, http.StatusUnauthorized},
+		{"API session without sign-in", "", api, http.StatusUnauthorized},
+		{"without sign-in", "", alice, http.StatusOK},
+	}
+	for _, tt := range tests {
+		for _, target := range []string{"/submit/async", "/session/status"} {
+			method := http.MethodPost
+			if target == "/session/status" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, target, strings.NewReader("key=Enter"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-Test-User", tt.user)
+			req.AddCookie(&http.Cookie{Name: "3270Web_session", Value: tt.s.ID})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("%s: %s %s = %d, want %d", tt.name, method, target, w.Code, tt.want)
+			}
+		}
+	}
+}
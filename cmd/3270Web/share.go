@@ -0,0 +1,716 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const (
+	// shareViewerCookie identifies a viewer's browser to a shared session.
+	// It is scoped to the share's /watch path.
+	shareViewerCookie = "3270Web_viewer"
+	// shareViewerTimeout drops viewers whose page has stopped polling.
+	shareViewerTimeout = 30 * time.Second
+	maxShareEvents     = 200
+)
+
+// Share event types, recorded in the order they happen.
+const (
+	shareEventStarted   = "share-started"
+	shareEventStopped   = "share-stopped"
+	shareEventJoined    = "viewer-joined"
+	shareEventLeft      = "viewer-left"
+	shareEventRequested = "control-requested"
+	shareEventGranted   = "control-granted"
+	shareEventDeclined  = "control-declined"
+	shareEventReturned  = "control-returned"
+	shareEventReclaimed = "control-reclaimed"
+)
+
+// errNoKeyboardControl rejects input from whoever does not hold the
+// keyboard of a shared session.
+var errNoKeyboardControl = errors.New("keyboard control is with someone else")
+
+// shareStore tracks the share of each browser session, by session and by
+// share token.
+type shareStore struct {
+	mu        sync.Mutex
+	byToken   map[string]*screenShare
+	bySession map[string]*screenShare
+}
+
+func newShareStore() *shareStore {
+	return &shareStore{byToken: make(map[string]*screenShare), bySession: make(map[string]*screenShare)}
+}
+
+func (s *shareStore) get(token string) (*screenShare, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sh, ok := s.byToken[token]
+	return sh, ok
+}
+
+func (s *shareStore) forSession(sessionID string) (*screenShare, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sh, ok := s.bySession[sessionID]
+	return sh, ok
+}
+
+func (s *shareStore) set(sh *screenShare) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byToken[sh.token] = sh
+	s.bySession[sh.sessionID] = sh
+}
+
+func (s *shareStore) delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sh, ok := s.bySession[sessionID]; ok {
+		delete(s.byToken, sh.token)
+		delete(s.bySession, sessionID)
+	}
+}
+
+// screenShare lets viewers with the share link watch a session and, when
+// allowed, take over its keyboard. The owner holds the keyboard unless
+// controller names a viewer.
+type screenShare struct {
+	mu           sync.Mutex
+	token        string
+	sessionID    string
+	owner        string
+	allowControl bool
+	created      time.Time
+	viewers      map[string]*shareViewer
+	nextViewer   int
+	controller   *shareViewer
+	events       []shareEvent
+	// logEvent, when set, persists each event. events only keeps the
+	// latest maxShareEvents, and is dropped when the share stops.
+	logEvent func(shareEvent)
+}
+
+// shareViewer is one browser watching a share. key is the viewer's cookie
+// and is never shown to anyone else; id names the viewer in the dialogs.
+type shareViewer struct {
+	id        string
+	key       string
+	name      string
+	addr      string
+	joined    time.Time
+	lastSeen  time.Time
+	requested bool
+}
+
+type shareEvent struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Viewer string    `json:"viewer,omitempty"`
+}
+
+type shareViewerStatus struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Joined    time.Time `json:"joined"`
+	Requested bool      `json:"requested"`
+	Control   bool      `json:"control"`
+}
+
+// shareStatus is the owner's view of a share.
+type shareStatus struct {
+	URL          string              `json:"url"`
+	AllowControl bool                `json:"allowControl"`
+	Created      time.Time           `json:"created"`
+	Controller   string              `json:"controller,omitempty"`
+	Viewers      []shareViewerStatus `json:"viewers"`
+	Events       []shareEvent        `json:"events"`
+}
+
+// shareWatchStatus is a viewer's view of a share. It names the other
+// viewers but does not identify them.
+type shareWatchStatus struct {
+	Owner        string       `json:"owner"`
+	Name         string       `json:"name"`
+	AllowControl bool         `json:"allowControl"`
+	Control      bool         `json:"control"`
+	Requested    bool         `json:"requested"`
+	Controller   string       `json:"controller,omitempty"`
+	Viewers      []string     `json:"viewers"`
+	Events       []shareEvent `json:"events"`
+}
+
+func newScreenShare(sessionID, owner string, allowControl bool, now time.Time, logEvent func(shareEvent)) (*screenShare, error) {
+	token, err := newAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	sh := &screenShare{
+		token:        token,
+		sessionID:    sessionID,
+		owner:        owner,
+		allowControl: allowControl,
+		created:      now,
+		viewers:      make(map[string]*shareViewer),
+		logEvent:     logEvent,
+	}
+	sh.recordLocked(now, shareEventStarted, nil)
+	return sh, nil
+}
+
+func (sh *screenShare) url() string {
+	return "/watch/" + sh.token
+}
+
+// recordLocked appends an event, keeping the most recent maxShareEvents.
+func (sh *screenShare) recordLocked(at time.Time, kind string, v *shareViewer) {
+	e := shareEvent{Time: at, Type: kind}
+	if v != nil {
+		e.Viewer = v.name
+	}
+	if sh.logEvent != nil {
+		sh.logEvent(e)
+	}
+	sh.events = append(sh.events, e)
+	if len(sh.events) > maxShareEvents {
+		sh.events = append(sh.events[:0:0], sh.events[len(sh.events)-maxShareEvents:]...)
+	}
+}
+
+// join returns the viewer with key, adding a new viewer named name when the
+// key is unknown or empty.
+func (sh *screenShare) join(key, name, addr string, now time.Time) (*shareViewer, error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if v, ok := sh.viewers[key]; ok && key != "" {
+		v.lastSeen = now
+		return v, nil
+	}
+	newKey, err := newAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	sh.nextViewer++
+	if name == "" {
+		name = "Guest " + strconv.Itoa(sh.nextViewer)
+	}
+	v := &shareViewer{
+		id:       "v" + strconv.Itoa(sh.nextViewer),
+		key:      newKey,
+		name:     name,
+		addr:     addr,
+		joined:   now,
+		lastSeen: now,
+	}
+	sh.viewers[newKey] = v
+	sh.recordLocked(now, shareEventJoined, v)
+	return v, nil
+}
+
+// viewer returns the present viewer with key and marks it seen.
+func (sh *screenShare) viewer(key string, now time.Time) (*shareViewer, bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.pruneLocked(now)
+	v, ok := sh.viewers[key]
+	if ok {
+		v.lastSeen = now
+	}
+	return v, ok
+}
+
+// pruneLocked drops viewers that stopped polling, returning the keyboard
+// to the owner if one of them held it.
+func (sh *screenShare) pruneLocked(now time.Time) {
+	for key, v := range sh.viewers {
+		if now.Sub(v.lastSeen) <= shareViewerTimeout {
+			continue
+		}
+		delete(sh.viewers, key)
+		sh.recordLocked(now, shareEventLeft, v)
+		if sh.controller == v {
+			sh.controller = nil
+			sh.recordLocked(now, shareEventReturned, v)
+		}
+	}
+}
+
+// present reports whether v is still watching, first dropping viewers that
+// stopped polling.
+func (sh *screenShare) present(v *shareViewer, now time.Time) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.pruneLocked(now)
+	return sh.viewers[v.key] == v
+}
+
+func (sh *screenShare) viewerByID(id string) *shareViewer {
+	for _, v := range sh.viewers {
+		if v.id == id {
+			return v
+		}
+	}
+	return nil
+}
+
+// requestControl asks the owner for the keyboard on behalf of v.
+func (sh *screenShare) requestControl(v *shareViewer, now time.Time) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if !sh.allowControl {
+		return errors.New("this share is view-only")
+	}
+	if sh.controller == v || v.requested {
+		return nil
+	}
+	v.requested = true
+	sh.recordLocked(now, shareEventRequested, v)
+	return nil
+}
+
+// grant hands the keyboard to the viewer with id.
+func (sh *screenShare) grant(id string, now time.Time) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if !sh.allowControl {
+		return errors.New("this share is view-only")
+	}
+	v := sh.viewerByID(id)
+	if v == nil {
+		return errors.New("viewer has left")
+	}
+	if sh.controller == v {
+		return nil
+	}
+	if sh.controller != nil {
+		sh.recordLocked(now, shareEventReclaimed, sh.controller)
+	}
+	v.requested = false
+	sh.controller = v
+	sh.recordLocked(now, shareEventGranted, v)
+	return nil
+}
+
+// decline turns down the request of the viewer with id.
+func (sh *screenShare) decline(id string, now time.Time) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	v := sh.viewerByID(id)
+	if v == nil {
+		return errors.New("viewer has left")
+	}
+	if v.requested {
+		v.requested = false
+		sh.recordLocked(now, shareEventDeclined, v)
+	}
+	return nil
+}
+
+// release gives the keyboard back to the owner if v holds it.
+func (sh *screenShare) release(v *shareViewer, now time.Time) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.controller == v {
+		sh.controller = nil
+		sh.recordLocked(now, shareEventReturned, v)
+	}
+}
+
+// reclaim takes the keyboard back for the owner.
+func (sh *screenShare) reclaim(now time.Time) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.controller != nil {
+		sh.recordLocked(now, shareEventReclaimed, sh.controller)
+		sh.controller = nil
+	}
+}
+
+// canType reports whether v, or the owner when v is nil, holds the keyboard.
+func (sh *screenShare) canType(v *shareViewer) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.controller == v
+}
+
+func (sh *screenShare) Status(now time.Time) shareStatus {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.pruneLocked(now)
+	st := shareStatus{
+		URL:          sh.url(),
+		AllowControl: sh.allowControl,
+		Created:      sh.created,
+		Viewers:      make([]shareViewerStatus, 0, len(sh.viewers)),
+		Events:       append([]shareEvent(nil), sh.events...),
+	}
+	if sh.controller != nil {
+		st.Controller = sh.controller.name
+	}
+	for _, v := range sh.sortedViewersLocked() {
+		st.Viewers = append(st.Viewers, shareViewerStatus{
+			ID:        v.id,
+			Name:      v.name,
+			Joined:    v.joined,
+			Requested: v.requested,
+			Control:   sh.controller == v,
+		})
+	}
+	return st
+}
+
+func (sh *screenShare) watchStatus(v *shareViewer) shareWatchStatus {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	st := shareWatchStatus{
+		Owner:        sh.owner,
+		Name:         v.name,
+		AllowControl: sh.allowControl,
+		Control:      sh.controller == v,
+		Requested:    v.requested,
+		Viewers:      []string{},
+		Events:       append([]shareEvent(nil), sh.events...),
+	}
+	if sh.controller != nil {
+		st.Controller = sh.controller.name
+	}
+	for _, other := range sh.sortedViewersLocked() {
+		st.Viewers = append(st.Viewers, other.name)
+	}
+	return st
+}
+
+// sortedViewersLocked lists viewers in the order they joined.
+func (sh *screenShare) sortedViewersLocked() []*shareViewer {
+	out := make([]*shareViewer, 0, len(sh.viewers))
+	for _, v := range sh.viewers {
+		out = append(out, v)
+	}
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0 && out[j].joined.Before(out[j-1].joined); j-- {
+			out[j], out[j-1] = out[j-1], out[j]
+		}
+	}
+	return out
+}
+
+// checkKeyboardControl rejects input to a shared session from anyone but
+// the holder of its keyboard. v is the viewer typing, or nil for the owner.
+func (app *App) checkKeyboardControl(s *session.Session, v *shareViewer) error {
+	if app.shares == nil {
+		return nil
+	}
+	sh, ok := app.shares.forSession(s.ID)
+	if !ok {
+		if v != nil {
+			return errNoKeyboardControl
+		}
+		return nil
+	}
+	if !sh.canType(v) {
+		return errNoKeyboardControl
+	}
+	return nil
+}
+
+// automationRunning names the workflow playback, chaos run or file transfer
+// driving s, or returns "" when there is none. The keyboard stays with the
+// owner while one runs.
+func (app *App) automationRunning(s *session.Session) string {
+	playing := false
+	withSessionLock(s, func() {
+		playing = s.Playback != nil && s.Playback.Active
+	})
+	if playing {
+		return "workflow playback"
+	}
+	if app.chaosEngines != nil {
+		if eng, ok := app.chaosEngines.get(s.ID); ok && eng.Status().Active {
+			return "chaos run"
+		}
+	}
+	if app.transfers != nil {
+		if tr, ok := app.transfers.get(s.ID); ok && tr.Active() {
+			return "file transfer"
+		}
+	}
+	return ""
+}
+
+// stopShare ends the session's share, disconnecting its viewers.
+func (app *App) stopShare(s *session.Session) {
+	if app.shares == nil {
+		return
+	}
+	if sh, ok := app.shares.forSession(s.ID); ok {
+		sh.mu.Lock()
+		sh.recordLocked(time.Now(), shareEventStopped, nil)
+		sh.mu.Unlock()
+		app.shares.delete(s.ID)
+	}
+}
+
+func (app *App) ownerShare(c *gin.Context, peek bool) (*session.Session, *screenShare, bool) {
+	s := app.getSession(c)
+	if peek {
+		s = app.peekSession(c)
+	}
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return nil, nil, false
+	}
+	sh, _ := app.shares.forSession(s.ID)
+	return s, sh, true
+}
+
+func (app *App) writeShareStatus(c *gin.Context, sh *screenShare) {
+	if sh == nil {
+		c.JSON(http.StatusOK, gin.H{"share": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"share": sh.Status(time.Now())})
+}
+
+// ShareStartHandler handles POST /share/start, creating the session's share
+// link. allowControl lets viewers ask for the keyboard. Starting again
+// replaces the link and disconnects earlier viewers.
+func (app *App) ShareStartHandler(c *gin.Context) {
+	s, _, ok := app.ownerShare(c, false)
+	if !ok {
+		return
+	}
+	app.stopShare(s)
+	var owner string
+	withSessionLock(s, func() {
+		owner = s.User
+	})
+	if owner == "" {
+		owner = "the session owner"
+	}
+	sh, err := newScreenShare(s.ID, owner, c.PostForm("allowControl") == "on", time.Now(), app.shareEventLogger(s))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("create share link: %v", err)})
+		return
+	}
+	app.shares.set(sh)
+	app.writeShareStatus(c, sh)
+}
+
+// ShareStopHandler handles POST /share/stop.
+func (app *App) ShareStopHandler(c *gin.Context) {
+	s, _, ok := app.ownerShare(c, false)
+	if !ok {
+		return
+	}
+	app.stopShare(s)
+	app.writeShareStatus(c, nil)
+}
+
+// ShareStatusHandler handles GET /share/status for the share dialog.
+func (app *App) ShareStatusHandler(c *gin.Context) {
+	_, sh, ok := app.ownerShare(c, true)
+	if !ok {
+		return
+	}
+	app.writeShareStatus(c, sh)
+}
+
+// ShareControlHandler handles POST /share/grant, /share/decline and
+// /share/reclaim, the owner's answers to control requests.
+func (app *App) ShareControlHandler(c *gin.Context) {
+	s, sh, ok := app.ownerShare(c, false)
+	if !ok {
+		return
+	}
+	if sh == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this session is not shared"})
+		return
+	}
+	now := time.Now()
+	var err error
+	switch action := c.Param("action"); action {
+	case "grant":
+		if running := app.automationRunning(s); running != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "wait for the " + running + " to finish before handing over the keyboard"})
+			return
+		}
+		err = sh.grant(c.PostForm("viewer"), now)
+	case "decline":
+		err = sh.decline(c.PostForm("viewer"), now)
+	case "reclaim":
+		sh.reclaim(now)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown share action"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	app.writeShareStatus(c, sh)
+}
+
+// watchTarget resolves the share and session named by the /watch path.
+func (app *App) watchTarget(c *gin.Context) (*screenShare, *session.Session, bool) {
+	sh, ok := app.shares.get(c.Param("token"))
+	if !ok {
+		return nil, nil, false
+	}
+	s, ok := app.SessionManager.PeekSession(sh.sessionID)
+	if !ok {
+		return nil, nil, false
+	}
+	return sh, s, true
+}
+
+// watchViewer resolves the viewer making a /watch request, writing an error
+// response when the share or viewer is gone.
+func (app *App) watchViewer(c *gin.Context) (*screenShare, *session.Session, *shareViewer, bool) {
+	sh, s, ok := app.watchTarget(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "this session is no longer shared"})
+		return nil, nil, nil, false
+	}
+	key, _ := c.Cookie(shareViewerCookie)
+	v, ok := sh.viewer(key, time.Now())
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "reload the page to watch this session again"})
+		return nil, nil, nil, false
+	}
+	return sh, s, v, true
+}
+
+// WatchHandler handles GET /watch/:token, the page viewers open from a
+// share link. It joins the viewer to the share and shows the live screen.
+func (app *App) WatchHandler(c *gin.Context) {
+	sh, s, ok := app.watchTarget(c)
+	if !ok {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{"Error": "This session is no longer shared. Ask for a new link."})
+		return
+	}
+	name := app.currentUserName(c)
+	key, _ := c.Cookie(shareViewerCookie)
+	v, err := sh.join(key, name, c.ClientIP(), time.Now())
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"Error": fmt.Sprintf("Join shared session failed: %v", err)})
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(shareViewerCookie, v.key, 0, sh.url(), "", c.Request.TLS != nil, true)
+
+	content, err := app.renderWatchContent(s, sh)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"Error": err.Error()})
+		return
+	}
+	snap := app.snapshotSession(s)
+	c.HTML(http.StatusOK, "watch.html", gin.H{
+		"ScreenContent": content.HTML,
+		"ThemeCSS":      app.buildThemeCSS(snap.Prefs),
+		"WatchURL":      sh.url(),
+		"Owner":         sh.owner,
+		"Host":          sessionTargetLabel(s),
+	})
+}
+
+// renderWatchContent renders the shared screen with its form posting back
+// to the share, so a viewer with the keyboard never touches their own
+// session.
+func (app *App) renderWatchContent(s *session.Session, sh *screenShare) (screenContent, error) {
+	return app.renderScreenContentTo(s, sh.url()+"/submit", true)
+}
+
+// WatchStatusHandler handles GET /watch/:token/status, polled by viewers.
+func (app *App) WatchStatusHandler(c *gin.Context) {
+	sh, _, v, ok := app.watchViewer(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"share": sh.watchStatus(v)})
+}
+
+// WatchContentHandler handles GET /watch/:token/content, the viewer's
+// fallback when the screen socket is closed.
+func (app *App) WatchContentHandler(c *gin.Context) {
+	sh, s, _, ok := app.watchViewer(c)
+	if !ok {
+		return
+	}
+	content, err := app.renderWatchContent(s, sh)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeScreenContent(c, content)
+}
+
+// WatchSubmitHandler handles POST /watch/:token/submit, the screen form of
+// the viewer holding the keyboard.
+func (app *App) WatchSubmitHandler(c *gin.Context) {
+	sh, s, v, ok := app.watchViewer(c)
+	if !ok {
+		return
+	}
+	s.Touch()
+	if err := app.processSubmitBy(s, v, c.PostForm); err != nil {
+		c.HTML(http.StatusConflict, "error.html", gin.H{"Error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, sh.url())
+}
+
+// WatchSubmitAsyncHandler handles POST /watch/:token/submit/async, the
+// viewer's fallback when the screen socket is closed.
+func (app *App) WatchSubmitAsyncHandler(c *gin.Context) {
+	_, s, v, ok := app.watchViewer(c)
+	if !ok {
+		return
+	}
+	s.Touch()
+	if err := app.processSubmitBy(s, v, c.PostForm); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// WatchControlHandler handles POST /watch/:token/control/:action, where
+// action is request or release: a viewer asking for or handing back the
+// keyboard.
+func (app *App) WatchControlHandler(c *gin.Context) {
+	sh, _, v, ok := app.watchViewer(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	switch c.Param("action") {
+	case "request":
+		if err := sh.requestControl(v, now); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	case "release":
+		sh.release(v, now)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown share action"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"share": sh.watchStatus(v)})
+}
+
+// WatchWSHandler handles GET /watch/:token/ws, the viewer's screen stream.
+func (app *App) WatchWSHandler(c *gin.Context) {
+	sh, s, v, ok := app.watchViewer(c)
+	if !ok {
+		return
+	}
+	app.serveScreenSocket(c, s, &screenWSViewer{share: sh, viewer: v})
+}
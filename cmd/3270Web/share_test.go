@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/config"
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/render"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+type shareTestClient struct {
+	t       *testing.T
+	r       *gin.Engine
+	cookies []*http.Cookie
+}
+
+func (c *shareTestClient) do(method, target string, form url.Values, out any) int {
+	c.t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	c.r.ServeHTTP(w, req)
+	c.cookies = append(c.cookies, w.Result().Cookies()...)
+	if out != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			c.t.Fatalf("%s %s: %v", method, target, err)
+		}
+	}
+	return w.Code
+}
+
+func setupShareTest(t *testing.T) (*App, *shareTestClient, *shareTestClient, *host.MockHost) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	mockHost.Connected = true
+	copy(mockHost.Screen.Buffer[0], []rune("SIGN ON"))
+	app := &App{
+		SessionManager: session.NewManager(),
+		Renderer:       render.NewHtmlRenderer(),
+		Config:         &config.Config{},
+		themeCache:     make(map[string]string),
+		chaosEngines:   newChaosEngineStore(),
+		shares:         newShareStore(),
+		audit:          audit.NewLog(filepath.Join(t.TempDir(), "audit.jsonl")),
+	}
+	s := app.SessionManager.CreateSession(mockHost)
+	s.User = "alice"
+
+	r := gin.New()
+	r.LoadHTMLGlob(filepath.Join("..", "..", "web", "templates", "*.html"))
+	r.POST("/submit", app.SubmitHandler)
+	r.POST("/submit/async", app.SubmitAsyncHandler)
+	r.POST("/share/start", app.ShareStartHandler)
+	r.POST("/share/stop", app.ShareStopHandler)
+	r.GET("/share/status", app.ShareStatusHandler)
+	r.POST("/share/:action", app.ShareControlHandler)
+	r.GET("/watch/:token", app.WatchHandler)
+	r.GET("/watch/:token/status", app.WatchStatusHandler)
+	r.POST("/watch/:token/submit/async", app.WatchSubmitAsyncHandler)
+	r.POST("/watch/:token/control/:action", app.WatchControlHandler)
+
+	owner := &shareTestClient{t: t, r: r, cookies: []*http.Cookie{{Name: "3270Web_session", Value: s.ID}}}
+	viewer := &shareTestClient{t: t, r: r}
+	return app, owner, viewer, mockHost
+}
+
+func TestShareControlHandoff(t *testing.T) {
+	app, owner, viewer, mockHost := setupShareTest(t)
+
+	var started struct {
+		Share shareStatus `json:"share"`
+	}
+	if code := owner.do(http.MethodPost, "/share/start", url.Values{"allowControl": {"on"}}, &started); code != http.StatusOK {
+		t.Fatalf("start share = %d", code)
+	}
+	link := started.Share.URL
+	if !strings.HasPrefix(link, "/watch/") || !started.Share.AllowControl {
+		t.Fatalf("share = %+v, want a /watch link allowing control", started.Share)
+	}
+
+	if code := viewer.do(http.MethodGet, link, nil, nil); code != http.StatusOK {
+		t.Fatalf("GET %s = %d", link, code)
+	}
+	key := url.Values{"key": {"PF3"}}
+	if code := viewer.do(http.MethodPost, link+"/submit/async", key, nil); code != http.StatusConflict {
+		t.Fatalf("viewer submit without control = %d, want 409", code)
+	}
+	viewer.do(http.MethodPost, link+"/control/request", url.Values{}, nil)
+
+	var status struct {
+		Share shareStatus `json:"share"`
+	}
+	owner.do(http.MethodGet, "/share/status", nil, &status)
+	if len(status.Share.Viewers) != 1 || !status.Share.Viewers[0].Requested || status.Share.Viewers[0].Name != "Guest 1" {
+		t.Fatalf("viewers = %+v, want Guest 1 asking for control", status.Share.Viewers)
+	}
+	owner.do(http.MethodPost, "/share/grant", url.Values{"viewer": {status.Share.Viewers[0].ID}}, nil)
+
+	for _, target := range []string{"/submit", "/submit/async"} {
+		if code := owner.do(http.MethodPost, target, url.Values{"key": {"Enter"}}, nil); code != http.StatusConflict {
+			t.Fatalf("owner POST %s while viewer has control = %d, want 409", target, code)
+		}
+	}
+	if code := viewer.do(http.MethodPost, link+"/submit/async", key, nil); code != http.StatusOK {
+		t.Fatalf("viewer submit with control = %d, want 200", code)
+	}
+	if got := mockHost.Commands[len(mockHost.Commands)-1]; got != "key:PF(3)" {
+		t.Fatalf("last host command = %q, want the viewer's PF(3)", got)
+	}
+	events, _, _ := app.audit.Search(audit.Query{Source: audit.SourceTerminal}, 0)
+	if len(events) != 1 || events[0].User != "Guest 1" || events[0].AID != "PF(3)" {
+		t.Fatalf("audit = %+v, want PF(3) from Guest 1", events)
+	}
+
+	viewer.do(http.MethodPost, link+"/control/release", url.Values{}, nil)
+	if code := owner.do(http.MethodPost, "/submit/async", url.Values{"key": {"Enter"}}, nil); code != http.StatusOK {
+		t.Fatalf("owner submit after release = %d, want 200", code)
+	}
+
+	owner.do(http.MethodGet, "/share/status", nil, &status)
+	var kinds []string
+	for _, e := range status.Share.Events {
+		kinds = append(kinds, e.Type)
+	}
+	want := []string{shareEventStarted, shareEventJoined, shareEventRequested, shareEventGranted, shareEventReturned}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", kinds, want)
+	}
+
+	owner.do(http.MethodPost, "/share/stop", url.Values{}, nil)
+	if code := viewer.do(http.MethodGet, link+"/status", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("viewer status after stop = %d, want 404", code)
+	}
+
+	logged, _, _ := app.audit.Search(audit.Query{Source: audit.SourceShare}, 0)
+	kinds = nil
+	for i := len(logged) - 1; i >= 0; i-- {
+		kinds = append(kinds, logged[i].Share)
+	}
+	want = append(want, shareEventStopped)
+	if strings.Join(kinds, ",") != strings.Join(want, ",") || logged[0].User != "alice" || logged[0].Viewer != "" || logged[1].Viewer != "Guest 1" {
+		t.Fatalf("audited share events = %+v, want %v by alice", logged, want)
+	}
+}
+
+func TestShareHandoffBlocksOwnerAutomation(t *testing.T) {
+	app, owner, viewer, _ := setupShareTest(t)
+	owner.r.POST("/workflow/play", app.PlayWorkflowHandler)
+	owner.r.POST("/workflow/step", app.StepWorkflowHandler)
+	owner.r.POST("/chaos/start", app.ChaosStartHandler)
+	owner.r.POST("/transfer/upload", app.TransferUploadHandler)
+
+	var started struct {
+		Share shareStatus `json:"share"`
+	}
+	owner.do(http.MethodPost, "/share/start", url.Values{"allowControl": {"on"}}, &started)
+	link := started.Share.URL
+	viewer.do(http.MethodGet, link, nil, nil)
+	viewer.do(http.MethodPost, link+"/control/request", url.Values{}, nil)
+	var status struct {
+		Share shareStatus `json:"share"`
+	}
+	owner.do(http.MethodGet, "/share/status", nil, &status)
+	viewerID := status.Share.Viewers[0].ID
+	owner.do(http.MethodPost, "/share/grant", url.Values{"viewer": {viewerID}}, nil)
+
+	for _, target := range []string{"/workflow/play", "/workflow/step", "/chaos/start", "/transfer/upload"} {
+		if code := owner.do(http.MethodPost, target, url.Values{}, nil); code != http.StatusConflict {
+			t.Errorf("owner POST %s while a viewer has control = %d, want 409", target, code)
+		}
+	}
+
+	owner.do(http.MethodPost, "/share/reclaim", url.Values{}, nil)
+	s, _ := app.SessionManager.PeekSession(owner.cookies[0].Value)
+	withSessionLock(s, func() {
+		s.Playback = &session.WorkflowPlayback{Active: true}
+	})
+	viewer.do(http.MethodPost, link+"/control/request", url.Values{}, nil)
+	if code := owner.do(http.MethodPost, "/share/grant", url.Values{"viewer": {viewerID}}, nil); code != http.StatusConflict {
+		t.Fatalf("grant during workflow playback = %d, want 409", code)
+	}
+	owner.do(http.MethodGet, "/share/status", nil, &status)
+	if status.Share.Controller != "" {
+		t.Fatalf("controller = %q during playback, want the owner", status.Share.Controller)
+	}
+}
+
+func TestShareViewOnly(t *testing.T) {
+	_, owner, viewer, mockHost := setupShareTest(t)
+	screen := mockHost.Screen
+	copy(screen.Buffer[5], []rune("PASSWD SECRET"))
+	screen.IsFormatted = true
+	screen.Fields = []*host.Field{
+		host.NewField(screen, host.AttrProtected, 0, 0, 6, 0, host.AttrColDefault, host.AttrEhDefault),
+		host.NewField(screen, host.AttrDisp1|host.AttrDisp2, 7, 5, 14, 5, host.AttrColDefault, host.AttrEhDefault),
+	}
+	var started struct {
+		Share shareStatus `json:"share"`
+	}
+	owner.do(http.MethodPost, "/share/start", url.Values{}, &started)
+
+	req := httptest.NewRequest(http.MethodGet, started.Share.URL, nil)
+	w := httptest.NewRecorder()
+	viewer.r.ServeHTTP(w, req)
+	viewer.cookies = append(viewer.cookies, w.Result().Cookies()...)
+	page := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(page, "SIGN ON") {
+		t.Fatalf("GET %s = %d, want the shared screen", started.Share.URL, w.Code)
+	}
+	if strings.Contains(page, "SECRET") || strings.Contains(page, owner.cookies[0].Value) {
+		t.Fatal("watch page shows a hidden field or the owner's session ID")
+	}
+	if code := viewer.do(http.MethodPost, started.Share.URL+"/control/request", url.Values{}, nil); code != http.StatusConflict {
+		t.Fatalf("request control on a view-only share = %d, want 409", code)
+	}
+	if code := viewer.do(http.MethodGet, "/watch/unknown", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("unknown share link = %d, want 404", code)
+	}
+}
+
+func TestShareDropsIdleViewers(t *testing.T) {
+	now := time.Now()
+	sh, err := newScreenShare("s1", "alice", true, now, nil)
+	if err != nil {
+		t.Fatalf("newScreenShare: %v", err)
+	}
+	v, _ := sh.join("", "bob", "10.0.0.2", now)
+	if err := sh.grant(v.id, now); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	st := sh.Status(now.Add(shareViewerTimeout + time.Second))
+	if len(st.Viewers) != 0 || st.Controller != "" {
+		t.Fatalf("status = %+v, want the idle viewer gone and control back with the owner", st)
+	}
+	if !sh.canType(nil) {
+		t.Fatal("owner cannot type after the controlling viewer left")
+	}
+}
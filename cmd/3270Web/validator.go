@@ -42,6 +42,9 @@ func isValidHostname(hostname string) bool {
 	if _, port, ok := parseSampleAppHost(host); ok {
 		return port == 0 || isAllowedSampleAppPort(port)
 	}
+	if name, ok := parseProfileHost(host); ok {
+		return profileNamePattern.MatchString(name)
+	}
 
 	// Extract port, if present.
 	if strings.HasPrefix(host, "[") {
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+const (
+	defaultWorkflowWaitTimeout = 10 * time.Second
+	workflowWaitPollInterval   = 250 * time.Millisecond
+)
+
+// errPlaybackStopped reports that a stop request interrupted a waiting step.
+var errPlaybackStopped = errors.New("playback stopped")
+
+// isWorkflowCheckStep reports whether a step only inspects the screen rather
+// than sending input to the host.
+func isWorkflowCheckStep(stepType string) bool {
+	switch strings.TrimSpace(stepType) {
+	case "WaitForText", "AssertText", "AssertField", "AssertCursor":
+		return true
+	}
+	return false
+}
+
+// validateWorkflowCheckStep rejects check steps that could never pass, so a
+// broken recording fails on load rather than halfway through playback.
+func validateWorkflowCheckStep(step session.WorkflowStep) error {
+	stepType := strings.TrimSpace(step.Type)
+	if step.Regex != "" {
+		if _, err := regexp.Compile(step.Regex); err != nil {
+			return fmt.Errorf("invalid regex %q: %v", step.Regex, err)
+		}
+	}
+	if step.Coordinates != nil && (step.Coordinates.Row <= 0 || step.Coordinates.Column <= 0) {
+		return errors.New("coordinates must be 1-based positive values")
+	}
+	switch stepType {
+	case "WaitForText", "AssertText":
+		if step.Text == "" && step.Regex == "" {
+			return errors.New("Text or Regex is required")
+		}
+	case "AssertField", "AssertCursor":
+		if step.Coordinates == nil {
+			return errors.New("Coordinates are required")
+		}
+	}
+	if step.Timeout < 0 {
+		return errors.New("Timeout must not be negative")
+	}
+	return nil
+}
+
+// applyWorkflowCheck runs a check step against the current host screen.
+// WaitForText polls until the text appears or the step timeout elapses; the
+// assertions check once.
+func applyWorkflowCheck(s *session.Session, step session.WorkflowStep) error {
+	if err := validateWorkflowCheckStep(step); err != nil {
+		return err
+	}
+	if strings.TrimSpace(step.Type) != "WaitForText" {
+		if err := s.Host.UpdateScreen(); err != nil {
+			return err
+		}
+		return checkWorkflowScreen(s.Host.GetScreen(), step)
+	}
+
+	timeout := defaultWorkflowWaitTimeout
+	if step.Timeout > 0 {
+		timeout = time.Duration(step.Timeout * float64(time.Second))
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := s.Host.UpdateScreen(); err != nil {
+			return err
+		}
+		err := checkWorkflowScreen(s.Host.GetScreen(), step)
+		if err == nil {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("timed out after %s: %v", timeout, err)
+		}
+		if remaining > workflowWaitPollInterval {
+			remaining = workflowWaitPollInterval
+		}
+		if sleepCanceled(s, remaining) {
+			return errPlaybackStopped
+		}
+	}
+}
+
+// checkWorkflowScreen evaluates a check step against a screen and describes
+// the mismatch when the expectation is not met.
+func checkWorkflowScreen(screen *host.Screen, step session.WorkflowStep) error {
+	if screen == nil {
+		return errors.New("screen is unavailable")
+	}
+	switch strings.TrimSpace(step.Type) {
+	case "WaitForText", "AssertText":
+		return checkWorkflowText(screen, step)
+	case "AssertField":
+		return checkWorkflowField(screen, step)
+	case "AssertCursor":
+		return checkWorkflowCursor(screen, step)
+	}
+	return fmt.Errorf("unsupported workflow step type: %s", step.Type)
+}
+
+func checkWorkflowText(screen *host.Screen, step session.WorkflowStep) error {
+	if step.Coordinates == nil {
+		text := screen.Text()
+		if step.Regex != "" {
+			re, err := regexp.Compile(step.Regex)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(text) {
+				return fmt.Errorf("expected screen to match /%s/", step.Regex)
+			}
+			return nil
+		}
+		if !strings.Contains(text, step.Text) {
+			return fmt.Errorf("expected %q on screen", step.Text)
+		}
+		return nil
+	}
+
+	row := step.Coordinates.Row - 1
+	col := step.Coordinates.Column - 1
+	length := step.Coordinates.Length
+	if length <= 0 && step.Regex == "" {
+		length = len([]rune(step.Text))
+	}
+	found := screenRegion(screen, row, col, length)
+	if step.Regex != "" {
+		re, err := regexp.Compile(step.Regex)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(found) {
+			return fmt.Errorf("expected /%s/ at row %d, column %d, found %q",
+				step.Regex, step.Coordinates.Row, step.Coordinates.Column, found)
+		}
+		return nil
+	}
+	if found != step.Text {
+		return fmt.Errorf("expected %q at row %d, column %d, found %q",
+			step.Text, step.Coordinates.Row, step.Coordinates.Column, found)
+	}
+	return nil
+}
+
+func checkWorkflowField(screen *host.Screen, step session.WorkflowStep) error {
+	row := step.Coordinates.Row - 1
+	col := step.Coordinates.Column - 1
+	field := screen.GetFieldAt(col, row)
+	if field == nil {
+		return fmt.Errorf("no field at row %d, column %d", step.Coordinates.Row, step.Coordinates.Column)
+	}
+	value := strings.TrimSpace(strings.ReplaceAll(field.GetValue(), "\x00", " "))
+	found := fmt.Sprintf("%q", value)
+	if field.IsHidden() {
+		found = "a hidden value"
+	}
+	if step.Regex != "" {
+		re, err := regexp.Compile(step.Regex)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("expected field at row %d, column %d to match /%s/, found %s",
+				step.Coordinates.Row, step.Coordinates.Column, step.Regex, found)
+		}
+		return nil
+	}
+	if value != strings.TrimSpace(step.Text) {
+		return fmt.Errorf("expected field at row %d, column %d to be %q, found %s",
+			step.Coordinates.Row, step.Coordinates.Column, step.Text, found)
+	}
+	return nil
+}
+
+func checkWorkflowCursor(screen *host.Screen, step session.WorkflowStep) error {
+	row, col, ok := screen.StatusCursor()
+	if !ok {
+		return errors.New("cursor position is unavailable")
+	}
+	row++
+	col++
+	if row != step.Coordinates.Row || col != step.Coordinates.Column {
+		return fmt.Errorf("expected cursor at row %d, column %d, found row %d, column %d",
+			step.Coordinates.Row, step.Coordinates.Column, row, col)
+	}
+	return nil
+}
+
+// screenRegion returns length characters of one screen row starting at col
+// (0-based), or the rest of the row when length is not positive.
+func screenRegion(screen *host.Screen, row, col, length int) string {
+	if row < 0 || row >= len(screen.Buffer) {
+		return ""
+	}
+	line := screen.Buffer[row]
+	if col < 0 || col >= len(line) {
+		return ""
+	}
+	end := len(line)
+	if length > 0 && col+length < end {
+		end = col + length
+	}
+	region := append([]rune(nil), line[col:end]...)
+	for i, ch := range region {
+		if ch == 0 {
+			region[i] = ' '
+		}
+	}
+	return string(region)
+}
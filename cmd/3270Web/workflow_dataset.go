@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// WorkflowDataset supplies values for ${name} placeholders in step Text and
+// Regex. Playback runs the steps once per row. Rows holds JSON records; CSV
+// holds comma-separated text whose first line names the columns.
+type WorkflowDataset struct {
+	Rows []map[string]interface{} `json:"Rows,omitempty"`
+	CSV  string                   `json:"CSV,omitempty"`
+}
+
+var workflowPlaceholderPattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// records returns the dataset rows as column/value maps.
+func (d *WorkflowDataset) records() ([]map[string]string, error) {
+	if d == nil {
+		return nil, nil
+	}
+	if len(d.Rows) > 0 && strings.TrimSpace(d.CSV) != "" {
+		return nil, errors.New("dataset must use Rows or CSV, not both")
+	}
+	var rows []map[string]string
+	if strings.TrimSpace(d.CSV) != "" {
+		parsed, err := parseWorkflowDatasetCSV(d.CSV)
+		if err != nil {
+			return nil, err
+		}
+		rows = parsed
+	} else {
+		for i, record := range d.Rows {
+			row := make(map[string]string, len(record))
+			for name, value := range record {
+				text, err := workflowDatasetValue(value)
+				if err != nil {
+					return nil, fmt.Errorf("dataset row %d, column %q: %v", i+1, name, err)
+				}
+				row[name] = text
+			}
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("dataset has no rows")
+	}
+	return rows, nil
+}
+
+func parseWorkflowDatasetCSV(text string) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("dataset CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("dataset CSV is empty")
+	}
+	header := records[0]
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+		if header[i] == "" {
+			return nil, fmt.Errorf("dataset CSV column %d has no name", i+1)
+		}
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func workflowDatasetValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("value must be a string, number or boolean")
+}
+
+// workflowPlaceholders returns the placeholder names a step refers to.
+func workflowPlaceholders(step session.WorkflowStep) []string {
+	var names []string
+	for _, text := range []string{step.Text, step.Regex} {
+		for _, match := range workflowPlaceholderPattern.FindAllStringSubmatch(text, -1) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// validateWorkflowDataset checks the dataset parses and that every
+// placeholder in the steps names one of its columns.
+func validateWorkflowDataset(workflow *WorkflowConfig) error {
+	rows, err := workflow.Dataset.records()
+	if err != nil {
+		return err
+	}
+	for i, step := range workflow.Steps {
+		for _, name := range workflowPlaceholders(step) {
+			if rows == nil {
+				return fmt.Errorf("step %d (%s) uses ${%s} but the workflow has no Dataset", i+1, step.Type, name)
+			}
+			for j, row := range rows {
+				if _, ok := row[name]; !ok {
+					return fmt.Errorf("step %d (%s) uses ${%s} but dataset row %d has no such column", i+1, step.Type, name, j+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// expandWorkflowStep substitutes row values into a step's placeholders.
+// Values are quoted in Regex so they always match literally.
+func expandWorkflowStep(step session.WorkflowStep, row map[string]string) session.WorkflowStep {
+	if row == nil {
+		return step
+	}
+	expand := func(text string, quote bool) string {
+		return workflowPlaceholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+			value, ok := row[match[2:len(match)-1]]
+			if !ok {
+				return match
+			}
+			if quote {
+				return regexp.QuoteMeta(value)
+			}
+			return value
+		})
+	}
+	step.Text = expand(step.Text, false)
+	step.Regex = expand(step.Regex, true)
+	return step
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func TestWorkflowDatasetRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		dataset *WorkflowDataset
+		want    []map[string]string
+		wantErr bool
+	}{
+		{name: "none", dataset: nil, want: nil},
+		{
+			name:    "csv",
+			dataset: &WorkflowDataset{CSV: "user, pin\nalice,\"0042\"\nbob,7\n"},
+			want:    []map[string]string{{"user": "alice", "pin": "0042"}, {"user": "bob", "pin": "7"}},
+		},
+		{
+			name:    "json rows",
+			dataset: &WorkflowDataset{Rows: []map[string]interface{}{{"user": "alice", "pin": float64(42), "admin": true, "note": nil}}},
+			want:    []map[string]string{{"user": "alice", "pin": "42", "admin": "true", "note": ""}},
+		},
+		{name: "header only", dataset: &WorkflowDataset{CSV: "user\n"}, wantErr: true},
+		{name: "ragged csv", dataset: &WorkflowDataset{CSV: "user,pin\nalice\n"}, wantErr: true},
+		{name: "unnamed column", dataset: &WorkflowDataset{CSV: "user,\nalice,1\n"}, wantErr: true},
+		{name: "nested value", dataset: &WorkflowDataset{Rows: []map[string]interface{}{{"user": []interface{}{"a"}}}}, wantErr: true},
+		{name: "both", dataset: &WorkflowDataset{CSV: "user\nalice\n", Rows: []map[string]interface{}{{"user": "bob"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.dataset.records()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("records() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("records() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWorkflowPayloadValidatesPlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{
+			name:    "known column",
+			payload: `{"Dataset":{"CSV":"user\nalice\n"},"Steps":[{"Type":"FillString","Coordinates":{"Row":1,"Column":1},"Text":"${user}"}]}`,
+		},
+		{
+			name:    "no dataset",
+			payload: `{"Steps":[{"Type":"FillString","Coordinates":{"Row":1,"Column":1},"Text":"${user}"}]}`,
+			wantErr: "has no Dataset",
+		},
+		{
+			name:    "unknown column",
+			payload: `{"Dataset":{"Rows":[{"user":"alice"}]},"Steps":[{"Type":"AssertText","Regex":"HELLO ${name}"}]}`,
+			wantErr: "no such column",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWorkflowPayload([]byte(tt.payload))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseWorkflowPayload = %v, want nil", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("parseWorkflowPayload = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpandWorkflowStep(t *testing.T) {
+	step := session.WorkflowStep{Type: "AssertText", Text: "${user} / ${missing}", Regex: `^HELLO ${user}$`}
+	got := expandWorkflowStep(step, map[string]string{"user": "a.b"})
+	if got.Text != "a.b / ${missing}" {
+		t.Fatalf("Text = %q, want %q", got.Text, "a.b / ${missing}")
+	}
+	if got.Regex != `^HELLO a\.b$` {
+		t.Fatalf("Regex = %q, want %q", got.Regex, `^HELLO a\.b$`)
+	}
+	if step.Text != "${user} / ${missing}" {
+		t.Fatalf("original step modified: %q", step.Text)
+	}
+}
+
+func TestPlayWorkflowReportsEachDatasetRow(t *testing.T) {
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	sess := &session.Session{Host: mockHost, Playback: &session.WorkflowPlayback{}}
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	workflow, err := parseWorkflowPayload([]byte(`{
+		"Dataset": {"CSV": "user,expect\nalice,alice\nbob,robert\ncarol,carol\n"},
+		"Steps": [
+			{"Type": "Connect"},
+			{"Type": "FillString", "Coordinates": {"Row": 5, "Column": 8}, "Text": "${user}"},
+			{"Type": "AssertText", "Coordinates": {"Row": 5, "Column": 8}, "Text": "${expect}"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("parseWorkflowPayload: %v", err)
+	}
+
+	app.playWorkflow(sess, workflow)
+
+	var messages []string
+	withSessionLock(sess, func() {
+		for _, event := range sess.PlaybackEvents {
+			messages = append(messages, event.Message)
+		}
+	})
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{
+		"Row 1/3 passed",
+		`Step 3 failed (AssertText): expected "robert" at row 5, column 8`,
+		"Row 2/3 failed",
+		"Row 3/3 passed",
+		"Dataset finished: 2 of 3 rows passed",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("events = %v, want %q", messages, want)
+		}
+	}
+}
@@ -15,12 +15,21 @@ func (app *App) playWorkflow(s *session.Session, workflow *WorkflowConfig) {
 		return
 	}
 
+	target := sessionTargetLabel(s)
 	withSessionLock(s, func() {
 		if s.Playback == nil {
 			s.Playback = &session.WorkflowPlayback{}
 		}
 		s.Playback.Active = true
 		s.Playback.TotalSteps = len(workflow.Steps)
+		s.PlaybackResult = &session.WorkflowResult{
+			Host:      target,
+			Mode:      s.Playback.Mode,
+			StartedAt: time.Now(),
+		}
+		if s.LoadedWorkflow != nil {
+			s.PlaybackResult.Name = s.LoadedWorkflow.Name
+		}
 	})
 
 	defer func() {
@@ -35,18 +44,80 @@ func (app *App) playWorkflow(s *session.Session, workflow *WorkflowConfig) {
 			s.LastPlaybackDelayApplied = formatDelayApplied(s.Playback.CurrentDelayUsed.Seconds())
 			s.Playback.Active = false
 			s.PlaybackCompletedAt = time.Now()
+			if s.PlaybackResult != nil {
+				s.PlaybackResult.FinishedAt = s.PlaybackCompletedAt
+			}
 		})
 		addPlaybackEvent(s, "Playback stopped")
 	}()
 
+	rows, err := workflow.Dataset.records()
+	if err != nil {
+		addPlaybackEvent(s, fmt.Sprintf("Dataset invalid: %v", err))
+		return
+	}
+	if len(rows) == 0 {
+		if err := app.playWorkflowSteps(s, workflow, 0, nil); err != nil {
+			return
+		}
+		addPlaybackEvent(s, "Playback completed")
+		return
+	}
+
+	withSessionLock(s, func() {
+		if s.Playback != nil {
+			s.Playback.TotalRows = len(rows)
+		}
+	})
+	passed := 0
+	for r, row := range rows {
+		if r > 0 {
+			// Each row starts from a fresh connection so a failed row cannot
+			// leave the next one on the wrong screen.
+			if err := restartWorkflowHost(s); err != nil {
+				addPlaybackEvent(s, fmt.Sprintf("Row %d/%d failed: reconnect: %v", r+1, len(rows), err))
+				skipWorkflowSteps(s, workflow, r+1, 0)
+				continue
+			}
+		}
+		withSessionLock(s, func() {
+			if s.Playback != nil {
+				s.Playback.CurrentRow = r + 1
+				s.Playback.PendingInput = false
+				s.Playback.AuditFields = nil
+			}
+		})
+		addPlaybackEvent(s, fmt.Sprintf("Row %d/%d started", r+1, len(rows)))
+		err := app.playWorkflowSteps(s, workflow, r+1, row)
+		if errors.Is(err, errPlaybackStopped) {
+			return
+		}
+		if err != nil {
+			addPlaybackEvent(s, fmt.Sprintf("Row %d/%d failed", r+1, len(rows)))
+			continue
+		}
+		passed++
+		addPlaybackEvent(s, fmt.Sprintf("Row %d/%d passed", r+1, len(rows)))
+	}
+	addPlaybackEvent(s, fmt.Sprintf("Dataset finished: %d of %d rows passed", passed, len(rows)))
+	addPlaybackEvent(s, "Playback completed")
+}
+
+// playWorkflowSteps runs every step once, substituting row values into
+// placeholders, and records a result for each step. rowNumber is the 1-based
+// dataset row, or 0 without a dataset. It returns errPlaybackStopped when
+// playback was stopped, or the failing step's error.
+func (app *App) playWorkflowSteps(s *session.Session, workflow *WorkflowConfig, rowNumber int, row map[string]string) error {
 	for i, step := range workflow.Steps {
 		if shouldStopPlayback(s) {
 			addPlaybackEvent(s, "Playback stop acknowledged")
-			return
+			skipWorkflowSteps(s, workflow, rowNumber, i)
+			return errPlaybackStopped
 		}
 		if err := waitForDebugPermission(s); err != nil {
 			addPlaybackEvent(s, "Playback interrupted")
-			return
+			skipWorkflowSteps(s, workflow, rowNumber, i)
+			return errPlaybackStopped
 		}
 
 		delayMin, delayMax := workflowDelayForStep(workflow, step)
@@ -65,13 +136,41 @@ func (app *App) playWorkflow(s *session.Session, workflow *WorkflowConfig) {
 		if delayUsed > 0 {
 			if sleepCanceled(s, delayUsed) {
 				addPlaybackEvent(s, "Playback stop acknowledged")
-				return
+				skipWorkflowSteps(s, workflow, rowNumber, i)
+				return errPlaybackStopped
 			}
 		}
 
-		if err := app.applyWorkflowStep(s, step); err != nil {
+		started := time.Now()
+		drift, err := verifyWorkflowScreen(s, workflow, step)
+		if drift != "" {
+			addPlaybackEvent(s, fmt.Sprintf("Step %d: screen differs from the recording", i+1))
+		}
+		if err == nil {
+			err = app.applyWorkflowStep(s, expandWorkflowStep(step, row))
+		}
+		result := session.WorkflowStepResult{
+			Row:      rowNumber,
+			Step:     i + 1,
+			Type:     step.Type,
+			Status:   session.StepPassed,
+			Duration: time.Since(started),
+			Drift:    drift,
+		}
+		if errors.Is(err, errPlaybackStopped) {
+			addPlaybackEvent(s, "Playback stop acknowledged")
+			skipWorkflowSteps(s, workflow, rowNumber, i)
+			return err
+		}
+		if err != nil {
+			result.Status = session.StepFailed
+			result.Error = err.Error()
+		}
+		recordWorkflowStepResult(s, result)
+		if err != nil {
 			addPlaybackEvent(s, fmt.Sprintf("Step %d failed (%s): %v", i+1, step.Type, err))
-			return
+			skipWorkflowSteps(s, workflow, rowNumber, i+1)
+			return err
 		}
 
 		addPlaybackEvent(s, fmt.Sprintf("Step %d/%d: %s", i+1, len(workflow.Steps), step.Type))
@@ -85,8 +184,20 @@ func (app *App) playWorkflow(s *session.Session, workflow *WorkflowConfig) {
 			}
 		})
 	}
+	return nil
+}
 
-	addPlaybackEvent(s, "Playback completed")
+// restartWorkflowHost reconnects the session host between dataset rows.
+func restartWorkflowHost(s *session.Session) error {
+	if s == nil || s.Host == nil {
+		return errors.New("session host is unavailable")
+	}
+	if s.Host.IsConnected() {
+		if err := s.Host.Stop(); err != nil {
+			return err
+		}
+	}
+	return s.Host.Start()
 }
 
 func (app *App) applyWorkflowStep(s *session.Session, step session.WorkflowStep) error {
@@ -105,9 +216,19 @@ func (app *App) applyWorkflowStep(s *session.Session, step session.WorkflowStep)
 	case "Disconnect":
 		return s.Host.Stop()
 	case "FillString":
-		if err := app.applyWorkflowFill(s, step); err != nil {
+		// Secrets are resolved here only, so no error message or report
+		// can carry their values.
+		resolved, err := app.resolvePlaybackSecrets(s, step)
+		if err != nil {
+			return err
+		}
+		if err := app.applyWorkflowFill(s, resolved); err != nil {
 			return err
 		}
+		auditPlaybackFill(s, step)
+	case "WaitForText", "AssertText", "AssertField", "AssertCursor":
+		// Checks refresh the screen themselves and leave pending input unsent.
+		return applyWorkflowCheck(s, step)
 	default:
 		if err := submitWorkflowPendingInput(s); err != nil {
 			return err
@@ -116,7 +237,10 @@ func (app *App) applyWorkflowStep(s *session.Session, step session.WorkflowStep)
 		if !ok {
 			return fmt.Errorf("unsupported workflow step type: %s", step.Type)
 		}
-		if err := s.Host.SendKey(key); err != nil {
+		app.noteScreenAID(s, key)
+		err := s.Host.SendKey(key)
+		app.auditPlaybackKey(s, key, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -126,6 +250,7 @@ func (app *App) applyWorkflowStep(s *session.Session, step session.WorkflowStep)
 		if err := s.Host.UpdateScreen(); err != nil {
 			return err
 		}
+		app.observeScreen(s)
 	}
 	return nil
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/jnnngs/3270Web/internal/host"
 	"github.com/jnnngs/3270Web/internal/session"
 )
 
@@ -30,3 +32,120 @@ func TestStopWorkflowPlaybackUpdatesState(t *testing.T) {
 		t.Fatalf("expected stop event message, got %q", got)
 	}
 }
+
+func newCheckStepSession(t *testing.T) (*session.Session, *host.MockHost) {
+	t.Helper()
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	mockHost.Connected = true
+	screen := mockHost.Screen
+	copy(screen.Buffer[0], []rune("SIGN ON"))
+	copy(screen.Buffer[4], []rune("USERID ALICE"))
+	copy(screen.Buffer[5], []rune("PASSWD SECRET"))
+	screen.Fields = []*host.Field{
+		host.NewField(screen, host.AttrProtected, 0, 0, 6, 0, host.AttrColDefault, host.AttrEhDefault),
+		host.NewField(screen, 0, 7, 4, 14, 4, host.AttrColDefault, host.AttrEhDefault),
+		host.NewField(screen, host.AttrDisp1|host.AttrDisp2, 7, 5, 14, 5, host.AttrColDefault, host.AttrEhDefault),
+	}
+	screen.Status = "U F U C(localhost) I 2 24 80 4 7 0x0 -"
+	return &session.Session{Host: mockHost, Playback: &session.WorkflowPlayback{Active: true}}, mockHost
+}
+
+func TestApplyWorkflowCheckSteps(t *testing.T) {
+	at := func(row, col int) *session.WorkflowCoordinates {
+		return &session.WorkflowCoordinates{Row: row, Column: col}
+	}
+	tests := []struct {
+		name    string
+		step    session.WorkflowStep
+		wantErr string
+	}{
+		{name: "text anywhere", step: session.WorkflowStep{Type: "AssertText", Text: "SIGN ON"}},
+		{name: "text missing", step: session.WorkflowStep{Type: "AssertText", Text: "WELCOME"}, wantErr: `expected "WELCOME" on screen`},
+		{name: "text at position", step: session.WorkflowStep{Type: "AssertText", Text: "USERID", Coordinates: at(5, 1)}},
+		{name: "text at wrong position", step: session.WorkflowStep{Type: "AssertText", Text: "USERID", Coordinates: at(6, 1)}, wantErr: `expected "USERID" at row 6, column 1, found "PASSWD"`},
+		{name: "regex anywhere", step: session.WorkflowStep{Type: "AssertText", Regex: `USERID\s+A\w+`}},
+		{name: "regex at position", step: session.WorkflowStep{Type: "AssertText", Regex: `^SIGN`, Coordinates: at(1, 1)}},
+		{name: "field value", step: session.WorkflowStep{Type: "AssertField", Text: "ALICE", Coordinates: at(5, 10)}},
+		{name: "field mismatch", step: session.WorkflowStep{Type: "AssertField", Text: "BOB", Coordinates: at(5, 9)}, wantErr: `to be "BOB", found "ALICE"`},
+		{name: "hidden field mismatch", step: session.WorkflowStep{Type: "AssertField", Text: "GUESS", Coordinates: at(6, 9)}, wantErr: "found a hidden value"},
+		{name: "no field", step: session.WorkflowStep{Type: "AssertField", Text: "X", Coordinates: at(20, 1)}, wantErr: "no field at row 20, column 1"},
+		{name: "cursor", step: session.WorkflowStep{Type: "AssertCursor", Coordinates: at(5, 8)}},
+		{name: "cursor mismatch", step: session.WorkflowStep{Type: "AssertCursor", Coordinates: at(1, 1)}, wantErr: "expected cursor at row 1, column 1, found row 5, column 8"},
+		{name: "wait found", step: session.WorkflowStep{Type: "WaitForText", Text: "SIGN ON"}},
+		{name: "wait timeout", step: session.WorkflowStep{Type: "WaitForText", Text: "READY", Timeout: 0.05}, wantErr: "timed out after 50ms"},
+		{name: "invalid regex", step: session.WorkflowStep{Type: "AssertText", Regex: "("}, wantErr: "invalid regex"},
+	}
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess, mockHost := newCheckStepSession(t)
+			err := app.applyWorkflowStep(sess, tt.step)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("applyWorkflowStep = %v, want nil", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("applyWorkflowStep = %v, want error containing %q", err, tt.wantErr)
+			}
+			if len(mockHost.Commands) != 0 {
+				t.Fatalf("commands = %v, want none", mockHost.Commands)
+			}
+		})
+	}
+}
+
+func TestPlayWorkflowStopsOnFailedAssertion(t *testing.T) {
+	sess, mockHost := newCheckStepSession(t)
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	workflow := &WorkflowConfig{Steps: []session.WorkflowStep{
+		{Type: "AssertText", Text: "SIGN ON"},
+		{Type: "AssertText", Text: "MAIN MENU"},
+		{Type: "PressEnter"},
+	}}
+
+	app.playWorkflow(sess, workflow)
+
+	if len(mockHost.Commands) != 0 {
+		t.Fatalf("commands = %v, want playback to stop before PressEnter", mockHost.Commands)
+	}
+	var messages []string
+	withSessionLock(sess, func() {
+		for _, event := range sess.PlaybackEvents {
+			messages = append(messages, event.Message)
+		}
+	})
+	want := `Step 2 failed (AssertText): expected "MAIN MENU" on screen`
+	found := false
+	for _, message := range messages {
+		if message == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("events = %v, want %q", messages, want)
+	}
+}
+
+func TestParseWorkflowPayloadValidatesCheckSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantErr bool
+	}{
+		{name: "valid", payload: `{"Steps":[{"Type":"WaitForText","Text":"READY","Timeout":5}]}`},
+		{name: "missing text", payload: `{"Steps":[{"Type":"AssertText"}]}`, wantErr: true},
+		{name: "bad regex", payload: `{"Steps":[{"Type":"WaitForText","Regex":"["}]}`, wantErr: true},
+		{name: "cursor without coordinates", payload: `{"Steps":[{"Type":"AssertCursor"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWorkflowPayload([]byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWorkflowPayload error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
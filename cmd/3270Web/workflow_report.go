@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// maxWorkflowResultSnapshots bounds memory for long dataset runs: passed steps
+// beyond it are recorded without a screen snapshot. Failures always keep one.
+const maxWorkflowResultSnapshots = 500
+
+func recordWorkflowStepResult(s *session.Session, result session.WorkflowStepResult) {
+	withSessionLock(s, func() {
+		if s.PlaybackResult == nil {
+			return
+		}
+		if result.Status == session.StepFailed || len(s.PlaybackResult.Steps) < maxWorkflowResultSnapshots {
+			result.Screen = workflowScreenSnapshot(s)
+		}
+		s.PlaybackResult.Steps = append(s.PlaybackResult.Steps, result)
+	})
+}
+
+// skipWorkflowSteps records the steps from index from onwards as skipped.
+func skipWorkflowSteps(s *session.Session, workflow *WorkflowConfig, rowNumber, from int) {
+	withSessionLock(s, func() {
+		if s.PlaybackResult == nil {
+			return
+		}
+		for i := from; i < len(workflow.Steps); i++ {
+			s.PlaybackResult.Steps = append(s.PlaybackResult.Steps, session.WorkflowStepResult{
+				Row:    rowNumber,
+				Step:   i + 1,
+				Type:   workflow.Steps[i].Type,
+				Status: session.StepSkipped,
+			})
+		}
+	})
+}
+
+// workflowScreenSnapshot returns the current screen text with hidden fields
+// blanked. The caller holds the session lock.
+func workflowScreenSnapshot(s *session.Session) string {
+	if s.Host == nil {
+		return ""
+	}
+	screen := s.Host.GetScreen()
+	if screen == nil {
+		return ""
+	}
+	return strings.TrimRight(strings.Join(buildAPIScreen(screen).Text, "\n"), " \n")
+}
+
+func playbackResultSnapshot(s *session.Session) *session.WorkflowResult {
+	var result *session.WorkflowResult
+	withSessionLock(s, func() {
+		if s.PlaybackResult != nil {
+			copied := *s.PlaybackResult
+			copied.Steps = append([]session.WorkflowStepResult(nil), s.PlaybackResult.Steps...)
+			result = &copied
+		}
+	})
+	return result
+}
+
+func workflowResultName(result *session.WorkflowResult) string {
+	if result.Name == "" {
+		return "workflow"
+	}
+	return result.Name
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Hostname  string          `xml:"hostname,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// workflowResultJUnit renders a playback result as JUnit XML, with one test
+// suite per dataset row and one test case per step.
+func workflowResultJUnit(result *session.WorkflowResult) ([]byte, error) {
+	name := workflowResultName(result)
+	suites := junitTestSuites{Name: name}
+	var total time.Duration
+	var suiteTimes []time.Duration
+	suiteIndex := map[int]int{}
+	for _, step := range result.Steps {
+		idx, ok := suiteIndex[step.Row]
+		if !ok {
+			suiteName := name
+			if step.Row > 0 {
+				suiteName = fmt.Sprintf("%s row %d", name, step.Row)
+			}
+			suites.Suites = append(suites.Suites, junitTestSuite{
+				Name:      suiteName,
+				Timestamp: result.StartedAt.Format("2006-01-02T15:04:05"),
+				Hostname:  result.Host,
+			})
+			suiteTimes = append(suiteTimes, 0)
+			idx = len(suites.Suites) - 1
+			suiteIndex[step.Row] = idx
+		}
+		suite := &suites.Suites[idx]
+		tc := junitTestCase{
+			ClassName: name,
+			Name:      fmt.Sprintf("Step %d: %s", step.Step, step.Type),
+			Time:      junitSeconds(step.Duration),
+		}
+		if step.Drift != "" {
+			tc.SystemOut = "Screen differs from the recording:\n" + step.Drift
+		}
+		switch step.Status {
+		case session.StepFailed:
+			tc.Failure = &junitFailure{Message: step.Error, Type: step.Type, Text: step.Error + "\n\n" + step.Screen}
+			suite.Failures++
+			suites.Failures++
+		case session.StepSkipped:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+			suites.Skipped++
+		}
+		suite.Tests++
+		suites.Tests++
+		suite.Cases = append(suite.Cases, tc)
+		suiteTimes[idx] += step.Duration
+		total += step.Duration
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = junitSeconds(suiteTimes[i])
+	}
+	suites.Time = junitSeconds(total)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+var workflowReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string { return fmt.Sprintf("%.0f ms", float64(d)/float64(time.Millisecond)) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Name }} playback report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 24px; color: #1d2330; background: #f6f7f9; }
+h1 { font-size: 1.4rem; margin-bottom: 4px; }
+.meta { color: #5b6475; margin-bottom: 16px; }
+.totals span { display: inline-block; margin-right: 16px; font-weight: 600; }
+table { width: 100%; border-collapse: collapse; background: #fff; margin-top: 16px; }
+th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #e1e4ea; vertical-align: top; }
+.passed { color: #1b7f3b; }
+.failed { color: #b42318; }
+.skipped { color: #7a8294; }
+.error { color: #b42318; white-space: pre-wrap; }
+.drift { color: #9a6700; }
+pre { background: #0f1420; color: #3ddc84; padding: 10px; border-radius: 6px; overflow: auto; font-size: 12px; line-height: 1.25; }
+</style>
+</head>
+<body>
+<h1>{{ .Name }}</h1>
+<div class="meta">Host {{ .Host }} &middot; {{ .Mode }} mode &middot; started {{ .StartedAt.Format "2006-01-02 15:04:05" }}{{ if not .FinishedAt.IsZero }} &middot; finished {{ .FinishedAt.Format "2006-01-02 15:04:05" }}{{ end }}</div>
+<div class="totals"><span class="passed">{{ .Passed }} passed</span><span class="failed">{{ .Failed }} failed</span><span class="skipped">{{ .Skipped }} skipped</span></div>
+<table>
+<thead><tr>{{ if .HasRows }}<th>Row</th>{{ end }}<th>Step</th><th>Type</th><th>Status</th><th>Duration</th><th>Details</th></tr></thead>
+<tbody>
+{{ range .Steps }}<tr>
+{{ if $.HasRows }}<td>{{ .Row }}</td>{{ end }}<td>{{ .Step }}</td><td>{{ .Type }}</td><td class="{{ .Status }}">{{ .Status }}</td><td>{{ if ne .Status "skipped" }}{{ ms .Duration }}{{ end }}</td>
+<td>{{ if .Error }}<div class="error">{{ .Error }}</div>{{ end }}{{ if .Drift }}<details open><summary class="drift">Screen differs from the recording</summary><pre>{{ .Drift }}</pre></details>{{ end }}{{ if .Screen }}<details{{ if .Error }} open{{ end }}><summary>Screen</summary><pre>{{ .Screen }}</pre></details>{{ end }}</td>
+</tr>
+{{ end }}</tbody>
+</table>
+</body>
+</html>
+`))
+
+// workflowResultHTML renders a self-contained HTML report with the screen
+// after each step.
+func workflowResultHTML(result *session.WorkflowResult) ([]byte, error) {
+	data := struct {
+		*session.WorkflowResult
+		Name                    string
+		Passed, Failed, Skipped int
+		HasRows                 bool
+	}{WorkflowResult: result, Name: workflowResultName(result)}
+	for _, step := range result.Steps {
+		switch step.Status {
+		case session.StepPassed:
+			data.Passed++
+		case session.StepFailed:
+			data.Failed++
+		case session.StepSkipped:
+			data.Skipped++
+		}
+		if step.Row > 0 {
+			data.HasRows = true
+		}
+	}
+	var buf bytes.Buffer
+	if err := workflowReportTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WorkflowReportHandler handles GET /workflow/report and downloads the last
+// playback result as HTML, or as JUnit XML with ?format=junit.
+func (app *App) WorkflowReportHandler(c *gin.Context) {
+	s := app.getSession(c)
+	if s == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		return
+	}
+	result := playbackResultSnapshot(s)
+	if result == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no playback result for this session"})
+		return
+	}
+	base := strings.TrimSuffix(filepath.Base(workflowResultName(result)), filepath.Ext(workflowResultName(result)))
+	switch c.DefaultQuery("format", "html") {
+	case "junit":
+		data, err := workflowResultJUnit(result)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", base+"-junit.xml"))
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", data)
+	case "html":
+		data, err := workflowResultHTML(result)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", base+"-report.html"))
+		c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or junit"})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func TestPlayWorkflowRecordsResult(t *testing.T) {
+	sess, _ := newCheckStepSession(t)
+	sess.LoadedWorkflow = &session.LoadedWorkflow{Name: "signon.json"}
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	workflow := &WorkflowConfig{Steps: []session.WorkflowStep{
+		{Type: "AssertText", Text: "SIGN ON"},
+		{Type: "AssertText", Text: "MAIN MENU"},
+		{Type: "PressEnter"},
+	}}
+
+	app.playWorkflow(sess, workflow)
+
+	result := playbackResultSnapshot(sess)
+	if result == nil || result.Name != "signon.json" || result.FinishedAt.IsZero() {
+		t.Fatalf("result = %+v, want finished result for signon.json", result)
+	}
+	wantStatus := []string{session.StepPassed, session.StepFailed, session.StepSkipped}
+	if len(result.Steps) != len(wantStatus) {
+		t.Fatalf("steps = %+v, want %d", result.Steps, len(wantStatus))
+	}
+	for i, want := range wantStatus {
+		if got := result.Steps[i].Status; got != want {
+			t.Fatalf("step %d status = %q, want %q", i+1, got, want)
+		}
+	}
+	failed := result.Steps[1]
+	if !strings.Contains(failed.Error, `"MAIN MENU"`) || !strings.Contains(failed.Screen, "USERID ALICE") {
+		t.Fatalf("failed step = %+v, want error and screen text", failed)
+	}
+	if strings.Contains(failed.Screen, "SECRET") {
+		t.Fatalf("screen snapshot exposes hidden field: %q", failed.Screen)
+	}
+}
+
+func TestWorkflowResultJUnit(t *testing.T) {
+	result := &session.WorkflowResult{
+		Name: "flow.json",
+		Steps: []session.WorkflowStepResult{
+			{Row: 1, Step: 1, Type: "PressEnter", Status: session.StepPassed},
+			{Row: 1, Step: 2, Type: "AssertText", Status: session.StepPassed},
+			{Row: 2, Step: 1, Type: "PressEnter", Status: session.StepPassed},
+			{Row: 2, Step: 2, Type: "AssertText", Status: session.StepFailed, Error: "expected <READY>", Screen: "ERROR"},
+		},
+	}
+	data, err := workflowResultJUnit(result)
+	if err != nil {
+		t.Fatalf("workflowResultJUnit: %v", err)
+	}
+	var parsed junitTestSuites
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("parse junit: %v\n%s", err, data)
+	}
+	if parsed.Tests != 4 || parsed.Failures != 1 || len(parsed.Suites) != 2 {
+		t.Fatalf("junit = %d tests, %d failures, %d suites; want 4, 1, 2", parsed.Tests, parsed.Failures, len(parsed.Suites))
+	}
+	row2 := parsed.Suites[1]
+	if row2.Name != "flow.json row 2" || row2.Failures != 1 {
+		t.Fatalf("suite 2 = %+v, want one failure in row 2", row2)
+	}
+	if f := row2.Cases[1].Failure; f == nil || f.Message != "expected <READY>" || !strings.Contains(f.Text, "ERROR") {
+		t.Fatalf("failure = %+v, want message and screen", f)
+	}
+}
+
+func TestWorkflowReportHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	sess := app.SessionManager.CreateSession(nil)
+
+	r := gin.New()
+	r.GET("/workflow/report", app.WorkflowReportHandler)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "3270Web_session", Value: sess.ID})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/workflow/report"); w.Code != http.StatusNotFound {
+		t.Fatalf("status without result = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	withSessionLock(sess, func() {
+		sess.PlaybackResult = &session.WorkflowResult{
+			Name: "flow.json",
+			Steps: []session.WorkflowStepResult{
+				{Step: 1, Type: "AssertText", Status: session.StepFailed, Error: "expected <b>", Screen: "<script>"},
+			},
+		}
+	})
+
+	w := get("/workflow/report")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), "flow-report.html") {
+		t.Fatalf("html report = %d %q", w.Code, w.Header().Get("Content-Disposition"))
+	}
+	if !strings.Contains(body, "0 passed") || !strings.Contains(body, "1 failed") || strings.Contains(body, "<script>") {
+		t.Fatalf("html report body = %s", body)
+	}
+	w = get("/workflow/report?format=junit")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<testsuites") {
+		t.Fatalf("junit report = %d %s", w.Code, w.Body.String())
+	}
+	if w := get("/workflow/report?format=pdf"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
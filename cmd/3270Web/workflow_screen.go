@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// recordScreensEnv makes new recordings store the expected screen on each
+// key step.
+const recordScreensEnv = "APP_RECORD_SCREENS"
+
+// Values for WorkflowConfig.ScreenCheck. The default, warn, reports drift in
+// the playback result without failing the step.
+const (
+	screenCheckWarn = "warn"
+	screenCheckFail = "fail"
+	screenCheckOff  = "off"
+)
+
+// screenCheckSettle is how long playback waits for the live screen to match
+// before reporting drift, since the host may still be painting it.
+const screenCheckSettle = time.Second
+
+func recordScreensEnabled() bool {
+	return parseBoolFormValue(os.Getenv(recordScreensEnv))
+}
+
+func validateScreenCheck(mode string) error {
+	switch mode {
+	case "", screenCheckWarn, screenCheckFail, screenCheckOff:
+		return nil
+	}
+	return fmt.Errorf("ScreenCheck must be %s, %s or %s", screenCheckWarn, screenCheckFail, screenCheckOff)
+}
+
+// expectedWorkflowScreen captures the screen for comparison during playback.
+// Input fields are blanked so typed values, passwords and dataset values
+// never end up in the recording or cause drift.
+func expectedWorkflowScreen(screen *host.Screen) *session.WorkflowScreen {
+	if screen == nil || len(screen.Buffer) == 0 {
+		return nil
+	}
+	text := buildAPIScreen(screen).Text
+	for _, f := range screen.Fields {
+		if !f.IsProtected() || f.IsHidden() {
+			blankFieldText(text, f, screen.Width)
+		}
+	}
+	for i, line := range text {
+		text[i] = strings.TrimRight(line, " ")
+	}
+	sum := sha256.Sum256([]byte(strings.Join(text, "\n")))
+	return &session.WorkflowScreen{
+		Fingerprint: hex.EncodeToString(sum[:8]),
+		Text:        text,
+	}
+}
+
+// workflowScreenDrift describes the rows where actual differs from the
+// recorded screen as a row-level diff, and returns how many rows differ.
+func workflowScreenDrift(expected, actual *session.WorkflowScreen) (string, int) {
+	if expected == nil || actual == nil || expected.Fingerprint == actual.Fingerprint {
+		return "", 0
+	}
+	rows := len(expected.Text)
+	if len(actual.Text) > rows {
+		rows = len(actual.Text)
+	}
+	var sb strings.Builder
+	changed := 0
+	for i := 0; i < rows; i++ {
+		var want, got string
+		if i < len(expected.Text) {
+			want = expected.Text[i]
+		}
+		if i < len(actual.Text) {
+			got = actual.Text[i]
+		}
+		if want == got {
+			continue
+		}
+		changed++
+		fmt.Fprintf(&sb, "row %d\n- %s\n+ %s\n", i+1, want, got)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), changed
+}
+
+// verifyWorkflowScreen compares the live screen with the one the step was
+// recorded on. It returns the drift, if any, and an error when the workflow's
+// ScreenCheck is fail or playback was stopped while waiting.
+func verifyWorkflowScreen(s *session.Session, workflow *WorkflowConfig, step session.WorkflowStep) (string, error) {
+	if step.Expect == nil || workflow == nil || workflow.ScreenCheck == screenCheckOff {
+		return "", nil
+	}
+	deadline := time.Now().Add(screenCheckSettle)
+	for {
+		if err := s.Host.UpdateScreen(); err != nil {
+			return "", err
+		}
+		actual := expectedWorkflowScreen(s.Host.GetScreen())
+		drift, rows := workflowScreenDrift(step.Expect, actual)
+		if drift == "" {
+			return "", nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if workflow.ScreenCheck == screenCheckFail {
+				return drift, fmt.Errorf("screen differs from the recording: %d of %d rows changed", rows, len(actual.Text))
+			}
+			return drift, nil
+		}
+		if remaining > workflowWaitPollInterval {
+			remaining = workflowWaitPollInterval
+		}
+		if sleepCanceled(s, remaining) {
+			return "", errPlaybackStopped
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func TestExpectedWorkflowScreenIgnoresInputFields(t *testing.T) {
+	_, mockHost := newCheckStepSession(t)
+	screen := mockHost.Screen
+	expected := expectedWorkflowScreen(screen)
+	if expected == nil {
+		t.Fatal("expectedWorkflowScreen returned nil")
+	}
+	if got := expected.Text[4]; got != "USERID" {
+		t.Fatalf("row 5 = %q, want the input field blanked", got)
+	}
+	if got := expected.Text[5]; got != "PASSWD" {
+		t.Fatalf("row 6 = %q, want the hidden field blanked", got)
+	}
+
+	copy(screen.Buffer[4][7:], []rune("BOB  "))
+	if got := expectedWorkflowScreen(screen).Fingerprint; got != expected.Fingerprint {
+		t.Fatalf("fingerprint changed with input value: %s, want %s", got, expected.Fingerprint)
+	}
+	copy(screen.Buffer[0], []rune("WELCOME"))
+	if got := expectedWorkflowScreen(screen).Fingerprint; got == expected.Fingerprint {
+		t.Fatal("fingerprint unchanged after protected text changed")
+	}
+}
+
+func TestWorkflowScreenDrift(t *testing.T) {
+	recorded := &session.WorkflowScreen{Fingerprint: "a", Text: []string{"SIGN ON", "", "USERID"}}
+	tests := []struct {
+		name      string
+		actual    *session.WorkflowScreen
+		wantDrift string
+		wantRows  int
+	}{
+		{name: "same fingerprint", actual: &session.WorkflowScreen{Fingerprint: "a", Text: []string{"ignored"}}},
+		{
+			name:      "changed row",
+			actual:    &session.WorkflowScreen{Fingerprint: "b", Text: []string{"WELCOME", "", "USERID"}},
+			wantDrift: "row 1\n- SIGN ON\n+ WELCOME",
+			wantRows:  1,
+		},
+		{
+			name:      "extra row",
+			actual:    &session.WorkflowScreen{Fingerprint: "c", Text: []string{"SIGN ON", "", "USERID", "MORE"}},
+			wantDrift: "row 4\n- \n+ MORE",
+			wantRows:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift, rows := workflowScreenDrift(recorded, tt.actual)
+			if drift != tt.wantDrift || rows != tt.wantRows {
+				t.Fatalf("workflowScreenDrift = %q, %d, want %q, %d", drift, rows, tt.wantDrift, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestRecordActionKeyCapturesExpectedScreen(t *testing.T) {
+	s, mockHost := newCheckStepSession(t)
+	s.Recording = &session.WorkflowRecording{Active: true, CaptureScreens: true}
+	recordActionKey(s, "Enter")
+	s.Recording.CaptureScreens = false
+	recordActionKey(s, "PF(3)")
+
+	steps := s.Recording.Steps
+	if len(steps) != 2 {
+		t.Fatalf("recorded %d steps, want 2", len(steps))
+	}
+	want := expectedWorkflowScreen(mockHost.Screen)
+	if steps[0].Expect == nil || steps[0].Expect.Fingerprint != want.Fingerprint {
+		t.Fatalf("Enter Expect = %+v, want fingerprint %s", steps[0].Expect, want.Fingerprint)
+	}
+	if steps[1].Expect != nil {
+		t.Fatalf("PF3 Expect = %+v, want nil when capture is off", steps[1].Expect)
+	}
+}
+
+func TestPlayWorkflowReportsScreenDrift(t *testing.T) {
+	s, mockHost := newCheckStepSession(t)
+	recorded := expectedWorkflowScreen(mockHost.Screen)
+	copy(mockHost.Screen.Buffer[0], []rune("WELCOME"))
+
+	app := &App{SessionManager: session.NewManager(), chaosEngines: newChaosEngineStore()}
+	for _, tt := range []struct {
+		mode       string
+		wantStatus string
+	}{
+		{mode: "", wantStatus: session.StepPassed},
+		{mode: screenCheckFail, wantStatus: session.StepFailed},
+		{mode: screenCheckOff, wantStatus: session.StepPassed},
+	} {
+		workflow := &WorkflowConfig{
+			ScreenCheck: tt.mode,
+			Steps:       []session.WorkflowStep{{Type: "PressEnter", Expect: recorded}},
+		}
+		app.playWorkflow(s, workflow)
+		result := playbackResultSnapshot(s)
+		if len(result.Steps) != 1 {
+			t.Fatalf("mode %q: %d step results, want 1", tt.mode, len(result.Steps))
+		}
+		step := result.Steps[0]
+		if step.Status != tt.wantStatus {
+			t.Fatalf("mode %q: status = %s, want %s (error %q)", tt.mode, step.Status, tt.wantStatus, step.Error)
+		}
+		wantDrift := tt.mode != screenCheckOff
+		if hasDrift := strings.Contains(step.Drift, "- SIGN ON\n+ WELCOME"); hasDrift != wantDrift {
+			t.Fatalf("mode %q: drift = %q, want drift %v", tt.mode, step.Drift, wantDrift)
+		}
+		if tt.mode == screenCheckFail && !strings.Contains(step.Error, "1 of 24 rows changed") {
+			t.Fatalf("mode %q: error = %q, want changed row count", tt.mode, step.Error)
+		}
+	}
+}
+
+func TestParseWorkflowPayloadValidatesScreenCheck(t *testing.T) {
+	if _, err := parseWorkflowPayload([]byte(`{"ScreenCheck":"fail","Steps":[{"Type":"Connect"}]}`)); err != nil {
+		t.Fatalf("ScreenCheck fail rejected: %v", err)
+	}
+	_, err := parseWorkflowPayload([]byte(`{"ScreenCheck":"strict","Steps":[{"Type":"Connect"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "ScreenCheck must be") {
+		t.Fatalf("err = %v, want ScreenCheck error", err)
+	}
+}
@@ -0,0 +1,270 @@
+// Package audit writes an append-only JSON lines trail of what each user sent
+// to which host, and searches it.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+// Sources of audited input.
+const (
+	SourceTerminal = "terminal"
+	SourcePlayback = "playback"
+	SourceChaos    = "chaos"
+	SourceAPI      = "api"
+	// SourceShare marks shared-session events, such as a viewer taking
+	// the keyboard, rather than input.
+	SourceShare = "share"
+)
+
+// Redacted replaces the value of input typed into hidden fields.
+const Redacted = "********"
+
+// Field is one input field sent to the host. Row and Column are 1-based,
+// matching recording coordinates, and zero for input typed on an
+// unformatted screen.
+type Field struct {
+	Row      int    `json:"row"`
+	Column   int    `json:"column"`
+	Value    string `json:"value"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// Event is one submission to a host: the fields changed and the AID key
+// that sent them. API field writes are logged without an AID key. Events
+// from SourceShare carry the share event and viewer instead.
+type Event struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	Source    string    `json:"source"`
+	SessionID string    `json:"sessionId,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	AID       string    `json:"aid,omitempty"`
+	Fields    []Field   `json:"fields,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Share     string    `json:"share,omitempty"`
+	Viewer    string    `json:"viewer,omitempty"`
+}
+
+// HiddenField returns the field for f's value, redacted when f is hidden.
+func HiddenField(f *host.Field, row, column int, value string) Field {
+	if f != nil && f.IsHidden() {
+		return RedactedField(row, column, value)
+	}
+	return Field{Row: row, Column: column, Value: value}
+}
+
+// RedactedField returns the field with its value replaced by Redacted. Empty
+// values stay empty, so clearing a password field is still visible.
+func RedactedField(row, column int, value string) Field {
+	if value == "" {
+		return Field{Row: row, Column: column, Redacted: true}
+	}
+	return Field{Row: row, Column: column, Value: Redacted, Redacted: true}
+}
+
+// Log appends events to a JSON lines file. The file is only ever opened for
+// appending; nothing in the app rewrites or truncates it.
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLog returns a log backed by path. The file is created on first write.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the log file path.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append writes e as one line. A zero Time is set to now.
+func (l *Log) Append(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return fmt.Errorf("create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Query selects events. Empty string fields match everything; User, Source
+// and AID match exactly ignoring case, Host and SessionID match a prefix,
+// and Text matches any field value, user, viewer, host or error as a
+// substring.
+type Query struct {
+	User      string
+	Source    string
+	SessionID string
+	Host      string
+	AID       string
+	Text      string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Matches reports whether e satisfies the query.
+func (q Query) Matches(e Event) bool {
+	switch {
+	case q.User != "" && !strings.EqualFold(q.User, e.User):
+		return false
+	case q.Source != "" && !strings.EqualFold(q.Source, e.Source):
+		return false
+	case q.AID != "" && !strings.EqualFold(q.AID, e.AID):
+		return false
+	case q.SessionID != "" && !strings.HasPrefix(e.SessionID, q.SessionID):
+		return false
+	case q.Host != "" && !strings.HasPrefix(strings.ToLower(e.Host), strings.ToLower(q.Host)):
+		return false
+	case !q.Since.IsZero() && e.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	}
+	if q.Text == "" {
+		return true
+	}
+	text := strings.ToLower(q.Text)
+	for _, s := range []string{e.User, e.Viewer, e.Host, e.Error} {
+		if strings.Contains(strings.ToLower(s), text) {
+			return true
+		}
+	}
+	for _, f := range e.Fields {
+		if !f.Redacted && strings.Contains(strings.ToLower(f.Value), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxLineBytes bounds one event line; longer lines are skipped.
+const maxLineBytes = 1 << 20
+
+// scan calls fn with each event in the log and its raw line, oldest first.
+// The line is only valid during the call.
+func (l *Log) scan(fn func(e Event, line []byte) error) error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64*1024)
+	var line []byte
+	oversized := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineBytes+1 {
+			oversized = true
+			line = line[:0]
+		} else if !oversized {
+			line = append(line, chunk...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("read audit log: %w", err)
+		}
+		if !oversized {
+			if err := scanLine(bytes.TrimRight(line, "\r\n"), fn); err != nil {
+				return err
+			}
+		}
+		line = line[:0]
+		oversized = false
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// scanLine passes line to fn when it holds an event.
+func scanLine(line []byte, fn func(e Event, line []byte) error) error {
+	if len(line) == 0 {
+		return nil
+	}
+	var e Event
+	if err := json.Unmarshal(line, &e); err != nil {
+		return nil
+	}
+	return fn(e, line)
+}
+
+// Search returns the newest events matching q, at most limit of them
+// (0 for all), newest first, and the total number of matches.
+func (l *Log) Search(q Query, limit int) ([]Event, int, error) {
+	var matched []Event
+	total := 0
+	err := l.scan(func(e Event, _ []byte) error {
+		if !q.Matches(e) {
+			return nil
+		}
+		total++
+		matched = append(matched, e)
+		if limit > 0 && len(matched) > limit {
+			matched = matched[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+	return matched, total, nil
+}
+
+// Export writes the lines of events matching q to w in log order and
+// returns how many it wrote.
+func (l *Log) Export(w io.Writer, q Query) (int, error) {
+	n := 0
+	err := l.scan(func(e Event, line []byte) error {
+		if !q.Matches(e) {
+			return nil
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendAndSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+	l := NewLog(path)
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Time: base, User: "alice", Source: SourceTerminal, SessionID: "s1", Host: "prod:23", AID: "Enter", Fields: []Field{{Row: 5, Column: 8, Value: "PAYROLL"}}},
+		{Time: base.Add(time.Minute), User: "alice", Source: SourceTerminal, SessionID: "s1", Host: "prod:23", AID: "Enter", Fields: []Field{RedactedField(6, 8, "hunter2")}},
+		{Time: base.Add(2 * time.Minute), User: "bob", Source: SourcePlayback, SessionID: "s2", Host: "test:3270", AID: "PF(3)"},
+	}
+	for _, e := range events {
+		if err := l.Append(e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("log file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "hunter2") {
+		t.Fatal("redacted value written to the log")
+	}
+
+	tests := []struct {
+		name  string
+		q     Query
+		limit int
+		want  []string
+		total int
+	}{
+		{"all newest first", Query{}, 0, []string{"PF(3)", "Enter", "Enter"}, 3},
+		{"limit keeps newest", Query{}, 1, []string{"PF(3)"}, 3},
+		{"user", Query{User: "Alice"}, 0, []string{"Enter", "Enter"}, 2},
+		{"host prefix", Query{Host: "test"}, 0, []string{"PF(3)"}, 1},
+		{"text", Query{Text: "payroll"}, 0, []string{"Enter"}, 1},
+		{"text skips redacted", Query{Text: Redacted}, 0, nil, 0},
+		{"since", Query{Since: base.Add(time.Minute)}, 0, []string{"PF(3)", "Enter"}, 2},
+		{"until excludes end", Query{Until: base.Add(time.Minute)}, 0, []string{"Enter"}, 1},
+	}
+	for _, tt := range tests {
+		got, total, err := l.Search(tt.q, tt.limit)
+		if err != nil {
+			t.Fatalf("%s: Search: %v", tt.name, err)
+		}
+		var keys []string
+		for _, e := range got {
+			keys = append(keys, e.AID)
+		}
+		if strings.Join(keys, ",") != strings.Join(tt.want, ",") || total != tt.total {
+			t.Errorf("%s: Search = %v (total %d), want %v (total %d)", tt.name, keys, total, tt.want, tt.total)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := l.Export(&buf, Query{User: "alice"})
+	if err != nil || n != 2 || strings.Count(buf.String(), "\n") != 2 {
+		t.Fatalf("Export = %d, %v, %q, want two lines", n, err, buf.String())
+	}
+}
+
+func TestSearchMissingLog(t *testing.T) {
+	l := NewLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	got, total, err := l.Search(Query{}, 10)
+	if err != nil || len(got) != 0 || total != 0 {
+		t.Fatalf("Search on a missing log = %v, %d, %v, want nothing", got, total, err)
+	}
+}
+
+func TestSearchSkipsOversizedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := NewLog(path)
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	if err := l.Append(Event{Time: base, User: "alice", AID: "Enter"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huge := `{"user":"mallory","aid":"` + strings.Repeat("x", maxLineBytes) + `"}` + "\n"
+	if _, err := f.WriteString(huge); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := l.Append(Event{Time: base.Add(time.Minute), User: "alice", AID: "PF(3)"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	got, total, err := l.Search(Query{}, 0)
+	if err != nil || total != 2 || len(got) != 2 || got[0].AID != "PF(3)" || got[1].AID != "Enter" {
+		t.Fatalf("Search = %v, %d, %v, want both events around the oversized line", got, total, err)
+	}
+	var buf bytes.Buffer
+	n, err := l.Export(&buf, Query{})
+	if err != nil || n != 2 || strings.Contains(buf.String(), "mallory") {
+		t.Fatalf("Export = %d, %v, want two events without the oversized line", n, err)
+	}
+}
+
+func TestRedactedField(t *testing.T) {
+	if f := RedactedField(1, 2, ""); f.Value != "" || !f.Redacted {
+		t.Fatalf("RedactedField of empty = %+v, want an empty redacted value", f)
+	}
+	if f := HiddenField(nil, 1, 2, "visible"); f.Value != "visible" || f.Redacted {
+		t.Fatalf("HiddenField without a field = %+v, want the value kept", f)
+	}
+}
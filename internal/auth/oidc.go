@@ -0,0 +1,365 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig describes an OpenID Connect identity provider and how its users
+// map to roles.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// RoleClaim names the ID token claim holding the user's role, either a
+	// string or a list of strings. The most privileged known role wins.
+	RoleClaim string
+	// DefaultRole applies when the claim is missing or names no known role.
+	// An empty DefaultRole rejects such users.
+	DefaultRole Role
+}
+
+// Identity is a user authenticated by the identity provider.
+type Identity struct {
+	Subject string
+	Name    string
+	Role    Role
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider runs the authorization code flow with PKCE against one
+// identity provider and verifies the RS256 ID tokens it issues.
+type OIDCProvider struct {
+	config    OIDCConfig
+	discovery oidcDiscovery
+	client    *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+const oidcHTTPTimeout = 10 * time.Second
+
+// jwksRefreshInterval limits how often an unknown key ID triggers a refetch.
+const jwksRefreshInterval = time.Minute
+
+// NewOIDCProvider reads the provider's discovery document.
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	cfg.Issuer = strings.TrimRight(strings.TrimSpace(cfg.Issuer), "/")
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC issuer, client ID and redirect URL are required")
+	}
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "role"
+	}
+	p := &OIDCProvider{config: cfg, client: &http.Client{Timeout: oidcHTTPTimeout}}
+	if err := p.getJSON(ctx, cfg.Issuer+"/.well-known/openid-configuration", &p.discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+	if strings.TrimRight(p.discovery.Issuer, "/") != cfg.Issuer {
+		return nil, fmt.Errorf("OIDC discovery: issuer %q does not match %q", p.discovery.Issuer, cfg.Issuer)
+	}
+	if p.discovery.AuthorizationEndpoint == "" || p.discovery.TokenEndpoint == "" || p.discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery: document is missing endpoints")
+	}
+	return p, nil
+}
+
+// Config returns the configuration the provider was created with.
+func (p *OIDCProvider) Config() OIDCConfig {
+	return p.config
+}
+
+// NewOIDCRequest returns random state, nonce and PKCE verifier values for
+// one login attempt.
+func NewOIDCRequest() (state, nonce, verifier string, err error) {
+	values := make([]string, 3)
+	for i := range values {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", "", "", err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(buf)
+	}
+	return values[0], values[1], values[2], nil
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL that starts a login.
+func (p *OIDCProvider) AuthCodeURL(state, nonce, verifier string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {"openid profile email"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {pkceChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code and returns the identity from the
+// verified ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("OIDC token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Identity{}, fmt.Errorf("OIDC token request: %w", err)
+	}
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return Identity{}, fmt.Errorf("OIDC token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.IDToken == "" {
+		msg := token.Error
+		if token.ErrorDescription != "" {
+			msg += ": " + token.ErrorDescription
+		}
+		if msg == "" {
+			msg = resp.Status
+		}
+		return Identity{}, fmt.Errorf("OIDC token request failed: %s", msg)
+	}
+	claims, err := p.verifyIDToken(ctx, token.IDToken, nonce, time.Now())
+	if err != nil {
+		return Identity{}, err
+	}
+	return p.identity(claims)
+}
+
+// verifyIDToken checks the token's RS256 signature, issuer, audience,
+// expiry and nonce, and returns its claims.
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, raw, nonce string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("ID token is not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("ID token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("ID token algorithm %q is not supported", header.Alg)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("ID token signature is malformed")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("ID token signature is invalid")
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("ID token claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("ID token issuer %q is not trusted", iss)
+	}
+	if !audienceContains(claims["aud"], p.config.ClientID) {
+		return nil, errors.New("ID token was not issued for this client")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return nil, errors.New("ID token has expired")
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func audienceContains(aud any, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// identity maps verified claims to a user name and role.
+func (p *OIDCProvider) identity(claims map[string]any) (Identity, error) {
+	id := Identity{}
+	id.Subject, _ = claims["sub"].(string)
+	for _, claim := range []string{"preferred_username", "email", "name"} {
+		if name, _ := claims[claim].(string); name != "" {
+			id.Name = name
+			break
+		}
+	}
+	if id.Name == "" {
+		id.Name = id.Subject
+	}
+	if id.Name == "" {
+		return Identity{}, errors.New("ID token does not identify the user")
+	}
+
+	var values []string
+	switch v := claims[p.config.RoleClaim].(type) {
+	case string:
+		values = strings.Fields(strings.ReplaceAll(v, ",", " "))
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, value := range values {
+		if role, err := ParseRole(value); err == nil && role.Allows(id.Role) {
+			id.Role = role
+		}
+	}
+	if id.Role == "" {
+		id.Role = p.config.DefaultRole
+	}
+	if id.Role == "" {
+		return Identity{}, fmt.Errorf("user %s has no role in the %q claim", id.Name, p.config.RoleClaim)
+	}
+	return id, nil
+}
+
+// key returns the signing key with the given ID, refetching the key set
+// when the ID is unknown.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lookup := func() *rsa.PublicKey {
+		if kid == "" && len(p.keys) == 1 {
+			for _, k := range p.keys {
+				return k
+			}
+		}
+		return p.keys[kid]
+	}
+	if k := lookup(); k != nil {
+		return k, nil
+	}
+	if time.Since(p.fetched) < jwksRefreshInterval && p.keys != nil {
+		return nil, fmt.Errorf("ID token key %q is unknown", kid)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("OIDC key set: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys, p.fetched = keys, time.Now()
+	if k := lookup(); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("ID token key %q is unknown", kid)
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/auth/oidctest"
+)
+
+const testRedirectURL = "http://app.example/auth/oidc/callback"
+
+// login runs an authorization request against the test provider and returns
+// the code from the redirect.
+func login(t *testing.T, p *OIDCProvider, state, nonce, verifier string) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(p.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), testRedirectURL) {
+		t.Fatalf("authorize redirect = %q, %v", resp.Header.Get("Location"), err)
+	}
+	if got := location.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	return location.Query().Get("code")
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := oidctest.NewServer("3270web", "s3cret")
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := NewOIDCProvider(ctx, OIDCConfig{
+		Issuer:       idp.URL + "/",
+		ClientID:     "3270web",
+		ClientSecret: "s3cret",
+		RedirectURL:  testRedirectURL,
+		RoleClaim:    "groups",
+		DefaultRole:  RoleUser,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+
+	tests := []struct {
+		claims   map[string]any
+		wantName string
+		wantRole Role
+	}{
+		{map[string]any{"sub": "1", "preferred_username": "alice", "groups": []any{"staff", "tester"}}, "alice", RoleTester},
+		{map[string]any{"sub": "2", "email": "bob@example.com", "groups": "admin user"}, "bob@example.com", RoleAdmin},
+		{map[string]any{"sub": "3"}, "3", RoleUser},
+	}
+	for _, tt := range tests {
+		idp.SetClaims(tt.claims)
+		state, nonce, verifier, err := NewOIDCRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := login(t, p, state, nonce, verifier)
+		id, err := p.Exchange(ctx, code, verifier, nonce)
+		if err != nil || id.Name != tt.wantName || id.Role != tt.wantRole {
+			t.Errorf("Exchange(%v) = %+v, %v, want %s as %s", tt.claims, id, err, tt.wantName, tt.wantRole)
+		}
+	}
+
+	_, nonce, verifier, _ := NewOIDCRequest()
+	code := login(t, p, "s", nonce, verifier)
+	if _, err := p.Exchange(ctx, code, "wrong-verifier", nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("wrong PKCE verifier error = %v, want invalid_grant", err)
+	}
+	code = login(t, p, "s", nonce, verifier)
+	if _, err := p.Exchange(ctx, code, verifier, "other-nonce"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("wrong nonce error = %v, want nonce mismatch", err)
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	idp := oidctest.NewServer("3270web", "")
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := NewOIDCProvider(ctx, OIDCConfig{Issuer: idp.URL, ClientID: "3270web", RedirectURL: testRedirectURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{"iss": idp.URL, "aud": "3270web", "exp": now.Add(time.Minute).Unix(), "nonce": "n", "sub": "x"}
+	}
+	tests := []struct {
+		name    string
+		mutate  func(map[string]any)
+		token   func(string) string
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "issuer", mutate: func(c map[string]any) { c["iss"] = "https://evil.example" }, wantErr: "issuer"},
+		{name: "audience", mutate: func(c map[string]any) { c["aud"] = []any{"other"} }, wantErr: "not issued for this client"},
+		{name: "expired", mutate: func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() }, wantErr: "expired"},
+		{name: "tampered", token: func(tok string) string {
+			parts := strings.Split(tok, ".")
+			return parts[0] + "." + strings.TrimRight(parts[1], "=") + "x." + parts[2]
+		}, wantErr: ""},
+		{name: "unsigned", token: func(tok string) string {
+			return "eyJhbGciOiJub25lIn0." + strings.Split(tok, ".")[1] + "."
+		}, wantErr: "algorithm"},
+	}
+	for _, tt := range tests {
+		claims := valid()
+		if tt.mutate != nil {
+			tt.mutate(claims)
+		}
+		token := idp.SignToken(claims)
+		if tt.token != nil {
+			token = tt.token(token)
+		}
+		_, err := p.verifyIDToken(ctx, token, "n", now)
+		switch {
+		case tt.name == "valid" && err != nil:
+			t.Errorf("%s: verifyIDToken error = %v", tt.name, err)
+		case tt.name != "valid" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: verifyIDToken error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if _, err := p.identity(map[string]any{"sub": "x"}); err == nil || !strings.Contains(err.Error(), "no role") {
+		t.Fatalf("identity without a default role error = %v, want no role", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Package auth provides local user accounts, OIDC login and the roles that
+// gate administrative parts of the web UI.
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role grants access to a set of features. Each role includes everything the
+// roles below it may do.
+type Role string
+
+const (
+	// RoleUser may connect to hosts and use the terminal, recordings,
+	// workflows, file transfer and printing.
+	RoleUser Role = "user"
+	// RoleTester may also run chaos exploration and load tests.
+	RoleTester Role = "tester"
+	// RoleAdmin may also change settings, themes and connection profiles,
+	// read logs and restart the app.
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleUser: 1, RoleTester: 2, RoleAdmin: 3}
+
+// Roles lists the roles from least to most privileged.
+func Roles() []Role {
+	return []Role{RoleUser, RoleTester, RoleAdmin}
+}
+
+// ParseRole returns the role named by s, ignoring case and surrounding space.
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q: use user, tester or admin", s)
+	}
+	return role, nil
+}
+
+// Allows reports whether r includes the required role.
+func (r Role) Allows(required Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[required]
+}
+
+const (
+	hashScheme     = "pbkdf2-sha256"
+	hashIterations = 600000
+	hashSaltSize   = 16
+	hashKeySize    = 32
+)
+
+// HashPassword derives a salted PBKDF2-SHA256 hash of password, encoded as
+// "pbkdf2-sha256$<iterations>$<salt>$<hash>" with base64 salt and hash.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, hashSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, hashIterations, hashKeySize)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return strings.Join([]string{hashScheme, strconv.Itoa(hashIterations), enc.EncodeToString(salt), enc.EncodeToString(key)}, "$"), nil
+}
+
+// VerifyPassword reports whether password matches an encoded hash from
+// HashPassword. Malformed hashes never match.
+func VerifyPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// dummyHash is verified against when a login names an unknown user, so the
+// response takes as long as for a wrong password.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("")
+	return hash
+})
+
+// ErrInvalidCredentials is returned for an unknown user or a wrong password.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+var userNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+// ValidUserName reports whether name can be used for a local account.
+func ValidUserName(name string) bool {
+	return userNamePattern.MatchString(name)
+}
+
+// User is a local account. Names are matched without regard to case.
+type User struct {
+	Name         string `json:"name"`
+	PasswordHash string `json:"passwordHash"`
+	Role         Role   `json:"role"`
+}
+
+type usersFile struct {
+	Users []User `json:"users"`
+}
+
+// Store keeps local accounts in a JSON file. It rereads the file when it
+// changes on disk, so accounts edited from the command line apply to a
+// running server.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	users   []User
+}
+
+// NewStore returns a store backed by path. A missing file holds no users.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) loadLocked() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.users, s.modTime, s.size = nil, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read users file: %w", err)
+	}
+	if s.users != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("read users file: %w", err)
+	}
+	var file usersFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parse users file: %w", err)
+	}
+	if file.Users == nil {
+		file.Users = []User{}
+	}
+	s.users, s.modTime, s.size = file.Users, info.ModTime(), info.Size()
+	return nil
+}
+
+func (s *Store) saveLocked(users []User) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return fmt.Errorf("create users directory: %w", err)
+	}
+	data, err := json.MarshalIndent(usersFile{Users: users}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal users: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("write users file: %w", err)
+	}
+	s.users = nil
+	return s.loadLocked()
+}
+
+func (s *Store) indexLocked(name string) int {
+	for i, u := range s.users {
+		if strings.EqualFold(u.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasUsers reports whether any local account exists.
+func (s *Store) HasUsers() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return false, err
+	}
+	return len(s.users) > 0, nil
+}
+
+// List returns the accounts sorted by name.
+func (s *Store) List() ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	users := append([]User{}, s.users...)
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].Name) < strings.ToLower(users[j].Name)
+	})
+	return users, nil
+}
+
+// Authenticate returns the account matching name and password.
+func (s *Store) Authenticate(name, password string) (User, error) {
+	s.mu.Lock()
+	err := s.loadLocked()
+	var user User
+	found := false
+	if err == nil {
+		if i := s.indexLocked(name); i >= 0 {
+			user, found = s.users[i], true
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return User{}, err
+	}
+	if !found {
+		VerifyPassword(dummyHash(), password)
+		return User{}, ErrInvalidCredentials
+	}
+	if !VerifyPassword(user.PasswordHash, password) {
+		return User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// Put creates the account or, if it exists, replaces its password and role.
+func (s *Store) Put(name, password string, role Role) error {
+	if !ValidUserName(name) {
+		return fmt.Errorf("invalid user name %q: use letters, digits, '.', '_', '@' or '-'", name)
+	}
+	if password == "" {
+		return errors.New("password must not be empty")
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	user := User{Name: name, PasswordHash: hash, Role: role}
+	next := append([]User{}, s.users...)
+	if i := s.indexLocked(name); i >= 0 {
+		next[i] = user
+	} else {
+		next = append(next, user)
+	}
+	return s.saveLocked(next)
+}
+
+// Remove deletes the account and reports whether it existed.
+func (s *Store) Remove(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return false, err
+	}
+	i := s.indexLocked(name)
+	if i < 0 {
+		return false, nil
+	}
+	next := append(append([]User{}, s.users[:i]...), s.users[i+1:]...)
+	return true, s.saveLocked(next)
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, required Role
+		want           bool
+	}{
+		{RoleAdmin, RoleUser, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RoleTester, RoleUser, true},
+		{RoleTester, RoleAdmin, false},
+		{RoleUser, RoleTester, false},
+		{Role("guest"), RoleUser, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.want {
+			t.Errorf("%s.Allows(%s) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+	if role, err := ParseRole(" Tester "); err != nil || role != RoleTester {
+		t.Fatalf("ParseRole = %q, %v, want tester", role, err)
+	}
+	if _, err := ParseRole("root"); err == nil {
+		t.Fatal("ParseRole(root) should fail")
+	}
+}
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$600000$") || strings.Contains(hash, "correct horse") {
+		t.Fatalf("hash = %q, want an encoded PBKDF2 hash", hash)
+	}
+	other, _ := HashPassword("correct horse")
+	if other == hash {
+		t.Fatal("hashes of the same password should use different salts")
+	}
+	for _, tt := range []struct {
+		hash, password string
+		want           bool
+	}{
+		{hash, "correct horse", true},
+		{hash, "Correct horse", false},
+		{"plain", "plain", false},
+		{"pbkdf2-sha256$x$a$b", "", false},
+	} {
+		if got := VerifyPassword(tt.hash, tt.password); got != tt.want {
+			t.Errorf("VerifyPassword(%q, %q) = %v, want %v", tt.hash, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	store := NewStore(path)
+	if has, err := store.HasUsers(); err != nil || has {
+		t.Fatalf("HasUsers on a missing file = %v, %v, want false", has, err)
+	}
+	if err := store.Put("Alice", "pw1", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("bob", "pw2", RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name, password string
+		wantErr        string
+	}{
+		{"bad name!", "pw", "invalid user name"},
+		{"carol", "", "password must not be empty"},
+	} {
+		if err := store.Put(tt.name, tt.password, RoleUser); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Put(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+	if err := store.Put("carol", "pw", Role("root")); err == nil {
+		t.Error("Put with an unknown role should fail")
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("users file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "pw1") {
+		t.Fatal("users file contains a plain-text password")
+	}
+
+	user, err := store.Authenticate("alice", "pw1")
+	if err != nil || user.Name != "Alice" || user.Role != RoleAdmin {
+		t.Fatalf("Authenticate = %+v, %v, want Alice as admin", user, err)
+	}
+	if _, err := store.Authenticate("alice", "pw2"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := store.Authenticate("nobody", "pw1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("unknown user error = %v, want ErrInvalidCredentials", err)
+	}
+
+	// Another store on the same file, like the CLI, sees and makes changes.
+	other := NewStore(path)
+	if removed, err := other.Remove("BOB"); err != nil || !removed {
+		t.Fatalf("Remove = %v, %v, want true", removed, err)
+	}
+	users, err := store.List()
+	if err != nil || len(users) != 1 || users[0].Name != "Alice" {
+		t.Fatalf("List after remove = %+v, %v, want only Alice", users, err)
+	}
+	if removed, _ := other.Remove("bob"); removed {
+		t.Fatal("second Remove should report false")
+	}
+}
@@ -1,6 +1,34 @@
 package chaos
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Exploration strategies for Config.Strategy.
+const (
+	// StrategyRandom fuzzes whichever screen the host is showing.
+	StrategyRandom = "random"
+
+	// StrategyFrontier steers toward frontier areas: screens with AID keys
+	// never pressed from them or input fields barely tried. From a screen
+	// that is already explored it replays the shortest known path of
+	// transitions to the nearest frontier, then explores there.
+	StrategyFrontier = "frontier"
+)
+
+// ParseStrategy normalises a strategy name; empty means StrategyRandom.
+func ParseStrategy(name string) (string, error) {
+	switch strategy := strings.ToLower(strings.TrimSpace(name)); strategy {
+	case "":
+		return StrategyRandom, nil
+	case StrategyRandom, StrategyFrontier:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown chaos strategy %q (want %s or %s)", name, StrategyRandom, StrategyFrontier)
+	}
+}
 
 // Hint describes optional user-provided guidance for chaos exploration.
 // Transaction is typically a known transaction code, and KnownData contains
@@ -43,10 +71,47 @@ type Config struct {
 	// when no screen transition occurs.
 	ExcludeNoProgressEvents bool `json:"excludeNoProgressEvents"`
 
+	// Strategy selects where the engine explores: StrategyRandom (also when
+	// empty) or StrategyFrontier.
+	Strategy string `json:"strategy,omitempty"`
+
+	// FindingRules are the findings oracle's detection rules; see
+	// DefaultFindingRules. An empty list turns detection off.
+	FindingRules []FindingRule `json:"findingRules,omitempty"`
+
+	// ValueWeights maps value generator names, the built-in ones listed by
+	// ValueGeneratorNames or those of ValueGenerators, to relative weights.
+	// Each generated field value comes from a generator picked by weight.
+	// Empty means random values only.
+	ValueWeights map[string]int `json:"valueWeights,omitempty"`
+
+	// ValueGenerators adds custom generators, picked by name in
+	// ValueWeights. A custom generator replaces a built-in one of the same
+	// name.
+	ValueGenerators []ValueGenerator `json:"-"`
+
+	// MaskRules blank volatile parts of the screen, such as clocks and
+	// counters, before screens are hashed; see ValidateMaskRules.
+	MaskRules []MaskRule `json:"maskRules,omitempty"`
+
+	// AutoMask adds rectangles over protected text that changes between
+	// visits reached from the same screen with the same input.
+	AutoMask bool `json:"autoMask,omitempty"`
+
 	// ExportHost and ExportPort are optional metadata used when writing
 	// workflow-compatible chaos output files.
 	ExportHost string `json:"-"`
 	ExportPort int    `json:"-"`
+
+	// OnAttempt, when set, is called after each AID key is sent with the
+	// fields written and the key, before the screen is refreshed. It runs on
+	// the engine goroutine without the engine lock held.
+	OnAttempt func(Attempt) `json:"-"`
+
+	// SecretLookup, when set, finds the hidden values a resumed run
+	// generated earlier by their run-scoped names. Saved runs keep only the
+	// names; without a value a hidden field gets a fresh one.
+	SecretLookup func(name string) (string, bool) `json:"-"`
 }
 
 // DefaultConfig returns sensible defaults for a chaos exploration run.
@@ -57,6 +122,10 @@ func DefaultConfig() Config {
 		StepDelay:               500 * time.Millisecond,
 		MaxFieldLength:          40,
 		ExcludeNoProgressEvents: true,
+		Strategy:                StrategyRandom,
+		FindingRules:            DefaultFindingRules(),
+		ValueWeights:            DefaultValueWeights(),
+		AutoMask:                true,
 		AIDKeyWeights: map[string]int{
 			"Enter":  70,
 			"PF(1)":  5,
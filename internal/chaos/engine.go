@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/secrets"
 	"github.com/jnnngs/3270Web/internal/session"
 )
 
@@ -38,16 +39,28 @@ type Status struct {
 	LastAttempt    *Attempt       `json:"lastAttempt,omitempty"`
 	RecentAttempts []Attempt      `json:"recentAttempts,omitempty"`
 	MindMap        *MindMap       `json:"mindMap,omitempty"`
-	Error          string         `json:"error,omitempty"`
+	Strategy       string         `json:"strategy,omitempty"`
+	FrontierAreas  int            `json:"frontierAreas,omitempty"`
+	// Findings lists what the findings oracle detected, without the
+	// reproducing steps; see Engine.Findings.
+	Findings []Finding `json:"findings,omitempty"`
+	// MaskRules are the volatile regions blanked before hashing, including
+	// those the run detected itself.
+	MaskRules []MaskRule `json:"maskRules,omitempty"`
+	Error     string     `json:"error,omitempty"`
 }
 
 // AttemptFieldWrite captures one field write operation attempted by chaos
 // during a single step.
+//
+// For hidden fields Value holds a ${secret:NAME} placeholder instead of the
+// generated input, and Secret is set.
 type AttemptFieldWrite struct {
 	Row     int    `json:"row"`
 	Column  int    `json:"column"`
 	Length  int    `json:"length"`
 	Value   string `json:"value,omitempty"`
+	Secret  bool   `json:"secret,omitempty"`
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
 }
@@ -65,10 +78,17 @@ type Attempt struct {
 	Transitioned   bool                `json:"transitioned"`
 	Error          string              `json:"error,omitempty"`
 	FieldWrites    []AttemptFieldWrite `json:"fieldWrites,omitempty"`
+	// NavigateTo is the frontier area the step headed for when it replayed a
+	// known transition instead of exploring.
+	NavigateTo string `json:"navigateTo,omitempty"`
 }
 
 const maxRecentAttempts = 40
 
+// maxHopFailures is how many times replaying a known transition may miss
+// its destination before the frontier strategy stops routing through it.
+const maxHopFailures = 2
+
 // minPressesForPenalty is the number of times a key must be pressed from a
 // screen without causing any transition before it receives a negative boost.
 // Below this threshold the engine gives a key the benefit of the doubt; above
@@ -108,7 +128,16 @@ type Engine struct {
 	attempts       []Attempt
 	mindMap        *MindMap
 	workflowHeader *WorkflowHeader
-
+	hopFailures    map[string]int
+	findings       []Finding
+	maskRules      []MaskRule
+	masks          []screenMask
+	maskVisits     map[string]maskVisit
+	secretScope    string
+	secrets        map[string]string
+
+	findingMatchers  []findingMatcher
+	valueGenerators  []weightedValueGenerator
 	hintTransactions []string
 	hintKnownData    []string
 }
@@ -120,15 +149,27 @@ func New(h host.Host, cfg Config) *Engine {
 		seed = time.Now().UnixNano()
 	}
 	hintTransactions, hintKnownData := normalizeHints(cfg.Hints)
-	return &Engine{
+	// Rules that do not compile are dropped; callers taking rules from
+	// users check them first with ValidateFindingRules.
+	var matchers []findingMatcher
+	for _, rule := range cfg.FindingRules {
+		if compiled, err := compileFindingRules([]FindingRule{rule}); err == nil {
+			matchers = append(matchers, compiled...)
+		}
+	}
+	e := &Engine{
 		cfg:              cfg,
 		h:                h,
 		rng:              rand.New(rand.NewSource(seed)), //nolint:gosec
 		stopCh:           make(chan struct{}),
 		workflowHeader:   workflowHeaderFromConfig(cfg),
+		findingMatchers:  matchers,
+		valueGenerators:  valueGeneratorMix(cfg.ValueWeights, cfg.ValueGenerators),
 		hintTransactions: hintTransactions,
 		hintKnownData:    hintKnownData,
 	}
+	e.setMaskRulesLocked(cfg.MaskRules)
+	return e
 }
 
 // Start begins chaos exploration in a background goroutine.
@@ -159,6 +200,11 @@ func (e *Engine) Start() error {
 	e.attempts = nil
 	e.mindMap = newMindMap()
 	e.workflowHeader = workflowHeaderFromConfig(e.cfg)
+	e.hopFailures = make(map[string]int)
+	e.findings = nil
+	e.setMaskRulesLocked(e.cfg.MaskRules)
+	e.secretScope = newSecretScope()
+	e.secrets = nil
 	e.stopCh = make(chan struct{})
 
 	go e.run()
@@ -198,6 +244,10 @@ func (e *Engine) Status() Status {
 		lastAttempt = &latest
 	}
 	mindMap := e.mindMap.clone()
+	frontierAreas := 0
+	if e.frontierStrategy() {
+		frontierAreas = e.mindMap.frontierCount(e.explorationKeys())
+	}
 	return Status{
 		Active:         e.active,
 		StepsRun:       e.stepsRun,
@@ -211,6 +261,10 @@ func (e *Engine) Status() Status {
 		LastAttempt:    lastAttempt,
 		RecentAttempts: attempts,
 		MindMap:        mindMap,
+		Strategy:       e.strategy(),
+		FrontierAreas:  frontierAreas,
+		Findings:       cloneFindings(e.findings, false),
+		MaskRules:      append([]MaskRule(nil), e.maskRules...),
 		Error:          e.lastErr,
 	}
 }
@@ -236,6 +290,10 @@ func (e *Engine) ExportWorkflow(hostName string, port int) ([]byte, error) {
 	header := e.workflowHeader.clone()
 	e.mu.Unlock()
 
+	return e.marshalWorkflow(hostName, port, steps, header)
+}
+
+func (e *Engine) marshalWorkflow(hostName string, port int, steps []session.WorkflowStep, header *WorkflowHeader) ([]byte, error) {
 	if hostName == "" {
 		hostName = e.cfg.ExportHost
 	}
@@ -294,6 +352,7 @@ func (e *Engine) Snapshot(runID string) *SavedRun {
 			Transitions:   len(transitions),
 			UniqueScreens: len(hashes),
 			UniqueInputs:  len(inputs),
+			Findings:      len(e.findings),
 			Error:         e.lastErr,
 		},
 		ScreenHashes:      hashes,
@@ -304,6 +363,9 @@ func (e *Engine) Snapshot(runID string) *SavedRun {
 		UniqueInputValues: inputs,
 		Attempts:          attempts,
 		MindMap:           mindMap,
+		Findings:          cloneFindings(e.findings, true),
+		FindingRules:      append([]FindingRule(nil), e.cfg.FindingRules...),
+		MaskRules:         append([]MaskRule(nil), e.maskRules...),
 	}
 }
 
@@ -357,6 +419,11 @@ func (e *Engine) Resume(saved *SavedRun) error {
 	if e.mindMap == nil {
 		e.mindMap = newMindMap()
 	}
+	e.hopFailures = make(map[string]int)
+	e.findings = cloneFindings(saved.Findings, true)
+	e.setMaskRulesLocked(mergeMaskRules(e.cfg.MaskRules, saved.MaskRules))
+	e.secretScope = newSecretScope()
+	e.secrets = RunSecrets(saved, e.cfg.SecretLookup)
 
 	e.active = true
 	e.startedAt = time.Now()
@@ -403,6 +470,7 @@ func (e *Engine) run() {
 		// Check step and time limits.
 		e.mu.Lock()
 		steps := e.stepsRun
+		masks := e.masks
 		e.mu.Unlock()
 
 		if e.cfg.MaxSteps > 0 && steps >= e.cfg.MaxSteps {
@@ -424,14 +492,14 @@ func (e *Engine) run() {
 			return
 		}
 
-		currentHash := hashScreen(screen)
+		currentHash := hashMaskedScreen(screen, masks)
+		currentLayout := screenLayout(screen)
 		attempt := Attempt{
 			Attempt:  steps + 1,
 			Time:     time.Now(),
 			FromHash: currentHash,
 		}
 
-		// Fill unprotected fields with random values.
 		var batchSteps []session.WorkflowStep
 		fields := unprotectedFields(screen)
 		attempt.FieldsTargeted = len(fields)
@@ -442,18 +510,48 @@ func (e *Engine) run() {
 		e.mu.Lock()
 		knownValues := e.snapshotAreaValuesLocked(currentHash)
 		keyBoosts := e.snapshotKeyBoostsLocked(currentHash)
+		route := e.planRouteLocked(currentHash)
+		untried := e.untriedKeysLocked(currentHash)
 		e.mu.Unlock()
 
-		for idx, f := range fields {
-			value := e.generateValueForFieldWith(f, idx == 0, knownValues)
+		// Fill unprotected fields with random values, or with the values of
+		// the transition being replayed toward a frontier.
+		var fills []fieldFill
+		if route != nil {
+			attempt.NavigateTo = route.target
+			fills = e.routeFills(route, fields, knownValues)
+		} else {
+			for idx, f := range fields {
+				fills = append(fills, fieldFill{field: f, value: e.generateValueForFieldWith(f, idx == 0, knownValues)})
+			}
+		}
+
+		for _, fill := range fills {
+			f, value := fill.field, fill.value
 			if value == "" {
-				continue
+				// An empty value clears the field. Blanks overwrite
+				// whatever the host or an earlier attempt left there,
+				// and replay the same way as any other fill.
+				value = strings.Repeat(" ", fieldLength(f))
+				if value == "" {
+					continue
+				}
+			}
+			// Hidden input is recorded as a placeholder so generated
+			// passwords never reach steps, inputs or the mind map. The
+			// value is kept with the run under its own name for replays.
+			recorded := value
+			if f.IsHidden() && strings.TrimSpace(value) != "" {
+				e.mu.Lock()
+				recorded = secrets.Placeholder(e.storeSecretLocked(value))
+				e.mu.Unlock()
 			}
 			fieldAttempt := AttemptFieldWrite{
 				Row:    f.StartY + 1,
 				Column: f.StartX + 1,
 				Length: len(value),
-				Value:  value,
+				Value:  recorded,
+				Secret: f.IsHidden(),
 			}
 			if err := e.h.WriteStringAt(f.StartY, f.StartX, value); err != nil {
 				// Non-fatal: skip this field.
@@ -470,21 +568,37 @@ func (e *Engine) run() {
 					Row:    f.StartY + 1, // workflow uses 1-based coordinates
 					Column: f.StartX + 1,
 				},
-				Text: value,
+				Text: recorded,
 			})
 		}
 
 		// Choose and send an AID key (adaptive: prefer keys that previously
-		// caused screen transitions from the current area).
-		aidKey := e.chooseAIDKeyBoosted(keyBoosts)
+		// caused screen transitions from the current area). The frontier
+		// strategy presses the route's key, or first tries untried keys.
+		var aidKey string
+		switch {
+		case route != nil:
+			aidKey = route.hop.Key
+		case len(untried) > 0:
+			aidKey = untried[e.rng.Intn(len(untried))]
+		default:
+			aidKey = e.chooseAIDKeyBoosted(keyBoosts)
+		}
 		attempt.AIDKey = aidKey
-		if err := e.h.SendKey(aidKey); err != nil {
+		err := e.h.SendKey(aidKey)
+		if err != nil {
 			attempt.Error = err.Error()
+		}
+		if e.cfg.OnAttempt != nil {
+			e.cfg.OnAttempt(attempt)
+		}
+		if err != nil {
 			e.mu.Lock()
 			e.lastErr = err.Error()
 			e.observeMindMapAreaLocked(currentHash, screen, attempt.Time)
 			e.recordMindMapAttemptLocked(attempt)
 			e.appendAttemptLocked(attempt)
+			e.recordDisconnectLocked(currentHash, attempt, append(batchSteps, session.WorkflowStep{Type: aidKeyToStepType(aidKey)}))
 			e.mu.Unlock()
 			return
 		}
@@ -498,14 +612,32 @@ func (e *Engine) run() {
 			e.observeMindMapAreaLocked(currentHash, screen, attempt.Time)
 			e.recordMindMapAttemptLocked(attempt)
 			e.appendAttemptLocked(attempt)
+			e.recordDisconnectLocked(currentHash, attempt, batchSteps)
 			e.mu.Unlock()
 			return
 		}
 		newScreen := e.h.GetScreen()
 		newHash := ""
+		var hits []findingHit
+		findingText := ""
 		if newScreen != nil {
-			newHash = hashScreen(newScreen)
+			newHash = hashMaskedScreen(newScreen, masks)
+			if hits = screenFindings(e.findingMatchers, newScreen); len(hits) > 0 {
+				findingText = findingScreenText(newScreen)
+			}
+		}
+		if newScreen != nil && e.cfg.AutoMask {
+			e.mu.Lock()
+			if stale, ok := e.detectVolatileLocked(maskInput(currentLayout, batchSteps), newScreen, newHash); ok {
+				// Uncount the earlier visit; it was the same screen.
+				newHash = hashMaskedScreen(newScreen, e.masks)
+				if stale != newHash && stale != currentHash {
+					delete(e.screenHashes, stale)
+				}
+			}
+			e.mu.Unlock()
 		}
+		disconnected := !e.h.IsConnected()
 		attempt.ToHash = newHash
 		attempt.Transitioned = newHash != "" && newHash != currentHash
 		recordAttempt := !e.cfg.ExcludeNoProgressEvents || attempt.Transitioned || attempt.Error != ""
@@ -517,6 +649,9 @@ func (e *Engine) run() {
 			e.observeMindMapAreaLocked(newHash, newScreen, attempt.Time)
 		}
 		e.recordMindMapAttemptLocked(attempt)
+		if route != nil && newHash != route.hop.To {
+			e.hopFailures[route.hop.id()]++
+		}
 		e.stepsRun++
 		e.steps = append(e.steps, batchSteps...)
 		e.screenHashes[currentHash] = true
@@ -525,7 +660,7 @@ func (e *Engine) run() {
 		}
 		e.aidKeyCounts[aidKey]++
 		for _, bs := range batchSteps {
-			if bs.Type == "FillString" && bs.Text != "" {
+			if bs.Type == "FillString" && bs.Text != "" && !secrets.HasPlaceholder(bs.Text) {
 				e.uniqueInputs[bs.Text] = true
 			}
 		}
@@ -539,6 +674,13 @@ func (e *Engine) run() {
 		if recordAttempt {
 			e.appendAttemptLocked(attempt)
 		}
+		e.recordFindingsLocked(hits, newHash, findingText, attempt, e.steps)
+		if disconnected {
+			e.lastErr = "host disconnected"
+			e.recordFindingsLocked(disconnectFinding(e.findingMatchers), currentHash, "", attempt, e.steps)
+			e.mu.Unlock()
+			return
+		}
 		e.mu.Unlock()
 
 		// Inter-step delay (cancellable).
@@ -552,6 +694,131 @@ func (e *Engine) run() {
 	}
 }
 
+// recordDisconnectLocked raises a disconnect finding when a failed step
+// left the host disconnected. batch holds the failed step's fills and key.
+// Must be called with e.mu held.
+func (e *Engine) recordDisconnectLocked(hash string, attempt Attempt, batch []session.WorkflowStep) {
+	if e.h.IsConnected() {
+		return
+	}
+	path := append(append([]session.WorkflowStep(nil), e.steps...), batch...)
+	e.recordFindingsLocked(disconnectFinding(e.findingMatchers), hash, "", attempt, path)
+}
+
+// fieldFill is a value to type into a field before the AID key.
+type fieldFill struct {
+	field *host.Field
+	value string
+}
+
+// frontierRoute is the next step toward a frontier area: the known hop to
+// take and the fields filled when that transition was first seen.
+type frontierRoute struct {
+	hop    mindMapHop
+	target string
+	fills  []session.WorkflowStep
+}
+
+func (e *Engine) strategy() string {
+	if e.cfg.Strategy == "" {
+		return StrategyRandom
+	}
+	return e.cfg.Strategy
+}
+
+func (e *Engine) frontierStrategy() bool {
+	return e.strategy() == StrategyFrontier
+}
+
+// explorationKeys returns the configured AID keys, sorted, that a frontier
+// area still has to try.
+func (e *Engine) explorationKeys() []string {
+	keys := make([]string, 0, len(e.cfg.AIDKeyWeights))
+	for key, weight := range e.cfg.AIDKeyWeights {
+		if weight > 0 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return []string{"Enter"}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// untriedKeysLocked returns the keys never pressed from the area when the
+// frontier strategy is on. Must be called with e.mu held.
+func (e *Engine) untriedKeysLocked(hash string) []string {
+	if !e.frontierStrategy() || e.mindMap == nil {
+		return nil
+	}
+	area, ok := e.mindMap.Areas[hash]
+	if !ok || area == nil {
+		return nil
+	}
+	return area.untriedKeys(e.explorationKeys())
+}
+
+// planRouteLocked returns the next hop toward the nearest frontier when the
+// frontier strategy is on and the current area is explored, or nil to
+// explore here. Must be called with e.mu held.
+func (e *Engine) planRouteLocked(hash string) *frontierRoute {
+	if !e.frontierStrategy() || e.mindMap == nil {
+		return nil
+	}
+	keys := e.explorationKeys()
+	if e.mindMap.Areas[hash].isFrontier(keys) {
+		return nil
+	}
+	hop, target, ok := e.mindMap.frontierRoute(hash, keys, func(h mindMapHop) bool {
+		return e.hopFailures[h.id()] >= maxHopFailures
+	})
+	if !ok {
+		return nil
+	}
+	route := &frontierRoute{hop: hop, target: target}
+	keyStep := aidKeyToStepType(hop.Key)
+	for i := len(e.transitions) - 1; i >= 0; i-- {
+		t := e.transitions[i]
+		if t.FromHash != hop.From || t.ToHash != hop.To || len(t.Steps) == 0 || t.Steps[len(t.Steps)-1].Type != keyStep {
+			continue
+		}
+		for _, step := range t.Steps[:len(t.Steps)-1] {
+			if step.Type == "FillString" && step.Coordinates != nil {
+				route.fills = append(route.fills, step)
+			}
+		}
+		break
+	}
+	return route
+}
+
+// routeFills matches the route's recorded fills to the screen's fields.
+// Hidden values are replayed from the run's own secrets; placeholders the
+// run did not generate, such as those of a seeding recording, get a fresh
+// value.
+func (e *Engine) routeFills(route *frontierRoute, fields []*host.Field, knownValues map[string][]string) []fieldFill {
+	var fills []fieldFill
+	for _, step := range route.fills {
+		for _, f := range fields {
+			if f.StartY+1 != step.Coordinates.Row || f.StartX+1 != step.Coordinates.Column {
+				continue
+			}
+			value := step.Text
+			if secrets.HasPlaceholder(value) {
+				resolved, err := secrets.Resolve(value, e.secretValue)
+				if err != nil {
+					resolved = e.generateValueForFieldWith(f, false, knownValues)
+				}
+				value = resolved
+			}
+			fills = append(fills, fieldFill{field: f, value: value})
+			break
+		}
+	}
+	return fills
+}
+
 func (e *Engine) observeMindMapAreaLocked(hash string, screen *host.Screen, seenAt time.Time) {
 	if e.mindMap == nil {
 		e.mindMap = newMindMap()
@@ -698,8 +965,8 @@ func (e *Engine) generateValueForFieldWith(f *host.Field, preferTransaction bool
 	if hinted := e.hintValueForField(f, preferTransaction); hinted != "" {
 		return hinted
 	}
-	// 3. Generate a random value appropriate for the field type.
-	return e.generateValue(f)
+	// 3. Generate a value with one of the configured value generators.
+	return e.generatedValue(f)
 }
 
 func (e *Engine) hintValueForField(f *host.Field, preferTransaction bool) string {
@@ -764,43 +1031,7 @@ func fitHintValueForField(candidate string, maxLen int, numeric bool) string {
 // generateValue produces a random string appropriate for the field's
 // type and length constraints.
 func (e *Engine) generateValue(f *host.Field) string {
-	length := fieldLength(f)
-	if length <= 0 {
-		return ""
-	}
-	maxLen := e.cfg.MaxFieldLength
-	if maxLen <= 0 {
-		maxLen = 40
-	}
-	if length > maxLen {
-		length = maxLen
-	}
-
-	if f.IsNumeric() {
-		const digits = "0123456789"
-		b := make([]byte, length)
-		for i := range b {
-			b[i] = digits[e.rng.Intn(len(digits))]
-		}
-		return string(b)
-	}
-
-	// 3270 mainframe applications predominantly use uppercase input for
-	// commands, transaction codes and data.  Generating only uppercase
-	// characters and digits (plus a single space for subsequent positions)
-	// makes random values far more likely to match valid application inputs,
-	// improving the chance that each submission causes a meaningful screen
-	// transition.  The first character never uses a space because 3270
-	// command and transaction-code fields reject leading whitespace; avoiding
-	// it eliminates wasted exploration steps on those fields.
-	const charsFirst = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 "
-	b := make([]byte, length)
-	b[0] = charsFirst[e.rng.Intn(len(charsFirst))]
-	for i := 1; i < length; i++ {
-		b[i] = chars[e.rng.Intn(len(chars))]
-	}
-	return string(b)
+	return randomValue(e.rng, e.fieldInput(f).Length, f.IsNumeric())
 }
 
 // chooseAIDKey selects an AID key using the configured weights.
@@ -882,8 +1113,13 @@ func hashScreen(s *host.Screen) string {
 	if s == nil {
 		return ""
 	}
+	return hashScreenText(s, s.Text())
+}
+
+// hashScreenText is hashScreen with text standing in for the screen text.
+func hashScreenText(s *host.Screen, text string) string {
 	h := sha256.New()
-	fmt.Fprintf(h, "%s|%d", s.Text(), len(s.Fields))
+	fmt.Fprintf(h, "%s|%d", text, len(s.Fields))
 	for _, f := range s.Fields {
 		if f == nil {
 			continue
@@ -0,0 +1,106 @@
+package chaos
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+func TestValidateFindingRules(t *testing.T) {
+	if err := ValidateFindingRules(DefaultFindingRules()); err != nil {
+		t.Fatalf("default rules: %v", err)
+	}
+	for _, rules := range [][]FindingRule{
+		{{Name: "", Kind: FindingText, Pattern: "ABEND"}},
+		{{Name: "bad", Kind: FindingText, Pattern: "("}},
+		{{Name: "empty", Kind: FindingText}},
+		{{Name: "odd", Kind: "smell"}},
+	} {
+		if err := ValidateFindingRules(rules); err == nil {
+			t.Errorf("ValidateFindingRules(%+v) = nil, want error", rules)
+		}
+	}
+}
+
+func TestEngineFindings(t *testing.T) {
+	h, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := buildMockScreen()
+	copy(s.Buffer[5], []rune("DFHAC2206 TRANSACTION ABENDED ASRA"))
+	copy(s.Buffer[22], []rune("INVALID ACCOUNT"))
+	s.Fields = append(s.Fields, host.NewField(s, host.AttrProtected|host.AttrDisp1, 0, 22, 19, 22, host.AttrColRed, 0))
+	h.Screen = s
+	h.Connected = true
+
+	cfg := DefaultConfig()
+	cfg.MaxSteps = 5
+	cfg.StepDelay = 0
+	cfg.Seed = 3
+	cfg.OnAttempt = func(a Attempt) {
+		if a.Attempt == 3 {
+			h.Connected = false
+		}
+	}
+
+	e := New(h, cfg)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Status().Active && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	st := e.Status()
+	if st.StepsRun != 3 || st.Error != "host disconnected" {
+		t.Fatalf("run stopped after %d steps with %q, want 3 and host disconnected", st.StepsRun, st.Error)
+	}
+	byRule := map[string]Finding{}
+	for _, f := range e.Findings() {
+		byRule[f.Rule] = f
+	}
+	for _, rule := range []string{"ABEND", "CICS abend message", "Red error message", "Unexpected disconnect"} {
+		if _, ok := byRule[rule]; !ok {
+			t.Fatalf("findings %+v missing rule %q", byRule, rule)
+		}
+	}
+	if _, ok := byRule["Data set error"]; ok {
+		t.Fatal("IEC rule fired without an IEC message")
+	}
+
+	cics := byRule["CICS abend message"]
+	if cics.Occurrences != 3 || cics.Attempt != 1 || !strings.HasPrefix(cics.Detail, "DFHAC2206") {
+		t.Fatalf("CICS finding = %+v, want 3 occurrences first seen at attempt 1", cics)
+	}
+	if cics.StepCount != 2 || len(cics.Steps) != 2 || cics.Steps[0].Type != "FillString" || !strings.HasPrefix(cics.Steps[1].Type, "Press") {
+		t.Fatalf("CICS reproducer = %+v, want the first fill and key", cics.Steps)
+	}
+	if red := byRule["Red error message"]; red.Detail != "INVALID ACCOUNT" {
+		t.Fatalf("red message detail = %q", red.Detail)
+	}
+	if disconnect := byRule["Unexpected disconnect"]; disconnect.StepCount != 6 {
+		t.Fatalf("disconnect reproducer has %d steps, want all 6", disconnect.StepCount)
+	}
+	for _, f := range st.Findings {
+		if f.Steps != nil || f.StepCount == 0 {
+			t.Fatalf("status finding %+v should carry a step count but no steps", f)
+		}
+	}
+
+	data, err := e.ExportFinding(cics.ID, "host.example.com", 23)
+	if err != nil || !strings.Contains(string(data), `"Host": "host.example.com"`) || !strings.Contains(string(data), "FillString") {
+		t.Fatalf("ExportFinding = %s, %v", data, err)
+	}
+	if _, err := e.ExportFinding("F99", "", 0); err == nil {
+		t.Fatal("ExportFinding of an unknown finding should fail")
+	}
+
+	saved := e.Snapshot("findings-run")
+	if saved.SavedRunMeta.Findings != 4 || len(saved.Findings) != 4 || len(saved.Findings[0].Steps) == 0 {
+		t.Fatalf("snapshot findings = %d/%d, want 4 with steps", saved.SavedRunMeta.Findings, len(saved.Findings))
+	}
+}
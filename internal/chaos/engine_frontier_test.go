@@ -0,0 +1,111 @@
+package chaos
+
+import (
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// exploredArea returns an area where every key in keys has been pressed and
+// its one input field filled often enough to no longer be a frontier.
+func exploredArea(m *MindMap, hash string, keys []string) *MindMapArea {
+	area := m.ensureArea(hash)
+	area.FieldMetadata = map[string]MindMapFieldMetadata{
+		"R3C11L10": {Row: 3, Column: 11, Length: 10},
+	}
+	area.FieldFills = map[string]int{"R3C11": frontierFieldFills}
+	for _, key := range keys {
+		area.KeyPresses[key] = &MindMapKeyPress{Presses: 1}
+	}
+	return area
+}
+
+func TestMindMapFrontierRoute(t *testing.T) {
+	keys := []string{"Enter", "PF(3)"}
+	m := newMindMap()
+	menu := exploredArea(m, "menu", keys)
+	list := exploredArea(m, "list", keys)
+	m.ensureArea("detail")
+	menu.KeyPresses["Enter"].Destinations = map[string]int{"list": 1}
+	menu.KeyPresses["PF(3)"].Destinations = map[string]int{"menu": 1}
+	list.KeyPresses["Enter"].Destinations = map[string]int{"detail": 1}
+	list.KeyPresses["PF(3)"].Destinations = map[string]int{"menu": 1}
+
+	if menu.isFrontier(keys) || list.isFrontier(keys) {
+		t.Fatal("explored areas reported as frontiers")
+	}
+	if !m.Areas["detail"].isFrontier(keys) {
+		t.Fatal("area with untried keys should be a frontier")
+	}
+	if got := m.frontierCount(keys); got != 1 {
+		t.Fatalf("frontierCount = %d, want 1", got)
+	}
+
+	hop, target, ok := m.frontierRoute("menu", keys, nil)
+	if !ok || target != "detail" || hop != (mindMapHop{From: "menu", Key: "Enter", To: "list"}) {
+		t.Fatalf("frontierRoute = %+v, %q, %v; want menu Enter list toward detail", hop, target, ok)
+	}
+
+	skip := func(h mindMapHop) bool { return h.id() == "list|Enter|detail" }
+	if _, _, ok := m.frontierRoute("menu", keys, skip); ok {
+		t.Fatal("frontierRoute should fail when the only hop to the frontier is skipped")
+	}
+
+	list.FieldFills["R3C11"] = frontierFieldFills - 1
+	if _, target, _ := m.frontierRoute("menu", keys, nil); target != "list" {
+		t.Fatalf("frontierRoute target = %q, want the nearer under-filled list area", target)
+	}
+}
+
+func TestPlanRouteLocked(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AIDKeyWeights = map[string]int{"Enter": 1, "PF(3)": 1, "Clear": 0}
+	e := New(nil, cfg)
+	e.mindMap = newMindMap()
+	e.hopFailures = make(map[string]int)
+	keys := e.explorationKeys()
+	if len(keys) != 2 || keys[0] != "Enter" || keys[1] != "PF(3)" {
+		t.Fatalf("explorationKeys = %v, want [Enter PF(3)]", keys)
+	}
+	menu := exploredArea(e.mindMap, "menu", keys)
+	menu.KeyPresses["Enter"].Destinations = map[string]int{"list": 1}
+	e.mindMap.ensureArea("list")
+	e.transitions = []Transition{{
+		FromHash: "menu",
+		ToHash:   "list",
+		Steps: []session.WorkflowStep{
+			{Type: "FillString", Coordinates: &session.WorkflowCoordinates{Row: 3, Column: 11}, Text: "LIST"},
+			{Type: "PressEnter"},
+		},
+	}}
+
+	if route := e.planRouteLocked("menu"); route != nil {
+		t.Fatalf("random strategy planned a route: %+v", route)
+	}
+
+	e.cfg.Strategy = StrategyFrontier
+	route := e.planRouteLocked("menu")
+	if route == nil || route.target != "list" || route.hop.Key != "Enter" {
+		t.Fatalf("planRouteLocked = %+v, want Enter toward list", route)
+	}
+	if len(route.fills) != 1 || route.fills[0].Text != "LIST" {
+		t.Fatalf("route fills = %+v, want the recorded LIST fill", route.fills)
+	}
+	screen := buildMockScreen()
+	fills := e.routeFills(route, unprotectedFields(screen), nil)
+	if len(fills) != 1 || fills[0].value != "LIST" || fills[0].field.StartY != 2 {
+		t.Fatalf("routeFills = %+v, want LIST in the row 3 field", fills)
+	}
+
+	if route := e.planRouteLocked("list"); route != nil {
+		t.Fatalf("planned a route away from a frontier: %+v", route)
+	}
+	if untried := e.untriedKeysLocked("list"); len(untried) != 2 {
+		t.Fatalf("untriedKeysLocked = %v, want both keys", untried)
+	}
+
+	e.hopFailures["menu|Enter|list"] = maxHopFailures
+	if route := e.planRouteLocked("menu"); route != nil {
+		t.Fatalf("planned a route through a failing hop: %+v", route)
+	}
+}
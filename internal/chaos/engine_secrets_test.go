@@ -0,0 +1,100 @@
+package chaos
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/secrets"
+)
+
+func TestEngineRecordsHiddenInputAsSecretPlaceholder(t *testing.T) {
+	h, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := buildMockScreen()
+	copy(s.Buffer[3], []rune("PASSWORD"))
+	s.Fields = append(s.Fields, host.NewField(s, host.AttrDisp1|host.AttrDisp2, 10, 3, 19, 3, 0, 0))
+	h.Screen = s
+	h.Connected = true
+
+	cfg := DefaultConfig()
+	cfg.MaxSteps = 2
+	cfg.StepDelay = 0
+	cfg.Seed = 7
+	cfg.ExcludeNoProgressEvents = false
+
+	e := New(h, cfg)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Status().Active && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	snapshot := e.Snapshot("secret-test")
+	generated := e.Secrets()
+	names := make(map[string]bool)
+	for _, step := range snapshot.Steps {
+		if step.Type != "FillString" || step.Coordinates.Row != 4 {
+			continue
+		}
+		found := secrets.Names(step.Text)
+		if len(found) != 1 || secrets.Placeholder(found[0]) != step.Text || !strings.HasPrefix(found[0], secretPrefix) {
+			t.Fatalf("hidden FillString Text = %q, want a run-scoped placeholder", step.Text)
+		}
+		if names[found[0]] {
+			t.Fatalf("placeholder %q reused for another fill", step.Text)
+		}
+		names[found[0]] = true
+		if value := generated[found[0]]; value == "" || secrets.HasPlaceholder(value) {
+			t.Fatalf("run secret %s = %q, want the generated value", found[0], value)
+		}
+	}
+	if len(names) == 0 {
+		t.Fatal("expected FillString steps for the hidden field")
+	}
+	if len(generated) != len(names) {
+		t.Fatalf("run kept %d secrets for %d hidden fills", len(generated), len(names))
+	}
+	saved, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range generated {
+		if strings.Contains(string(saved), value) {
+			t.Fatalf("saved run contains the value of %s", name)
+		}
+	}
+	for value := range snapshot.UniqueInputValues {
+		if strings.Contains(value, "secret:") {
+			t.Fatalf("unique inputs include placeholder %q", value)
+		}
+	}
+	for _, attempt := range snapshot.Attempts {
+		for _, fw := range attempt.FieldWrites {
+			if fw.Row == 4 && (!fw.Secret || !names[strings.TrimSuffix(strings.TrimPrefix(fw.Value, "${secret:"), "}")]) {
+				t.Fatalf("hidden field write = %+v, want a run secret placeholder", fw)
+			}
+		}
+	}
+
+	cfg.SecretLookup = func(name string) (string, bool) {
+		value, ok := generated[name]
+		return value, ok
+	}
+	resumed := New(h, cfg)
+	if err := resumed.Resume(snapshot); err != nil {
+		t.Fatalf("Resume() error: %v", err)
+	}
+	resumed.Stop()
+	for name, value := range generated {
+		if got, ok := resumed.secretValue(name); !ok || got != value {
+			t.Fatalf("resumed secret %s = %q, %v, want %q", name, got, ok, value)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package chaos
 import (
 	"encoding/json"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
@@ -450,6 +451,47 @@ func TestEngineStatusIncludesAttemptDetails(t *testing.T) {
 	}
 }
 
+func TestEngineOnAttempt(t *testing.T) {
+	h, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Screen = buildMockScreen()
+	h.Connected = true
+
+	var mu sync.Mutex
+	var seen []Attempt
+	cfg := DefaultConfig()
+	cfg.MaxSteps = 3
+	cfg.StepDelay = 0
+	cfg.Seed = 7
+	cfg.OnAttempt = func(a Attempt) {
+		mu.Lock()
+		seen = append(seen, a)
+		mu.Unlock()
+	}
+
+	e := New(h, cfg)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && e.Status().Active {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 3 {
+		t.Fatalf("OnAttempt called %d times, want 3", len(seen))
+	}
+	for i, a := range seen {
+		if a.Attempt != i+1 || a.AIDKey == "" || len(a.FieldWrites) == 0 {
+			t.Errorf("attempt %d = %+v, want its number, key and field writes", i, a)
+		}
+	}
+}
+
 func TestEngineStatusIncludesMindMap(t *testing.T) {
 	h, err := host.NewMockHost("")
 	if err != nil {
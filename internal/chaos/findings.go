@@ -0,0 +1,314 @@
+package chaos
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// Finding rule kinds for FindingRule.Kind.
+const (
+	// FindingText matches Pattern, a regular expression, against the
+	// screen text.
+	FindingText = "text"
+
+	// FindingRedMessage matches protected fields shown red and intensified
+	// that hold text, the usual look of host error messages.
+	FindingRedMessage = "red-message"
+
+	// FindingKeyboardLocked matches a screen left with the keyboard locked
+	// (X SYSTEM) after the host has answered.
+	FindingKeyboardLocked = "keyboard-locked"
+
+	// FindingDisconnect matches the host dropping the connection.
+	FindingDisconnect = "disconnect"
+)
+
+// maxFindings caps how many distinct findings a run keeps.
+const maxFindings = 100
+
+// maxFindingDetail caps the matched text stored with a finding.
+const maxFindingDetail = 160
+
+// FindingRule is one detection rule of the findings oracle.
+type FindingRule struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Finding is a suspected bug the oracle detected: which rule fired, where,
+// and the steps from the start of the run that lead there.
+type Finding struct {
+	ID          string    `json:"id"`
+	Rule        string    `json:"rule"`
+	Kind        string    `json:"kind"`
+	Detail      string    `json:"detail,omitempty"`
+	ScreenHash  string    `json:"screenHash,omitempty"`
+	Screen      string    `json:"screen,omitempty"`
+	Attempt     int       `json:"attempt"`
+	FoundAt     time.Time `json:"foundAt"`
+	Occurrences int       `json:"occurrences"`
+	// StepCount is len(Steps); it stays set when Status leaves Steps out.
+	StepCount int `json:"stepCount"`
+	// Steps reproduce the finding when replayed from the screen the run
+	// started on. Later occurrences reached in fewer steps replace them.
+	Steps []session.WorkflowStep `json:"steps,omitempty"`
+}
+
+// DefaultFindingRules returns the rules used when a run does not configure
+// its own: common CICS and z/OS failure messages, red error messages, a
+// stuck keyboard and dropped connections.
+func DefaultFindingRules() []FindingRule {
+	return []FindingRule{
+		{Name: "ABEND", Kind: FindingText, Pattern: `\bABEND`},
+		{Name: "CICS abend message", Kind: FindingText, Pattern: `\bDFHAC\d{4}`},
+		{Name: "Data set error", Kind: FindingText, Pattern: `\bIEC\d{3}[A-Z]\b`},
+		{Name: "Red error message", Kind: FindingRedMessage},
+		{Name: "Keyboard locked (X SYSTEM)", Kind: FindingKeyboardLocked},
+		{Name: "Unexpected disconnect", Kind: FindingDisconnect},
+	}
+}
+
+// ValidateFindingRules reports the first rule that is missing a name, has
+// an unknown kind, or has a text pattern that does not compile.
+func ValidateFindingRules(rules []FindingRule) error {
+	_, err := compileFindingRules(rules)
+	return err
+}
+
+// findingMatcher is a FindingRule ready to run against screens.
+type findingMatcher struct {
+	rule FindingRule
+	re   *regexp.Regexp
+}
+
+// findingHit is one rule firing on one screen.
+type findingHit struct {
+	rule   FindingRule
+	detail string
+}
+
+func compileFindingRules(rules []FindingRule) ([]findingMatcher, error) {
+	matchers := make([]findingMatcher, 0, len(rules))
+	for i, rule := range rules {
+		rule.Name = strings.TrimSpace(rule.Name)
+		if rule.Name == "" {
+			return nil, fmt.Errorf("finding rule %d: name is required", i+1)
+		}
+		m := findingMatcher{rule: rule}
+		switch rule.Kind {
+		case FindingText:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil || rule.Pattern == "" {
+				return nil, fmt.Errorf("finding rule %q: invalid pattern %q", rule.Name, rule.Pattern)
+			}
+			m.re = re
+		case FindingRedMessage, FindingKeyboardLocked, FindingDisconnect:
+		default:
+			return nil, fmt.Errorf("finding rule %q: unknown kind %q", rule.Name, rule.Kind)
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// screenFindings runs the screen rules against s.
+func screenFindings(matchers []findingMatcher, s *host.Screen) []findingHit {
+	if s == nil {
+		return nil
+	}
+	text := findingScreenText(s)
+	var hits []findingHit
+	for _, m := range matchers {
+		switch m.rule.Kind {
+		case FindingText:
+			if match := m.re.FindString(text); match != "" {
+				hits = append(hits, findingHit{rule: m.rule, detail: findingLine(text, match)})
+			}
+		case FindingRedMessage:
+			for _, f := range s.Fields {
+				if f == nil || !f.IsProtected() || !f.IsIntensified() || f.Color != host.AttrColRed {
+					continue
+				}
+				if value := strings.TrimSpace(strings.ReplaceAll(f.GetValue(), "\x00", " ")); value != "" {
+					hits = append(hits, findingHit{rule: m.rule, detail: truncateFindingDetail(value)})
+					break
+				}
+			}
+		case FindingKeyboardLocked:
+			if state, ok := s.StatusKeyboardState(); ok && state != "U" {
+				hits = append(hits, findingHit{rule: m.rule, detail: "keyboard state " + state})
+			}
+		}
+	}
+	return hits
+}
+
+// disconnectFinding returns the disconnect rule as a hit, if configured.
+func disconnectFinding(matchers []findingMatcher) []findingHit {
+	for _, m := range matchers {
+		if m.rule.Kind == FindingDisconnect {
+			return []findingHit{{rule: m.rule, detail: "host closed the connection"}}
+		}
+	}
+	return nil
+}
+
+// findingScreenText returns the screen text with hidden fields blanked, so
+// findings never carry passwords typed into them.
+func findingScreenText(s *host.Screen) string {
+	rows := make([][]rune, len(s.Buffer))
+	for y, row := range s.Buffer {
+		rows[y] = append([]rune(nil), row...)
+	}
+	for _, f := range s.Fields {
+		if f == nil || !f.IsHidden() {
+			continue
+		}
+		for y := f.StartY; y <= f.EndY && y < len(rows); y++ {
+			startX, endX := 0, len(rows[y])-1
+			if y == f.StartY {
+				startX = f.StartX
+			}
+			if y == f.EndY && f.EndX < endX {
+				endX = f.EndX
+			}
+			for x := startX; x <= endX && x >= 0; x++ {
+				rows[y][x] = ' '
+			}
+		}
+	}
+	lines := make([]string, len(rows))
+	for y, row := range rows {
+		lines[y] = strings.TrimRight(strings.Map(func(r rune) rune {
+			if r == 0 {
+				return ' '
+			}
+			return r
+		}, string(row)), " ")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// findingLine returns the trimmed screen line holding match.
+func findingLine(text, match string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.Contains(line, match) {
+			return truncateFindingDetail(strings.TrimSpace(line))
+		}
+	}
+	return truncateFindingDetail(match)
+}
+
+func truncateFindingDetail(detail string) string {
+	if runes := []rune(detail); len(runes) > maxFindingDetail {
+		return string(runes[:maxFindingDetail])
+	}
+	return detail
+}
+
+// sameFinding reports whether a hit on screen hash repeats f. Message
+// rules repeat when they match the same text, since chaos input changes
+// the screen hash; the others when they fire on the same screen.
+func sameFinding(f Finding, hit findingHit, hash string) bool {
+	if f.Rule != hit.rule.Name {
+		return false
+	}
+	switch hit.rule.Kind {
+	case FindingText, FindingRedMessage:
+		return f.Detail == hit.detail
+	default:
+		return f.ScreenHash == hash
+	}
+}
+
+// recordFindingsLocked adds hits seen at attempt on screen hash, reached by
+// path. A hit repeating an earlier finding counts as another occurrence of
+// it. Must be called with e.mu held.
+func (e *Engine) recordFindingsLocked(hits []findingHit, hash, screen string, attempt Attempt, path []session.WorkflowStep) {
+	for _, hit := range hits {
+		existing := -1
+		for i := range e.findings {
+			if sameFinding(e.findings[i], hit, hash) {
+				existing = i
+				break
+			}
+		}
+		if existing >= 0 {
+			f := &e.findings[existing]
+			f.Occurrences++
+			if len(path) < len(f.Steps) {
+				f.Steps = append([]session.WorkflowStep(nil), path...)
+				f.StepCount = len(f.Steps)
+				f.Attempt = attempt.Attempt
+			}
+			continue
+		}
+		if len(e.findings) >= maxFindings {
+			continue
+		}
+		e.findings = append(e.findings, Finding{
+			ID:          fmt.Sprintf("F%d", len(e.findings)+1),
+			Rule:        hit.rule.Name,
+			Kind:        hit.rule.Kind,
+			Detail:      hit.detail,
+			ScreenHash:  hash,
+			Screen:      screen,
+			Attempt:     attempt.Attempt,
+			FoundAt:     attempt.Time,
+			Occurrences: 1,
+			StepCount:   len(path),
+			Steps:       append([]session.WorkflowStep(nil), path...),
+		})
+	}
+}
+
+// Findings returns the findings detected so far, with their steps.
+func (e *Engine) Findings() []Finding {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return cloneFindings(e.findings, true)
+}
+
+// ExportFinding returns the steps that reproduce finding id as workflow
+// JSON, like ExportWorkflow.
+func (e *Engine) ExportFinding(id, hostName string, port int) ([]byte, error) {
+	e.mu.Lock()
+	var steps []session.WorkflowStep
+	found := false
+	for _, f := range e.findings {
+		if f.ID == id {
+			steps = append([]session.WorkflowStep(nil), f.Steps...)
+			found = true
+			break
+		}
+	}
+	header := e.workflowHeader.clone()
+	e.mu.Unlock()
+	if !found {
+		return nil, fmt.Errorf("finding %q not found", id)
+	}
+	return e.marshalWorkflow(hostName, port, steps, header)
+}
+
+// cloneFindings copies findings, leaving the steps out unless withSteps.
+func cloneFindings(findings []Finding, withSteps bool) []Finding {
+	if len(findings) == 0 {
+		return nil
+	}
+	out := make([]Finding, len(findings))
+	copy(out, findings)
+	for i := range out {
+		if withSteps {
+			out[i].Steps = append([]session.WorkflowStep(nil), findings[i].Steps...)
+		} else {
+			out[i].Steps = nil
+		}
+	}
+	return out
+}
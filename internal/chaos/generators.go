@@ -0,0 +1,505 @@
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+// Built-in value generator names for Config.ValueWeights.
+const (
+	// ValueRandom fills the field with random uppercase letters and digits,
+	// or digits for numeric fields.
+	ValueRandom = "random"
+
+	// ValueBoundary uses lengths at the edges of the field: one character,
+	// one short of full, and full.
+	ValueBoundary = "boundary"
+
+	// ValueEmptyMax clears the field or fills every position with the
+	// same character.
+	ValueEmptyMax = "empty-max"
+
+	// ValueNumber uses zero, negative and overflowing numbers.
+	ValueNumber = "number"
+
+	// ValueDate uses valid, edge and invalid dates in several formats.
+	ValueDate = "date"
+
+	// ValueSpecial uses characters that often break EBCDIC code page
+	// conversion or input editing, such as ¢ ¬ | [ ] and lowercase.
+	ValueSpecial = "special"
+
+	// ValueLabel infers the field's type from the label in front of it,
+	// such as Account, Date, Amount or Name, and fills a value of that type.
+	ValueLabel = "label"
+)
+
+// FieldInput describes the input field a ValueGenerator fills.
+type FieldInput struct {
+	// Length is how many characters fit, capped at Config.MaxFieldLength.
+	Length int
+	// Numeric is set for fields that only accept digits, '-' and '.'.
+	Numeric bool
+	// Hidden is set for non-display fields such as passwords.
+	Hidden bool
+	// Label is the protected text in front of the field, such as
+	// "Account number", or the text above it for column layouts.
+	Label string
+}
+
+// ValueGenerator produces values for input fields. Generate returns ok
+// false when it has nothing suited to the field, and the engine falls back
+// to random values. An empty value with ok true leaves the field blank.
+// Values longer than the field are cut to fit.
+type ValueGenerator interface {
+	Name() string
+	Generate(rng *rand.Rand, in FieldInput) (value string, ok bool)
+}
+
+// valueGeneratorFunc adapts a function to ValueGenerator.
+type valueGeneratorFunc struct {
+	name string
+	fn   func(rng *rand.Rand, in FieldInput) (string, bool)
+}
+
+func (g valueGeneratorFunc) Name() string { return g.name }
+
+func (g valueGeneratorFunc) Generate(rng *rand.Rand, in FieldInput) (string, bool) {
+	return g.fn(rng, in)
+}
+
+// builtinValueGenerators lists the generators selectable by name.
+var builtinValueGenerators = []ValueGenerator{
+	valueGeneratorFunc{ValueRandom, randomGeneratedValue},
+	valueGeneratorFunc{ValueBoundary, boundaryValue},
+	valueGeneratorFunc{ValueEmptyMax, emptyMaxValue},
+	valueGeneratorFunc{ValueNumber, numberValue},
+	valueGeneratorFunc{ValueDate, dateValue},
+	valueGeneratorFunc{ValueSpecial, specialValue},
+	valueGeneratorFunc{ValueLabel, labelValue},
+}
+
+// ValueGeneratorNames returns the built-in generator names, sorted.
+func ValueGeneratorNames() []string {
+	names := make([]string, len(builtinValueGenerators))
+	for i, g := range builtinValueGenerators {
+		names[i] = g.Name()
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultValueWeights returns the generator mix used when a run does not
+// set its own: mostly random values, with the other strategies mixed in.
+func DefaultValueWeights() map[string]int {
+	return map[string]int{
+		ValueRandom:   60,
+		ValueLabel:    15,
+		ValueBoundary: 8,
+		ValueNumber:   5,
+		ValueEmptyMax: 4,
+		ValueDate:     4,
+		ValueSpecial:  4,
+	}
+}
+
+// ValidateValueWeights reports unknown built-in generator names and
+// negative weights.
+func ValidateValueWeights(weights map[string]int) error {
+	known := make(map[string]bool, len(builtinValueGenerators))
+	for _, g := range builtinValueGenerators {
+		known[g.Name()] = true
+	}
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("unknown value generator %q (want one of %s)", name, strings.Join(ValueGeneratorNames(), ", "))
+		}
+		if weights[name] < 0 {
+			return fmt.Errorf("value generator %q: weight must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// ParseValueWeights parses "name=weight" pairs separated by commas, such
+// as "random=50,boundary=20".
+func ParseValueWeights(spec string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid value weight %q (want name=weight)", part)
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+	if err := ValidateValueWeights(weights); err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+// weightedValueGenerator is a generator with its share of the picks.
+type weightedValueGenerator struct {
+	gen    ValueGenerator
+	weight int
+}
+
+// valueGeneratorMix resolves weights against the built-in and custom
+// generators, sorted by name so picks are deterministic for a seed.
+// Unknown names and weights below one are left out.
+func valueGeneratorMix(weights map[string]int, custom []ValueGenerator) []weightedValueGenerator {
+	byName := make(map[string]ValueGenerator, len(builtinValueGenerators)+len(custom))
+	for _, g := range builtinValueGenerators {
+		byName[g.Name()] = g
+	}
+	for _, g := range custom {
+		if g != nil {
+			byName[g.Name()] = g
+		}
+	}
+	var mix []weightedValueGenerator
+	for name, weight := range weights {
+		if g, ok := byName[name]; ok && weight > 0 {
+			mix = append(mix, weightedValueGenerator{gen: g, weight: weight})
+		}
+	}
+	sort.Slice(mix, func(i, j int) bool { return mix[i].gen.Name() < mix[j].gen.Name() })
+	return mix
+}
+
+// pickValueGenerator chooses a generator by weight, or nil if none is
+// configured.
+func (e *Engine) pickValueGenerator() ValueGenerator {
+	total := 0
+	for _, w := range e.valueGenerators {
+		total += w.weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	pick := e.rng.Intn(total)
+	for _, w := range e.valueGenerators {
+		if pick < w.weight {
+			return w.gen
+		}
+		pick -= w.weight
+	}
+	return nil
+}
+
+// generatedValue fills f with a value from a weighted generator, falling
+// back to generateValue when none is configured or the pick declines.
+func (e *Engine) generatedValue(f *host.Field) string {
+	in := e.fieldInput(f)
+	if in.Length <= 0 {
+		return ""
+	}
+	if gen := e.pickValueGenerator(); gen != nil {
+		if value, ok := gen.Generate(e.rng, in); ok {
+			return fitGeneratedValue(value, in)
+		}
+	}
+	return e.generateValue(f)
+}
+
+func (e *Engine) fieldInput(f *host.Field) FieldInput {
+	length := fieldLength(f)
+	maxLen := e.cfg.MaxFieldLength
+	if maxLen <= 0 {
+		maxLen = 40
+	}
+	if length > maxLen {
+		length = maxLen
+	}
+	return FieldInput{Length: length, Numeric: f.IsNumeric(), Hidden: f.IsHidden(), Label: fieldLabel(f)}
+}
+
+// fitGeneratedValue cuts value to the field and, for numeric fields, drops
+// characters the terminal would refuse.
+func fitGeneratedValue(value string, in FieldInput) string {
+	if in.Numeric {
+		value = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' || r == '-' || r == '.' {
+				return r
+			}
+			return -1
+		}, value)
+	}
+	if runes := []rune(value); len(runes) > in.Length {
+		value = string(runes[:in.Length])
+	}
+	return value
+}
+
+// fieldLabel returns the protected text in front of f on its row, back to
+// the previous input field, or else the text above the field.
+func fieldLabel(f *host.Field) string {
+	s := f.Screen
+	if s == nil || f.StartY < 0 || f.StartY >= len(s.Buffer) {
+		return ""
+	}
+	start := 0
+	for _, other := range s.Fields {
+		if other != nil && other != f && !other.IsProtected() && other.EndY == f.StartY && other.EndX < f.StartX && other.EndX+1 > start {
+			start = other.EndX + 1
+		}
+	}
+	if label := labelText(s.Buffer[f.StartY], start, f.StartX-1); label != "" {
+		return label
+	}
+	if f.StartY > 0 {
+		return labelText(s.Buffer[f.StartY-1], f.StartX, f.StartX+fieldLength(f))
+	}
+	return ""
+}
+
+// labelText returns row[from:to] with NULs, leader dots and trailing
+// colons removed.
+func labelText(row []rune, from, to int) string {
+	if to > len(row) {
+		to = len(row)
+	}
+	if from < 0 || from >= to {
+		return ""
+	}
+	text := strings.ReplaceAll(string(row[from:to]), "\x00", " ")
+	text = strings.TrimRight(strings.TrimSpace(text), ".:=> ")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// randomValue is the random strategy; see generateValue.
+func randomValue(rng *rand.Rand, length int, numeric bool) string {
+	if length <= 0 {
+		return ""
+	}
+	if numeric {
+		const digits = "0123456789"
+		b := make([]byte, length)
+		for i := range b {
+			b[i] = digits[rng.Intn(len(digits))]
+		}
+		return string(b)
+	}
+
+	// 3270 mainframe applications predominantly use uppercase input for
+	// commands, transaction codes and data.  Generating only uppercase
+	// characters and digits (plus a single space for subsequent positions)
+	// makes random values far more likely to match valid application inputs,
+	// improving the chance that each submission causes a meaningful screen
+	// transition.  The first character never uses a space because 3270
+	// command and transaction-code fields reject leading whitespace; avoiding
+	// it eliminates wasted exploration steps on those fields.
+	const charsFirst = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 "
+	b := make([]byte, length)
+	b[0] = charsFirst[rng.Intn(len(charsFirst))]
+	for i := 1; i < length; i++ {
+		b[i] = chars[rng.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+func randomGeneratedValue(rng *rand.Rand, in FieldInput) (string, bool) {
+	return randomValue(rng, in.Length, in.Numeric), true
+}
+
+func boundaryValue(rng *rand.Rand, in FieldInput) (string, bool) {
+	lengths := []int{1, in.Length - 1, in.Length}
+	length := lengths[rng.Intn(len(lengths))]
+	if length < 1 {
+		length = 1
+	}
+	return randomValue(rng, length, in.Numeric), true
+}
+
+func emptyMaxValue(rng *rand.Rand, in FieldInput) (string, bool) {
+	if rng.Intn(2) == 0 {
+		return "", true
+	}
+	fill := "9"
+	if !in.Numeric {
+		fill = []string{"X", "9", "Z"}[rng.Intn(3)]
+	}
+	return strings.Repeat(fill, in.Length), true
+}
+
+// edgeNumbers are zero, signs and the limits of common binary and packed
+// decimal fields.
+var edgeNumbers = []string{
+	"0", "-0", "-1", "1", "-999", "0.5", "-0.01",
+	"32767", "32768", "-32769", "65536",
+	"2147483647", "2147483648", "-2147483649", "4294967296",
+	"9223372036854775808", "99999999999999999999",
+}
+
+func numberValue(rng *rand.Rand, in FieldInput) (string, bool) {
+	candidates := []string{strings.Repeat("9", in.Length)}
+	if in.Length > 1 {
+		candidates = append(candidates, "-"+strings.Repeat("9", in.Length-1))
+	}
+	for _, n := range edgeNumbers {
+		if len(n) <= in.Length {
+			candidates = append(candidates, n)
+		}
+	}
+	return candidates[rng.Intn(len(candidates))], true
+}
+
+// dateLayouts are common mainframe date formats. DDD is the day of the
+// year, as in Julian YYDDD dates.
+var dateLayouts = []string{
+	"YYYY-MM-DD", "DD/MM/YYYY", "MM/DD/YYYY", "DD.MM.YYYY", "YYYYMMDD",
+	"DDMMYYYY", "MM/DD/YY", "DD/MM/YY", "YYMMDD", "DDMMYY", "YYDDD", "YYYYDDD",
+}
+
+// edgeDates are valid dates that often expose bugs: leap days, century
+// and epoch limits. invalidDates cannot exist.
+var (
+	edgeDates    = [][3]int{{2000, 2, 29}, {1999, 12, 31}, {2000, 1, 1}, {1900, 1, 1}, {2038, 1, 19}, {9999, 12, 31}, {2024, 2, 29}}
+	invalidDates = [][3]int{{2023, 2, 29}, {2024, 2, 30}, {2024, 13, 1}, {2024, 4, 31}, {0, 0, 0}}
+)
+
+func dateValue(rng *rand.Rand, in FieldInput) (string, bool) {
+	var layouts []string
+	for _, layout := range dateLayouts {
+		numericLayout := !strings.ContainsAny(layout, "-/.")
+		if len(layout) <= in.Length && (numericLayout || !in.Numeric) {
+			layouts = append(layouts, layout)
+		}
+	}
+	if len(layouts) == 0 {
+		return "", false
+	}
+	var date [3]int
+	switch n := rng.Intn(10); {
+	case n < 6:
+		date = [3]int{1900 + rng.Intn(200), 1 + rng.Intn(12), 1 + rng.Intn(28)}
+	case n < 9:
+		date = edgeDates[rng.Intn(len(edgeDates))]
+	default:
+		date = invalidDates[rng.Intn(len(invalidDates))]
+	}
+	return formatDate(layouts[rng.Intn(len(layouts))], date), true
+}
+
+// formatDate writes year, month and day into layout without checking they
+// form a real date, so invalid dates can be generated too.
+func formatDate(layout string, date [3]int) string {
+	year, month, day := date[0], date[1], date[2]
+	yday := day
+	for m := 1; m < month && m <= 12; m++ {
+		yday += [12]int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}[m-1]
+	}
+	if month > 2 && !(year%4 == 0 && (year%100 != 0 || year%400 == 0)) {
+		yday--
+	}
+	return strings.NewReplacer(
+		"YYYY", fmt.Sprintf("%04d", year),
+		"YY", fmt.Sprintf("%02d", year%100),
+		"MM", fmt.Sprintf("%02d", month),
+		"DDD", fmt.Sprintf("%03d", yday),
+		"DD", fmt.Sprintf("%02d", day),
+	).Replace(layout)
+}
+
+// specialChars differ between EBCDIC code pages or are special to CICS,
+// COBOL or SQL: national characters, brackets, quotes and lowercase.
+var specialChars = []rune("¢¬|!¦[]^~\\{}`@#$£€'\"&<>%_;*?éñßäöü")
+
+func specialValue(rng *rand.Rand, in FieldInput) (string, bool) {
+	if in.Numeric {
+		return "", false
+	}
+	length := 1 + rng.Intn(in.Length)
+	b := make([]rune, length)
+	for i := range b {
+		switch rng.Intn(4) {
+		case 0:
+			b[i] = rune('a' + rng.Intn(26))
+		case 1:
+			b[i] = rune('A' + rng.Intn(26))
+		default:
+			b[i] = specialChars[rng.Intn(len(specialChars))]
+		}
+	}
+	return string(b), true
+}
+
+// labelTypes map label words to field types, checked in order so that
+// "Account No" is an account rather than a number.
+var labelTypes = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"date", regexp.MustCompile(`\b(DATE|DOB|DT|BIRTH|EXPIRY|EXPIRES|EFFECTIVE)\b`)},
+	{"account", regexp.MustCompile(`\b(ACCOUNT|ACCT|ACC|A/C|IBAN|POLICY|CARD)\b`)},
+	{"amount", regexp.MustCompile(`\b(AMOUNT|AMT|BALANCE|BAL|PRICE|TOTAL|LIMIT|PAYMENT|VALUE|COST|FEE|SALARY|RATE)\b`)},
+	{"time", regexp.MustCompile(`\b(TIME|HH:?MM)\b`)},
+	{"name", regexp.MustCompile(`\b(NAME|SURNAME|FORENAME|FIRST|LAST|CUSTOMER)\b`)},
+	{"number", regexp.MustCompile(`\b(QTY|QUANTITY|COUNT|NUMBER|NUM|NO|NBR|AGE|DAYS|YEARS|ID)\b`)},
+}
+
+// labelFieldType infers a field type from its label, or "" if none fits.
+func labelFieldType(label string) string {
+	upper := strings.ToUpper(label)
+	for _, t := range labelTypes {
+		if t.re.MatchString(upper) {
+			return t.kind
+		}
+	}
+	return ""
+}
+
+var (
+	edgeAmounts = []string{"0", "0.00", "-1.00", "0.01", "-0.01", "1,234.56", "1.234,56", "9999999.99", "99999999999.99", "0.001", "1E6"}
+	edgeTimes   = []string{"00:00", "23:59", "24:00", "12:60", "0000", "2359", "2400"}
+	edgeNames   = []string{"SMITH", "O'BRIEN", "VAN DER BERG", "LI", "SMITH-JONES", "MÜLLER", "X", "JOHN SMITH"}
+)
+
+func labelValue(rng *rand.Rand, in FieldInput) (string, bool) {
+	switch labelFieldType(in.Label) {
+	case "date":
+		return dateValue(rng, in)
+	case "account":
+		switch rng.Intn(4) {
+		case 0:
+			return strings.Repeat("0", in.Length), true
+		case 1:
+			if in.Length > 1 {
+				return randomValue(rng, in.Length-1, true), true
+			}
+		}
+		return randomValue(rng, in.Length, true), true
+	case "amount":
+		if rng.Intn(3) == 0 {
+			return numberValue(rng, in)
+		}
+		return edgeAmounts[rng.Intn(len(edgeAmounts))], true
+	case "time":
+		return edgeTimes[rng.Intn(len(edgeTimes))], true
+	case "name":
+		if in.Numeric {
+			return "", false
+		}
+		return edgeNames[rng.Intn(len(edgeNames))], true
+	case "number":
+		return numberValue(rng, in)
+	}
+	return "", false
+}
@@ -0,0 +1,192 @@
+package chaos
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+// constantGenerator is a custom ValueGenerator for tests.
+type constantGenerator string
+
+func (g constantGenerator) Name() string { return "constant" }
+
+func (g constantGenerator) Generate(*rand.Rand, FieldInput) (string, bool) {
+	return string(g), true
+}
+
+func TestBuiltinValueGeneratorsFitFields(t *testing.T) {
+	rng := rand.New(rand.NewSource(9)) //nolint:gosec
+	for _, gen := range builtinValueGenerators {
+		for _, in := range []FieldInput{
+			{Length: 8, Label: "Amount"},
+			{Length: 8, Numeric: true, Label: "Account no"},
+			{Length: 3, Label: "Date"},
+			{Length: 1},
+		} {
+			for i := 0; i < 200; i++ {
+				value, ok := gen.Generate(rng, in)
+				if !ok {
+					continue
+				}
+				fitted := fitGeneratedValue(value, in)
+				if n := len([]rune(fitted)); n > in.Length {
+					t.Fatalf("%s: %q is %d characters for a %d character field", gen.Name(), fitted, n, in.Length)
+				}
+				if in.Numeric && strings.Trim(fitted, "0123456789-.") != "" {
+					t.Fatalf("%s: %q in a numeric field", gen.Name(), fitted)
+				}
+			}
+		}
+	}
+
+	in := FieldInput{Length: 10}
+	lengths := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		value, _ := boundaryValue(rng, in)
+		lengths[len(value)] = true
+	}
+	if len(lengths) != 3 || !lengths[1] || !lengths[9] || !lengths[10] {
+		t.Fatalf("boundary lengths = %v, want 1, 9 and 10", lengths)
+	}
+	if _, ok := specialValue(rng, FieldInput{Length: 5, Numeric: true}); ok {
+		t.Fatal("special characters offered for a numeric field")
+	}
+	if _, ok := dateValue(rng, FieldInput{Length: 4}); ok {
+		t.Fatal("date offered for a field too short for any format")
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	for _, tc := range []struct {
+		layout string
+		date   [3]int
+		want   string
+	}{
+		{"YYYY-MM-DD", [3]int{2024, 2, 29}, "2024-02-29"},
+		{"DD/MM/YY", [3]int{2023, 2, 30}, "30/02/23"},
+		{"YYDDD", [3]int{2024, 3, 1}, "24061"},
+		{"YYYYDDD", [3]int{2023, 12, 31}, "2023365"},
+		{"MM/DD/YYYY", [3]int{0, 0, 0}, "00/00/0000"},
+	} {
+		if got := formatDate(tc.layout, tc.date); got != tc.want {
+			t.Errorf("formatDate(%q, %v) = %q, want %q", tc.layout, tc.date, got, tc.want)
+		}
+	}
+}
+
+func TestFieldLabelAndType(t *testing.T) {
+	s := buildMockScreen()
+	copy(s.Buffer[2], []rune("Account No...:"))
+	copy(s.Buffer[9], []rune("  Amount"))
+	account := host.NewField(s, 0x00, 15, 2, 24, 2, 0, 0)
+	amount := host.NewField(s, 0x00, 2, 10, 9, 10, 0, 0)
+	s.Fields = append(s.Fields, account, amount)
+
+	if got := fieldLabel(account); got != "Account No" {
+		t.Errorf("fieldLabel(account) = %q, want Account No", got)
+	}
+	if got := fieldLabel(amount); got != "Amount" {
+		t.Errorf("fieldLabel(amount) = %q, want the column heading", got)
+	}
+	for label, want := range map[string]string{
+		"Account No":    "account",
+		"Date of birth": "date",
+		"Amount":        "amount",
+		"Qty":           "number",
+		"Last name":     "name",
+		"Password":      "",
+	} {
+		if got := labelFieldType(label); got != want {
+			t.Errorf("labelFieldType(%q) = %q, want %q", label, got, want)
+		}
+	}
+	if _, ok := labelValue(rand.New(rand.NewSource(1)), FieldInput{Length: 8, Label: "Password"}); ok { //nolint:gosec
+		t.Error("label generator offered a value for an unknown label")
+	}
+}
+
+func TestEngineValueWeights(t *testing.T) {
+	s := &host.Screen{Width: 80, Height: 24}
+	f := host.NewField(s, 0x00, 0, 0, 5, 0, 0, 0)
+
+	cfg := DefaultConfig()
+	cfg.ValueWeights = map[string]int{ValueEmptyMax: 1, ValueRandom: 0}
+	e := New(nil, cfg)
+	for i := 0; i < 50; i++ {
+		if v := e.generateValueForField(f, false); v != "" && v != strings.Repeat(v[:1], 6) {
+			t.Fatalf("empty-max generator produced %q", v)
+		}
+	}
+
+	cfg.ValueWeights = map[string]int{"constant": 1}
+	cfg.ValueGenerators = []ValueGenerator{constantGenerator("FIXED-VALUE")}
+	e = New(nil, cfg)
+	if v := e.generateValueForField(f, false); v != "FIXED-" {
+		t.Fatalf("custom generator value = %q, want it cut to the field", v)
+	}
+
+	cfg.ValueWeights = map[string]int{ValueSpecial: 1}
+	e = New(nil, cfg)
+	numeric := host.NewField(s, host.AttrNumeric, 0, 1, 5, 1, 0, 0)
+	if v := e.generateValueForField(numeric, false); strings.Trim(v, "0123456789") != "" {
+		t.Fatalf("declined generator should fall back to digits, got %q", v)
+	}
+}
+
+func TestEngineEmptyValueClearsField(t *testing.T) {
+	h, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Screen = buildMockScreen()
+	h.Connected = true
+	copy(h.Screen.Buffer[2][10:], []rune("OLDVALUE12"))
+
+	cfg := DefaultConfig()
+	cfg.MaxSteps = 1
+	cfg.StepDelay = 0
+	cfg.ExcludeNoProgressEvents = false
+	cfg.ValueWeights = map[string]int{"constant": 1}
+	cfg.ValueGenerators = []ValueGenerator{constantGenerator("")}
+	e := New(h, cfg)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Status().Active && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	blank := strings.Repeat(" ", 10)
+	if got := string(h.Screen.Buffer[2][10:20]); got != blank {
+		t.Fatalf("field after an empty value = %q, want it cleared", got)
+	}
+	var fills []string
+	for _, step := range e.Snapshot("empty").Steps {
+		if step.Type == "FillString" {
+			fills = append(fills, step.Text)
+		}
+	}
+	if len(fills) != 1 || fills[0] != blank {
+		t.Fatalf("FillString steps = %q, want one that blanks the field", fills)
+	}
+}
+
+func TestParseValueWeights(t *testing.T) {
+	weights, err := ParseValueWeights(" random=50, Boundary=20 ,")
+	if err != nil || len(weights) != 2 || weights[ValueRandom] != 50 || weights[ValueBoundary] != 20 {
+		t.Fatalf("ParseValueWeights = %v, %v", weights, err)
+	}
+	for _, spec := range []string{"random", "random=x", "fuzzy=1", "date=-1"} {
+		if _, err := ParseValueWeights(spec); err == nil {
+			t.Errorf("ParseValueWeights(%q) = nil error", spec)
+		}
+	}
+	if err := ValidateValueWeights(DefaultValueWeights()); err != nil {
+		t.Fatalf("default weights: %v", err)
+	}
+}
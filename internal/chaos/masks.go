@@ -0,0 +1,384 @@
+package chaos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// Mask rule kinds for MaskRule.Kind.
+const (
+	// MaskRect blanks a rectangle of Height rows and Width columns from
+	// Row, Column (1-based).
+	MaskRect = "rect"
+
+	// MaskRegex blanks every match of Pattern on each screen line.
+	MaskRegex = "regex"
+)
+
+// maxAutoMasks caps the rectangles a run adds by itself.
+const maxAutoMasks = 20
+
+// maxMaskVisits caps the remembered screens used to detect volatile
+// regions.
+const maxMaskVisits = 500
+
+// Limits on what auto-detection treats as a volatile region rather than
+// a different screen: a few short runs of changed characters.
+const (
+	maxVolatileRuns  = 4
+	maxVolatileWidth = 24
+)
+
+// MaskRule blanks a volatile part of the screen, such as a clock, date,
+// terminal ID or record counter, before the screen is hashed, so visits to
+// the same screen count as one.
+type MaskRule struct {
+	Kind    string `json:"kind"`
+	Row     int    `json:"row,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	// Auto marks rectangles the run detected itself.
+	Auto bool `json:"auto,omitempty"`
+}
+
+// screenMask is a MaskRule ready to apply.
+type screenMask struct {
+	rule MaskRule
+	re   *regexp.Regexp
+}
+
+// ValidateMaskRules reports the first rule with an unknown kind, a
+// rectangle off the screen's top left, or a pattern that does not compile.
+func ValidateMaskRules(rules []MaskRule) error {
+	_, err := compileMaskRules(rules)
+	return err
+}
+
+func compileMaskRules(rules []MaskRule) ([]screenMask, error) {
+	masks := make([]screenMask, 0, len(rules))
+	for i, rule := range rules {
+		m := screenMask{rule: rule}
+		switch rule.Kind {
+		case MaskRect:
+			if rule.Row < 1 || rule.Column < 1 || rule.Width < 1 || rule.Height < 0 {
+				return nil, fmt.Errorf("mask rule %d: row, column and width must be positive", i+1)
+			}
+		case MaskRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil || rule.Pattern == "" {
+				return nil, fmt.Errorf("mask rule %d: invalid pattern %q", i+1, rule.Pattern)
+			}
+			m.re = re
+		default:
+			return nil, fmt.Errorf("mask rule %d: unknown kind %q", i+1, rule.Kind)
+		}
+		masks = append(masks, m)
+	}
+	return masks, nil
+}
+
+// ParseMaskRules parses rules separated by semicolons. A rectangle is
+// written R<row>C<column>W<width>, optionally followed by H<height>, such
+// as R1C72W8; a regular expression is written between slashes, such as
+// /\d\d:\d\d:\d\d/, with \/ for a literal slash.
+func ParseMaskRules(spec string) ([]MaskRule, error) {
+	var rules []MaskRule
+	for rest := strings.TrimSpace(spec); rest != ""; rest = strings.TrimLeft(rest, "; \t") {
+		if rest[0] == '/' {
+			end := 1
+			for end < len(rest) && (rest[end] != '/' || rest[end-1] == '\\') {
+				end++
+			}
+			if end == len(rest) {
+				return nil, fmt.Errorf("mask rule %q: missing closing /", rest)
+			}
+			pattern := strings.ReplaceAll(rest[1:end], `\/`, "/")
+			rules = append(rules, MaskRule{Kind: MaskRegex, Pattern: pattern})
+			rest = rest[end+1:]
+			continue
+		}
+		part, after, _ := strings.Cut(rest, ";")
+		rule, err := parseMaskRect(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+		rest = after
+	}
+	if err := ValidateMaskRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+var maskRectPattern = regexp.MustCompile(`^(?i)R(\d+)C(\d+)W(\d+)(?:H(\d+))?$`)
+
+func parseMaskRect(text string) (MaskRule, error) {
+	m := maskRectPattern.FindStringSubmatch(strings.ReplaceAll(text, " ", ""))
+	if m == nil {
+		return MaskRule{}, fmt.Errorf("invalid mask rule %q (want R<row>C<column>W<width>[H<height>] or /regexp/)", text)
+	}
+	rule := MaskRule{Kind: MaskRect}
+	rule.Row, _ = strconv.Atoi(m[1])
+	rule.Column, _ = strconv.Atoi(m[2])
+	rule.Width, _ = strconv.Atoi(m[3])
+	if m[4] != "" {
+		rule.Height, _ = strconv.Atoi(m[4])
+	}
+	return rule, nil
+}
+
+// FormatMaskRules writes rules in the form ParseMaskRules reads.
+func FormatMaskRules(rules []MaskRule) string {
+	parts := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		switch rule.Kind {
+		case MaskRect:
+			part := fmt.Sprintf("R%dC%dW%d", rule.Row, rule.Column, rule.Width)
+			if rule.Height > 1 {
+				part += fmt.Sprintf("H%d", rule.Height)
+			}
+			parts = append(parts, part)
+		case MaskRegex:
+			parts = append(parts, "/"+strings.ReplaceAll(rule.Pattern, "/", `\/`)+"/")
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// mergeMaskRules appends the rules of extra not already in rules.
+func mergeMaskRules(rules, extra []MaskRule) []MaskRule {
+	out := append([]MaskRule(nil), rules...)
+	for _, rule := range extra {
+		found := false
+		for _, have := range out {
+			if sameMaskRegion(have, rule) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, rule)
+		}
+	}
+	return out
+}
+
+func sameMaskRegion(a, b MaskRule) bool {
+	return a.Kind == b.Kind && a.Row == b.Row && a.Column == b.Column &&
+		a.Width == b.Width && max(a.Height, 1) == max(b.Height, 1) && a.Pattern == b.Pattern
+}
+
+// maskedText returns the screen text, as Screen.Text lays it out, with
+// masked characters replaced by '*'.
+func maskedText(s *host.Screen, masks []screenMask) string {
+	text := s.Text()
+	if len(masks) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	rows := make([][]rune, len(lines))
+	for y, line := range lines {
+		rows[y] = []rune(line)
+	}
+	for _, m := range masks {
+		switch m.rule.Kind {
+		case MaskRect:
+			for y := m.rule.Row - 1; y < m.rule.Row-1+max(m.rule.Height, 1) && y < len(rows); y++ {
+				for x := m.rule.Column - 1; x < m.rule.Column-1+m.rule.Width && x < len(rows[y]); x++ {
+					rows[y][x] = '*'
+				}
+			}
+		case MaskRegex:
+			for y, row := range rows {
+				line := string(row)
+				for _, loc := range m.re.FindAllStringIndex(line, -1) {
+					start := len([]rune(line[:loc[0]]))
+					end := start + len([]rune(line[loc[0]:loc[1]]))
+					for x := start; x < end; x++ {
+						rows[y][x] = '*'
+					}
+				}
+			}
+		}
+	}
+	for y, row := range rows {
+		lines[y] = string(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hashMaskedScreen is hashScreen over the masked screen text. Without
+// masks it equals hashScreen, so runs saved before masking keep their
+// hashes.
+func hashMaskedScreen(s *host.Screen, masks []screenMask) string {
+	if s == nil {
+		return ""
+	}
+	return hashScreenText(s, maskedText(s, masks))
+}
+
+// screenLayout fingerprints the field structure alone, so revisits of a
+// screen can be told apart from other screens when its text has changed.
+func screenLayout(s *host.Screen) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%dx%d|%d", s.Width, s.Height, len(s.Fields))
+	for _, f := range s.Fields {
+		if f != nil {
+			fmt.Fprintf(h, "|%d,%d,%d,%d,%d", f.StartY, f.StartX, f.EndY, f.EndX, f.FieldCode)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// maskVisit is the screen one input led to, for volatile region detection.
+type maskVisit struct {
+	layout string
+	text   string
+	hash   string
+}
+
+// maskInput keys a visit by the layout of the screen it started from and
+// the input sent there: the field values and the AID key. The layout stands
+// in for the screen because its text may hold the very regions still to be
+// detected.
+func maskInput(fromLayout string, batch []session.WorkflowStep) string {
+	var b strings.Builder
+	b.WriteString(fromLayout)
+	for _, step := range batch {
+		b.WriteString("|" + step.Type)
+		if step.Coordinates != nil {
+			fmt.Fprintf(&b, "@%d,%d", step.Coordinates.Row, step.Coordinates.Column)
+		}
+		b.WriteString("=" + step.Text)
+	}
+	return b.String()
+}
+
+// volatileRegions compares two visits of the same screen and returns
+// rectangles over the protected characters that changed, or nil when the
+// screens differ too much to be the same screen.
+func volatileRegions(s *host.Screen, before, after string) []MaskRule {
+	a, b := strings.Split(before, "\n"), strings.Split(after, "\n")
+	if len(a) != len(b) {
+		return nil
+	}
+	var regions []MaskRule
+	for y := range a {
+		ra, rb := []rune(a[y]), []rune(b[y])
+		if len(ra) != len(rb) {
+			return nil
+		}
+		for x := 0; x < len(ra); x++ {
+			if ra[x] == rb[x] {
+				continue
+			}
+			start := x
+			// Changes one unchanged character apart, as in 12:04:59
+			// becoming 12:05:00, make one region.
+			for x+1 < len(ra) && (ra[x+1] != rb[x+1] || x+2 < len(ra) && ra[x+2] != rb[x+2]) {
+				x++
+			}
+			// Widen to the whole word, so a clock's seconds changing
+			// masks the clock rather than its last digit.
+			for start > 0 && (ra[start-1] != ' ' || rb[start-1] != ' ') {
+				start--
+			}
+			for x+1 < len(ra) && (ra[x+1] != ' ' || rb[x+1] != ' ') {
+				x++
+			}
+			if x-start+1 > maxVolatileWidth || len(regions) == maxVolatileRuns {
+				return nil
+			}
+			for cx := start; cx <= x; cx++ {
+				if isInputCell(s, y, cx) {
+					return nil
+				}
+			}
+			regions = append(regions, MaskRule{Kind: MaskRect, Row: y + 1, Column: start + 1, Width: x - start + 1, Auto: true})
+		}
+	}
+	return regions
+}
+
+// isInputCell reports whether row y, column x lies in an input field.
+func isInputCell(s *host.Screen, y, x int) bool {
+	for _, f := range s.Fields {
+		if f == nil || f.IsProtected() || y < f.StartY || y > f.EndY {
+			continue
+		}
+		if (y > f.StartY || x >= f.StartX) && (y < f.EndY || x <= f.EndX) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectVolatileLocked remembers the screen, hashed as hash, that input
+// led to. When the same input earlier led to the same layout with only a
+// few short runs of protected characters changed, it adds auto rectangles
+// over them and returns the hash the earlier visit was counted under.
+// Must be called with e.mu held.
+func (e *Engine) detectVolatileLocked(input string, s *host.Screen, hash string) (string, bool) {
+	if !e.cfg.AutoMask || s == nil {
+		return "", false
+	}
+	visit := maskVisit{layout: screenLayout(s), text: maskedText(s, e.masks), hash: hash}
+	prev, seen := e.maskVisits[input]
+	if !seen {
+		if len(e.maskVisits) < maxMaskVisits {
+			e.maskVisits[input] = visit
+		}
+		return "", false
+	}
+	if prev.layout != visit.layout || prev.text == visit.text {
+		return "", false
+	}
+	regions := volatileRegions(s, prev.text, visit.text)
+	if len(regions) == 0 || e.autoMaskCountLocked()+len(regions) > maxAutoMasks {
+		return "", false
+	}
+	rules := mergeMaskRules(e.maskRules, regions)
+	masks, err := compileMaskRules(rules)
+	if err != nil {
+		return "", false
+	}
+	e.maskRules = rules
+	e.masks = masks
+	// The remembered texts were masked with the old rules.
+	e.maskVisits = make(map[string]maskVisit)
+	return prev.hash, true
+}
+
+func (e *Engine) autoMaskCountLocked() int {
+	n := 0
+	for _, rule := range e.maskRules {
+		if rule.Auto {
+			n++
+		}
+	}
+	return n
+}
+
+// setMaskRulesLocked replaces the mask rules, dropping any that do not
+// compile; callers taking rules from users check them first with
+// ValidateMaskRules. Must be called with e.mu held.
+func (e *Engine) setMaskRulesLocked(rules []MaskRule) {
+	e.maskRules, e.masks = nil, nil
+	for _, rule := range rules {
+		if compiled, err := compileMaskRules([]MaskRule{rule}); err == nil {
+			e.maskRules = append(e.maskRules, rule)
+			e.masks = append(e.masks, compiled...)
+		}
+	}
+	e.maskVisits = make(map[string]maskVisit)
+}
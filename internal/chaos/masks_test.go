@@ -0,0 +1,155 @@
+package chaos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+func TestParseMaskRules(t *testing.T) {
+	rules, err := ParseMaskRules(` r1c72w8 ; R3C2W10H2;/\d\d:\d\d/; /a\/b;c/ `)
+	if err != nil {
+		t.Fatalf("ParseMaskRules() error: %v", err)
+	}
+	want := []MaskRule{
+		{Kind: MaskRect, Row: 1, Column: 72, Width: 8},
+		{Kind: MaskRect, Row: 3, Column: 2, Width: 10, Height: 2},
+		{Kind: MaskRegex, Pattern: `\d\d:\d\d`},
+		{Kind: MaskRegex, Pattern: `a/b;c`},
+	}
+	if fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Fatalf("rules = %+v, want %+v", rules, want)
+	}
+	spec := FormatMaskRules(rules)
+	if again, err := ParseMaskRules(spec); err != nil || fmt.Sprint(again) != fmt.Sprint(want) {
+		t.Fatalf("round trip of %q = %+v, %v", spec, again, err)
+	}
+	if rules, err := ParseMaskRules("  "); err != nil || len(rules) != 0 {
+		t.Fatalf("empty spec = %v, %v", rules, err)
+	}
+	for _, spec := range []string{"R0C1W1", "R1C1", "row 1", "/(/", "/unclosed", "//"} {
+		if _, err := ParseMaskRules(spec); err == nil {
+			t.Errorf("ParseMaskRules(%q) = nil error", spec)
+		}
+	}
+	if err := ValidateMaskRules([]MaskRule{{Kind: "circle"}}); err == nil {
+		t.Error("ValidateMaskRules accepted an unknown kind")
+	}
+}
+
+func TestHashMaskedScreen(t *testing.T) {
+	a, b := buildMockScreen(), buildMockScreen()
+	copy(a.Buffer[0][72:], []rune("12:04:59"))
+	copy(b.Buffer[0][72:], []rune("12:05:00"))
+	copy(a.Buffer[5], []rune("TERM=T001"))
+	copy(b.Buffer[5], []rune("TERM=T042"))
+
+	if hashMaskedScreen(a, nil) != hashScreen(a) {
+		t.Fatal("hash without masks differs from hashScreen")
+	}
+	rules, _ := ParseMaskRules(`R1C73W8; /TERM=\w+/`)
+	masks, err := compileMaskRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashMaskedScreen(a, masks) != hashMaskedScreen(b, masks) {
+		t.Fatal("masked screens hash differently")
+	}
+	copy(b.Buffer[10], []rune("NEXT PAGE"))
+	if hashMaskedScreen(a, masks) == hashMaskedScreen(b, masks) {
+		t.Fatal("masks hid a change outside them")
+	}
+}
+
+func TestVolatileRegions(t *testing.T) {
+	s := buildMockScreen()
+	before := maskedText(s, nil)
+	copy(s.Buffer[0][70:], []rune("12:05"))
+	copy(s.Buffer[23][0:], []rune("REC 7"))
+	regions := volatileRegions(s, before, maskedText(s, nil))
+	if len(regions) != 2 || regions[0].Row != 1 || regions[0].Column != 71 || regions[0].Width != 5 ||
+		regions[1].Row != 24 || regions[1].Width != 5 || !regions[0].Auto {
+		t.Fatalf("regions = %+v", regions)
+	}
+
+	// Text typed into the input field is not a volatile region.
+	s = buildMockScreen()
+	before = maskedText(s, nil)
+	copy(s.Buffer[2][12:], []rune("ABC"))
+	if regions := volatileRegions(s, before, maskedText(s, nil)); regions != nil {
+		t.Fatalf("input field change gave regions %+v", regions)
+	}
+
+	// A screen with most of its text changed is another screen.
+	s = buildMockScreen()
+	before = maskedText(s, nil)
+	for y := 5; y < 15; y++ {
+		copy(s.Buffer[y], []rune("DIFFERENT"))
+	}
+	if regions := volatileRegions(s, before, maskedText(s, nil)); regions != nil {
+		t.Fatalf("different screen gave regions %+v", regions)
+	}
+}
+
+// clockHost shows a screen with no input fields and a clock that ticks on
+// every key press.
+type clockHost struct {
+	*host.MockHost
+	ticks int
+}
+
+func (h *clockHost) SendKey(key string) error {
+	h.ticks++
+	copy(h.Screen.Buffer[0][72:], []rune(fmt.Sprintf("12:%02d:%02d", h.ticks/60, h.ticks%60)))
+	return h.MockHost.SendKey(key)
+}
+
+func runClockEngine(t *testing.T, autoMask bool) Status {
+	t.Helper()
+	mock, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.Connected = true
+	copy(mock.Screen.Buffer[0], []rune("MAIN MENU"))
+	mock.Screen.Fields = append(mock.Screen.Fields, host.NewField(mock.Screen, host.AttrProtected, 0, 0, 79, 23, 0, 0))
+
+	cfg := DefaultConfig()
+	cfg.MaxSteps = 10
+	cfg.StepDelay = 0
+	cfg.Seed = 1
+	cfg.AIDKeyWeights = map[string]int{"Enter": 1}
+	cfg.AutoMask = autoMask
+	e := New(&clockHost{MockHost: mock}, cfg)
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Status().Active && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	e.Stop()
+
+	if saved := e.Snapshot("run"); fmt.Sprint(saved.MaskRules) != fmt.Sprint(e.Status().MaskRules) {
+		t.Fatalf("saved mask rules %+v, status %+v", saved.MaskRules, e.Status().MaskRules)
+	}
+	return e.Status()
+}
+
+func TestEngineAutoMask(t *testing.T) {
+	st := runClockEngine(t, false)
+	if st.UniqueScreens != 11 || len(st.MaskRules) != 0 {
+		t.Fatalf("without auto-mask: %d screens, rules %+v", st.UniqueScreens, st.MaskRules)
+	}
+
+	st = runClockEngine(t, true)
+	if st.UniqueScreens > 3 {
+		t.Fatalf("with auto-mask: %d screens, want the clock masked", st.UniqueScreens)
+	}
+	want := MaskRule{Kind: MaskRect, Row: 1, Column: 73, Width: 8, Auto: true}
+	if len(st.MaskRules) != 1 || st.MaskRules[0] != want {
+		t.Fatalf("mask rules = %+v, want %+v", st.MaskRules, want)
+	}
+}
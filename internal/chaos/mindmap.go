@@ -2,6 +2,7 @@ package chaos
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -10,6 +11,10 @@ import (
 
 const maxKnownValuesPerField = 12
 
+// frontierFieldFills is how many times each input field of an area must be
+// filled before the frontier strategy stops counting the area as a frontier.
+const frontierFieldFills = 3
+
 // MindMap captures a lightweight graph of discovered application areas.
 // Areas are keyed by screen hash (or a synthetic ID when seeded from a recording).
 type MindMap struct {
@@ -30,6 +35,9 @@ type MindMapArea struct {
 	FieldMetadata      map[string]MindMapFieldMetadata `json:"fieldMetadata,omitempty"`
 	KnownWorkingValues map[string][]string             `json:"knownWorkingValues,omitempty"`
 	KeyPresses         map[string]*MindMapKeyPress     `json:"keyPresses,omitempty"`
+	// FieldFills counts successful writes per input field, keyed by row and
+	// column.
+	FieldFills map[string]int `json:"fieldFills,omitempty"`
 }
 
 // MindMapFieldMetadata describes one input field in an area.
@@ -76,6 +84,12 @@ func (m *MindMap) clone() *MindMap {
 				next.KnownWorkingValues[fKey] = append([]string(nil), values...)
 			}
 		}
+		if len(area.FieldFills) > 0 {
+			next.FieldFills = make(map[string]int, len(area.FieldFills))
+			for fKey, count := range area.FieldFills {
+				next.FieldFills[fKey] = count
+			}
+		}
 		if len(area.KeyPresses) > 0 {
 			next.KeyPresses = make(map[string]*MindMapKeyPress, len(area.KeyPresses))
 			for aid, keyPress := range area.KeyPresses {
@@ -174,6 +188,15 @@ func (m *MindMap) recordAttempt(attempt Attempt) {
 	}
 	keyPress.Presses++
 	keyPress.LastUsedAt = attempt.Time
+	for _, fw := range attempt.FieldWrites {
+		if !fw.Success {
+			continue
+		}
+		if area.FieldFills == nil {
+			area.FieldFills = make(map[string]int)
+		}
+		area.FieldFills[mindMapFieldPosKey(fw.Row, fw.Column)]++
+	}
 
 	toHash := strings.TrimSpace(attempt.ToHash)
 	if attempt.Transitioned && toHash != "" {
@@ -191,7 +214,7 @@ func (m *MindMap) recordAttempt(attempt Attempt) {
 		area.KnownWorkingValues = make(map[string][]string)
 	}
 	for _, fw := range attempt.FieldWrites {
-		if !fw.Success {
+		if !fw.Success || fw.Secret {
 			continue
 		}
 		value := strings.TrimSpace(fw.Value)
@@ -276,6 +299,118 @@ func mindMapFieldKey(row, column, length int) string {
 	return fmt.Sprintf("R%dC%dL%d", row, column, length)
 }
 
+func mindMapFieldPosKey(row, column int) string {
+	return fmt.Sprintf("R%dC%d", row, column)
+}
+
+// untriedKeys returns the keys, in order, never pressed from the area.
+func (a *MindMapArea) untriedKeys(keys []string) []string {
+	var untried []string
+	for _, key := range keys {
+		if kp, ok := a.KeyPresses[key]; !ok || kp == nil || kp.Presses == 0 {
+			untried = append(untried, key)
+		}
+	}
+	return untried
+}
+
+// isFrontier reports whether the area is still worth exploring: some of
+// keys were never pressed from it, or an input field was filled fewer than
+// frontierFieldFills times.
+func (a *MindMapArea) isFrontier(keys []string) bool {
+	if a == nil {
+		return true
+	}
+	if len(a.untriedKeys(keys)) > 0 {
+		return true
+	}
+	for _, meta := range a.FieldMetadata {
+		if a.FieldFills[mindMapFieldPosKey(meta.Row, meta.Column)] < frontierFieldFills {
+			return true
+		}
+	}
+	return false
+}
+
+// frontierCount returns how many areas are frontiers for keys.
+func (m *MindMap) frontierCount(keys []string) int {
+	if m == nil {
+		return 0
+	}
+	count := 0
+	for _, area := range m.Areas {
+		if area != nil && area.isFrontier(keys) {
+			count++
+		}
+	}
+	return count
+}
+
+// mindMapHop is one known transition: pressing Key on From led to To.
+type mindMapHop struct {
+	From string
+	Key  string
+	To   string
+}
+
+func (h mindMapHop) id() string {
+	return h.From + "|" + h.Key + "|" + h.To
+}
+
+// frontierRoute searches the transitions seen so far, breadth first, for
+// the nearest frontier area other than from. It returns the first hop of
+// the shortest path there and the frontier's hash. Hops for which skip
+// returns true are not used.
+func (m *MindMap) frontierRoute(from string, keys []string, skip func(mindMapHop) bool) (mindMapHop, string, bool) {
+	if m == nil || m.Areas[from] == nil {
+		return mindMapHop{}, "", false
+	}
+	first := map[string]mindMapHop{}
+	queue := []string{from}
+	seen := map[string]bool{from: true}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		area := m.Areas[hash]
+		if area == nil {
+			continue
+		}
+		if hash != from && area.isFrontier(keys) {
+			return first[hash], hash, true
+		}
+		pressed := make([]string, 0, len(area.KeyPresses))
+		for key := range area.KeyPresses {
+			pressed = append(pressed, key)
+		}
+		sort.Strings(pressed)
+		for _, key := range pressed {
+			kp := area.KeyPresses[key]
+			if kp == nil {
+				continue
+			}
+			destinations := make([]string, 0, len(kp.Destinations))
+			for to := range kp.Destinations {
+				destinations = append(destinations, to)
+			}
+			sort.Strings(destinations)
+			for _, to := range destinations {
+				hop := mindMapHop{From: hash, Key: key, To: to}
+				if seen[to] || (skip != nil && skip(hop)) {
+					continue
+				}
+				seen[to] = true
+				if hash == from {
+					first[to] = hop
+				} else {
+					first[to] = first[hash]
+				}
+				queue = append(queue, to)
+			}
+		}
+	}
+	return mindMapHop{}, "", false
+}
+
 func appendUniqueLimited(values []string, candidate string, max int) []string {
 	for _, existing := range values {
 		if existing == candidate {
@@ -0,0 +1,268 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// ErrNotReproduced is returned by Minimize when replaying all of the steps
+// does not show the failure, so there is nothing to minimize.
+var ErrNotReproduced = errors.New("the steps do not reproduce the failure")
+
+// FailureCheck reports whether a screen reached during a replay shows the
+// failure being minimized. connected is false once the host has dropped the
+// connection.
+type FailureCheck func(s *host.Screen, connected bool) bool
+
+// ReplayFunc replays steps against a fresh session, from the screen a run
+// starts on, and reports whether the failure showed up. An error aborts
+// the minimization; a step that merely fails to apply should be reported
+// as not failing instead.
+type ReplayFunc func(steps []session.WorkflowStep) (bool, error)
+
+// MinimizeResult is the outcome of Minimize.
+type MinimizeResult struct {
+	// Steps is the smallest workflow found that still fails.
+	Steps []session.WorkflowStep
+	// OriginalSteps is the number of steps Minimize started from.
+	OriginalSteps int
+	// Replays counts the replays run, including the initial check.
+	Replays int
+}
+
+// FindingCheck returns a FailureCheck that fires when rules, the rules of
+// the run that found f, raise the same finding again. masks are the run's
+// mask rules, so screens hash as they did during the run.
+func FindingCheck(rules []FindingRule, masks []MaskRule, f Finding) (FailureCheck, error) {
+	matchers, err := compileFindingRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	compiledMasks, err := compileMaskRules(masks)
+	if err != nil {
+		return nil, err
+	}
+	var rule []findingMatcher
+	for _, m := range matchers {
+		if m.rule.Name == f.Rule {
+			rule = []findingMatcher{m}
+			break
+		}
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("finding rule %q is not configured", f.Rule)
+	}
+	return func(s *host.Screen, connected bool) bool {
+		if !connected {
+			return rule[0].rule.Kind == FindingDisconnect
+		}
+		hash := hashMaskedScreen(s, compiledMasks)
+		for _, hit := range screenFindings(rule, s) {
+			if sameFinding(f, hit, hash) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// PatternCheck returns a FailureCheck that fires when pattern matches the
+// screen text, with hidden fields blanked as for findings.
+func PatternCheck(pattern string) (FailureCheck, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil || pattern == "" {
+		return nil, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	return func(s *host.Screen, connected bool) bool {
+		return connected && s != nil && re.MatchString(findingScreenText(s))
+	}, nil
+}
+
+// Minimize shrinks steps to a smaller workflow that replay still reports
+// as failing, using delta debugging. It first removes whole attempts (the
+// fills and key of one submission) and then single steps, so the result is
+// 1-minimal: dropping any one remaining step loses the failure.
+//
+// Each replay is expected to start a fresh session, so the cost is roughly
+// one replay per candidate; maxReplays > 0 caps them, after which the best
+// workflow found so far is returned. When replay fails, that best workflow
+// is returned along with the error.
+func Minimize(steps []session.WorkflowStep, replay ReplayFunc, maxReplays int) (MinimizeResult, error) {
+	m := &minimizer{replay: replay, maxReplays: maxReplays, tested: make(map[string]bool)}
+	result := MinimizeResult{OriginalSteps: len(steps)}
+	all := make([]int, len(steps))
+	for i := range all {
+		all[i] = i
+	}
+	m.steps = steps
+	fails, err := m.test(all)
+	if err == nil && !fails {
+		err = ErrNotReproduced
+	}
+	if err != nil {
+		result.Replays = m.replays
+		return result, err
+	}
+
+	best := all
+	groups, err := m.ddmin(attemptGroups(steps, best))
+	best = flattenGroups(groups)
+	if err == nil {
+		var singles [][]int
+		singles, err = m.ddmin(singleGroups(best))
+		best = flattenGroups(singles)
+	}
+	if errors.Is(err, errReplayBudget) {
+		err = nil
+	}
+	result.Steps = make([]session.WorkflowStep, len(best))
+	for i, idx := range best {
+		result.Steps[i] = steps[idx]
+	}
+	result.Replays = m.replays
+	return result, err
+}
+
+// errReplayBudget stops ddmin once maxReplays replays have run.
+var errReplayBudget = errors.New("replay budget spent")
+
+type minimizer struct {
+	steps      []session.WorkflowStep
+	replay     ReplayFunc
+	maxReplays int
+	replays    int
+	// tested caches outcomes by step indices, since ddmin revisits subsets.
+	tested map[string]bool
+}
+
+// test replays the steps at indices, in order.
+func (m *minimizer) test(indices []int) (bool, error) {
+	key := fmt.Sprint(indices)
+	if fails, ok := m.tested[key]; ok {
+		return fails, nil
+	}
+	if m.maxReplays > 0 && m.replays >= m.maxReplays {
+		return false, errReplayBudget
+	}
+	candidate := make([]session.WorkflowStep, len(indices))
+	for i, idx := range indices {
+		candidate[i] = m.steps[idx]
+	}
+	m.replays++
+	fails, err := m.replay(candidate)
+	if err != nil {
+		return false, err
+	}
+	m.tested[key] = fails
+	return fails, nil
+}
+
+// ddmin is Zeller's delta debugging over groups of step indices. groups
+// fail together when replayed; the returned groups still fail. On error
+// the smallest failing groups found so far are returned.
+func (m *minimizer) ddmin(groups [][]int) ([][]int, error) {
+	n := 2
+	for len(groups) >= 2 {
+		chunks := splitGroups(groups, n)
+		reduced := false
+		for _, chunk := range chunks {
+			fails, err := m.test(flattenGroups(chunk))
+			if err != nil {
+				return groups, err
+			}
+			if fails {
+				groups, n, reduced = chunk, 2, true
+				break
+			}
+		}
+		if !reduced && n > 2 {
+			for i := range chunks {
+				complement := complementGroups(chunks, i)
+				fails, err := m.test(flattenGroups(complement))
+				if err != nil {
+					return groups, err
+				}
+				if fails {
+					groups, n, reduced = complement, max(n-1, 2), true
+					break
+				}
+			}
+		}
+		if !reduced {
+			if n >= len(groups) {
+				break
+			}
+			n = min(n*2, len(groups))
+		}
+	}
+	return groups, nil
+}
+
+// attemptGroups splits indices into attempts: the fills up to and
+// including the key that submitted them.
+func attemptGroups(steps []session.WorkflowStep, indices []int) [][]int {
+	var groups [][]int
+	var current []int
+	for _, idx := range indices {
+		current = append(current, idx)
+		if isFillStep(steps[idx]) {
+			continue
+		}
+		groups = append(groups, current)
+		current = nil
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+func isFillStep(step session.WorkflowStep) bool {
+	return strings.TrimSpace(step.Type) == "FillString"
+}
+
+func singleGroups(indices []int) [][]int {
+	groups := make([][]int, len(indices))
+	for i, idx := range indices {
+		groups[i] = []int{idx}
+	}
+	return groups
+}
+
+// splitGroups divides groups into n chunks of near equal size.
+func splitGroups(groups [][]int, n int) [][][]int {
+	chunks := make([][][]int, 0, n)
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + (len(groups)-start)/(n-i)
+		if end > start {
+			chunks = append(chunks, groups[start:end])
+		}
+		start = end
+	}
+	return chunks
+}
+
+// complementGroups joins every chunk except skip, in order.
+func complementGroups(chunks [][][]int, skip int) [][]int {
+	var out [][]int
+	for i, chunk := range chunks {
+		if i != skip {
+			out = append(out, chunk...)
+		}
+	}
+	return out
+}
+
+func flattenGroups(groups [][]int) []int {
+	var out []int
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return out
+}
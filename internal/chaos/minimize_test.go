@@ -0,0 +1,129 @@
+package chaos
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/host"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+func minimizeSteps(types ...string) []session.WorkflowStep {
+	steps := make([]session.WorkflowStep, len(types))
+	for i, t := range types {
+		if text, ok := strings.CutPrefix(t, "fill:"); ok {
+			steps[i] = session.WorkflowStep{Type: "FillString", Text: text}
+			continue
+		}
+		steps[i] = session.WorkflowStep{Type: t}
+	}
+	return steps
+}
+
+// failsAfterBadInput fails once BAD has been submitted with PF(5) and PF(3)
+// is pressed afterwards.
+func failsAfterBadInput(steps []session.WorkflowStep) (bool, error) {
+	pending, armed := "", false
+	for _, step := range steps {
+		switch step.Type {
+		case "FillString":
+			pending = step.Text
+		case "PressPF5":
+			armed = armed || pending == "BAD"
+			pending = ""
+		case "PressPF3":
+			if armed {
+				return true, nil
+			}
+			pending = ""
+		default:
+			pending = ""
+		}
+	}
+	return false, nil
+}
+
+func TestMinimize(t *testing.T) {
+	steps := minimizeSteps(
+		"fill:A", "PressEnter",
+		"fill:B", "fill:BAD", "PressPF5",
+		"fill:C", "PressEnter",
+		"PressClear",
+		"fill:D", "PressPF3",
+		"fill:E", "PressEnter",
+	)
+	result, err := Minimize(steps, failsAfterBadInput, 0)
+	if err != nil {
+		t.Fatalf("Minimize() error: %v", err)
+	}
+	var got []string
+	for _, step := range result.Steps {
+		got = append(got, step.Type+step.Text)
+	}
+	if strings.Join(got, " ") != "FillStringBAD PressPF5 PressPF3" {
+		t.Fatalf("minimized steps = %v", got)
+	}
+	if result.OriginalSteps != len(steps) || result.Replays == 0 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	if _, err := Minimize(minimizeSteps("fill:A", "PressEnter"), failsAfterBadInput, 0); !errors.Is(err, ErrNotReproduced) {
+		t.Fatalf("Minimize of a passing workflow = %v, want ErrNotReproduced", err)
+	}
+
+	capped, err := Minimize(steps, failsAfterBadInput, 8)
+	if err != nil || capped.Replays != 8 || len(capped.Steps) >= len(steps) {
+		t.Fatalf("capped Minimize = %d steps after %d replays, %v", len(capped.Steps), capped.Replays, err)
+	}
+	if fails, _ := failsAfterBadInput(capped.Steps); !fails {
+		t.Fatal("capped result no longer fails")
+	}
+
+	boom := errors.New("host unreachable")
+	calls := 0
+	partial, err := Minimize(steps, func(candidate []session.WorkflowStep) (bool, error) {
+		if calls++; calls > 2 {
+			return false, boom
+		}
+		return failsAfterBadInput(candidate)
+	}, 0)
+	if !errors.Is(err, boom) || len(partial.Steps) == 0 {
+		t.Fatalf("Minimize with a failing replay = %d steps, %v", len(partial.Steps), err)
+	}
+}
+
+func TestFailureChecks(t *testing.T) {
+	s := buildMockScreen()
+	copy(s.Buffer[5], []rune("DFHAC2206 TRANSACTION ABENDED ASRA"))
+
+	f := Finding{Rule: "CICS abend message", Kind: FindingText, Detail: "DFHAC2206 TRANSACTION ABENDED ASRA"}
+	check, err := FindingCheck(DefaultFindingRules(), nil, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check(s, true) {
+		t.Fatal("FindingCheck missed the same message")
+	}
+	other := buildMockScreen()
+	copy(other.Buffer[5], []rune("DFHAC2001 TRANSACTION NOT RECOGNIZED"))
+	if check(other, true) {
+		t.Fatal("FindingCheck matched a different message")
+	}
+	if _, err := FindingCheck(DefaultFindingRules(), nil, Finding{Rule: "gone"}); err == nil {
+		t.Fatal("FindingCheck accepted a rule that is not configured")
+	}
+
+	disconnect, err := FindingCheck(DefaultFindingRules(), nil, Finding{Rule: "Unexpected disconnect", Kind: FindingDisconnect})
+	if err != nil || !disconnect(nil, false) || disconnect(s, true) {
+		t.Fatalf("disconnect check misbehaves (%v)", err)
+	}
+
+	pattern, err := PatternCheck(`ABENDED \w+`)
+	if err != nil || !pattern(s, true) || pattern(other, true) || pattern(&host.Screen{}, false) {
+		t.Fatalf("pattern check misbehaves (%v)", err)
+	}
+	if _, err := PatternCheck("("); err == nil {
+		t.Fatal("PatternCheck accepted an invalid pattern")
+	}
+}
@@ -23,6 +23,7 @@ type SavedRunMeta struct {
 	Transitions   int       `json:"transitions"`
 	UniqueScreens int       `json:"uniqueScreens"`
 	UniqueInputs  int       `json:"uniqueInputs"`
+	Findings      int       `json:"findings,omitempty"`
 	Error         string    `json:"error,omitempty"`
 }
 
@@ -38,6 +39,9 @@ type SavedRun struct {
 	UniqueInputValues map[string]bool        `json:"uniqueInputValues,omitempty"`
 	Attempts          []Attempt              `json:"attempts,omitempty"`
 	MindMap           *MindMap               `json:"mindMap,omitempty"`
+	Findings          []Finding              `json:"findings,omitempty"`
+	FindingRules      []FindingRule          `json:"findingRules,omitempty"`
+	MaskRules         []MaskRule             `json:"maskRules,omitempty"`
 }
 
 // runFileName returns the file name for a given run ID.
@@ -0,0 +1,93 @@
+package chaos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jnnngs/3270Web/internal/secrets"
+	"github.com/jnnngs/3270Web/internal/session"
+)
+
+// secretPrefix starts the names of values generated for hidden fields. Each
+// run gets its own prefix, such as CHAOS_1A2B3C4D_, so a chaos step can never
+// name one of the user's own secrets.
+const secretPrefix = "CHAOS_"
+
+// newSecretScope returns the name prefix for the hidden values of one run.
+func newSecretScope() string {
+	return secretPrefix + strings.ToUpper(randomHex(4)) + "_"
+}
+
+// storeSecretLocked keeps a value generated for a hidden field and returns
+// the run-scoped name its placeholder uses.
+func (e *Engine) storeSecretLocked(value string) string {
+	if e.secrets == nil {
+		e.secrets = make(map[string]string)
+	}
+	name := fmt.Sprintf("%s%d", e.secretScope, len(e.secrets)+1)
+	e.secrets[name] = value
+	return name
+}
+
+// IsRunSecret reports whether name is one a chaos run gave a value it
+// generated for a hidden field.
+func IsRunSecret(name string) bool {
+	return strings.HasPrefix(name, secretPrefix)
+}
+
+// RunSecrets looks up the hidden values of run with lookup, keyed by their
+// run-scoped names. Saved runs keep only the names; names lookup does not
+// know are left out, so those fields get fresh values.
+func RunSecrets(run *SavedRun, lookup func(name string) (string, bool)) map[string]string {
+	if run == nil || lookup == nil {
+		return nil
+	}
+	values := make(map[string]string)
+	add := func(steps []session.WorkflowStep) {
+		for _, step := range steps {
+			for _, name := range secrets.Names(step.Text) {
+				if _, ok := values[name]; ok || !IsRunSecret(name) {
+					continue
+				}
+				if value, ok := lookup(name); ok {
+					values[name] = value
+				}
+			}
+		}
+	}
+	add(run.Steps)
+	for _, t := range run.TransitionList {
+		add(t.Steps)
+	}
+	for _, f := range run.Findings {
+		add(f.Steps)
+	}
+	return cloneSecrets(values)
+}
+
+// secretValue returns a hidden value generated by this run.
+func (e *Engine) secretValue(name string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	value, ok := e.secrets[name]
+	return value, ok
+}
+
+// Secrets returns the values generated for hidden fields so far, keyed by
+// the names their ${secret:NAME} placeholders use.
+func (e *Engine) Secrets() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return cloneSecrets(e.secrets)
+}
+
+func cloneSecrets(values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		out[k] = v
+	}
+	return out
+}
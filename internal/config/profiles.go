@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ConnectionProfile is a named set of connection settings for one host. Its
+// values take precedence over the global s3270 options and .env overrides
+// when a session connects through the profile.
+type ConnectionProfile struct {
+	Name         string `json:"name"`
+	Host         string `json:"host"`
+	Port         int    `json:"port,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Oversize     string `json:"oversize,omitempty"`
+	CodePage     string `json:"codePage,omitempty"`
+	LU           string `json:"lu,omitempty"`
+	DeviceName   string `json:"deviceName,omitempty"`
+	TLS          bool   `json:"tls,omitempty"`
+	NoVerifyCert bool   `json:"noVerifyCert,omitempty"`
+	CAFile       string `json:"caFile,omitempty"`
+	CertFile     string `json:"certFile,omitempty"`
+	KeyFile      string `json:"keyFile,omitempty"`
+	LoginMacro   string `json:"loginMacro,omitempty"`
+	Proxy        string `json:"proxy,omitempty"`
+}
+
+type profilesFile struct {
+	Profiles []ConnectionProfile `json:"profiles"`
+}
+
+// Address returns host:port, or just the host when no port is set.
+func (p ConnectionProfile) Address() string {
+	if p.Port <= 0 {
+		return p.Host
+	}
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+}
+
+// S3270Host returns the host argument in s3270 syntax: an L: prefix for TLS
+// and the LU name before an @.
+func (p ConnectionProfile) S3270Host() string {
+	target := p.Address()
+	if p.LU != "" {
+		target = p.LU + "@" + target
+	}
+	if p.TLS {
+		target = "L:" + target
+	}
+	return target
+}
+
+// S3270Args returns the s3270 flags for the profile's settings other than
+// model and code page, which the caller merges with the global options.
+func (p ConnectionProfile) S3270Args() []string {
+	var args []string
+	add := func(flag, value string) {
+		if value != "" {
+			args = append(args, flag, value)
+		}
+	}
+	add("-oversize", p.Oversize)
+	add("-devname", p.DeviceName)
+	add("-cafile", p.CAFile)
+	add("-certfile", p.CertFile)
+	add("-keyfile", p.KeyFile)
+	if p.NoVerifyCert {
+		args = append(args, "-noverifycert")
+	}
+	add("-loginmacro", p.LoginMacro)
+	add("-proxy", p.Proxy)
+	return args
+}
+
+// OverriddenEnvVars lists the .env options the profile replaces, so the
+// global value is not passed to s3270 as well.
+func (p ConnectionProfile) OverriddenEnvVars() []string {
+	var vars []string
+	for _, o := range []struct {
+		set    bool
+		envVar string
+	}{
+		{p.Model != "", "S3270_MODEL"},
+		{p.CodePage != "", "S3270_CODE_PAGE"},
+		{p.Oversize != "", "S3270_OVERSIZE"},
+		{p.DeviceName != "", "S3270_DEV_NAME"},
+		{p.CAFile != "", "S3270_CA_FILE"},
+		{p.CertFile != "", "S3270_CERT_FILE"},
+		{p.KeyFile != "", "S3270_KEY_FILE"},
+		{p.NoVerifyCert, "S3270_NO_VERIFY_CERT"},
+		{p.LoginMacro != "", "S3270_LOGIN_MACRO"},
+		{p.Proxy != "", "S3270_PROXY"},
+	} {
+		if o.set {
+			vars = append(vars, o.envVar)
+		}
+	}
+	return vars
+}
+
+// LoadProfiles reads connection profiles from a JSON file. A missing file
+// holds no profiles.
+func LoadProfiles(path string) ([]ConnectionProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ConnectionProfile{}, nil
+		}
+		return nil, fmt.Errorf("read connection profiles: %w", err)
+	}
+	var file profilesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse connection profiles: %w", err)
+	}
+	if file.Profiles == nil {
+		file.Profiles = []ConnectionProfile{}
+	}
+	return file.Profiles, nil
+}
+
+// SaveProfiles writes connection profiles to a JSON file, readable only by
+// the owner since profiles may name key files and proxies.
+func SaveProfiles(path string, profiles []ConnectionProfile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("create connection profiles directory: %w", err)
+	}
+	data, err := json.MarshalIndent(profilesFile{Profiles: profiles}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal connection profiles: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("write connection profiles: %w", err)
+	}
+	return nil
+}
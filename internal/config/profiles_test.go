@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConnectionProfileS3270Translation(t *testing.T) {
+	p := ConnectionProfile{
+		Name:         "prod",
+		Host:         "mvs.example.com",
+		Port:         992,
+		LU:           "TSO001",
+		TLS:          true,
+		NoVerifyCert: true,
+		Oversize:     "132x43",
+		CAFile:       "/etc/ca.pem",
+		LoginMacro:   `String("ME") Enter`,
+	}
+	if got, want := p.S3270Host(), "L:TSO001@mvs.example.com:992"; got != want {
+		t.Errorf("S3270Host() = %q, want %q", got, want)
+	}
+	wantArgs := []string{"-oversize", "132x43", "-cafile", "/etc/ca.pem", "-noverifycert", "-loginmacro", `String("ME") Enter`}
+	if got := p.S3270Args(); !reflect.DeepEqual(got, wantArgs) {
+		t.Errorf("S3270Args() = %q, want %q", got, wantArgs)
+	}
+	wantVars := []string{"S3270_OVERSIZE", "S3270_CA_FILE", "S3270_NO_VERIFY_CERT", "S3270_LOGIN_MACRO"}
+	if got := p.OverriddenEnvVars(); !reflect.DeepEqual(got, wantVars) {
+		t.Errorf("OverriddenEnvVars() = %q, want %q", got, wantVars)
+	}
+	if got := (ConnectionProfile{Host: "::1"}).Address(); got != "::1" {
+		t.Errorf("Address() without port = %q, want ::1", got)
+	}
+	if got := (ConnectionProfile{Host: "::1", Port: 23}).Address(); got != "[::1]:23" {
+		t.Errorf("Address() = %q, want [::1]:23", got)
+	}
+}
+
+func TestSaveAndLoadProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "profiles.json")
+	profiles, err := LoadProfiles(path)
+	if err != nil || len(profiles) != 0 {
+		t.Fatalf("LoadProfiles(missing) = %v, %v; want empty", profiles, err)
+	}
+	want := []ConnectionProfile{{Name: "test", Host: "localhost", Port: 3270, Model: "3278-2"}}
+	if err := SaveProfiles(path, want); err != nil {
+		t.Fatalf("SaveProfiles: %v", err)
+	}
+	got, err := LoadProfiles(path)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadProfiles = %+v, %v; want %+v", got, err, want)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+		t.Errorf("profiles file mode = %v, want owner-only", info.Mode().Perm())
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProfiles(path); err == nil {
+		t.Fatal("LoadProfiles(invalid JSON) succeeded, want error")
+	}
+}
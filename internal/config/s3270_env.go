@@ -530,15 +530,26 @@ func LoadDotEnv(path string) error {
 	return scanner.Err()
 }
 
-// S3270EnvOverridesFromEnv builds s3270 command-line arguments from environment variables.
-func S3270EnvOverridesFromEnv() (S3270EnvOverrides, error) {
+// S3270EnvOverridesFromEnv builds s3270 command-line arguments from environment
+// variables, ignoring the variables named in skip.
+func S3270EnvOverridesFromEnv(skip ...string) (S3270EnvOverrides, error) {
 	var overrides S3270EnvOverrides
 	var parseErr error
-	certFile := strings.TrimSpace(os.Getenv("S3270_CERT_FILE"))
-	keyFile := strings.TrimSpace(os.Getenv("S3270_KEY_FILE"))
+	skipped := make(map[string]bool, len(skip))
+	for _, envVar := range skip {
+		skipped[envVar] = true
+	}
+	lookup := func(envVar string) string {
+		if skipped[envVar] {
+			return ""
+		}
+		return strings.TrimSpace(os.Getenv(envVar))
+	}
+	certFile := lookup("S3270_CERT_FILE")
+	keyFile := lookup("S3270_KEY_FILE")
 
 	for _, spec := range s3270OptionSpecs {
-		value := strings.TrimSpace(os.Getenv(spec.EnvVar))
+		value := lookup(spec.EnvVar)
 		if value == "" {
 			continue
 		}
@@ -599,6 +610,27 @@ func buildDotEnvContent() string {
 	buf.WriteString("# 3270Web UI preferences.\n")
 	buf.WriteString("# Show virtual keyboard by default for new sessions.\n")
 	buf.WriteString("APP_USE_KEYPAD=false\n")
+	buf.WriteString("# Default connection engine: s3270 (subprocess) or native (built-in TN3270 client).\n")
+	buf.WriteString("APP_HOST_ENGINE=s3270\n")
+	buf.WriteString("# Store the expected screen on each recorded key step for playback verification.\n")
+	buf.WriteString("APP_RECORD_SCREENS=false\n")
+	buf.WriteString("# Bearer token for the /api/v1 automation API (empty disables the API).\n")
+	buf.WriteString("APP_API_TOKEN=\n")
+	buf.WriteString("# Passphrase for the encrypted secrets file used to resolve ${secret:NAME} in recordings.\n")
+	buf.WriteString("APP_SECRETS_KEY=\n")
+	buf.WriteString("# Minutes of inactivity before a session is closed (0 disables expiry).\n")
+	buf.WriteString("APP_SESSION_IDLE_TIMEOUT_MIN=30\n")
+	buf.WriteString("# Screens each session keeps for the screen history panel (0 disables it).\n")
+	buf.WriteString("APP_SCREEN_HISTORY_SIZE=50\n")
+	buf.WriteString("# OpenID Connect sign-in (empty issuer disables it). Local accounts live in users.json.\n")
+	buf.WriteString("APP_OIDC_ISSUER=\n")
+	buf.WriteString("APP_OIDC_CLIENT_ID=\n")
+	buf.WriteString("APP_OIDC_CLIENT_SECRET=\n")
+	buf.WriteString("# Callback URL registered with the identity provider (empty derives it from the request).\n")
+	buf.WriteString("APP_OIDC_REDIRECT_URL=\n")
+	buf.WriteString("# ID token claim holding user, tester or admin, and the role for users without one.\n")
+	buf.WriteString("APP_OIDC_ROLE_CLAIM=role\n")
+	buf.WriteString("APP_OIDC_DEFAULT_ROLE=\n")
 	buf.WriteString("# Chaos Explorer defaults.\n")
 	buf.WriteString("CHAOS_MAX_STEPS=100\n")
 	buf.WriteString("CHAOS_TIME_BUDGET_SEC=300\n")
@@ -608,6 +640,12 @@ func buildDotEnvContent() string {
 	buf.WriteString("CHAOS_OUTPUT_FILE=\n")
 	buf.WriteString("# Exclude no-progress chaos events (no screen transition) from chaos event history.\n")
 	buf.WriteString("CHAOS_EXCLUDE_NO_PROGRESS_EVENTS=true\n")
+	buf.WriteString("# Chaos exploration strategy: random or frontier.\n")
+	buf.WriteString("CHAOS_STRATEGY=random\n")
+	buf.WriteString("# Chaos screen regions ignored when telling screens apart, e.g. R1C72W8; /\\d\\d:\\d\\d/\n")
+	buf.WriteString("CHAOS_MASK_RULES=\n")
+	buf.WriteString("# Also ignore regions that change between chaos visits with the same input.\n")
+	buf.WriteString("CHAOS_AUTO_MASK=true\n")
 	return buf.String()
 }
 
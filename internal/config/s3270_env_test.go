@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -336,3 +337,22 @@ func TestUpsertDotEnvValueAppendsMissingKey(t *testing.T) {
 		t.Fatalf("expected appended key, got: %q", text)
 	}
 }
+
+func TestS3270EnvOverridesSkip(t *testing.T) {
+	os.Setenv("S3270_PROXY", "socks5:global:1080")
+	os.Setenv("S3270_CERT_FILE", "/etc/global.pem")
+	os.Setenv("S3270_CERT_FILE_TYPE", "asn1")
+	os.Setenv("S3270_TRACE", "true")
+	defer os.Unsetenv("S3270_PROXY")
+	defer os.Unsetenv("S3270_CERT_FILE")
+	defer os.Unsetenv("S3270_CERT_FILE_TYPE")
+	defer os.Unsetenv("S3270_TRACE")
+
+	overrides, err := S3270EnvOverridesFromEnv("S3270_PROXY", "S3270_CERT_FILE")
+	if err != nil {
+		t.Fatalf("S3270EnvOverridesFromEnv failed: %v", err)
+	}
+	if want := []string{"-trace"}; !reflect.DeepEqual(overrides.Args, want) {
+		t.Errorf("Args = %q, want %q", overrides.Args, want)
+	}
+}
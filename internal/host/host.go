@@ -18,12 +18,23 @@ type Host interface {
 	SubmitUnformatted(data string) error
 }
 
+// ScreenNotifier is implemented by hosts that know when their screen
+// changes, so callers can wait for a change instead of polling UpdateScreen.
+type ScreenNotifier interface {
+	// ScreenChanged returns a channel closed at the next change to the
+	// screen, keyboard lock or connection, or nil when the host cannot
+	// tell right now.
+	ScreenChanged() <-chan struct{}
+}
+
 // MockHost is a mock implementation of Host for testing.
 type MockHost struct {
 	Screen    *Screen
 	DumpFile  string
 	Connected bool
 	Commands  []string
+	// TransferData is written to the local file of receive transfers.
+	TransferData []byte
 }
 
 func NewMockHost(dumpFile string) (*MockHost, error) {
@@ -154,3 +165,18 @@ func (m *MockHost) SubmitUnformatted(data string) error {
 	}
 	return nil
 }
+
+// Transfer records the Transfer() command and, for downloads, writes
+// TransferData to the local file.
+func (m *MockHost) Transfer(opts TransferOptions) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	m.Commands = append(m.Commands, opts.Command())
+	if opts.Direction == TransferReceive {
+		if err := os.WriteFile(opts.LocalFile, m.TransferData, 0600); err != nil {
+			return "", err
+		}
+	}
+	return "Transfer complete", nil
+}
@@ -0,0 +1,225 @@
+package host
+
+import (
+	"strings"
+
+	"github.com/racingmars/go3270"
+)
+
+// SCS control codes understood by the printer session.
+const (
+	scsHT  = 0x05 // Horizontal Tab
+	scsVT  = 0x0B // Vertical Tab
+	scsFF  = 0x0C // Form Feed
+	scsCR  = 0x0D // Carriage Return
+	scsNL  = 0x15 // New Line
+	scsBS  = 0x16 // Backspace
+	scsEM  = 0x19 // End of Medium (3270 print buffers)
+	scsIRS = 0x1E // Interchange Record Separator
+	scsLF  = 0x25 // Line Feed
+	scsCSP = 0x2B // Control Sequence Prefix
+	scsPP  = 0x34 // Presentation Position
+)
+
+// Presentation Position sub-commands.
+const (
+	scsPPAbsoluteHorizontal = 0xC0
+	scsPPRelativeHorizontal = 0xC8
+	scsPPAbsoluteVertical   = 0xC4
+	scsPPRelativeVertical   = 0x4C
+)
+
+const (
+	printMaxColumns = 132
+	printTabWidth   = 8
+)
+
+// printOutput lays printed characters out into lines and pages.
+type printOutput struct {
+	pages [][]string
+	lines []string
+	line  []rune
+	col   int
+}
+
+func (o *printOutput) put(r rune) {
+	for len(o.line) < o.col {
+		o.line = append(o.line, ' ')
+	}
+	if o.col < len(o.line) {
+		o.line[o.col] = r
+	} else {
+		o.line = append(o.line, r)
+	}
+	o.col++
+}
+
+// lineFeed moves down a line, keeping the column.
+func (o *printOutput) lineFeed() {
+	o.lines = append(o.lines, strings.TrimRight(string(o.line), " "))
+	o.line = o.line[:0]
+}
+
+// newline moves to the start of the next line.
+func (o *printOutput) newline() {
+	o.lineFeed()
+	o.col = 0
+}
+
+// formFeed ends the current page. Form feeds before any output on the page
+// are ignored so jobs that start with one do not begin with a blank page.
+func (o *printOutput) formFeed() {
+	if len(o.line) > 0 {
+		o.newline()
+	}
+	o.col = 0
+	for len(o.lines) > 0 && o.lines[len(o.lines)-1] == "" {
+		o.lines = o.lines[:len(o.lines)-1]
+	}
+	if len(o.lines) > 0 {
+		o.pages = append(o.pages, o.lines)
+		o.lines = nil
+	}
+}
+
+// finish flushes the last page and returns the job's pages.
+func (o *printOutput) finish() [][]string {
+	o.formFeed()
+	return o.pages
+}
+
+// printable decodes one EBCDIC character, reporting false for characters
+// with nothing to print.
+func printable(cp go3270.Codepage, ch byte) (rune, bool) {
+	decoded := []rune(cp.Decode([]byte{ch}))
+	if len(decoded) != 1 {
+		return 0, false
+	}
+	r := decoded[0]
+	if r < 0x20 || (r >= 0x7F && r < 0xA0) {
+		return 0, false
+	}
+	return r, true
+}
+
+// writeSCS interprets an SNA Character String, the LU1 print data stream.
+// Formatting controls that only change fonts or page geometry are skipped.
+func (o *printOutput) writeSCS(data []byte, cp go3270.Codepage) {
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		switch ch {
+		case scsNL, scsIRS, scsVT:
+			o.newline()
+		case scsLF:
+			o.lineFeed()
+		case scsCR:
+			o.col = 0
+		case scsFF:
+			o.formFeed()
+		case scsHT:
+			o.col = (o.col/printTabWidth + 1) * printTabWidth
+		case scsBS:
+			if o.col > 0 {
+				o.col--
+			}
+		case scsCSP:
+			// 2B class length params..., where length counts itself.
+			if i+2 < len(data) && data[i+2] > 0 {
+				i += 1 + int(data[i+2])
+			} else {
+				i = len(data)
+			}
+		case scsPP:
+			if i+2 >= len(data) {
+				i = len(data)
+				continue
+			}
+			value := int(data[i+2])
+			switch data[i+1] {
+			case scsPPAbsoluteHorizontal:
+				if value > 0 {
+					o.col = value - 1
+				}
+			case scsPPRelativeHorizontal:
+				o.col += value
+			case scsPPAbsoluteVertical:
+				for len(o.lines) < value-1 {
+					o.newline()
+				}
+			case scsPPRelativeVertical:
+				for n := 0; n < value; n++ {
+					o.lineFeed()
+				}
+			}
+			i += 2
+		default:
+			if r, ok := printable(cp, ch); ok {
+				if o.col >= printMaxColumns {
+					o.newline()
+				}
+				o.put(r)
+			}
+		}
+	}
+}
+
+// write3270 prints an LU3 buffer. The WCC line-length bits select a fixed
+// 40, 64 or 80 column layout; without them the buffer is printed
+// unformatted, honoring NL, CR, FF and EM and suppressing nulls.
+func (o *printOutput) write3270(b *tnBuffer, wcc byte, cp go3270.Codepage) {
+	width := 0
+	switch wcc & wccPrintLineLength {
+	case 0x10:
+		width = 40
+	case 0x20:
+		width = 64
+	case 0x30:
+		width = 80
+	}
+
+	if width > 0 {
+		for addr, cell := range b.cells {
+			if addr > 0 && addr%width == 0 {
+				o.newline()
+			}
+			r := ' '
+			if !cell.fa {
+				if decoded, ok := printable(cp, cell.ch); ok {
+					r = decoded
+				}
+			}
+			o.put(r)
+		}
+		o.formFeed()
+		return
+	}
+
+	for _, cell := range b.cells {
+		if cell.fa {
+			o.put(' ')
+			continue
+		}
+		switch cell.ch {
+		case 0:
+		case scsNL:
+			o.newline()
+		case scsCR:
+			o.col = 0
+		case scsFF:
+			o.formFeed()
+		case scsEM:
+			o.newline()
+			return
+		default:
+			if r, ok := printable(cp, cell.ch); ok {
+				if o.col >= printMaxColumns {
+					o.newline()
+				}
+				o.put(r)
+			}
+		}
+	}
+	if len(o.line) > 0 {
+		o.newline()
+	}
+}
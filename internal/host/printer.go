@@ -0,0 +1,452 @@
+package host
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/racingmars/go3270"
+)
+
+// TN3270E data types, functions and response codes used by printer sessions
+// (RFC 2355).
+const (
+	tn3270eDataSCS      = 0x01
+	tn3270eDataResponse = 0x02
+	tn3270eDataUnbind   = 0x04
+	tn3270eDataPrintEOJ = 0x08
+
+	tn3270eFuncDataStreamCtl = 0x01
+	tn3270eFuncResponses     = 0x02
+	tn3270eFuncSCSCtlCodes   = 0x03
+
+	tn3270eResponseAlways   = 0x02
+	tn3270ePositiveResponse = 0x00
+	tn3270eDeviceEnd        = 0x00
+)
+
+const (
+	printerDeviceType = "IBM-3287-1"
+	printerJobTimeout = 5 * time.Second
+)
+
+// tn3270eRejectReasons names the DEVICE-TYPE REJECT reason codes.
+var tn3270eRejectReasons = map[byte]string{
+	0: "connection partner error",
+	1: "device in use",
+	2: "invalid associate",
+	3: "invalid name",
+	4: "invalid device type",
+	5: "type name error",
+	6: "unknown error",
+	7: "unsupported request",
+}
+
+// PrinterEndpoint is implemented by hosts that can tell a printer session
+// where to connect and which terminal LU to associate with. The LU is empty
+// when the host did not assign one.
+type PrinterEndpoint interface {
+	PrinterEndpoint() (target, lu string)
+}
+
+// PrinterOptions configures a printer session.
+type PrinterOptions struct {
+	// AssociateLU pairs the printer with a terminal LU, like pr3287 -assoc.
+	// It takes precedence over LU.
+	AssociateLU string
+	// LU requests a specific printer LU when AssociateLU is empty.
+	LU string
+	// CodePage is the host EBCDIC code page, as in TN3270Options.
+	CodePage string
+	// ConnectTimeout bounds the dial, as in TN3270Options.
+	ConnectTimeout time.Duration
+	// JobTimeout ends a job once the host has been quiet this long, for
+	// hosts that do not send an explicit end of job. Defaults to 5 seconds.
+	JobTimeout time.Duration
+	// OnJob receives each completed print job.
+	OnJob func(PrintJob)
+}
+
+// PrintJob is one captured print job, split into pages of text lines.
+type PrintJob struct {
+	Started  time.Time
+	Finished time.Time
+	Pages    [][]string
+}
+
+// Text returns the job as plain text, with a form feed between pages.
+func (j PrintJob) Text() string {
+	var sb strings.Builder
+	for i, page := range j.Pages {
+		if i > 0 {
+			sb.WriteByte('\f')
+		}
+		for _, line := range page {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// LineCount returns the number of lines across all pages.
+func (j PrintJob) LineCount() int {
+	n := 0
+	for _, page := range j.Pages {
+		n += len(page)
+	}
+	return n
+}
+
+// Printer emulates a 3287 printer the way pr3287 does: it opens its own
+// TN3270E connection, binds to a printer LU and turns the SCS or 3270 print
+// data the host sends into print jobs.
+type Printer struct {
+	Target  string
+	Options PrinterOptions
+
+	tnConn     // Guards the fields below with its mu
+	negotiated bool
+	lu         string
+	lastErr    string
+	functions  map[byte]bool
+	buf        *tnBuffer
+	out        *printOutput
+	started    time.Time
+	idle       *time.Timer
+
+	codepage go3270.Codepage
+}
+
+// NewPrinter creates a printer session for target, which accepts the same
+// "host", "host:port" and s3270 "L:lu@host:port" forms as the terminal.
+func NewPrinter(target string, opts PrinterOptions) *Printer {
+	p := &Printer{
+		Target:   target,
+		Options:  opts,
+		codepage: tn3270Codepage(opts.CodePage),
+	}
+	p.tnConn.init(p, "printer", printerDeviceType)
+	return p
+}
+
+// PrinterEndpoint returns the s3270 target and the LU s3270 reports for the
+// current connection.
+func (h *S3270) PrinterEndpoint() (string, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	data, status, err := h.doCommandLocked("Query(LuName)")
+	if err != nil || isS3270Error(status, data) || len(data) == 0 {
+		return h.TargetHost, ""
+	}
+	return h.TargetHost, strings.TrimSpace(strings.TrimPrefix(data[0], "data:"))
+}
+
+// printerAddress strips s3270 connection prefixes and LU names from target
+// and adds the default port. It reports whether the L: (TLS) prefix was set.
+func printerAddress(target string) (string, bool) {
+	target = strings.TrimSpace(target)
+	useTLS := false
+	for len(target) > 2 && target[1] == ':' && strings.ContainsRune("ABCLNPSYabclnpsy", rune(target[0])) {
+		if target[0] == 'L' || target[0] == 'l' {
+			useTLS = true
+		}
+		target = target[2:]
+	}
+	if at := strings.LastIndex(target, "@"); at >= 0 {
+		target = target[at+1:]
+	}
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target, useTLS
+	}
+	if strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]") {
+		target = target[1 : len(target)-1]
+	}
+	return net.JoinHostPort(target, tn3270DefaultPort), useTLS
+}
+
+// Start connects and negotiates the printer LU. It fails when the host
+// rejects the device type or association.
+func (p *Printer) Start() error {
+	if strings.TrimSpace(p.Target) == "" {
+		return fmt.Errorf("target host not set")
+	}
+	_ = p.Stop()
+
+	addr, useTLS := printerAddress(p.Target)
+	conn, err := dialTN3270(addr, useTLS, p.Options.ConnectTimeout)
+	if err != nil {
+		return fmt.Errorf("printer failed to connect to %s: %w", addr, err)
+	}
+
+	p.mu.Lock()
+	p.attachLocked(conn)
+	p.negotiated = false
+	p.lu = ""
+	p.lastErr = ""
+	p.functions = map[byte]bool{}
+	p.buf = newTNBuffer(24, 80)
+	p.out = &printOutput{}
+	p.mu.Unlock()
+
+	go p.readLoop(conn)
+
+	// Hosts without TN3270E never confirm a device type; carry on in plain
+	// tn3270 mode once the negotiation window has passed.
+	p.waitFor(tn3270StartTimeout, func() bool { return !p.connected || p.negotiated })
+
+	p.mu.Lock()
+	connected, lastErr := p.connected, p.lastErr
+	p.mu.Unlock()
+	if !connected {
+		_ = p.Stop()
+		if lastErr == "" {
+			lastErr = "connection closed during negotiation"
+		}
+		return fmt.Errorf("printer session: %s", lastErr)
+	}
+	return nil
+}
+
+// Stop disconnects, ending any job in progress.
+func (p *Printer) Stop() error {
+	p.mu.Lock()
+	conn := p.conn
+	p.conn = nil
+	p.connected = false
+	job, ok := p.finishJobLocked()
+	p.notifyLocked()
+	p.mu.Unlock()
+	if ok {
+		p.deliver(job)
+	}
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// IsConnected reports whether the printer session is still open.
+func (p *Printer) IsConnected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.connected
+}
+
+// LU returns the printer LU the host assigned, if any.
+func (p *Printer) LU() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lu
+}
+
+// LastError returns why the host rejected or dropped the session.
+func (p *Printer) LastError() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// connectionClosed ends the job in progress when conn was the current
+// connection.
+func (p *Printer) connectionClosed(conn net.Conn, err error) {
+	p.mu.Lock()
+	var job PrintJob
+	var ok bool
+	if p.conn == conn {
+		p.connected = false
+		job, ok = p.finishJobLocked()
+		log.Printf("printer: connection closed: %v", err)
+	}
+	p.notifyLocked()
+	p.mu.Unlock()
+	if ok {
+		p.deliver(job)
+	}
+}
+
+// handleTN3270E requests the printer device type, associated with the
+// terminal LU when one is configured, and agrees on the print functions.
+func (p *Printer) handleTN3270E(sub []byte) {
+	switch {
+	case len(sub) >= 2 && sub[0] == tn3270eSend && sub[1] == tn3270eDeviceType:
+		req := []byte{tn3270eDeviceType, tn3270eRequest}
+		req = append(req, printerDeviceType...)
+		if lu := strings.TrimSpace(p.Options.AssociateLU); lu != "" {
+			req = append(req, tn3270eAssociate)
+			req = append(req, lu...)
+		} else if lu := strings.TrimSpace(p.Options.LU); lu != "" {
+			req = append(req, tn3270eConnect)
+			req = append(req, lu...)
+		}
+		p.sendSubnegotiation(telnetOptTN3270E, req)
+	case len(sub) >= 2 && sub[0] == tn3270eDeviceType && sub[1] == tn3270eIs:
+		lu := ""
+		for i := 2; i < len(sub); i++ {
+			if sub[i] == tn3270eConnect {
+				lu = string(sub[i+1:])
+				break
+			}
+		}
+		p.mu.Lock()
+		p.lu = lu
+		p.mu.Unlock()
+		p.sendSubnegotiation(telnetOptTN3270E, []byte{tn3270eFunctions, tn3270eRequest,
+			tn3270eFuncDataStreamCtl, tn3270eFuncResponses, tn3270eFuncSCSCtlCodes})
+	case len(sub) >= 2 && sub[0] == tn3270eDeviceType && sub[1] == tn3270eReject:
+		reason := "device type rejected"
+		if len(sub) >= 4 && sub[2] == tn3270eReason {
+			if name, ok := tn3270eRejectReasons[sub[3]]; ok {
+				reason = "host rejected the printer: " + name
+			}
+		}
+		p.mu.Lock()
+		p.lastErr = reason
+		conn := p.conn
+		p.mu.Unlock()
+		if conn != nil {
+			_ = conn.Close()
+		}
+	case len(sub) >= 2 && sub[0] == tn3270eFunctions && sub[1] == tn3270eRequest:
+		var agreed []byte
+		for _, fn := range sub[2:] {
+			switch fn {
+			case tn3270eFuncDataStreamCtl, tn3270eFuncResponses, tn3270eFuncSCSCtlCodes:
+				agreed = append(agreed, fn)
+			}
+		}
+		p.setFunctions(agreed)
+		p.sendSubnegotiation(telnetOptTN3270E, append([]byte{tn3270eFunctions, tn3270eIs}, agreed...))
+	case len(sub) >= 2 && sub[0] == tn3270eFunctions && sub[1] == tn3270eIs:
+		p.setFunctions(sub[2:])
+	}
+}
+
+func (p *Printer) setFunctions(functions []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.functions = map[byte]bool{}
+	for _, fn := range functions {
+		p.functions[fn] = true
+	}
+	p.tn3270e = true
+	p.negotiated = true
+	p.notifyLocked()
+}
+
+// handleRecord adds SCS or 3270 print data to the current job and ends the
+// job on PRINT-EOJ or UNBIND.
+func (p *Printer) handleRecord(record []byte) {
+	p.mu.Lock()
+	var response []byte
+	dataType := byte(tn3270eData3270)
+	if p.tn3270e {
+		if len(record) < 5 {
+			p.mu.Unlock()
+			return
+		}
+		dataType = record[0]
+		if record[2] == tn3270eResponseAlways && p.functions[tn3270eFuncResponses] {
+			response = []byte{tn3270eDataResponse, 0, tn3270ePositiveResponse, record[3], record[4], tn3270eDeviceEnd}
+		}
+		record = record[5:]
+	}
+
+	var job PrintJob
+	var done bool
+	switch dataType {
+	case tn3270eData3270:
+		if len(record) > 0 {
+			p.startJobLocked()
+			if err := p.process3270Locked(record[0], record[1:]); err != nil {
+				log.Printf("printer: %v", err)
+			}
+		}
+	case tn3270eDataSCS:
+		p.startJobLocked()
+		p.out.writeSCS(record, p.codepage)
+	case tn3270eDataPrintEOJ, tn3270eDataUnbind:
+		job, done = p.finishJobLocked()
+	}
+	p.mu.Unlock()
+
+	if response != nil {
+		_ = p.sendRecord(response)
+	}
+	if done {
+		p.deliver(job)
+	}
+}
+
+// process3270Locked applies an LU3 write to the printer buffer and prints
+// the buffer when the WCC start-print bit is set.
+func (p *Printer) process3270Locked(cmd byte, data []byte) error {
+	switch cmd {
+	case cmdWrite, cmdWriteSNA, cmdEraseWrite, cmdEraseWriteSNA, cmdEraseWriteAlternate, cmdEraseWriteAlternateSNA:
+		wcc, err := p.buf.write(cmd, data)
+		if wcc&wccStartPrinter != 0 {
+			p.out.write3270(p.buf, wcc, p.codepage)
+		}
+		return err
+	case cmdEraseAllUnprotected, cmdEraseAllUnprotectedSNA:
+		p.buf.eraseAllUnprotected()
+		return nil
+	case cmdWriteStructuredField, cmdWriteStructuredFieldSNA:
+		return nil
+	default:
+		return fmt.Errorf("unsupported 3270 print command 0x%02x", cmd)
+	}
+}
+
+// startJobLocked opens a job if none is in progress and, unless the host
+// marks job ends itself, restarts the idle timer that closes it.
+func (p *Printer) startJobLocked() {
+	if p.started.IsZero() {
+		p.started = time.Now()
+	}
+	if p.functions[tn3270eFuncDataStreamCtl] {
+		return
+	}
+	timeout := p.Options.JobTimeout
+	if timeout <= 0 {
+		timeout = printerJobTimeout
+	}
+	if p.idle != nil {
+		p.idle.Stop()
+	}
+	p.idle = time.AfterFunc(timeout, p.endIdleJob)
+}
+
+func (p *Printer) endIdleJob() {
+	p.mu.Lock()
+	job, ok := p.finishJobLocked()
+	p.mu.Unlock()
+	if ok {
+		p.deliver(job)
+	}
+}
+
+// finishJobLocked closes the current job. It reports false when nothing
+// printable was received.
+func (p *Printer) finishJobLocked() (PrintJob, bool) {
+	if p.idle != nil {
+		p.idle.Stop()
+		p.idle = nil
+	}
+	if p.out == nil || p.started.IsZero() {
+		return PrintJob{}, false
+	}
+	pages := p.out.finish()
+	job := PrintJob{Started: p.started, Finished: time.Now(), Pages: pages}
+	p.out = &printOutput{}
+	p.started = time.Time{}
+	return job, len(pages) > 0
+}
+
+func (p *Printer) deliver(job PrintJob) {
+	if p.Options.OnJob != nil {
+		p.Options.OnJob(job)
+	}
+}
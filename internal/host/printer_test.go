@@ -0,0 +1,173 @@
+package host
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/sampleapps"
+	"github.com/racingmars/go3270"
+)
+
+func TestPrinterCapturesSampleAppReport(t *testing.T) {
+	port := freeLocalPort(t)
+	server, err := sampleapps.StartServer("app3", port)
+	if err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	defer server.Stop()
+	target := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	terminal := NewTN3270(target, TN3270Options{})
+	if err := terminal.Start(); err != nil {
+		t.Fatalf("terminal Start failed: %v", err)
+	}
+	defer terminal.Stop()
+	addr, lu := terminal.PrinterEndpoint()
+	if addr != target || !strings.HasPrefix(lu, "SAMPT") {
+		t.Fatalf("PrinterEndpoint = %q, %q, want %q and a terminal LU", addr, lu, target)
+	}
+
+	jobs := make(chan PrintJob, 1)
+	printer := NewPrinter(addr, PrinterOptions{AssociateLU: lu, OnJob: func(job PrintJob) { jobs <- job }})
+	if err := printer.Start(); err != nil {
+		t.Fatalf("printer Start failed: %v", err)
+	}
+	defer printer.Stop()
+	if !strings.HasPrefix(printer.LU(), "SAMPP") {
+		t.Fatalf("printer LU = %q, want a printer LU", printer.LU())
+	}
+
+	if err := terminal.WriteStringAt(9, 20, "2"); err != nil {
+		t.Fatalf("WriteStringAt failed: %v", err)
+	}
+	if err := terminal.SendKey("PF(4)"); err != nil {
+		t.Fatalf("SendKey(PF4) failed: %v", err)
+	}
+
+	select {
+	case job := <-jobs:
+		if len(job.Pages) != 2 {
+			t.Fatalf("pages = %d, want 2", len(job.Pages))
+		}
+		if got := job.Pages[0][0]; !strings.HasPrefix(got, "DAILY SALES REPORT") || !strings.HasSuffix(got, "PAGE 1 OF 2") {
+			t.Fatalf("first line = %q, want report title and page", got)
+		}
+		text := job.Text()
+		if !strings.Contains(text, "TOTAL") || strings.Count(text, "\f") != 1 {
+			t.Fatalf("text = %q, want two report pages", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no print job received")
+	}
+
+	if err := terminal.UpdateScreen(); err != nil {
+		t.Fatalf("UpdateScreen failed: %v", err)
+	}
+	if got := screenRow(terminal.GetScreen(), 14); !strings.Contains(got, "Report sent to printer "+printer.LU()) {
+		t.Fatalf("row 15 = %q, want confirmation", got)
+	}
+}
+
+func TestPrinterRejectsUnknownAssociation(t *testing.T) {
+	port := freeLocalPort(t)
+	server, err := sampleapps.StartServer("app3", port)
+	if err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	defer server.Stop()
+
+	printer := NewPrinter(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), PrinterOptions{AssociateLU: "NOSUCHLU"})
+	err = printer.Start()
+	if err == nil || !strings.Contains(err.Error(), "invalid associate") {
+		t.Fatalf("Start error = %v, want invalid associate", err)
+	}
+	if printer.IsConnected() {
+		t.Fatalf("IsConnected = true after rejection")
+	}
+}
+
+func TestPrintOutputSCS(t *testing.T) {
+	cp := go3270.CodepageBracket()
+	var data []byte
+	data = append(data, scsFF)
+	data = append(data, cp.Encode("HELLO")...)
+	data = append(data, scsNL)
+	data = append(data, scsCSP, 0xC1, 0x02, 0x84) // set horizontal format
+	data = append(data, scsPP, scsPPAbsoluteHorizontal, 5)
+	data = append(data, cp.Encode("X")...)
+	data = append(data, scsHT)
+	data = append(data, cp.Encode("Y")...)
+	data = append(data, scsFF)
+	data = append(data, cp.Encode("PAGE2")...)
+	data = append(data, scsCR)
+	data = append(data, cp.Encode("p")...)
+
+	out := &printOutput{}
+	out.writeSCS(data, cp)
+	pages := out.finish()
+	want := [][]string{{"HELLO", "    X   Y"}, {"pAGE2"}}
+	if len(pages) != len(want) {
+		t.Fatalf("pages = %q, want %q", pages, want)
+	}
+	for i := range want {
+		if strings.Join(pages[i], "|") != strings.Join(want[i], "|") {
+			t.Fatalf("page %d = %q, want %q", i+1, pages[i], want[i])
+		}
+	}
+}
+
+func TestPrintOutputWrite3270(t *testing.T) {
+	cp := go3270.CodepageBracket()
+	b := newTNBuffer(24, 80)
+	data := []byte{0x00}
+	data = append(data, cp.Encode("LINE ONE")...)
+	data = append(data, scsNL)
+	data = append(data, cp.Encode("LINE TWO")...)
+	data = append(data, scsEM)
+	data = append(data, cp.Encode("IGNORED")...)
+	if _, err := b.write(cmdEraseWrite, data); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		wcc  byte
+		want []string
+	}{
+		{"unformatted", wccStartPrinter, []string{"LINE ONE", "LINE TWO"}},
+		{"40 columns", wccStartPrinter | 0x10, []string{"LINE ONE LINE TWO IGNORED"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &printOutput{}
+			out.write3270(b, tt.wcc, cp)
+			pages := out.finish()
+			if len(pages) != 1 || strings.Join(pages[0], "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("pages = %q, want %q", pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrinterAddress(t *testing.T) {
+	tests := []struct {
+		target  string
+		addr    string
+		withTLS bool
+	}{
+		{"mainframe.example.com", "mainframe.example.com:23", false},
+		{"mainframe.example.com:3270", "mainframe.example.com:3270", false},
+		{"L:mainframe.example.com:992", "mainframe.example.com:992", true},
+		{"L:Y:LU01@mainframe.example.com:992", "mainframe.example.com:992", true},
+		{"[::1]", "[::1]:23", false},
+	}
+	for _, tt := range tests {
+		addr, withTLS := printerAddress(tt.target)
+		if addr != tt.addr || withTLS != tt.withTLS {
+			t.Errorf("printerAddress(%q) = %q, %v, want %q, %v", tt.target, addr, withTLS, tt.addr, tt.withTLS)
+		}
+	}
+}
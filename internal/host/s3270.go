@@ -2,6 +2,7 @@ package host
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -28,6 +29,7 @@ type S3270 struct {
 	screen         *Screen
 	mu             sync.Mutex // Protects command execution
 	verboseLogging bool
+	transferring   bool // An IND$FILE transfer has the connection
 }
 
 const (
@@ -52,6 +54,9 @@ func NewS3270(execPath string, args ...string) *S3270 {
 func (h *S3270) Start() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return err
+	}
 
 	h.cmd = exec.Command(h.ExecPath, h.Args...)
 	configureCmd(h.cmd)
@@ -81,7 +86,7 @@ func (h *S3270) Start() error {
 		return fmt.Errorf("failed to start s3270: %w", err)
 	}
 
-	go h.captureStderr()
+	go h.captureStderr(h.stderr)
 
 	if h.TargetHost == "" {
 		return nil
@@ -132,11 +137,17 @@ func (h *S3270) UpdateScreen() error {
 func (h *S3270) updateScreenOnce() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return err
+	}
 
 	for i := 0; i < 50; i++ {
+		// s3270 answers a locked or disconnected read with "error", so
+		// those replies are handled before the error itself.
 		lines, status, err := h.doCommandLocked("readbuffer ascii")
-		if err != nil {
-			return err
+		if len(lines) > 0 && strings.HasPrefix(lines[0], "data: Keyboard locked") {
+			time.Sleep(100 * time.Millisecond)
+			continue
 		}
 		if isDisconnectedStatus(status) {
 			if err := h.reconnectLocked(); err != nil {
@@ -144,9 +155,8 @@ func (h *S3270) updateScreenOnce() error {
 			}
 			continue
 		}
-		if len(lines) > 0 && strings.HasPrefix(lines[0], "data: Keyboard locked") {
-			time.Sleep(100 * time.Millisecond)
-			continue
+		if err != nil {
+			return err
 		}
 		return h.screen.Update(status, lines)
 	}
@@ -184,6 +194,9 @@ func (h *S3270) withRetry(op func() error) error {
 func (h *S3270) sendKeyOnce(key string) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return err
+	}
 
 	// Sentinel: Prevent command injection via s3270 pipe
 	if strings.ContainsAny(key, "\n\r\t;") {
@@ -226,7 +239,7 @@ func (h *S3270) executeKeyCommand(cmd string, isAid bool) ([]string, string, err
 	data, status, err := h.doCommandLocked(cmd)
 	log.Printf("s3270: cmd=%q status=%q", cmd, status)
 
-	if err == nil && isDisconnectedStatus(status) {
+	if (err == nil || isCommandError(err)) && isDisconnectedStatus(status) {
 		if rErr := h.reconnectLocked(); rErr != nil {
 			return data, status, rErr, true
 		}
@@ -248,6 +261,9 @@ func (h *S3270) executeKeyCommand(cmd string, isAid bool) ([]string, string, err
 func (h *S3270) writeStringAtOnce(row, col int, text string) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return err
+	}
 
 	if text == "" {
 		return nil
@@ -262,12 +278,12 @@ func (h *S3270) writeStringAtOnce(row, col int, text string) error {
 		keyCmd := fmt.Sprintf("key(0x%x)", r)
 		_, status, err = h.doCommandLocked(keyCmd)
 		log.Printf("s3270: cmd=%q status=%q", keyCmd, status)
-		if err != nil {
-			return err
-		}
 		if isDisconnectedStatus(status) {
 			return fmt.Errorf("not connected")
 		}
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -275,6 +291,9 @@ func (h *S3270) writeStringAtOnce(row, col int, text string) error {
 func (h *S3270) MoveCursor(row, col int) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return err
+	}
 
 	cmd := fmt.Sprintf("movecursor(%d, %d)", row, col)
 	_, status, err := h.doCommandLocked(cmd)
@@ -303,6 +322,9 @@ func (h *S3270) waitUnlockCommand() string {
 func (h *S3270) SubmitScreen() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return err
+	}
 
 	for _, f := range h.screen.Fields {
 		if !f.IsProtected() && f.Changed {
@@ -358,6 +380,9 @@ func (h *S3270) SubmitFieldUpdates(updates map[string]string) error {
 func (h *S3270) SubmitUnformatted(data string) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return err
+	}
 
 	if h.screen == nil {
 		return fmt.Errorf("screen not initialized")
@@ -453,16 +478,25 @@ func (h *S3270) executeCommandLocked(cmd string, logCmd string) ([]string, strin
 }
 
 func (h *S3270) readResponse() ([]string, string, error) {
+	return h.readResponseFrom(h.stdout)
+}
+
+// readResponseFrom reads one reply: data lines and the status line, closed
+// by "ok" or, when the action failed, by "error". A failed action returns
+// its data and status along with a *commandError.
+func (h *S3270) readResponseFrom(stdout *bufio.Scanner) ([]string, string, error) {
 	var lines []string
+	failed := false
 	for {
-		if !h.stdout.Scan() {
-			if err := h.stdout.Err(); err != nil {
+		if !stdout.Scan() {
+			if err := stdout.Err(); err != nil {
 				return nil, "", err
 			}
 			return nil, "", h.terminalError("s3270 terminated")
 		}
-		line := h.stdout.Text()
-		if line == "ok" {
+		line := stdout.Text()
+		if line == "ok" || line == "error" {
+			failed = line == "error"
 			break
 		}
 		lines = append(lines, line)
@@ -474,12 +508,34 @@ func (h *S3270) readResponse() ([]string, string, error) {
 
 	status := lines[len(lines)-1]
 	data := lines[:len(lines)-1]
+	if failed {
+		return data, status, &commandError{data: data}
+	}
 	return data, status, nil
 }
 
-func (h *S3270) captureStderr() {
-	for h.stderr.Scan() {
-		msg := strings.TrimSpace(h.stderr.Text())
+// commandError reports an action that s3270 answered with "error" instead of
+// "ok". The data lines carry s3270's explanation.
+type commandError struct {
+	data []string
+}
+
+func (e *commandError) Error() string {
+	msg := strings.TrimSpace(strings.Join(trimDataPrefixes(e.data), " "))
+	if msg == "" {
+		msg = "command failed"
+	}
+	return "s3270: " + msg
+}
+
+func isCommandError(err error) bool {
+	var cmdErr *commandError
+	return errors.As(err, &cmdErr)
+}
+
+func (h *S3270) captureStderr(stderr *bufio.Scanner) {
+	for stderr.Scan() {
+		msg := strings.TrimSpace(stderr.Text())
 		if msg == "" {
 			continue
 		}
@@ -1,8 +1,15 @@
 package host
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestWaitUnlockCommandUsesTimeout(t *testing.T) {
@@ -159,3 +166,159 @@ func TestIsConnectionError(t *testing.T) {
 		})
 	}
 }
+
+// fakeS3270Status is the status line the fake s3270 reports: unlocked,
+// formatted and connected, with a 2x2 screen.
+const fakeS3270Status = "U F U C(fake) I 4 2 2 0 0 0x0 0.000"
+
+// TestMain lets the test binary stand in for s3270 when FAKE_S3270 is set.
+func TestMain(m *testing.M) {
+	if os.Getenv("FAKE_S3270") != "" {
+		runFakeS3270()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeS3270 answers s3270 script commands on stdin. FAKE_S3270_LOG
+// collects the commands received, FAKE_S3270_LOCKED is how many screen reads
+// find the keyboard locked, FAKE_S3270_REJECT fails actions containing it,
+// and FAKE_S3270_EXIT_ON exits without replying to that command once, using
+// the FAKE_S3270_MARKER file to remember it did.
+func runFakeS3270() {
+	var logFile *os.File
+	if path := os.Getenv("FAKE_S3270_LOG"); path != "" {
+		logFile, _ = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	}
+	locked, _ := strconv.Atoi(os.Getenv("FAKE_S3270_LOCKED"))
+	reject := os.Getenv("FAKE_S3270_REJECT")
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		cmd := in.Text()
+		if cmd == "quit" {
+			return
+		}
+		if logFile != nil {
+			fmt.Fprintln(logFile, cmd)
+		}
+		if cmd == os.Getenv("FAKE_S3270_EXIT_ON") {
+			marker := os.Getenv("FAKE_S3270_MARKER")
+			if _, err := os.Stat(marker); err != nil {
+				_ = os.WriteFile(marker, nil, 0600)
+				os.Exit(1)
+			}
+		}
+		switch {
+		case reject != "" && strings.Contains(cmd, reject):
+			fmt.Printf("data: Unknown action: %s\n%s\nerror\n", cmd, fakeS3270Status)
+		case cmd == "readbuffer ascii" && locked > 0:
+			locked--
+			fmt.Printf("data: Keyboard locked\n%s\nerror\n", fakeS3270Status)
+		case cmd == "readbuffer ascii":
+			fmt.Printf("data: 41 42\ndata: 43 44\n%s\nok\n", fakeS3270Status)
+		default:
+			fmt.Printf("%s\nok\n", fakeS3270Status)
+		}
+	}
+}
+
+// startFakeS3270 starts the fake with env and returns the host and a
+// function reading back the commands it received.
+func startFakeS3270(t *testing.T, env map[string]string) (*S3270, func() []string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "commands.log")
+	t.Setenv("FAKE_S3270", "1")
+	t.Setenv("FAKE_S3270_LOG", logPath)
+	t.Setenv("FAKE_S3270_MARKER", filepath.Join(dir, "exited"))
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewS3270(exe)
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { h.Stop() })
+	return h, func() []string {
+		data, _ := os.ReadFile(logPath)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestS3270SendKey(t *testing.T) {
+	h, commands := startFakeS3270(t, nil)
+	if err := h.SendKey("Enter"); err != nil {
+		t.Fatalf("SendKey(Enter) error: %v", err)
+	}
+	if got := strings.Join(commands(), "; "); got != "Enter" {
+		t.Fatalf("commands = %q, want Enter", got)
+	}
+}
+
+func TestS3270SendKeyReportsRejectedAction(t *testing.T) {
+	h, commands := startFakeS3270(t, map[string]string{"FAKE_S3270_REJECT": "PA"})
+	start := time.Now()
+	err := h.SendKey("PA(9)")
+	if err == nil || !strings.Contains(err.Error(), "Unknown action") {
+		t.Fatalf("SendKey(PA(9)) error = %v, want s3270's explanation", err)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Fatalf("rejected key took %s, want an answer without the command timeout", waited)
+	}
+	if got := commands(); len(got) != 2 || got[1] != "Key(PA9)" {
+		t.Fatalf("commands = %q, want the action and its Key() fallback", got)
+	}
+	if !h.IsConnected() {
+		t.Fatal("a rejected action should leave s3270 running")
+	}
+}
+
+func TestS3270WriteStringAt(t *testing.T) {
+	h, commands := startFakeS3270(t, nil)
+	if err := h.WriteStringAt(1, 0, "AB"); err != nil {
+		t.Fatalf("WriteStringAt() error: %v", err)
+	}
+	want := "movecursor(1, 0); key(0x41); key(0x42)"
+	if got := strings.Join(commands(), "; "); got != want {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestS3270RetriesAfterProcessExit(t *testing.T) {
+	h, commands := startFakeS3270(t, map[string]string{"FAKE_S3270_EXIT_ON": "key(0x41)"})
+	if err := h.WriteStringAt(0, 0, "A"); err != nil {
+		t.Fatalf("WriteStringAt() error: %v", err)
+	}
+	want := "movecursor(0, 0); key(0x41); movecursor(0, 0); key(0x41)"
+	if got := strings.Join(commands(), "; "); got != want {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestS3270UpdateScreenWaitsForUnlock(t *testing.T) {
+	h, commands := startFakeS3270(t, map[string]string{"FAKE_S3270_LOCKED": "2"})
+	if err := h.UpdateScreen(); err != nil {
+		t.Fatalf("UpdateScreen() error: %v", err)
+	}
+	if got := len(commands()); got != 3 {
+		t.Fatalf("read the buffer %d times, want 3", got)
+	}
+	if got := string(h.GetScreen().Buffer[1]); got != "CD" {
+		t.Fatalf("row 2 = %q, want CD", got)
+	}
+}
+
+func TestS3270TransferReportsFailure(t *testing.T) {
+	h, _ := startFakeS3270(t, map[string]string{"FAKE_S3270_REJECT": "Transfer("})
+	message, err := h.Transfer(TransferOptions{Direction: TransferReceive, LocalFile: "/tmp/out", HostFile: "PROFILE EXEC A"})
+	if err == nil || !strings.HasPrefix(message, "Unknown action: Transfer(") {
+		t.Fatalf("Transfer() = %q, %v, want s3270's failure message", message, err)
+	}
+	if err := h.MoveCursor(0, 0); err != nil {
+		t.Fatalf("MoveCursor after a failed transfer = %v", err)
+	}
+}
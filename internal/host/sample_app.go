@@ -6,16 +6,19 @@ import (
 	"github.com/jnnngs/3270Web/internal/sampleapps"
 )
 
-// GoSampleAppHost runs a Go-based sample application and connects using s3270.
+// GoSampleAppHost runs a Go-based sample application and connects using s3270,
+// or the native TN3270 client when Native is set.
 type GoSampleAppHost struct {
-	AppID    string
-	Port     int
-	ExecPath string
-	Args     []string
-	Target   string
+	AppID         string
+	Port          int
+	ExecPath      string
+	Args          []string
+	Target        string
+	Native        bool
+	NativeOptions TN3270Options
 
 	server         *sampleapps.Server
-	client         *S3270
+	client         Host
 	verboseLogging bool
 }
 
@@ -43,6 +46,39 @@ func NewGoSampleAppHost(appID string, port int, execPath string, args []string,
 	}, nil
 }
 
+// NewGoSampleAppNativeHost creates a sample app host that connects with the
+// native TN3270 client instead of s3270.
+func NewGoSampleAppNativeHost(appID string, port int, target string, opts TN3270Options) (*GoSampleAppHost, error) {
+	if appID == "" {
+		return nil, fmt.Errorf("missing sample app id")
+	}
+	if port <= 0 {
+		return nil, fmt.Errorf("invalid sample app port %d", port)
+	}
+	if target == "" {
+		return nil, fmt.Errorf("missing sample app target host")
+	}
+	return &GoSampleAppHost{
+		AppID:         appID,
+		Port:          port,
+		Target:        target,
+		Native:        true,
+		NativeOptions: opts,
+	}, nil
+}
+
+func (h *GoSampleAppHost) newClient() Host {
+	if h.Native {
+		client := NewTN3270(h.Target, h.NativeOptions)
+		client.SetVerboseLogging(h.verboseLogging)
+		return client
+	}
+	client := NewS3270(h.ExecPath, h.Args...)
+	client.TargetHost = h.Target
+	client.SetVerboseLogging(h.verboseLogging)
+	return client
+}
+
 func (h *GoSampleAppHost) Start() error {
 	if h.server == nil {
 		server, err := sampleapps.StartServer(h.AppID, h.Port)
@@ -51,9 +87,7 @@ func (h *GoSampleAppHost) Start() error {
 		}
 		h.server = server
 	}
-	h.client = NewS3270(h.ExecPath, h.Args...)
-	h.client.TargetHost = h.Target
-	h.client.SetVerboseLogging(h.verboseLogging)
+	h.client = h.newClient()
 	if err := h.client.Start(); err != nil {
 		h.server.Stop()
 		h.server = nil
@@ -140,15 +174,33 @@ func (h *GoSampleAppHost) SubmitUnformatted(data string) error {
 // SetVerboseLogging enables or disables verbose logging for the underlying client.
 func (h *GoSampleAppHost) SetVerboseLogging(enabled bool) {
 	h.verboseLogging = enabled
-	if h.client != nil {
-		h.client.SetVerboseLogging(enabled)
+	if logger, ok := h.client.(interface{ SetVerboseLogging(bool) }); ok {
+		logger.SetVerboseLogging(enabled)
 	}
 }
 
 // GetVerboseLogging returns the current verbose logging setting.
 func (h *GoSampleAppHost) GetVerboseLogging() bool {
-	if h.client != nil {
-		return h.client.GetVerboseLogging()
+	if logger, ok := h.client.(interface{ GetVerboseLogging() bool }); ok {
+		return logger.GetVerboseLogging()
 	}
 	return h.verboseLogging
 }
+
+// ScreenChanged forwards the client's change notifications. It returns nil,
+// so callers poll, when the client cannot notify.
+func (h *GoSampleAppHost) ScreenChanged() <-chan struct{} {
+	if notifier, ok := h.client.(ScreenNotifier); ok {
+		return notifier.ScreenChanged()
+	}
+	return nil
+}
+
+// PrinterEndpoint forwards the client's endpoint so printer sessions can
+// associate with the sample app terminal.
+func (h *GoSampleAppHost) PrinterEndpoint() (string, string) {
+	if endpoint, ok := h.client.(PrinterEndpoint); ok {
+		return endpoint.PrinterEndpoint()
+	}
+	return h.Target, ""
+}
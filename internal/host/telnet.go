@@ -0,0 +1,305 @@
+package host
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Telnet commands and options used by tn3270 and tn3270e.
+const (
+	telnetSE   = 240
+	telnetIP   = 244
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+	telnetEOR  = 239
+
+	telnetOptBinary  = 0
+	telnetOptTType   = 24
+	telnetOptEOR     = 25
+	telnetOptTN3270E = 40
+
+	ttypeIs   = 0
+	ttypeSend = 1
+)
+
+// TN3270E sub-negotiation codes (RFC 2355).
+const (
+	tn3270eAssociate  = 0
+	tn3270eConnect    = 1
+	tn3270eDeviceType = 2
+	tn3270eFunctions  = 3
+	tn3270eIs         = 4
+	tn3270eReason     = 5
+	tn3270eReject     = 6
+	tn3270eRequest    = 7
+	tn3270eSend       = 8
+
+	tn3270eData3270 = 0x00
+)
+
+// tnHandler receives what a tnConn reads that only its owner understands.
+// Handlers are called without mu held.
+type tnHandler interface {
+	// handleRecord receives each record, TN3270E header included.
+	handleRecord(record []byte)
+	// handleTN3270E receives TN3270E sub-negotiation after the option byte.
+	handleTN3270E(sub []byte)
+	// connectionClosed runs once conn stops delivering data.
+	connectionClosed(conn net.Conn, err error)
+}
+
+// tnConn is the telnet layer shared by the terminal and printer sessions. It
+// reads records, answers option negotiation and the terminal type, and
+// frames what the owner sends. Its mu also guards the owner's own state, so
+// waitFor conditions can use both.
+type tnConn struct {
+	mu         sync.Mutex // Protects everything below and the owner's state
+	conn       net.Conn
+	connected  bool
+	tn3270e    bool
+	localOpts  [256]bool
+	remoteOpts [256]bool
+	changed    chan struct{}
+
+	writeMu  sync.Mutex // Serializes writes to conn
+	handler  tnHandler
+	label    string // Names the session in write errors
+	termType string // Sent in reply to TTYPE SEND
+}
+
+// init prepares t for an owner that embeds it.
+func (t *tnConn) init(handler tnHandler, label, termType string) {
+	t.changed = make(chan struct{})
+	t.handler = handler
+	t.label = label
+	t.termType = termType
+}
+
+// dialTN3270 opens the TCP (or, with useTLS, TLS) connection for a session.
+// A zero timeout uses tn3270DialTimeout.
+func dialTN3270(addr string, useTLS bool, timeout time.Duration) (net.Conn, error) {
+	if timeout <= 0 {
+		timeout = tn3270DialTimeout
+	}
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, &tls.Config{ServerName: host})
+	}
+	return net.DialTimeout("tcp", addr, timeout)
+}
+
+// attachLocked starts negotiation afresh on conn.
+func (t *tnConn) attachLocked(conn net.Conn) {
+	t.conn = conn
+	t.connected = true
+	t.tn3270e = false
+	t.localOpts = [256]bool{}
+	t.remoteOpts = [256]bool{}
+}
+
+// waitFor blocks until cond (evaluated under mu) holds or timeout elapses.
+// It reports whether cond held.
+func (t *tnConn) waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		t.mu.Lock()
+		ok := cond()
+		changed := t.changed
+		t.mu.Unlock()
+		if ok {
+			return true
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		select {
+		case <-changed:
+		case <-time.After(remaining):
+		}
+	}
+}
+
+func (t *tnConn) notifyLocked() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// readLoop reads conn until it fails, then tells the handler.
+func (t *tnConn) readLoop(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	var record []byte
+	err := func() error {
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if b != telnetIAC {
+				record = append(record, b)
+				continue
+			}
+			cmd, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			switch cmd {
+			case telnetIAC:
+				record = append(record, telnetIAC)
+			case telnetEOR:
+				t.handler.handleRecord(record)
+				record = record[:0]
+			case telnetDO, telnetDONT, telnetWILL, telnetWONT:
+				opt, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				t.handleOption(cmd, opt)
+			case telnetSB:
+				sub, err := readSubnegotiation(r)
+				if err != nil {
+					return err
+				}
+				t.handleSubnegotiation(sub)
+			}
+		}
+	}()
+	t.handler.connectionClosed(conn, err)
+}
+
+func readSubnegotiation(r *bufio.Reader) ([]byte, error) {
+	var sub []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != telnetIAC {
+			sub = append(sub, b)
+			continue
+		}
+		next, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if next == telnetSE {
+			return sub, nil
+		}
+		sub = append(sub, next)
+	}
+}
+
+func tn3270SupportsLocal(opt byte) bool {
+	switch opt {
+	case telnetOptBinary, telnetOptEOR, telnetOptTType, telnetOptTN3270E:
+		return true
+	default:
+		return false
+	}
+}
+
+func tn3270SupportsRemote(opt byte) bool {
+	return opt == telnetOptBinary || opt == telnetOptEOR
+}
+
+// handleOption answers DO/DONT/WILL/WONT, replying only on state changes so
+// negotiation cannot loop.
+func (t *tnConn) handleOption(cmd, opt byte) {
+	var reply []byte
+	t.mu.Lock()
+	switch cmd {
+	case telnetDO:
+		if !tn3270SupportsLocal(opt) {
+			reply = []byte{telnetIAC, telnetWONT, opt}
+		} else if !t.localOpts[opt] {
+			t.localOpts[opt] = true
+			reply = []byte{telnetIAC, telnetWILL, opt}
+		}
+	case telnetDONT:
+		if t.localOpts[opt] {
+			t.localOpts[opt] = false
+			reply = []byte{telnetIAC, telnetWONT, opt}
+		}
+		if opt == telnetOptTN3270E {
+			t.tn3270e = false
+		}
+	case telnetWILL:
+		if !tn3270SupportsRemote(opt) {
+			reply = []byte{telnetIAC, telnetDONT, opt}
+		} else if !t.remoteOpts[opt] {
+			t.remoteOpts[opt] = true
+			reply = []byte{telnetIAC, telnetDO, opt}
+		}
+	case telnetWONT:
+		if t.remoteOpts[opt] {
+			t.remoteOpts[opt] = false
+			reply = []byte{telnetIAC, telnetDONT, opt}
+		}
+	}
+	t.mu.Unlock()
+	if reply != nil {
+		_ = t.send(reply)
+	}
+}
+
+func (t *tnConn) handleSubnegotiation(sub []byte) {
+	if len(sub) < 2 {
+		return
+	}
+	switch sub[0] {
+	case telnetOptTType:
+		if sub[1] == ttypeSend {
+			t.sendSubnegotiation(telnetOptTType, append([]byte{ttypeIs}, t.termType...))
+		}
+	case telnetOptTN3270E:
+		t.handler.handleTN3270E(sub[1:])
+	}
+}
+
+// sendRecord frames data, TN3270E header included, as a telnet record.
+func (t *tnConn) sendRecord(data []byte) error {
+	out := make([]byte, 0, len(data)+8)
+	for _, b := range data {
+		out = append(out, b)
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	out = append(out, telnetIAC, telnetEOR)
+	return t.send(out)
+}
+
+func (t *tnConn) sendSubnegotiation(opt byte, payload []byte) {
+	out := []byte{telnetIAC, telnetSB, opt}
+	for _, b := range payload {
+		out = append(out, b)
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	out = append(out, telnetIAC, telnetSE)
+	_ = t.send(out)
+}
+
+func (t *tnConn) send(data []byte) error {
+	t.mu.Lock()
+	conn := t.conn
+	t.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("%s write failed: %w", t.label, err)
+	}
+	return nil
+}
@@ -0,0 +1,767 @@
+package host
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racingmars/go3270"
+)
+
+const (
+	tn3270DefaultPort   = "23"
+	tn3270DialTimeout   = 15 * time.Second
+	tn3270StartTimeout  = 5 * time.Second
+	tn3270UnlockTimeout = waitUnlockTimeoutSeconds * time.Second
+	tn3270UpdateWait    = time.Second
+)
+
+// TN3270Options configures the native TN3270 client.
+type TN3270Options struct {
+	// Model is the terminal model, e.g. "3279-2-E". Defaults to 3278-2.
+	Model string
+	// CodePage is the host EBCDIC code page using x3270 names, e.g.
+	// "bracket", "cp037" or "1047". Defaults to bracket.
+	CodePage string
+	// LU is an optional logical unit name requested during TN3270E
+	// negotiation.
+	LU string
+	// ConnectTimeout bounds the TCP dial. Defaults to 15 seconds.
+	ConnectTimeout time.Duration
+}
+
+// TN3270 implements the Host interface by speaking tn3270/tn3270e directly
+// over TCP, without an s3270 subprocess.
+type TN3270 struct {
+	TargetHost string
+	Options    TN3270Options
+
+	tnConn         // Guards the fields below with its mu
+	started        bool
+	locked         bool
+	luName         string
+	seq            uint16
+	buf            *tnBuffer
+	screen         *Screen
+	verboseLogging bool
+
+	codepage go3270.Codepage
+	model    string
+}
+
+// NewTN3270 creates a native TN3270 host for target ("host" or "host:port").
+func NewTN3270(target string, opts TN3270Options) *TN3270 {
+	model := normalizeTN3270Model(opts.Model)
+	rows, cols, _ := getModelDimensions(model)
+	h := &TN3270{
+		TargetHost: target,
+		Options:    opts,
+		buf:        newTNBuffer(rows, cols),
+		screen:     &Screen{},
+		codepage:   tn3270Codepage(opts.CodePage),
+		model:      model,
+	}
+	h.tnConn.init(h, "tn3270", "IBM-"+model)
+	return h
+}
+
+// normalizeTN3270Model returns a model in the "3279-2-E" form used for the
+// terminal type, falling back to 3278-2 when the model is unknown.
+func normalizeTN3270Model(model string) string {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return "3278-2"
+	}
+	if _, _, ok := getModelDimensions(model); !ok {
+		return "3278-2"
+	}
+	if !strings.Contains(model, "-") {
+		return "3278-" + model
+	}
+	return model
+}
+
+var tn3270Codepages = map[string]func() go3270.Codepage{
+	"bracket": go3270.CodepageBracket,
+	"037":     go3270.Codepage037,
+	"273":     go3270.Codepage273,
+	"275":     go3270.Codepage275,
+	"277":     go3270.Codepage277,
+	"278":     go3270.Codepage278,
+	"280":     go3270.Codepage280,
+	"284":     go3270.Codepage284,
+	"285":     go3270.Codepage285,
+	"297":     go3270.Codepage297,
+	"424":     go3270.Codepage424,
+	"500":     go3270.Codepage500,
+	"803":     go3270.Codepage803,
+	"870":     go3270.Codepage870,
+	"871":     go3270.Codepage871,
+	"875":     go3270.Codepage875,
+	"880":     go3270.Codepage880,
+	"924":     go3270.Codepage924,
+	"1026":    go3270.Codepage1026,
+	"1047":    go3270.Codepage1047,
+	"1140":    go3270.Codepage1140,
+	"1141":    go3270.Codepage1141,
+	"1142":    go3270.Codepage1142,
+	"1143":    go3270.Codepage1143,
+	"1144":    go3270.Codepage1144,
+	"1145":    go3270.Codepage1145,
+	"1146":    go3270.Codepage1146,
+	"1147":    go3270.Codepage1147,
+	"1148":    go3270.Codepage1148,
+	"1149":    go3270.Codepage1149,
+	"1160":    go3270.Codepage1160,
+}
+
+// tn3270Codepage resolves an x3270 code page name such as "cp037", "37" or
+// "bracket". Unknown names fall back to bracket, matching s3270.
+func tn3270Codepage(name string) go3270.Codepage {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.TrimPrefix(key, "cp")
+	if n, err := strconv.Atoi(key); err == nil {
+		key = fmt.Sprintf("%03d", n)
+	}
+	if fn, ok := tn3270Codepages[key]; ok {
+		return fn()
+	}
+	return go3270.CodepageBracket()
+}
+
+func (h *TN3270) address() string {
+	target := strings.TrimSpace(h.TargetHost)
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	if strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]") {
+		target = target[1 : len(target)-1]
+	}
+	return net.JoinHostPort(target, tn3270DefaultPort)
+}
+
+func (h *TN3270) Start() error {
+	if strings.TrimSpace(h.TargetHost) == "" {
+		return fmt.Errorf("target host not set")
+	}
+	_ = h.Stop()
+
+	conn, err := dialTN3270(h.address(), false, h.Options.ConnectTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", h.TargetHost, err)
+	}
+
+	h.mu.Lock()
+	h.attachLocked(conn)
+	h.started = true
+	h.locked = true
+	h.luName = ""
+	h.seq = 0
+	h.buf = newTNBuffer(h.buf.altRows, h.buf.altCols)
+	h.mu.Unlock()
+
+	go h.readLoop(conn)
+
+	// Wait for a formatted, unlocked screen like s3270, but keep it bounded.
+	h.waitFor(tn3270StartTimeout, func() bool {
+		return !h.connected || (!h.locked && h.buf.isFormatted())
+	})
+
+	h.mu.Lock()
+	connected := h.connected
+	h.mu.Unlock()
+	if !connected {
+		return fmt.Errorf("connection to %s closed during negotiation", h.TargetHost)
+	}
+	return h.UpdateScreen()
+}
+
+func (h *TN3270) Stop() error {
+	h.mu.Lock()
+	conn := h.conn
+	h.conn = nil
+	h.started = false
+	h.connected = false
+	h.notifyLocked()
+	h.mu.Unlock()
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+func (h *TN3270) IsConnected() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.started
+}
+
+// ensureConnected reconnects when the host has dropped the session, the way
+// s3270 reconnects on a disconnected status.
+func (h *TN3270) ensureConnected() error {
+	h.mu.Lock()
+	connected := h.connected
+	h.mu.Unlock()
+	if connected {
+		return nil
+	}
+	return h.Start()
+}
+
+func (h *TN3270) UpdateScreen() error {
+	if err := h.ensureConnected(); err != nil {
+		return err
+	}
+	// Give a host that is still writing a moment to unlock the keyboard so
+	// the snapshot isn't taken mid-update.
+	h.waitFor(tn3270UpdateWait, func() bool {
+		return !h.connected || !h.locked
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.screen.Update(h.statusLocked(), h.bufferLinesLocked())
+}
+
+func (h *TN3270) GetScreen() *Screen {
+	return h.screen
+}
+
+// ScreenChanged implements ScreenNotifier. The channel closes when a record
+// arrives from the host, input changes the buffer or the connection drops.
+func (h *TN3270) ScreenChanged() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.changed
+}
+
+func (h *TN3270) SendKey(key string) error {
+	if strings.ContainsAny(key, "\n\r\t;") {
+		return fmt.Errorf("security error: invalid characters in key command")
+	}
+	if err := h.ensureConnected(); err != nil {
+		return err
+	}
+	spec := keyToKeySpec(key)
+
+	if aid, ok := tn3270AIDForKey(spec); ok {
+		return h.sendAID(aid)
+	}
+	if strings.EqualFold(spec, "Attn") {
+		return h.send([]byte{telnetIAC, telnetIP})
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.verboseLogging {
+		log.Printf("[VERBOSE] tn3270 key: %q", spec)
+	}
+	b := h.buf
+	switch strings.ToLower(spec) {
+	case "reset":
+		h.locked = false
+		b.insert = false
+		return nil
+	}
+	if h.locked {
+		return fmt.Errorf("keyboard locked")
+	}
+	switch strings.ToLower(spec) {
+	case "tab":
+		b.tab()
+	case "backtab":
+		b.backTab()
+	case "home":
+		b.home()
+	case "newline":
+		b.newline()
+	case "up":
+		b.moveCursor(-b.cols)
+	case "down":
+		b.moveCursor(b.cols)
+	case "left", "backspace":
+		b.moveCursor(-1)
+	case "right":
+		b.moveCursor(1)
+	case "eraseeof":
+		return b.eraseEOF()
+	case "eraseinput":
+		b.eraseInput()
+	case "delete":
+		return b.deleteChar()
+	case "insert":
+		b.insert = !b.insert
+	case "dup":
+		if err := b.typeChar(0x1C); err != nil {
+			return err
+		}
+		b.tab()
+	case "fieldmark":
+		return b.typeChar(0x1E)
+	default:
+		return fmt.Errorf("unsupported key %q", key)
+	}
+	return nil
+}
+
+// tn3270AIDForKey maps a key spec (see keyToKeySpec) to its AID byte.
+func tn3270AIDForKey(spec string) (byte, bool) {
+	upper := strings.ToUpper(strings.TrimSpace(spec))
+	switch upper {
+	case "ENTER":
+		return aidEnter, true
+	case "CLEAR":
+		return aidClear, true
+	case "SYSREQ":
+		return aidSysReq, true
+	case "PA1":
+		return aidPA1, true
+	case "PA2":
+		return aidPA2, true
+	case "PA3":
+		return aidPA3, true
+	}
+	if strings.HasPrefix(upper, "PF") {
+		if n, err := strconv.Atoi(strings.TrimPrefix(upper, "PF")); err == nil && n >= 1 && n <= len(aidPF) {
+			return aidPF[n-1], true
+		}
+	}
+	return 0, false
+}
+
+func (h *TN3270) sendAID(aid byte) error {
+	h.mu.Lock()
+	if h.locked {
+		h.mu.Unlock()
+		return fmt.Errorf("keyboard locked")
+	}
+	if aid == aidClear {
+		h.buf.resize(h.buf.defaultRows, h.buf.defaultCols)
+	}
+	data := h.buf.readModified(aid, false)
+	h.locked = true
+	if h.verboseLogging {
+		log.Printf("[VERBOSE] tn3270 aid: 0x%02x (%d bytes)", aid, len(data))
+	}
+	h.mu.Unlock()
+
+	if err := h.sendRecord(data); err != nil {
+		return err
+	}
+	if !h.waitFor(tn3270UnlockTimeout, func() bool { return !h.connected || !h.locked }) {
+		return fmt.Errorf("keyboard locked timeout")
+	}
+	return nil
+}
+
+func (h *TN3270) MoveCursor(row, col int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	defer h.notifyLocked()
+	return h.moveCursorLocked(row, col)
+}
+
+func (h *TN3270) moveCursorLocked(row, col int) error {
+	b := h.buf
+	if row < 0 || col < 0 || row >= b.rows || col >= b.cols {
+		return fmt.Errorf("cursor position %d,%d outside %dx%d screen", row, col, b.rows, b.cols)
+	}
+	b.cursor = row*b.cols + col
+	return nil
+}
+
+func (h *TN3270) WriteStringAt(row, col int, text string) error {
+	if text == "" {
+		return nil
+	}
+	if err := h.ensureConnected(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.locked {
+		return fmt.Errorf("keyboard locked")
+	}
+	defer h.notifyLocked()
+	if err := h.moveCursorLocked(row, col); err != nil {
+		return err
+	}
+	return h.typeStringLocked(text)
+}
+
+func (h *TN3270) typeStringLocked(text string) error {
+	for _, r := range text {
+		if r == '\n' {
+			h.buf.newline()
+			continue
+		}
+		encoded := h.codepage.Encode(string(r))
+		if len(encoded) == 0 {
+			continue
+		}
+		if err := h.buf.typeChar(encoded[len(encoded)-1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *TN3270) SubmitScreen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.locked {
+		return fmt.Errorf("keyboard locked")
+	}
+	defer h.notifyLocked()
+
+	for _, f := range h.screen.Fields {
+		if f.IsProtected() || !f.Changed {
+			continue
+		}
+		if err := h.moveCursorLocked(f.StartY, f.StartX); err != nil {
+			return err
+		}
+		if err := h.buf.eraseEOF(); err != nil {
+			return err
+		}
+		if err := h.typeStringLocked(f.Value); err != nil {
+			return err
+		}
+		f.Changed = false
+	}
+	return nil
+}
+
+func (h *TN3270) SubmitUnformatted(data string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.locked {
+		return fmt.Errorf("keyboard locked")
+	}
+
+	if h.screen == nil {
+		return fmt.Errorf("screen not initialized")
+	}
+	defer h.notifyLocked()
+	index := 0
+	runes := []rune(data)
+	for y := 0; y < h.screen.Height && index < len(runes); y++ {
+		for x := 0; x < h.screen.Width && index < len(runes); x++ {
+			newCh := runes[index]
+			if newCh != h.screen.CharAt(x, y) {
+				if err := h.moveCursorLocked(y, x); err != nil {
+					return err
+				}
+				if newCh != 0 {
+					if err := h.typeStringLocked(string(newCh)); err != nil {
+						return err
+					}
+				}
+			}
+			index++
+		}
+		index++ // skip newline
+	}
+	return nil
+}
+
+// SetVerboseLogging enables or disables verbose logging of data stream activity.
+func (h *TN3270) SetVerboseLogging(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.verboseLogging = enabled
+}
+
+// GetVerboseLogging returns whether verbose logging is enabled.
+func (h *TN3270) GetVerboseLogging() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.verboseLogging
+}
+
+// PrinterEndpoint returns the host address and the LU assigned during
+// TN3270E negotiation, for pairing a printer session with this terminal.
+func (h *TN3270) PrinterEndpoint() (string, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.address(), h.luName
+}
+
+// statusLocked synthesizes an s3270-compatible status line so the status
+// accessors and screen parser behave the same for both host types.
+func (h *TN3270) statusLocked() string {
+	b := h.buf
+	keyboard := "U"
+	if h.locked {
+		keyboard = "L"
+	}
+	formatted := "U"
+	if b.isFormatted() {
+		formatted = "F"
+	}
+	protection := "U"
+	if b.isProtected(b.cursor) {
+		protection = "P"
+	}
+	connection := "N"
+	mode := "N"
+	if h.connected {
+		connection = fmt.Sprintf("C(%s)", h.TargetHost)
+		mode = "I"
+	}
+	modelNum := "2"
+	if parts := strings.Split(h.model, "-"); len(parts) >= 2 {
+		modelNum = parts[1]
+	}
+	return fmt.Sprintf("%s %s %s %s %s %s %d %d %d %d 0x0 -",
+		keyboard, formatted, protection, connection, mode, modelNum,
+		b.rows, b.cols, b.cursor/b.cols, b.cursor%b.cols)
+}
+
+// bufferLinesLocked renders the presentation space in the s3270
+// "readbuffer ascii" format understood by Screen.Update.
+func (h *TN3270) bufferLinesLocked() []string {
+	b := h.buf
+	lines := make([]string, 0, b.rows)
+	var sb strings.Builder
+	for y := 0; y < b.rows; y++ {
+		sb.Reset()
+		sb.WriteString("data:")
+		for x := 0; x < b.cols; x++ {
+			c := b.cells[y*b.cols+x]
+			sb.WriteByte(' ')
+			if c.fa {
+				fmt.Fprintf(&sb, "SF(c0=%02x", c.attr)
+				if c.hl != 0 {
+					fmt.Fprintf(&sb, ",41=%02x", c.hl)
+				}
+				if c.fg != 0 {
+					fmt.Fprintf(&sb, ",42=%02x", c.fg)
+				}
+				sb.WriteByte(')')
+				continue
+			}
+			fmt.Fprintf(&sb, "%02x", h.displayByte(c.ch))
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+// displayByte converts an EBCDIC character to the single-byte form used in
+// buffer lines. Nulls stay null; anything unprintable becomes a space.
+func (h *TN3270) displayByte(ch byte) byte {
+	if ch == 0 {
+		return 0
+	}
+	decoded := []rune(h.codepage.Decode([]byte{ch}))
+	if len(decoded) != 1 {
+		return ' '
+	}
+	r := decoded[0]
+	if r < 0x20 || (r >= 0x7F && r < 0xA0) || r > 0xFF {
+		return ' '
+	}
+	return byte(r)
+}
+
+// connectionClosed marks the session disconnected when conn was the
+// current connection, so the next command reconnects.
+func (h *TN3270) connectionClosed(conn net.Conn, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn == conn {
+		h.connected = false
+		if h.verboseLogging {
+			log.Printf("[VERBOSE] tn3270 connection closed: %v", err)
+		}
+	}
+	h.notifyLocked()
+}
+
+// handleTN3270E negotiates the device type and (empty) function list.
+func (h *TN3270) handleTN3270E(sub []byte) {
+	switch {
+	case len(sub) >= 2 && sub[0] == tn3270eSend && sub[1] == tn3270eDeviceType:
+		req := []byte{tn3270eDeviceType, tn3270eRequest}
+		req = append(req, h.tn3270eDeviceType()...)
+		if lu := strings.TrimSpace(h.Options.LU); lu != "" {
+			req = append(req, tn3270eConnect)
+			req = append(req, lu...)
+		}
+		h.sendSubnegotiation(telnetOptTN3270E, req)
+	case len(sub) >= 2 && sub[0] == tn3270eDeviceType && sub[1] == tn3270eIs:
+		for i := 2; i < len(sub); i++ {
+			if sub[i] == tn3270eConnect {
+				h.mu.Lock()
+				h.luName = string(sub[i+1:])
+				h.mu.Unlock()
+				break
+			}
+		}
+		h.sendSubnegotiation(telnetOptTN3270E, []byte{tn3270eFunctions, tn3270eRequest})
+	case len(sub) >= 2 && sub[0] == tn3270eDeviceType && sub[1] == tn3270eReject:
+		h.mu.Lock()
+		h.localOpts[telnetOptTN3270E] = false
+		h.mu.Unlock()
+		_ = h.send([]byte{telnetIAC, telnetWONT, telnetOptTN3270E})
+	case len(sub) >= 2 && sub[0] == tn3270eFunctions && sub[1] == tn3270eRequest:
+		// We support none of the optional functions; agree on the empty set.
+		h.sendSubnegotiation(telnetOptTN3270E, []byte{tn3270eFunctions, tn3270eIs})
+	case len(sub) >= 2 && sub[0] == tn3270eFunctions && sub[1] == tn3270eIs:
+		h.mu.Lock()
+		h.tn3270e = true
+		h.mu.Unlock()
+	}
+}
+
+// tn3270eDeviceType returns the terminal type in the form TN3270E requires.
+func (h *TN3270) tn3270eDeviceType() string {
+	if strings.HasSuffix(h.termType, "-E") {
+		return h.termType
+	}
+	return h.termType + "-E"
+}
+
+func (h *TN3270) handleRecord(record []byte) {
+	h.mu.Lock()
+	if h.tn3270e {
+		if len(record) < 5 || record[0] != tn3270eData3270 {
+			h.mu.Unlock()
+			return
+		}
+		record = record[5:]
+	}
+	if len(record) == 0 {
+		h.mu.Unlock()
+		return
+	}
+	if h.verboseLogging {
+		log.Printf("[VERBOSE] tn3270 record: command=0x%02x length=%d", record[0], len(record))
+	}
+	reply, err := h.processCommandLocked(record[0], record[1:])
+	if err != nil {
+		log.Printf("tn3270: %v", err)
+	}
+	h.notifyLocked()
+	h.mu.Unlock()
+
+	if reply != nil {
+		_ = h.sendRecord(reply)
+	}
+}
+
+// processCommandLocked applies an outbound 3270 command and returns any
+// inbound reply the host expects.
+func (h *TN3270) processCommandLocked(cmd byte, data []byte) ([]byte, error) {
+	b := h.buf
+	switch cmd {
+	case cmdWrite, cmdWriteSNA, cmdEraseWrite, cmdEraseWriteSNA, cmdEraseWriteAlternate, cmdEraseWriteAlternateSNA:
+		wcc, err := b.write(cmd, data)
+		if wcc&wccKeyboardRestore != 0 {
+			h.locked = false
+		}
+		return nil, err
+	case cmdEraseAllUnprotected, cmdEraseAllUnprotectedSNA:
+		b.eraseAllUnprotected()
+		h.locked = false
+		return nil, nil
+	case cmdReadBuffer, cmdReadBufferSNA:
+		return b.readBuffer(aidNone), nil
+	case cmdReadModified, cmdReadModifiedSNA:
+		return b.readModified(aidNone, false), nil
+	case cmdReadModifiedAll, cmdReadModifiedAllSNA:
+		return b.readModified(aidNone, true), nil
+	case cmdWriteStructuredField, cmdWriteStructuredFieldSNA:
+		return h.processStructuredFieldsLocked(data)
+	default:
+		return nil, fmt.Errorf("unsupported 3270 command 0x%02x", cmd)
+	}
+}
+
+// processStructuredFieldsLocked handles Read Partition queries and outbound
+// 3270DS fields. Other structured fields are ignored.
+func (h *TN3270) processStructuredFieldsLocked(data []byte) ([]byte, error) {
+	var reply []byte
+	for len(data) >= 3 {
+		length := int(data[0])<<8 | int(data[1])
+		if length == 0 || length > len(data) {
+			length = len(data)
+		}
+		if length < 3 {
+			return reply, fmt.Errorf("invalid structured field length %d", length)
+		}
+		field := data[2:length]
+		data = data[length:]
+
+		switch field[0] {
+		case 0x01: // Read Partition
+			if len(field) >= 3 && (field[2] == 0x02 || field[2] == 0x03) {
+				reply = h.queryReplyLocked()
+			}
+		case 0x03: // Erase/Reset
+			alt := len(field) >= 2 && field[1]&0x80 != 0
+			if alt {
+				h.buf.resize(h.buf.altRows, h.buf.altCols)
+			} else {
+				h.buf.resize(h.buf.defaultRows, h.buf.defaultCols)
+			}
+		case 0x40: // Outbound 3270DS
+			if len(field) >= 3 {
+				if _, err := h.processCommandLocked(field[2], field[3:]); err != nil {
+					return reply, err
+				}
+			}
+		}
+	}
+	return reply, nil
+}
+
+// queryReplyLocked builds the Query Reply describing this terminal: summary,
+// usable area, color, highlighting and implicit partition.
+func (h *TN3270) queryReplyLocked() []byte {
+	b := h.buf
+	rows, cols := b.altRows, b.altCols
+	size := rows * cols
+	u16 := func(v int) []byte { return []byte{byte(v >> 8), byte(v)} }
+	sf := func(body ...[]byte) []byte {
+		var out []byte
+		for _, part := range body {
+			out = append(out, part...)
+		}
+		return append(u16(len(out)+2), out...)
+	}
+
+	reply := []byte{aidStructuredField}
+	reply = append(reply, sf([]byte{0x81, 0x80, 0x80, 0x81, 0x86, 0x87, 0xA6})...)
+	reply = append(reply, sf(
+		[]byte{0x81, 0x81, 0x01, 0x00}, u16(cols), u16(rows),
+		[]byte{0x00, 0x00, 0x0A, 0x02, 0xE5, 0x00, 0x02, 0x00, 0x6F, 0x09, 0x0C}, u16(size),
+	)...)
+	reply = append(reply, sf([]byte{0x81, 0x86, 0x00, 0x08,
+		0x00, 0xF4, 0xF1, 0xF1, 0xF2, 0xF2, 0xF3, 0xF3,
+		0xF4, 0xF4, 0xF5, 0xF5, 0xF6, 0xF6, 0xF7, 0xF7})...)
+	reply = append(reply, sf([]byte{0x81, 0x87, 0x04,
+		0x00, 0xF0, 0xF1, 0xF1, 0xF2, 0xF2, 0xF4, 0xF4})...)
+	reply = append(reply, sf(
+		[]byte{0x81, 0xA6, 0x00, 0x00, 0x0B, 0x01, 0x00},
+		u16(b.defaultCols), u16(b.defaultRows), u16(cols), u16(rows),
+	)...)
+	return reply
+}
+
+// sendRecord frames 3270 data as a telnet record, adding the TN3270E header
+// when that mode is active.
+func (h *TN3270) sendRecord(data []byte) error {
+	h.mu.Lock()
+	var header []byte
+	if h.tn3270e {
+		header = []byte{tn3270eData3270, 0, 0, byte(h.seq >> 8), byte(h.seq)}
+		h.seq++
+	}
+	h.mu.Unlock()
+	return h.tnConn.sendRecord(append(header, data...))
+}
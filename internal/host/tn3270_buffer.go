@@ -0,0 +1,596 @@
+package host
+
+import (
+	"fmt"
+)
+
+// 3270 data stream commands. Hosts may send either the CCW (local) or the SNA
+// (remote) form, so both are recognized.
+const (
+	cmdWrite                   = 0xF1
+	cmdWriteSNA                = 0x01
+	cmdEraseWrite              = 0xF5
+	cmdEraseWriteSNA           = 0x05
+	cmdEraseWriteAlternate     = 0x7E
+	cmdEraseWriteAlternateSNA  = 0x0D
+	cmdReadBuffer              = 0xF2
+	cmdReadBufferSNA           = 0x02
+	cmdReadModified            = 0xF6
+	cmdReadModifiedSNA         = 0x06
+	cmdReadModifiedAll         = 0x6E
+	cmdReadModifiedAllSNA      = 0x0E
+	cmdEraseAllUnprotected     = 0x6F
+	cmdEraseAllUnprotectedSNA  = 0x0F
+	cmdWriteStructuredField    = 0xF3
+	cmdWriteStructuredFieldSNA = 0x11
+)
+
+// 3270 orders embedded in Write data.
+const (
+	orderSF  = 0x1D // Start Field
+	orderSFE = 0x29 // Start Field Extended
+	orderSBA = 0x11 // Set Buffer Address
+	orderSA  = 0x28 // Set Attribute
+	orderMF  = 0x2C // Modify Field
+	orderIC  = 0x13 // Insert Cursor
+	orderPT  = 0x05 // Program Tab
+	orderRA  = 0x3C // Repeat to Address
+	orderEUA = 0x12 // Erase Unprotected to Address
+	orderGE  = 0x08 // Graphic Escape
+)
+
+// Write Control Character bits.
+const (
+	wccResetMDT        = 0x01
+	wccKeyboardRestore = 0x02
+	wccStartPrinter    = 0x08
+	wccPrintLineLength = 0x30
+)
+
+// Extended attribute types used by SFE, SA and MF.
+const (
+	xaAll        = 0x00
+	xa3270       = 0xC0
+	xaHighlight  = 0x41
+	xaForeground = 0x42
+)
+
+// Field attribute bits beyond the display masks in types.go.
+const (
+	attrMDT = 0x01
+)
+
+// Attention identifiers.
+const (
+	aidNone            = 0x60
+	aidStructuredField = 0x88
+	aidEnter           = 0x7D
+	aidClear           = 0x6D
+	aidSysReq          = 0xF0
+	aidPA1             = 0x6C
+	aidPA2             = 0x6E
+	aidPA3             = 0x6B
+)
+
+// aidPF maps PF1-PF24 to their AID bytes.
+var aidPF = [...]byte{
+	0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8, 0xF9, 0x7A, 0x7B, 0x7C,
+	0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xC7, 0xC8, 0xC9, 0x4A, 0x4B, 0x4C,
+}
+
+// bufferAddressCodes is the 6-bit to EBCDIC translation table used for
+// 12-bit buffer addresses and field attribute bytes.
+var bufferAddressCodes = [64]byte{
+	0x40, 0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xC7, 0xC8, 0xC9, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F,
+	0x50, 0xD1, 0xD2, 0xD3, 0xD4, 0xD5, 0xD6, 0xD7, 0xD8, 0xD9, 0x5A, 0x5B, 0x5C, 0x5D, 0x5E, 0x5F,
+	0x60, 0x61, 0xE2, 0xE3, 0xE4, 0xE5, 0xE6, 0xE7, 0xE8, 0xE9, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F,
+	0xF0, 0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8, 0xF9, 0x7A, 0x7B, 0x7C, 0x7D, 0x7E, 0x7F,
+}
+
+// decodeBufferAddress converts a two-byte 12- or 14-bit buffer address.
+func decodeBufferAddress(b0, b1 byte) int {
+	if b0&0xC0 == 0 {
+		return int(b0&0x3F)<<8 | int(b1)
+	}
+	return int(b0&0x3F)<<6 | int(b1&0x3F)
+}
+
+// encodeBufferAddress converts addr to the two-byte form appropriate for a
+// buffer of the given size: 12-bit up to 4096 positions, 14-bit beyond.
+func encodeBufferAddress(addr, size int) [2]byte {
+	if size > 4096 {
+		return [2]byte{byte(addr>>8) & 0x3F, byte(addr)}
+	}
+	return [2]byte{bufferAddressCodes[(addr>>6)&0x3F], bufferAddressCodes[addr&0x3F]}
+}
+
+// isShortReadAID reports whether an AID is sent without cursor or field data.
+func isShortReadAID(aid byte) bool {
+	switch aid {
+	case aidClear, aidPA1, aidPA2, aidPA3, aidSysReq:
+		return true
+	default:
+		return false
+	}
+}
+
+// tnCell is one position of the 3270 presentation space.
+type tnCell struct {
+	ch   byte // EBCDIC character (unused when fa is set)
+	fa   bool // position holds a field attribute
+	attr byte // 3270 field attribute
+	fg   byte // extended foreground color
+	hl   byte // extended highlighting
+}
+
+// tnBuffer is the 3270 presentation space maintained by the native client.
+type tnBuffer struct {
+	defaultRows, defaultCols int
+	altRows, altCols         int
+	rows, cols               int
+	cells                    []tnCell
+	cursor                   int
+	insert                   bool
+}
+
+func newTNBuffer(altRows, altCols int) *tnBuffer {
+	b := &tnBuffer{
+		defaultRows: 24,
+		defaultCols: 80,
+		altRows:     altRows,
+		altCols:     altCols,
+	}
+	b.resize(b.defaultRows, b.defaultCols)
+	return b
+}
+
+func (b *tnBuffer) size() int {
+	return len(b.cells)
+}
+
+func (b *tnBuffer) resize(rows, cols int) {
+	b.rows = rows
+	b.cols = cols
+	b.cells = make([]tnCell, rows*cols)
+	b.cursor = 0
+}
+
+func (b *tnBuffer) clear() {
+	for i := range b.cells {
+		b.cells[i] = tnCell{}
+	}
+	b.cursor = 0
+}
+
+func (b *tnBuffer) wrap(addr int) int {
+	n := b.size()
+	if n == 0 {
+		return 0
+	}
+	addr %= n
+	if addr < 0 {
+		addr += n
+	}
+	return addr
+}
+
+// isFormatted reports whether the buffer contains any field attributes.
+func (b *tnBuffer) isFormatted() bool {
+	for i := range b.cells {
+		if b.cells[i].fa {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldAttrAddr returns the address of the field attribute governing addr,
+// or -1 when the buffer is unformatted.
+func (b *tnBuffer) fieldAttrAddr(addr int) int {
+	n := b.size()
+	for i := 0; i < n; i++ {
+		p := b.wrap(addr - i)
+		if b.cells[p].fa {
+			return p
+		}
+	}
+	return -1
+}
+
+// isProtected reports whether a character may not be typed at addr.
+func (b *tnBuffer) isProtected(addr int) bool {
+	if b.cells[addr].fa {
+		return true
+	}
+	fa := b.fieldAttrAddr(addr)
+	if fa < 0 {
+		return false
+	}
+	return b.cells[fa].attr&AttrProtected != 0
+}
+
+// nextUnprotected returns the first data position of the next unprotected
+// field at or after addr, or -1 when there is none.
+func (b *tnBuffer) nextUnprotected(addr int) int {
+	n := b.size()
+	for i := 0; i < n; i++ {
+		p := b.wrap(addr + i)
+		if !b.cells[p].fa || b.cells[p].attr&AttrProtected != 0 {
+			continue
+		}
+		next := b.wrap(p + 1)
+		if !b.cells[next].fa {
+			return next
+		}
+	}
+	return -1
+}
+
+// fieldEnd returns the last data position of the field containing addr.
+func (b *tnBuffer) fieldEnd(addr int) int {
+	n := b.size()
+	for i := 1; i < n; i++ {
+		p := b.wrap(addr + i)
+		if b.cells[p].fa {
+			return b.wrap(p - 1)
+		}
+	}
+	return b.wrap(addr - 1)
+}
+
+func (b *tnBuffer) setMDT(addr int) {
+	if fa := b.fieldAttrAddr(addr); fa >= 0 {
+		b.cells[fa].attr |= attrMDT
+	}
+}
+
+func (b *tnBuffer) resetMDT(unprotectedOnly bool) {
+	for i := range b.cells {
+		c := &b.cells[i]
+		if !c.fa {
+			continue
+		}
+		if unprotectedOnly && c.attr&AttrProtected != 0 {
+			continue
+		}
+		c.attr &^= attrMDT
+	}
+}
+
+// write applies a Write, Erase/Write or Erase/Write Alternate command. data
+// starts at the WCC. It returns the WCC so callers can react to keyboard
+// restore.
+func (b *tnBuffer) write(cmd byte, data []byte) (byte, error) {
+	switch cmd {
+	case cmdEraseWrite, cmdEraseWriteSNA:
+		b.resize(b.defaultRows, b.defaultCols)
+	case cmdEraseWriteAlternate, cmdEraseWriteAlternateSNA:
+		b.resize(b.altRows, b.altCols)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	wcc := data[0]
+	if wcc&wccResetMDT != 0 {
+		b.resetMDT(false)
+	}
+
+	addr := b.cursor
+	var saFg, saHl byte
+	putChar := func(ch byte) {
+		b.cells[addr] = tnCell{ch: ch, fg: saFg, hl: saHl}
+		addr = b.wrap(addr + 1)
+	}
+	need := func(i, n int) error {
+		if i+n >= len(data) {
+			return fmt.Errorf("truncated 3270 order 0x%02x", data[i])
+		}
+		return nil
+	}
+
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case orderSF:
+			if err := need(i, 1); err != nil {
+				return wcc, err
+			}
+			b.cells[addr] = tnCell{fa: true, attr: data[i+1]}
+			addr = b.wrap(addr + 1)
+			i++
+		case orderSFE, orderMF:
+			if err := need(i, 1); err != nil {
+				return wcc, err
+			}
+			count := int(data[i+1])
+			if err := need(i+1, count*2); err != nil {
+				return wcc, err
+			}
+			cell := tnCell{fa: true}
+			if data[i] == orderMF {
+				cell = b.cells[addr]
+			}
+			for p := 0; p < count; p++ {
+				t, v := data[i+2+p*2], data[i+3+p*2]
+				switch t {
+				case xa3270:
+					cell.attr = v
+				case xaHighlight:
+					cell.hl = v
+				case xaForeground:
+					cell.fg = v
+				case xaAll:
+					cell.fg, cell.hl = 0, 0
+				}
+			}
+			if data[i] == orderSFE || cell.fa {
+				b.cells[addr] = cell
+			}
+			addr = b.wrap(addr + 1)
+			i += 1 + count*2
+		case orderSBA:
+			if err := need(i, 2); err != nil {
+				return wcc, err
+			}
+			addr = b.wrap(decodeBufferAddress(data[i+1], data[i+2]))
+			i += 2
+		case orderSA:
+			if err := need(i, 2); err != nil {
+				return wcc, err
+			}
+			switch data[i+1] {
+			case xaAll:
+				saFg, saHl = 0, 0
+			case xaHighlight:
+				saHl = data[i+2]
+			case xaForeground:
+				saFg = data[i+2]
+			}
+			i += 2
+		case orderIC:
+			b.cursor = addr
+		case orderPT:
+			if next := b.nextUnprotected(addr); next >= 0 && next >= addr {
+				addr = next
+			} else {
+				addr = 0
+			}
+		case orderRA:
+			if err := need(i, 3); err != nil {
+				return wcc, err
+			}
+			stop := b.wrap(decodeBufferAddress(data[i+1], data[i+2]))
+			ch := data[i+3]
+			i += 3
+			if ch == orderGE {
+				if err := need(i, 1); err != nil {
+					return wcc, err
+				}
+				ch = data[i+1]
+				i++
+			}
+			for first := true; first || addr != stop; first = false {
+				putChar(ch)
+			}
+		case orderEUA:
+			if err := need(i, 2); err != nil {
+				return wcc, err
+			}
+			stop := b.wrap(decodeBufferAddress(data[i+1], data[i+2]))
+			i += 2
+			for first := true; first || addr != stop; first = false {
+				if !b.isProtected(addr) {
+					b.cells[addr].ch = 0
+				}
+				addr = b.wrap(addr + 1)
+			}
+		case orderGE:
+			if err := need(i, 1); err != nil {
+				return wcc, err
+			}
+			putChar(data[i+1])
+			i++
+		default:
+			putChar(data[i])
+		}
+	}
+	return wcc, nil
+}
+
+// eraseAllUnprotected implements the EAU command.
+func (b *tnBuffer) eraseAllUnprotected() {
+	for i := range b.cells {
+		if !b.cells[i].fa && !b.isProtected(i) {
+			b.cells[i].ch = 0
+		}
+	}
+	b.resetMDT(true)
+	b.home()
+}
+
+// readModified builds the inbound data for an AID. When all is set, every
+// field is sent regardless of its modified data tag (Read Modified All).
+func (b *tnBuffer) readModified(aid byte, all bool) []byte {
+	out := []byte{aid}
+	if isShortReadAID(aid) && !all {
+		return out
+	}
+	n := b.size()
+	cur := encodeBufferAddress(b.cursor, n)
+	out = append(out, cur[0], cur[1])
+
+	if !b.isFormatted() {
+		for i := 0; i < n; i++ {
+			if ch := b.cells[i].ch; ch != 0 {
+				out = append(out, ch)
+			}
+		}
+		return out
+	}
+
+	for fa := 0; fa < n; fa++ {
+		c := b.cells[fa]
+		if !c.fa || (!all && c.attr&attrMDT == 0) {
+			continue
+		}
+		start := b.wrap(fa + 1)
+		addr := encodeBufferAddress(start, n)
+		out = append(out, orderSBA, addr[0], addr[1])
+		for p := start; !b.cells[p].fa; p = b.wrap(p + 1) {
+			if ch := b.cells[p].ch; ch != 0 {
+				out = append(out, ch)
+			}
+		}
+	}
+	return out
+}
+
+// readBuffer builds the inbound data for a Read Buffer command.
+func (b *tnBuffer) readBuffer(aid byte) []byte {
+	n := b.size()
+	out := make([]byte, 0, n+3)
+	cur := encodeBufferAddress(b.cursor, n)
+	out = append(out, aid, cur[0], cur[1])
+	for _, c := range b.cells {
+		if c.fa {
+			out = append(out, orderSF, c.attr)
+			continue
+		}
+		out = append(out, c.ch)
+	}
+	return out
+}
+
+// typeChar enters ch at the cursor the way an operator keystroke would.
+func (b *tnBuffer) typeChar(ch byte) error {
+	addr := b.cursor
+	if b.isProtected(addr) {
+		return fmt.Errorf("cannot type at row %d column %d: protected field", addr/b.cols, addr%b.cols)
+	}
+	if b.insert {
+		end := b.fieldEnd(addr)
+		if b.cells[end].ch != 0 {
+			return fmt.Errorf("cannot insert: field is full")
+		}
+		for p := end; p != addr; p = b.wrap(p - 1) {
+			prev := b.wrap(p - 1)
+			b.cells[p].ch = b.cells[prev].ch
+		}
+	}
+	b.cells[addr].ch = ch
+	b.setMDT(addr)
+
+	next := b.wrap(addr + 1)
+	if b.cells[next].fa {
+		if skip := b.nextUnprotected(next); skip >= 0 {
+			next = skip
+		} else {
+			next = b.wrap(next + 1)
+		}
+	}
+	b.cursor = next
+	return nil
+}
+
+// eraseEOF clears from the cursor to the end of its field.
+func (b *tnBuffer) eraseEOF() error {
+	addr := b.cursor
+	if b.isProtected(addr) {
+		return fmt.Errorf("cannot erase: protected field")
+	}
+	if !b.isFormatted() {
+		for p := addr; p < b.size(); p++ {
+			b.cells[p].ch = 0
+		}
+		return nil
+	}
+	end := b.fieldEnd(addr)
+	for p := addr; ; p = b.wrap(p + 1) {
+		b.cells[p].ch = 0
+		if p == end {
+			break
+		}
+	}
+	b.setMDT(addr)
+	return nil
+}
+
+// eraseInput clears all unprotected fields and homes the cursor.
+func (b *tnBuffer) eraseInput() {
+	b.eraseAllUnprotected()
+}
+
+// deleteChar removes the character at the cursor, shifting the rest of the
+// field left.
+func (b *tnBuffer) deleteChar() error {
+	addr := b.cursor
+	if b.isProtected(addr) {
+		return fmt.Errorf("cannot delete: protected field")
+	}
+	end := b.size() - 1
+	if b.isFormatted() {
+		end = b.fieldEnd(addr)
+	}
+	for p := addr; p != end; p = b.wrap(p + 1) {
+		b.cells[p].ch = b.cells[b.wrap(p+1)].ch
+	}
+	b.cells[end].ch = 0
+	b.setMDT(addr)
+	return nil
+}
+
+func (b *tnBuffer) tab() {
+	if next := b.nextUnprotected(b.wrap(b.cursor + 1)); next >= 0 {
+		b.cursor = next
+		return
+	}
+	b.cursor = 0
+}
+
+func (b *tnBuffer) backTab() {
+	n := b.size()
+	start := b.cursor
+	// From inside a field (past its first position) go to that field's start.
+	if fa := b.fieldAttrAddr(start); fa >= 0 && !b.cells[start].fa && b.cells[fa].attr&AttrProtected == 0 {
+		if first := b.wrap(fa + 1); first != start {
+			b.cursor = first
+			return
+		}
+		start = fa
+	}
+	for i := 1; i <= n; i++ {
+		p := b.wrap(start - i)
+		if !b.cells[p].fa || b.cells[p].attr&AttrProtected != 0 {
+			continue
+		}
+		if next := b.wrap(p + 1); !b.cells[next].fa {
+			b.cursor = next
+			return
+		}
+	}
+	b.cursor = 0
+}
+
+func (b *tnBuffer) home() {
+	if next := b.nextUnprotected(0); next >= 0 {
+		b.cursor = next
+		return
+	}
+	b.cursor = 0
+}
+
+func (b *tnBuffer) newline() {
+	start := b.wrap((b.cursor/b.cols + 1) * b.cols)
+	if !b.isProtected(start) {
+		b.cursor = start
+		return
+	}
+	if next := b.nextUnprotected(start); next >= 0 {
+		b.cursor = next
+		return
+	}
+	b.cursor = start
+}
+
+func (b *tnBuffer) moveCursor(delta int) {
+	b.cursor = b.wrap(b.cursor + delta)
+}
@@ -0,0 +1,402 @@
+package host
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/sampleapps"
+)
+
+func freeLocalPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func screenRow(s *Screen, row int) string {
+	if row >= len(s.Buffer) {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		if r == 0 {
+			return ' '
+		}
+		return r
+	}, string(s.Buffer[row]))
+}
+
+func TestTN3270SampleAppEndToEnd(t *testing.T) {
+	port := freeLocalPort(t)
+	server, err := sampleapps.StartServer("app1", port)
+	if err != nil {
+		t.Fatalf("StartServer failed: %v", err)
+	}
+	defer server.Stop()
+
+	h := NewTN3270(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), TN3270Options{Model: "3279-2-E"})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer h.Stop()
+
+	screen := h.GetScreen()
+	if !screen.IsFormatted {
+		t.Fatalf("IsFormatted = false, want true")
+	}
+	if got := screenRow(screen, 0); !strings.Contains(got, "3270 Example Application") {
+		t.Fatalf("row 0 = %q, want title", got)
+	}
+	if locked, ok := screen.StatusKeyboardLocked(); !ok || locked {
+		t.Fatalf("StatusKeyboardLocked = %v,%v, want false,true", locked, ok)
+	}
+	if row, col, ok := screen.StatusCursor(); !ok || row != 4 || col != 20 {
+		t.Fatalf("StatusCursor = %d,%d,%v, want 4,20,true", row, col, ok)
+	}
+	if rows, cols, ok := screen.StatusDimensions(); !ok || rows != 24 || cols != 80 {
+		t.Fatalf("StatusDimensions = %d,%d,%v, want 24,80,true", rows, cols, ok)
+	}
+
+	var errField *Field
+	for _, f := range screen.Fields {
+		if f.StartY == 10 && f.StartX == 1 {
+			errField = f
+		}
+	}
+	if errField == nil {
+		t.Fatalf("expected error message field at row 10")
+	}
+	if errField.Color != AttrColRed || !errField.IsIntensified() {
+		t.Fatalf("error field color=%#x intensified=%v, want red intensified", errField.Color, errField.IsIntensified())
+	}
+
+	// Submitting with no input fails validation on the host side.
+	if err := h.SendKey("Enter"); err != nil {
+		t.Fatalf("SendKey(Enter) failed: %v", err)
+	}
+	if err := h.UpdateScreen(); err != nil {
+		t.Fatalf("UpdateScreen failed: %v", err)
+	}
+	if got := screenRow(h.GetScreen(), 10); !strings.Contains(got, "First and Last Name fields are required.") {
+		t.Fatalf("row 10 = %q, want validation message", got)
+	}
+
+	// Fill the fields both ways: direct writes and changed-field submission.
+	if err := h.WriteStringAt(4, 20, "Ada"); err != nil {
+		t.Fatalf("WriteStringAt failed: %v", err)
+	}
+	for _, f := range h.GetScreen().Fields {
+		if f.StartY == 5 && f.StartX == 20 {
+			f.Value = "Lovelace"
+			f.Changed = true
+		}
+		if f.StartY == 6 && f.StartX == 20 {
+			f.Value = "secret"
+			f.Changed = true
+		}
+	}
+	if err := h.SubmitScreen(); err != nil {
+		t.Fatalf("SubmitScreen failed: %v", err)
+	}
+	if err := h.SendKey("Enter"); err != nil {
+		t.Fatalf("SendKey(Enter) failed: %v", err)
+	}
+	if err := h.UpdateScreen(); err != nil {
+		t.Fatalf("UpdateScreen failed: %v", err)
+	}
+	screen = h.GetScreen()
+	if got := screenRow(screen, 4); !strings.Contains(got, "Your first name is Ada") {
+		t.Fatalf("row 4 = %q, want first name echoed", got)
+	}
+	if got := screenRow(screen, 5); !strings.Contains(got, "And your last name is Lovelace") {
+		t.Fatalf("row 5 = %q, want last name echoed", got)
+	}
+	if got := screenRow(screen, 6); !strings.Contains(got, "Your password was 6 characters long") {
+		t.Fatalf("row 6 = %q, want password length", got)
+	}
+
+	// PF3 ends the sample app; the next update reconnects like s3270 does.
+	if err := h.SendKey("PF(3)"); err != nil {
+		t.Fatalf("SendKey(PF3) failed: %v", err)
+	}
+	if err := h.UpdateScreen(); err != nil {
+		t.Fatalf("UpdateScreen after disconnect failed: %v", err)
+	}
+	if got := screenRow(h.GetScreen(), 0); !strings.Contains(got, "3270 Example Application") {
+		t.Fatalf("row 0 after reconnect = %q, want title", got)
+	}
+}
+
+// TestTN3270ENegotiation drives the client through TN3270E device-type and
+// function negotiation and checks that data records carry the header.
+func TestTN3270ENegotiation(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+
+	inbound := make(chan []byte, 1)
+	deviceType := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		expect := func(want ...byte) bool {
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(r, got); err != nil {
+				return false
+			}
+			return string(got) == string(want)
+		}
+
+		conn.Write([]byte{telnetIAC, telnetDO, telnetOptTN3270E})
+		if !expect(telnetIAC, telnetWILL, telnetOptTN3270E) {
+			return
+		}
+		conn.Write([]byte{telnetIAC, telnetSB, telnetOptTN3270E, tn3270eSend, tn3270eDeviceType, telnetIAC, telnetSE})
+		sub, err := readTestSubnegotiation(r)
+		if err != nil {
+			return
+		}
+		deviceType <- string(sub)
+		reply := []byte{telnetIAC, telnetSB, telnetOptTN3270E, tn3270eDeviceType, tn3270eIs}
+		reply = append(reply, "IBM-3278-2-E"...)
+		reply = append(reply, tn3270eConnect)
+		reply = append(reply, "LU01"...)
+		reply = append(reply, telnetIAC, telnetSE)
+		conn.Write(reply)
+		if _, err := readTestSubnegotiation(r); err != nil {
+			return
+		}
+		conn.Write([]byte{telnetIAC, telnetSB, telnetOptTN3270E, tn3270eFunctions, tn3270eIs, telnetIAC, telnetSE})
+
+		// Erase/Write with keyboard restore: protected label, input field.
+		record := []byte{tn3270eData3270, 0, 0, 0, 0, cmdEraseWrite, 0xC3,
+			orderSF, 0x60, 0xC8, 0xC9, // "HI"
+			orderSF, 0x40, orderIC,
+			orderSBA, 0x40, 0x4A, orderSF, 0x60,
+			telnetIAC, telnetEOR}
+		conn.Write(record)
+
+		var data []byte
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			if b == telnetIAC {
+				next, _ := r.ReadByte()
+				if next == telnetEOR {
+					break
+				}
+			}
+			data = append(data, b)
+		}
+		inbound <- data
+		conn.Write([]byte{tn3270eData3270, 0, 0, 0, 1, cmdWrite, 0xC2, telnetIAC, telnetEOR})
+		time.Sleep(200 * time.Millisecond)
+	}()
+
+	h := NewTN3270(l.Addr().String(), TN3270Options{Model: "3278-2", LU: "LU01"})
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer h.Stop()
+
+	select {
+	case got := <-deviceType:
+		want := string([]byte{tn3270eDeviceType, tn3270eRequest}) + "IBM-3278-2-E" + string([]byte{tn3270eConnect}) + "LU01"
+		if got != want {
+			t.Fatalf("device type request = %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("device type request not received")
+	}
+
+	if got := screenRow(h.GetScreen(), 0); !strings.HasPrefix(got, " HI") {
+		t.Fatalf("row 0 = %q, want HI label", got)
+	}
+	if err := h.WriteStringAt(0, 4, "A"); err != nil {
+		t.Fatalf("WriteStringAt failed: %v", err)
+	}
+	if err := h.SendKey("Enter"); err != nil {
+		t.Fatalf("SendKey failed: %v", err)
+	}
+
+	select {
+	case got := <-inbound:
+		want := []byte{tn3270eData3270, 0, 0, 0, 0, aidEnter, 0x40, 0xC5, orderSBA, 0x40, 0xC4, 0xC1}
+		if string(got) != string(want) {
+			t.Fatalf("inbound record = % x, want % x", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("inbound record not received")
+	}
+}
+
+func readTestSubnegotiation(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	return readSubnegotiation(r)
+}
+
+func TestBufferAddressRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		addr int
+		size int
+		want [2]byte
+	}{
+		{name: "origin 12-bit", addr: 0, size: 1920, want: [2]byte{0x40, 0x40}},
+		{name: "row 4 col 20", addr: 340, size: 1920, want: [2]byte{0xC5, 0xD4}},
+		{name: "last position 12-bit", addr: 1919, size: 1920, want: [2]byte{0x5D, 0x7F}},
+		{name: "14-bit", addr: 5000, size: 27 * 132 * 2, want: [2]byte{0x13, 0x88}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := encodeBufferAddress(tt.addr, tt.size)
+			if got != tt.want {
+				t.Fatalf("encodeBufferAddress(%d) = % x, want % x", tt.addr, got, tt.want)
+			}
+			if back := decodeBufferAddress(got[0], got[1]); back != tt.addr {
+				t.Fatalf("decodeBufferAddress = %d, want %d", back, tt.addr)
+			}
+		})
+	}
+}
+
+func TestTNBufferWriteOrders(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    byte
+		data   []byte
+		check  func(t *testing.T, b *tnBuffer)
+		cursor int
+	}{
+		{
+			name: "start field extended sets color and highlight",
+			cmd:  cmdEraseWrite,
+			data: []byte{0xC3, orderSFE, 0x03, xa3270, 0xE8, xaForeground, 0xF2, xaHighlight, 0xF4, 0xC1},
+			check: func(t *testing.T, b *tnBuffer) {
+				c := b.cells[0]
+				if !c.fa || c.attr != 0xE8 || c.fg != 0xF2 || c.hl != 0xF4 {
+					t.Fatalf("cell 0 = %+v, want extended field attribute", c)
+				}
+				if b.cells[1].ch != 0xC1 {
+					t.Fatalf("cell 1 = %#x, want 0xc1", b.cells[1].ch)
+				}
+			},
+		},
+		{
+			name: "repeat to address fills and insert cursor",
+			cmd:  cmdEraseWrite,
+			data: []byte{0xC3, orderRA, 0x40, 0x4A, 0x5C, orderIC},
+			check: func(t *testing.T, b *tnBuffer) {
+				for i := 0; i < 10; i++ {
+					if b.cells[i].ch != 0x5C {
+						t.Fatalf("cell %d = %#x, want 0x5c", i, b.cells[i].ch)
+					}
+				}
+				if b.cells[10].ch != 0 {
+					t.Fatalf("cell 10 = %#x, want null", b.cells[10].ch)
+				}
+			},
+			cursor: 10,
+		},
+		{
+			name: "erase unprotected to address keeps protected data",
+			cmd:  cmdEraseWrite,
+			data: []byte{0xC3, orderSF, 0x60, 0xC1, orderSF, 0x40, 0xC2, 0xC3, orderSBA, 0x40, 0x40, orderEUA, 0x40, 0x46},
+			check: func(t *testing.T, b *tnBuffer) {
+				if b.cells[1].ch != 0xC1 {
+					t.Fatalf("protected cell = %#x, want 0xc1", b.cells[1].ch)
+				}
+				if b.cells[3].ch != 0 || b.cells[4].ch != 0 {
+					t.Fatalf("unprotected cells = %#x %#x, want nulls", b.cells[3].ch, b.cells[4].ch)
+				}
+			},
+			cursor: 0,
+		},
+		{
+			name: "erase write alternate resizes",
+			cmd:  cmdEraseWriteAlternate,
+			data: []byte{0xC3},
+			check: func(t *testing.T, b *tnBuffer) {
+				if b.rows != 43 || b.cols != 80 {
+					t.Fatalf("size = %dx%d, want 43x80", b.rows, b.cols)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTNBuffer(43, 80)
+			if _, err := b.write(tt.cmd, tt.data); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			tt.check(t, b)
+			if b.cursor != tt.cursor {
+				t.Fatalf("cursor = %d, want %d", b.cursor, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestTNBufferTypingRespectsFields(t *testing.T) {
+	b := newTNBuffer(24, 80)
+	// Protected label, two-character input field, protected trailer, input field.
+	data := []byte{0xC3, orderSF, 0x60, 0xC1, orderSF, 0x40, orderIC, 0x00, 0x00, orderSF, 0x60, orderSF, 0x40}
+	if _, err := b.write(cmdEraseWrite, data); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	b.cursor = 0
+	if err := b.typeChar(0xC2); err == nil {
+		t.Fatalf("typeChar on field attribute succeeded, want error")
+	}
+	b.home()
+	if b.cursor != 3 {
+		t.Fatalf("home cursor = %d, want 3", b.cursor)
+	}
+	for _, ch := range []byte{0xC2, 0xC3} {
+		if err := b.typeChar(ch); err != nil {
+			t.Fatalf("typeChar failed: %v", err)
+		}
+	}
+	if b.cursor != 7 {
+		t.Fatalf("cursor after filling field = %d, want auto-skip to 7", b.cursor)
+	}
+	got := b.readModified(aidEnter, false)
+	want := []byte{aidEnter, 0x40, 0xC7, orderSBA, 0x40, 0xC3, 0xC2, 0xC3}
+	if string(got) != string(want) {
+		t.Fatalf("readModified = % x, want % x", got, want)
+	}
+}
+
+func TestTN3270InputRejectedWhileLocked(t *testing.T) {
+	h := NewTN3270("127.0.0.1:1", TN3270Options{})
+	h.screen = &Screen{Width: 80, Height: 24}
+	h.locked = true
+	if err := h.SubmitScreen(); err == nil || !strings.Contains(err.Error(), "keyboard locked") {
+		t.Fatalf("SubmitScreen while locked = %v, want keyboard locked", err)
+	}
+	if err := h.SubmitUnformatted("ABC"); err == nil || !strings.Contains(err.Error(), "keyboard locked") {
+		t.Fatalf("SubmitUnformatted while locked = %v, want keyboard locked", err)
+	}
+	if got := h.buf.cells[0].ch; got != 0 {
+		t.Fatalf("buffer changed while locked: %#x", got)
+	}
+}
@@ -0,0 +1,247 @@
+package host
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transfer directions, named from the workstation's point of view as s3270
+// does: "send" uploads a local file to the host, "receive" downloads one.
+const (
+	TransferSend    = "send"
+	TransferReceive = "receive"
+)
+
+// transferTimeout bounds a single IND$FILE transfer. Large files need far
+// longer than an ordinary command.
+const transferTimeout = 30 * time.Minute
+
+// errTransferInProgress is returned by commands issued while a transfer has
+// the s3270 connection, so callers fail at once instead of waiting for it.
+var errTransferInProgress = errors.New("file transfer in progress")
+
+var (
+	transferHostTypes   = []string{"tso", "vm", "cics"}
+	transferModes       = []string{"ascii", "binary"}
+	transferCRModes     = []string{"add", "remove", "keep"}
+	transferExistModes  = []string{"keep", "replace", "append"}
+	transferRecfms      = []string{"default", "fixed", "variable", "undefined"}
+	transferAllocations = []string{"default", "tracks", "cylinders", "avblock"}
+)
+
+// TransferOptions describes one IND$FILE transfer. Empty strings and zero
+// numbers leave the s3270 default in place.
+type TransferOptions struct {
+	Direction  string
+	LocalFile  string
+	HostFile   string
+	HostType   string
+	Mode       string
+	CR         string
+	Exist      string
+	Recfm      string
+	Lrecl      int
+	Blksize    int
+	Allocation string
+	Primary    int
+	Secondary  int
+	Avblock    int
+}
+
+// FileTransferer is implemented by hosts that can run IND$FILE transfers over
+// their existing connection.
+type FileTransferer interface {
+	Transfer(opts TransferOptions) (string, error)
+}
+
+// Validate checks the options against the values s3270 accepts.
+func (o TransferOptions) Validate() error {
+	if o.Direction != TransferSend && o.Direction != TransferReceive {
+		return fmt.Errorf("direction must be %s or %s", TransferSend, TransferReceive)
+	}
+	if strings.TrimSpace(o.HostFile) == "" {
+		return fmt.Errorf("host file is required")
+	}
+	if o.LocalFile == "" {
+		return fmt.Errorf("local file is required")
+	}
+	if strings.ContainsAny(o.HostFile, "\r\n\x00") || strings.ContainsAny(o.LocalFile, "\r\n\x00") {
+		return fmt.Errorf("file names must not contain control characters")
+	}
+	enums := []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"host type", o.HostType, transferHostTypes},
+		{"mode", o.Mode, transferModes},
+		{"CR handling", o.CR, transferCRModes},
+		{"exist", o.Exist, transferExistModes},
+		{"record format", o.Recfm, transferRecfms},
+		{"allocation", o.Allocation, transferAllocations},
+	}
+	for _, e := range enums {
+		if e.value != "" && !containsString(e.allowed, e.value) {
+			return fmt.Errorf("%s must be one of %s", e.name, strings.Join(e.allowed, ", "))
+		}
+	}
+	if o.Lrecl < 0 || o.Blksize < 0 || o.Primary < 0 || o.Secondary < 0 || o.Avblock < 0 {
+		return fmt.Errorf("LRECL, block size and space values must not be negative")
+	}
+	if o.HostType == "cics" && (o.Recfm != "" || o.Lrecl > 0 || o.Blksize > 0 || o.Allocation != "") {
+		return fmt.Errorf("record format, LRECL, block size and allocation do not apply to CICS")
+	}
+	return nil
+}
+
+// Command builds the s3270 Transfer() action for the options.
+func (o TransferOptions) Command() string {
+	args := []string{"Direction=" + o.Direction, "LocalFile=" + o.LocalFile, "HostFile=" + o.HostFile}
+	add := func(key, value string) {
+		if value != "" {
+			args = append(args, key+"="+value)
+		}
+	}
+	addInt := func(key string, value int) {
+		if value > 0 {
+			args = append(args, key+"="+strconv.Itoa(value))
+		}
+	}
+	add("Host", o.HostType)
+	add("Mode", o.Mode)
+	add("Cr", o.CR)
+	add("Exist", o.Exist)
+	add("Recfm", o.Recfm)
+	addInt("Lrecl", o.Lrecl)
+	addInt("Blksize", o.Blksize)
+	add("Allocation", o.Allocation)
+	addInt("PrimarySpace", o.Primary)
+	addInt("SecondarySpace", o.Secondary)
+	addInt("Avblock", o.Avblock)
+	for i, arg := range args {
+		args[i] = quoteActionArg(arg)
+	}
+	return "Transfer(" + strings.Join(args, ",") + ")"
+}
+
+// quoteActionArg wraps an action argument in double quotes when it contains
+// characters the s3270 action parser would otherwise split on.
+func quoteActionArg(arg string) string {
+	if !strings.ContainsAny(arg, " ,()\"'\\\t") {
+		return arg
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg)
+	return `"` + escaped + `"`
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Transfer runs an IND$FILE transfer on the open s3270 connection and
+// returns s3270's completion message. The command lock is only held to start
+// the transfer; until it ends, other commands fail with
+// errTransferInProgress and Stop kills the transfer with the connection.
+func (h *S3270) Transfer(opts TransferOptions) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	cmd := opts.Command()
+	stdout, err := h.beginTransfer(cmd)
+	if err != nil {
+		return "", err
+	}
+	defer h.endTransfer()
+
+	type transferResult struct {
+		data   []string
+		status string
+		err    error
+	}
+	resultCh := make(chan transferResult, 1)
+	go func() {
+		data, status, err := h.readResponseFrom(stdout)
+		resultCh <- transferResult{data: data, status: status, err: err}
+	}()
+
+	var result transferResult
+	select {
+	case result = <-resultCh:
+	case <-time.After(transferTimeout):
+		h.mu.Lock()
+		if h.cmd != nil && h.cmd.Process != nil {
+			_ = h.cmd.Process.Kill()
+		}
+		if h.stdin != nil {
+			h.stdin.Close()
+			h.stdin = nil
+		}
+		h.mu.Unlock()
+		return "", fmt.Errorf("s3270 transfer timed out")
+	}
+	log.Printf("s3270: cmd=%q status=%q", cmd, result.status)
+	message := strings.TrimSpace(strings.Join(trimDataPrefixes(result.data), " "))
+	if result.err != nil && !isCommandError(result.err) {
+		return message, result.err
+	}
+	if result.err != nil || isS3270Error(result.status, result.data) {
+		if message == "" {
+			message = "transfer failed"
+		}
+		return message, fmt.Errorf("%s", message)
+	}
+	return message, nil
+}
+
+// beginTransfer sends the Transfer() action and marks the connection busy.
+// It returns the reader the response arrives on.
+func (h *S3270) beginTransfer(cmd string) (*bufio.Scanner, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.busyLocked(); err != nil {
+		return nil, err
+	}
+	if h.stdin == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if h.verboseLogging {
+		log.Printf("[VERBOSE] s3270 command: %q", cmd)
+	}
+	if _, err := fmt.Fprintln(h.stdin, cmd); err != nil {
+		h.stdin = nil
+		return nil, err
+	}
+	h.transferring = true
+	return h.stdout, nil
+}
+
+func (h *S3270) endTransfer() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.transferring = false
+}
+
+// busyLocked fails while a transfer has the connection. Callers hold h.mu.
+func (h *S3270) busyLocked() error {
+	if h.transferring {
+		return errTransferInProgress
+	}
+	return nil
+}
+
+func trimDataPrefixes(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, strings.TrimPrefix(line, "data: "))
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package host
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransferOptionsCommand(t *testing.T) {
+	opts := TransferOptions{
+		Direction: TransferSend,
+		LocalFile: "/tmp/my data.txt",
+		HostFile:  "'USER.DATA(MEMBER)'",
+		HostType:  "tso",
+		Mode:      "ascii",
+		CR:        "add",
+		Recfm:     "fixed",
+		Lrecl:     80,
+	}
+	want := `Transfer(Direction=send,"LocalFile=/tmp/my data.txt","HostFile='USER.DATA(MEMBER)'",Host=tso,Mode=ascii,Cr=add,Recfm=fixed,Lrecl=80)`
+	if got := opts.Command(); got != want {
+		t.Fatalf("Command() = %s, want %s", got, want)
+	}
+	opts.LocalFile = `C:\a"b`
+	if got := opts.Command(); !strings.Contains(got, `"LocalFile=C:\\a\"b"`) {
+		t.Fatalf("Command() = %s, want escaped local file", got)
+	}
+}
+
+func TestTransferOptionsValidate(t *testing.T) {
+	base := TransferOptions{Direction: TransferReceive, LocalFile: "/tmp/out", HostFile: "PROFILE EXEC A", HostType: "vm"}
+	tests := []struct {
+		name    string
+		modify  func(o *TransferOptions)
+		wantErr string
+	}{
+		{name: "valid", modify: func(o *TransferOptions) {}},
+		{name: "direction", modify: func(o *TransferOptions) { o.Direction = "both" }, wantErr: "direction"},
+		{name: "host file", modify: func(o *TransferOptions) { o.HostFile = " " }, wantErr: "host file is required"},
+		{name: "newline", modify: func(o *TransferOptions) { o.HostFile = "A\nQuit()" }, wantErr: "control characters"},
+		{name: "mode", modify: func(o *TransferOptions) { o.Mode = "ebcdic" }, wantErr: "mode must be one of"},
+		{name: "negative lrecl", modify: func(o *TransferOptions) { o.Lrecl = -1 }, wantErr: "negative"},
+		{name: "cics recfm", modify: func(o *TransferOptions) { o.HostType = "cics"; o.Recfm = "fixed" }, wantErr: "do not apply to CICS"},
+	}
+	for _, tt := range tests {
+		opts := base
+		tt.modify(&opts)
+		err := opts.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("%s: Validate() = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("%s: Validate() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTransferRefusesOtherCommands(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	h := NewS3270("s3270")
+	h.stdin = inW
+	h.stdout = bufio.NewScanner(outR)
+	sent := bufio.NewScanner(inR)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.Transfer(TransferOptions{Direction: TransferReceive, LocalFile: "/tmp/out", HostFile: "PROFILE EXEC A"})
+		done <- err
+	}()
+	if !sent.Scan() || !strings.HasPrefix(sent.Text(), "Transfer(") {
+		t.Fatalf("sent %q, want the Transfer() action", sent.Text())
+	}
+
+	start := time.Now()
+	if err := h.MoveCursor(0, 0); !errors.Is(err, errTransferInProgress) {
+		t.Fatalf("MoveCursor during transfer = %v, want errTransferInProgress", err)
+	}
+	if err := h.UpdateScreen(); !errors.Is(err, errTransferInProgress) {
+		t.Fatalf("UpdateScreen during transfer = %v, want errTransferInProgress", err)
+	}
+	if _, err := h.Transfer(TransferOptions{Direction: TransferReceive, LocalFile: "/tmp/out", HostFile: "OTHER EXEC A"}); !errors.Is(err, errTransferInProgress) {
+		t.Fatalf("second Transfer = %v, want errTransferInProgress", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Fatalf("commands waited %s for the transfer", waited)
+	}
+
+	fmt.Fprint(outW, "data: Transfer complete, 42 bytes transferred\nU F U C(host) I 4 24 80 0 0 0x0 0.000\nok\n")
+	if err := <-done; err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	go func() { sent.Scan() }()
+	go fmt.Fprint(outW, "U F U C(host) I 4 24 80 0 0 0x0 0.000\nok\n")
+	if err := h.MoveCursor(0, 0); err != nil {
+		t.Fatalf("MoveCursor after transfer = %v", err)
+	}
+}
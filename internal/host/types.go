@@ -271,6 +271,17 @@ func (s *Screen) GetInputFieldAt(x, y int) *Field {
 	return nil
 }
 
+// GetFieldAt returns the field, protected or not, at the given coordinates, or
+// nil.
+func (s *Screen) GetFieldAt(x, y int) *Field {
+	for _, f := range s.Fields {
+		if s.contains(f, x, y) {
+			return f
+		}
+	}
+	return nil
+}
+
 func (s *Screen) contains(f *Field, x, y int) bool {
 	// Simple case: single line
 	if f.StartY == f.EndY {
@@ -0,0 +1,125 @@
+// Package pdf renders plain text as a PDF in the built-in Courier font. It
+// covers what captured print jobs need: fixed-pitch lines, page breaks and
+// a title, with no external fonts or images.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	pageShort   = 612.0 // US Letter, in points
+	pageLong    = 792.0
+	margin      = 36.0
+	charWidth   = 0.6 // Courier advance width per point of font size
+	maxFontSize = 10.0
+	minColumns  = 80
+	leading     = 1.2
+)
+
+// Text renders pages of text lines. Output is landscape when lines are
+// wider than a portrait page holds at a readable size, and input pages with
+// more lines than fit are continued on further PDF pages.
+func Text(title string, pages [][]string) []byte {
+	cols := minColumns
+	for _, page := range pages {
+		for _, line := range page {
+			if n := len([]rune(line)); n > cols {
+				cols = n
+			}
+		}
+	}
+	width, height := pageShort, pageLong
+	if cols > 85 {
+		width, height = pageLong, pageShort
+	}
+	fontSize := math.Min(maxFontSize, (width-2*margin)/(charWidth*float64(cols)))
+	lineHeight := fontSize * leading
+	perPage := int((height - 2*margin) / lineHeight)
+
+	var chunks [][]string
+	for _, page := range pages {
+		for len(page) > perPage {
+			chunks = append(chunks, page[:perPage])
+			page = page[perPage:]
+		}
+		chunks = append(chunks, page)
+	}
+	if len(chunks) == 0 {
+		chunks = [][]string{nil}
+	}
+
+	w := &writer{}
+	w.buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	kids := make([]string, len(chunks))
+	for i := range chunks {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	w.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	w.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(chunks)))
+	w.object(3, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	w.object(4, fmt.Sprintf("<< /Title %s /Producer (3270Web) >>", literal(title)))
+	for i, lines := range chunks {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %s Tf\n%s TL\n%s %s Td\n", num(fontSize), num(lineHeight), num(margin), num(height-margin-fontSize))
+		for _, line := range lines {
+			fmt.Fprintf(&content, "%s Tj T*\n", literal(line))
+		}
+		content.WriteString("ET\n")
+		w.object(5+2*i, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			num(width), num(height), 6+2*i))
+		w.object(6+2*i, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+	return w.finish()
+}
+
+// writer assembles numbered objects and the cross-reference table.
+type writer struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *writer) object(id int, body string) {
+	for len(w.offsets) < id {
+		w.offsets = append(w.offsets, 0)
+	}
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (w *writer) finish() []byte {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, off := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, xref)
+	return w.buf.Bytes()
+}
+
+// literal encodes s as a PDF string in WinAnsi, replacing characters
+// outside Latin-1 with '?'.
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 0x20 && r < 0x7F, r >= 0xA0 && r <= 0xFF:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func num(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
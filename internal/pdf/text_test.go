@@ -0,0 +1,56 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTextStructure(t *testing.T) {
+	long := make([]string, 100)
+	for i := range long {
+		long[i] = fmt.Sprintf("LINE %d", i+1)
+	}
+	doc := Text("REPORT (1)", [][]string{{"HELLO (WORLD) \\ café ☃"}, long})
+
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatalf("document is missing the PDF header or trailer")
+	}
+	s := string(doc)
+	if !strings.Contains(s, "/Title (REPORT \\(1\\))") {
+		t.Fatalf("title not escaped in %q", s[:200])
+	}
+	if !strings.Contains(s, "(HELLO \\(WORLD\\) \\\\ caf\xe9 ?) Tj") {
+		t.Fatalf("text line not encoded as WinAnsi")
+	}
+	// 100 lines at 10pt need two portrait pages, plus the first job page.
+	if !strings.Contains(s, "/Count 3") {
+		t.Fatalf("want 3 pages, got %s", regexp.MustCompile(`/Count \d+`).FindString(s))
+	}
+
+	// Every xref entry must point at its object.
+	xref := regexp.MustCompile(`(?s)startxref\n(\d+)`).FindStringSubmatch(s)
+	start, _ := strconv.Atoi(xref[1])
+	lines := strings.Split(s[start:], "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for id := 1; id < count; id++ {
+		off, _ := strconv.Atoi(strings.Fields(lines[2+id])[0])
+		if want := fmt.Sprintf("%d 0 obj", id); !strings.HasPrefix(s[off:], want) {
+			t.Fatalf("xref offset for object %d points at %q", id, s[off:off+10])
+		}
+	}
+}
+
+func TestTextWideLinesUseLandscape(t *testing.T) {
+	doc := string(Text("", [][]string{{strings.Repeat("X", 132)}}))
+	if !strings.Contains(doc, "/MediaBox [0 0 792 612]") {
+		t.Fatalf("132-column job not landscape")
+	}
+	doc = string(Text("", nil))
+	if !strings.Contains(doc, "/Count 1") || !strings.Contains(doc, "/MediaBox [0 0 612 792]") {
+		t.Fatalf("empty job should be one portrait page")
+	}
+}
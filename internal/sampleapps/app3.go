@@ -0,0 +1,153 @@
+package sampleapps
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racingmars/go3270"
+)
+
+var app3Screen = go3270.Screen{
+	{Row: 0, Col: 28, Intense: true, Content: "3270 Print Demo"},
+	{Row: 2, Col: 0, Content: "This application prints a report to the printer session associated"},
+	{Row: 3, Col: 0, Content: "with your terminal LU, like a CICS or TSO job sending output to a 3287."},
+	{Row: 5, Col: 0, Content: "Terminal LU . . ."},
+	{Row: 5, Col: 19, Name: "termlu", Intense: true},
+	{Row: 6, Col: 0, Content: "Printer LU  . . ."},
+	{Row: 6, Col: 19, Name: "printlu", Intense: true},
+	{Row: 8, Col: 0, Content: "Report title  . ."},
+	{Row: 8, Col: 19, Name: "title", Write: true, Highlighting: go3270.Underscore},
+	{Row: 8, Col: 50, Autoskip: true},
+	{Row: 9, Col: 0, Content: "Copies (1-3) . . ."},
+	{Row: 9, Col: 19, Name: "copies", Write: true, Highlighting: go3270.Underscore},
+	{Row: 9, Col: 21, Autoskip: true},
+	{Row: 11, Col: 0, Content: "Press"},
+	{Row: 11, Col: 6, Intense: true, Content: "PF4"},
+	{Row: 11, Col: 10, Content: "to print the report."},
+	{Row: 13, Col: 0, Intense: true, Color: go3270.Red, Name: "errormsg"},
+	{Row: 14, Col: 0, Intense: true, Color: go3270.Green, Name: "message"},
+	{Row: 22, Col: 0, Content: "PF3 Exit  PF4 Print  ENTER Refresh"},
+}
+
+// app3Sales is the data behind the printed report.
+var app3Sales = []struct {
+	region string
+	units  int
+	amount float64
+}{
+	{"NORTH", 1250, 18750.00},
+	{"SOUTH", 980, 14210.50},
+	{"EAST", 1432, 22912.00},
+	{"WEST", 1105, 16022.75},
+	{"CENTRAL", 864, 12960.00},
+}
+
+// app3Report builds one page of the sample sales report.
+func app3Report(title string, page, copies int, printed time.Time) []string {
+	lines := []string{
+		fmt.Sprintf("%-40s%40s", title, "PAGE "+strconv.Itoa(page)+" OF "+strconv.Itoa(copies)),
+		"PRINTED " + printed.Format("2006-01-02 15:04:05"),
+		"",
+		fmt.Sprintf("%-12s%12s%16s", "REGION", "UNITS", "AMOUNT"),
+		strings.Repeat("-", 40),
+	}
+	units, amount := 0, 0.0
+	for _, row := range app3Sales {
+		lines = append(lines, fmt.Sprintf("%-12s%12d%16.2f", row.region, row.units, row.amount))
+		units += row.units
+		amount += row.amount
+	}
+	lines = append(lines,
+		strings.Repeat("-", 40),
+		fmt.Sprintf("%-12s%12d%16.2f", "TOTAL", units, amount),
+		"",
+		"*** END OF REPORT ***",
+	)
+	return lines
+}
+
+// handleApp3 serves the print demo. Terminals negotiate TN3270E to get an
+// LU name; a printer session associated with that LU receives the report.
+func (s *Server) handleApp3(conn net.Conn) {
+	defer conn.Close()
+
+	session, err := s.negotiateTN3270E(conn)
+	if err != nil {
+		return
+	}
+	if session != nil && session.printer != nil {
+		s.servePrinter(conn, session)
+		return
+	}
+	var screenConn net.Conn = conn
+	termLU := ""
+	if session != nil {
+		termLU = session.lu
+		screenConn = newTN3270EConn(conn)
+		defer s.releaseLU(termLU)
+	} else if _, err := go3270.NegotiateTelnet(conn); err != nil {
+		return
+	}
+
+	fieldValues := map[string]string{"title": "DAILY SALES REPORT", "copies": "1"}
+	for {
+		fieldValues["termlu"] = termLU
+		fieldValues["printlu"] = "none associated"
+		if termLU == "" {
+			fieldValues["termlu"] = "none (TN3270E not negotiated)"
+		}
+		printer := s.printerFor(termLU)
+		if printer != nil {
+			fieldValues["printlu"] = printer.lu
+		}
+
+		response, err := go3270.ShowScreen(app3Screen, fieldValues, 8, 19, screenConn)
+		if err != nil {
+			return
+		}
+		if response.AID == go3270.AIDPF3 {
+			return
+		}
+		// Only modified fields come back; keep the rest as displayed.
+		for _, name := range []string{"title", "copies"} {
+			if value, ok := response.Values[name]; ok {
+				fieldValues[name] = value
+			}
+		}
+		fieldValues["errormsg"] = ""
+		fieldValues["message"] = ""
+		if response.AID != go3270.AIDPF4 {
+			continue
+		}
+
+		title := strings.ToUpper(strings.TrimSpace(fieldValues["title"]))
+		if title == "" {
+			fieldValues["errormsg"] = "Report title is required."
+			continue
+		}
+		copies, err := strconv.Atoi(strings.TrimSpace(fieldValues["copies"]))
+		if err != nil || copies < 1 || copies > 3 {
+			fieldValues["errormsg"] = "Copies must be 1, 2 or 3."
+			continue
+		}
+		// The printer may have connected since the screen was drawn.
+		printer = s.printerFor(termLU)
+		if printer == nil {
+			fieldValues["errormsg"] = "No printer session is associated with this terminal."
+			continue
+		}
+		now := time.Now()
+		pages := make([][]string, 0, copies)
+		for i := 1; i <= copies; i++ {
+			pages = append(pages, app3Report(title, i, copies, now))
+		}
+		if err := printer.print(pages); err != nil {
+			fieldValues["errormsg"] = "Printing failed: " + err.Error()
+			continue
+		}
+		fieldValues["message"] = fmt.Sprintf("Report sent to printer %s (%d page(s)).", printer.lu, copies)
+	}
+}
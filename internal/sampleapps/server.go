@@ -14,12 +14,24 @@ type Server struct {
 	listener net.Listener
 	stopOnce sync.Once
 	done     chan struct{}
+
+	mu       sync.Mutex // Protects the LU registry below
+	nextLU   int
+	lus      map[string]bool
+	printers map[string]*samplePrinter // keyed by associated terminal LU
 }
 
 type handler func(net.Conn)
 
 func StartServer(appID string, port int) (*Server, error) {
-	appHandler := handlerFor(appID)
+	server := &Server{
+		appID:    appID,
+		port:     port,
+		done:     make(chan struct{}),
+		lus:      make(map[string]bool),
+		printers: make(map[string]*samplePrinter),
+	}
+	appHandler := server.handlerFor(appID)
 	if appHandler == nil {
 		return nil, fmt.Errorf("unknown sample app %q", appID)
 	}
@@ -27,12 +39,7 @@ func StartServer(appID string, port int) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("sample app %s failed to listen on 127.0.0.1:%d: %w", appID, port, err)
 	}
-	server := &Server{
-		appID:    appID,
-		port:     port,
-		listener: listener,
-		done:     make(chan struct{}),
-	}
+	server.listener = listener
 	go server.serve(appHandler)
 	return server, nil
 }
@@ -65,12 +72,14 @@ func (s *Server) Stop() error {
 	return err
 }
 
-func handlerFor(appID string) handler {
+func (s *Server) handlerFor(appID string) handler {
 	switch appID {
 	case "app1":
 		return handleApp1
 	case "app2":
 		return handleApp2
+	case "app3":
+		return s.handleApp3
 	default:
 		return nil
 	}
@@ -0,0 +1,480 @@
+package sampleapps
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racingmars/go3270"
+)
+
+// Telnet and TN3270E (RFC 2355) codes used by the sample server's TN3270E
+// negotiation.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+	telnetEOR  = 239
+
+	optTN3270E = 40
+
+	tn3270eAssociate  = 0
+	tn3270eConnect    = 1
+	tn3270eDeviceType = 2
+	tn3270eFunctions  = 3
+	tn3270eIs         = 4
+	tn3270eReason     = 5
+	tn3270eReject     = 6
+	tn3270eRequest    = 7
+	tn3270eSend       = 8
+
+	tn3270eReasonInvAssociate = 2
+	tn3270eReasonInvName      = 3
+
+	tn3270eData3270     = 0x00
+	tn3270eDataSCS      = 0x01
+	tn3270eDataPrintEOJ = 0x08
+
+	tn3270eFuncDataStreamCtl = 0x01
+	tn3270eFuncSCSCtlCodes   = 0x03
+
+	scsFF = 0x0C
+	scsNL = 0x15
+)
+
+const negotiationTimeout = 5 * time.Second
+
+// errTN3270ERejected means the client asked for a device the server refused.
+var errTN3270ERejected = errors.New("tn3270e device rejected")
+
+// tn3270eSession is the outcome of a successful TN3270E negotiation.
+type tn3270eSession struct {
+	lu        string
+	printer   *samplePrinter
+	associate string
+	functions map[byte]bool
+}
+
+// negotiateTN3270E offers TN3270E to a new client. It returns nil, nil when
+// the client declines so the caller can fall back to plain tn3270.
+func (s *Server) negotiateTN3270E(conn net.Conn) (*tn3270eSession, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(negotiationTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	if _, err := conn.Write([]byte{telnetIAC, telnetDO, optTN3270E}); err != nil {
+		return nil, err
+	}
+	for {
+		cmd, opt, _, err := readTelnetCommand(conn)
+		if err != nil {
+			return nil, err
+		}
+		if opt != optTN3270E {
+			continue
+		}
+		if cmd == telnetWONT {
+			return nil, nil
+		}
+		if cmd == telnetWILL {
+			break
+		}
+	}
+
+	if err := writeSubnegotiation(conn, []byte{optTN3270E, tn3270eSend, tn3270eDeviceType}); err != nil {
+		return nil, err
+	}
+	sub, err := readTN3270ESub(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(sub) < 2 || sub[0] != tn3270eDeviceType || sub[1] != tn3270eRequest {
+		return nil, fmt.Errorf("unexpected tn3270e message % x", sub)
+	}
+	devType, kind, name := parseDeviceTypeRequest(sub[2:])
+
+	session := &tn3270eSession{}
+	var reason byte
+	printer := strings.HasPrefix(devType, "IBM-3287")
+	if printer {
+		session.associate = name
+		if kind != tn3270eAssociate || !s.hasTerminal(name) {
+			reason = tn3270eReasonInvAssociate
+		} else {
+			session.lu = s.assignLU("SAMPP")
+		}
+	} else if kind == tn3270eConnect && name != "" {
+		if !s.claimLU(name) {
+			reason = tn3270eReasonInvName
+		}
+		session.lu = name
+	} else {
+		session.lu = s.assignLU("SAMPT")
+	}
+	if reason != 0 {
+		_ = writeSubnegotiation(conn, []byte{optTN3270E, tn3270eDeviceType, tn3270eReject, tn3270eReason, reason})
+		return nil, errTN3270ERejected
+	}
+	if printer {
+		// Register before confirming so the terminal can print as soon as
+		// the client considers the session up.
+		session.printer = s.registerPrinter(conn, session)
+	}
+	reply := append([]byte{optTN3270E, tn3270eDeviceType, tn3270eIs}, devType...)
+	reply = append(reply, tn3270eConnect)
+	reply = append(reply, session.lu...)
+	if err := writeSubnegotiation(conn, reply); err != nil {
+		s.releaseLU(session.lu)
+		return nil, err
+	}
+
+	// Agree on the functions this sample server implements: print data
+	// stream control for printers, nothing for terminals.
+	sub, err = readTN3270ESub(conn)
+	if err != nil {
+		s.releaseLU(session.lu)
+		return nil, err
+	}
+	if len(sub) < 2 || sub[0] != tn3270eFunctions || sub[1] != tn3270eRequest {
+		s.releaseLU(session.lu)
+		return nil, fmt.Errorf("unexpected tn3270e message % x", sub)
+	}
+	var agreed []byte
+	for _, fn := range sub[2:] {
+		if printer && (fn == tn3270eFuncDataStreamCtl || fn == tn3270eFuncSCSCtlCodes) {
+			agreed = append(agreed, fn)
+		}
+	}
+	session.setFunctions(agreed)
+	if len(agreed) == len(sub)-2 {
+		err = writeSubnegotiation(conn, append([]byte{optTN3270E, tn3270eFunctions, tn3270eIs}, agreed...))
+	} else {
+		err = writeSubnegotiation(conn, append([]byte{optTN3270E, tn3270eFunctions, tn3270eRequest}, agreed...))
+		if err == nil {
+			sub, err = readTN3270ESub(conn)
+			if err == nil && len(sub) >= 2 && sub[0] == tn3270eFunctions && sub[1] == tn3270eIs {
+				session.setFunctions(sub[2:])
+			}
+		}
+	}
+	if err != nil {
+		s.releaseLU(session.lu)
+		return nil, err
+	}
+	return session, nil
+}
+
+// setFunctions records the agreed functions. It runs before the final
+// negotiation message so a printer is ready the moment the client is.
+func (t *tn3270eSession) setFunctions(functions []byte) {
+	t.functions = map[byte]bool{}
+	for _, fn := range functions {
+		t.functions[fn] = true
+	}
+	if t.printer != nil {
+		t.printer.setEOJ(t.functions[tn3270eFuncDataStreamCtl])
+	}
+}
+
+// parseDeviceTypeRequest splits "type [ASSOCIATE|CONNECT name]".
+func parseDeviceTypeRequest(data []byte) (string, int, string) {
+	for i, b := range data {
+		if b == tn3270eAssociate || b == tn3270eConnect {
+			return string(data[:i]), int(b), string(data[i+1:])
+		}
+	}
+	return string(data), -1, ""
+}
+
+func (s *Server) assignLU(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		s.nextLU++
+		lu := fmt.Sprintf("%s%03d", prefix, s.nextLU)
+		if !s.lus[lu] {
+			s.lus[lu] = true
+			return lu
+		}
+	}
+}
+
+func (s *Server) claimLU(lu string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lus[lu] {
+		return false
+	}
+	s.lus[lu] = true
+	return true
+}
+
+func (s *Server) releaseLU(lu string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lus, lu)
+	delete(s.printers, lu)
+	for assoc, p := range s.printers {
+		if p.lu == lu {
+			delete(s.printers, assoc)
+		}
+	}
+}
+
+func (s *Server) hasTerminal(lu string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lu != "" && s.lus[lu]
+}
+
+// samplePrinter is a printer session associated with a terminal LU.
+type samplePrinter struct {
+	lu   string
+	conn net.Conn
+	eoj  bool
+
+	mu  sync.Mutex
+	seq uint16
+}
+
+// registerPrinter records a printer session under the terminal LU it is
+// associated with.
+func (s *Server) registerPrinter(conn net.Conn, session *tn3270eSession) *samplePrinter {
+	p := &samplePrinter{lu: session.lu, conn: conn}
+	s.mu.Lock()
+	s.printers[session.associate] = p
+	s.mu.Unlock()
+	return p
+}
+
+// servePrinter holds a printer session open until the client disconnects.
+func (s *Server) servePrinter(conn net.Conn, session *tn3270eSession) {
+	defer s.releaseLU(session.lu)
+	buf := make([]byte, 512)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+func (p *samplePrinter) setEOJ(eoj bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.eoj = eoj
+}
+
+// printerFor returns the printer associated with a terminal LU, if any.
+func (s *Server) printerFor(terminalLU string) *samplePrinter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.printers[terminalLU]
+}
+
+// print sends pages of text as one SCS print job.
+func (p *samplePrinter) print(pages [][]string) error {
+	cp := go3270.CodepageBracket()
+	var scs []byte
+	for i, page := range pages {
+		if i > 0 {
+			scs = append(scs, scsFF)
+		}
+		for _, line := range page {
+			scs = append(scs, cp.Encode(line)...)
+			scs = append(scs, scsNL)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.sendRecord(tn3270eDataSCS, scs); err != nil {
+		return err
+	}
+	if p.eoj {
+		return p.sendRecord(tn3270eDataPrintEOJ, nil)
+	}
+	return nil
+}
+
+func (p *samplePrinter) sendRecord(dataType byte, data []byte) error {
+	header := []byte{dataType, 0, 0, byte(p.seq >> 8), byte(p.seq)}
+	p.seq++
+	out := make([]byte, 0, len(header)+len(data)+8)
+	for _, b := range append(header, data...) {
+		out = append(out, b)
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	out = append(out, telnetIAC, telnetEOR)
+	_, err := p.conn.Write(out)
+	return err
+}
+
+// tn3270eConn adapts a TN3270E terminal connection for go3270, which speaks
+// plain tn3270: it adds the 3270-DATA header to outbound records and strips
+// headers from inbound ones.
+type tn3270eConn struct {
+	net.Conn
+
+	inRecord   bool
+	writeIAC   bool
+	readIAC    bool
+	headerLeft int
+	pending    []byte
+}
+
+func newTN3270EConn(conn net.Conn) *tn3270eConn {
+	return &tn3270eConn{Conn: conn, headerLeft: 5}
+}
+
+func (c *tn3270eConn) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p)+5)
+	for _, b := range p {
+		if !c.inRecord {
+			out = append(out, tn3270eData3270, 0, 0, 0, 0)
+			c.inRecord = true
+		}
+		out = append(out, b)
+		if c.writeIAC {
+			c.writeIAC = false
+			if b == telnetEOR {
+				c.inRecord = false
+			}
+		} else if b == telnetIAC {
+			c.writeIAC = true
+		}
+	}
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *tn3270eConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		buf := make([]byte, len(p)+5)
+		n, err := c.Conn.Read(buf)
+		for _, b := range buf[:n] {
+			switch {
+			case c.readIAC:
+				c.readIAC = false
+				switch b {
+				case telnetEOR:
+					c.pending = append(c.pending, telnetIAC, telnetEOR)
+					c.headerLeft = 5
+				case telnetIAC:
+					if c.headerLeft > 0 {
+						c.headerLeft--
+					} else {
+						c.pending = append(c.pending, telnetIAC, telnetIAC)
+					}
+				default:
+					c.pending = append(c.pending, telnetIAC, b)
+				}
+			case b == telnetIAC:
+				c.readIAC = true
+			case c.headerLeft > 0:
+				c.headerLeft--
+			default:
+				c.pending = append(c.pending, b)
+			}
+		}
+		if err != nil && len(c.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func readByte(conn net.Conn) (byte, error) {
+	var b [1]byte
+	if _, err := conn.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readTelnetCommand reads up to the next telnet command, returning its verb,
+// option and, for sub-negotiations, the payload after the option byte.
+func readTelnetCommand(conn net.Conn) (byte, byte, []byte, error) {
+	for {
+		b, err := readByte(conn)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if b != telnetIAC {
+			continue
+		}
+		cmd, err := readByte(conn)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		switch cmd {
+		case telnetDO, telnetDONT, telnetWILL, telnetWONT:
+			opt, err := readByte(conn)
+			return cmd, opt, nil, err
+		case telnetSB:
+			var sub []byte
+			for {
+				b, err := readByte(conn)
+				if err != nil {
+					return 0, 0, nil, err
+				}
+				if b == telnetIAC {
+					next, err := readByte(conn)
+					if err != nil {
+						return 0, 0, nil, err
+					}
+					if next == telnetSE {
+						break
+					}
+					b = next
+				}
+				sub = append(sub, b)
+			}
+			if len(sub) == 0 {
+				continue
+			}
+			return telnetSB, sub[0], sub[1:], nil
+		}
+	}
+}
+
+// readTN3270ESub reads the next TN3270E sub-negotiation, skipping anything
+// else the client sends meanwhile.
+func readTN3270ESub(conn net.Conn) ([]byte, error) {
+	for {
+		cmd, opt, sub, err := readTelnetCommand(conn)
+		if err != nil {
+			return nil, err
+		}
+		if cmd == telnetSB && opt == optTN3270E {
+			return sub, nil
+		}
+		if cmd == telnetWONT && opt == optTN3270E {
+			return nil, fmt.Errorf("client withdrew tn3270e")
+		}
+	}
+}
+
+func writeSubnegotiation(conn net.Conn, payload []byte) error {
+	out := []byte{telnetIAC, telnetSB}
+	for _, b := range payload {
+		out = append(out, b)
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	out = append(out, telnetIAC, telnetSE)
+	_, err := conn.Write(out)
+	return err
+}
@@ -0,0 +1,249 @@
+// Package screenrec writes and reads screen recordings: every screen a
+// session shows and every key sent, with timestamps, for replay in the
+// browser.
+//
+// A recording is gzip-compressed JSON lines. The first line is the Header;
+// each following line is an Event. Screens are stored whole, which gzip
+// compresses well because consecutive screens share most of their text.
+package screenrec
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+// Format identifies screen recordings in the header.
+const (
+	Format  = "3270Web-screen-recording"
+	Version = 1
+)
+
+// FileExt is the file name extension for screen recordings.
+const FileExt = ".3270rec"
+
+// maxEvents bounds how many events Read accepts, so an uploaded file cannot
+// exhaust memory.
+const maxEvents = 200000
+
+// Header describes a recording.
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Started time.Time `json:"started"`
+	Host    string    `json:"host,omitempty"`
+	User    string    `json:"user,omitempty"`
+}
+
+// Field is a field of a recorded screen. Positions are 1-based.
+type Field struct {
+	Row         int  `json:"row"`
+	Column      int  `json:"column"`
+	EndRow      int  `json:"endRow"`
+	EndColumn   int  `json:"endColumn"`
+	Protected   bool `json:"protected,omitempty"`
+	Hidden      bool `json:"hidden,omitempty"`
+	Intensified bool `json:"intensified,omitempty"`
+	Color       int  `json:"color,omitempty"`
+}
+
+// Frame is one screen state. Hidden fields are blanked in Text.
+type Frame struct {
+	Rows      int      `json:"rows"`
+	Columns   int      `json:"columns"`
+	Text      []string `json:"text"`
+	Fields    []Field  `json:"fields,omitempty"`
+	CursorRow int      `json:"cursorRow,omitempty"`
+	CursorCol int      `json:"cursorColumn,omitempty"`
+}
+
+// Event is a screen or a key, At milliseconds after the recording started.
+// Key events carry the input fields sent with the key, with hidden values
+// redacted; field writes through the API are key events without a Key.
+type Event struct {
+	At     int64         `json:"t"`
+	Screen *Frame        `json:"screen,omitempty"`
+	Key    string        `json:"key,omitempty"`
+	Fields []audit.Field `json:"fields,omitempty"`
+}
+
+// Recording is a whole recording as read back.
+type Recording struct {
+	Header Header  `json:"header"`
+	Events []Event `json:"events"`
+}
+
+// NewFrame copies screen into a frame, blanking hidden fields.
+func NewFrame(screen *host.Screen) Frame {
+	f := Frame{
+		Rows:    screen.Height,
+		Columns: screen.Width,
+		Text:    make([]string, len(screen.Buffer)),
+	}
+	rows := make([][]rune, len(screen.Buffer))
+	for y, row := range screen.Buffer {
+		line := make([]rune, len(row))
+		for x, r := range row {
+			if r == 0 {
+				r = ' '
+			}
+			line[x] = r
+		}
+		rows[y] = line
+	}
+	for _, field := range screen.Fields {
+		if field.IsHidden() {
+			blank(rows, field)
+		}
+		f.Fields = append(f.Fields, Field{
+			Row:         field.StartY + 1,
+			Column:      field.StartX + 1,
+			EndRow:      field.EndY + 1,
+			EndColumn:   field.EndX + 1,
+			Protected:   field.IsProtected(),
+			Hidden:      field.IsHidden(),
+			Intensified: field.IsIntensified(),
+			Color:       field.Color,
+		})
+	}
+	for y, line := range rows {
+		f.Text[y] = string(line)
+	}
+	if row, col, ok := screen.StatusCursor(); ok {
+		f.CursorRow, f.CursorCol = row+1, col+1
+	}
+	return f
+}
+
+// blank overwrites the field's positions with spaces, wrapping across rows.
+func blank(rows [][]rune, f *host.Field) {
+	for y := f.StartY; y <= f.EndY && y < len(rows); y++ {
+		if y < 0 {
+			continue
+		}
+		from, to := 0, len(rows[y])-1
+		if y == f.StartY {
+			from = f.StartX
+		}
+		if y == f.EndY && f.EndX < to {
+			to = f.EndX
+		}
+		for x := from; x <= to && x >= 0; x++ {
+			rows[y][x] = ' '
+		}
+	}
+}
+
+// Writer appends events to a recording.
+type Writer struct {
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	started time.Time
+	last    *Frame
+	screens int
+	keys    int
+}
+
+// NewWriter writes h to w and returns a writer for the events. h.Format,
+// h.Version and, when zero, h.Started are filled in.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	h.Format, h.Version = Format, Version
+	if h.Started.IsZero() {
+		h.Started = time.Now()
+	}
+	h.Started = h.Started.UTC()
+	gz := gzip.NewWriter(w)
+	rw := &Writer{gz: gz, enc: json.NewEncoder(gz), started: h.Started}
+	if err := rw.enc.Encode(h); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+func (w *Writer) offset(at time.Time) int64 {
+	ms := at.Sub(w.started).Milliseconds()
+	if ms < 0 {
+		return 0
+	}
+	return ms
+}
+
+// Screen records f unless it matches the last recorded screen. It reports
+// whether the screen was written.
+func (w *Writer) Screen(at time.Time, f Frame) (bool, error) {
+	if w.last != nil && reflect.DeepEqual(*w.last, f) {
+		return false, nil
+	}
+	if err := w.enc.Encode(Event{At: w.offset(at), Screen: &f}); err != nil {
+		return false, err
+	}
+	w.last = &f
+	w.screens++
+	return true, nil
+}
+
+// Key records a key and the fields sent with it.
+func (w *Writer) Key(at time.Time, key string, fields []audit.Field) error {
+	if err := w.enc.Encode(Event{At: w.offset(at), Key: key, Fields: fields}); err != nil {
+		return err
+	}
+	w.keys++
+	return nil
+}
+
+// Counts returns how many screens and keys have been recorded.
+func (w *Writer) Counts() (screens, keys int) {
+	return w.screens, w.keys
+}
+
+// Close flushes the compressed stream. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	return w.gz.Close()
+}
+
+// Read reads a whole recording.
+func Read(r io.Reader) (*Recording, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.New("not a screen recording: expected gzip data")
+	}
+	defer gz.Close()
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read screen recording: %w", err)
+		}
+		return nil, errors.New("screen recording is empty")
+	}
+	rec := &Recording{}
+	if err := json.Unmarshal(scanner.Bytes(), &rec.Header); err != nil || rec.Header.Format != Format {
+		return nil, errors.New("not a screen recording: missing header")
+	}
+	if rec.Header.Version > Version {
+		return nil, fmt.Errorf("screen recording version %d is newer than this version of 3270Web supports", rec.Header.Version)
+	}
+	for scanner.Scan() {
+		if len(rec.Events) == maxEvents {
+			return nil, fmt.Errorf("screen recording has more than %d events", maxEvents)
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("screen recording event %d: %w", len(rec.Events)+1, err)
+		}
+		rec.Events = append(rec.Events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read screen recording: %w", err)
+	}
+	return rec, nil
+}
@@ -0,0 +1,107 @@
+package screenrec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jnnngs/3270Web/internal/audit"
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+func testScreen(t *testing.T) *host.Screen {
+	t.Helper()
+	mockHost, err := host.NewMockHost("")
+	if err != nil {
+		t.Fatalf("mock host: %v", err)
+	}
+	screen := mockHost.Screen
+	copy(screen.Buffer[0], []rune("SIGN ON"))
+	copy(screen.Buffer[5], []rune("PASSWD SECRET"))
+	screen.Fields = []*host.Field{
+		host.NewField(screen, host.AttrProtected, 0, 0, 6, 0, host.AttrColDefault, host.AttrEhDefault),
+		host.NewField(screen, host.AttrDisp1|host.AttrDisp2, 7, 5, 14, 5, host.AttrColDefault, host.AttrEhDefault),
+	}
+	return screen
+}
+
+func TestNewFrameBlanksHiddenFields(t *testing.T) {
+	f := NewFrame(testScreen(t))
+	if !strings.HasPrefix(f.Text[0], "SIGN ON") {
+		t.Errorf("row 1 = %q, want SIGN ON", f.Text[0])
+	}
+	if strings.Contains(strings.Join(f.Text, "\n"), "SECRET") {
+		t.Error("hidden field value kept in the frame")
+	}
+	if len(f.Fields) != 2 || !f.Fields[1].Hidden || f.Fields[1].Row != 6 || f.Fields[1].Column != 8 {
+		t.Errorf("fields = %+v, want the hidden field at row 6, column 8", f.Fields)
+	}
+}
+
+func TestWriteAndRead(t *testing.T) {
+	started := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	screen := testScreen(t)
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Started: started, Host: "prod:23", User: "alice"})
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if ok, err := w.Screen(started.Add(10*time.Millisecond), NewFrame(screen)); !ok || err != nil {
+		t.Fatalf("first Screen = %v, %v, want written", ok, err)
+	}
+	if ok, _ := w.Screen(started.Add(20*time.Millisecond), NewFrame(screen)); ok {
+		t.Fatal("unchanged screen written twice")
+	}
+	if err := w.Key(started.Add(time.Second), "Enter", []audit.Field{audit.RedactedField(6, 8, "hunter2")}); err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+	copy(screen.Buffer[0], []rune("MAIN MENU"))
+	w.Screen(started.Add(2*time.Second), NewFrame(screen))
+	if screens, keys := w.Counts(); screens != 2 || keys != 1 {
+		t.Fatalf("Counts = %d, %d, want 2 screens and 1 key", screens, keys)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rec, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if rec.Header.Format != Format || rec.Header.Host != "prod:23" || !rec.Header.Started.Equal(started) {
+		t.Errorf("header = %+v", rec.Header)
+	}
+	if len(rec.Events) != 3 {
+		t.Fatalf("events = %d, want 3", len(rec.Events))
+	}
+	if e := rec.Events[0]; e.At != 10 || e.Screen == nil {
+		t.Errorf("event 1 = %+v, want a screen at 10ms", e)
+	}
+	if e := rec.Events[1]; e.At != 1000 || e.Key != "Enter" || len(e.Fields) != 1 || e.Fields[0].Value != audit.Redacted {
+		t.Errorf("event 2 = %+v, want Enter at 1000ms with a redacted field", e)
+	}
+	if e := rec.Events[2]; e.At != 2000 || e.Screen == nil || !strings.HasPrefix(e.Screen.Text[0], "MAIN MENU") {
+		t.Errorf("event 3 = %+v, want MAIN MENU at 2000ms", e)
+	}
+}
+
+func TestReadRejectsOtherFiles(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`{"format":"something-else"}` + "\n"))
+	zw.Close()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"plain text", []byte("not gzip")},
+		{"other format", gz.Bytes()},
+	}
+	for _, tt := range tests {
+		if _, err := Read(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: Read succeeded, want an error", tt.name)
+		}
+	}
+}
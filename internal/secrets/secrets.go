@@ -0,0 +1,127 @@
+// Package secrets keeps passwords out of recordings and chaos output. Input
+// typed into hidden fields is recorded as a ${secret:NAME} placeholder, and
+// playback resolves placeholders from environment variables or an encrypted
+// local secrets file.
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+// EnvPrefix prefixes the environment variable that supplies a secret, so
+// ${secret:PASSWD} reads APP_SECRET_PASSWD.
+const EnvPrefix = "APP_SECRET_"
+
+var (
+	placeholderPattern = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_.-]+)\}`)
+	namePattern        = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	labelWordPattern   = regexp.MustCompile(`[A-Za-z0-9]+`)
+)
+
+// Placeholder returns the placeholder recorded in place of a secret value.
+func Placeholder(name string) string {
+	return "${secret:" + name + "}"
+}
+
+// HasPlaceholder reports whether text contains a secret placeholder.
+func HasPlaceholder(text string) bool {
+	return placeholderPattern.MatchString(text)
+}
+
+// Names returns the secret names text refers to.
+func Names(text string) []string {
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// ValidName reports whether name can be used in a placeholder.
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// EnvName returns the environment variable that supplies the named secret.
+func EnvName(name string) string {
+	upper := strings.ToUpper(name)
+	return EnvPrefix + strings.NewReplacer(".", "_", "-", "_").Replace(upper)
+}
+
+// Resolve substitutes every placeholder in text using lookup. It fails on the
+// first secret lookup cannot supply, naming where it can be set.
+func Resolve(text string, lookup func(name string) (string, bool)) (string, error) {
+	var missing string
+	resolved := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		value, ok := lookup(name)
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return match
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("secret %s is not set: set %s or add it to the secrets file", missing, EnvName(missing))
+	}
+	return resolved, nil
+}
+
+// FieldName names the secret for a hidden field after the label to its left,
+// such as NEW_PASSWORD for "New Password ===>". Input fields earlier on the
+// row and text before a wide gap are not part of the label. Fields without a
+// label are named after their 1-based position, for example FIELD_R6C10.
+func FieldName(screen *host.Screen, f *host.Field) string {
+	if words := labelWordPattern.FindAllString(fieldLabel(screen, f), -1); len(words) > 0 {
+		return strings.ToUpper(strings.Join(words, "_"))
+	}
+	if f == nil {
+		return "FIELD"
+	}
+	return fmt.Sprintf("FIELD_R%dC%d", f.StartY+1, f.StartX+1)
+}
+
+// fieldLabel returns the text between f and whatever precedes its label on
+// the same row: the nearest input field or a run of two or more blanks.
+func fieldLabel(screen *host.Screen, f *host.Field) string {
+	if screen == nil || f == nil || f.StartY < 0 || f.StartY >= len(screen.Buffer) {
+		return ""
+	}
+	line := screen.Buffer[f.StartY]
+	start, end := 0, min(f.StartX, len(line))
+	for _, other := range screen.Fields {
+		if other == f || other.IsProtected() || other.EndY != f.StartY {
+			continue
+		}
+		if other.EndX < end && other.EndX+1 > start {
+			start = other.EndX + 1
+		}
+	}
+	if start >= end {
+		return ""
+	}
+	label := strings.TrimRight(strings.ReplaceAll(string(line[start:end]), "\x00", " "), " ")
+	if i := strings.LastIndex(label, "  "); i >= 0 {
+		label = label[i:]
+	}
+	return label
+}
+
+// UniqueName returns name, or name with the lowest numeric suffix from 2 up
+// that taken rejects, so fields sharing a label keep separate secrets.
+func UniqueName(name string, taken func(name string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	for n := 2; ; n++ {
+		if candidate := fmt.Sprintf("%s_%d", name, n); !taken(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jnnngs/3270Web/internal/host"
+)
+
+func TestResolve(t *testing.T) {
+	values := map[string]string{"PASSWD": "s3cret", "db.pass": "x$1"}
+	lookup := func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+	tests := []struct {
+		text    string
+		want    string
+		wantErr string
+	}{
+		{text: "plain", want: "plain"},
+		{text: "${secret:PASSWD}", want: "s3cret"},
+		{text: "a${secret:db.pass}b", want: "ax$1b"},
+		{text: "${name}", want: "${name}"},
+		{text: "${secret:MISSING}", wantErr: "secret MISSING is not set: set APP_SECRET_MISSING"},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(tt.text, lookup)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Resolve(%q) error = %v, want %q", tt.text, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("Resolve(%q) = %q, %v, want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("db.user-pass"); got != "APP_SECRET_DB_USER_PASS" {
+		t.Fatalf("EnvName = %q, want APP_SECRET_DB_USER_PASS", got)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	screen := &host.Screen{Buffer: [][]rune{
+		[]rune("Password ===> ________"),
+		[]rune("        "),
+		[]rune("New Password ________   Confirm Password ________"),
+		[]rune("Userid ALICE Password ________"),
+	}}
+	user := &host.Field{StartX: 7, StartY: 3, EndX: 11, EndY: 3}
+	screen.Fields = []*host.Field{user}
+	tests := []struct {
+		field *host.Field
+		want  string
+	}{
+		{field: &host.Field{StartX: 14, StartY: 0}, want: "PASSWORD"},
+		{field: &host.Field{StartX: 4, StartY: 1}, want: "FIELD_R2C5"},
+		{field: &host.Field{StartX: 13, StartY: 2}, want: "NEW_PASSWORD"},
+		{field: &host.Field{StartX: 41, StartY: 2}, want: "CONFIRM_PASSWORD"},
+		{field: &host.Field{StartX: 22, StartY: 3}, want: "PASSWORD"},
+	}
+	for _, tt := range tests {
+		if got := FieldName(screen, tt.field); got != tt.want {
+			t.Fatalf("FieldName at %d,%d = %q, want %q", tt.field.StartY, tt.field.StartX, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	taken := map[string]bool{"PASSWORD": true, "PASSWORD_2": true}
+	lookup := func(name string) bool { return taken[name] }
+	if got := UniqueName("PIN", lookup); got != "PIN" {
+		t.Fatalf("UniqueName(PIN) = %q, want PIN", got)
+	}
+	if got := UniqueName("PASSWORD", lookup); got != "PASSWORD_3" {
+		t.Fatalf("UniqueName(PASSWORD) = %q, want PASSWORD_3", got)
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	store, err := Open(path, "passphrase")
+	if err != nil {
+		t.Fatalf("Open new: %v", err)
+	}
+	if err := store.Set("PASSWD", "s3cret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set("bad name", "x"); err == nil {
+		t.Fatal("Set accepted an invalid name")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Fatal("secrets file contains the plaintext value")
+	}
+
+	reopened, err := Open(path, "passphrase")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if v, ok := reopened.Get("PASSWD"); !ok || v != "s3cret" {
+		t.Fatalf("Get = %q, %v, want s3cret, true", v, ok)
+	}
+	if _, err := Open(path, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Fatalf("Open with wrong key error = %v, want wrong key", err)
+	}
+	if _, err := Open(path, ""); err != ErrNoKey {
+		t.Fatalf("Open without key error = %v, want ErrNoKey", err)
+	}
+
+	if existed, err := reopened.Delete("PASSWD"); !existed || err != nil {
+		t.Fatalf("Delete = %v, %v, want true, nil", existed, err)
+	}
+	if names := reopened.Names(); len(names) != 0 {
+		t.Fatalf("Names after delete = %v, want none", names)
+	}
+}
+
+func TestStoreAddAllKeepsExisting(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "secrets.json"), "passphrase")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := store.Set("PASSWD", "first"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	existing, err := store.AddAll(map[string]string{"PASSWD": "second", "PASSWD_2": "second"})
+	if err != nil || len(existing) != 1 || existing[0] != "PASSWD" {
+		t.Fatalf("AddAll = %v, %v, want PASSWD reported as existing", existing, err)
+	}
+	if v, _ := store.Get("PASSWD"); v != "first" {
+		t.Fatalf("PASSWD = %q, want the stored value kept", v)
+	}
+	if v, _ := store.Get("PASSWD_2"); v != "second" {
+		t.Fatalf("PASSWD_2 = %q, want the new value added", v)
+	}
+}
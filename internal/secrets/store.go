@@ -0,0 +1,217 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	fileVersion   = 1
+	kdfIterations = 600000
+	saltSize      = 16
+	keySize       = 32
+)
+
+// ErrNoKey is returned when the secrets file is used without a passphrase.
+var ErrNoKey = errors.New("secrets key is not set")
+
+// encryptedFile is the on-disk form: the secret map as JSON, sealed with
+// AES-256-GCM under a key derived from the passphrase with PBKDF2-SHA256.
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Store is a passphrase-protected set of named secrets backed by one file.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	salt   []byte
+	aead   cipher.AEAD
+	values map[string]string
+}
+
+// Open decrypts the secrets file at path, or returns an empty store if the
+// file does not exist yet. The file is only written by Set and Delete.
+func Open(path, passphrase string) (*Store, error) {
+	if passphrase == "" {
+		return nil, ErrNoKey
+	}
+	store := &Store{path: path, values: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		store.salt = make([]byte, saltSize)
+		if _, err := rand.Read(store.salt); err != nil {
+			return nil, err
+		}
+		if store.aead, err = newAEAD(passphrase, store.salt); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse secrets file: %w", err)
+	}
+	if file.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported secrets file version %d", file.Version)
+	}
+	store.salt = file.Salt
+	if store.aead, err = newAEAD(passphrase, file.Salt); err != nil {
+		return nil, err
+	}
+	plain, err := store.aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, errors.New("decrypt secrets file: wrong key or corrupted file")
+	}
+	if err := json.Unmarshal(plain, &store.values); err != nil {
+		return nil, fmt.Errorf("parse secrets: %w", err)
+	}
+	return store, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Get returns the named secret.
+func (s *Store) Get(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// Names returns the stored secret names in order.
+func (s *Store) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.values))
+	for name := range s.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set stores the named secret and rewrites the file.
+func (s *Store) Set(name, value string) error {
+	return s.SetAll(map[string]string{name: value})
+}
+
+// SetAll stores every named secret in values and rewrites the file once.
+func (s *Store) SetAll(values map[string]string) error {
+	for name := range values {
+		if !ValidName(name) {
+			return fmt.Errorf("invalid secret name %q: use letters, digits, '.', '_' or '-'", name)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for name, value := range values {
+		if current, ok := s.values[name]; ok && current == value {
+			continue
+		}
+		s.values[name] = value
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// AddAll stores the secrets in values whose names are not stored yet and
+// rewrites the file once. Existing entries are never replaced; their names
+// are returned in order.
+func (s *Store) AddAll(values map[string]string) ([]string, error) {
+	for name := range values {
+		if !ValidName(name) {
+			return nil, fmt.Errorf("invalid secret name %q: use letters, digits, '.', '_' or '-'", name)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var existing []string
+	added := false
+	for name, value := range values {
+		if _, ok := s.values[name]; ok {
+			existing = append(existing, name)
+			continue
+		}
+		s.values[name] = value
+		added = true
+	}
+	sort.Strings(existing)
+	if !added {
+		return existing, nil
+	}
+	return existing, s.saveLocked()
+}
+
+// Delete removes the named secret and rewrites the file. It reports whether
+// the secret existed.
+func (s *Store) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[name]; !ok {
+		return false, nil
+	}
+	delete(s.values, name)
+	return true, s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	plain, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(encryptedFile{
+		Version: fileVersion,
+		Salt:    s.salt,
+		Nonce:   nonce,
+		Data:    s.aead.Seal(nil, nonce, plain, nil),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
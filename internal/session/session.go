@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/jnnngs/3270Web/internal/audit"
 	"github.com/jnnngs/3270Web/internal/host"
 )
 
@@ -14,17 +15,24 @@ import (
 type Session struct {
 	mu                       sync.Mutex
 	ID                       string
+	Name                     string
 	Host                     host.Host
 	LastAccess               time.Time
 	Prefs                    Preferences
 	TargetHost               string
 	TargetPort               int
+	HostEngine               string
+	Profile                  string
+	APIOwned                 bool
+	User                     string
+	ClientAddr               string
 	Recording                *WorkflowRecording
 	Playback                 *WorkflowPlayback
 	Chaos                    *ChaosState
 	LoadedWorkflow           *LoadedWorkflow
 	PlaybackCompletedAt      time.Time
 	PlaybackEvents           []WorkflowEvent
+	PlaybackResult           *WorkflowResult
 	LastPlaybackStep         int
 	LastPlaybackStepType     string
 	LastPlaybackStepTotal    int
@@ -54,13 +62,24 @@ type WorkflowStep struct {
 	Type        string               `json:"Type"`
 	Coordinates *WorkflowCoordinates `json:"Coordinates,omitempty"`
 	Text        string               `json:"Text,omitempty"`
+	Regex       string               `json:"Regex,omitempty"`
+	Timeout     float64              `json:"Timeout,omitempty"`
 	StepDelay   *WorkflowDelayRange  `json:"StepDelay,omitempty"`
+	Expect      *WorkflowScreen      `json:"Expect,omitempty"`
+}
+
+// WorkflowScreen is the screen a step was recorded on. Text holds the rows
+// with input fields blanked; Fingerprint is a hash of Text.
+type WorkflowScreen struct {
+	Fingerprint string   `json:"Fingerprint"`
+	Text        []string `json:"Text"`
 }
 
 type WorkflowRecording struct {
 	Active         bool
 	Host           string
 	Port           int
+	Profile        string
 	OutputFilePath string
 	Steps          []WorkflowStep
 	FilePath       string
@@ -69,6 +88,7 @@ type WorkflowRecording struct {
 	DelayMin       float64
 	DelayMax       float64
 	DelaySamples   int
+	CaptureScreens bool
 }
 
 type WorkflowPlayback struct {
@@ -81,10 +101,18 @@ type WorkflowPlayback struct {
 	CurrentStep      int
 	CurrentStepType  string
 	TotalSteps       int
+	CurrentRow       int
+	TotalRows        int
 	StepRequested    bool
 	CurrentDelayMin  float64
 	CurrentDelayMax  float64
 	CurrentDelayUsed time.Duration
+	// AuditFields holds the fills made since the last key, as they will
+	// appear in the audit log.
+	AuditFields []audit.Field
+	// Secrets, when not nil, is the only source for ${secret:NAME}
+	// placeholders, as when replaying the steps of a chaos run.
+	Secrets map[string]string
 }
 
 type WorkflowEvent struct {
@@ -92,6 +120,35 @@ type WorkflowEvent struct {
 	Message string
 }
 
+// WorkflowResult is the structured outcome of a playback, kept for reports.
+type WorkflowResult struct {
+	Name       string
+	Host       string
+	Mode       string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Steps      []WorkflowStepResult
+}
+
+// WorkflowStepResult records one executed, failed or skipped step. Row is the
+// 1-based dataset row, or 0 when the workflow has no dataset.
+type WorkflowStepResult struct {
+	Row      int
+	Step     int
+	Type     string
+	Status   string
+	Duration time.Duration
+	Error    string
+	Screen   string
+	Drift    string
+}
+
+const (
+	StepPassed  = "passed"
+	StepFailed  = "failed"
+	StepSkipped = "skipped"
+)
+
 type LoadedWorkflow struct {
 	Name     string
 	Payload  []byte
@@ -141,6 +198,7 @@ type ChaosAttempt struct {
 	Transitioned   bool
 	Error          string
 	FieldWrites    []ChaosFieldWrite
+	NavigateTo     string
 }
 
 // Manager manages sessions.
@@ -169,6 +227,28 @@ func (m *Manager) GetSession(id string) (*Session, bool) {
 	return s, ok
 }
 
+// PeekSession retrieves a session by ID without counting as activity, for
+// background polling that should not keep an idle session alive.
+func (m *Manager) PeekSession(id string) (*Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+// IdleSessions returns the sessions whose last access is older than timeout.
+func (m *Manager) IdleSessions(timeout time.Duration, now time.Time) []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var idle []*Session
+	for _, s := range m.sessions {
+		if s.IdleFor(now) > timeout {
+			idle = append(idle, s)
+		}
+	}
+	return idle
+}
+
 // CreateSession creates a new session with the given host.
 func (m *Manager) CreateSession(h host.Host) *Session {
 	id := generateID()
@@ -200,6 +280,20 @@ func generateID() string {
 	return hex.EncodeToString(b)
 }
 
+// Touch records activity on the session.
+func (s *Session) Touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastAccess = time.Now()
+}
+
+// IdleFor returns how long the session has been without activity at now.
+func (s *Session) IdleFor(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return now.Sub(s.LastAccess)
+}
+
 // Lock guards session state mutations.
 func (s *Session) Lock() {
 	if s == nil {
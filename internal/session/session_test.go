@@ -98,3 +98,42 @@ func TestManager_GetSession_ConcurrentAccess(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestManager_PeekSessionDoesNotTouch(t *testing.T) {
+	m := NewManager()
+	h, _ := host.NewMockHost("")
+	s := m.CreateSession(h)
+	stale := time.Now().Add(-time.Hour)
+	s.mu.Lock()
+	s.LastAccess = stale
+	s.mu.Unlock()
+
+	if got, ok := m.PeekSession(s.ID); !ok || got != s {
+		t.Fatalf("PeekSession = %v, %v; want session", got, ok)
+	}
+	if !s.LastAccess.Equal(stale) {
+		t.Fatalf("LastAccess = %v after PeekSession, want unchanged %v", s.LastAccess, stale)
+	}
+	m.GetSession(s.ID)
+	if s.LastAccess.Equal(stale) {
+		t.Fatal("LastAccess unchanged after GetSession")
+	}
+}
+
+func TestManager_IdleSessions(t *testing.T) {
+	m := NewManager()
+	h1, _ := host.NewMockHost("")
+	h2, _ := host.NewMockHost("")
+	idle := m.CreateSession(h1)
+	active := m.CreateSession(h2)
+	now := time.Now()
+	idle.mu.Lock()
+	idle.LastAccess = now.Add(-10 * time.Minute)
+	idle.mu.Unlock()
+	active.Touch()
+
+	got := m.IdleSessions(5*time.Minute, now)
+	if len(got) != 1 || got[0] != idle {
+		t.Fatalf("IdleSessions = %v, want only the idle session", got)
+	}
+}
@@ -0,0 +1,270 @@
+(function () {
+  "use strict";
+
+  // Lists what the chaos findings oracle detected, downloads the workflow
+  // that reproduces each finding, and edits the detection rules.
+  var modal = document.querySelector("[data-chaos-findings-modal]");
+  if (!modal) {
+    return;
+  }
+  var errorBox = modal.querySelector("[data-chaos-findings-error]");
+  var empty = modal.querySelector("[data-chaos-findings-empty]");
+  var layout = modal.querySelector("[data-chaos-findings-layout]");
+  var list = modal.querySelector("[data-chaos-findings-list]");
+  var meta = modal.querySelector("[data-chaos-findings-meta]");
+  var screen = modal.querySelector("[data-chaos-findings-screen]");
+  var workflowButton = modal.querySelector("[data-chaos-findings-workflow]");
+  var exportButton = modal.querySelector("[data-chaos-findings-export]");
+  var rulesList = modal.querySelector("[data-chaos-finding-rules-list]");
+  var rulesStatus = modal.querySelector("[data-chaos-finding-rules-status]");
+  var ruleKinds = [
+    { value: "text", label: "Screen text" },
+    { value: "red-message", label: "Red message" },
+    { value: "keyboard-locked", label: "Keyboard locked" },
+    { value: "disconnect", label: "Disconnect" }
+  ];
+  var findings = [];
+  var selected = null;
+  var defaultRules = [];
+  var lastFocused = null;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function download(name, data) {
+    var url = URL.createObjectURL(new Blob([data], { type: "application/json" }));
+    var a = document.createElement("a");
+    a.href = url;
+    a.download = name;
+    document.body.appendChild(a);
+    a.click();
+    document.body.removeChild(a);
+    URL.revokeObjectURL(url);
+  }
+
+  function select(finding, button) {
+    selected = finding;
+    list.querySelectorAll(".history-item.is-selected").forEach(function (el) {
+      el.classList.remove("is-selected");
+    });
+    button.classList.add("is-selected");
+    var parts = [finding.rule + " at attempt " + finding.attempt];
+    if (finding.occurrences > 1) {
+      parts.push("seen " + finding.occurrences + " times");
+    }
+    parts.push(finding.stepCount + " steps to reproduce");
+    meta.textContent = parts.join(" · ") + (finding.detail ? ": " + finding.detail : ".");
+    screen.textContent = finding.screen || "";
+    screen.hidden = !finding.screen;
+  }
+
+  function renderFindings() {
+    list.textContent = "";
+    exportButton.disabled = findings.length === 0;
+    if (!findings.length) {
+      empty.textContent = "No findings yet. Findings appear here when a chaos run hits a detection rule.";
+      empty.hidden = false;
+      layout.hidden = true;
+      return;
+    }
+    findings.forEach(function (finding) {
+      var li = document.createElement("li");
+      var button = document.createElement("button");
+      button.type = "button";
+      button.className = "history-item";
+      var label = document.createElement("span");
+      label.className = "history-item-label";
+      label.textContent = finding.id + " · " + finding.rule;
+      var title = document.createElement("span");
+      title.className = "history-item-title";
+      title.textContent = finding.detail || finding.kind;
+      button.appendChild(label);
+      button.appendChild(title);
+      button.addEventListener("click", function () {
+        select(finding, button);
+      });
+      li.appendChild(button);
+      list.appendChild(li);
+    });
+    empty.hidden = true;
+    layout.hidden = false;
+    list.querySelector(".history-item").click();
+  }
+
+  function loadFindings() {
+    showError("");
+    return request("/chaos/findings")
+      .then(function (body) {
+        findings = body.findings || [];
+        renderFindings();
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  function setRulesStatus(message, isError) {
+    rulesStatus.textContent = message || "";
+    rulesStatus.style.color = isError ? "#ff9a5a" : "";
+  }
+
+  function addRuleRow(rule) {
+    var row = document.createElement("div");
+    row.className = "chaos-finding-rule";
+    var name = document.createElement("input");
+    name.type = "text";
+    name.value = rule.name || "";
+    name.placeholder = "Name";
+    name.setAttribute("aria-label", "Rule name");
+    name.setAttribute("data-rule-name", "");
+    var kind = document.createElement("select");
+    kind.setAttribute("aria-label", "Rule kind");
+    kind.setAttribute("data-rule-kind", "");
+    ruleKinds.forEach(function (k) {
+      var option = document.createElement("option");
+      option.value = k.value;
+      option.textContent = k.label;
+      kind.appendChild(option);
+    });
+    kind.value = rule.kind || "text";
+    var pattern = document.createElement("input");
+    pattern.type = "text";
+    pattern.value = rule.pattern || "";
+    pattern.placeholder = "Regular expression";
+    pattern.setAttribute("aria-label", "Rule pattern");
+    pattern.setAttribute("data-rule-pattern", "");
+    var remove = document.createElement("button");
+    remove.type = "button";
+    remove.textContent = "Remove";
+    remove.addEventListener("click", function () {
+      row.remove();
+    });
+    function syncPattern() {
+      pattern.disabled = kind.value !== "text";
+    }
+    kind.addEventListener("change", syncPattern);
+    syncPattern();
+    row.appendChild(name);
+    row.appendChild(kind);
+    row.appendChild(pattern);
+    row.appendChild(remove);
+    rulesList.appendChild(row);
+    return row;
+  }
+
+  function renderRules(rules) {
+    rulesList.textContent = "";
+    rules.forEach(addRuleRow);
+  }
+
+  function collectRules() {
+    return Array.prototype.map.call(rulesList.querySelectorAll(".chaos-finding-rule"), function (row) {
+      var rule = {
+        name: row.querySelector("[data-rule-name]").value.trim(),
+        kind: row.querySelector("[data-rule-kind]").value
+      };
+      if (rule.kind === "text") {
+        rule.pattern = row.querySelector("[data-rule-pattern]").value;
+      }
+      return rule;
+    });
+  }
+
+  function loadRules() {
+    setRulesStatus("");
+    request("/chaos/finding-rules")
+      .then(function (body) {
+        defaultRules = body.defaults || [];
+        renderRules(body.rules || []);
+      })
+      .catch(function (err) {
+        setRulesStatus(err.message, true);
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    loadFindings();
+    loadRules();
+    modal.querySelector("[data-chaos-findings-close]:not(.workflow-modal-backdrop)").focus();
+  }
+
+  function close() {
+    modal.hidden = true;
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  workflowButton.addEventListener("click", function () {
+    if (!selected) {
+      return;
+    }
+    var id = selected.id;
+    showError("");
+    fetch("/chaos/findings/" + encodeURIComponent(id) + "/workflow", { credentials: "same-origin" })
+      .then(function (response) {
+        return response.text().then(function (text) {
+          if (!response.ok) {
+            throw new Error(JSON.parse(text).error || "Download failed");
+          }
+          download("chaos-finding-" + id + ".json", text);
+        });
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  });
+  exportButton.addEventListener("click", function () {
+    download("chaos-findings.json", JSON.stringify({ findings: findings }, null, 2));
+  });
+  modal.querySelector("[data-chaos-findings-refresh]").addEventListener("click", loadFindings);
+  modal.querySelector("[data-chaos-finding-rules-add]").addEventListener("click", function () {
+    addRuleRow({ kind: "text" }).querySelector("input").focus();
+  });
+  modal.querySelector("[data-chaos-finding-rules-defaults]").addEventListener("click", function () {
+    renderRules(defaultRules);
+    setRulesStatus("Defaults restored; save to keep them.");
+  });
+  modal.querySelector("[data-chaos-finding-rules-save]").addEventListener("click", function () {
+    request("/chaos/finding-rules", {
+      method: "POST",
+      headers: { Accept: "application/json", "Content-Type": "application/json" },
+      body: JSON.stringify({ rules: collectRules() })
+    })
+      .then(function (body) {
+        renderRules(body.rules || []);
+        setRulesStatus("Rules saved.");
+      })
+      .catch(function (err) {
+        setRulesStatus(err.message, true);
+      });
+  });
+  document.querySelectorAll("[data-chaos-findings-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelectorAll("[data-chaos-findings-close]").forEach(function (button) {
+    button.addEventListener("click", close);
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+})();
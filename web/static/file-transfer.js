@@ -0,0 +1,174 @@
+(function () {
+  "use strict";
+
+  // Runs IND$FILE uploads and downloads on the session's host connection and
+  // shows their progress.
+  var modal = document.querySelector("[data-transfer-modal]");
+  if (!modal) {
+    return;
+  }
+  var form = modal.querySelector("[data-transfer-form]");
+  var startButton = modal.querySelector("[data-transfer-start]");
+  var fileRow = modal.querySelector("[data-transfer-file-row]");
+  var datasetFields = modal.querySelectorAll("[data-transfer-dataset]");
+  var errorBox = modal.querySelector("[data-transfer-error]");
+  var progress = modal.querySelector("[data-transfer-progress]");
+  var bar = modal.querySelector("[data-transfer-bar]");
+  var summary = modal.querySelector("[data-transfer-summary]");
+  var downloadLink = modal.querySelector("[data-transfer-download]");
+  var pollTimer = null;
+  var lastFocused = null;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function direction() {
+    return form.elements.direction.value;
+  }
+
+  function syncFields() {
+    fileRow.hidden = direction() !== "upload";
+    var cics = form.elements.hostType.value === "cics";
+    Array.prototype.forEach.call(datasetFields, function (field) {
+      field.hidden = cics;
+    });
+  }
+
+  function bytes(n) {
+    if (n >= 1048576) {
+      return (n / 1048576).toFixed(1) + " MB";
+    }
+    if (n >= 1024) {
+      return (n / 1024).toFixed(1) + " KB";
+    }
+    return n + " bytes";
+  }
+
+  function render(tr) {
+    if (!tr) {
+      progress.hidden = true;
+      downloadLink.hidden = true;
+      startButton.disabled = false;
+      return;
+    }
+    startButton.disabled = tr.active;
+    progress.hidden = false;
+    var verb = tr.direction === "send" ? "Upload of " + tr.fileName + " to " : "Download of ";
+    var text = verb + tr.hostFile;
+    if (tr.active) {
+      text += tr.direction === "send"
+        ? ": sending " + bytes(tr.totalBytes)
+        : ": " + bytes(tr.bytes) + " received";
+      bar.removeAttribute("value");
+    } else if (tr.error) {
+      text += " failed";
+      bar.value = 0;
+    } else {
+      text += " complete, " + bytes(tr.bytes);
+      bar.max = 1;
+      bar.value = 1;
+    }
+    text += " (" + Math.round(tr.elapsedSec) + "s)";
+    if (tr.message && !tr.error) {
+      text += " - " + tr.message;
+    }
+    summary.textContent = text;
+    showError(tr.error ? tr.error : "");
+    downloadLink.hidden = !tr.downloadReady;
+    if (tr.downloadReady) {
+      downloadLink.textContent = "Save " + tr.fileName;
+    }
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function poll() {
+    window.clearTimeout(pollTimer);
+    request("/transfer/status")
+      .then(function (body) {
+        render(body.transfer);
+        if (!modal.hidden && body.transfer && body.transfer.active) {
+          pollTimer = window.setTimeout(poll, 1000);
+        }
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    showError("");
+    syncFields();
+    poll();
+  }
+
+  function close() {
+    modal.hidden = true;
+    window.clearTimeout(pollTimer);
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  form.addEventListener("change", syncFields);
+
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    showError("");
+    var upload = direction() === "upload";
+    if (upload && !form.elements.file.files.length) {
+      showError("Choose a file to upload.");
+      return;
+    }
+    var data = new FormData(form);
+    data.delete("direction");
+    if (form.elements.hostType.value === "cics") {
+      ["recfm", "lrecl", "blksize", "allocation", "primary", "secondary"].forEach(function (name) {
+        data.delete(name);
+      });
+    }
+    var body = data;
+    if (!upload) {
+      data.delete("file");
+      body = new URLSearchParams();
+      data.forEach(function (value, key) {
+        body.append(key, value);
+      });
+    }
+    startButton.disabled = true;
+    downloadLink.hidden = true;
+    request(upload ? "/transfer/upload" : "/transfer/download", { method: "POST", body: body })
+      .then(poll)
+      .catch(function (err) {
+        startButton.disabled = false;
+        showError(err.message);
+      });
+  });
+
+  document.querySelectorAll("[data-transfer-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelectorAll("[data-transfer-close]").forEach(function (button) {
+    button.addEventListener("click", close);
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+})();
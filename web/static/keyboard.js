@@ -109,7 +109,7 @@
   }
 
   function sendFormWithKey(key, formId, target) {
-    if (submitting) {
+    if (submitting || isScreenReadOnly()) {
       return;
     }
     var form = findForm(formId);
@@ -156,7 +156,40 @@
   }
 
   function submitFormWithoutNavigation(form, formId, preferredFieldName, preferredCaret) {
-    var action = "/submit/async";
+    var request;
+    if (window.screenSocket && window.screenSocket.isOpen()) {
+      request = window.screenSocket.submit(formValues(form));
+    } else {
+      request = submitFormWithFetch(form);
+    }
+    return request
+      .then(function (payload) {
+        applyScreenPayload(payload, formId, preferredFieldName, preferredCaret);
+      })
+      .catch(function () {
+        // Fall back to full form submit if async update fails.
+        form.submit();
+      });
+  }
+
+  function formValues(form) {
+    var values = {};
+    new FormData(form).forEach(function (value, name) {
+      if (typeof value === "string") {
+        values[name] = value;
+      }
+    });
+    return values;
+  }
+
+  // isScreenReadOnly is true while someone else holds the keyboard of a
+  // shared session.
+  function isScreenReadOnly() {
+    return document.body.hasAttribute("data-screen-readonly");
+  }
+
+  function submitFormWithFetch(form) {
+    var action = document.body.getAttribute("data-submit-async") || "/submit/async";
     var method = (form.getAttribute("method") || "post").toUpperCase();
     var body = new URLSearchParams(new FormData(form));
 
@@ -173,7 +206,7 @@
         if (!response.ok) {
           throw new Error("submit failed");
         }
-        return fetch("/screen/content", {
+        return fetch(document.body.getAttribute("data-screen-content") || "/screen/content", {
           headers: {
             Accept: "application/json",
             "Cache-Control": "no-cache"
@@ -186,50 +219,55 @@
           throw new Error("content refresh failed");
         }
         return response.json();
-      })
-      .then(function (payload) {
-        if (!payload || typeof payload.html !== "string") {
-          return;
-        }
-        if (
-          typeof payload.cursorRow === "number" &&
-          isFinite(payload.cursorRow) &&
-          payload.cursorRow >= 0 &&
-          typeof payload.cursorCol === "number" &&
-          isFinite(payload.cursorCol) &&
-          payload.cursorCol >= 0
-        ) {
-          lastKnownCursorRow = payload.cursorRow;
-          lastKnownCursorCol = payload.cursorCol;
-        }
-        var container = document.querySelector(".screen-container");
-        if (!container) {
-          return;
-        }
-        container.innerHTML = payload.html;
-
-        var updatedForm = container.querySelector("form.renderer-form");
-        var updatedFormId = updatedForm ? (updatedForm.id || updatedForm.getAttribute("name")) : formId;
-        if (typeof window.installKeyHandler === "function") {
-          window.installKeyHandler(updatedFormId);
-        }
-        restoreScreenFocus(
-          updatedForm,
-          preferredFieldName,
-          preferredCaret,
-          payload.cursorRow,
-          payload.cursorCol
-        );
-        if (typeof window.sizeScreenContainer === "function") {
-          window.sizeScreenContainer();
-        }
-      })
-      .catch(function () {
-        // Fall back to full form submit if async update fails.
-        form.submit();
       });
   }
 
+  function applyScreenPayload(payload, formId, preferredFieldName, preferredCaret) {
+    var updatedForm = replaceScreen(payload, formId);
+    if (!updatedForm) {
+      return;
+    }
+    restoreScreenFocus(
+      updatedForm,
+      preferredFieldName,
+      preferredCaret,
+      payload.cursorRow,
+      payload.cursorCol
+    );
+  }
+
+  function replaceScreen(payload, formId) {
+    if (!payload || typeof payload.html !== "string") {
+      return null;
+    }
+    if (
+      typeof payload.cursorRow === "number" &&
+      isFinite(payload.cursorRow) &&
+      payload.cursorRow >= 0 &&
+      typeof payload.cursorCol === "number" &&
+      isFinite(payload.cursorCol) &&
+      payload.cursorCol >= 0
+    ) {
+      lastKnownCursorRow = payload.cursorRow;
+      lastKnownCursorCol = payload.cursorCol;
+    }
+    var container = document.querySelector(".screen-container");
+    if (!container) {
+      return null;
+    }
+    container.innerHTML = payload.html;
+
+    var updatedForm = container.querySelector("form.renderer-form");
+    var updatedFormId = updatedForm ? (updatedForm.id || updatedForm.getAttribute("name")) : formId;
+    if (typeof window.installKeyHandler === "function") {
+      window.installKeyHandler(updatedFormId);
+    }
+    if (typeof window.sizeScreenContainer === "function") {
+      window.sizeScreenContainer();
+    }
+    return updatedForm;
+  }
+
   function isCursorNavigationKey(key) {
     if (!key) {
       return false;
@@ -388,6 +426,14 @@
       "[data-about-modal]",
       "[data-chaos-runs-modal]",
       "[data-chaos-hints-modal]",
+      "[data-chaos-findings-modal]",
+      "[data-loadtest-modal]",
+      "[data-transfer-modal]",
+      "[data-printer-modal]",
+      "[data-history-modal]",
+      "[data-screenrec-modal]",
+      "[data-share-modal]",
+      "[data-session-renaming]",
       "[data-modal]"
     ];
     for (var i = 0; i < selectors.length; i++) {
@@ -1450,6 +1496,19 @@
     });
   }
 
+  // applyScreenPayload replaces the screen with a pushed update, keeping focus
+  // on the terminal only if the user was already there.
+  window.applyScreenPayload = function (payload) {
+    var container = document.querySelector(".screen-container");
+    var active = document.activeElement;
+    if (!container || !active || !container.contains(active)) {
+      replaceScreen(payload, null);
+      return;
+    }
+    var caret = typeof active.selectionStart === "number" ? active.selectionStart : null;
+    applyScreenPayload(payload, null, active.name || "", caret);
+  };
+
   window.sendKey = function (key, formId) {
     sendFormWithKey(key, formId);
   };
@@ -0,0 +1,168 @@
+(function () {
+  "use strict";
+
+  // Starts load tests of the loaded recording and shows live statistics.
+  var modal = document.querySelector("[data-loadtest-modal]");
+  if (!modal) {
+    return;
+  }
+  var form = modal.querySelector("[data-loadtest-form]");
+  var startButton = modal.querySelector("[data-loadtest-start]");
+  var stopButton = modal.querySelector("[data-loadtest-stop]");
+  var errorBox = modal.querySelector("[data-loadtest-error]");
+  var summary = modal.querySelector("[data-loadtest-summary]");
+  var table = modal.querySelector("[data-loadtest-table]");
+  var stepsBody = modal.querySelector("[data-loadtest-steps]");
+  var errorList = modal.querySelector("[data-loadtest-errors]");
+  var downloads = modal.querySelector("[data-loadtest-downloads]");
+  var pollTimer = null;
+  var lastFocused = null;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function cell(row, text) {
+    var td = document.createElement("td");
+    td.textContent = text;
+    row.appendChild(td);
+  }
+
+  function ms(value) {
+    return value ? value.toFixed(1) : "-";
+  }
+
+  function render(run) {
+    if (!run) {
+      summary.hidden = true;
+      table.hidden = true;
+      errorList.hidden = true;
+      downloads.hidden = true;
+      startButton.disabled = false;
+      stopButton.hidden = true;
+      return;
+    }
+    startButton.disabled = run.active;
+    stopButton.hidden = !run.active;
+    downloads.hidden = run.active;
+
+    summary.textContent =
+      (run.active ? "Running" : "Finished") +
+      " for " + Math.round(run.elapsedSec) + "s against " + run.host +
+      " | users " + run.usersStarted + "/" + run.users + " started, " + run.usersActive + " active, " +
+      run.usersCompleted + " completed, " + run.usersFailed + " failed" +
+      " | iterations " + run.iterationsCompleted + " passed, " + run.iterationsFailed + " failed" +
+      " | " + run.errors + " errors" +
+      " | " + run.iterationsPerSec.toFixed(2) + " iterations/s, " + run.stepsPerSec.toFixed(2) + " steps/s";
+    summary.hidden = false;
+
+    stepsBody.textContent = "";
+    (run.steps || []).forEach(function (step) {
+      var row = document.createElement("tr");
+      if (step.errors > 0) {
+        row.className = "loadtest-step-error";
+      }
+      cell(row, step.step);
+      cell(row, step.type);
+      cell(row, step.count);
+      cell(row, step.errors);
+      cell(row, ms(step.avgMs));
+      cell(row, ms(step.p50Ms));
+      cell(row, ms(step.p90Ms));
+      cell(row, ms(step.p95Ms));
+      cell(row, ms(step.p99Ms));
+      cell(row, ms(step.maxMs));
+      stepsBody.appendChild(row);
+    });
+    table.hidden = false;
+
+    errorList.textContent = "";
+    (run.recentErrors || []).forEach(function (e) {
+      var item = document.createElement("li");
+      var where = e.step > 0 ? "step " + e.step + " (" + e.type + ")" : e.type;
+      item.textContent = "User " + e.user + ", " + where + ": " + e.message;
+      errorList.appendChild(item);
+    });
+    errorList.hidden = !(run.recentErrors && run.recentErrors.length);
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function poll() {
+    window.clearTimeout(pollTimer);
+    request("/loadtest/status")
+      .then(function (body) {
+        render(body.run);
+        if (!modal.hidden && body.run && body.run.active) {
+          pollTimer = window.setTimeout(poll, 1000);
+        }
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    showError("");
+    poll();
+  }
+
+  function close() {
+    modal.hidden = true;
+    window.clearTimeout(pollTimer);
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    showError("");
+    startButton.disabled = true;
+    request("/loadtest/start", {
+      method: "POST",
+      headers: { Accept: "application/json", "Content-Type": "application/json" },
+      body: JSON.stringify({
+        users: parseInt(form.elements.users.value, 10),
+        iterations: parseInt(form.elements.iterations.value, 10)
+      })
+    })
+      .then(poll)
+      .catch(function (err) {
+        startButton.disabled = false;
+        showError(err.message);
+      });
+  });
+
+  stopButton.addEventListener("click", function () {
+    request("/loadtest/stop", { method: "POST" }).then(poll).catch(function (err) {
+      showError(err.message);
+    });
+  });
+
+  document.querySelectorAll("[data-loadtest-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelectorAll("[data-loadtest-close]").forEach(function (button) {
+    button.addEventListener("click", close);
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+})();
@@ -0,0 +1,181 @@
+(function () {
+  "use strict";
+
+  // Starts and stops the session's printer emulation and lists the print
+  // jobs it has captured for download as text or PDF.
+  var modal = document.querySelector("[data-printer-modal]");
+  if (!modal) {
+    return;
+  }
+  var form = modal.querySelector("[data-printer-form]");
+  var startButton = modal.querySelector("[data-printer-start]");
+  var stopButton = modal.querySelector("[data-printer-stop]");
+  var clearButton = modal.querySelector("[data-printer-clear]");
+  var state = modal.querySelector("[data-printer-state]");
+  var errorBox = modal.querySelector("[data-printer-error]");
+  var table = modal.querySelector("[data-printer-jobs]");
+  var rows = modal.querySelector("[data-printer-job-rows]");
+  var empty = modal.querySelector("[data-printer-empty]");
+  var badge = document.querySelector("[data-printer-badge]");
+  var pollTimer = null;
+  var lastFocused = null;
+  var seenId = -1;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function link(href, text) {
+    var a = document.createElement("a");
+    a.href = href;
+    a.textContent = text;
+    a.setAttribute("download", "");
+    return a;
+  }
+
+  function cell(row, content) {
+    var td = document.createElement("td");
+    if (typeof content === "string") {
+      td.textContent = content;
+    } else {
+      content.forEach(function (node) {
+        td.appendChild(node);
+      });
+    }
+    row.appendChild(td);
+  }
+
+  // updateBadge counts jobs that arrived since the dialog was last open.
+  function updateBadge(printer) {
+    var jobs = printer ? printer.jobs : [];
+    if (seenId < 0 || (!modal.hidden && jobs.length)) {
+      seenId = jobs.length ? jobs[0].id : 0;
+    }
+    var unseen = jobs.filter(function (job) {
+      return job.id > seenId;
+    }).length;
+    badge.hidden = unseen === 0;
+    badge.textContent = unseen ? String(unseen) : "";
+  }
+
+  function render(printer) {
+    if (!printer) {
+      state.textContent = "Printer not started.";
+      startButton.hidden = false;
+      stopButton.hidden = true;
+    } else if (printer.connected) {
+      state.textContent = "Printer " + (printer.lu || "connected") +
+        (printer.associateLu ? " is associated with terminal " + printer.associateLu + "." : " is connected.");
+      startButton.hidden = true;
+      stopButton.hidden = false;
+    } else {
+      state.textContent = "Printer stopped." + (printer.error ? " " + printer.error : "");
+      startButton.hidden = false;
+      stopButton.hidden = true;
+    }
+
+    var jobs = printer ? printer.jobs : [];
+    rows.textContent = "";
+    jobs.forEach(function (job) {
+      var row = document.createElement("tr");
+      cell(row, String(job.id));
+      cell(row, new Date(job.finished).toLocaleString());
+      cell(row, String(job.pages));
+      cell(row, String(job.lines));
+      cell(row, [
+        link("/printer/jobs/" + job.id, "Text"),
+        link("/printer/jobs/" + job.id + "?format=pdf", "PDF")
+      ]);
+      rows.appendChild(row);
+    });
+    table.hidden = jobs.length === 0;
+    empty.hidden = jobs.length > 0;
+    clearButton.hidden = jobs.length === 0;
+    updateBadge(printer);
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  // poll refreshes the job list every few seconds while the printer is
+  // connected, so the toolbar badge shows new jobs with the dialog closed.
+  function poll() {
+    window.clearTimeout(pollTimer);
+    request("/printer/status")
+      .then(function (body) {
+        render(body.printer);
+        if (body.printer && body.printer.connected) {
+          pollTimer = window.setTimeout(poll, modal.hidden ? 5000 : 2000);
+        }
+      })
+      .catch(function (err) {
+        if (!modal.hidden) {
+          showError(err.message);
+        }
+      });
+  }
+
+  function post(url, body) {
+    showError("");
+    return request(url, { method: "POST", body: body || new URLSearchParams() })
+      .then(poll)
+      .catch(function (err) {
+        showError(err.message);
+        poll();
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    showError("");
+    poll();
+  }
+
+  function close() {
+    modal.hidden = true;
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    startButton.disabled = true;
+    state.textContent = "Connecting printer...";
+    post("/printer/start", new URLSearchParams(new FormData(form))).then(function () {
+      startButton.disabled = false;
+    });
+  });
+  stopButton.addEventListener("click", function () {
+    post("/printer/stop");
+  });
+  clearButton.addEventListener("click", function () {
+    post("/printer/clear");
+  });
+
+  document.querySelectorAll("[data-printer-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelectorAll("[data-printer-close]").forEach(function (button) {
+    button.addEventListener("click", close);
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+
+  poll();
+})();
@@ -0,0 +1,204 @@
+(function () {
+  "use strict";
+
+  // Edits the server-side connection profiles and keeps the connect form's
+  // profile picker in step with them.
+  var modal = document.querySelector("[data-profiles-modal]");
+  if (!modal) {
+    return;
+  }
+  var picker = document.querySelector("[data-profile-select]");
+  var hostInput = document.querySelector("[data-host-input]");
+  var list = modal.querySelector("[data-profiles-list]");
+  var form = modal.querySelector("[data-profile-form]");
+  var status = modal.querySelector("[data-profiles-status]");
+  var profiles = [];
+  var lastFocused = null;
+
+  var textFields = ["name", "host", "model", "oversize", "codePage", "lu", "deviceName",
+    "caFile", "certFile", "keyFile", "proxy", "loginMacro"];
+  var checkFields = ["tls", "noVerifyCert"];
+
+  function setStatus(message) {
+    status.textContent = message || "";
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function option(value, text) {
+    var opt = document.createElement("option");
+    opt.value = value;
+    opt.textContent = text;
+    return opt;
+  }
+
+  // syncPicker rebuilds the connect form's picker, keeping its selection
+  // when that profile still exists.
+  function syncPicker() {
+    if (!picker) {
+      return;
+    }
+    var selected = picker.value;
+    picker.textContent = "";
+    picker.appendChild(option("", "No profile"));
+    profiles.forEach(function (p) {
+      picker.appendChild(option(p.name, p.name));
+    });
+    picker.value = profiles.some(function (p) { return p.name === selected; }) ? selected : "";
+    updateHostInput();
+  }
+
+  // A selected profile supplies the host, so the hostname is not needed.
+  function updateHostInput() {
+    if (!picker || !hostInput) {
+      return;
+    }
+    var usingProfile = picker.value !== "";
+    hostInput.disabled = usingProfile;
+    hostInput.required = !usingProfile;
+  }
+
+  function fill(profile) {
+    var p = profile || {};
+    textFields.forEach(function (name) {
+      form.elements[name].value = p[name] || "";
+    });
+    form.elements.port.value = p.port ? String(p.port) : "";
+    checkFields.forEach(function (name) {
+      form.elements[name].checked = !!p[name];
+    });
+  }
+
+  function renderList(selected) {
+    list.textContent = "";
+    list.appendChild(option("", profiles.length ? "New profile" : "No saved profiles yet"));
+    profiles.forEach(function (p) {
+      list.appendChild(option(p.name, p.name + " (" + p.host + (p.port ? ":" + p.port : "") + ")"));
+    });
+    list.value = selected || "";
+    fill(profiles.filter(function (p) { return p.name === list.value; })[0]);
+  }
+
+  function load(selected) {
+    return request("/profiles")
+      .then(function (body) {
+        profiles = body.profiles || [];
+        renderList(selected);
+        syncPicker();
+      })
+      .catch(function (err) {
+        setStatus(err.message);
+      });
+  }
+
+  function collect() {
+    var profile = {};
+    textFields.forEach(function (name) {
+      profile[name] = form.elements[name].value.trim();
+    });
+    profile.port = parseInt(form.elements.port.value, 10) || 0;
+    checkFields.forEach(function (name) {
+      profile[name] = form.elements[name].checked;
+    });
+    return profile;
+  }
+
+  function save() {
+    if (!form.reportValidity()) {
+      return;
+    }
+    var profile = collect();
+    request("/profiles", {
+      method: "POST",
+      headers: { Accept: "application/json", "Content-Type": "application/json" },
+      body: JSON.stringify(profile)
+    })
+      .then(function (body) {
+        return load(body.profile.name).then(function () {
+          setStatus("Profile saved.");
+        });
+      })
+      .catch(function (err) {
+        setStatus(err.message);
+      });
+  }
+
+  function remove() {
+    var name = list.value;
+    if (!name) {
+      setStatus("Select a profile first.");
+      return;
+    }
+    request("/profiles/delete", { method: "POST", body: new URLSearchParams({ name: name }) })
+      .then(function () {
+        return load("").then(function () {
+          setStatus("Profile deleted.");
+        });
+      })
+      .catch(function (err) {
+        setStatus(err.message);
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    setStatus("");
+    load(picker ? picker.value : "");
+    list.focus();
+  }
+
+  function close() {
+    modal.hidden = true;
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  list.addEventListener("change", function () {
+    setStatus("");
+    fill(profiles.filter(function (p) { return p.name === list.value; })[0]);
+  });
+  modal.querySelector("[data-profile-new]").addEventListener("click", function () {
+    list.value = "";
+    fill(null);
+    setStatus("");
+    form.elements.name.focus();
+  });
+  modal.querySelector("[data-profile-save]").addEventListener("click", save);
+  modal.querySelector("[data-profile-delete]").addEventListener("click", remove);
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    save();
+  });
+
+  document.querySelectorAll("[data-profiles-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelector("[data-profiles-close]").addEventListener("click", close);
+  modal.addEventListener("click", function (event) {
+    if (event.target === modal) {
+      close();
+    }
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+  if (picker) {
+    picker.addEventListener("change", updateHostInput);
+    updateHostInput();
+  }
+})();
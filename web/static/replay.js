@@ -0,0 +1,236 @@
+(function () {
+  "use strict";
+
+  // Replays a screen recording: the screens a session showed and the keys
+  // sent, on their original timeline at a chosen speed.
+  var root = document.querySelector("[data-replay]");
+  if (!root) {
+    return;
+  }
+  var loadForm = root.querySelector("[data-replay-load]");
+  var errorBox = root.querySelector("[data-replay-error]");
+  var player = root.querySelector("[data-replay-player]");
+  var meta = root.querySelector("[data-replay-meta]");
+  var playButton = root.querySelector("[data-replay-play]");
+  var prevButton = root.querySelector("[data-replay-prev]");
+  var nextButton = root.querySelector("[data-replay-next]");
+  var seek = root.querySelector("[data-replay-seek]");
+  var timeLabel = root.querySelector("[data-replay-time]");
+  var speedSelect = root.querySelector("[data-replay-speed]");
+  var skipIdle = root.querySelector("[data-replay-skip-idle]");
+  var screen = root.querySelector("[data-replay-screen]");
+  var keyList = root.querySelector("[data-replay-keys]");
+
+  // Pauses longer than idleGap are shortened to idleLead when skipping.
+  var idleGap = 3000;
+  var idleLead = 500;
+
+  var events = [];
+  var screens = [];
+  var keys = [];
+  var duration = 0;
+  var position = 0;
+  var shownScreen = -1;
+  var playing = false;
+  var lastTick = 0;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function formatTime(ms) {
+    var total = Math.floor(ms / 1000);
+    var minutes = Math.floor(total / 60);
+    var seconds = total % 60;
+    return minutes + ":" + (seconds < 10 ? "0" : "") + seconds;
+  }
+
+  function describeFields(fields) {
+    return (fields || []).map(function (field) {
+      var at = field.row ? field.row + "," + field.column + "=" : "";
+      return at + (field.redacted ? "********" : JSON.stringify(field.value));
+    }).join(" ");
+  }
+
+  // lastIndexAtOrBefore finds the last item at or before ms, or -1.
+  function lastIndexAtOrBefore(items, ms) {
+    var found = -1;
+    for (var i = 0; i < items.length && items[i].t <= ms; i++) {
+      found = i;
+    }
+    return found;
+  }
+
+  function renderKeys() {
+    keyList.textContent = "";
+    keys.forEach(function (event) {
+      var li = document.createElement("li");
+      var label = formatTime(event.t) + " " + (event.key || "(field write)");
+      var fields = describeFields(event.fields);
+      li.textContent = fields ? label + " " + fields : label;
+      li.title = "Jump to " + formatTime(event.t);
+      li.addEventListener("click", function () {
+        moveTo(event.t);
+      });
+      keyList.appendChild(li);
+    });
+  }
+
+  function update() {
+    var index = lastIndexAtOrBefore(screens, position);
+    if (index !== shownScreen) {
+      shownScreen = index;
+      if (index >= 0) {
+        window.ScreenView.render(screen, screens[index].screen, 0, { cursor: true });
+      } else {
+        screen.textContent = "";
+      }
+    }
+    var current = lastIndexAtOrBefore(keys, position);
+    keyList.querySelectorAll("li").forEach(function (li, i) {
+      li.classList.toggle("is-past", i < current);
+      li.classList.toggle("is-current", i === current);
+    });
+    var active = keyList.querySelector(".is-current");
+    if (active && playing) {
+      active.scrollIntoView({ block: "nearest" });
+    }
+    seek.value = String(Math.round(position));
+    timeLabel.textContent = formatTime(position) + " / " + formatTime(duration);
+  }
+
+  function moveTo(ms) {
+    position = Math.max(0, Math.min(duration, ms));
+    update();
+  }
+
+  // nextEventAfter returns the time of the first event after ms, or -1.
+  function nextEventAfter(ms) {
+    for (var i = 0; i < events.length; i++) {
+      if (events[i].t > ms) {
+        return events[i].t;
+      }
+    }
+    return -1;
+  }
+
+  function tick(now) {
+    if (!playing) {
+      return;
+    }
+    var elapsed = (now - lastTick) * Number(speedSelect.value);
+    lastTick = now;
+    var next = position + elapsed;
+    if (skipIdle.checked) {
+      var upcoming = nextEventAfter(position);
+      if (upcoming - position > idleGap) {
+        next = Math.max(next, upcoming - idleLead);
+      }
+    }
+    moveTo(next);
+    if (position >= duration) {
+      setPlaying(false);
+      return;
+    }
+    window.requestAnimationFrame(tick);
+  }
+
+  function setPlaying(on) {
+    playing = on && events.length > 0;
+    playButton.textContent = playing ? "Pause" : "Play";
+    if (playing) {
+      if (position >= duration) {
+        moveTo(0);
+      }
+      lastTick = performance.now();
+      window.requestAnimationFrame(tick);
+    }
+  }
+
+  function stepScreen(direction) {
+    setPlaying(false);
+    var index = lastIndexAtOrBefore(screens, position) + direction;
+    if (index >= 0 && index < screens.length) {
+      moveTo(screens[index].t);
+    }
+  }
+
+  function load(recording) {
+    setPlaying(false);
+    events = recording.events || [];
+    screens = events.filter(function (event) {
+      return event.screen;
+    });
+    keys = events.filter(function (event) {
+      return !event.screen;
+    });
+    duration = events.length ? events[events.length - 1].t : 0;
+    shownScreen = -1;
+    var header = recording.header || {};
+    var parts = ["Recorded " + new Date(header.started).toLocaleString()];
+    if (header.host) {
+      parts.push("on " + header.host);
+    }
+    if (header.user) {
+      parts.push("by " + header.user);
+    }
+    meta.textContent = parts.join(" ") + " · " + screens.length + " screens, " +
+      keys.length + " keys, " + formatTime(duration) + ".";
+    seek.max = String(duration);
+    renderKeys();
+    player.hidden = false;
+    moveTo(0);
+  }
+
+  function fetchRecording(url, options) {
+    showError("");
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Could not open the recording");
+          }
+          return body;
+        });
+      })
+      .then(load)
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  loadForm.addEventListener("submit", function (event) {
+    event.preventDefault();
+    fetchRecording("/replay/load", { method: "POST", body: new FormData(loadForm) });
+  });
+  playButton.addEventListener("click", function () {
+    setPlaying(!playing);
+  });
+  prevButton.addEventListener("click", function () {
+    stepScreen(-1);
+  });
+  nextButton.addEventListener("click", function () {
+    stepScreen(1);
+  });
+  seek.addEventListener("input", function () {
+    moveTo(Number(seek.value));
+  });
+  document.addEventListener("keydown", function (event) {
+    if (player.hidden || event.target.closest("input, select, textarea")) {
+      return;
+    }
+    if (event.key === " ") {
+      event.preventDefault();
+      setPlaying(!playing);
+    } else if (event.key === "ArrowLeft") {
+      stepScreen(-1);
+    } else if (event.key === "ArrowRight") {
+      stepScreen(1);
+    }
+  });
+
+  if (root.hasAttribute("data-replay-session")) {
+    fetchRecording("/screenrec/events");
+  }
+})();
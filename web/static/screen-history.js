@@ -0,0 +1,170 @@
+(function () {
+  "use strict";
+
+  // Browses the screens this session has shown, newest first, and searches
+  // their text. Snapshots are read-only; hidden fields arrive blanked.
+  var modal = document.querySelector("[data-history-modal]");
+  if (!modal) {
+    return;
+  }
+  var searchForm = modal.querySelector("[data-history-search]");
+  var searchInput = searchForm.querySelector("input[name=q]");
+  var showAll = modal.querySelector("[data-history-all]");
+  var errorBox = modal.querySelector("[data-history-error]");
+  var empty = modal.querySelector("[data-history-empty]");
+  var layout = modal.querySelector("[data-history-layout]");
+  var list = modal.querySelector("[data-history-list]");
+  var meta = modal.querySelector("[data-history-meta]");
+  var screen = modal.querySelector("[data-history-screen]");
+  var lastFocused = null;
+  var selected = null;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function request(url) {
+    return fetch(url, { credentials: "same-origin", headers: { Accept: "application/json" } })
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function describe(entry) {
+    var parts = ["#" + entry.seq, new Date(entry.time).toLocaleTimeString()];
+    if (entry.aid) {
+      parts.push(entry.aid);
+    }
+    return parts.join(" · ");
+  }
+
+  function showEmpty(message) {
+    empty.textContent = message;
+    empty.hidden = false;
+    layout.hidden = true;
+  }
+
+  // renderList shows entries (newest first); each opens its snapshot, and
+  // search matches also highlight the matching row.
+  function renderList(items, isSearch) {
+    list.textContent = "";
+    items.forEach(function (item) {
+      var li = document.createElement("li");
+      var button = document.createElement("button");
+      button.type = "button";
+      button.className = "history-item";
+      var label = document.createElement("span");
+      label.className = "history-item-label";
+      label.textContent = describe(item);
+      var title = document.createElement("span");
+      title.className = "history-item-title";
+      title.textContent = isSearch ? "Row " + item.row + ": " + item.line.trim() : item.title;
+      button.appendChild(label);
+      button.appendChild(title);
+      button.addEventListener("click", function () {
+        list.querySelectorAll(".history-item.is-selected").forEach(function (el) {
+          el.classList.remove("is-selected");
+        });
+        button.classList.add("is-selected");
+        openSnapshot(item.seq, isSearch ? item.row : 0);
+      });
+      li.appendChild(button);
+      list.appendChild(li);
+    });
+    empty.hidden = true;
+    layout.hidden = false;
+    var first = list.querySelector(".history-item");
+    if (first) {
+      first.click();
+    }
+  }
+
+  function openSnapshot(seq, matchRow) {
+    selected = seq;
+    showError("");
+    request("/screen/history/" + seq)
+      .then(function (snap) {
+        if (selected !== seq) {
+          return;
+        }
+        var when = new Date(snap.time).toLocaleString();
+        meta.textContent = "Screen #" + snap.seq + " shown " + when +
+          (snap.aid ? " after " + snap.aid : "") + ".";
+        window.ScreenView.render(screen, snap.screen, matchRow);
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  function load(query) {
+    showError("");
+    showAll.hidden = !query;
+    var url = "/screen/history" + (query ? "?q=" + encodeURIComponent(query) : "");
+    request(url)
+      .then(function (body) {
+        if (!body.size) {
+          showEmpty("Screen history is turned off in settings.");
+          return;
+        }
+        if (query) {
+          if (!body.matches.length) {
+            showEmpty("No previous screen contains “" + query + "”.");
+            return;
+          }
+          renderList(body.matches, true);
+          return;
+        }
+        if (!body.entries.length) {
+          showEmpty("No screens recorded yet.");
+          return;
+        }
+        renderList(body.entries.slice().reverse(), false);
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    searchInput.value = "";
+    load("");
+    searchInput.focus();
+  }
+
+  function close() {
+    modal.hidden = true;
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  searchForm.addEventListener("submit", function (event) {
+    event.preventDefault();
+    load(searchInput.value.trim());
+  });
+  showAll.addEventListener("click", function () {
+    searchInput.value = "";
+    load("");
+  });
+  document.querySelectorAll("[data-history-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelectorAll("[data-history-close]").forEach(function (button) {
+    button.addEventListener("click", close);
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+})();
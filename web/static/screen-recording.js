@@ -0,0 +1,137 @@
+(function () {
+  "use strict";
+
+  // Starts and stops the session's screen recording and offers the finished
+  // file for download or for the replay viewer.
+  var modal = document.querySelector("[data-screenrec-modal]");
+  if (!modal) {
+    return;
+  }
+  var state = modal.querySelector("[data-screenrec-state]");
+  var startButton = modal.querySelector("[data-screenrec-start]");
+  var stopButton = modal.querySelector("[data-screenrec-stop]");
+  var download = modal.querySelector("[data-screenrec-download]");
+  var view = modal.querySelector("[data-screenrec-view]");
+  var errorBox = modal.querySelector("[data-screenrec-error]");
+  var badge = document.querySelector("[data-screenrec-badge]");
+  var pollTimer = null;
+  var lastFocused = null;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function formatBytes(n) {
+    if (n < 1024) {
+      return n + " bytes";
+    }
+    if (n < 1024 * 1024) {
+      return (n / 1024).toFixed(1) + " KB";
+    }
+    return (n / (1024 * 1024)).toFixed(1) + " MB";
+  }
+
+  function counts(rec) {
+    return rec.screens + (rec.screens === 1 ? " screen, " : " screens, ") +
+      rec.keys + (rec.keys === 1 ? " key" : " keys");
+  }
+
+  function render(rec) {
+    var active = !!(rec && rec.active);
+    var finished = !!(rec && !rec.active);
+    if (!rec) {
+      state.textContent = "Not recording.";
+    } else if (active) {
+      state.textContent = "Recording since " + new Date(rec.started).toLocaleTimeString() +
+        ": " + counts(rec) + ".";
+    } else {
+      state.textContent = "Recorded " + new Date(rec.started).toLocaleTimeString() + " to " +
+        new Date(rec.stopped).toLocaleTimeString() + ": " + counts(rec) + ", " + formatBytes(rec.bytes) + ".";
+    }
+    if (rec && rec.error) {
+      showError("Recording stopped: " + rec.error);
+    }
+    startButton.hidden = active;
+    stopButton.hidden = !active;
+    download.hidden = !finished;
+    view.hidden = !finished;
+    badge.hidden = !active;
+  }
+
+  // poll refreshes the counts while recording, more often with the dialog
+  // open, so the toolbar dot stays accurate.
+  function poll() {
+    window.clearTimeout(pollTimer);
+    request("/screenrec/status")
+      .then(function (body) {
+        render(body.recording);
+        if (body.recording && body.recording.active) {
+          pollTimer = window.setTimeout(poll, modal.hidden ? 10000 : 2000);
+        }
+      })
+      .catch(function (err) {
+        if (!modal.hidden) {
+          showError(err.message);
+        }
+      });
+  }
+
+  function post(url) {
+    showError("");
+    return request(url, { method: "POST", body: new URLSearchParams() })
+      .then(poll)
+      .catch(function (err) {
+        showError(err.message);
+        poll();
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    showError("");
+    poll();
+  }
+
+  function close() {
+    modal.hidden = true;
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  startButton.addEventListener("click", function () {
+    post("/screenrec/start");
+  });
+  stopButton.addEventListener("click", function () {
+    post("/screenrec/stop");
+  });
+
+  document.querySelectorAll("[data-screenrec-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelectorAll("[data-screenrec-close]").forEach(function (button) {
+    button.addEventListener("click", close);
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+
+  poll();
+})();
@@ -0,0 +1,163 @@
+(function () {
+  "use strict";
+
+  // Streams screen updates from /screen/ws. Pages fall back to polling
+  // /screen/content whenever the socket is not open.
+  var socket = null;
+  var open = false;
+  var stopped = false;
+  var nextId = 1;
+  var pending = {};
+  var reconnectDelayMs = 1000;
+  var maxReconnectDelayMs = 15000;
+  var submitTimeoutMs = 15000;
+  var dirty = false;
+
+  // socketURL is /screen/ws unless the page names another stream, as the
+  // shared-session watch page does.
+  function socketURL() {
+    var scheme = window.location.protocol === "https:" ? "wss:" : "ws:";
+    var path = document.body.getAttribute("data-screen-socket") || "/screen/ws";
+    return scheme + "//" + window.location.host + path;
+  }
+
+  function rejectPending(reason) {
+    Object.keys(pending).forEach(function (id) {
+      var entry = pending[id];
+      delete pending[id];
+      window.clearTimeout(entry.timer);
+      entry.reject(new Error(reason));
+    });
+  }
+
+  function notify(payload) {
+    var event;
+    try {
+      event = new CustomEvent("screen-socket-message", { detail: payload });
+    } catch (err) {
+      return;
+    }
+    document.dispatchEvent(event);
+  }
+
+  function applyPushedScreen(payload) {
+    var forced = (payload.playbackActive && !payload.playbackPaused) || payload.chaosActive;
+    if (dirty && !forced) {
+      // Keep the user's unsent typing; their next submit refreshes the screen.
+      return;
+    }
+    dirty = false;
+    if (typeof window.applyScreenPayload === "function") {
+      window.applyScreenPayload(payload);
+    }
+  }
+
+  function handleMessage(event) {
+    var payload;
+    try {
+      payload = JSON.parse(event.data);
+    } catch (err) {
+      return;
+    }
+    if (!payload || typeof payload.type !== "string") {
+      return;
+    }
+    if (payload.type === "closed") {
+      stopped = true;
+      rejectPending("session closed");
+      return;
+    }
+    if (payload.ack && pending[payload.ack]) {
+      var entry = pending[payload.ack];
+      delete pending[payload.ack];
+      window.clearTimeout(entry.timer);
+      if (payload.type === "error") {
+        entry.reject(new Error(payload.error || "submit failed"));
+      } else {
+        dirty = false;
+        entry.resolve(payload);
+      }
+    } else if (payload.type === "screen" && typeof payload.html === "string") {
+      applyPushedScreen(payload);
+    }
+    notify(payload);
+  }
+
+  function connect() {
+    if (stopped || typeof window.WebSocket !== "function") {
+      return;
+    }
+    try {
+      socket = new WebSocket(socketURL());
+    } catch (err) {
+      return;
+    }
+    socket.onopen = function () {
+      open = true;
+      reconnectDelayMs = 1000;
+    };
+    socket.onmessage = handleMessage;
+    socket.onclose = function () {
+      open = false;
+      socket = null;
+      rejectPending("screen socket closed");
+      if (stopped) {
+        return;
+      }
+      window.setTimeout(connect, reconnectDelayMs);
+      reconnectDelayMs = Math.min(reconnectDelayMs * 2, maxReconnectDelayMs);
+    };
+  }
+
+  function submit(values) {
+    if (!open || !socket) {
+      return Promise.reject(new Error("screen socket not open"));
+    }
+    var id = nextId++;
+    return new Promise(function (resolve, reject) {
+      pending[id] = {
+        resolve: resolve,
+        reject: reject,
+        timer: window.setTimeout(function () {
+          if (pending[id]) {
+            delete pending[id];
+            reject(new Error("submit timed out"));
+          }
+        }, submitTimeoutMs)
+      };
+      try {
+        socket.send(JSON.stringify({ type: "submit", id: id, fields: values }));
+      } catch (err) {
+        window.clearTimeout(pending[id].timer);
+        delete pending[id];
+        reject(err);
+      }
+    });
+  }
+
+  function trackTyping(event) {
+    var container = document.querySelector(".screen-container");
+    if (container && event.target && container.contains(event.target)) {
+      dirty = true;
+    }
+  }
+
+  window.screenSocket = {
+    isOpen: function () {
+      return open;
+    },
+    submit: submit
+  };
+
+  document.addEventListener("input", trackTyping, true);
+  window.addEventListener("beforeunload", function () {
+    stopped = true;
+    if (socket) {
+      socket.close();
+    }
+  });
+
+  if (document.querySelector(".screen-container")) {
+    connect();
+  }
+})();
@@ -0,0 +1,94 @@
+(function () {
+  "use strict";
+
+  // Renders a read-only screen (rows, columns, text and fields, as returned
+  // by the screen history and screen recordings) into a <pre>. Used by the
+  // screen history dialog and the replay viewer.
+
+  // fieldClasses maps each screen position to a class for the field that
+  // covers it, so input and intensified fields stand out.
+  function fieldClasses(view) {
+    var classes = {};
+    (view.fields || []).forEach(function (field) {
+      var cls = "";
+      if (field.hidden) {
+        cls = "screen-view-hidden";
+      } else if (!field.protected) {
+        cls = "screen-view-input";
+      } else if (field.intensified) {
+        cls = "screen-view-bright";
+      }
+      if (!cls) {
+        return;
+      }
+      var start = (field.row - 1) * view.columns + field.column - 1;
+      var end = (field.endRow - 1) * view.columns + field.endColumn - 1;
+      for (var pos = start; pos <= end; pos++) {
+        classes[pos] = cls;
+      }
+    });
+    return classes;
+  }
+
+  // cursorPosition returns the cursor as a 0-based screen position, or -1.
+  function cursorPosition(view) {
+    if (view.cursor) {
+      return (view.cursor.row - 1) * view.columns + view.cursor.column - 1;
+    }
+    if (view.cursorRow) {
+      return (view.cursorRow - 1) * view.columns + view.cursorColumn - 1;
+    }
+    return -1;
+  }
+
+  // render draws view into pre. highlightRow (1-based) marks a row, such as
+  // a search match, and scrolls it into view; options.cursor shows the
+  // cursor position.
+  function render(pre, view, highlightRow, options) {
+    var classes = fieldClasses(view);
+    var cursor = options && options.cursor ? cursorPosition(view) : -1;
+    pre.textContent = "";
+    (view.text || []).forEach(function (line, row) {
+      var rowEl = document.createElement("span");
+      rowEl.className = "screen-view-row" + (row + 1 === highlightRow ? " screen-view-match" : "");
+      var chars = Array.from(line);
+      var runClass = null;
+      var run = "";
+      function flush() {
+        if (!run) {
+          return;
+        }
+        if (runClass) {
+          var span = document.createElement("span");
+          span.className = runClass;
+          span.textContent = run;
+          rowEl.appendChild(span);
+        } else {
+          rowEl.appendChild(document.createTextNode(run));
+        }
+        run = "";
+      }
+      chars.forEach(function (ch, col) {
+        var pos = row * view.columns + col;
+        var cls = classes[pos] || null;
+        if (pos === cursor) {
+          cls = (cls ? cls + " " : "") + "screen-view-cursor";
+        }
+        if (cls !== runClass) {
+          flush();
+          runClass = cls;
+        }
+        run += ch;
+      });
+      flush();
+      pre.appendChild(rowEl);
+      pre.appendChild(document.createTextNode("\n"));
+    });
+    var match = pre.querySelector(".screen-view-match");
+    if (match) {
+      match.scrollIntoView({ block: "nearest" });
+    }
+  }
+
+  window.ScreenView = { render: render };
+})();
@@ -0,0 +1,104 @@
+(function () {
+  "use strict";
+
+  // Warns before the server closes an idle session and offers to keep it.
+  var banner = document.querySelector("[data-session-expiry]");
+  if (!banner) {
+    return;
+  }
+  var message = banner.querySelector("[data-session-expiry-message]");
+  var keepButton = banner.querySelector("[data-session-keepalive]");
+  var reconnectLink = banner.querySelector("[data-session-reconnect]");
+  var pollMs = 30000;
+  var pollTimer = null;
+  var countdownTimer = null;
+  var expiresAt = 0;
+  var expired = false;
+
+  function formatRemaining(seconds) {
+    var minutes = Math.floor(seconds / 60);
+    var rest = seconds % 60;
+    return minutes + ":" + (rest < 10 ? "0" : "") + rest;
+  }
+
+  function showExpired() {
+    expired = true;
+    window.clearInterval(countdownTimer);
+    window.clearTimeout(pollTimer);
+    message.textContent = "Your session expired after a period of inactivity.";
+    keepButton.hidden = true;
+    reconnectLink.hidden = false;
+    banner.hidden = false;
+  }
+
+  function renderCountdown() {
+    var remaining = Math.max(0, Math.round((expiresAt - Date.now()) / 1000));
+    if (remaining <= 0) {
+      refresh();
+      return;
+    }
+    message.textContent =
+      "Your session will close in " + formatRemaining(remaining) + " due to inactivity.";
+  }
+
+  function applyStatus(status) {
+    window.clearInterval(countdownTimer);
+    countdownTimer = null;
+    if (!status || !status.idleTimeoutSec) {
+      banner.hidden = true;
+      return;
+    }
+    expiresAt = Date.now() + status.expiresInSec * 1000;
+    if (status.expiresInSec > status.warningSec) {
+      banner.hidden = true;
+      schedule(Math.min(pollMs, (status.expiresInSec - status.warningSec) * 1000));
+      return;
+    }
+    keepButton.hidden = false;
+    reconnectLink.hidden = true;
+    banner.hidden = false;
+    renderCountdown();
+    countdownTimer = window.setInterval(renderCountdown, 1000);
+    schedule(pollMs);
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        if (response.status === 401) {
+          showExpired();
+          return null;
+        }
+        return response.ok ? response.json() : null;
+      })
+      .catch(function () {
+        return null;
+      });
+  }
+
+  function refresh() {
+    if (expired) {
+      return;
+    }
+    request("/session/status").then(function (status) {
+      if (!expired) {
+        applyStatus(status);
+      }
+    });
+  }
+
+  function schedule(delay) {
+    window.clearTimeout(pollTimer);
+    pollTimer = window.setTimeout(refresh, Math.max(1000, delay));
+  }
+
+  keepButton.addEventListener("click", function () {
+    request("/session/keepalive", { method: "POST" }).then(function (status) {
+      if (!expired) {
+        applyStatus(status);
+      }
+    });
+  });
+
+  refresh();
+})();
@@ -0,0 +1,144 @@
+(function () {
+  "use strict";
+
+  // Session tabs: rename the active session in place, confirm before
+  // closing one, and follow switches made from another browser tab.
+  var tabs = document.querySelector("[data-session-tabs]");
+  if (!tabs) {
+    return;
+  }
+  var sessionID = document.body.getAttribute("data-session-id") || "";
+  var closeConfirmMs = 3000;
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function startRename(button) {
+    var label = button.querySelector("[data-session-name]");
+    if (!label || button.querySelector("input")) {
+      return;
+    }
+    var original = label.textContent;
+    var input = document.createElement("input");
+    input.type = "text";
+    input.maxLength = 40;
+    input.value = original;
+    input.setAttribute("aria-label", "Session name");
+    // Keeps the terminal key handler away while the name is edited.
+    input.setAttribute("data-session-renaming", "");
+    label.hidden = true;
+    button.insertBefore(input, label);
+    input.focus();
+    input.select();
+
+    var done = false;
+    function finish(save) {
+      if (done) {
+        return;
+      }
+      done = true;
+      var name = input.value.trim();
+      input.remove();
+      label.hidden = false;
+      if (!save || !name || name === original) {
+        return;
+      }
+      label.textContent = name;
+      request("/sessions/rename", { method: "POST", body: new URLSearchParams({ id: sessionID, name: name }) })
+        .then(function (body) {
+          label.textContent = body.name;
+        })
+        .catch(function (err) {
+          label.textContent = original;
+          label.title = err.message;
+        });
+    }
+
+    input.addEventListener("keydown", function (event) {
+      if (event.key === "Enter") {
+        event.preventDefault();
+        finish(true);
+      } else if (event.key === "Escape") {
+        event.preventDefault();
+        finish(false);
+      }
+    });
+    input.addEventListener("blur", function () {
+      finish(true);
+    });
+    input.addEventListener("click", function (event) {
+      event.preventDefault();
+      event.stopPropagation();
+    });
+  }
+
+  var active = tabs.querySelector("[data-session-rename]");
+  if (active) {
+    // The active tab is already showing; clicking it only renames.
+    active.addEventListener("click", function (event) {
+      event.preventDefault();
+    });
+    active.addEventListener("dblclick", function (event) {
+      event.preventDefault();
+      startRename(active);
+    });
+  }
+
+  // Closing disconnects from the host, so the first click only arms the
+  // button.
+  tabs.querySelectorAll("[data-session-close]").forEach(function (form) {
+    var button = form.querySelector("button");
+    var timer = null;
+    form.addEventListener("submit", function (event) {
+      if (button.classList.contains("is-armed")) {
+        return;
+      }
+      event.preventDefault();
+      button.classList.add("is-armed");
+      button.textContent = "Close?";
+      timer = window.setTimeout(function () {
+        button.classList.remove("is-armed");
+        button.innerHTML = "&times;";
+      }, closeConfirmMs);
+    });
+    button.addEventListener("blur", function () {
+      if (timer) {
+        window.clearTimeout(timer);
+        timer = null;
+      }
+      button.classList.remove("is-armed");
+      button.innerHTML = "&times;";
+    });
+  });
+
+  // Another browser tab may have switched or closed this session; the
+  // screen form would then be rejected, so reload to the active one.
+  function checkActive() {
+    if (document.visibilityState === "hidden") {
+      return;
+    }
+    request("/sessions")
+      .then(function (body) {
+        if (!body.active) {
+          window.location.href = "/";
+        } else if (body.active !== sessionID) {
+          window.location.reload();
+        }
+      })
+      .catch(function () {
+        // The session expiry banner reports lost sessions.
+      });
+  }
+
+  window.addEventListener("focus", checkActive);
+  document.addEventListener("visibilitychange", checkActive);
+})();
@@ -0,0 +1,24 @@
+(function () {
+  "use strict";
+
+  // Describes shared-session events for the owner's share dialog and the
+  // viewers' watch page.
+  var labels = {
+    "share-started": "Sharing started",
+    "share-stopped": "Sharing stopped",
+    "viewer-joined": "{viewer} started watching",
+    "viewer-left": "{viewer} stopped watching",
+    "control-requested": "{viewer} asked for keyboard control",
+    "control-granted": "{viewer} was given keyboard control",
+    "control-declined": "{viewer}'s request for control was declined",
+    "control-returned": "{viewer} gave back keyboard control",
+    "control-reclaimed": "Keyboard control was taken back from {viewer}"
+  };
+
+  function describe(event) {
+    var label = labels[event.type] || event.type;
+    return label.replace("{viewer}", event.viewer || "A viewer");
+  }
+
+  window.ShareEvents = { describe: describe };
+})();
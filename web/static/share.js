@@ -0,0 +1,202 @@
+(function () {
+  "use strict";
+
+  // Creates and stops the session's share link, lists who is watching, and
+  // answers their requests for keyboard control. While a viewer holds the
+  // keyboard this page is read-only.
+  var modal = document.querySelector("[data-share-modal]");
+  if (!modal) {
+    return;
+  }
+  var form = modal.querySelector("[data-share-form]");
+  var active = modal.querySelector("[data-share-active]");
+  var linkInput = modal.querySelector("[data-share-link]");
+  var copyButton = modal.querySelector("[data-share-copy]");
+  var stopButton = modal.querySelector("[data-share-stop]");
+  var state = modal.querySelector("[data-share-state]");
+  var table = modal.querySelector("[data-share-viewers]");
+  var rows = modal.querySelector("[data-share-viewer-rows]");
+  var eventList = modal.querySelector("[data-share-events]");
+  var errorBox = modal.querySelector("[data-share-error]");
+  var badge = document.querySelector("[data-share-badge]");
+  var banner = document.querySelector("[data-share-banner]");
+  var bannerMessage = document.querySelector("[data-share-banner-message]");
+  var pollTimer = null;
+  var lastFocused = null;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            throw new Error(body.error || "Request failed");
+          }
+          return body;
+        });
+      });
+  }
+
+  function setReadOnly(readOnly) {
+    if (readOnly) {
+      document.body.setAttribute("data-screen-readonly", "");
+    } else {
+      document.body.removeAttribute("data-screen-readonly");
+    }
+  }
+
+  function actionButton(label, action, viewer) {
+    var button = document.createElement("button");
+    button.type = "button";
+    button.textContent = label;
+    button.addEventListener("click", function () {
+      post("/share/" + action, new URLSearchParams({ viewer: viewer.id }));
+    });
+    return button;
+  }
+
+  function renderViewers(share) {
+    rows.textContent = "";
+    share.viewers.forEach(function (viewer) {
+      var row = document.createElement("tr");
+      var name = document.createElement("td");
+      name.textContent = viewer.name;
+      var since = document.createElement("td");
+      since.textContent = new Date(viewer.joined).toLocaleTimeString();
+      var keyboard = document.createElement("td");
+      if (viewer.control) {
+        keyboard.textContent = "Has control";
+      } else if (viewer.requested) {
+        keyboard.appendChild(actionButton("Hand over", "grant", viewer));
+        keyboard.appendChild(actionButton("Decline", "decline", viewer));
+      } else if (share.allowControl) {
+        keyboard.appendChild(actionButton("Hand over", "grant", viewer));
+      } else {
+        keyboard.textContent = "View only";
+      }
+      row.appendChild(name);
+      row.appendChild(since);
+      row.appendChild(keyboard);
+      rows.appendChild(row);
+    });
+    table.hidden = share.viewers.length === 0;
+  }
+
+  function renderEvents(events) {
+    eventList.textContent = "";
+    events.slice().reverse().forEach(function (event) {
+      var li = document.createElement("li");
+      li.textContent = new Date(event.time).toLocaleTimeString() + " " +
+        window.ShareEvents.describe(event);
+      eventList.appendChild(li);
+    });
+  }
+
+  function render(share) {
+    form.hidden = !!share;
+    active.hidden = !share;
+    if (!share) {
+      badge.hidden = true;
+      banner.hidden = true;
+      setReadOnly(false);
+      return;
+    }
+    linkInput.value = window.location.origin + share.url;
+    var watching = share.viewers.length;
+    state.textContent = watching === 0 ? "Nobody is watching yet." :
+      watching + (watching === 1 ? " viewer is" : " viewers are") + " watching.";
+    renderViewers(share);
+    renderEvents(share.events);
+
+    var requests = share.viewers.filter(function (viewer) {
+      return viewer.requested;
+    }).length;
+    badge.hidden = requests === 0;
+    badge.textContent = requests ? String(requests) : "";
+
+    banner.hidden = !share.controller;
+    bannerMessage.textContent = share.controller ?
+      share.controller + " has keyboard control of this session." : "";
+    setReadOnly(!!share.controller);
+  }
+
+  // poll refreshes the share while it is open, so control requests show on
+  // the toolbar badge with the dialog closed.
+  function poll() {
+    window.clearTimeout(pollTimer);
+    request("/share/status")
+      .then(function (body) {
+        render(body.share);
+        if (body.share) {
+          pollTimer = window.setTimeout(poll, modal.hidden ? 5000 : 2000);
+        }
+      })
+      .catch(function (err) {
+        if (!modal.hidden) {
+          showError(err.message);
+        }
+      });
+  }
+
+  function post(url, body) {
+    showError("");
+    return request(url, { method: "POST", body: body || new URLSearchParams() })
+      .then(poll)
+      .catch(function (err) {
+        showError(err.message);
+        poll();
+      });
+  }
+
+  function open() {
+    lastFocused = document.activeElement;
+    modal.hidden = false;
+    showError("");
+    poll();
+  }
+
+  function close() {
+    modal.hidden = true;
+    if (lastFocused) {
+      lastFocused.focus();
+      lastFocused = null;
+    }
+  }
+
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    post("/share/start", new URLSearchParams(new FormData(form)));
+  });
+  stopButton.addEventListener("click", function () {
+    post("/share/stop");
+  });
+  copyButton.addEventListener("click", function () {
+    linkInput.select();
+    if (navigator.clipboard) {
+      navigator.clipboard.writeText(linkInput.value).catch(function () {});
+    }
+  });
+  document.querySelectorAll("[data-share-reclaim]").forEach(function (button) {
+    button.addEventListener("click", function () {
+      post("/share/reclaim");
+    });
+  });
+
+  document.querySelectorAll("[data-share-open]").forEach(function (button) {
+    button.addEventListener("click", open);
+  });
+  modal.querySelectorAll("[data-share-close]").forEach(function (button) {
+    button.addEventListener("click", close);
+  });
+  document.addEventListener("keydown", function (event) {
+    if (event.key === "Escape" && !modal.hidden) {
+      close();
+    }
+  });
+
+  poll();
+})();
@@ -153,6 +153,18 @@
         S3270_UTENV: 'false',
         ALLOW_LOG_ACCESS: 'true',
         APP_USE_KEYPAD: 'false',
+        APP_HOST_ENGINE: 's3270',
+        APP_RECORD_SCREENS: 'false',
+        APP_API_TOKEN: '',
+        APP_SECRETS_KEY: '',
+        APP_SESSION_IDLE_TIMEOUT_MIN: '30',
+        APP_SCREEN_HISTORY_SIZE: '50',
+        APP_OIDC_ISSUER: '',
+        APP_OIDC_CLIENT_ID: '',
+        APP_OIDC_CLIENT_SECRET: '',
+        APP_OIDC_REDIRECT_URL: '',
+        APP_OIDC_ROLE_CLAIM: 'role',
+        APP_OIDC_DEFAULT_ROLE: '',
         CHAOS_MAX_STEPS: '100',
         CHAOS_TIME_BUDGET_SEC: '300',
         CHAOS_STEP_DELAY_SEC: '0.5',
@@ -160,6 +172,9 @@
         CHAOS_MAX_FIELD_LENGTH: '40',
         CHAOS_OUTPUT_FILE: '',
         CHAOS_EXCLUDE_NO_PROGRESS_EVENTS: 'true',
+        CHAOS_STRATEGY: 'random',
+        CHAOS_MASK_RULES: '',
+        CHAOS_AUTO_MASK: 'true',
     };
 
     const modelOptions = [
@@ -302,6 +317,25 @@
             fields: [
                 { key: 'ALLOW_LOG_ACCESS', label: 'Allow log access', type: 'checkbox', helper: 'Enable viewing log output in the UI.' },
                 { key: 'APP_USE_KEYPAD', label: 'Use keypad', type: 'checkbox', helper: 'Show the virtual keypad by default.' },
+                { key: 'APP_HOST_ENGINE', label: 'Connection engine', type: 'select', options: ['s3270', 'native'], helper: 'Default engine on the connect page: the s3270 subprocess or the built-in native TN3270 client.' },
+                { key: 'APP_RECORD_SCREENS', label: 'Record expected screens', type: 'checkbox', helper: 'Store each screen in new recordings so playback can report when the host has drifted.' },
+                { key: 'APP_SESSION_IDLE_TIMEOUT_MIN', label: 'Idle timeout (minutes)', type: 'text', helper: 'Close sessions and their s3270 processes after this many idle minutes. 0 disables expiry.' },
+                { key: 'APP_SCREEN_HISTORY_SIZE', label: 'Screen history size', type: 'text', helper: 'Previous screens each session keeps for the Screen history panel, up to 1000. 0 disables the history.' },
+                { key: 'APP_API_TOKEN', label: 'API token', type: 'password', helper: 'Bearer token for the /api/v1 automation API. Leave empty to disable the API.' },
+                { key: 'APP_SECRETS_KEY', label: 'Secrets key', type: 'password', helper: 'Passphrase for secrets.json, which stores passwords recorded from hidden fields.' },
+            ],
+        },
+        {
+            id: 'auth',
+            title: 'Sign-in',
+            description: 'Single sign-on with an OpenID Connect identity provider. Local accounts are managed with the "3270Web users" command.',
+            fields: [
+                { key: 'APP_OIDC_ISSUER', label: 'OIDC issuer', type: 'text', helper: 'Issuer URL of the identity provider. Setting it requires everyone to sign in. Leave empty to disable single sign-on.' },
+                { key: 'APP_OIDC_CLIENT_ID', label: 'Client ID', type: 'text', helper: 'Client ID registered with the identity provider.' },
+                { key: 'APP_OIDC_CLIENT_SECRET', label: 'Client secret', type: 'password', helper: 'Client secret, if the provider issued one.' },
+                { key: 'APP_OIDC_REDIRECT_URL', label: 'Redirect URL', type: 'text', helper: 'Callback URL registered with the provider, ending in /auth/oidc/callback. Empty derives it from the request.' },
+                { key: 'APP_OIDC_ROLE_CLAIM', label: 'Role claim', type: 'text', helper: 'ID token claim holding user, tester or admin.' },
+                { key: 'APP_OIDC_DEFAULT_ROLE', label: 'Default role', type: 'select', options: ['user', 'tester', 'admin'], allowEmpty: true, helper: 'Role for users whose token names none. Default rejects them.' },
             ],
         },
         {
@@ -316,6 +350,9 @@
                 { key: 'CHAOS_MAX_FIELD_LENGTH', label: 'Max field length', type: 'text', helper: 'Maximum characters generated per input field.' },
                 { key: 'CHAOS_OUTPUT_FILE', label: 'Output file', type: 'text', helper: 'Path to save the learned workflow JSON on stop (leave empty to skip).' },
                 { key: 'CHAOS_EXCLUDE_NO_PROGRESS_EVENTS', label: 'Exclude no-progress events', type: 'checkbox', helper: 'Exclude attempts with no screen transition from chaos event history and attempt detail views.' },
+                { key: 'CHAOS_STRATEGY', label: 'Strategy', type: 'select', options: ['random', 'frontier'], helper: 'random fuzzes the current screen; frontier replays known transitions to reach screens with untried keys or fields, then explores there.' },
+                { key: 'CHAOS_MASK_RULES', label: 'Mask rules', type: 'text', helper: 'Screen regions ignored when telling screens apart, separated by ";": R1C72W8 masks row 1 from column 72 for 8 columns (add H2 for two rows), /\\d\\d:\\d\\d/ masks regex matches.' },
+                { key: 'CHAOS_AUTO_MASK', label: 'Auto-mask volatile regions', type: 'checkbox', helper: 'Also mask short protected text, such as a clock or counter, that changes between visits reached with the same input.' },
             ],
         },
     ];
@@ -1009,6 +1046,7 @@
     const completeIndicator = document.querySelector('[data-chaos-complete-indicator]');
     const statsIndicator = document.querySelector('[data-chaos-stats-indicator]');
     const statsText = document.querySelector('[data-chaos-stats-text]');
+    const findingsCount = document.querySelector('[data-chaos-findings-count]');
     const runsModal = document.querySelector('[data-chaos-runs-modal]');
     const runsModalClose = document.querySelectorAll('[data-chaos-runs-close]');
     const runsList = document.querySelector('[data-chaos-runs-list]');
@@ -1532,6 +1570,15 @@
                 if (status.uniqueInputs > 0) {
                     txt += ` · ${status.uniqueInputs} inputs`;
                 }
+                if (status.strategy === 'frontier') {
+                    txt += ` · ${status.frontierAreas || 0} frontier`;
+                }
+                if (status.findings && status.findings.length > 0) {
+                    txt += ` · ${status.findings.length} findings`;
+                }
+                if (status.maskRules && status.maskRules.length > 0) {
+                    txt += ` · ${status.maskRules.length} masked`;
+                }
                 if (status.error) {
                     txt += ' · error';
                 }
@@ -1541,6 +1588,11 @@
                 statsText.textContent = txt;
             }
         }
+        if (findingsCount) {
+            const count = (status && status.findings) ? status.findings.length : 0;
+            findingsCount.textContent = String(count);
+            findingsCount.hidden = count === 0;
+        }
         syncChaosSectionLayout();
         applyChaosInterlocks();
     };
@@ -1765,6 +1817,17 @@
 
         cfg.excludeNoProgressEvents = getBool('CHAOS_EXCLUDE_NO_PROGRESS_EVENTS', true);
 
+        const strategy = getVal('CHAOS_STRATEGY');
+        if (strategy) {
+            cfg.strategy = strategy;
+        }
+
+        const maskRules = getVal('CHAOS_MASK_RULES');
+        if (maskRules) {
+            cfg.maskRules = maskRules;
+        }
+        cfg.autoMask = getBool('CHAOS_AUTO_MASK', true);
+
         const draftHints = (hintsModal && !hintsModal.hidden) ? collectHintsFromUI() : chaosHints;
         if (Array.isArray(draftHints) && draftHints.length > 0) {
             cfg.hints = draftHints;
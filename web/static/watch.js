@@ -0,0 +1,155 @@
+(function () {
+  "use strict";
+
+  // Keeps a shared-session viewer's page in step with the share: whether
+  // this viewer holds the keyboard, control requests, and the session
+  // events. The screen itself streams through screen-socket.js.
+  var base = document.body.getAttribute("data-watch");
+  if (!base) {
+    return;
+  }
+  var state = document.querySelector("[data-watch-state]");
+  var errorBox = document.querySelector("[data-watch-error]");
+  var requestButton = document.querySelector("[data-watch-request]");
+  var releaseButton = document.querySelector("[data-watch-release]");
+  var eventList = document.querySelector("[data-watch-events]");
+  var container = document.querySelector(".screen-container");
+  var pollTimer = null;
+  var ended = false;
+
+  function showError(message) {
+    errorBox.textContent = message || "";
+    errorBox.hidden = !message;
+  }
+
+  function request(url, options) {
+    return fetch(url, Object.assign({ credentials: "same-origin", headers: { Accept: "application/json" } }, options))
+      .then(function (response) {
+        return response.json().then(function (body) {
+          if (!response.ok) {
+            var err = new Error(body.error || "Request failed");
+            err.status = response.status;
+            throw err;
+          }
+          return body;
+        });
+      });
+  }
+
+  // setReadOnly blocks typing into the screen unless this viewer holds the
+  // keyboard.
+  function setReadOnly(readOnly) {
+    if (readOnly) {
+      document.body.setAttribute("data-screen-readonly", "");
+    } else {
+      document.body.removeAttribute("data-screen-readonly");
+    }
+    container.querySelectorAll("input, textarea").forEach(function (input) {
+      if (input.type !== "hidden") {
+        input.readOnly = readOnly;
+      }
+    });
+  }
+
+  function renderEvents(events) {
+    eventList.textContent = "";
+    (events || []).slice().reverse().forEach(function (event) {
+      var li = document.createElement("li");
+      li.textContent = new Date(event.time).toLocaleTimeString() + " " +
+        window.ShareEvents.describe(event);
+      eventList.appendChild(li);
+    });
+  }
+
+  function render(share) {
+    var others = share.viewers.filter(function (name) {
+      return name !== share.name;
+    });
+    var text;
+    if (share.control) {
+      text = "You have keyboard control.";
+    } else if (share.controller) {
+      text = share.controller + " has keyboard control. Read-only.";
+    } else if (share.requested) {
+      text = "Waiting for " + share.owner + " to hand over the keyboard. Read-only.";
+    } else {
+      text = "Read-only.";
+    }
+    if (others.length) {
+      text += " Also watching: " + others.join(", ") + ".";
+    }
+    state.textContent = text;
+    requestButton.hidden = !share.allowControl || share.control;
+    requestButton.disabled = share.requested;
+    releaseButton.hidden = !share.control;
+    setReadOnly(!share.control);
+    renderEvents(share.events);
+  }
+
+  function end(message) {
+    ended = true;
+    window.clearTimeout(pollTimer);
+    setReadOnly(true);
+    requestButton.hidden = true;
+    releaseButton.hidden = true;
+    state.textContent = message;
+  }
+
+  // poll refreshes the share every two seconds, which also tells the
+  // server this viewer is still watching. Without the screen socket it
+  // refreshes the screen too.
+  function poll() {
+    window.clearTimeout(pollTimer);
+    request(base + "/status")
+      .then(function (body) {
+        render(body.share);
+        if (window.screenSocket && window.screenSocket.isOpen()) {
+          return null;
+        }
+        return request(base + "/content").then(function (payload) {
+          if (typeof window.applyScreenPayload === "function") {
+            window.applyScreenPayload(payload);
+          }
+        });
+      })
+      .then(function () {
+        pollTimer = window.setTimeout(poll, 2000);
+      })
+      .catch(function (err) {
+        if (err.status === 404 || err.status === 403) {
+          end(err.message.charAt(0).toUpperCase() + err.message.slice(1) + ".");
+          return;
+        }
+        showError(err.message);
+        pollTimer = window.setTimeout(poll, 5000);
+      });
+  }
+
+  function post(action) {
+    showError("");
+    request(base + "/control/" + action, { method: "POST", body: new URLSearchParams() })
+      .then(function (body) {
+        render(body.share);
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  requestButton.addEventListener("click", function () {
+    post("request");
+  });
+  releaseButton.addEventListener("click", function () {
+    post("release");
+  });
+
+  // Screens replaced by the socket arrive with editable inputs.
+  new MutationObserver(function () {
+    if (ended || document.body.hasAttribute("data-screen-readonly")) {
+      setReadOnly(true);
+    }
+  }).observe(container, { childList: true, subtree: true });
+
+  setReadOnly(true);
+  poll();
+})();
@@ -181,6 +181,7 @@
         delayRange: statusWidget.querySelector('[data-status-delay-range-line]'),
         delayApplied: statusWidget.querySelector('[data-status-delay-applied-line]'),
         events: statusWidget.querySelector('[data-status-events]'),
+        reports: statusWidget.querySelector('[data-status-reports]'),
       }
     : null;
 
@@ -474,6 +475,9 @@
     body.dataset.chaosActive = lastChaosActive ? 'true' : 'false';
     updatePlaybackControls(payload);
     updateActiveRunRow(payload);
+    if (widgetLines) {
+      setHidden(widgetLines.reports, !payload.playbackReport);
+    }
     if (!trackingEnabled) {
       return;
     }
@@ -512,6 +516,9 @@
         typeText = stoppedAt ? `Status: Complete at ${stoppedAt}` : 'Status: Complete';
       } else if (chaosLastAttempt && chaosLastAttempt.aidKey) {
         typeText = `AID: ${chaosLastAttempt.aidKey}`;
+        if (chaosLastAttempt.navigateTo) {
+          typeText += ` (toward frontier ${chaosLastAttempt.navigateTo})`;
+        }
       } else {
         typeText = '';
       }
@@ -877,6 +884,28 @@
       });
   };
 
+  const screenSocketOpen = () => !!(window.screenSocket && window.screenSocket.isOpen());
+
+  let lastSocketProgress = '';
+  document.addEventListener('screen-socket-message', (event) => {
+    const payload = event.detail;
+    if (!payload) {
+      return;
+    }
+    const progress = [
+      payload.playbackActive,
+      payload.playbackPaused,
+      payload.playbackStep,
+      payload.chaosActive,
+      payload.chaosStepsRun,
+    ].join(':');
+    if (progress === lastSocketProgress) {
+      return;
+    }
+    lastSocketProgress = progress;
+    refreshWorkflowStatus();
+  });
+
   const pollPlayback = () => {
     if (document.visibilityState !== 'visible') {
       playbackPollTimer = window.setTimeout(pollPlayback, playbackSlowMs);
@@ -888,6 +917,10 @@
         const isPaused = payload && payload.playbackPaused;
         const chaosActive = payload && payload.chaosActive;
         const container = document.querySelector('.screen-container');
+        if (screenSocketOpen()) {
+          // The screen socket pushes screen changes as they happen.
+          return false;
+        }
         if ((isActive && !isPaused) || chaosActive) {
           return updateScreenContent(container, { force: true }).then(() => true);
         }